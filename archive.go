@@ -0,0 +1,1551 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 归档与编码工具: tar, gzip, unzip, base64, xxd
+// ==========================================
+
+const (
+	archiveMaxEntries = 4096            // 单次解包最多写入的条目数
+	archiveMaxTotal   = 8 * MaxFileSize // 单次解包最多写入的总字节数
+)
+
+var errQuota = errors.New("超出磁盘限额")
+
+// readLimited 读取全部数据，超过单文件上限时返回 errQuota
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return data, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, errQuota
+	}
+	return data, nil
+}
+
+// setModTime 把刚写入会话层的条目的修改时间改为归档中记录的时间
+func (t *Terminal) setModTime(p string, mt time.Time) {
+	if mt.IsZero() {
+		return
+	}
+	if e, ok := t.FS.GetEntry(p); ok {
+		e.mu.Lock()
+		e.ModTime = mt
+		e.mu.Unlock()
+	}
+}
+
+// extractor 把解包出的文件写入 SessionFS：统一做条目数和总量限额，并把每个文件送入隔离区
+type extractor struct {
+	t       *Terminal
+	tool    string
+	archive string
+	entries int
+	total   int64
+}
+
+func (x *extractor) count(n int64) error {
+	x.entries++
+	x.total += n
+	if x.entries > archiveMaxEntries || x.total > archiveMaxTotal || n > MaxFileSize {
+		return errQuota
+	}
+	return nil
+}
+
+// mkdirAll 逐级创建目录，路径上存在同名文件时失败
+func (x *extractor) mkdirAll(p string, mode os.FileMode) error {
+	if e, ok := x.t.FS.GetEntry(p); ok {
+		if !e.IsDir {
+			return errors.New("不是目录")
+		}
+		return nil
+	}
+	if err := x.mkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+	x.t.FS.Mkdir(p)
+	if mode != 0 && mode.Perm() != 0755 {
+		x.t.FS.Chmod(p, mode.Perm())
+	}
+	return nil
+}
+
+func (x *extractor) dir(p string, mode os.FileMode) error {
+	if err := x.count(0); err != nil {
+		return err
+	}
+	return x.mkdirAll(p, mode)
+}
+
+func (x *extractor) file(p, member string, data []byte, mode os.FileMode, mt time.Time) error {
+	if err := x.count(int64(len(data))); err != nil {
+		return err
+	}
+	if err := x.mkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+	if e, ok := x.t.FS.GetEntry(p); ok && e.IsDir {
+		return errors.New("是一个目录")
+	}
+	mode &= os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	if err := x.t.FS.Write(p, data, mode); err != nil {
+		return err
+	}
+	x.t.setModTime(p, mt)
+	log.Printf("[Archive] %s: %s extracted %s from %s to %s", x.t.Remote, x.tool, member, x.archive, p)
+	CapturePayload(x.tool, x.t.Remote, x.archive+":"+member, data)
+	return nil
+}
+
+// ---------- gzip / gunzip / zcat ----------
+
+// gunzipName 按 gzip 认识的后缀得到解压后的文件名
+func gunzipName(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, s := range []struct{ from, to string }{
+		{".tgz", ".tar"}, {".taz", ".tar"}, {".gz", ""}, {"-gz", ""}, {".z", ""}, {"-z", ""}, {"_z", ""},
+	} {
+		if len(name) > len(s.from) && strings.HasSuffix(lower, s.from) {
+			return name[:len(name)-len(s.from)] + s.to, true
+		}
+	}
+	return "", false
+}
+
+// gzipRatio 计算 gzip -v/-l 显示的压缩率
+func gzipRatio(compressed, original int64) float64 {
+	if original == 0 {
+		return 0
+	}
+	return float64(original-compressed) * 100 / float64(original)
+}
+
+func (t *Terminal) cmdGzip(name string, args []string, in io.Reader, out io.Writer) {
+	decompress := name == "gunzip" || name == "zcat"
+	stdout := name == "zcat"
+	keep, force, list, test, verbose := false, false, false, false, false
+	level := gzip.DefaultCompression
+	var files []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--stdout" || a == "--to-stdout":
+			stdout = true
+		case a == "--decompress" || a == "--uncompress":
+			decompress = true
+		case a == "--keep":
+			keep = true
+		case a == "--force":
+			force = true
+		case a == "--list":
+			list = true
+		case a == "--test":
+			test = true
+		case a == "--verbose":
+			verbose = true
+		case a == "--fast":
+			level = gzip.BestSpeed
+		case a == "--best":
+			level = gzip.BestCompression
+		case a == "--quiet" || a == "--name" || a == "--no-name" || a == "--rsyncable":
+		case a == "-V" || a == "--version":
+			fmt.Fprint(out, "gzip 1.10\nCopyright (C) 2018 Free Software Foundation, Inc.\n"+
+				"Copyright (C) 1993 Jean-loup Gailly.\n"+
+				"This is free software.  You may redistribute copies of it under the terms of\n"+
+				"the GNU General Public License <https://www.gnu.org/licenses/gpl.html>.\n"+
+				"There is NO WARRANTY, to the extent permitted by law.\n\nWritten by Jean-loup Gailly.\n")
+			return
+		case strings.HasPrefix(a, "--"):
+			fmt.Fprintf(t.Stderr, "gzip: unrecognized option '%s'\nTry `gzip --help' for more information.\n", a)
+			t.lastExitCode = 1
+			return
+		case len(a) > 1 && a[0] == '-':
+			bad := byte(0)
+			shortOpts(args, i, "", func(opt byte, _ string) {
+				switch {
+				case opt == 'c':
+					stdout = true
+				case opt == 'd':
+					decompress = true
+				case opt == 'k':
+					keep = true
+				case opt == 'f':
+					force = true
+				case opt == 'l':
+					list = true
+				case opt == 't':
+					test = true
+				case opt == 'v':
+					verbose = true
+				case opt >= '1' && opt <= '9':
+					level = int(opt - '0')
+				case opt == 'q' || opt == 'n' || opt == 'N':
+				default:
+					if bad == 0 {
+						bad = opt
+					}
+				}
+			})
+			if bad != 0 {
+				fmt.Fprintf(t.Stderr, "gzip: invalid option -- '%c'\nTry `gzip --help' for more information.\n", bad)
+				t.lastExitCode = 1
+				return
+			}
+		default:
+			files = append(files, a)
+		}
+	}
+	if list || test {
+		decompress = true
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := 0
+	warn := func(format string, a ...any) {
+		fmt.Fprintf(t.Stderr, "gzip: "+format+"\n", a...)
+		if code == 0 {
+			code = 2
+		}
+	}
+	fail := func(format string, a ...any) {
+		fmt.Fprintf(t.Stderr, "gzip: "+format+"\n", a...)
+		code = 1
+	}
+	if list {
+		fmt.Fprintln(out, "         compressed        uncompressed  ratio uncompressed_name")
+	}
+
+	for _, f := range files {
+		// 标准输入到标准输出：边读边写，适合管道
+		if f == "-" {
+			switch {
+			case decompress:
+				zr, err := gzip.NewReader(in)
+				if err != nil {
+					fail("stdin: not in gzip format")
+					continue
+				}
+				dst := out
+				if test || list {
+					dst = io.Discard
+				}
+				if _, err := io.Copy(dst, io.LimitReader(zr, archiveMaxTotal)); err != nil {
+					fail("stdin: unexpected end of file")
+				}
+			case isTTY(out) && !force:
+				fmt.Fprint(t.Stderr, "gzip: compressed data not written to a terminal. Use -f to force compression.\nFor help, type: gzip -h\n")
+				code = 1
+			default:
+				zw, _ := gzip.NewWriterLevel(out, level)
+				zw.OS = 3
+				io.Copy(zw, io.LimitReader(in, MaxFileSize))
+				zw.Close()
+			}
+			continue
+		}
+
+		p := t.FS.Abs(t.expandTilde(f))
+		e, ok := t.FS.GetEntry(p)
+		if !ok {
+			fail("%s: No such file or directory", f)
+			continue
+		}
+		if e.IsDir {
+			warn("%s is a directory -- ignored", f)
+			continue
+		}
+		e.mu.RLock()
+		data := e.Content
+		e.mu.RUnlock()
+
+		if !decompress {
+			if _, has := gunzipName(f); has && !stdout {
+				warn("%s already has %s suffix -- unchanged", f, path.Ext(f))
+				continue
+			}
+			var buf bytes.Buffer
+			zw, _ := gzip.NewWriterLevel(&buf, level)
+			zw.Name, zw.ModTime, zw.OS = e.Name, e.ModTime, 3
+			zw.Write(data)
+			zw.Close()
+			if stdout {
+				if isTTY(out) && !force {
+					fmt.Fprint(t.Stderr, "gzip: compressed data not written to a terminal. Use -f to force compression.\nFor help, type: gzip -h\n")
+					code = 1
+					return
+				}
+				out.Write(buf.Bytes())
+				continue
+			}
+			dst := p + ".gz"
+			if _, exists := t.FS.GetEntry(dst); exists && !force {
+				warn("%s.gz already exists; not overwritten", f)
+				continue
+			}
+			if err := t.FS.Write(dst, buf.Bytes(), e.Mode); err != nil {
+				fail("%s.gz: Disk quota exceeded", f)
+				continue
+			}
+			t.setModTime(dst, e.ModTime)
+			if !keep {
+				t.FS.Remove(p)
+			}
+			if verbose {
+				fmt.Fprintf(t.Stderr, "%s:\t%5.1f%% -- replaced with %s.gz\n", f, gzipRatio(int64(buf.Len()), int64(len(data))), f)
+			}
+			continue
+		}
+
+		target, known := gunzipName(f)
+		if !known && !stdout && !list && !test {
+			warn("%s: unknown suffix -- ignored", f)
+			continue
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			fail("%s: not in gzip format", f)
+			continue
+		}
+		if list {
+			var size uint32
+			if len(data) >= 4 {
+				size = uint32(data[len(data)-4]) | uint32(data[len(data)-3])<<8 | uint32(data[len(data)-2])<<16 | uint32(data[len(data)-1])<<24
+			}
+			if !known {
+				target = f
+			}
+			fmt.Fprintf(out, "%19d %19d %5.1f%% %s\n", len(data), size, gzipRatio(int64(len(data)), int64(size)), target)
+			continue
+		}
+		content, err := readLimited(zr)
+		switch {
+		case err == errQuota:
+			fail("%s: Disk quota exceeded", f)
+			continue
+		case err != nil:
+			fail("%s: unexpected end of file", f)
+			continue
+		case test:
+			if verbose {
+				fmt.Fprintf(t.Stderr, "%s:\t OK\n", f)
+			}
+			continue
+		case stdout:
+			out.Write(content)
+			continue
+		}
+		dst := t.FS.Abs(target)
+		if _, exists := t.FS.GetEntry(dst); exists && !force {
+			warn("%s already exists; not overwritten", target)
+			continue
+		}
+		mt := zr.ModTime
+		if mt.IsZero() {
+			mt = e.ModTime
+		}
+		x := &extractor{t: t, tool: "gunzip", archive: f}
+		if err := x.file(dst, path.Base(target), content, e.Mode, mt); err != nil {
+			fail("%s: Disk quota exceeded", target)
+			continue
+		}
+		if !keep {
+			t.FS.Remove(p)
+		}
+		if verbose {
+			fmt.Fprintf(t.Stderr, "%s:\t%5.1f%% -- replaced with %s\n", f, gzipRatio(int64(len(data)), int64(len(content))), target)
+		}
+	}
+	t.lastExitCode = code
+}
+
+// ---------- tar ----------
+
+type tarOpts struct {
+	mode                       byte // c、x、t
+	file, dir                  string
+	verbose, gzip, bzip2, xz   bool
+	toStdout, keepOld, absName bool
+	strip                      int
+	excludes, members          []string
+}
+
+func (o *tarOpts) excluded(name string) bool {
+	for _, pat := range o.excludes {
+		if ok, _ := path.Match(pat, path.Base(name)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, strings.TrimSuffix(name, "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+const tarHint = "请用“tar --help”或“tar --usage”获得更多信息。\n"
+
+func (t *Terminal) cmdTar(args []string, in io.Reader, out io.Writer) {
+	o := &tarOpts{}
+	bad := ""
+	setMode := func(m byte) {
+		if o.mode != 0 && o.mode != m {
+			bad = "您不能指定超过一个“-Acdtrux”、“--delete”或“--test-label”选项\n"
+		}
+		o.mode = m
+	}
+	opt := func(c byte, v string) {
+		switch c {
+		case 'c', 'x', 't':
+			setMode(c)
+		case 'f':
+			o.file = v
+		case 'C':
+			o.dir = v
+		case 'v':
+			o.verbose = true
+		case 'z':
+			o.gzip = true
+		case 'j':
+			o.bzip2 = true
+		case 'J':
+			o.xz = true
+		case 'O':
+			o.toStdout = true
+		case 'k':
+			o.keepOld = true
+		case 'P':
+			o.absName = true
+		case 'a', 'p', 'o', 'm', 'h', 'w', 'T', 'X', 'b', 'B', 'S':
+		default:
+			if bad == "" {
+				bad = fmt.Sprintf("无效的选项 -- '%c'\n", c)
+			}
+		}
+	}
+
+	i := 1
+	// 旧式选项：tar xzf a.tgz，需要参数的字母依次消耗后面的参数
+	if len(args) > 1 && args[1] != "" && args[1][0] != '-' {
+		i = 2
+		for j := 0; j < len(args[1]); j++ {
+			c, v := args[1][j], ""
+			if strings.IndexByte("fCTXb", c) >= 0 && i < len(args) {
+				v = args[i]
+				i++
+			}
+			opt(c, v)
+		}
+	}
+	for ; i < len(args) && bad == ""; i++ {
+		a := args[i]
+		long, val, hasVal := strings.Cut(a, "=")
+		next := func() string {
+			if hasVal {
+				return val
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch {
+		case a == "--":
+			o.members = append(o.members, args[i+1:]...)
+			i = len(args)
+		case long == "--create":
+			setMode('c')
+		case long == "--extract" || long == "--get":
+			setMode('x')
+		case long == "--list":
+			setMode('t')
+		case long == "--file":
+			o.file = next()
+		case long == "--directory":
+			o.dir = next()
+		case long == "--gzip" || long == "--gunzip" || long == "--ungzip":
+			o.gzip = true
+		case long == "--bzip2":
+			o.bzip2 = true
+		case long == "--xz":
+			o.xz = true
+		case long == "--verbose":
+			o.verbose = true
+		case long == "--to-stdout":
+			o.toStdout = true
+		case long == "--keep-old-files":
+			o.keepOld = true
+		case long == "--absolute-names":
+			o.absName = true
+		case long == "--strip-components":
+			n, err := strconv.Atoi(next())
+			if err != nil || n < 0 {
+				bad = fmt.Sprintf("无效的组件数 \"%s\"\n", val)
+			}
+			o.strip = n
+		case long == "--exclude":
+			o.excludes = append(o.excludes, next())
+		case long == "--version":
+			fmt.Fprint(out, "tar (GNU tar) 1.34\nCopyright (C) 2021 Free Software Foundation, Inc.\n"+
+				"License GPLv3+: GNU GPL version 3 or later <https://gnu.org/licenses/gpl.html>.\n"+
+				"This is free software: you are free to change and redistribute it.\n"+
+				"There is NO WARRANTY, to the extent permitted by law.\n\nWritten by John Gilmore and Jay Fenlason.\n")
+			return
+		case strings.HasPrefix(a, "--"):
+			// --overwrite、--no-same-owner、--warning= 等不影响结果的选项
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "fCTXb", opt)
+		default:
+			o.members = append(o.members, a)
+		}
+	}
+	if bad == "" && o.mode == 0 {
+		bad = "您必须从“-Acdtrux”、“--delete”或“--test-label”选项中指定一个\n"
+	}
+	if bad != "" {
+		fmt.Fprint(t.Stderr, "tar: "+bad+tarHint)
+		t.lastExitCode = 2
+		return
+	}
+	if o.file == "" {
+		o.file = "-"
+	}
+	base := t.FS.Abs(t.expandTilde(o.dir))
+	if o.dir != "" {
+		if e, ok := t.FS.GetEntry(base); !ok || !e.IsDir {
+			fmt.Fprintf(t.Stderr, "tar: %s：无法 open: 没有那个文件或目录\ntar: 错误不可恢复：现在退出\n", o.dir)
+			t.lastExitCode = 2
+			return
+		}
+	}
+	if o.mode == 'c' {
+		t.tarCreate(o, base, out)
+	} else {
+		t.tarRead(o, base, in, out)
+	}
+}
+
+func (t *Terminal) tarCreate(o *tarOpts, base string, out io.Writer) {
+	if len(o.members) == 0 {
+		fmt.Fprint(t.Stderr, "tar: 谨慎地拒绝创建空归档文件\n"+tarHint)
+		t.lastExitCode = 2
+		return
+	}
+	if o.file == "-" && isTTY(out) {
+		fmt.Fprint(t.Stderr, "tar: 拒绝将归档内容写入终端 (缺少 -f 选项？)\ntar: 错误不可恢复：现在退出\n")
+		t.lastExitCode = 2
+		return
+	}
+	// 归档写到标准输出时，-v 的列表改写到标准错误
+	vout := out
+	if o.file == "-" {
+		vout = t.Stderr
+	}
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if o.gzip {
+		zw = gzip.NewWriter(&buf)
+		zw.OS = 3
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	failed, stripped, full := false, false, false
+	entries := 0
+
+	var add func(p, name string)
+	add = func(p, name string) {
+		if full || o.excluded(name) {
+			return
+		}
+		e, ok := t.FS.GetEntry(p)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "tar: %s：无法 stat: 没有那个文件或目录\n", name)
+			failed = true
+			return
+		}
+		entries++
+		if entries > archiveMaxEntries || buf.Len() > MaxFileSize {
+			full = true
+			return
+		}
+		e.mu.RLock()
+		data := e.Content
+		e.mu.RUnlock()
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(unixMode(e.Mode) & 07777),
+			Uid:     e.UID,
+			Gid:     e.GID,
+			Uname:   t.FS.uidName(e.UID),
+			Gname:   t.FS.gidName(e.GID),
+			ModTime: e.ModTime,
+			Format:  tar.FormatGNU,
+		}
+		if e.IsDir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name = strings.TrimSuffix(name, "/") + "/"
+		} else {
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(data))
+		}
+		if o.verbose {
+			fmt.Fprintln(vout, hdr.Name)
+		}
+		tw.WriteHeader(hdr)
+		if !e.IsDir {
+			tw.Write(data)
+			return
+		}
+		children, _ := t.FS.ListDir(p)
+		for _, c := range children {
+			add(path.Join(p, c.Name), strings.TrimSuffix(name, "/")+"/"+c.Name)
+		}
+	}
+	for _, m := range o.members {
+		name := m
+		if strings.HasPrefix(name, "/") && !o.absName {
+			name = strings.TrimLeft(name, "/")
+			if !stripped {
+				fmt.Fprint(t.Stderr, "tar: 从成员名中删除开头的“/”\n")
+				stripped = true
+			}
+			if name == "" {
+				name = "."
+			}
+		}
+		p := m
+		if !path.IsAbs(p) {
+			p = path.Join(base, t.expandTilde(p))
+		}
+		add(path.Clean(p), name)
+	}
+	tw.Close()
+	if zw != nil {
+		zw.Close()
+	}
+
+	if full {
+		fmt.Fprint(t.Stderr, "tar: 写入归档时出错: 超出磁盘限额\ntar: 错误不可恢复：现在退出\n")
+		t.lastExitCode = 2
+		return
+	}
+	if o.file == "-" {
+		out.Write(buf.Bytes())
+	} else if !t.writeTextFile("tar", t.FS.Abs(t.expandTilde(o.file)), buf.Bytes(), false) {
+		t.lastExitCode = 2
+		return
+	}
+	log.Printf("[Archive] %s: tar created %s (%d bytes)", t.Remote, o.file, buf.Len())
+	t.lastExitCode = 0
+	if failed {
+		fmt.Fprint(t.Stderr, "tar: 由于前次错误，将以上次的错误状态退出\n")
+		t.lastExitCode = 2
+	}
+}
+
+// tarMember 去掉成员名开头的 "/" 和前 strip 级目录，名字中含 ".." 时返回 false
+func tarMember(name string, strip int) (string, bool) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	for _, p := range parts {
+		if p == ".." {
+			return "", false
+		}
+	}
+	if len(parts) <= strip {
+		return "", true
+	}
+	return strings.Join(parts[strip:], "/"), true
+}
+
+func (t *Terminal) tarRead(o *tarOpts, base string, in io.Reader, out io.Writer) {
+	var data []byte
+	if o.file == "-" {
+		data, _ = io.ReadAll(io.LimitReader(in, MaxFileSize))
+	} else {
+		e, ok := t.FS.GetEntry(t.FS.Abs(t.expandTilde(o.file)))
+		if !ok || e.IsDir {
+			reason := "没有那个文件或目录"
+			if ok {
+				reason = "是一个目录"
+			}
+			fmt.Fprintf(t.Stderr, "tar: %s：无法 open: %s\ntar: 错误不可恢复：现在退出\n", o.file, reason)
+			t.lastExitCode = 2
+			return
+		}
+		e.mu.RLock()
+		data = e.Content
+		e.mu.RUnlock()
+	}
+
+	// 和 GNU tar 一样按魔数自动识别压缩格式
+	var r io.Reader = bytes.NewReader(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("\x1f\x8b")):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			break
+		}
+		r = zr
+	case bytes.HasPrefix(data, []byte("BZh")):
+		r = bzip2.NewReader(r)
+	case o.gzip:
+		fmt.Fprint(t.Stderr, "\ngzip: stdin: not in gzip format\ntar: Child returned status 1\ntar: 错误不可恢复：现在退出\n")
+		t.lastExitCode = 2
+		return
+	}
+
+	var filter map[string]bool
+	if len(o.members) > 0 {
+		filter = make(map[string]bool)
+		for _, m := range o.members {
+			filter[strings.TrimSuffix(m, "/")] = false
+		}
+	}
+	matched := func(name string) bool {
+		if filter == nil {
+			return true
+		}
+		name = strings.TrimSuffix(name, "/")
+		for m := range filter {
+			if name == m || strings.HasPrefix(name, m+"/") {
+				filter[m] = true
+				return true
+			}
+		}
+		return false
+	}
+
+	x := &extractor{t: t, tool: "tar", archive: o.file}
+	tr := tar.NewReader(r)
+	failed, stripped := false, false
+	ugsWidth := 19
+	for n := 0; ; n++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			if n == 0 && len(data) > 0 && !bytes.Equal(data[:min(len(data), 512)], make([]byte, min(len(data), 512))) {
+				fmt.Fprint(t.Stderr, "tar: 这似乎不像是一个 tar 归档\ntar: 由于前次错误，将以上次的错误状态退出\n")
+				t.lastExitCode = 2
+				return
+			}
+			break
+		}
+		if err != nil {
+			if n == 0 {
+				fmt.Fprint(t.Stderr, "tar: 这似乎不像是一个 tar 归档\ntar: 由于前次错误，将以上次的错误状态退出\n")
+			} else {
+				fmt.Fprint(t.Stderr, "tar: 归档文件中异常的 EOF\ntar: 错误不可恢复：现在退出\n")
+			}
+			t.lastExitCode = 2
+			return
+		}
+		if !matched(hdr.Name) || o.excluded(hdr.Name) {
+			continue
+		}
+
+		if o.mode == 't' {
+			if !o.verbose {
+				fmt.Fprintln(out, hdr.Name)
+				continue
+			}
+			owner := hdr.Uname + "/" + hdr.Gname
+			if hdr.Uname == "" {
+				owner = strconv.Itoa(hdr.Uid) + "/" + strconv.Itoa(hdr.Gid)
+			}
+			size := strconv.FormatInt(hdr.Size, 10)
+			ugsWidth = max(ugsWidth, len(owner)+1+len(size))
+			line := fmt.Sprintf("%s %s %*s %s %s", modeString(hdr.FileInfo().Mode()), owner,
+				ugsWidth-len(owner)-1, size, hdr.ModTime.Local().Format("2006-01-02 15:04"), hdr.Name)
+			switch hdr.Typeflag {
+			case tar.TypeSymlink:
+				line += " -> " + hdr.Linkname
+			case tar.TypeLink:
+				line += " link to " + hdr.Linkname
+			}
+			fmt.Fprintln(out, line)
+			continue
+		}
+
+		if strings.HasPrefix(hdr.Name, "/") && !o.absName && !stripped {
+			fmt.Fprint(t.Stderr, "tar: 从成员名中删除开头的“/”\n")
+			stripped = true
+		}
+		name, safe := tarMember(hdr.Name, o.strip)
+		if !safe {
+			fmt.Fprintf(t.Stderr, "tar: %s：成员名包含“..”\n", hdr.Name)
+			failed = true
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		if o.verbose && !o.toStdout {
+			fmt.Fprintln(out, hdr.Name)
+		}
+		dst := path.Join(base, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if o.toStdout {
+				continue
+			}
+			err = x.dir(dst, hdr.FileInfo().Mode())
+		case tar.TypeReg, tar.TypeChar, tar.TypeBlock, tar.TypeFifo, tar.TypeCont, '\x00':
+			content, rerr := readLimited(tr)
+			if rerr != nil {
+				err = errQuota
+				break
+			}
+			if o.toStdout {
+				out.Write(content)
+				continue
+			}
+			if _, exists := t.FS.GetEntry(dst); exists && o.keepOld {
+				fmt.Fprintf(t.Stderr, "tar: %s：无法 open: 文件已存在\n", name)
+				failed = true
+				continue
+			}
+			err = x.file(dst, hdr.Name, content, hdr.FileInfo().Mode(), hdr.ModTime)
+		case tar.TypeLink:
+			// 硬链接按内容复制
+			target, ok := tarMember(hdr.Linkname, o.strip)
+			if src, exists := t.FS.GetEntry(path.Join(base, target)); ok && exists && !src.IsDir && !o.toStdout {
+				src.mu.RLock()
+				content := src.Content
+				src.mu.RUnlock()
+				err = x.file(dst, hdr.Name, content, src.Mode, hdr.ModTime)
+			}
+		default:
+			// 会话文件系统不支持符号链接，记录后跳过
+			log.Printf("[Archive] %s: tar skipped %s -> %s", t.Remote, hdr.Name, hdr.Linkname)
+		}
+		if err == errQuota {
+			fmt.Fprintf(t.Stderr, "tar: %s：无法 write: 超出磁盘限额\ntar: 错误不可恢复：现在退出\n", name)
+			t.lastExitCode = 2
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(t.Stderr, "tar: %s：无法 open: %v\n", name, err)
+			failed = true
+		}
+	}
+	for m, found := range filter {
+		if !found {
+			fmt.Fprintf(t.Stderr, "tar: %s：归档中找不到\n", m)
+			failed = true
+		}
+	}
+	t.lastExitCode = 0
+	if failed {
+		fmt.Fprint(t.Stderr, "tar: 由于前次错误，将以上次的错误状态退出\n")
+		t.lastExitCode = 2
+	}
+}
+
+// ---------- unzip ----------
+
+func (t *Terminal) cmdUnzip(args []string, out io.Writer) {
+	list, test, pipe, never, quiet, junk := false, false, false, false, false, false
+	dir, archive := "", ""
+	var members []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if len(a) > 1 && a[0] == '-' {
+			i = shortOpts(args, i, "dPx", func(opt byte, v string) {
+				switch opt {
+				case 'l', 'v', 'Z':
+					list = true
+				case 't':
+					test = true
+				case 'p', 'c':
+					pipe = true
+				case 'o':
+					// 非交互会话里已存在的文件总是直接覆盖
+				case 'n':
+					never = true
+				case 'q':
+					quiet = true
+				case 'j':
+					junk = true
+				case 'd':
+					dir = v
+				}
+			})
+			continue
+		}
+		if archive == "" {
+			archive = a
+		} else {
+			members = append(members, a)
+		}
+	}
+	if archive == "" {
+		fmt.Fprint(out, "UnZip 6.00 of 20 April 2009, by Debian. Original by Info-ZIP.\n\n"+
+			"Usage: unzip [-Z] [-opts[modifiers]] file[.zip] [list] [-x xlist] [-d exdir]\n"+
+			"  Default action is to extract files in list, except those in xlist, to exdir;\n"+
+			"  file[.zip] may be a wildcard.  -Z => ZipInfo mode (\"unzip -Z\" for usage).\n\n"+
+			"  -p  extract files to pipe, no messages     -l  list files (short format)\n"+
+			"  -f  freshen existing files, create none    -t  test compressed archive data\n"+
+			"  -u  update files, create if necessary      -z  display archive comment only\n"+
+			"  -v  list verbosely/show version info       -T  timestamp archive to latest\n"+
+			"  -x  exclude files that follow (in xlist)   -d  extract files into exdir\n")
+		return
+	}
+
+	var e *FileEntry
+	found := ""
+	for _, cand := range []string{archive, archive + ".zip", archive + ".ZIP"} {
+		if ent, ok := t.FS.GetEntry(t.FS.Abs(t.expandTilde(cand))); ok && !ent.IsDir {
+			e, found = ent, cand
+			break
+		}
+	}
+	if e == nil {
+		fmt.Fprintf(t.Stderr, "unzip:  cannot find or open %s, %s.zip or %s.ZIP.\n", archive, archive, archive)
+		t.lastExitCode = 9
+		return
+	}
+	e.mu.RLock()
+	data := e.Content
+	e.mu.RUnlock()
+	if !pipe && !quiet {
+		fmt.Fprintf(out, "Archive:  %s\n", found)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		fmt.Fprintf(t.Stderr, "  End-of-central-directory signature not found.  Either this file is not\n"+
+			"  a zipfile, or it constitutes one disk of a multi-part archive.  In the\n"+
+			"  latter case the central directory and zipfile comment will be found on\n"+
+			"  the last disk(s) of this archive.\n"+
+			"unzip:  cannot find zipfile directory in one of %s or\n"+
+			"        %s.zip, and cannot find %s.ZIP, period.\n", found, found, found)
+		t.lastExitCode = 9
+		return
+	}
+
+	want := func(name string) bool {
+		if len(members) == 0 {
+			return true
+		}
+		for _, m := range members {
+			if ok, _ := path.Match(m, name); ok || m == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if list {
+		fmt.Fprint(out, "  Length      Date    Time    Name\n---------  ---------- -----   ----\n")
+		var total uint64
+		n := 0
+		for _, f := range zr.File {
+			if !want(f.Name) {
+				continue
+			}
+			fmt.Fprintf(out, "%9d  %s   %s\n", f.UncompressedSize64, f.Modified.Format("2006-01-02 15:04"), f.Name)
+			total += f.UncompressedSize64
+			n++
+		}
+		plural := "s"
+		if n == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(out, "---------                     -------\n%9d                     %d file%s\n", total, n, plural)
+		return
+	}
+
+	base := t.FS.Abs(".")
+	if dir != "" {
+		base = t.FS.Abs(t.expandTilde(dir))
+	}
+	x := &extractor{t: t, tool: "unzip", archive: found}
+	code := 0
+	for _, f := range zr.File {
+		if !want(f.Name) {
+			continue
+		}
+		// 去掉绝对路径和 "../"，防止解压到目标目录之外
+		var parts []string
+		dotdot := false
+		for _, p := range strings.Split(f.Name, "/") {
+			switch p {
+			case "..":
+				dotdot = true
+			case "", ".":
+			default:
+				parts = append(parts, p)
+			}
+		}
+		if dotdot && !quiet && !pipe {
+			fmt.Fprintf(out, "warning:  skipped \"../\" path component(s) in %s\n", f.Name)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		name := strings.Join(parts, "/")
+		if junk {
+			name = parts[len(parts)-1]
+		}
+		dst := path.Join(base, name)
+
+		if strings.HasSuffix(f.Name, "/") {
+			if junk || pipe || test {
+				continue
+			}
+			if !quiet {
+				fmt.Fprintf(out, "   creating: %s/\n", path.Join(dir, name))
+			}
+			if err := x.dir(dst, f.Mode()); err != nil {
+				fmt.Fprintf(t.Stderr, "checkdir error:  cannot create %s\n                 Disk quota exceeded\n", name)
+				t.lastExitCode = 50
+				return
+			}
+			continue
+		}
+		if f.Flags&1 != 0 {
+			fmt.Fprintf(out, "   skipping: %-22s  unable to get password\n", f.Name)
+			code = max(code, 1)
+			continue
+		}
+		if _, exists := t.FS.GetEntry(dst); exists && never && !pipe && !test {
+			continue
+		}
+		rc, err := f.Open()
+		var content []byte
+		if err == nil {
+			content, err = readLimited(rc)
+			rc.Close()
+		}
+		if test {
+			status := "OK"
+			if err != nil {
+				status = "bad CRC"
+				code = max(code, 2)
+			}
+			fmt.Fprintf(out, "    testing: %-22s   %s\n", f.Name, status)
+			continue
+		}
+		if err == errQuota || (err == nil && x.total+int64(len(content)) > archiveMaxTotal) {
+			fmt.Fprintf(t.Stderr, "error:  cannot create %s\n        Disk quota exceeded\n", name)
+			t.lastExitCode = 50
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  error:  invalid compressed data to inflate %s\n", f.Name)
+			code = max(code, 2)
+			continue
+		}
+		if pipe {
+			out.Write(content)
+			continue
+		}
+		if !quiet {
+			verb := "  inflating"
+			if f.Method == zip.Store {
+				verb = " extracting"
+			}
+			fmt.Fprintf(out, "%s: %s  \n", verb, path.Join(dir, name))
+		}
+		mode := f.Mode()
+		if mode.Perm() == 0 {
+			mode |= 0644
+		}
+		if err := x.file(dst, f.Name, content, mode, f.Modified); err != nil {
+			fmt.Fprintf(t.Stderr, "error:  cannot create %s\n        Disk quota exceeded\n", name)
+			t.lastExitCode = 50
+			return
+		}
+	}
+	if test && code == 0 {
+		fmt.Fprintf(out, "No errors detected in compressed data of %s.\n", found)
+	}
+	t.lastExitCode = code
+}
+
+// ---------- base64 ----------
+
+// wrapWriter 每 width 列插入一个换行，width 为 0 时不折行
+type wrapWriter struct {
+	w          io.Writer
+	width, col int
+}
+
+func (ww *wrapWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if ww.width <= 0 {
+		return ww.w.Write(p)
+	}
+	for len(p) > 0 {
+		k := min(ww.width-ww.col, len(p))
+		ww.w.Write(p[:k])
+		ww.col += k
+		p = p[k:]
+		if ww.col == ww.width {
+			ww.w.Write([]byte{'\n'})
+			ww.col = 0
+		}
+	}
+	return n, nil
+}
+
+// base64Filter 解码前去掉换行；ignore 时去掉所有非 base64 字符
+type base64Filter struct {
+	r      io.Reader
+	ignore bool
+}
+
+func (f *base64Filter) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		k := 0
+		for _, c := range p[:n] {
+			switch {
+			case c == '\n' || c == '\r':
+			case f.ignore && !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '='):
+			default:
+				p[k] = c
+				k++
+			}
+		}
+		if k > 0 || err != nil {
+			return k, err
+		}
+	}
+}
+
+// cappedBuffer 只保留前 MaxFileSize 字节，用于把解码结果送入隔离区
+type cappedBuffer struct{ bytes.Buffer }
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := MaxFileSize - c.Len(); room > 0 {
+		c.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (t *Terminal) cmdBase64(args []string, in io.Reader, out io.Writer) {
+	decode, ignore := false, false
+	wrap := 76
+	var files []string
+	ok := true
+	setWrap := func(v string) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fmt.Fprintf(t.Stderr, "base64: 无效的折行大小: '%s'\n", v)
+			t.lastExitCode = 1
+			ok = false
+		}
+		wrap = n
+	}
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--decode":
+			decode = true
+		case a == "--ignore-garbage":
+			ignore = true
+		case strings.HasPrefix(a, "--wrap="):
+			setWrap(strings.TrimPrefix(a, "--wrap="))
+		case strings.HasPrefix(a, "--"):
+			t.usageError("base64", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "w", func(opt byte, v string) {
+				switch opt {
+				case 'd':
+					decode = true
+				case 'i':
+					ignore = true
+				case 'w':
+					setWrap(v)
+				default:
+					if ok {
+						t.usageError("base64", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+						ok = false
+					}
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		return
+	}
+	if len(files) > 1 {
+		t.usageError("base64", fmt.Sprintf("额外的操作数 '%s'", files[1]), 1)
+		return
+	}
+	name := "-"
+	if len(files) == 1 {
+		name = files[0]
+	}
+	src, reason := t.openInput(name, in)
+	if reason != "" {
+		fmt.Fprintf(t.Stderr, "base64: %s: %s\n", name, reason)
+		t.lastExitCode = 1
+		return
+	}
+
+	if !decode {
+		ww := &wrapWriter{w: out, width: wrap}
+		enc := base64.NewEncoder(base64.StdEncoding, ww)
+		io.Copy(enc, io.LimitReader(src, MaxFileSize))
+		enc.Close()
+		if ww.col > 0 {
+			out.Write([]byte{'\n'})
+		}
+		return
+	}
+
+	var decoded cappedBuffer
+	dec := base64.NewDecoder(base64.StdEncoding, &base64Filter{r: src, ignore: ignore})
+	_, err := io.Copy(io.MultiWriter(out, &decoded), io.LimitReader(dec, archiveMaxTotal))
+	if decoded.Len() > 0 {
+		CapturePayload("base64", t.Remote, name, decoded.Bytes())
+	}
+	if err != nil {
+		fmt.Fprint(t.Stderr, "base64: 输入无效\n")
+		t.lastExitCode = 1
+	}
+}
+
+// ---------- xxd ----------
+
+// xxdReverse 把 xxd 的十六进制转储还原为二进制；plain 对应 -p 的纯十六进制格式
+func xxdReverse(text []byte, plain bool) []byte {
+	hexVal := func(c byte) int {
+		switch {
+		case c >= '0' && c <= '9':
+			return int(c - '0')
+		case c >= 'a' && c <= 'f':
+			return int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			return int(c-'A') + 10
+		}
+		return -1
+	}
+	var res []byte
+	if plain {
+		hi := -1
+		for _, c := range text {
+			v := hexVal(c)
+			if v < 0 {
+				continue
+			}
+			if hi < 0 {
+				hi = v
+			} else {
+				res = append(res, byte(hi<<4|v))
+				hi = -1
+			}
+			if len(res) >= MaxFileSize {
+				break
+			}
+		}
+		return res
+	}
+	for _, line := range strings.Split(string(text), "\n") {
+		offStr, rest, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		off, err := strconv.ParseInt(strings.TrimSpace(offStr), 16, 64)
+		if err != nil || off < 0 || off >= MaxFileSize {
+			continue
+		}
+		var chunk []byte
+		hi, spaces := -1, 0
+		for i := 0; i < len(rest); i++ {
+			c := rest[i]
+			if c == ' ' || c == '\t' {
+				spaces++
+				// 连续两个空格之后是 ASCII 栏
+				if spaces >= 2 && len(chunk) > 0 {
+					break
+				}
+				continue
+			}
+			v := hexVal(c)
+			if v < 0 {
+				break
+			}
+			spaces = 0
+			if hi < 0 {
+				hi = v
+			} else {
+				chunk = append(chunk, byte(hi<<4|v))
+				hi = -1
+			}
+		}
+		end := int(off) + len(chunk)
+		if end > MaxFileSize {
+			continue
+		}
+		if end > len(res) {
+			res = append(res, make([]byte, end-len(res))...)
+		}
+		copy(res[off:], chunk)
+	}
+	return res
+}
+
+// xxdDump 生成 xxd 默认格式、-p 或 -i 格式的转储
+func xxdDump(data []byte, offset int64, cols, group int, upper, plain, include bool, varName string) string {
+	hexDigits := "0123456789abcdef"
+	if upper {
+		hexDigits = "0123456789ABCDEF"
+	}
+	var b strings.Builder
+	hexByte := func(c byte) {
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	switch {
+	case plain:
+		for i, c := range data {
+			hexByte(c)
+			if (i+1)%cols == 0 || i == len(data)-1 {
+				b.WriteByte('\n')
+			}
+		}
+	case include:
+		if varName != "" {
+			fmt.Fprintf(&b, "unsigned char %s[] = {\n", varName)
+		}
+		for i, c := range data {
+			if i%cols == 0 {
+				b.WriteString("  ")
+			} else {
+				b.WriteByte(' ')
+			}
+			b.WriteString("0x")
+			hexByte(c)
+			switch {
+			case i == len(data)-1:
+				b.WriteByte('\n')
+			case (i+1)%cols == 0:
+				b.WriteString(",\n")
+			default:
+				b.WriteByte(',')
+			}
+		}
+		if varName != "" {
+			fmt.Fprintf(&b, "};\nunsigned int %s_len = %d;\n", varName, len(data))
+		}
+	default:
+		width := cols*2 + (cols+group-1)/group
+		for i := 0; i < len(data); i += cols {
+			row := data[i:min(i+cols, len(data))]
+			fmt.Fprintf(&b, "%08x: ", offset+int64(i))
+			var h strings.Builder
+			for j, c := range row {
+				h.WriteByte(hexDigits[c>>4])
+				h.WriteByte(hexDigits[c&15])
+				if (j+1)%group == 0 || j == len(row)-1 {
+					h.WriteByte(' ')
+				}
+			}
+			fmt.Fprintf(&b, "%-*s ", width, h.String())
+			for _, c := range row {
+				if c >= 0x20 && c < 0x7f {
+					b.WriteByte(c)
+				} else {
+					b.WriteByte('.')
+				}
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// longFlag 返回参数使用的选项写法 (xxd 的 -c16 与 -cols 16 等价)
+func longFlag(a, short, long string) string {
+	if strings.HasPrefix(a, long) {
+		return long
+	}
+	return short
+}
+
+const xxdUsage = `Usage:
+       xxd [options] [infile [outfile]]
+    or
+       xxd -r [-s [-]offset] [-c cols] [-ps] [infile [outfile]]
+Options:
+    -a          toggle autoskip: A single '*' replaces nul-lines. Default off.
+    -c cols     format <cols> octets per line. Default 16 (-i: 12, -ps: 30).
+    -g          number of octets per group in normal output. Default 2 (-e: 4).
+    -h          print this summary.
+    -i          output in C include file style.
+    -l len      stop after <len> octets.
+    -p          output in postscript plain hexdump style.
+    -r          reverse operation: convert (or patch) hexdump into binary.
+    -s [+][-]seek  start at <seek> bytes abs. (or +: rel.) infile offset.
+    -u          use upper case hex letters.
+    -v          show version: "xxd 2021-10-22 by Juergen Weigert et al.".
+`
+
+func (t *Terminal) cmdXxd(args []string, in io.Reader, out io.Writer) {
+	reverse, plain, include, upper := false, false, false, false
+	cols, group := 0, 2
+	limit, seek := int64(-1), int64(0)
+	var files []string
+	num := func(i *int, flag string) (int64, bool) {
+		a := args[*i]
+		v := strings.TrimPrefix(a, flag)
+		if v == "" {
+			if *i+1 >= len(args) {
+				fmt.Fprint(t.Stderr, xxdUsage)
+				return 0, false
+			}
+			*i++
+			v = args[*i]
+		}
+		n, err := strconv.ParseInt(strings.TrimPrefix(v, "+"), 0, 64)
+		if err != nil {
+			fmt.Fprint(t.Stderr, xxdUsage)
+			return 0, false
+		}
+		return n, true
+	}
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		var n int64
+		good := true
+		switch {
+		case a == "-r" || a == "-revert":
+			reverse = true
+		case a == "-p" || a == "-ps" || a == "-postscript" || a == "-plain":
+			plain = true
+		case a == "-rp" || a == "-pr" || a == "-r-p":
+			reverse, plain = true, true
+		case a == "-i" || a == "-include":
+			include = true
+		case a == "-u":
+			upper = true
+		case a == "-a" || a == "-autoskip":
+		case a == "-v" || a == "-version":
+			fmt.Fprintln(t.Stderr, "xxd 2021-10-22 by Juergen Weigert et al.")
+			return
+		case a == "-h" || a == "-help":
+			fmt.Fprint(t.Stderr, xxdUsage)
+			return
+		case strings.HasPrefix(a, "-c"):
+			n, good = num(&i, longFlag(a, "-c", "-cols"))
+			cols = int(n)
+		case strings.HasPrefix(a, "-g"):
+			n, good = num(&i, longFlag(a, "-g", "-groupsize"))
+			group = int(n)
+		case strings.HasPrefix(a, "-l"):
+			n, good = num(&i, longFlag(a, "-l", "-len"))
+			limit = n
+		case strings.HasPrefix(a, "-s"):
+			n, good = num(&i, longFlag(a, "-s", "-seek"))
+			seek = n
+		case a == "-":
+			files = append(files, a)
+		case strings.HasPrefix(a, "-"):
+			fmt.Fprint(t.Stderr, xxdUsage)
+			t.lastExitCode = 1
+			return
+		default:
+			files = append(files, a)
+		}
+		if !good {
+			t.lastExitCode = 1
+			return
+		}
+	}
+	if len(files) > 2 {
+		fmt.Fprint(t.Stderr, xxdUsage)
+		t.lastExitCode = 1
+		return
+	}
+	name := "-"
+	if len(files) > 0 {
+		name = files[0]
+	}
+	src, reason := t.openInput(name, in)
+	if reason != "" {
+		fmt.Fprintf(t.Stderr, "xxd: %s: %s\n", name, map[string]string{"没有那个文件或目录": "No such file or directory", "是一个目录": "Is a directory"}[reason])
+		t.lastExitCode = 2
+		return
+	}
+	data, _ := io.ReadAll(io.LimitReader(src, MaxFileSize))
+
+	var result []byte
+	if reverse {
+		result = xxdReverse(data, plain)
+		if len(result) > 0 {
+			CapturePayload("xxd", t.Remote, name, result)
+		}
+	} else {
+		if seek < 0 {
+			seek = max(int64(len(data))+seek, 0)
+		}
+		data = data[min(seek, int64(len(data))):]
+		if limit >= 0 && limit < int64(len(data)) {
+			data = data[:limit]
+		}
+		switch {
+		case cols > 0:
+		case plain:
+			cols = 30
+		case include:
+			cols = 12
+		default:
+			cols = 16
+		}
+		if group <= 0 {
+			group = cols
+		}
+		varName := ""
+		if include && name != "-" {
+			varName = strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+					return r
+				}
+				return '_'
+			}, name)
+			if varName[0] >= '0' && varName[0] <= '9' {
+				varName = "__" + varName
+			}
+		}
+		result = []byte(xxdDump(data, seek, cols, group, upper, plain, include, varName))
+	}
+
+	if len(files) == 2 {
+		if !t.writeTextFile("xxd", files[1], result, false) {
+			t.lastExitCode = 1
+		}
+		return
+	}
+	out.Write(result)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// 统一认证策略 (SSH / Telnet / RLogin / RExec / FTP 共享)
+// ==========================================
+
+// AuthPolicy 决定一次登录尝试是否成功，并记录所有凭据
+type AuthPolicy struct {
+	mu       sync.Mutex
+	attempts map[string]*authPeer // 来源 IP -> 尝试记录
+
+	// RejectFirst 每个来源 IP 的前 N 次尝试一律失败，
+	// 让暴力破解看起来更像真实主机 (0 表示全部接受)
+	RejectFirst int
+
+	// Accounts 不为 nil 时，SSH 和 Telnet 登录遵循会话中改过的账户密码：
+	// 用 passwd/chpasswd 改过密码或用 useradd 新建的账户只接受 shadow 中的密码。
+	// 所有会话共享同一文件系统，开启后攻击者改密码会把其他来源挡在外面，所以默认关闭，
+	// 用 -honour-accounts 启动时设为 GlobalSessionFS
+	Accounts *SessionFS
+}
+
+const (
+	// authPeerTTL 来源 IP 超过该时长没有新的尝试，重新开始计数
+	authPeerTTL = time.Hour
+	// maxAuthPeers 记录的来源 IP 上限，防止轮换地址的扫描器让表无限增长
+	maxAuthPeers = 65536
+)
+
+type authPeer struct {
+	n    int // 已尝试次数
+	last time.Time
+}
+
+// Auth 全局认证策略
+var Auth = NewAuthPolicy()
+
+func NewAuthPolicy() *AuthPolicy {
+	return &AuthPolicy{
+		attempts: make(map[string]*authPeer),
+	}
+}
+
+// Check 记录凭据并返回是否允许登录
+func (a *AuthPolicy) Check(service, remote, user, pass string) bool {
+	now := time.Now()
+	a.mu.Lock()
+	p := a.attempts[remote]
+	if p == nil || now.Sub(p.last) > authPeerTTL {
+		if p == nil && len(a.attempts) >= maxAuthPeers {
+			a.pruneLocked(now)
+		}
+		p = &authPeer{}
+		a.attempts[remote] = p
+	}
+	p.n++
+	p.last = now
+	n := p.n
+	a.mu.Unlock()
+
+	ok := n > a.RejectFirst
+	if a.Accounts != nil && (service == "ssh" || service == "telnet") {
+		if valid, set := a.Accounts.sessionPassword(user, pass); set {
+			ok = valid
+		}
+	}
+	log.Printf("[Auth] %s %s: user=%q pass=%q attempt=%d accepted=%v", service, remote, user, pass, n, ok)
+	return ok
+}
+
+// pruneLocked 删除过期的来源 IP；仍然超过上限时随机淘汰一半 (map 遍历顺序随机)
+// 调用方需持有 a.mu
+func (a *AuthPolicy) pruneLocked(now time.Time) {
+	for ip, p := range a.attempts {
+		if now.Sub(p.last) > authPeerTTL {
+			delete(a.attempts, ip)
+		}
+	}
+	for ip := range a.attempts {
+		if len(a.attempts) < maxAuthPeers/2 {
+			break
+		}
+		delete(a.attempts, ip)
+	}
+}
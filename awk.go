@@ -0,0 +1,2653 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ==========================================
+// awk 解释器 (行为与 Ubuntu 默认的 mawk 一致，mawk 没有中文翻译)
+// ==========================================
+
+// 单次执行的资源上限，防止死循环和无限增长的字符串、数组拖垮服务器；超出时按运行时错误退出
+const (
+	awkMaxSteps    = 5000000         // 最多运行的语句数
+	awkMaxTime     = 5 * time.Second // 最长运行时间
+	awkMaxString   = MaxFileSize     // 单个字符串值的最大长度
+	awkMaxElements = 1 << 20         // 单个数组的最大元素数
+	awkMaxOutput   = 8 * MaxFileSize // 写到标准输出的总字节数
+)
+
+const awkUsage = "usage: awk [-F value] [-v var=value] [--] 'program text' [file ...]\n" +
+	"usage: awk [-F value] [-v var=value] [-f program-file] [--] [file ...]\n"
+
+// ---------- 词法分析 ----------
+
+const (
+	atEOF = iota
+	atNL
+	atNum
+	atStr
+	atERE
+	atName
+	atBuiltin
+	atKw
+	atOp
+)
+
+type awkTok struct {
+	kind  int
+	s     string
+	n     float64
+	line  int
+	paren bool // 名字后面紧跟 "(" (函数调用)
+}
+
+var awkKeywords = map[string]bool{
+	"BEGIN": true, "END": true, "function": true, "func": true, "if": true, "else": true,
+	"while": true, "for": true, "do": true, "break": true, "continue": true, "next": true,
+	"nextfile": true, "exit": true, "return": true, "delete": true, "in": true,
+	"getline": true, "print": true, "printf": true,
+}
+
+var awkBuiltins = map[string]bool{
+	"length": true, "substr": true, "index": true, "split": true, "sub": true, "gsub": true,
+	"match": true, "sprintf": true, "sin": true, "cos": true, "atan2": true, "exp": true,
+	"log": true, "sqrt": true, "int": true, "rand": true, "srand": true, "tolower": true,
+	"toupper": true, "system": true, "close": true, "fflush": true,
+}
+
+// awkOps 按长度从长到短匹配
+var awkOps = []string{
+	"**=", "+=", "-=", "*=", "/=", "%=", "^=", "==", "<=", ">=", "!=", "++", "--", "&&", "||", ">>", "!~", "**",
+	"{", "}", "(", ")", "[", "]", ";", ",", "+", "-", "*", "/", "%", "^", "!", ">", "<", "|", "?", ":", "~", "$", "=",
+}
+
+type awkSyntaxError struct {
+	line int
+	near string
+	msg  string
+}
+
+func (e *awkSyntaxError) Error() string {
+	if e.msg != "" {
+		return fmt.Sprintf("line %d: %s", e.line, e.msg)
+	}
+	return fmt.Sprintf("line %d: syntax error at or near %s", e.line, e.near)
+}
+
+// awkUnescape 处理字符串常量中的转义
+func awkUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '"', '/', '\\':
+			b.WriteByte(c)
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				for j := 0; j < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; j++ {
+					n = n*8 + int(s[i]-'0')
+					i++
+				}
+				i--
+				b.WriteByte(byte(n))
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+func awkLex(src string) ([]awkTok, error) {
+	var toks []awkTok
+	line := 1
+	// 上一个记号是否是操作数 (决定 / 是除号还是正则)
+	operand := func() bool {
+		if len(toks) == 0 {
+			return false
+		}
+		t := toks[len(toks)-1]
+		switch t.kind {
+		case atNum, atStr, atName, atBuiltin:
+			return true
+		case atOp:
+			return t.s == ")" || t.s == "]" || t.s == "$" || t.s == "++" || t.s == "--"
+		}
+		return false
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+			line++
+		case c == '\n':
+			toks = append(toks, awkTok{kind: atNL, s: "end of line", line: line})
+			line++
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				if src[j] == '\n' {
+					return nil, &awkSyntaxError{line: line, msg: "runaway string constant \"" + src[i+1:j] + " ..."}
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, &awkSyntaxError{line: line, msg: "runaway string constant \"" + src[i+1:j] + " ..."}
+			}
+			toks = append(toks, awkTok{kind: atStr, s: awkUnescape(src[i+1 : j]), line: line})
+			i = j + 1
+		case c == '/' && !operand():
+			j := i + 1
+			inBracket := false
+			for j < len(src) && (src[j] != '/' || inBracket) {
+				switch src[j] {
+				case '\\':
+					j++
+				case '[':
+					inBracket = true
+				case ']':
+					inBracket = false
+				case '\n':
+					return nil, &awkSyntaxError{line: line, msg: "runaway regular expression /" + src[i+1:j] + " ..."}
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, &awkSyntaxError{line: line, msg: "runaway regular expression /" + src[i+1:] + " ..."}
+			}
+			toks = append(toks, awkTok{kind: atERE, s: strings.ReplaceAll(src[i+1:j], `\/`, "/"), line: line})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				k := j + 1
+				if k < len(src) && (src[k] == '+' || src[k] == '-') {
+					k++
+				}
+				if k < len(src) && src[k] >= '0' && src[k] <= '9' {
+					for j = k; j < len(src) && src[j] >= '0' && src[j] <= '9'; j++ {
+					}
+				}
+			}
+			n, _ := strconv.ParseFloat(src[i:j], 64)
+			toks = append(toks, awkTok{kind: atNum, s: src[i:j], n: n, line: line})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			word := src[i:j]
+			kind := atName
+			switch {
+			case awkKeywords[word]:
+				kind = atKw
+				if word == "func" {
+					word = "function"
+				}
+			case awkBuiltins[word]:
+				kind = atBuiltin
+			}
+			toks = append(toks, awkTok{kind: kind, s: word, line: line, paren: j < len(src) && src[j] == '('})
+			i = j
+		default:
+			matched := false
+			for _, op := range awkOps {
+				if strings.HasPrefix(src[i:], op) {
+					if op == "**" {
+						op = "^"
+					} else if op == "**=" {
+						op = "^="
+					}
+					toks = append(toks, awkTok{kind: atOp, s: op, line: line})
+					i += len(op)
+					if op == "^=" && strings.HasPrefix(src[i-len(op):], "**=") {
+						i++
+					}
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &awkSyntaxError{line: line, near: string(c)}
+			}
+		}
+	}
+	return append(toks, awkTok{kind: atEOF, s: "end of file", line: line}), nil
+}
+
+// ---------- 语法树 ----------
+
+const (
+	anNum = iota
+	anStr
+	anRegex // 单独出现的 /re/，匹配 $0
+	anVar
+	anField
+	anIndex
+	anAssign
+	anCond
+	anAnd
+	anOr
+	anNot
+	anNeg
+	anPlus
+	anBinary
+	anConcat
+	anCompare
+	anMatch
+	anIn
+	anIncDec
+	anCall
+	anUserCall
+	anGetline
+	anGroup
+)
+
+type awkNode struct {
+	kind int
+	op   string // 运算符；anIncDec 为 "++x" "x++" "--x" "x--"；anGetline 为 ""、"<" 或 "|"
+	s    string // 常量字符串、变量名、函数名
+	num  float64
+	re   *regexp.Regexp
+	args []*awkNode
+}
+
+const (
+	asExpr = iota
+	asPrint
+	asPrintf
+	asIf
+	asWhile
+	asDo
+	asFor
+	asForIn
+	asBlock
+	asNext
+	asNextFile
+	asExit
+	asBreak
+	asContinue
+	asReturn
+	asDelete
+)
+
+type awkStmt struct {
+	kind       int
+	exprs      []*awkNode
+	cond       *awkNode
+	init, post *awkStmt
+	body, alt  []*awkStmt
+	redir      string // ">"、">>" 或 "|"
+	dest       *awkNode
+	name, arr  string // for-in 的变量和数组，delete 的数组
+}
+
+type awkItem struct {
+	begin, end   bool
+	pat, pat2    *awkNode
+	inRange      bool
+	body         []*awkStmt
+	printDefault bool // 只有模式没有动作
+}
+
+type awkFunc struct {
+	name   string
+	params []string
+	body   []*awkStmt
+}
+
+type awkProgram struct {
+	items []*awkItem
+	funcs map[string]*awkFunc
+}
+
+// ---------- 语法分析 ----------
+
+type awkParser struct {
+	toks  []awkTok
+	pos   int
+	noGT  bool // print 参数中 > 是重定向
+	funcs map[string]*awkFunc
+	calls []awkTok // 用于检查未定义的函数
+}
+
+func (p *awkParser) tok() awkTok { return p.toks[p.pos] }
+
+func (p *awkParser) is(kind int, s string) bool {
+	t := p.toks[p.pos]
+	return t.kind == kind && (s == "" || t.s == s)
+}
+
+func (p *awkParser) isOp(s string) bool { return p.is(atOp, s) }
+
+func (p *awkParser) fail() error {
+	t := p.tok()
+	near := t.s
+	switch t.kind {
+	case atStr:
+		near = strconv.Quote(t.s)
+	case atERE:
+		near = "/" + t.s + "/"
+	}
+	return &awkSyntaxError{line: t.line, near: near}
+}
+
+func (p *awkParser) expectOp(s string) error {
+	if !p.isOp(s) {
+		return p.fail()
+	}
+	p.pos++
+	return nil
+}
+
+func (p *awkParser) skipNL() {
+	for p.is(atNL, "") {
+		p.pos++
+	}
+}
+
+func (p *awkParser) skipTerms() {
+	for p.is(atNL, "") || p.isOp(";") {
+		p.pos++
+	}
+}
+
+func parseAwk(src string) (*awkProgram, error) {
+	toks, err := awkLex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &awkParser{toks: toks, funcs: map[string]*awkFunc{}}
+	prog := &awkProgram{funcs: p.funcs}
+	for {
+		p.skipTerms()
+		if p.is(atEOF, "") {
+			break
+		}
+		if p.is(atKw, "function") {
+			if err := p.function(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		it := &awkItem{}
+		switch {
+		case p.is(atKw, "BEGIN"):
+			it.begin = true
+			p.pos++
+		case p.is(atKw, "END"):
+			it.end = true
+			p.pos++
+		case !p.isOp("{"):
+			if it.pat, err = p.expr(); err != nil {
+				return nil, err
+			}
+			if p.isOp(",") {
+				p.pos++
+				p.skipNL()
+				if it.pat2, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if p.isOp("{") {
+			if it.body, err = p.block(); err != nil {
+				return nil, err
+			}
+		} else if it.begin || it.end {
+			return nil, p.fail()
+		} else {
+			it.printDefault = true
+		}
+		prog.items = append(prog.items, it)
+		if it.printDefault && !p.is(atNL, "") && !p.isOp(";") && !p.is(atEOF, "") {
+			return nil, p.fail()
+		}
+	}
+	for _, c := range p.calls {
+		if p.funcs[c.s] == nil {
+			return nil, &awkSyntaxError{line: c.line, msg: "function " + c.s + " never defined"}
+		}
+	}
+	return prog, nil
+}
+
+func (p *awkParser) function() error {
+	p.pos++
+	if !p.is(atName, "") {
+		return p.fail()
+	}
+	f := &awkFunc{name: p.tok().s}
+	p.pos++
+	if err := p.expectOp("("); err != nil {
+		return err
+	}
+	for !p.isOp(")") {
+		if !p.is(atName, "") {
+			return p.fail()
+		}
+		f.params = append(f.params, p.tok().s)
+		p.pos++
+		if p.isOp(",") {
+			p.pos++
+			p.skipNL()
+		} else if !p.isOp(")") {
+			return p.fail()
+		}
+	}
+	p.pos++
+	p.skipNL()
+	body, err := p.block()
+	if err != nil {
+		return err
+	}
+	f.body = body
+	p.funcs[f.name] = f
+	return nil
+}
+
+func (p *awkParser) block() ([]*awkStmt, error) {
+	if err := p.expectOp("{"); err != nil {
+		return nil, err
+	}
+	var list []*awkStmt
+	for {
+		p.skipTerms()
+		if p.isOp("}") {
+			p.pos++
+			return list, nil
+		}
+		if p.is(atEOF, "") {
+			return nil, p.fail()
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+}
+
+// body 解析 if/while/for 的语句体 (可以是单独的 ;)
+func (p *awkParser) body() ([]*awkStmt, error) {
+	p.skipNL()
+	if p.isOp(";") {
+		p.pos++
+		return nil, nil
+	}
+	s, err := p.stmt()
+	if err != nil {
+		return nil, err
+	}
+	if s.kind == asBlock {
+		return s.body, nil
+	}
+	return []*awkStmt{s}, nil
+}
+
+// endSimple 简单语句之后必须是 ;、换行、} 或文件结束
+func (p *awkParser) endSimple() error {
+	switch {
+	case p.isOp(";") || p.is(atNL, ""):
+		p.pos++
+	case p.isOp("}") || p.is(atEOF, ""):
+	default:
+		return p.fail()
+	}
+	return nil
+}
+
+func (p *awkParser) stmt() (*awkStmt, error) {
+	t := p.tok()
+	if t.kind == atOp && t.s == "{" {
+		body, err := p.block()
+		return &awkStmt{kind: asBlock, body: body}, err
+	}
+	if t.kind == atKw {
+		switch t.s {
+		case "if":
+			p.pos++
+			s := &awkStmt{kind: asIf}
+			var err error
+			if s.cond, err = p.paren(); err != nil {
+				return nil, err
+			}
+			if s.body, err = p.body(); err != nil {
+				return nil, err
+			}
+			save := p.pos
+			p.skipTerms()
+			if p.is(atKw, "else") {
+				p.pos++
+				if s.alt, err = p.body(); err != nil {
+					return nil, err
+				}
+			} else {
+				p.pos = save
+			}
+			return s, nil
+		case "while":
+			p.pos++
+			s := &awkStmt{kind: asWhile}
+			var err error
+			if s.cond, err = p.paren(); err != nil {
+				return nil, err
+			}
+			s.body, err = p.body()
+			return s, err
+		case "do":
+			p.pos++
+			s := &awkStmt{kind: asDo}
+			var err error
+			if s.body, err = p.body(); err != nil {
+				return nil, err
+			}
+			p.skipTerms()
+			if !p.is(atKw, "while") {
+				return nil, p.fail()
+			}
+			p.pos++
+			if s.cond, err = p.paren(); err != nil {
+				return nil, err
+			}
+			return s, p.endSimple()
+		case "for":
+			return p.forStmt()
+		}
+	}
+	if t.kind == atOp && t.s == ";" {
+		p.pos++
+		return &awkStmt{kind: asBlock}, nil
+	}
+	s, err := p.simple()
+	if err != nil {
+		return nil, err
+	}
+	return s, p.endSimple()
+}
+
+func (p *awkParser) paren() (*awkNode, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	e, err := p.exprNoGT(false)
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expectOp(")")
+}
+
+func (p *awkParser) forStmt() (*awkStmt, error) {
+	p.pos++
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	// for (k in arr)
+	if p.is(atName, "") && p.toks[p.pos+1].kind == atKw && p.toks[p.pos+1].s == "in" &&
+		p.toks[p.pos+2].kind == atName && p.toks[p.pos+3].kind == atOp && p.toks[p.pos+3].s == ")" {
+		s := &awkStmt{kind: asForIn, name: p.tok().s, arr: p.toks[p.pos+2].s}
+		p.pos += 4
+		var err error
+		s.body, err = p.body()
+		return s, err
+	}
+	s := &awkStmt{kind: asFor}
+	var err error
+	if !p.isOp(";") {
+		if s.init, err = p.simple(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expectOp(";"); err != nil {
+		return nil, err
+	}
+	p.skipNL()
+	if !p.isOp(";") {
+		if s.cond, err = p.exprNoGT(false); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expectOp(";"); err != nil {
+		return nil, err
+	}
+	p.skipNL()
+	if !p.isOp(")") {
+		if s.post, err = p.simple(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	s.body, err = p.body()
+	return s, err
+}
+
+func (p *awkParser) simple() (*awkStmt, error) {
+	t := p.tok()
+	if t.kind == atKw {
+		switch t.s {
+		case "print", "printf":
+			return p.print()
+		case "next":
+			p.pos++
+			return &awkStmt{kind: asNext}, nil
+		case "nextfile":
+			p.pos++
+			return &awkStmt{kind: asNextFile}, nil
+		case "break":
+			p.pos++
+			return &awkStmt{kind: asBreak}, nil
+		case "continue":
+			p.pos++
+			return &awkStmt{kind: asContinue}, nil
+		case "exit", "return":
+			p.pos++
+			s := &awkStmt{kind: asExit}
+			if t.s == "return" {
+				s.kind = asReturn
+			}
+			if !p.isOp(";") && !p.isOp("}") && !p.is(atNL, "") && !p.is(atEOF, "") {
+				e, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				s.exprs = []*awkNode{e}
+			}
+			return s, nil
+		case "delete":
+			p.pos++
+			if !p.is(atName, "") {
+				return nil, p.fail()
+			}
+			s := &awkStmt{kind: asDelete, arr: p.tok().s}
+			p.pos++
+			if p.isOp("[") {
+				subs, err := p.subscripts()
+				if err != nil {
+					return nil, err
+				}
+				s.exprs = subs
+			}
+			return s, nil
+		}
+	}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &awkStmt{kind: asExpr, exprs: []*awkNode{e}}, nil
+}
+
+func (p *awkParser) print() (*awkStmt, error) {
+	s := &awkStmt{kind: asPrint}
+	if p.tok().s == "printf" {
+		s.kind = asPrintf
+	}
+	p.pos++
+	if !p.isOp(";") && !p.isOp("}") && !p.isOp(">") && !p.isOp(">>") && !p.isOp("|") && !p.is(atNL, "") && !p.is(atEOF, "") {
+		for {
+			e, err := p.exprNoGT(true)
+			if err != nil {
+				return nil, err
+			}
+			s.exprs = append(s.exprs, e)
+			if !p.isOp(",") {
+				break
+			}
+			p.pos++
+			p.skipNL()
+		}
+	}
+	// print (a, b) 中的括号是参数列表
+	if len(s.exprs) == 1 && s.exprs[0].kind == anGroup {
+		s.exprs = s.exprs[0].args
+	}
+	if s.kind == asPrintf && len(s.exprs) == 0 {
+		return nil, p.fail()
+	}
+	if p.isOp(">") || p.isOp(">>") || p.isOp("|") {
+		s.redir = p.tok().s
+		p.pos++
+		save := p.noGT
+		p.noGT = true
+		dest, err := p.concat()
+		p.noGT = save
+		if err != nil {
+			return nil, err
+		}
+		s.dest = dest
+	}
+	return s, nil
+}
+
+func (p *awkParser) subscripts() ([]*awkNode, error) {
+	p.pos++ // [
+	var subs []*awkNode
+	for {
+		e, err := p.exprNoGT(false)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, e)
+		if p.isOp("]") {
+			p.pos++
+			return subs, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+		p.skipNL()
+	}
+}
+
+// exprNoGT 在指定的 > 处理方式下解析表达式
+func (p *awkParser) exprNoGT(noGT bool) (*awkNode, error) {
+	save := p.noGT
+	p.noGT = noGT
+	e, err := p.expr()
+	p.noGT = save
+	return e, err
+}
+
+func isLvalue(n *awkNode) bool {
+	return n.kind == anVar || n.kind == anField || n.kind == anIndex
+}
+
+func (p *awkParser) expr() (*awkNode, error) {
+	left, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.tok(); t.kind == atOp && isLvalue(left) {
+		switch t.s {
+		case "=", "+=", "-=", "*=", "/=", "%=", "^=":
+			p.pos++
+			p.skipNL()
+			right, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return &awkNode{kind: anAssign, op: t.s, args: []*awkNode{left, right}}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *awkParser) ternary() (*awkNode, error) {
+	cond, err := p.or()
+	if err != nil || !p.isOp("?") {
+		return cond, err
+	}
+	p.pos++
+	p.skipNL()
+	a, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipNL()
+	if err := p.expectOp(":"); err != nil {
+		return nil, err
+	}
+	p.skipNL()
+	b, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &awkNode{kind: anCond, args: []*awkNode{cond, a, b}}, nil
+}
+
+func (p *awkParser) or() (*awkNode, error) {
+	left, err := p.and()
+	for err == nil && p.isOp("||") {
+		p.pos++
+		p.skipNL()
+		var right *awkNode
+		if right, err = p.and(); err == nil {
+			left = &awkNode{kind: anOr, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) and() (*awkNode, error) {
+	left, err := p.in()
+	for err == nil && p.isOp("&&") {
+		p.pos++
+		p.skipNL()
+		var right *awkNode
+		if right, err = p.in(); err == nil {
+			left = &awkNode{kind: anAnd, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) in() (*awkNode, error) {
+	left, err := p.match()
+	for err == nil && p.is(atKw, "in") {
+		p.pos++
+		if !p.is(atName, "") {
+			return nil, p.fail()
+		}
+		subs := []*awkNode{left}
+		if left.kind == anGroup {
+			subs = left.args
+		}
+		left = &awkNode{kind: anIn, s: p.tok().s, args: subs}
+		p.pos++
+	}
+	return left, err
+}
+
+func (p *awkParser) match() (*awkNode, error) {
+	left, err := p.compare()
+	for err == nil && (p.isOp("~") || p.isOp("!~")) {
+		op := p.tok().s
+		p.pos++
+		var right *awkNode
+		if right, err = p.compare(); err == nil {
+			left = &awkNode{kind: anMatch, op: op, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) compare() (*awkNode, error) {
+	left, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	// cmd | getline [var]
+	for p.isOp("|") && p.toks[p.pos+1].kind == atKw && p.toks[p.pos+1].s == "getline" {
+		p.pos += 2
+		g := &awkNode{kind: anGetline, op: "|", args: []*awkNode{left, nil}}
+		if p.is(atName, "") || p.isOp("$") {
+			if g.args[1], err = p.postfix(); err != nil {
+				return nil, err
+			}
+		}
+		left = g
+	}
+	t := p.tok()
+	if t.kind == atOp {
+		switch t.s {
+		case "<", "<=", "==", "!=", ">=", ">":
+			if t.s == ">" && p.noGT {
+				return left, nil
+			}
+			p.pos++
+			right, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			return &awkNode{kind: anCompare, op: t.s, args: []*awkNode{left, right}}, nil
+		}
+	}
+	return left, nil
+}
+
+// startsOperand 判断当前记号能否开始一个被连接的操作数
+func (p *awkParser) startsOperand() bool {
+	t := p.tok()
+	switch t.kind {
+	case atNum, atStr, atERE, atName, atBuiltin:
+		return true
+	case atOp:
+		return t.s == "$" || t.s == "(" || t.s == "++" || t.s == "--"
+	case atKw:
+		return t.s == "getline"
+	}
+	return false
+}
+
+func (p *awkParser) concat() (*awkNode, error) {
+	left, err := p.additive()
+	for err == nil && p.startsOperand() {
+		var right *awkNode
+		if right, err = p.additive(); err == nil {
+			left = &awkNode{kind: anConcat, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) additive() (*awkNode, error) {
+	left, err := p.mul()
+	for err == nil && (p.isOp("+") || p.isOp("-")) {
+		op := p.tok().s
+		p.pos++
+		var right *awkNode
+		if right, err = p.mul(); err == nil {
+			left = &awkNode{kind: anBinary, op: op, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) mul() (*awkNode, error) {
+	left, err := p.unary()
+	for err == nil && (p.isOp("*") || p.isOp("/") || p.isOp("%")) {
+		op := p.tok().s
+		p.pos++
+		var right *awkNode
+		if right, err = p.unary(); err == nil {
+			left = &awkNode{kind: anBinary, op: op, args: []*awkNode{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *awkParser) unary() (*awkNode, error) {
+	switch {
+	case p.isOp("!"):
+		p.pos++
+		e, err := p.unary()
+		return &awkNode{kind: anNot, args: []*awkNode{e}}, err
+	case p.isOp("-"):
+		p.pos++
+		e, err := p.unary()
+		return &awkNode{kind: anNeg, args: []*awkNode{e}}, err
+	case p.isOp("+"):
+		p.pos++
+		e, err := p.unary()
+		return &awkNode{kind: anPlus, args: []*awkNode{e}}, err
+	}
+	return p.pow()
+}
+
+func (p *awkParser) pow() (*awkNode, error) {
+	base, err := p.postfix()
+	if err != nil || !p.isOp("^") {
+		return base, err
+	}
+	p.pos++
+	exp, err := p.unary() // 右结合，允许 2^-1
+	if err != nil {
+		return nil, err
+	}
+	return &awkNode{kind: anBinary, op: "^", args: []*awkNode{base, exp}}, nil
+}
+
+func (p *awkParser) postfix() (*awkNode, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if isLvalue(e) && (p.isOp("++") || p.isOp("--")) {
+		op := "x" + p.tok().s
+		p.pos++
+		return &awkNode{kind: anIncDec, op: op, args: []*awkNode{e}}, nil
+	}
+	return e, nil
+}
+
+func (p *awkParser) primary() (*awkNode, error) {
+	t := p.tok()
+	switch t.kind {
+	case atNum:
+		p.pos++
+		return &awkNode{kind: anNum, num: t.n}, nil
+	case atStr:
+		p.pos++
+		return &awkNode{kind: anStr, s: t.s}, nil
+	case atERE:
+		p.pos++
+		re, err := sedRegexp(t.s, true, false)
+		if err != nil {
+			return nil, &awkSyntaxError{line: t.line, msg: "regular expression compile failed (" + err.Error() + ")\n" + t.s}
+		}
+		return &awkNode{kind: anRegex, s: t.s, re: re}, nil
+	case atName:
+		p.pos++
+		if p.isOp("[") {
+			subs, err := p.subscripts()
+			return &awkNode{kind: anIndex, s: t.s, args: subs}, err
+		}
+		if t.paren {
+			p.calls = append(p.calls, t)
+			args, err := p.callArgs()
+			return &awkNode{kind: anUserCall, s: t.s, args: args}, err
+		}
+		return &awkNode{kind: anVar, s: t.s}, nil
+	case atBuiltin:
+		p.pos++
+		n := &awkNode{kind: anCall, s: t.s}
+		if p.isOp("(") {
+			var err error
+			if n.args, err = p.callArgs(); err != nil {
+				return nil, err
+			}
+		} else if t.s != "length" {
+			return nil, p.fail()
+		}
+		return n, nil
+	case atKw:
+		if t.s != "getline" {
+			return nil, p.fail()
+		}
+		p.pos++
+		g := &awkNode{kind: anGetline, args: []*awkNode{nil, nil}}
+		var err error
+		if p.is(atName, "") || p.isOp("$") {
+			if g.args[1], err = p.postfix(); err != nil {
+				return nil, err
+			}
+		}
+		if p.isOp("<") {
+			p.pos++
+			g.op = "<"
+			if g.args[0], err = p.postfix(); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	case atOp:
+		switch t.s {
+		case "$":
+			p.pos++
+			var e *awkNode
+			var err error
+			if p.isOp("++") || p.isOp("--") || p.isOp("-") {
+				e, err = p.unary()
+			} else {
+				e, err = p.primary()
+			}
+			return &awkNode{kind: anField, args: []*awkNode{e}}, err
+		case "++", "--":
+			p.pos++
+			e, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			if !isLvalue(e) {
+				return nil, p.fail()
+			}
+			return &awkNode{kind: anIncDec, op: t.s + "x", args: []*awkNode{e}}, nil
+		case "(":
+			p.pos++
+			var list []*awkNode
+			for {
+				e, err := p.exprNoGT(false)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, e)
+				if p.isOp(")") {
+					p.pos++
+					break
+				}
+				if err := p.expectOp(","); err != nil {
+					return nil, err
+				}
+				p.skipNL()
+			}
+			if len(list) == 1 {
+				return &awkNode{kind: anGroup, args: list, op: "("}, nil
+			}
+			return &awkNode{kind: anGroup, args: list}, nil
+		case "-", "+", "!":
+			return p.unary()
+		}
+	}
+	return nil, p.fail()
+}
+
+func (p *awkParser) callArgs() ([]*awkNode, error) {
+	p.pos++ // (
+	var args []*awkNode
+	for !p.isOp(")") {
+		e, err := p.exprNoGT(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+		if p.isOp(",") {
+			p.pos++
+			p.skipNL()
+		} else if !p.isOp(")") {
+			return nil, p.fail()
+		}
+	}
+	p.pos++
+	return args, nil
+}
+
+// ---------- 值 ----------
+
+// awkVal 的 kind：0 未初始化，'n' 数值，'s' 字符串，'m' 来自输入的字符串 (看起来像数字时按数值比较)
+type awkVal struct {
+	kind byte
+	n    float64
+	s    string
+}
+
+type awkArray map[string]awkVal
+
+func awkNum(n float64) awkVal  { return awkVal{kind: 'n', n: n} }
+func awkStr(s string) awkVal   { return awkVal{kind: 's', s: s} }
+func awkInput(s string) awkVal { return awkVal{kind: 'm', s: s} }
+
+// awkStrtod 解析字符串开头的数字 (C 的 strtod 语义)，没有数字时为 0
+func awkStrtod(s string) float64 {
+	s = strings.TrimLeft(s, " \t\n\r\f\v")
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := false
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i, digits = i+1, true
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i, digits = i+1, true
+		}
+	}
+	if !digits {
+		return 0
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for i = j; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			}
+		}
+	}
+	n, _ := strconv.ParseFloat(strings.TrimRight(s[:i], "."), 64)
+	if strings.HasSuffix(s[:i], ".") {
+		n, _ = strconv.ParseFloat(s[:i]+"0", 64)
+	}
+	return n
+}
+
+// looksNumeric 判断输入字符串整体是否是一个数字
+func looksNumeric(s string) bool {
+	s = strings.Trim(s, " \t\n")
+	if s == "" {
+		return false
+	}
+	if c := s[len(s)-1]; c != '.' && (c < '0' || c > '9') {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && !strings.ContainsAny(s, "xXnN_")
+}
+
+func (v awkVal) num() float64 {
+	switch v.kind {
+	case 'n':
+		return v.n
+	case 's', 'm':
+		return awkStrtod(v.s)
+	}
+	return 0
+}
+
+// numeric 判断比较时是否按数值处理
+func (v awkVal) numeric() bool {
+	return v.kind == 'n' || v.kind == 0 || v.kind == 'm' && looksNumeric(v.s)
+}
+
+func (v awkVal) bool() bool {
+	switch v.kind {
+	case 'n':
+		return v.n != 0
+	case 's':
+		return v.s != ""
+	case 'm':
+		if looksNumeric(v.s) {
+			return awkStrtod(v.s) != 0
+		}
+		return v.s != ""
+	}
+	return false
+}
+
+// awkFormatNum 数值转字符串：整数直接输出，否则使用 CONVFMT/OFMT
+func awkFormatNum(n float64, format string) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e16 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	if math.IsNaN(n) {
+		return "nan"
+	}
+	if math.IsInf(n, 0) {
+		if n > 0 {
+			return "inf"
+		}
+		return "-inf"
+	}
+	return awkSprintf(format, []awkVal{awkNum(n)}, nil)
+}
+
+// ---------- 解释执行 ----------
+
+type awkFrame struct {
+	vars map[string]awkVal
+	arrs map[string]awkArray
+}
+
+// 用 panic 实现跨越函数调用的 next / exit 以及运行时错误
+type awkNextPanic struct{ file bool }
+type awkExitPanic struct{}
+type awkErrorPanic struct{ msg string }
+
+type awkFlow int
+
+const (
+	awkFlowNone awkFlow = iota
+	awkFlowBreak
+	awkFlowContinue
+	awkFlowReturn
+)
+
+type awkInterp struct {
+	t      *Terminal
+	prog   *awkProgram
+	out    io.Writer
+	in     io.Reader
+	vars   map[string]awkVal
+	arrs   map[string]awkArray
+	frames []*awkFrame
+	retval awkVal
+
+	record    string
+	fields    []string // fields[0] 未使用
+	split     bool
+	nf        int
+	files     map[string]*bytes.Buffer
+	fileOrder []string
+	appendTo  map[string]bool
+	pipes     map[string]*bytes.Buffer
+	pipeOrder []string
+	readers   map[string]*lineReader // getline < 文件、cmd | getline
+	regexes   map[string]*regexp.Regexp
+
+	input    *lineReader
+	operands []string
+	argIdx   int
+	steps    int
+	deadline time.Time
+	written  int // 已写到标准输出的字节数
+	exitCode int
+	inEnd    bool
+	rng      *rand.Rand
+	seed     float64
+}
+
+func (a *awkInterp) runtimeError(format string, args ...any) {
+	panic(awkErrorPanic{fmt.Sprintf(format, args...)})
+}
+
+func (a *awkInterp) step() {
+	a.steps++
+	if a.steps > awkMaxSteps || a.steps%1024 == 0 && time.Now().After(a.deadline) {
+		a.runtimeError("execution limit exceeded")
+	}
+}
+
+// checkSize 在字符串值超过 awkMaxString 时报错
+func (a *awkInterp) checkSize(n int) {
+	if n > awkMaxString {
+		a.runtimeError("out of memory")
+	}
+}
+
+func (a *awkInterp) frame() *awkFrame {
+	if len(a.frames) == 0 {
+		return nil
+	}
+	return a.frames[len(a.frames)-1]
+}
+
+func (a *awkInterp) getVar(name string) awkVal {
+	if f := a.frame(); f != nil {
+		if v, ok := f.vars[name]; ok {
+			return v
+		}
+	}
+	switch name {
+	case "NF":
+		a.splitRecord()
+		return awkNum(float64(a.nf))
+	}
+	return a.vars[name]
+}
+
+func (a *awkInterp) setVar(name string, v awkVal) {
+	if f := a.frame(); f != nil {
+		if _, ok := f.vars[name]; ok {
+			f.vars[name] = v
+			return
+		}
+	}
+	if name == "NF" {
+		a.splitRecord()
+		n := int(v.num())
+		if n < 0 {
+			n = 0
+		}
+		for len(a.fields)-1 < n {
+			a.fields = append(a.fields, "")
+		}
+		a.fields = a.fields[:n+1]
+		a.nf = n
+		a.rebuild()
+		return
+	}
+	a.vars[name] = v
+}
+
+// array 按名字取数组，函数参数优先；不存在时创建
+func (a *awkInterp) array(name string) awkArray {
+	if f := a.frame(); f != nil {
+		if arr, ok := f.arrs[name]; ok {
+			return arr
+		}
+		if _, ok := f.vars[name]; ok {
+			arr := awkArray{}
+			f.arrs[name] = arr
+			delete(f.vars, name)
+			return arr
+		}
+	}
+	arr, ok := a.arrs[name]
+	if !ok {
+		arr = awkArray{}
+		a.arrs[name] = arr
+	}
+	return arr
+}
+
+func (a *awkInterp) str(v awkVal) string {
+	if v.kind == 'n' {
+		return awkFormatNum(v.n, a.vars["CONVFMT"].s)
+	}
+	return v.s
+}
+
+func (a *awkInterp) outStr(v awkVal) string {
+	if v.kind == 'n' {
+		return awkFormatNum(v.n, a.vars["OFMT"].s)
+	}
+	return v.s
+}
+
+func (a *awkInterp) regex(v awkVal, n *awkNode) *regexp.Regexp {
+	if n != nil && n.kind == anRegex {
+		return n.re
+	}
+	s := a.str(v)
+	if re, ok := a.regexes[s]; ok {
+		return re
+	}
+	re, err := sedRegexp(s, true, false)
+	if err != nil {
+		a.runtimeError("regular expression compile failed (%v)\n%s", err, s)
+	}
+	if len(a.regexes) < 1000 {
+		a.regexes[s] = re
+	}
+	return re
+}
+
+// ---------- 记录与字段 ----------
+
+func (a *awkInterp) setRecord(s string) {
+	a.record, a.split = s, false
+}
+
+func (a *awkInterp) splitRecord() {
+	if a.split {
+		return
+	}
+	a.split = true
+	a.fields = append(a.fields[:0], "")
+	a.fields = append(a.fields, a.splitFS(a.record, a.str(a.vars["FS"]))...)
+	a.nf = len(a.fields) - 1
+}
+
+// splitFS 按 awk 的 FS 规则切分字符串
+func (a *awkInterp) splitFS(s, fs string) []string {
+	switch {
+	case fs == " ":
+		return strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' })
+	case s == "":
+		return nil
+	case fs == "":
+		var res []string
+		for _, r := range s {
+			res = append(res, string(r))
+		}
+		return res
+	case utf8.RuneCountInString(fs) == 1 && fs != "\\":
+		return strings.Split(s, fs)
+	}
+	return a.regex(awkStr(fs), nil).Split(s, -1)
+}
+
+func (a *awkInterp) rebuild() {
+	ofs := a.str(a.vars["OFS"])
+	a.record = strings.Join(a.fields[1:], ofs)
+}
+
+func (a *awkInterp) getField(i int) awkVal {
+	if i < 0 {
+		a.runtimeError("negative field index $%d", i)
+	}
+	if i == 0 {
+		return awkInput(a.record)
+	}
+	a.splitRecord()
+	if i > a.nf {
+		return awkVal{}
+	}
+	return awkInput(a.fields[i])
+}
+
+func (a *awkInterp) setField(i int, v string) {
+	switch {
+	case i < 0:
+		a.runtimeError("negative field index $%d", i)
+	case i == 0:
+		a.setRecord(v)
+		return
+	}
+	a.splitRecord()
+	for a.nf < i {
+		a.fields = append(a.fields, "")
+		a.nf++
+	}
+	a.fields[i] = v
+	a.rebuild()
+}
+
+// ---------- 表达式 ----------
+
+func (a *awkInterp) subscript(args []*awkNode) string {
+	if len(args) == 1 {
+		return a.str(a.eval(args[0]))
+	}
+	parts := make([]string, len(args))
+	for i, e := range args {
+		parts[i] = a.str(a.eval(e))
+	}
+	return strings.Join(parts, a.str(a.vars["SUBSEP"]))
+}
+
+func (a *awkInterp) assign(n *awkNode, v awkVal) {
+	a.checkSize(len(v.s))
+	switch n.kind {
+	case anVar:
+		a.setVar(n.s, v)
+	case anField:
+		a.setField(int(a.eval(n.args[0]).num()), a.str(v))
+	case anIndex:
+		a.setElem(a.array(n.s), a.subscript(n.args), v)
+	}
+}
+
+// setElem 设置数组元素，新增元素使数组超过 awkMaxElements 时报错
+func (a *awkInterp) setElem(arr awkArray, key string, v awkVal) {
+	if _, ok := arr[key]; !ok && len(arr) >= awkMaxElements {
+		a.runtimeError("out of memory")
+	}
+	arr[key] = v
+}
+
+func (a *awkInterp) eval(n *awkNode) awkVal {
+	switch n.kind {
+	case anNum:
+		return awkNum(n.num)
+	case anStr:
+		return awkStr(n.s)
+	case anRegex:
+		return a.boolVal(n.re.MatchString(a.record))
+	case anVar:
+		if f := a.frame(); f != nil {
+			if _, ok := f.arrs[n.s]; ok {
+				a.runtimeError("can't use array %s in scalar context", n.s)
+			}
+		}
+		return a.getVar(n.s)
+	case anField:
+		return a.getField(int(a.eval(n.args[0]).num()))
+	case anIndex:
+		arr := a.array(n.s)
+		key := a.subscript(n.args)
+		v, ok := arr[key]
+		if !ok {
+			a.setElem(arr, key, awkVal{})
+		}
+		return v
+	case anGroup:
+		return a.eval(n.args[len(n.args)-1])
+	case anAssign:
+		target := n.args[0]
+		v := a.eval(n.args[1])
+		if n.op != "=" {
+			v = awkNum(a.arith(n.op[:1], a.eval(target).num(), v.num()))
+		}
+		if v.kind == 0 {
+			v = awkVal{kind: 'm'}
+		}
+		a.assign(target, v)
+		return v
+	case anCond:
+		if a.eval(n.args[0]).bool() {
+			return a.eval(n.args[1])
+		}
+		return a.eval(n.args[2])
+	case anAnd:
+		return a.boolVal(a.eval(n.args[0]).bool() && a.eval(n.args[1]).bool())
+	case anOr:
+		return a.boolVal(a.eval(n.args[0]).bool() || a.eval(n.args[1]).bool())
+	case anNot:
+		return a.boolVal(!a.eval(n.args[0]).bool())
+	case anNeg:
+		return awkNum(-a.eval(n.args[0]).num())
+	case anPlus:
+		return awkNum(a.eval(n.args[0]).num())
+	case anBinary:
+		return awkNum(a.arith(n.op, a.eval(n.args[0]).num(), a.eval(n.args[1]).num()))
+	case anConcat:
+		l, r := a.str(a.eval(n.args[0])), a.str(a.eval(n.args[1]))
+		a.checkSize(len(l) + len(r))
+		return awkStr(l + r)
+	case anCompare:
+		l, r := a.eval(n.args[0]), a.eval(n.args[1])
+		var c int
+		if l.numeric() && r.numeric() {
+			c = cmp.Compare(l.num(), r.num())
+		} else {
+			c = strings.Compare(a.str(l), a.str(r))
+		}
+		switch n.op {
+		case "<":
+			return a.boolVal(c < 0)
+		case "<=":
+			return a.boolVal(c <= 0)
+		case "==":
+			return a.boolVal(c == 0)
+		case "!=":
+			return a.boolVal(c != 0)
+		case ">=":
+			return a.boolVal(c >= 0)
+		}
+		return a.boolVal(c > 0)
+	case anMatch:
+		s := a.str(a.eval(n.args[0]))
+		var re *regexp.Regexp
+		if n.args[1].kind == anRegex {
+			re = n.args[1].re
+		} else {
+			re = a.regex(a.eval(n.args[1]), nil)
+		}
+		return a.boolVal(re.MatchString(s) == (n.op == "~"))
+	case anIn:
+		_, ok := a.array(n.s)[a.subscript(n.args)]
+		return a.boolVal(ok)
+	case anIncDec:
+		old := a.eval(n.args[0]).num()
+		nv := old + 1
+		if strings.Contains(n.op, "--") {
+			nv = old - 1
+		}
+		a.assign(n.args[0], awkNum(nv))
+		if strings.HasPrefix(n.op, "x") {
+			return awkNum(old)
+		}
+		return awkNum(nv)
+	case anCall:
+		return a.builtin(n)
+	case anUserCall:
+		return a.call(n)
+	case anGetline:
+		return a.getline(n)
+	}
+	return awkVal{}
+}
+
+func (a *awkInterp) boolVal(b bool) awkVal {
+	if b {
+		return awkNum(1)
+	}
+	return awkNum(0)
+}
+
+func (a *awkInterp) arith(op string, x, y float64) float64 {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			a.runtimeError("division by zero")
+		}
+		return x / y
+	case "%":
+		if y == 0 {
+			a.runtimeError("division by zero in %%")
+		}
+		return math.Mod(x, y)
+	case "^":
+		return math.Pow(x, y)
+	}
+	return 0
+}
+
+func (a *awkInterp) call(n *awkNode) awkVal {
+	f := a.prog.funcs[n.s]
+	if len(n.args) > len(f.params) {
+		a.runtimeError("too many arguments in call to %s", n.s)
+	}
+	if len(a.frames) >= 200 {
+		a.runtimeError("function call nesting too deep")
+	}
+	fr := &awkFrame{vars: map[string]awkVal{}, arrs: map[string]awkArray{}}
+	for i, p := range f.params {
+		if i >= len(n.args) {
+			fr.vars[p] = awkVal{}
+			continue
+		}
+		arg := n.args[i]
+		// 数组按引用传递
+		if arg.kind == anVar {
+			if arr := a.lookupArray(arg.s); arr != nil {
+				fr.arrs[p] = arr
+				continue
+			}
+		}
+		fr.vars[p] = a.eval(arg)
+	}
+	a.frames = append(a.frames, fr)
+	defer func() { a.frames = a.frames[:len(a.frames)-1] }()
+	a.retval = awkVal{}
+	a.execList(f.body)
+	ret := a.retval
+	a.retval = awkVal{}
+	return ret
+}
+
+// lookupArray 返回已存在的数组，不存在时返回 nil
+func (a *awkInterp) lookupArray(name string) awkArray {
+	if f := a.frame(); f != nil {
+		if arr, ok := f.arrs[name]; ok {
+			return arr
+		}
+		if _, ok := f.vars[name]; ok {
+			return nil
+		}
+	}
+	return a.arrs[name]
+}
+
+// ---------- 内建函数 ----------
+
+func (a *awkInterp) arg(n *awkNode, i int) awkVal {
+	if i < len(n.args) {
+		return a.eval(n.args[i])
+	}
+	return awkVal{}
+}
+
+func (a *awkInterp) builtin(n *awkNode) awkVal {
+	argc := len(n.args)
+	need := func(lo, hi int) {
+		if argc < lo || argc > hi {
+			a.runtimeError("wrong number of arguments in call to %s", n.s)
+		}
+	}
+	switch n.s {
+	case "length":
+		need(0, 1)
+		if argc == 0 {
+			return awkNum(float64(utf8.RuneCountInString(a.record)))
+		}
+		if arg := n.args[0]; arg.kind == anVar {
+			if arr := a.lookupArray(arg.s); arr != nil {
+				return awkNum(float64(len(arr)))
+			}
+		}
+		return awkNum(float64(utf8.RuneCountInString(a.str(a.eval(n.args[0])))))
+	case "substr":
+		need(2, 3)
+		rs := []rune(a.str(a.arg(n, 0)))
+		start := a.arg(n, 1).num()
+		end := math.Inf(1)
+		if argc == 3 {
+			end = start + a.arg(n, 2).num()
+		}
+		// 按 POSIX 的舍入规则截取 [start, end)
+		s, e := math.Round(start), math.Round(end)
+		if s < 1 {
+			s = 1
+		}
+		if e > float64(len(rs)+1) {
+			e = float64(len(rs) + 1)
+		}
+		if e <= s {
+			return awkStr("")
+		}
+		return awkStr(string(rs[int(s)-1 : int(e)-1]))
+	case "index":
+		need(2, 2)
+		s, t := a.str(a.arg(n, 0)), a.str(a.arg(n, 1))
+		i := strings.Index(s, t)
+		if i < 0 {
+			return awkNum(0)
+		}
+		return awkNum(float64(utf8.RuneCountInString(s[:i]) + 1))
+	case "split":
+		need(2, 3)
+		s := a.str(a.arg(n, 0))
+		if n.args[1].kind != anVar {
+			a.runtimeError("split: second argument is not an array")
+		}
+		arr := a.array(n.args[1].s)
+		for k := range arr {
+			delete(arr, k)
+		}
+		var parts []string
+		switch {
+		case argc == 3 && n.args[2].kind == anRegex:
+			if s != "" {
+				parts = n.args[2].re.Split(s, -1)
+			}
+		case argc == 3:
+			parts = a.splitFS(s, a.str(a.eval(n.args[2])))
+		default:
+			parts = a.splitFS(s, a.str(a.vars["FS"]))
+		}
+		for i, p := range parts {
+			a.setElem(arr, strconv.Itoa(i+1), awkInput(p))
+		}
+		return awkNum(float64(len(parts)))
+	case "sub", "gsub":
+		need(2, 3)
+		re := a.regex(a.eval(n.args[0]), n.args[0])
+		repl := a.str(a.arg(n, 1))
+		target := &awkNode{kind: anField, args: []*awkNode{{kind: anNum}}}
+		if argc == 3 {
+			target = n.args[2]
+		}
+		src := a.str(a.eval(target))
+		count := 0
+		var b strings.Builder
+		last := 0
+		for _, m := range re.FindAllStringIndex(src, -1) {
+			if n.s == "sub" && count == 1 {
+				break
+			}
+			b.WriteString(src[last:m[0]])
+			for i := 0; i < len(repl); i++ {
+				switch {
+				case repl[i] == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+					i++
+					b.WriteByte(repl[i])
+				case repl[i] == '&':
+					b.WriteString(src[m[0]:m[1]])
+				default:
+					b.WriteByte(repl[i])
+				}
+			}
+			last = m[1]
+			count++
+		}
+		if count > 0 && isLvalue(target) {
+			b.WriteString(src[last:])
+			a.assign(target, awkStr(b.String()))
+		}
+		return awkNum(float64(count))
+	case "match":
+		need(2, 2)
+		s := a.str(a.arg(n, 0))
+		re := a.regex(a.eval(n.args[1]), n.args[1])
+		start, length := 0, -1
+		if m := re.FindStringIndex(s); m != nil {
+			start = utf8.RuneCountInString(s[:m[0]]) + 1
+			length = utf8.RuneCountInString(s[m[0]:m[1]])
+		}
+		a.vars["RSTART"], a.vars["RLENGTH"] = awkNum(float64(start)), awkNum(float64(length))
+		return awkNum(float64(start))
+	case "sprintf":
+		if argc == 0 {
+			a.runtimeError("wrong number of arguments in call to sprintf")
+		}
+		vals := make([]awkVal, argc-1)
+		for i := range vals {
+			vals[i] = a.eval(n.args[i+1])
+		}
+		return awkStr(a.sprintf(a.str(a.eval(n.args[0])), vals))
+	case "sin", "cos", "exp", "log", "sqrt", "int":
+		need(1, 1)
+		x := a.arg(n, 0).num()
+		switch n.s {
+		case "sin":
+			x = math.Sin(x)
+		case "cos":
+			x = math.Cos(x)
+		case "exp":
+			x = math.Exp(x)
+		case "log":
+			x = math.Log(x)
+		case "sqrt":
+			x = math.Sqrt(x)
+		default:
+			x = math.Trunc(x)
+		}
+		return awkNum(x)
+	case "atan2":
+		need(2, 2)
+		return awkNum(math.Atan2(a.arg(n, 0).num(), a.arg(n, 1).num()))
+	case "rand":
+		return awkNum(a.rng.Float64())
+	case "srand":
+		prev := a.seed
+		if argc > 0 {
+			a.seed = a.arg(n, 0).num()
+		} else {
+			a.seed = float64(time.Now().Unix())
+		}
+		a.rng = rand.New(rand.NewSource(int64(a.seed)))
+		return awkNum(prev)
+	case "tolower":
+		need(1, 1)
+		return awkStr(strings.ToLower(a.str(a.arg(n, 0))))
+	case "toupper":
+		need(1, 1)
+		return awkStr(strings.ToUpper(a.str(a.arg(n, 0))))
+	case "system":
+		need(1, 1)
+		return awkNum(float64(a.system(a.str(a.arg(n, 0)))))
+	case "close":
+		need(1, 1)
+		return awkNum(float64(a.close(a.str(a.arg(n, 0)))))
+	case "fflush":
+		return awkNum(0)
+	}
+	return awkVal{}
+}
+
+// ---------- printf ----------
+
+func (a *awkInterp) sprintf(format string, vals []awkVal) string {
+	return awkSprintf(format, vals, a.str)
+}
+
+// awkSprintf 按 C printf 的规则格式化 awk 值，str 为 nil 时数值按 %.6g 转换
+func awkSprintf(format string, vals []awkVal, str func(awkVal) string) string {
+	var b strings.Builder
+	next := func() awkVal {
+		if len(vals) == 0 {
+			return awkVal{}
+		}
+		v := vals[0]
+		vals = vals[1:]
+		return v
+	}
+	toStr := func(v awkVal) string {
+		if str != nil {
+			return str(v)
+		}
+		if v.kind == 'n' {
+			return strconv.FormatFloat(v.n, 'g', 6, 64)
+		}
+		return v.s
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		start, j := i, i+1
+		spec := "%"
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			spec += string(format[j])
+			j++
+		}
+		for j < len(format) && (format[j] >= '0' && format[j] <= '9' || format[j] == '*') {
+			if format[j] == '*' {
+				spec += strconv.Itoa(int(next().num()))
+			} else {
+				spec += string(format[j])
+			}
+			j++
+		}
+		hasPrec := false
+		if j < len(format) && format[j] == '.' {
+			hasPrec = true
+			spec += "."
+			j++
+			for j < len(format) && (format[j] >= '0' && format[j] <= '9' || format[j] == '*') {
+				if format[j] == '*' {
+					spec += strconv.Itoa(int(next().num()))
+				} else {
+					spec += string(format[j])
+				}
+				j++
+			}
+		}
+		for j < len(format) && strings.IndexByte("hlLqjzt", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+		verb := format[j]
+		i = j
+		switch verb {
+		case '%':
+			b.WriteByte('%')
+		case 'd', 'i':
+			fmt.Fprintf(&b, spec+"d", int64(next().num()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(&b, spec+string(verb), uint64(int64(next().num())))
+		case 'u':
+			fmt.Fprintf(&b, spec+"d", uint64(int64(next().num())))
+		case 'e', 'E', 'f', 'F':
+			fmt.Fprintf(&b, spec+string(verb), next().num())
+		case 'g', 'G':
+			if !hasPrec {
+				spec += ".6"
+			}
+			fmt.Fprintf(&b, spec+string(verb), next().num())
+		case 'c':
+			v := next()
+			ch := ""
+			if v.kind == 'n' {
+				ch = string(rune(int(v.n)))
+			} else if s := toStr(v); s != "" {
+				r, _ := utf8.DecodeRuneInString(s)
+				ch = string(r)
+			}
+			fmt.Fprintf(&b, strings.Split(spec, ".")[0]+"s", ch)
+		case 's':
+			fmt.Fprintf(&b, spec+"s", toStr(next()))
+		default:
+			b.WriteString(format[start : j+1])
+		}
+	}
+	return b.String()
+}
+
+// ---------- I/O ----------
+
+func (a *awkInterp) system(cmd string) int {
+	log.Printf("[Shell] %s: awk system(%q)", a.t.Remote, cmd)
+	return a.t.subshell(cmd, nil, a.out)
+}
+
+func (a *awkInterp) close(name string) int {
+	if buf, ok := a.pipes[name]; ok {
+		delete(a.pipes, name)
+		return a.runPipe(name, buf)
+	}
+	if _, ok := a.readers[name]; ok {
+		delete(a.readers, name)
+		return 0
+	}
+	if buf, ok := a.files[name]; ok {
+		a.flushFile(name, buf)
+		delete(a.files, name)
+		a.appendTo[name] = true // 再次打开时接着写
+		return 0
+	}
+	return -1
+}
+
+func (a *awkInterp) runPipe(cmd string, buf *bytes.Buffer) int {
+	log.Printf("[Shell] %s: awk print | %q", a.t.Remote, cmd)
+	return a.t.subshell(cmd, bytes.NewReader(buf.Bytes()), a.out)
+}
+
+func (a *awkInterp) flushFile(name string, buf *bytes.Buffer) {
+	switch name {
+	case "/dev/stdout", "-":
+		a.out.Write(buf.Bytes())
+	case "/dev/stderr":
+		a.t.Stderr.Write(buf.Bytes())
+	default:
+		a.t.writeTextFile("awk", name, buf.Bytes(), a.appendTo[name])
+	}
+}
+
+func (a *awkInterp) output(s *awkStmt, text string) {
+	if s.redir == "" {
+		if a.written += len(text); a.written > awkMaxOutput {
+			a.runtimeError("out of memory")
+		}
+		io.WriteString(a.out, text)
+		return
+	}
+	dest := a.str(a.eval(s.dest))
+	switch s.redir {
+	case "|":
+		buf, ok := a.pipes[dest]
+		if !ok {
+			buf = &bytes.Buffer{}
+			a.pipes[dest] = buf
+			a.pipeOrder = append(a.pipeOrder, dest)
+		}
+		if buf.Len() < MaxFileSize {
+			buf.WriteString(text)
+		}
+	default:
+		buf, ok := a.files[dest]
+		if !ok {
+			buf = &bytes.Buffer{}
+			a.files[dest] = buf
+			a.fileOrder = append(a.fileOrder, dest)
+			if s.redir == ">>" {
+				a.appendTo[dest] = true
+			}
+		}
+		if buf.Len() < MaxFileSize {
+			buf.WriteString(text)
+		}
+	}
+}
+
+// nextRecord 从主输入读取下一条记录，处理命令行上的 var=value 和文件切换
+func (a *awkInterp) nextRecord() (string, bool) {
+	for {
+		if a.input == nil {
+			if !a.openNext() {
+				return "", false
+			}
+		}
+		if rec, ok := a.readRecord(a.input); ok {
+			a.vars["NR"] = awkNum(a.vars["NR"].num() + 1)
+			a.vars["FNR"] = awkNum(a.vars["FNR"].num() + 1)
+			return rec, true
+		}
+		a.input = nil
+	}
+}
+
+func (a *awkInterp) openNext() bool {
+	for a.argIdx < len(a.operands) {
+		name := a.operands[a.argIdx]
+		a.argIdx++
+		if i := strings.IndexByte(name, '='); i > 0 && isIdentifier(name[:i]) {
+			a.vars[name[:i]] = awkInput(awkUnescape(name[i+1:]))
+			continue
+		}
+		r, reason := a.t.openInput(name, a.in)
+		if reason != "" {
+			msg := "No such file or directory"
+			if reason == "是一个目录" {
+				msg = "Is a directory"
+			}
+			a.runtimeError("cannot open %s (%s)", name, msg)
+		}
+		a.input = newLineReader(r)
+		a.vars["FILENAME"] = awkStr(name)
+		a.vars["FNR"] = awkNum(0)
+		return true
+	}
+	if a.argIdx == len(a.operands) && !a.hasFileOperand() {
+		// 没有文件参数时读标准输入
+		a.argIdx++
+		a.input = newLineReader(a.in)
+		a.vars["FNR"] = awkNum(0)
+		return true
+	}
+	return false
+}
+
+func (a *awkInterp) hasFileOperand() bool {
+	for _, o := range a.operands {
+		if i := strings.IndexByte(o, '='); i <= 0 || !isIdentifier(o[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// readRecord 按 RS 读取一条记录：RS 为 "\n"、单个字符或空串 (段落模式)
+func (a *awkInterp) readRecord(lr *lineReader) (string, bool) {
+	rs := a.str(a.vars["RS"])
+	switch {
+	case rs == "\n":
+		return lr.next()
+	case rs == "":
+		var lines []string
+		for {
+			line, ok := lr.next()
+			if !ok {
+				break
+			}
+			if line == "" {
+				if len(lines) > 0 {
+					break
+				}
+				continue
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n"), len(lines) > 0
+	}
+	sep := rs[0]
+	s, err := lr.r.ReadString(sep)
+	if s == "" && err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(s, string(sep)), true
+}
+
+func (a *awkInterp) getline(n *awkNode) awkVal {
+	var line string
+	var ok bool
+	switch n.op {
+	case "":
+		if line, ok = a.nextRecord(); !ok {
+			return awkNum(0)
+		}
+		if n.args[1] == nil {
+			a.setRecord(line)
+			return awkNum(1)
+		}
+	case "<", "|":
+		key := a.str(a.eval(n.args[0]))
+		lr, open := a.readers[key]
+		if !open {
+			if n.op == "<" {
+				r, reason := a.t.openInput(key, a.in)
+				if reason != "" {
+					return awkNum(-1)
+				}
+				lr = newLineReader(r)
+			} else {
+				log.Printf("[Shell] %s: awk %q | getline", a.t.Remote, key)
+				var buf bytes.Buffer
+				a.t.subshell(key, nil, &buf)
+				lr = newLineReader(&buf)
+			}
+			a.readers[key] = lr
+		}
+		if line, ok = a.readRecord(lr); !ok {
+			return awkNum(0)
+		}
+		if n.args[1] == nil {
+			a.setRecord(line)
+			if n.op == "|" {
+				a.vars["NR"] = awkNum(a.vars["NR"].num() + 1)
+			}
+			return awkNum(1)
+		}
+		if n.op == "|" {
+			a.vars["NR"] = awkNum(a.vars["NR"].num() + 1)
+		}
+	}
+	a.assign(n.args[1], awkInput(line))
+	return awkNum(1)
+}
+
+// ---------- 语句 ----------
+
+func (a *awkInterp) execList(list []*awkStmt) awkFlow {
+	for _, s := range list {
+		if f := a.exec(s); f != awkFlowNone {
+			return f
+		}
+	}
+	return awkFlowNone
+}
+
+func (a *awkInterp) exec(s *awkStmt) awkFlow {
+	a.step()
+	switch s.kind {
+	case asExpr:
+		a.eval(s.exprs[0])
+	case asPrint:
+		var b strings.Builder
+		if len(s.exprs) == 0 {
+			b.WriteString(a.record)
+		}
+		for i, e := range s.exprs {
+			if i > 0 {
+				b.WriteString(a.str(a.vars["OFS"]))
+			}
+			b.WriteString(a.outStr(a.eval(e)))
+		}
+		b.WriteString(a.str(a.vars["ORS"]))
+		a.output(s, b.String())
+	case asPrintf:
+		vals := make([]awkVal, len(s.exprs)-1)
+		for i := range vals {
+			vals[i] = a.eval(s.exprs[i+1])
+		}
+		a.output(s, a.sprintf(a.str(a.eval(s.exprs[0])), vals))
+	case asIf:
+		if a.eval(s.cond).bool() {
+			return a.execList(s.body)
+		}
+		return a.execList(s.alt)
+	case asWhile:
+		for a.eval(s.cond).bool() {
+			if f := a.execList(s.body); f == awkFlowBreak {
+				break
+			} else if f == awkFlowReturn {
+				return f
+			}
+			a.step()
+		}
+	case asDo:
+		for {
+			if f := a.execList(s.body); f == awkFlowBreak {
+				break
+			} else if f == awkFlowReturn {
+				return f
+			}
+			a.step()
+			if !a.eval(s.cond).bool() {
+				break
+			}
+		}
+	case asFor:
+		if s.init != nil {
+			a.exec(s.init)
+		}
+		for s.cond == nil || a.eval(s.cond).bool() {
+			if f := a.execList(s.body); f == awkFlowBreak {
+				break
+			} else if f == awkFlowReturn {
+				return f
+			}
+			if s.post != nil {
+				a.exec(s.post)
+			}
+			a.step()
+		}
+	case asForIn:
+		arr := a.array(s.arr)
+		keys := make([]string, 0, len(arr))
+		for k := range arr {
+			keys = append(keys, k)
+		}
+		sortAwkKeys(keys)
+		for _, k := range keys {
+			if _, ok := arr[k]; !ok {
+				continue
+			}
+			a.setVar(s.name, awkInput(k))
+			if f := a.execList(s.body); f == awkFlowBreak {
+				break
+			} else if f == awkFlowReturn {
+				return f
+			}
+		}
+	case asBlock:
+		return a.execList(s.body)
+	case asNext, asNextFile:
+		if a.inEnd {
+			a.runtimeError("improper use of next")
+		}
+		panic(awkNextPanic{file: s.kind == asNextFile})
+	case asExit:
+		if len(s.exprs) > 0 {
+			a.exitCode = int(a.eval(s.exprs[0]).num())
+		}
+		panic(awkExitPanic{})
+	case asBreak:
+		return awkFlowBreak
+	case asContinue:
+		return awkFlowContinue
+	case asReturn:
+		if len(s.exprs) > 0 {
+			a.retval = a.eval(s.exprs[0])
+		}
+		return awkFlowReturn
+	case asDelete:
+		arr := a.array(s.arr)
+		if s.exprs == nil {
+			for k := range arr {
+				delete(arr, k)
+			}
+		} else {
+			delete(arr, a.subscript(s.exprs))
+		}
+	}
+	return awkFlowNone
+}
+
+// sortAwkKeys 数字下标按数值排在前面，其余按字符串排序
+func sortAwkKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		ni, ei := strconv.ParseFloat(keys[i], 64)
+		nj, ej := strconv.ParseFloat(keys[j], 64)
+		switch {
+		case ei == nil && ej == nil:
+			return ni < nj
+		case ei == nil:
+			return true
+		case ej == nil:
+			return false
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// ---------- 主流程 ----------
+
+// runItems 对当前记录执行所有主体规则
+func (a *awkInterp) runItems() {
+	for _, it := range a.prog.items {
+		if it.begin || it.end {
+			continue
+		}
+		matched := true
+		switch {
+		case it.pat2 != nil:
+			if it.inRange {
+				if a.eval(it.pat2).bool() {
+					it.inRange = false
+				}
+			} else if a.eval(it.pat).bool() {
+				it.inRange = !a.eval(it.pat2).bool()
+			} else {
+				matched = false
+			}
+		case it.pat != nil:
+			matched = a.eval(it.pat).bool()
+		}
+		if !matched {
+			continue
+		}
+		if it.printDefault {
+			io.WriteString(a.out, a.record+a.str(a.vars["ORS"]))
+			continue
+		}
+		a.execList(it.body)
+	}
+}
+
+// protect 执行 fn，捕获 next/exit/运行时错误；返回 "next"、"nextfile"、"exit" 或 ""
+func (a *awkInterp) protect(fn func()) (res string) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.frames = a.frames[:0]
+			switch e := r.(type) {
+			case awkNextPanic:
+				res = "next"
+				if e.file {
+					res = "nextfile"
+				}
+			case awkExitPanic:
+				res = "exit"
+			case awkErrorPanic:
+				fmt.Fprintf(a.t.Stderr, "awk: run time error: %s\n\tFILENAME=\"%s\" FNR=%s NR=%s\n",
+					e.msg, a.str(a.vars["FILENAME"]), a.str(a.vars["FNR"]), a.str(a.vars["NR"]))
+				a.exitCode = 2
+				res = "error"
+			default:
+				panic(r)
+			}
+		}
+	}()
+	fn()
+	return ""
+}
+
+func (a *awkInterp) run() {
+	hasMain, hasEnd := false, false
+	for _, it := range a.prog.items {
+		switch {
+		case it.begin:
+		case it.end:
+			hasEnd = true
+		default:
+			hasMain = true
+		}
+	}
+	res := a.protect(func() {
+		for _, it := range a.prog.items {
+			if it.begin {
+				a.execList(it.body)
+			}
+		}
+	})
+	if res == "error" {
+		return
+	}
+	if res != "exit" && (hasMain || hasEnd) {
+		for res != "exit" && res != "error" {
+			var rec string
+			var ok bool
+			if res = a.protect(func() { rec, ok = a.nextRecord() }); res != "" || !ok {
+				break
+			}
+			a.setRecord(rec)
+			res = a.protect(a.runItems)
+			if res == "nextfile" {
+				a.input = nil
+			}
+		}
+		if res == "error" {
+			return
+		}
+	}
+	if hasEnd && (res != "exit" || !a.inEnd) {
+		a.inEnd = true
+		a.protect(func() {
+			for _, it := range a.prog.items {
+				if it.end {
+					a.execList(it.body)
+				}
+			}
+		})
+	}
+}
+
+func (a *awkInterp) finish() {
+	for _, name := range a.fileOrder {
+		if buf, ok := a.files[name]; ok {
+			a.flushFile(name, buf)
+		}
+	}
+	for _, name := range a.pipeOrder {
+		if buf, ok := a.pipes[name]; ok {
+			a.runPipe(name, buf)
+		}
+	}
+}
+
+func (t *Terminal) cmdAwk(args []string, in io.Reader, out io.Writer) {
+	var progText, fs string
+	var progFiles []string
+	var assigns []string
+	hasFS := false
+	i := 1
+	usage := func() {
+		fmt.Fprint(t.Stderr, awkUsage)
+		t.lastExitCode = 2
+	}
+options:
+	for ; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			i++
+			break options
+		case a == "-" || !strings.HasPrefix(a, "-"):
+			break options
+		case a == "--version" || a == "-W" && i+1 < len(args) && args[i+1] == "version" || a == "-Wversion" || a == "-Wv":
+			fmt.Fprintln(out, "mawk 1.3.4 20200120\nCopyright 2008-2019,2020, Thomas E. Dickey\nCopyright 1991-1996,2014, Michael D. Brennan\n\nrandom-funcs:       srandom/random\nregex-funcs:        internal\ncompiled limits:\nsprintf buffer      8192\nmaximum-integer     2147483647")
+			return
+		case strings.HasPrefix(a, "-F"):
+			fs, hasFS = a[2:], true
+			if fs == "" && i+1 < len(args) {
+				i++
+				fs = args[i]
+			}
+		case strings.HasPrefix(a, "-v"):
+			v := a[2:]
+			if v == "" && i+1 < len(args) {
+				i++
+				v = args[i]
+			}
+			if j := strings.IndexByte(v, '='); j <= 0 || !isIdentifier(v[:j]) {
+				fmt.Fprintf(t.Stderr, "awk: improper assignment: -v %s\n", v)
+				t.lastExitCode = 2
+				return
+			}
+			assigns = append(assigns, v)
+		case strings.HasPrefix(a, "-f"):
+			f := a[2:]
+			if f == "" && i+1 < len(args) {
+				i++
+				f = args[i]
+			}
+			progFiles = append(progFiles, f)
+		case strings.HasPrefix(a, "-W"):
+			if a == "-W" {
+				i++
+			}
+		default:
+			fmt.Fprintf(t.Stderr, "awk: not an option: %s\n", a)
+			t.lastExitCode = 2
+			return
+		}
+	}
+	if len(progFiles) > 0 {
+		var b strings.Builder
+		for _, f := range progFiles {
+			e, ok := t.FS.GetEntry(t.FS.Abs(f))
+			if !ok || e.IsDir {
+				fmt.Fprintf(t.Stderr, "awk: couldn't open file %s.\n", f)
+				t.lastExitCode = 2
+				return
+			}
+			b.Write(e.Content)
+			b.WriteByte('\n')
+		}
+		progText = b.String()
+	} else {
+		if i >= len(args) {
+			usage()
+			return
+		}
+		progText = args[i]
+		i++
+	}
+
+	prog, err := parseAwk(progText)
+	if err != nil {
+		fmt.Fprintf(t.Stderr, "awk: %v\n", err)
+		t.lastExitCode = 2
+		return
+	}
+	a := &awkInterp{
+		t: t, prog: prog, out: out, in: in,
+		vars:     map[string]awkVal{},
+		arrs:     map[string]awkArray{},
+		files:    map[string]*bytes.Buffer{},
+		appendTo: map[string]bool{},
+		pipes:    map[string]*bytes.Buffer{},
+		readers:  map[string]*lineReader{},
+		regexes:  map[string]*regexp.Regexp{},
+		operands: args[i:],
+		deadline: time.Now().Add(awkMaxTime),
+		rng:      rand.New(rand.NewSource(0)),
+	}
+	for k, v := range map[string]string{
+		"FS": " ", "OFS": " ", "ORS": "\n", "RS": "\n", "SUBSEP": "\x1c",
+		"CONVFMT": "%.6g", "OFMT": "%.6g", "FILENAME": "",
+	} {
+		a.vars[k] = awkStr(v)
+	}
+	for _, k := range []string{"NR", "FNR", "NF", "RSTART"} {
+		a.vars[k] = awkNum(0)
+	}
+	a.vars["RLENGTH"] = awkNum(-1)
+	if hasFS {
+		if fs == "t" {
+			fs = "\t"
+		}
+		a.vars["FS"] = awkStr(awkUnescape(fs))
+	}
+	for _, v := range assigns {
+		j := strings.IndexByte(v, '=')
+		a.vars[v[:j]] = awkInput(awkUnescape(v[j+1:]))
+	}
+	env := awkArray{}
+	t.mu.Lock()
+	for k, v := range t.Env {
+		env[k] = awkInput(v)
+	}
+	t.mu.Unlock()
+	a.arrs["ENVIRON"] = env
+	argv := awkArray{"0": awkStr("awk")}
+	for j, o := range a.operands {
+		argv[strconv.Itoa(j+1)] = awkInput(o)
+	}
+	a.arrs["ARGV"] = argv
+	a.vars["ARGC"] = awkNum(float64(len(a.operands) + 1))
+
+	a.run()
+	a.finish()
+	t.lastExitCode = a.exitCode
+}
@@ -0,0 +1,945 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 校验和与二进制查看工具: md5sum/sha*sum, hexdump, od, strings, dd
+// ==========================================
+
+// ---------- md5sum / sha*sum ----------
+
+var hashAlgos = map[string]struct {
+	name string
+	new  func() hash.Hash
+}{
+	"md5sum":    {"MD5", md5.New},
+	"sha1sum":   {"SHA1", sha1.New},
+	"sha224sum": {"SHA224", sha256.New224},
+	"sha256sum": {"SHA256", sha256.New},
+	"sha384sum": {"SHA384", sha512.New384},
+	"sha512sum": {"SHA512", sha512.New},
+}
+
+// hashInput 计算文件或标准输入的摘要，失败时返回原因
+func (t *Terminal) hashInput(newHash func() hash.Hash, name string, in io.Reader) (string, string) {
+	r, reason := t.openInput(name, in)
+	if reason != "" {
+		return "", reason
+	}
+	h := newHash()
+	io.Copy(h, r)
+	return hex.EncodeToString(h.Sum(nil)), ""
+}
+
+// parseChecksumLine 解析 "摘要  文件名"、"摘要 *文件名" 或 BSD 风格 "ALGO (文件名) = 摘要"
+func parseChecksumLine(line, algo string, size int) (sum, name string, ok bool) {
+	if rest, found := strings.CutPrefix(line, algo+" ("); found {
+		if i := strings.LastIndex(rest, ") = "); i >= 0 {
+			sum, name = rest[i+4:], rest[:i]
+		}
+	} else if len(line) > size+1 && (line[size] == ' ') {
+		sum, name = line[:size], line[size+1:]
+		if name != "" && (name[0] == ' ' || name[0] == '*') {
+			name = name[1:]
+		}
+	}
+	if len(sum) != size || name == "" {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", false
+	}
+	return strings.ToLower(sum), name, true
+}
+
+func (t *Terminal) cmdHashSum(cmd string, args []string, in io.Reader, out io.Writer) {
+	algo := hashAlgos[cmd]
+	check, binary, tag, quiet, status, warn, strict := false, false, false, false, false, false, false
+	var files []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--check":
+			check = true
+		case a == "--binary":
+			binary = true
+		case a == "--text":
+			binary = false
+		case a == "--tag":
+			tag = true
+		case a == "--quiet":
+			quiet = true
+		case a == "--status":
+			status = true
+		case a == "--warn":
+			warn = true
+		case a == "--strict":
+			strict = true
+		case a == "--ignore-missing":
+		case strings.HasPrefix(a, "--"):
+			t.usageError(cmd, fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			bad := byte(0)
+			shortOpts(args, i, "", func(opt byte, _ string) {
+				switch opt {
+				case 'c':
+					check = true
+				case 'b':
+					binary = true
+				case 't':
+					binary = false
+				case 'w':
+					warn = true
+				case 'z':
+				default:
+					if bad == 0 {
+						bad = opt
+					}
+				}
+			})
+			if bad != 0 {
+				t.usageError(cmd, fmt.Sprintf("无效的选项 -- '%c'", bad), 1)
+				return
+			}
+		default:
+			files = append(files, a)
+		}
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	if !check {
+		for _, f := range files {
+			sum, reason := t.hashInput(algo.new, f, in)
+			if reason != "" {
+				fmt.Fprintf(t.Stderr, "%s: %s: %s\n", cmd, f, reason)
+				t.lastExitCode = 1
+				continue
+			}
+			switch {
+			case tag:
+				fmt.Fprintf(out, "%s (%s) = %s\n", algo.name, f, sum)
+			case binary:
+				fmt.Fprintf(out, "%s *%s\n", sum, f)
+			default:
+				fmt.Fprintf(out, "%s  %s\n", sum, f)
+			}
+		}
+		return
+	}
+
+	size := algo.new().Size() * 2
+	for _, list := range files {
+		r, reason := t.openInput(list, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "%s: %s: %s\n", cmd, list, reason)
+			t.lastExitCode = 1
+			continue
+		}
+		valid, bad, mismatch, unreadable := 0, 0, 0, 0
+		lr := newLineReader(r)
+		for n := 1; ; n++ {
+			line, ok := lr.next()
+			if !ok {
+				break
+			}
+			want, name, good := parseChecksumLine(strings.TrimSuffix(line, "\r"), algo.name, size)
+			if !good {
+				bad++
+				if warn {
+					fmt.Fprintf(t.Stderr, "%s: %s: %d: 不正确的 %s 校验和行格式\n", cmd, list, n, algo.name)
+				}
+				continue
+			}
+			valid++
+			got, reason := t.hashInput(algo.new, name, nil)
+			switch {
+			case reason != "":
+				unreadable++
+				if !status {
+					fmt.Fprintf(t.Stderr, "%s: %s: %s\n", cmd, name, reason)
+					fmt.Fprintf(out, "%s: 打开或读取失败\n", name)
+				}
+			case got != want:
+				mismatch++
+				if !status {
+					fmt.Fprintf(out, "%s: 失败\n", name)
+				}
+			case !quiet && !status:
+				fmt.Fprintf(out, "%s: 成功\n", name)
+			}
+		}
+		if valid == 0 {
+			fmt.Fprintf(t.Stderr, "%s: %s: 没有找到正确格式的 %s 校验和行\n", cmd, list, algo.name)
+			t.lastExitCode = 1
+			continue
+		}
+		if !status {
+			if bad > 0 {
+				fmt.Fprintf(t.Stderr, "%s: 警告：%d 行的格式不正确\n", cmd, bad)
+			}
+			if unreadable > 0 {
+				fmt.Fprintf(t.Stderr, "%s: 警告：%d 个列出的文件无法读取\n", cmd, unreadable)
+			}
+			if mismatch > 0 {
+				fmt.Fprintf(t.Stderr, "%s: 警告：%d 个校验和不匹配\n", cmd, mismatch)
+			}
+		}
+		if mismatch > 0 || unreadable > 0 || (strict && bad > 0) {
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// ---------- hexdump / od 共用 ----------
+
+// readDumpInput 依次读取所有输入并拼接，跳过前 skip 字节，最多读取 limit 字节 (limit < 0 表示不限)
+func (t *Terminal) readDumpInput(cmd string, files []string, in io.Reader, skip, limit int64) ([]byte, bool) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var readers []io.Reader
+	failed := 0
+	for _, f := range files {
+		r, reason := t.openInput(f, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "%s: %s: %s\n", cmd, f, reason)
+			failed++
+			continue
+		}
+		readers = append(readers, r)
+	}
+	if failed == len(files) {
+		return nil, false
+	}
+	r := io.MultiReader(readers...)
+	io.CopyN(io.Discard, r, skip)
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+	data, _ := io.ReadAll(io.LimitReader(r, MaxFileSize))
+	return data, failed == 0
+}
+
+// dumpSize 解析 hexdump/od 的长度参数，支持 0x 前缀和 b/k/m 等后缀
+func dumpSize(s string) (int64, bool) {
+	mult := int64(1)
+	for _, suf := range []struct {
+		s string
+		m int64
+	}{{"KiB", 1024}, {"MiB", 1 << 20}, {"kB", 1000}, {"MB", 1000 * 1000}, {"K", 1024}, {"k", 1024}, {"M", 1 << 20}, {"b", 512}} {
+		if strings.HasSuffix(s, suf.s) && !strings.HasPrefix(s, "0x") {
+			s, mult = strings.TrimSuffix(s, suf.s), suf.m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * mult, true
+}
+
+// ---------- hexdump ----------
+
+func (t *Terminal) cmdHexdump(cmd string, args []string, in io.Reader, out io.Writer) {
+	canonical := cmd == "hd"
+	verbose := false
+	skip, limit := int64(0), int64(-1)
+	var files []string
+	ok := true
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--canonical":
+			canonical = true
+		case a == "--no-squeezing":
+			verbose = true
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "nse", func(opt byte, v string) {
+				switch opt {
+				case 'C':
+					canonical = true
+				case 'v':
+					verbose = true
+				case 'n', 's':
+					n, good := dumpSize(v)
+					if !good {
+						fmt.Fprintf(t.Stderr, "hexdump: 无效的长度参数: '%s'\n", v)
+						ok = false
+					} else if opt == 'n' {
+						limit = n
+					} else {
+						skip = n
+					}
+				case 'e', 'x', 'b', 'c', 'd', 'o':
+				default:
+					fmt.Fprintf(t.Stderr, "hexdump: 无效的选项 -- '%c'\n\nUsage:\n hexdump [options] <file>...\n\nDisplay file contents in hexadecimal, decimal, octal, or ascii.\n", opt)
+					ok = false
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		t.lastExitCode = 1
+		return
+	}
+	data, good := t.readDumpInput("hexdump", files, in, skip, limit)
+	if data == nil && !good {
+		fmt.Fprint(t.Stderr, "hexdump: 所有输入文件参数均失败\n")
+		t.lastExitCode = 1
+		return
+	}
+
+	var b strings.Builder
+	var prev []byte
+	squeezed := false
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:min(off+16, len(data))]
+		if !verbose && len(row) == 16 && bytes.Equal(row, prev) {
+			if !squeezed {
+				b.WriteString("*\n")
+				squeezed = true
+			}
+			continue
+		}
+		prev, squeezed = row, false
+		if canonical {
+			var h strings.Builder
+			for i, c := range row {
+				fmt.Fprintf(&h, "%02x ", c)
+				if i == 7 {
+					h.WriteByte(' ')
+				}
+			}
+			fmt.Fprintf(&b, "%08x  %-49s |", skip+int64(off), h.String())
+			for _, c := range row {
+				if c >= 0x20 && c < 0x7f {
+					b.WriteByte(c)
+				} else {
+					b.WriteByte('.')
+				}
+			}
+			b.WriteString("|\n")
+			continue
+		}
+		// 默认格式：按小端序显示 16 位字
+		fmt.Fprintf(&b, "%07x", skip+int64(off))
+		for i := 0; i < len(row); i += 2 {
+			w := uint16(row[i])
+			if i+1 < len(row) {
+				w |= uint16(row[i+1]) << 8
+			}
+			fmt.Fprintf(&b, " %04x", w)
+		}
+		b.WriteByte('\n')
+	}
+	if len(data) > 0 {
+		if canonical {
+			fmt.Fprintf(&b, "%08x\n", skip+int64(len(data)))
+		} else {
+			fmt.Fprintf(&b, "%07x\n", skip+int64(len(data)))
+		}
+	}
+	io.WriteString(out, b.String())
+	if !good {
+		t.lastExitCode = 1
+	}
+}
+
+// ---------- od ----------
+
+// odSpec 是 od 的一种输出类型，如 x1、o2、d4、c、a
+type odSpec struct {
+	kind  byte // x o d u c a
+	size  int
+	width int // 单个字段的字符数 (不含前导空格)
+	ascii bool
+}
+
+var odNames = [...]string{"nul", "soh", "stx", "etx", "eot", "enq", "ack", "bel", "bs", "ht", "nl", "vt", "ff", "cr", "so", "si",
+	"dle", "dc1", "dc2", "dc3", "dc4", "nak", "syn", "etb", "can", "em", "sub", "esc", "fs", "gs", "rs", "us", "sp"}
+
+func parseOdType(s string) ([]odSpec, bool) {
+	var specs []odSpec
+	for len(s) > 0 {
+		sp := odSpec{kind: s[0]}
+		s = s[1:]
+		switch sp.kind {
+		case 'c', 'a':
+			sp.size, sp.width = 1, 3
+		case 'x', 'o', 'd', 'u':
+			sp.size = 4
+			if sp.kind == 'x' || sp.kind == 'o' || sp.kind == 'u' {
+				sp.size = 2
+			}
+			if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+				j := 0
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+				sp.size, _ = strconv.Atoi(s[:j])
+				s = s[j:]
+			} else if len(s) > 0 {
+				if n := strings.IndexByte("CSIL", s[0]); n >= 0 {
+					sp.size = []int{1, 2, 4, 8}[n]
+					s = s[1:]
+				}
+			}
+			if sp.size != 1 && sp.size != 2 && sp.size != 4 && sp.size != 8 {
+				return nil, false
+			}
+			bits := sp.size * 8
+			maxU := uint64(1)<<bits - 1
+			if bits == 64 {
+				maxU = ^uint64(0)
+			}
+			switch sp.kind {
+			case 'x':
+				sp.width = sp.size * 2
+			case 'o':
+				sp.width = len(strconv.FormatUint(maxU, 8))
+			case 'u':
+				sp.width = len(strconv.FormatUint(maxU, 10))
+			case 'd':
+				sp.width = len(strconv.FormatInt(-int64(maxU>>1)-1, 10))
+			}
+		default:
+			return nil, false
+		}
+		if len(s) > 0 && s[0] == 'z' {
+			sp.ascii = true
+			s = s[1:]
+		}
+		specs = append(specs, sp)
+	}
+	return specs, true
+}
+
+// format 按类型格式化一个字段
+func (sp odSpec) format(b []byte) string {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	switch sp.kind {
+	case 'x':
+		return fmt.Sprintf("%0*x", sp.width, v)
+	case 'o':
+		return fmt.Sprintf("%0*o", sp.width, v)
+	case 'u':
+		return fmt.Sprintf("%*d", sp.width, v)
+	case 'd':
+		shift := 64 - sp.size*8
+		return fmt.Sprintf("%*d", sp.width, int64(v<<shift)>>shift)
+	case 'a':
+		c := b[0] & 0x7f
+		switch {
+		case c <= 0x20:
+			return fmt.Sprintf("%3s", odNames[c])
+		case c == 0x7f:
+			return "del"
+		}
+		return fmt.Sprintf("%3c", c)
+	}
+	c := b[0]
+	if esc, found := map[byte]string{0: `\0`, '\a': `\a`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`, '\t': `\t`, '\v': `\v`}[c]; found {
+		return fmt.Sprintf("%3s", esc)
+	}
+	if c >= 0x20 && c < 0x7f {
+		return fmt.Sprintf("%3c", c)
+	}
+	return fmt.Sprintf("%03o", c)
+}
+
+func (t *Terminal) cmdOd(args []string, in io.Reader, out io.Writer) {
+	radix := byte('o')
+	var specs []odSpec
+	skip, limit := int64(0), int64(-1)
+	width := 16
+	verbose := false
+	var files []string
+	ok := true
+	addType := func(v string) {
+		s, good := parseOdType(v)
+		if !good {
+			fmt.Fprintf(t.Stderr, "od: 类型字符串 \"%s\" 无效\n", v)
+			ok = false
+		}
+		specs = append(specs, s...)
+	}
+	setRadix := func(v string) {
+		if len(v) != 1 || strings.IndexByte("doxn", v[0]) < 0 {
+			fmt.Fprintf(t.Stderr, "od: 无效的输出地址进制 '%s'；必须是 [doxn] 中的一个字符\n", v)
+			ok = false
+			return
+		}
+		radix = v[0]
+	}
+	size := func(v string, dst *int64) {
+		n, good := dumpSize(v)
+		if !good {
+			fmt.Fprintf(t.Stderr, "od: 无效的参数 '%s'\n", v)
+			ok = false
+		}
+		*dst = n
+	}
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		long, val, _ := strings.Cut(a, "=")
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case long == "--address-radix":
+			setRadix(val)
+		case long == "--format":
+			addType(val)
+		case long == "--read-bytes":
+			size(val, &limit)
+		case long == "--skip-bytes":
+			size(val, &skip)
+		case long == "--output-duplicates":
+			verbose = true
+		case long == "--width":
+			w, err := strconv.Atoi(val)
+			if err != nil || w <= 0 {
+				w = 32
+			}
+			width = w
+		case strings.HasPrefix(a, "--"):
+			t.usageError("od", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "AtNjw", func(opt byte, v string) {
+				switch opt {
+				case 'A':
+					setRadix(v)
+				case 't':
+					addType(v)
+				case 'N':
+					size(v, &limit)
+				case 'j':
+					size(v, &skip)
+				case 'w':
+					if w, err := strconv.Atoi(v); err == nil && w > 0 {
+						width = w
+					} else {
+						width = 32
+					}
+				case 'v':
+					verbose = true
+				case 'c':
+					addType("c")
+				case 'b':
+					addType("o1")
+				case 'x':
+					addType("x2")
+				case 'd':
+					addType("u2")
+				case 'o':
+					addType("o2")
+				case 'i':
+					addType("dI")
+				case 'l':
+					addType("dL")
+				default:
+					if ok {
+						t.usageError("od", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+						ok = false
+					}
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		t.lastExitCode = 1
+		return
+	}
+	if len(specs) == 0 {
+		specs = []odSpec{{kind: 'o', size: 2, width: 6}}
+	}
+	data, good := t.readDumpInput("od", files, in, skip, limit)
+	if !good {
+		t.lastExitCode = 1
+	}
+
+	addrFmt := map[byte]string{'d': "%07d", 'o': "%07o", 'x': "%06x"}[radix]
+	addrWidth := len(fmt.Sprintf(addrFmt, 0))
+	// 多种类型同时输出时，按 GNU od 的方式补齐字段宽度使各行对齐
+	lineWidth := 0
+	for _, sp := range specs {
+		lineWidth = max(lineWidth, (sp.width+1)*(width/sp.size))
+	}
+	var b strings.Builder
+	var prev []byte
+	squeezed := false
+	for off := 0; off < len(data); off += width {
+		row := data[off:min(off+width, len(data))]
+		if !verbose && len(row) == width && bytes.Equal(row, prev) {
+			if !squeezed {
+				b.WriteString("*\n")
+				squeezed = true
+			}
+			continue
+		}
+		prev, squeezed = row, false
+		for k, sp := range specs {
+			switch {
+			case radix == 'n':
+			case k == 0:
+				fmt.Fprintf(&b, addrFmt, skip+int64(off))
+			default:
+				b.WriteString(strings.Repeat(" ", addrWidth))
+			}
+			fields := width / sp.size
+			pad := lineWidth - (sp.width+1)*fields
+			written := 0
+			for j := 0; j*sp.size < len(row); j++ {
+				chunk := make([]byte, sp.size)
+				copy(chunk, row[j*sp.size:])
+				extra := pad*(j+1)/fields - pad*j/fields
+				s := strings.Repeat(" ", extra+1) + sp.format(chunk)
+				b.WriteString(s)
+				written += len(s)
+			}
+			if sp.ascii {
+				b.WriteString(strings.Repeat(" ", lineWidth-written) + "  >")
+				for _, c := range row {
+					if c >= 0x20 && c < 0x7f {
+						b.WriteByte(c)
+					} else {
+						b.WriteByte('.')
+					}
+				}
+				b.WriteByte('<')
+			}
+			b.WriteByte('\n')
+		}
+	}
+	if radix != 'n' {
+		fmt.Fprintf(&b, addrFmt+"\n", skip+int64(len(data)))
+	}
+	io.WriteString(out, b.String())
+}
+
+// ---------- strings ----------
+
+func (t *Terminal) cmdStrings(args []string, in io.Reader, out io.Writer) {
+	minLen := 4
+	radix := byte(0)
+	printName := false
+	var files []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case strings.HasPrefix(a, "--bytes="):
+			minLen, _ = strconv.Atoi(strings.TrimPrefix(a, "--bytes="))
+		case strings.HasPrefix(a, "--radix="):
+			radix = strings.TrimPrefix(a, "--radix=")[0]
+		case a == "--print-file-name":
+			printName = true
+		case a == "--all" || strings.HasPrefix(a, "--encoding=") || strings.HasPrefix(a, "--"):
+		case len(a) > 1 && a[0] == '-' && a[1] >= '0' && a[1] <= '9':
+			minLen, _ = strconv.Atoi(a[1:])
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "nte", func(opt byte, v string) {
+				switch opt {
+				case 'n':
+					minLen, _ = strconv.Atoi(v)
+				case 't':
+					if v != "" {
+						radix = v[0]
+					}
+				case 'o':
+					radix = 'o'
+				case 'f':
+					printName = true
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if minLen < 1 {
+		fmt.Fprintf(t.Stderr, "strings: invalid minimum string length %d\n", minLen)
+		t.lastExitCode = 1
+		return
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	offFmt := map[byte]string{'d': "%7d ", 'o': "%7o ", 'x': "%7x "}[radix]
+	for _, f := range files {
+		e, exists := t.FS.GetEntry(t.FS.Abs(f))
+		if f != "-" && exists && e.IsDir {
+			fmt.Fprintf(t.Stderr, "strings: Warning: '%s' is a directory\n", f)
+			t.lastExitCode = 1
+			continue
+		}
+		r, reason := t.openInput(f, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "strings: '%s': No such file\n", f)
+			t.lastExitCode = 1
+			continue
+		}
+		br := bufio.NewWriter(out)
+		var cur []byte
+		start := int64(0)
+		flush := func() {
+			if len(cur) >= minLen {
+				if printName {
+					fmt.Fprintf(br, "%s: ", f)
+				}
+				if offFmt != "" {
+					fmt.Fprintf(br, offFmt, start)
+				}
+				br.Write(cur)
+				br.WriteByte('\n')
+			}
+			cur = cur[:0]
+		}
+		buf := make([]byte, 32*1024)
+		pos := int64(0)
+		src := io.LimitReader(r, MaxFileSize)
+		for {
+			n, err := src.Read(buf)
+			for _, c := range buf[:n] {
+				if c >= 0x20 && c < 0x7f || c == '\t' {
+					if len(cur) == 0 {
+						start = pos
+					}
+					cur = append(cur, c)
+				} else {
+					flush()
+				}
+				pos++
+			}
+			if err != nil {
+				break
+			}
+		}
+		flush()
+		br.Flush()
+	}
+}
+
+// ---------- dd ----------
+
+// ddSize 解析 dd 的数字参数：支持 c w b K M G kB MB GB 后缀和 x 乘法
+func ddSize(s string) (int64, bool) {
+	total := int64(1)
+	for _, part := range strings.Split(s, "x") {
+		mult := int64(1)
+		for _, suf := range []struct {
+			s string
+			m int64
+		}{{"kB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"KiB", 1024}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+			{"c", 1}, {"w", 2}, {"b", 512}, {"K", 1024}, {"k", 1024}, {"M", 1 << 20}, {"G", 1 << 30}} {
+			if strings.HasSuffix(part, suf.s) {
+				part, mult = strings.TrimSuffix(part, suf.s), suf.m
+				break
+			}
+		}
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		total *= n * mult
+	}
+	return total, true
+}
+
+// ddHuman 按 dd 的习惯显示字节数，si 为 true 时使用 1000 进制
+func ddHuman(n float64, si bool) string {
+	base, units := 1024.0, []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	if si {
+		base, units = 1000, []string{"B", "kB", "MB", "GB", "TB"}
+	}
+	i := 0
+	for n >= base && i < len(units)-1 {
+		n /= base
+		i++
+	}
+	if n < 10 && i > 0 {
+		return fmt.Sprintf("%.1f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.0f %s", n, units[i])
+}
+
+func (t *Terminal) cmdDd(args []string, in io.Reader, out io.Writer) {
+	var inFile, outFile, statusMode string
+	ibs, obs := int64(512), int64(512)
+	count, skip, seek := int64(-1), int64(0), int64(0)
+	notrunc := false
+	for _, a := range args[1:] {
+		if a == "--help" || a == "--version" {
+			continue
+		}
+		key, val, found := strings.Cut(a, "=")
+		if !found {
+			t.usageError("dd", fmt.Sprintf("无法识别的操作数 '%s'", a), 1)
+			return
+		}
+		num := func(dst *int64) bool {
+			n, ok := ddSize(val)
+			if !ok || (n == 0 && key != "count" && key != "skip" && key != "seek") {
+				fmt.Fprintf(t.Stderr, "dd: 无效的数字：'%s'\n", val)
+				t.lastExitCode = 1
+				return false
+			}
+			*dst = n
+			return true
+		}
+		good := true
+		switch key {
+		case "if":
+			inFile = val
+		case "of":
+			outFile = val
+		case "bs":
+			good = num(&ibs)
+			obs = ibs
+		case "ibs":
+			good = num(&ibs)
+		case "obs":
+			good = num(&obs)
+		case "count":
+			good = num(&count)
+		case "skip", "iseek":
+			good = num(&skip)
+		case "seek", "oseek":
+			good = num(&seek)
+		case "conv":
+			notrunc = strings.Contains(val, "notrunc")
+		case "status":
+			statusMode = val
+		case "iflag", "oflag", "cbs":
+		default:
+			t.usageError("dd", fmt.Sprintf("无法识别的操作数 '%s'", a), 1)
+			return
+		}
+		if !good {
+			return
+		}
+	}
+
+	src := in
+	if inFile != "" {
+		r, reason := t.openInput(inFile, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "dd: 无法打开'%s': %s\n", inFile, reason)
+			t.lastExitCode = 1
+			return
+		}
+		src = r
+	}
+	if src == nil {
+		src = strings.NewReader("")
+	}
+	start := time.Now()
+	if skip > 0 {
+		io.CopyN(io.Discard, src, skip*ibs)
+	}
+
+	// 按输入块读取，区分完整块和不完整块；写到标准输出时边读边写
+	var data bytes.Buffer
+	var total int64
+	fullIn, partIn := int64(0), int64(0)
+	noSpace := false
+	buf := make([]byte, min(ibs, MaxFileSize+1))
+	for count < 0 || fullIn+partIn < count {
+		n, err := io.ReadFull(src, buf)
+		if n == 0 {
+			break
+		}
+		if n == len(buf) {
+			fullIn++
+		} else {
+			partIn++
+		}
+		if outFile == "" {
+			out.Write(buf[:n])
+		} else if data.Len()+n > MaxFileSize {
+			data.Write(buf[:MaxFileSize-data.Len()])
+			total = int64(data.Len())
+			noSpace = true
+			break
+		} else {
+			data.Write(buf[:n])
+		}
+		total += int64(n)
+		if err != nil || total >= devStreamLimit {
+			break
+		}
+	}
+
+	if outFile != "" {
+		p := t.FS.Abs(t.expandTilde(outFile))
+		var content []byte
+		if e, ok := t.FS.GetEntry(p); ok && !e.IsDir {
+			e.mu.RLock()
+			content = append(content, e.Content...)
+			e.mu.RUnlock()
+		}
+		off := min(seek*obs, MaxFileSize)
+		end := off + int64(data.Len())
+		if !notrunc {
+			content = content[:min(int64(len(content)), off)]
+		}
+		if int64(len(content)) < end {
+			content = append(content, make([]byte, end-int64(len(content)))...)
+		}
+		copy(content[off:], data.Bytes())
+		if len(content) > MaxFileSize {
+			content, noSpace = content[:MaxFileSize], true
+		}
+		if !t.writeTextFile("dd", outFile, content, false) {
+			t.lastExitCode = 1
+			return
+		}
+		if noSpace {
+			fmt.Fprintf(t.Stderr, "dd: 写入'%s' 出错: 设备上没有空间\n", outFile)
+			t.lastExitCode = 1
+		}
+	}
+
+	if statusMode == "none" {
+		return
+	}
+	fmt.Fprintf(t.Stderr, "记录了%d+%d 的读入\n记录了%d+%d 的写出\n", fullIn, partIn, total/obs, min(total%obs, 1))
+	if statusMode == "noxfer" {
+		return
+	}
+	secs := max(time.Since(start).Seconds(), 1e-6)
+	rate := ddHuman(float64(total)/secs, true) + "/s"
+	elapsed := strconv.FormatFloat(secs, 'g', 6, 64)
+	if total < 1000 {
+		fmt.Fprintf(t.Stderr, "%d字节已复制，%s s，%s\n", total, elapsed, rate)
+	} else {
+		fmt.Fprintf(t.Stderr, "%d字节（%s，%s）已复制，%s s，%s\n", total, ddHuman(float64(total), true), ddHuman(float64(total), false), elapsed, rate)
+	}
+}
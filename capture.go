@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ==========================================
+// 载荷捕获 (隔离区)
+// ==========================================
+
+// CaptureDir 捕获文件的落盘目录，文件以内容 SHA256 命名，天然去重
+var CaptureDir = "captures"
+
+// CapturePayload 将攻击者投递的文件保存到隔离区并记录来源，返回 SHA256
+func CapturePayload(source, remote, name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	log.Printf("[Capture] %s %s: %s (%d bytes) sha256=%s", source, remote, name, len(data), digest)
+
+	if len(data) == 0 {
+		return digest
+	}
+	if err := os.MkdirAll(CaptureDir, 0700); err != nil {
+		log.Printf("[Capture] Failed to create %s: %v", CaptureDir, err)
+		return digest
+	}
+	dst := filepath.Join(CaptureDir, digest)
+	if _, err := os.Stat(dst); err == nil {
+		return digest
+	}
+	// 隔离区中的文件不可执行
+	if err := os.WriteFile(dst, data, 0400); err != nil {
+		log.Printf("[Capture] Failed to store %s: %v", digest, err)
+	}
+	return digest
+}
@@ -7,13 +7,11 @@ import (
 	"io"
 	"math"
 	"math/rand"
-	"os"
 	"path"
-	"sort"
 	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
+	"unicode"
 )
 
 // runCommand 执行单个命令
@@ -23,119 +21,53 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 	}
 	cmd := args[0]
 
-	// 环境变量展开
-	for i := 1; i < len(args); i++ {
-		if strings.HasPrefix(args[i], "$") {
-			varName := args[i][1:]
-			if val, ok := t.Env[varName]; ok {
-				args[i] = val
-			} else if varName == "?" {
-				args[i] = strconv.Itoa(t.lastExitCode)
-			} else if varName == "$" {
-				args[i] = strconv.Itoa(t.pid)
+	// /bin/ls、/usr/bin/id 等绝对路径调用映射到内置命令
+	if strings.Contains(cmd, "/") {
+		if e, ok := t.FS.GetEntry(t.FS.Abs(cmd)); ok && !e.IsDir {
+			switch path.Dir(t.FS.Abs(cmd)) {
+			case "/bin", "/usr/bin", "/sbin", "/usr/sbin", "/usr/local/bin":
+				cmd = path.Base(cmd)
 			}
 		}
 	}
 
-	switch cmd {
-	case "ls", "ll":
-		dir := t.FS.cwd
-		opts := map[string]bool{"a": false, "l": cmd == "ll", "h": false, "t": false, "r": false, "R": false}
-		paths := []string{}
-		useColor := isTTY(out)
-
-		for _, arg := range args[1:] {
-			if strings.HasPrefix(arg, "-") {
-				for _, char := range arg[1:] {
-					opts[string(char)] = true
-				}
-			} else {
-				paths = append(paths, t.FS.Abs(arg))
+	// NAME=value 形式的变量赋值
+	if isAssignment(cmd) {
+		for _, a := range args {
+			if !isAssignment(a) {
+				fmt.Fprintf(out, "%s: 未找到命令\n", a)
+				t.lastExitCode = 127
+				return
 			}
 		}
-		if len(paths) == 0 {
-			paths = append(paths, dir)
+		for _, a := range args {
+			kv := strings.SplitN(a, "=", 2)
+			t.Env[kv[0]] = kv[1]
 		}
+		return
+	}
 
-		for _, p := range paths {
-			files, err := t.FS.ListDir(p)
-			if err != nil {
-				fmt.Fprintf(out, "ls: 无法访问 '%s': %v\n", p, err)
-				t.lastExitCode = 2
-				continue
-			}
+	// shell 函数优先于内置命令
+	if _, ok := t.funcs[cmd]; ok {
+		t.callFunction(cmd, args[1:], out)
+		return
+	}
 
-			if opts["t"] {
-				sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
-			}
-			if opts["r"] {
-				for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
+	switch cmd {
+	case "ls", "dir":
+		t.cmdLs(args, out)
 
-			if opts["l"] {
-				// 模拟 ls -l 输出
-				tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
-				total := 0
-				for _, f := range files {
-					if !opts["a"] && strings.HasPrefix(f.Name, ".") {
-						continue
-					}
-					total += len(f.Content)/1024 + 4
-				}
-				fmt.Fprintf(out, "total %d\n", total)
+	case "find":
+		t.cmdFind(args, out)
 
-				for _, f := range files {
-					if !opts["a"] && strings.HasPrefix(f.Name, ".") {
-						continue
-					}
-					modeStr := f.Mode.String()
-					userName, groupName := "root", "root"
-					if name, ok := getNameByID(Users, f.UID); ok {
-						userName = name
-					}
-					if name, ok := getNameByID(Groups, f.GID); ok {
-						groupName = name
-					}
-					sizeStr := strconv.Itoa(len(f.Content))
-					if opts["h"] {
-						sizeStr = formatSize(int64(len(f.Content)))
-					}
+	case "stat":
+		t.cmdStat(args, out)
 
-					// 颜色处理
-					nameColor := f.Name
-					if useColor {
-						if f.IsDir {
-							nameColor = "\033[1;34m" + f.Name + "\033[0m"
-						} else if f.Mode&0111 != 0 {
-							nameColor = "\033[1;32m" + f.Name + "\033[0m"
-						}
-					}
+	case "file":
+		t.cmdFile(args, out)
 
-					fmt.Fprintf(tw, "%s %d %s %s %5s %s %s\n",
-						modeStr, f.Nlink, userName, groupName, sizeStr,
-						f.ModTime.Format("Jan _2 15:04"), nameColor)
-				}
-				tw.Flush()
-			} else {
-				for _, f := range files {
-					if !opts["a"] && strings.HasPrefix(f.Name, ".") {
-						continue
-					}
-					nameColor := f.Name
-					if useColor {
-						if f.IsDir {
-							nameColor = "\033[1;34m" + f.Name + "\033[0m"
-						} else if f.Mode&0111 != 0 {
-							nameColor = "\033[1;32m" + f.Name + "\033[0m"
-						}
-					}
-					fmt.Fprintf(out, "%s  ", nameColor)
-				}
-				fmt.Fprint(out, "\n")
-			}
-		}
+	case "du":
+		t.cmdDu(args, out)
 
 	case "cd":
 		if len(args) > 1 {
@@ -146,8 +78,10 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 				fmt.Fprintf(out, "-bash: cd: %s: 没有那个文件或目录\n", args[1])
 				t.lastExitCode = 1
 			}
-		} else {
-			t.FS.cwd = "/root"
+		} else if home := t.FS.Abs(t.expandTilde("~")); home != "" {
+			if e, ok := t.FS.GetEntry(home); ok && e.IsDir {
+				t.FS.cwd = home
+			}
 		}
 
 	case "pwd":
@@ -158,11 +92,8 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 			for _, f := range args[1:] {
 				p := t.FS.Abs(f)
 				// 处理特殊设备
-				if p == "/dev/null" {
-					continue
-				}
-				if p == "/dev/zero" {
-					out.Write(make([]byte, 1024))
+				if r, ok := t.FS.Stream(p); ok {
+					io.Copy(out, r)
 					continue
 				}
 
@@ -188,8 +119,7 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 		}
 
 	case "echo":
-		msg := strings.Join(args[1:], " ")
-		fmt.Fprintf(out, "%s\n", msg)
+		cmdEcho(args, out)
 
 	case "cp":
 		if len(args) >= 3 {
@@ -235,7 +165,10 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 			if !strings.HasPrefix(f, "-") {
 				p := t.FS.Abs(f)
 				if _, ok := t.FS.GetEntry(p); ok {
-					t.FS.Remove(p)
+					if err := t.FS.Remove(p); err != nil {
+						fmt.Fprintf(out, "rm: 无法删除 '%s': %v\n", f, err)
+						t.lastExitCode = 1
+					}
 				} else {
 					fmt.Fprintf(out, "rm: 无法删除 '%s': 没有那个文件或目录\n", f)
 					t.lastExitCode = 1
@@ -251,70 +184,26 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 			}
 		}
 
-	case "grep":
-		if len(args) < 2 {
-			fmt.Fprintln(out, "Usage: grep [PATTERN] [FILE]...")
-			t.lastExitCode = 1
-			return 
-		}
-		pattern := args[1]
-		files := args[2:]
-		// 极简实现，忽略 flags
-		if strings.HasPrefix(pattern, "-") {
-			if len(files) > 0 {
-				pattern = files[0]
-				files = files[1:]
-			}
-		}
-		useColor := isTTY(out)
-
-		doGrep := func(scanner *bufio.Scanner, fname string) {
-			for scanner.Scan() {
-				line := scanner.Text()
-				if strings.Contains(line, pattern) {
-					prefix := ""
-					if len(files) > 1 {
-						prefix = fname + ":"
-						if useColor {
-							prefix = "\033[35m" + fname + "\033[0m:"
-						}
-					}
+	case "grep", "egrep", "fgrep":
+		t.cmdGrep(args, in, out)
 
-					outputLine := line
-					if useColor {
-						// 高亮匹配
-						outputLine = strings.ReplaceAll(line, pattern, "\033[1;31m"+pattern+"\033[0m")
-					}
-					fmt.Fprintf(out, "%s%s\n", prefix, outputLine)
-				}
-			}
-		}
+	case "ps":
+		t.cmdPs(args, out)
 
-		if len(files) == 0 {
-			// 使用 scanner 逐行读取，避免 io.ReadAll 导致的死锁
-			scanner := bufio.NewScanner(in)
-			doGrep(scanner, "(standard input)")
-		} else {
-			for _, f := range files {
-				if e, ok := t.FS.GetEntry(t.FS.Abs(f)); ok && !e.IsDir {
-					scanner := bufio.NewScanner(bytes.NewReader(e.Content))
-					doGrep(scanner, f)
-				} else {
-					fmt.Fprintf(out, "grep: %s: 没有那个文件或目录\n", f)
-				}
-			}
-		}
+	case "top":
+		t.cmdTop(args, out)
 
-	case "ps":
-		// 模拟真实 ps aux 输出
-		fmt.Fprintln(out, "USER         PID %CPU %MEM    VSZ   RSS TTY      STAT START   TIME COMMAND")
-		fmt.Fprintf(out, "root           1  0.0  0.1 168532 12856 ?        Ss   %s   0:02 /sbin/init\n", startTime.Format("15:04"))
-		fmt.Fprintf(out, "root           2  0.0  0.0      0     0 ?        S    %s   0:00 [kthreadd]\n", startTime.Format("15:04"))
-		fmt.Fprintf(out, "root         832  0.0  0.3  14520  6520 ?        Ss   %s   0:00 /usr/sbin/sshd -D\n", startTime.Format("15:04"))
-		// 当前进程
-		myPID := t.pid
-		fmt.Fprintf(out, "root        %d  0.0  0.1  12340  4320 pts/0    Ss   %s   0:00 -bash\n", myPID, time.Now().Format("15:04"))
-		fmt.Fprintf(out, "root        %d  0.0  0.0   9820  3210 pts/0    R+   %s   0:00 ps aux\n", myPID+10, time.Now().Format("15:04"))
+	case "vi", "vim":
+		t.cmdVi(args, out)
+
+	case "nano":
+		t.cmdNano(args, out)
+
+	case "kill":
+		t.cmdKill(args, out)
+
+	case "pgrep", "pkill", "killall":
+		t.cmdPgrep(args, out)
 
 	case "whoami":
 		t.mu.Lock()
@@ -322,59 +211,60 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 		t.mu.Unlock()
 
 	case "id":
-		u := "root"
-		t.mu.Lock()
-		if v, ok := t.Env["USER"]; ok {
-			u = v
-		}
-		t.mu.Unlock()
-		uid, _ := Users[u]
-		gid, _ := Groups[u]
-		fmt.Fprintf(out, "uid=%d(%s) gid=%d(%s) groups=%d(%s)\n", uid, u, gid, u, gid, u)
+		t.cmdId(args, out)
 
 	case "date":
 		fmt.Fprintln(out, time.Now().Format(time.UnixDate))
 
 	case "uptime":
-		d := time.Since(startTime)
-		fmt.Fprintf(out, " %s up %d min,  1 user,  load average: 0.00, 0.01, 0.05\n",
-			time.Now().Format("15:04:05"), int(d.Minutes()))
+		switch {
+		case len(args) < 2:
+			fmt.Fprintln(out, " "+uptimeSummary())
+		case args[1] == "-p" || args[1] == "--pretty":
+			fmt.Fprintln(out, prettyUp(time.Since(startTime)))
+		case args[1] == "-s" || args[1] == "--since":
+			fmt.Fprintln(out, startTime.Format("2006-01-02 15:04:05"))
+		default:
+			t.usageError(cmd, fmt.Sprintf("无效的选项 -- '%s'", strings.TrimLeft(args[1], "-")), 1)
+		}
 
 	case "clear":
 		out.Write([]byte("\033[H\033[2J"))
 
 	case "exit", "logout":
-		t.Running = false
-
-	case "wget", "curl":
-		if len(args) > 1 {
-			url := args[len(args)-1]
-			if cmd == "wget" {
-				fmt.Fprintf(out, "--%s--  %s\n", time.Now().Format("2006-01-02 15:04:05"), url)
-				fmt.Fprintf(out, "Resolving %s... 127.0.0.1\n", strings.Split(url, "/")[2])
-				fmt.Fprintln(out, "Connecting to 127.0.0.1... connected.")
-				fmt.Fprintln(out, "HTTP request sent, awaiting response... 404 Not Found")
-				fmt.Fprintln(out, "2023-01-01 12:00:00 ERROR 404: Not Found.")
-			} else {
-				fmt.Fprintln(out, "curl: (6) Could not resolve host: "+url)
-			}
-			t.lastExitCode = 1
-		} else {
-			fmt.Fprintf(out, "%s: try 'help'\n", cmd)
+		if t.interactive() && !t.exitWarned && t.hasStoppedJobs() {
+			fmt.Fprintln(out, "有停止的任务。")
+			t.exitWarned = true
 			t.lastExitCode = 1
+			return
+		}
+		// 嵌套在 su、sudo -i 中时只退出内层 shell
+		if t.leaveShell() {
+			if len(args) > 1 {
+				t.lastExitCode, _ = strconv.Atoi(args[1])
+			}
+			return
 		}
+		t.Running = false
+
+	case "wget":
+		t.cmdWget(args, out)
+
+	case "curl":
+		t.cmdCurl(args, out)
+
+	case "sh", "bash", "dash":
+		t.runScript(cmd, args, in, out)
 
 	case "uname":
 		if len(args) > 1 && args[1] == "-a" {
-			fmt.Fprintln(out, "Linux ubuntu-server 5.15.0-generic #1 SMP Fri Jan 1 00:00:00 UTC 2022 x86_64 x86_64 x86_64 GNU/Linux")
+			fmt.Fprintln(out, HostPersona.Uname())
 		} else {
 			fmt.Fprintln(out, "Linux")
 		}
 
 	case "free":
-		fmt.Fprintln(out, "              total        used        free      shared  buff/cache   available")
-		fmt.Fprintln(out, "Mem:       16303284     1024512     2543210        1234    12735562    15000000")
-		fmt.Fprintln(out, "Swap:       2097148           0     2097148")
+		t.cmdFree(args, out)
 
 	case "df":
 		fmt.Fprintln(out, "Filesystem      1K-blocks      Used Available Use% Mounted on")
@@ -382,16 +272,7 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 		fmt.Fprintln(out, "tmpfs             1630328         0   1630328   0% /run/user/0")
 
 	case "chmod":
-		if len(args) > 2 {
-			if m, err := strconv.ParseInt(args[1], 8, 32); err == nil {
-				for _, f := range args[2:] {
-					t.FS.Chmod(t.FS.Abs(f), os.FileMode(m))
-				}
-			} else {
-				fmt.Fprintf(out, "chmod: 无效模式: '%s'\n", args[1])
-				t.lastExitCode = 1
-			}
-		}
+		t.cmdChmod(args, out)
 
 	case "chown":
 		// 忽略实际 chown 逻辑，仅做样子
@@ -402,25 +283,39 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 
 	case "head", "tail":
 		// 简易实现
-		limit := 10
+		limit, byBytes := 10, false
 		files := []string{}
 		for i := 1; i < len(args); i++ {
-			if args[i] == "-n" && i+1 < len(args) {
+			if (args[i] == "-n" || args[i] == "-c") && i+1 < len(args) {
 				limit, _ = strconv.Atoi(args[i+1])
+				byBytes = args[i] == "-c"
 				i++
+			} else if n, err := strconv.Atoi(strings.TrimPrefix(args[i], "--bytes=")); err == nil && strings.HasPrefix(args[i], "--bytes=") {
+				limit, byBytes = n, true
+			} else if n, err := strconv.Atoi(strings.TrimPrefix(args[i], "-c")); err == nil && strings.HasPrefix(args[i], "-c") {
+				limit, byBytes = n, true
+			} else if n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "n")); err == nil && strings.HasPrefix(args[i], "-") {
+				// 兼容 head -5、head -n5 的写法
+				limit = n
 			} else {
 				files = append(files, args[i])
 			}
 		}
-		if len(files) == 0 {
-			// 从 stdin 读取，必须流式处理以避免管道死锁
-			if cmd == "head" {
-				scanner := bufio.NewScanner(in)
+		// 流式处理输入，避免管道死锁，也使 head -c 能读取 /dev/urandom 等无限设备
+		emit := func(r io.Reader) {
+			switch {
+			case byBytes && cmd == "head":
+				io.CopyN(out, r, int64(limit))
+			case byBytes:
+				data, _ := io.ReadAll(io.LimitReader(r, MaxFileSize))
+				out.Write(data[max(len(data)-limit, 0):])
+			case cmd == "head":
+				scanner := bufio.NewScanner(r)
 				for i := 0; i < limit && scanner.Scan(); i++ {
 					fmt.Fprintln(out, scanner.Text())
 				}
-			} else { // tail
-				scanner := bufio.NewScanner(in)
+			default:
+				scanner := bufio.NewScanner(r)
 				var lines []string
 				for scanner.Scan() {
 					lines = append(lines, scanner.Text())
@@ -433,40 +328,81 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 					fmt.Fprintln(out, lines[i])
 				}
 			}
+		}
+		if len(files) == 0 {
+			emit(in)
 		} else {
 			for _, f := range files {
 				if len(files) > 1 {
 					fmt.Fprintf(out, "==> %s <==\n", f)
 				}
-				if e, ok := t.FS.GetEntry(t.FS.Abs(f)); ok {
+				p := t.FS.Abs(f)
+				if r, ok := t.FS.Stream(p); ok {
+					emit(r)
+				} else if e, ok := t.FS.GetEntry(p); ok && byBytes {
+					emit(bytes.NewReader(e.Content))
+				} else if ok {
 					printLines(out, string(e.Content), cmd == "head", limit)
 				}
 			}
 		}
 
 	case "wc":
-		// 仅实现类似 wc -l 的行数统计
-		handleWc := func(r io.Reader) int {
-			count := 0
-			scanner := bufio.NewScanner(r)
-			for scanner.Scan() {
-				count++
-			}
-			return count
-		}
+		t.cmdWc(args, in, out)
 
-		if len(args) == 1 {
-			// 从 stdin 读取，流式处理
-			count := handleWc(in)
-			fmt.Fprintf(out, "%d\n", count)
-		} else {
-			for _, f := range args[1:] {
-				if e, ok := t.FS.GetEntry(t.FS.Abs(f)); ok {
-					count := handleWc(bytes.NewReader(e.Content))
-					fmt.Fprintf(out, "%d %s\n", count, f)
-				}
-			}
-		}
+	case "cut":
+		t.cmdCut(args, in, out)
+
+	case "sort":
+		t.cmdSort(args, in, out)
+
+	case "uniq":
+		t.cmdUniq(args, in, out)
+
+	case "tr":
+		t.cmdTr(args, in, out)
+
+	case "tee":
+		t.cmdTee(args, in, out)
+
+	case "xargs":
+		t.cmdXargs(args, in, out)
+
+	case "tar":
+		t.cmdTar(args, in, out)
+
+	case "gzip", "gunzip", "zcat":
+		t.cmdGzip(cmd, args, in, out)
+
+	case "unzip":
+		t.cmdUnzip(args, out)
+
+	case "base64":
+		t.cmdBase64(args, in, out)
+
+	case "xxd":
+		t.cmdXxd(args, in, out)
+
+	case "md5sum", "sha1sum", "sha224sum", "sha256sum", "sha384sum", "sha512sum":
+		t.cmdHashSum(cmd, args, in, out)
+
+	case "hexdump", "hd":
+		t.cmdHexdump(cmd, args, in, out)
+
+	case "od":
+		t.cmdOd(args, in, out)
+
+	case "strings":
+		t.cmdStrings(args, in, out)
+
+	case "dd":
+		t.cmdDd(args, in, out)
+
+	case "sed":
+		t.cmdSed(args, in, out)
+
+	case "awk", "mawk", "gawk":
+		t.cmdAwk(args, in, out)
 
 	case "ping":
 		if len(args) < 2 {
@@ -488,38 +424,149 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 		fmt.Fprintf(out, "rtt min/avg/max/mdev = 20.1/25.2/30.5/3.1 ms\n")
 
 	case "sudo":
-		if len(args) > 1 {
-			// 简单的 sudo 模拟：如果是 root 直接执行，否则...也直接执行（蜜罐通常允许权限）
-			// 实际上我们需要递归调用 runCommand
-			newArgs := args[1:]
-			t.runCommand(newArgs, in, out)
-		}
+		t.cmdSudo(args, in, out)
+
+	case "su":
+		t.cmdSu(args, in, out)
+
+	case "passwd":
+		t.cmdPasswd(args, in, out)
+
+	case "chpasswd":
+		t.cmdChpasswd(args, in)
+
+	case "useradd":
+		t.cmdUseradd(args, out)
+
+	case "usermod":
+		t.cmdUsermod(args, out)
 
 	case "sleep":
 		if len(args) > 1 {
-			if d, err := strconv.Atoi(args[1]); err == nil {
-				// 限制最大 sleep 时间，防止被 DoS
-				if d > 5 {
-					d = 5
+			if d, err := strconv.ParseFloat(strings.TrimSuffix(args[1], "s"), 64); err == nil {
+				// 限制最大 sleep 时间，防止被 DoS；作业控制下可被 Ctrl-C 打断，上限放宽
+				limit := 5.0
+				if t.job != nil {
+					limit = 3600
+				}
+				if d > limit {
+					d = limit
+				}
+				if !t.pause(time.Duration(d * float64(time.Second))) {
+					t.lastExitCode = 128 + sigINT
 				}
-				time.Sleep(time.Duration(d) * time.Second)
 			}
 		}
 
-	case "netstat", "ss":
-		fmt.Fprintln(out, "Active Internet connections (w/o servers)")
-		fmt.Fprintln(out, "Proto Recv-Q Send-Q Local Address           Foreign Address         State")
-		fmt.Fprintf(out, "tcp        0     64 192.168.1.10:22         192.168.1.5:5678        ESTABLISHED\n")
+	case "jobs":
+		t.cmdJobs(args, out)
 
-	case "more", "less":
-		// 简化为 cat
-		if len(args) > 1 {
-			t.runCommand(append([]string{"cat"}, args[1:]...), in, out)
+	case "fg", "bg":
+		t.cmdFgBg(args, out)
+
+	case "wait":
+		t.cmdWait(args)
+
+	case "disown":
+		t.cmdDisown(args)
+
+	case "nohup":
+		t.cmdNohup(args, in, out)
+
+	case "netstat":
+		t.cmdNetstat(args, out)
+
+	case "ss":
+		t.cmdSs(args, out)
+
+	case "ip":
+		t.cmdIP(args, out)
+
+	case "ifconfig":
+		t.cmdIfconfig(args, out)
+
+	case "route":
+		t.cmdRoute(args, out)
+
+	case "arp":
+		t.cmdArp(args, out)
+
+	case "hostname":
+		t.cmdHostname(args, out)
+
+	case "apt", "apt-get", "apt-cache":
+		t.cmdApt(args, in, out)
+
+	case "dpkg", "dpkg-query":
+		t.cmdDpkg(args, out)
+
+	case "systemctl":
+		t.cmdSystemctl(args, out)
+
+	case "service":
+		t.cmdService(args, out)
+
+	case "crontab":
+		t.cmdCrontab(args, in, out)
+
+	case "at":
+		t.cmdAt(args, in, out)
+
+	case "atq":
+		t.cmdAtq(args, out)
+
+	case "atrm":
+		t.cmdAtrm(args)
+
+	case "pip", "pip3":
+		if _, ok := t.debInstalled("python3-pip"); !ok {
+			t.commandNotFound(args, out)
+			t.lastExitCode = 127
+			break
 		}
+		t.cmdPip(args, in, out)
+
+	case "more", "less":
+		t.cmdPager(args, in, out)
+
+	case "man", "apropos", "whatis":
+		t.cmdMan(args, out)
 
 	case "history":
-		for i, h := range t.History {
-			fmt.Fprintf(out, "%5d  %s\n", i+1, h)
+		t.cmdHistory(args, out)
+
+	case "alias":
+		t.cmdAlias(args, out)
+
+	case "unalias":
+		t.cmdUnalias(args)
+
+	case "type":
+		t.cmdType(args, out)
+
+	case "source", ".":
+		t.cmdSource(args, out)
+
+	case "test", "[":
+		t.cmdTest(args)
+
+	case "true", ":", "shopt", "mesg":
+		// shopt/mesg 只影响真实终端的行为，这里什么也不做
+
+	case "false":
+		t.lastExitCode = 1
+
+	case "unset":
+		for _, name := range args[1:] {
+			if name == "-v" || name == "-f" {
+				continue
+			}
+			if !isIdentifier(name) {
+				fmt.Fprintf(t.Stderr, "bash: unset: `%s': 不是有效的标识符\n", name)
+				t.lastExitCode = 1
+				continue
+			}
+			delete(t.Env, name)
 		}
 
 	case "export":
@@ -539,25 +586,30 @@ func (t *Terminal) runCommand(args []string, in io.Reader, out io.Writer) {
 	case "kernelpanic":
 		pr, pw := io.Pipe()
 
-		t.mu.Lock()
-		t.RawModeWriter = pw
-		t.mu.Unlock()
+		// 原始输入由登录 shell 的 inputLoop 转发
+		rt := t.root()
+		rt.mu.Lock()
+		rt.RawModeWriter = pw
+		rt.mu.Unlock()
 
 		// 恢复函数
 		defer func() {
-			t.mu.Lock()
-			t.RawModeWriter = nil
-			t.mu.Unlock()
+			rt.mu.Lock()
+			rt.RawModeWriter = nil
+			rt.mu.Unlock()
 			pw.Close()
 		}()
 
 		RunKernelPanic(out, pr, func() (int, int) {
-			t.mu.Lock()
-			defer t.mu.Unlock()
-			return t.Width, t.Height
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			return rt.Width, rt.Height
 		})
 
 	default:
+		if t.execFile(args, in, out) || t.commandNotFound(args, out) {
+			return
+		}
 		fmt.Fprintf(out, "%s: 未找到命令\n", cmd)
 		t.lastExitCode = 127
 	}
@@ -578,12 +630,137 @@ func isTTY(w io.Writer) bool {
 	if _, ok := w.(*CRLFWriter); ok {
 		return true
 	}
+	// 重定向到文件或非交互执行时输出到缓冲区
+	if _, ok := w.(*bytes.Buffer); ok {
+		return false
+	}
+	// 重定向到 /dev/null
+	if w == io.Discard {
+		return false
+	}
 	// 备用方案，安全起见假设是 TTY (例如，对于单个命令或未知写入器)
 	return true
 }
 
+// cmdEcho 实现 bash 内置的 echo：只有全部由 n、e、E 组成的参数才是选项
+func cmdEcho(args []string, out io.Writer) {
+	newline, escapes := true, false
+	i := 1
+	for ; i < len(args); i++ {
+		a := args[i]
+		if len(a) < 2 || a[0] != '-' || strings.Trim(a[1:], "neE") != "" {
+			break
+		}
+		for _, c := range a[1:] {
+			switch c {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+	}
+	msg := strings.Join(args[i:], " ")
+	if escapes {
+		var stop bool
+		if msg, stop = echoEscapes(msg); stop {
+			newline = false
+		}
+	}
+	if newline {
+		msg += "\n"
+	}
+	io.WriteString(out, msg)
+}
+
+// echoEscapes 处理 echo -e 的反斜杠转义；遇到 \c 时截断并返回 stop
+func echoEscapes(s string) (string, bool) {
+	if !strings.Contains(s, `\`) {
+		return s, false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'c':
+			return b.String(), true
+		case 'e', 'E':
+			b.WriteByte(033)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\':
+			b.WriteByte('\\')
+		case '0', 'x', 'u', 'U':
+			// \0nnn 八进制，\xHH 十六进制字节，\uHHHH、\UHHHHHHHH 为 Unicode 字符
+			digits, width := "0123456789abcdefABCDEF", map[byte]int{'0': 3, 'x': 2, 'u': 4, 'U': 8}[c]
+			base := 16
+			if c == '0' {
+				digits, base = "01234567", 8
+			}
+			j := i + 1
+			for j < len(s) && j-i-1 < width && strings.IndexByte(digits, s[j]) >= 0 {
+				j++
+			}
+			if j == i+1 && c != '0' {
+				b.WriteByte('\\')
+				b.WriteByte(c)
+				break
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], base, 32)
+			if c == 'u' || c == 'U' {
+				b.WriteRune(rune(n))
+			} else {
+				b.WriteByte(byte(n))
+			}
+			i = j - 1
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), false
+}
+
 // 辅助函数
 
+// isIdentifier 判断是否为合法的 shell 变量名
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAssignment 判断参数是否为 NAME=value 形式的赋值
+func isAssignment(s string) bool {
+	i := strings.IndexByte(s, '=')
+	return i > 0 && isIdentifier(s[:i])
+}
+
 func getNameByID(m map[string]int, id int) (string, bool) {
 	for k, v := range m {
 		if v == id {
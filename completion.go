@@ -0,0 +1,679 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// Tab 补全
+// 行为与 bash + bash-completion 一致：第一次 TAB 补全唯一候选或最长公共前缀，
+// 第二次 TAB 按终端宽度分栏列出候选，候选过多时先询问。
+// ==========================================
+
+// completionQueryItems 对应 readline 的 completion-query-items
+const completionQueryItems = 100
+
+// completer 为某个命令的参数生成候选，args 为当前简单命令中已输入的完整参数
+type completer func(t *Terminal, args []string, word string) []string
+
+// completers 按命令名注册的参数补全函数，未注册的命令补全文件名
+var completers map[string]completer
+
+func init() {
+	completers = map[string]completer{
+		"cd":        completeDirs,
+		"pushd":     completeDirs,
+		"rmdir":     completeDirs,
+		"ssh":       completeHosts,
+		"sftp":      completeHosts,
+		"ping":      completeHosts,
+		"telnet":    completeHosts,
+		"ftp":       completeHosts,
+		"scp":       completeScp,
+		"kill":      completeKill,
+		"systemctl": completeSystemctl,
+		"service":   completeService,
+		"su":        completeUsers,
+		"id":        completeUsers,
+		"groups":    completeUsers,
+		"export":    completeVarNames,
+		"unset":     completeVarNames,
+		"which":     completeCommandNames,
+		"type":      completeCommandNames,
+		"man":       completeCommandNames,
+	}
+}
+
+// commandWrappers 后面跟的是另一条命令
+var commandWrappers = map[string]bool{
+	"sudo": true, "nohup": true, "time": true, "xargs": true, "exec": true,
+	"command": true, "nice": true, "watch": true, "timeout": true, "strace": true,
+}
+
+// completionWord 描述光标所在的单词
+type completionWord struct {
+	args     []string // 当前简单命令中光标前的完整参数
+	word     string   // 当前单词 (已去掉引号和转义)
+	start    int      // 当前单词在 buffer 中的起始下标
+	quote    rune     // 光标处未闭合的引号
+	redirect bool     // 当前单词是重定向目标
+}
+
+// parseCompletionWord 解析 buffer 中光标之前的部分
+func parseCompletionWord(line []rune) completionWord {
+	var c completionWord
+	var cur strings.Builder
+	inWord := false
+	escaped := false
+	redirNext := false
+	flush := func() {
+		if inWord {
+			if redirNext {
+				redirNext = false
+			} else {
+				c.args = append(c.args, cur.String())
+			}
+		}
+		cur.Reset()
+		inWord = false
+	}
+	for i, r := range line {
+		if !inWord {
+			c.start = i
+		}
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+			inWord = true
+		case c.quote != 0:
+			if r == c.quote {
+				c.quote = 0
+			} else if r == '\\' && c.quote == '"' {
+				escaped = true
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == '\'' || r == '"':
+			c.quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case strings.ContainsRune("|;&()", r):
+			flush()
+			c.args = c.args[:0]
+			redirNext = false
+		case r == '<' || r == '>':
+			flush()
+			redirNext = true
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if !inWord {
+		c.start = len(line)
+	}
+	c.word = cur.String()
+	c.redirect = redirNext
+	return c
+}
+
+// escapeCompletion 按 bash 规则转义插入的候选
+func escapeCompletion(s string, quote rune) string {
+	special := " \t\n\"'\\$&|;()<>*?[]!{}#`"
+	switch quote {
+	case '\'':
+		return s
+	case '"':
+		special = "\"$\\`"
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// candidates 计算当前单词的候选 (未转义的完整单词)
+func (t *Terminal) candidates(c completionWord) []string {
+	word := c.word
+	switch {
+	case strings.HasPrefix(word, "${"):
+		var res []string
+		for _, v := range t.varNames(word[2:]) {
+			res = append(res, "${"+v+"}")
+		}
+		return res
+	case strings.HasPrefix(word, "$"):
+		var res []string
+		for _, v := range t.varNames(word[1:]) {
+			res = append(res, "$"+v)
+		}
+		return res
+	case strings.HasPrefix(word, "~") && !strings.Contains(word, "/"):
+		var res []string
+		for _, u := range t.userNames(word[1:]) {
+			res = append(res, "~"+u+"/")
+		}
+		return res
+	case c.redirect:
+		return t.completePaths(word, false, false)
+	}
+
+	args := c.args
+	for len(args) > 0 && commandWrappers[args[0]] {
+		args = args[1:]
+		// sudo -u user 之类的选项
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		if strings.Contains(word, "/") {
+			return t.completePaths(word, false, true)
+		}
+		return t.commandNames(word)
+	}
+	if fn, ok := completers[args[0]]; ok {
+		return fn(t, args, word)
+	}
+	return t.completePaths(word, false, false)
+}
+
+// handleAutoComplete 处理 TAB 键
+func (t *Terminal) handleAutoComplete() {
+	c := parseCompletionWord(t.buffer[:t.cursor])
+	cands := t.candidates(c)
+	t.action = "complete"
+
+	// 去重并排序
+	seen := make(map[string]bool)
+	var clean []string
+	for _, s := range cands {
+		if !seen[s] {
+			seen[s] = true
+			clean = append(clean, s)
+		}
+	}
+	sort.Strings(clean)
+	cands = clean
+
+	switch {
+	case len(cands) == 0:
+		t.RW.Write([]byte("\a"))
+	case len(cands) == 1:
+		s := cands[0]
+		suffix := ""
+		if !strings.HasSuffix(s, "/") && !strings.HasSuffix(s, ":") {
+			if c.quote != 0 {
+				suffix = string(c.quote)
+			}
+			suffix += " "
+		}
+		t.replaceWord(c, s, suffix)
+	default:
+		if lcp := commonPrefix(cands); len(lcp) > len(c.word) {
+			t.replaceWord(c, lcp, "")
+			return
+		}
+		if t.lastAction != "complete" {
+			t.RW.Write([]byte("\a"))
+			return
+		}
+		if len(cands) >= completionQueryItems {
+			t.leaveLine()
+			t.Print("Display all " + strconv.Itoa(len(cands)) + " possibilities? (y or n)")
+			t.completeAsk = cands
+			return
+		}
+		t.leaveLine()
+		t.listCandidates(cands)
+	}
+}
+
+// answerCompletionQuery 处理 "Display all N possibilities?" 的回答
+func (t *Terminal) answerCompletionQuery(ev keyEvent) {
+	cands := t.completeAsk
+	if ev.code != keyRune {
+		return
+	}
+	switch ev.r {
+	case 'y', 'Y', ' ':
+		t.completeAsk = nil
+		t.Print("\n")
+		t.listCandidates(cands)
+	case 'n', 'N', 127, 8, 3, 7:
+		t.completeAsk = nil
+		t.Print("\n")
+		t.Prompt()
+	}
+}
+
+// replaceWord 用候选替换光标所在的单词
+func (t *Terminal) replaceWord(c completionWord, s, suffix string) {
+	repl := s
+	if !strings.HasPrefix(s, "$") {
+		repl = escapeCompletion(s, c.quote)
+	}
+	if c.quote != 0 {
+		repl = string(c.quote) + repl
+	}
+	repl += suffix
+	rest := append([]rune(nil), t.buffer[t.cursor:]...)
+	t.buffer = append(append(t.buffer[:c.start], []rune(repl)...), rest...)
+	t.cursor = c.start + len([]rune(repl))
+	t.refreshLine()
+}
+
+// leaveLine 把终端光标移到编辑区之后的新行，不改变编辑状态
+func (t *Terminal) leaveLine() {
+	cur := t.cursor
+	t.cursor = len(t.buffer)
+	t.refreshLine()
+	t.cursor = cur
+	t.RW.Write([]byte("\r\n"))
+}
+
+func (t *Terminal) listCandidates(cands []string) {
+	items := make([]string, len(cands))
+	for i, s := range cands {
+		items[i] = completionDisplay(s)
+	}
+	t.Print(columnize(items, t.editWidth()))
+	t.Prompt()
+}
+
+// completionDisplay 返回列表中显示的名称：路径只显示最后一段
+func completionDisplay(s string) string {
+	trimmed := strings.TrimSuffix(s, "/")
+	if strings.HasPrefix(trimmed, "~") && !strings.Contains(trimmed, "/") {
+		return trimmed
+	}
+	if i := strings.LastIndex(trimmed, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// columnize 按列优先顺序把条目排成适合终端宽度的多栏
+func columnize(items []string, width int) string {
+	maxW := 0
+	for _, s := range items {
+		if w := visibleWidth(s); w > maxW {
+			maxW = w
+		}
+	}
+	colW := maxW + 2
+	cols := width / colW
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(items) + cols - 1) / cols
+	var b strings.Builder
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			i := c*rows + r
+			if i >= len(items) {
+				break
+			}
+			b.WriteString(items[i])
+			if (c+1)*rows+r < len(items) {
+				b.WriteString(strings.Repeat(" ", colW-visibleWidth(items[i])))
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	p := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, p) {
+			_, size := lastRune(p)
+			p = p[:len(p)-size]
+		}
+	}
+	return p
+}
+
+func lastRune(s string) (rune, int) {
+	rs := []rune(s)
+	if len(rs) == 0 {
+		return 0, 0
+	}
+	r := rs[len(rs)-1]
+	return r, len(string(r))
+}
+
+// ==========================================
+// 候选来源
+// ==========================================
+
+// commandNames 返回 $PATH 中的可执行文件和内置命令
+func (t *Terminal) commandNames(prefix string) []string {
+	var res []string
+	for _, m := range []map[string]bool{shellBuiltins} {
+		for name := range m {
+			if strings.HasPrefix(name, prefix) {
+				res = append(res, name)
+			}
+		}
+	}
+	for _, m := range []map[string]string{t.aliases, t.funcs} {
+		for name := range m {
+			if strings.HasPrefix(name, prefix) {
+				res = append(res, name)
+			}
+		}
+	}
+	t.mu.Lock()
+	pathVar, ok := t.Env["PATH"]
+	t.mu.Unlock()
+	if !ok {
+		pathVar = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+	for _, dir := range strings.Split(pathVar, ":") {
+		if dir == "" {
+			continue
+		}
+		files, _ := t.FS.ListDir(t.FS.Abs(dir))
+		for _, f := range files {
+			if !f.IsDir && f.Mode&0111 != 0 && strings.HasPrefix(f.Name, prefix) {
+				res = append(res, f.Name)
+			}
+		}
+	}
+	return res
+}
+
+// expandTilde 把 ~ 和 ~user 开头的路径展开为绝对路径
+func (t *Terminal) expandTilde(p string) string {
+	if !strings.HasPrefix(p, "~") {
+		return p
+	}
+	name, rest := p[1:], ""
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	home := ""
+	if name == "" {
+		t.mu.Lock()
+		home = t.Env["HOME"]
+		t.mu.Unlock()
+	} else if h, ok := lookupHome(t.FS, name); ok {
+		home = h
+	}
+	if home == "" {
+		return p
+	}
+	return home + rest
+}
+
+// completePaths 补全文件名；dirsOnly 只列目录，execOnly 只列可执行文件和目录
+func (t *Terminal) completePaths(word string, dirsOnly, execOnly bool) []string {
+	dir, prefix := path.Split(word)
+	lookup := t.expandTilde(dir)
+	if lookup == "" {
+		lookup = "."
+	}
+	files, err := t.FS.ListDir(t.FS.Abs(lookup))
+	if err != nil {
+		return nil
+	}
+	var res []string
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(f.Name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		switch {
+		case f.IsDir:
+			res = append(res, dir+f.Name+"/")
+		case dirsOnly, execOnly && f.Mode&0111 == 0:
+		default:
+			res = append(res, dir+f.Name)
+		}
+	}
+	return res
+}
+
+// varNames 返回以 prefix 开头的变量名
+func (t *Terminal) varNames(prefix string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var res []string
+	for k := range t.Env {
+		if strings.HasPrefix(k, prefix) {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// userNames 返回 /etc/passwd 中以 prefix 开头的用户名
+func (t *Terminal) userNames(prefix string) []string {
+	e, ok := t.FS.GetEntry("/etc/passwd")
+	if !ok {
+		return nil
+	}
+	e.mu.RLock()
+	content := string(e.Content)
+	e.mu.RUnlock()
+	var res []string
+	for _, line := range strings.Split(content, "\n") {
+		name, _, _ := strings.Cut(line, ":")
+		if name != "" && strings.HasPrefix(name, prefix) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// knownHosts 从 /etc/hosts、~/.ssh/known_hosts 和 ~/.ssh/config 收集主机名
+func (t *Terminal) knownHosts() []string {
+	read := func(p string) string {
+		e, ok := t.FS.GetEntry(t.FS.Abs(t.expandTilde(p)))
+		if !ok || e.IsDir {
+			return ""
+		}
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return string(e.Content)
+	}
+	var hosts []string
+	for _, line := range strings.Split(read("/etc/hosts"), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if f := strings.Fields(line); len(f) > 1 {
+			hosts = append(hosts, f[1:]...)
+		}
+	}
+	for _, line := range strings.Split(read("~/.ssh/known_hosts"), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") || strings.HasPrefix(f[0], "|") {
+			continue // 注释和 HashKnownHosts 加密的条目
+		}
+		if strings.HasPrefix(f[0], "@") && len(f) > 1 {
+			f = f[1:] // @cert-authority / @revoked
+		}
+		for _, h := range strings.Split(f[0], ",") {
+			if strings.HasPrefix(h, "[") {
+				h = strings.TrimPrefix(h[:strings.IndexByte(h+"]", ']')], "[")
+			}
+			hosts = append(hosts, h)
+		}
+	}
+	for _, line := range strings.Split(read("~/.ssh/config"), "\n") {
+		f := strings.Fields(line)
+		if len(f) > 1 && strings.EqualFold(f[0], "Host") {
+			for _, h := range f[1:] {
+				if !strings.ContainsAny(h, "*?!") {
+					hosts = append(hosts, h)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// ==========================================
+// 各命令的参数补全
+// ==========================================
+
+func completeDirs(t *Terminal, args []string, word string) []string {
+	return t.completePaths(word, true, false)
+}
+
+func completeCommandNames(t *Terminal, args []string, word string) []string {
+	return t.commandNames(word)
+}
+
+func completeUsers(t *Terminal, args []string, word string) []string {
+	if strings.HasPrefix(word, "-") {
+		return nil
+	}
+	return t.userNames(word)
+}
+
+func completeVarNames(t *Terminal, args []string, word string) []string {
+	if strings.Contains(word, "=") {
+		return nil
+	}
+	return t.varNames(word)
+}
+
+// completeHosts 补全 [user@]host
+func completeHosts(t *Terminal, args []string, word string) []string {
+	if strings.HasPrefix(word, "-") {
+		return nil
+	}
+	user := ""
+	if i := strings.IndexByte(word, '@'); i >= 0 {
+		user, word = word[:i+1], word[i+1:]
+	}
+	var res []string
+	for _, h := range t.knownHosts() {
+		if strings.HasPrefix(h, word) {
+			res = append(res, user+h)
+		}
+	}
+	return res
+}
+
+// completeScp 本地文件或 host: 形式的远程位置
+func completeScp(t *Terminal, args []string, word string) []string {
+	if strings.Contains(word, ":") || strings.HasPrefix(word, "-") {
+		return nil
+	}
+	res := t.completePaths(word, false, false)
+	if !strings.Contains(word, "/") {
+		for _, h := range completeHosts(t, args, word) {
+			res = append(res, h+":")
+		}
+	}
+	return res
+}
+
+// completeKill 补全信号名、作业号和 PID
+func completeKill(t *Terminal, args []string, word string) []string {
+	var res []string
+	switch {
+	case strings.HasPrefix(word, "-"):
+		for _, name := range signalNames[1:] {
+			if s := "-" + name; strings.HasPrefix(s, word) {
+				res = append(res, s)
+			}
+		}
+	case strings.HasPrefix(word, "%"):
+		root := t.root()
+		root.jobsMu.Lock()
+		for _, j := range root.jobs {
+			if s := "%" + strconv.Itoa(j.ID); strings.HasPrefix(s, word) {
+				res = append(res, s)
+			}
+		}
+		root.jobsMu.Unlock()
+	default:
+		for _, p := range Procs.Snapshot() {
+			if s := strconv.Itoa(p.PID); strings.HasPrefix(s, word) {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+var systemctlCommands = []string{
+	"cat", "daemon-reload", "disable", "edit", "enable", "is-active", "is-enabled",
+	"is-failed", "list-timers", "list-unit-files", "list-units", "mask", "reload",
+	"restart", "show", "start", "status", "stop", "try-restart", "unmask",
+}
+
+// completeSystemctl 第一个参数补全子命令，之后补全单元名
+func completeSystemctl(t *Terminal, args []string, word string) []string {
+	var res []string
+	sub := ""
+	for _, a := range args[1:] {
+		if !strings.HasPrefix(a, "-") {
+			sub = a
+			break
+		}
+	}
+	if sub == "" {
+		for _, c := range systemctlCommands {
+			if strings.HasPrefix(c, word) {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+	for _, u := range t.systemdUnits() {
+		if strings.HasPrefix(u, word) {
+			res = append(res, u)
+		}
+	}
+	return res
+}
+
+// completeService service <名称> <动作>
+func completeService(t *Terminal, args []string, word string) []string {
+	var res []string
+	if len(args) == 1 {
+		for _, u := range t.systemdUnits() {
+			if name := strings.TrimSuffix(u, ".service"); name != u && strings.HasPrefix(name, word) {
+				res = append(res, name)
+			}
+		}
+		return res
+	}
+	for _, a := range []string{"start", "stop", "restart", "reload", "status", "force-reload"} {
+		if strings.HasPrefix(a, word) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// systemdUnits 列出单元注册表中的单元名
+func (t *Terminal) systemdUnits() []string {
+	var res []string
+	for _, u := range t.unitRegistry().units() {
+		res = append(res, u.Name)
+	}
+	return res
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 计划任务: crontab, at, atq, atrm
+// 用户 crontab 保存在 /var/spool/cron/crontabs/<user>，at 作业保存在 /var/spool/cron/atjobs；
+// 写入这些位置会触发 persist.go 中的持久化检测
+// ==========================================
+
+const (
+	crontabDir = "/var/spool/cron/crontabs"
+	atjobsDir  = "/var/spool/cron/atjobs"
+)
+
+// crontabTemplate Debian 的 crontab -e 在没有 crontab 时提供的模板
+const crontabTemplate = `# Edit this file to introduce tasks to be run by cron.
+#
+# Each task to run has to be defined through a single line
+# indicating with different fields when the task will be run
+# and what command to run for the task
+#
+# To define the time you can provide concrete values for
+# minute (m), hour (h), day of month (dom), month (mon),
+# and day of week (dow) or use '*' in these fields (for 'any').
+#
+# Notice that tasks will be started based on the cron's system
+# daemon's notion of time and timezones.
+#
+# Output of the crontab jobs (including errors) is sent through
+# email to the user the crontab file belongs to (unless redirected).
+#
+# For example, you can run a backup of all your user accounts
+# at 5 a.m every week with:
+# 0 5 * * 1 tar -zcf /var/backups/home.tgz /home/
+#
+# For more information see the manual pages of crontab(5) and cron(8)
+#
+# m h  dom mon dow   command
+`
+
+// systemCrontab Ubuntu 默认的 /etc/crontab
+const systemCrontab = `# /etc/crontab: system-wide crontab
+# Unlike any other crontab you don't have to run the ` + "`crontab'" + `
+# command to install the new version when you edit this file
+# and files in /etc/cron.d. These files also have username fields,
+# that none of the other crontabs do.
+
+SHELL=/bin/sh
+# You can also override PATH, but by default, newer versions inherit it from the environment
+#PATH=/usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin
+
+# Example of job definition:
+# .---------------- minute (0 - 59)
+# |  .------------- hour (0 - 23)
+# |  |  .---------- day of month (1 - 31)
+# |  |  |  .------- month (1 - 12) OR jan,feb,mar,apr ...
+# |  |  |  |  .---- day of week (0 - 6) (Sunday=0 or 7) OR sun,mon,tue,wed,thu,fri,sat
+# |  |  |  |  |
+# *  *  *  *  * user-name command to be executed
+17 *	* * *	root    cd / && run-parts --report /etc/cron.hourly
+25 6	* * *	root	test -x /usr/sbin/anacron || ( cd / && run-parts --report /etc/cron.daily )
+47 6	* * 7	root	test -x /usr/sbin/anacron || ( cd / && run-parts --report /etc/cron.weekly )
+52 6	1 * *	root	test -x /usr/sbin/anacron || ( cd / && run-parts --report /etc/cron.monthly )
+#
+`
+
+const crontabUsage = `usage:	crontab [-u user] file
+	crontab [ -u user ] [ -i ] { -e | -l | -r }
+		(default operation is replace, per 1003.2)
+	-e	(edit user's crontab)
+	-l	(list user's crontab)
+	-r	(delete user's crontab)
+	-i	(prompt before deleting user's crontab)
+`
+
+var cronFields = []struct {
+	name   string
+	lo, hi int
+	names  []string
+}{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day-of-month", 1, 31, nil},
+	{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{"day-of-week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var (
+	cronSpecials = map[string]bool{"@reboot": true, "@yearly": true, "@annually": true, "@monthly": true,
+		"@weekly": true, "@daily": true, "@midnight": true, "@hourly": true}
+	cronEnvLine = regexp.MustCompile(`^[^\s=]+\s*=`)
+)
+
+// cronValue 解析单个值：数字或名称 (月份从 1 开始，星期从 0 开始)
+func cronValue(s string, lo, hi int, names []string) (int, bool) {
+	for i, n := range names {
+		if strings.EqualFold(s, n) {
+			return i + lo, true
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= lo && n <= hi
+}
+
+// cronField 校验一个时间字段：逗号分隔的 *、值或范围，可带 /步长
+func cronField(f string, lo, hi int, names []string) bool {
+	for _, item := range strings.Split(f, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n <= 0 {
+				return false
+			}
+		}
+		if rng == "*" {
+			continue
+		}
+		a, b, isRange := strings.Cut(rng, "-")
+		x, ok := cronValue(a, lo, hi, names)
+		if !ok {
+			return false
+		}
+		if isRange {
+			if y, ok := cronValue(b, lo, hi, names); !ok || y < x {
+				return false
+			}
+		} else if hasStep {
+			return false
+		}
+	}
+	return true
+}
+
+// cronCheck 按 cron 的规则校验用户 crontab，返回第一处错误的行号和原因
+func cronCheck(content string) (int, string) {
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || cronEnvLine.MatchString(line) {
+			continue
+		}
+		fields := strings.Fields(line)
+		if line[0] == '@' {
+			if !cronSpecials[strings.ToLower(fields[0])] {
+				return i + 1, "bad time specifier"
+			}
+			if len(fields) < 2 {
+				return i + 1, "bad command"
+			}
+			continue
+		}
+		for j, f := range cronFields {
+			if j >= len(fields) || !cronField(fields[j], f.lo, f.hi, f.names) {
+				return i + 1, "bad " + f.name
+			}
+		}
+		if len(fields) < 6 {
+			return i + 1, "bad command"
+		}
+	}
+	return 0, ""
+}
+
+// crontabBody 去掉安装时加入的三行文件头
+func crontabBody(content string) string {
+	if !strings.HasPrefix(content, "# DO NOT EDIT THIS FILE") {
+		return content
+	}
+	for i := 0; i < 3; i++ {
+		_, content, _ = strings.Cut(content, "\n")
+	}
+	return content
+}
+
+// crontabInstall 校验并安装 crontab，name 是错误信息中显示的文件名
+func (t *Terminal) crontabInstall(user, name, body string) bool {
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		fmt.Fprintln(t.Stderr, "new crontab file is missing newline before EOF, can't install.")
+		return false
+	}
+	if n, msg := cronCheck(body); n > 0 {
+		fmt.Fprintf(t.Stderr, "\"%s\":%d: %s\n", name, n, msg)
+		fmt.Fprintln(t.Stderr, "errors in crontab file, can't install.")
+		return false
+	}
+	header := fmt.Sprintf("# DO NOT EDIT THIS FILE - edit the master and reinstall.\n# (%s installed on %s)\n"+
+		"# (Cron version -- $Id: crontab.c,v 2.13 1994/01/17 03:20:37 vixie Exp $)\n", name, time.Now().Format("Mon Jan _2 15:04:05 2006"))
+	p := path.Join(crontabDir, user)
+	t.FS.Write(p, []byte(header+body), 0600)
+	t.FS.Chown(p, t.FS.uid(user), t.FS.gid("crontab"))
+	return true
+}
+
+func (t *Terminal) cmdCrontab(args []string, in io.Reader, out io.Writer) {
+	self := t.userName()
+	user, op, file := self, "", ""
+	confirm := false
+	usage := func(msg string) {
+		fmt.Fprintf(t.Stderr, "crontab: usage error: %s\n%s", msg, crontabUsage)
+		t.lastExitCode = 1
+	}
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-u" && i+1 < len(args):
+			i++
+			user = args[i]
+		case a == "-i":
+			confirm = true
+		case a == "-l" || a == "-r" || a == "-e":
+			if op != "" {
+				usage("only one operation permitted")
+				return
+			}
+			op = a
+		case a == "-" || !strings.HasPrefix(a, "-"):
+			file = a
+		default:
+			fmt.Fprintf(t.Stderr, "crontab: invalid option -- '%s'\n", strings.TrimLeft(a, "-"))
+			usage("unrecognized option")
+			return
+		}
+	}
+	if user != self {
+		if self != "root" {
+			fmt.Fprintln(t.Stderr, "must be privileged to use -u")
+			t.lastExitCode = 1
+			return
+		}
+		if _, ok := t.FS.lookupUser(user); !ok {
+			fmt.Fprintf(t.Stderr, "crontab: user `%s' unknown\n", user)
+			t.lastExitCode = 1
+			return
+		}
+	}
+	if op != "" && file != "" {
+		usage("no arguments permitted after this option")
+		return
+	}
+	spool := path.Join(crontabDir, user)
+	e, exists := t.FS.GetEntry(spool)
+	current := ""
+	if exists {
+		e.mu.RLock()
+		current = crontabBody(string(e.Content))
+		e.mu.RUnlock()
+	}
+
+	switch op {
+	case "-l":
+		if !exists {
+			fmt.Fprintf(t.Stderr, "no crontab for %s\n", user)
+			t.lastExitCode = 1
+			return
+		}
+		io.WriteString(out, current)
+	case "-r":
+		if !exists {
+			fmt.Fprintf(t.Stderr, "no crontab for %s\n", user)
+			t.lastExitCode = 1
+			return
+		}
+		if confirm {
+			fmt.Fprintf(out, "crontab: really delete %s's crontab? (y/n) ", user)
+			if reply, ok := t.readReply(in, out, true); !ok || !strings.HasPrefix(strings.ToLower(reply), "y") {
+				return
+			}
+		}
+		t.FS.Remove(spool)
+	case "-e":
+		t.crontabEdit(user, current, exists, in, out)
+	default:
+		if file == "" {
+			usage("file name must be specified for replace")
+			return
+		}
+		body := ""
+		if file == "-" {
+			if in != nil {
+				data, _ := io.ReadAll(in)
+				body = string(data)
+			}
+		} else {
+			f, ok := t.FS.GetEntry(t.FS.Abs(file))
+			if !ok || f.IsDir {
+				fmt.Fprintf(t.Stderr, "%s: No such file or directory\n", file)
+				t.lastExitCode = 1
+				return
+			}
+			f.mu.RLock()
+			body = string(f.Content)
+			f.mu.RUnlock()
+		}
+		if !t.crontabInstall(user, file, body) {
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// crontabEdit 用 $VISUAL/$EDITOR (默认 nano) 编辑临时副本，保存后校验并安装
+func (t *Terminal) crontabEdit(user, current string, exists bool, in io.Reader, out io.Writer) {
+	if !exists {
+		fmt.Fprintf(t.Stderr, "no crontab for %s - using an empty one\n", user)
+		current = crontabTemplate
+	}
+	dir := fmt.Sprintf("/tmp/crontab.%s", randomSuffix(6))
+	tmp := dir + "/crontab"
+	t.FS.Mkdir(dir)
+	t.FS.Write(tmp, []byte(current), 0600)
+	defer t.FS.Remove(dir)
+	defer t.FS.Remove(tmp)
+
+	editor := t.lookupVar("VISUAL")
+	if editor == "" {
+		editor = t.lookupVar("EDITOR")
+	}
+	for {
+		switch path.Base(editor) {
+		case "vi", "vim", "vim.basic", "vim.tiny":
+			t.cmdVi([]string{"vi", tmp}, out)
+		default:
+			t.cmdNano([]string{"nano", tmp}, out)
+		}
+		e, ok := t.FS.GetEntry(tmp)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "crontab: %s: No such file or directory\n", tmp)
+			t.lastExitCode = 1
+			return
+		}
+		e.mu.RLock()
+		body := string(e.Content)
+		e.mu.RUnlock()
+		if body == current {
+			fmt.Fprintln(t.Stderr, "No modification made")
+			t.lastExitCode = 0
+			return
+		}
+		if t.crontabInstall(user, tmp, body) {
+			fmt.Fprintln(t.Stderr, "crontab: installing new crontab")
+			t.lastExitCode = 0
+			return
+		}
+		fmt.Fprint(out, "Do you want to retry the same edit? (y/n) ")
+		if reply, ok := t.readReply(in, out, true); !ok || !strings.HasPrefix(strings.ToLower(reply), "y") {
+			fmt.Fprintf(t.Stderr, "crontab: edits left in %s\n", tmp)
+			t.lastExitCode = 1
+			return
+		}
+	}
+}
+
+// randomSuffix 生成 mkstemp 风格的随机后缀
+func randomSuffix(n int) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// ==========================================
+// at
+// ==========================================
+
+// atJob 作业文件名为 <队列><5 位十六进制作业号><8 位十六进制的执行分钟数>
+type atJob struct {
+	ID    int
+	Queue byte
+	When  time.Time
+	Owner string
+	Path  string
+}
+
+func (t *Terminal) atJobs() []atJob {
+	var jobs []atJob
+	files, _ := t.FS.ListDir(atjobsDir)
+	for _, f := range files {
+		if len(f.Name) != 14 || f.IsDir {
+			continue
+		}
+		id, err1 := strconv.ParseInt(f.Name[1:6], 16, 32)
+		mins, err2 := strconv.ParseInt(f.Name[6:], 16, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		jobs = append(jobs, atJob{ID: int(id), Queue: f.Name[0], When: time.Unix(mins*60, 0),
+			Owner: t.FS.uidName(f.UID), Path: path.Join(atjobsDir, f.Name)})
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// findAtJob 按作业号查找，普通用户只能看到自己的作业
+func (t *Terminal) findAtJob(arg string) (atJob, bool) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return atJob{}, false
+	}
+	for _, j := range t.atJobs() {
+		if j.ID == id && (j.Owner == t.userName() || t.userName() == "root") {
+			return j, true
+		}
+	}
+	return atJob{}, false
+}
+
+const atTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// parseAtTime 解析 at 的时间说明 (now/noon/midnight/teatime/HH:MM [am|pm] [today|tomorrow] [+ N 单位])，
+// 失败时返回最后看到的记号
+func parseAtTime(args []string, now time.Time) (time.Time, string, bool) {
+	var toks []string
+	for _, tok := range regexp.MustCompile(`\d+|[A-Za-z]+|\S`).FindAllString(strings.Join(args, " "), -1) {
+		toks = append(toks, strings.ToLower(tok))
+	}
+	if len(toks) == 0 {
+		return time.Time{}, "", false
+	}
+	i := 0
+	peek := func() string {
+		if i < len(toks) {
+			return toks[i]
+		}
+		return ""
+	}
+	fail := func() (time.Time, string, bool) {
+		return time.Time{}, toks[min(i, len(toks)-1)], false
+	}
+	now = now.Truncate(time.Minute)
+	at := now
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch tok := peek(); {
+	case tok == "now":
+		i++
+	case tok == "noon" || tok == "midnight" || tok == "teatime":
+		i++
+		at = today.Add(map[string]time.Duration{"noon": 12 * time.Hour, "midnight": 0, "teatime": 16 * time.Hour}[tok])
+	case tok != "" && tok[0] >= '0' && tok[0] <= '9':
+		i++
+		h, m := 0, 0
+		if n, _ := strconv.Atoi(tok); len(tok) > 2 {
+			h, m = n/100, n%100
+		} else {
+			h = n
+		}
+		if peek() == ":" {
+			i++
+			mm := peek()
+			n, err := strconv.Atoi(mm)
+			if err != nil || len(mm) != 2 {
+				return fail()
+			}
+			m = n
+			i++
+		}
+		switch peek() {
+		case "am", "pm":
+			if h < 1 || h > 12 {
+				return fail()
+			}
+			if h == 12 {
+				h = 0
+			}
+			if peek() == "pm" {
+				h += 12
+			}
+			i++
+		}
+		if h > 23 || m > 59 {
+			return fail()
+		}
+		at = today.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	case tok == "today" || tok == "tomorrow":
+	default:
+		i++
+		return fail()
+	}
+
+	switch peek() {
+	case "today":
+		i++
+	case "tomorrow":
+		i++
+		at = at.AddDate(0, 0, 1)
+	default:
+		if at.Before(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	}
+
+	if peek() == "+" || peek() == "next" {
+		n := 1
+		if toks[i] == "+" {
+			i++
+			v, err := strconv.Atoi(peek())
+			if err != nil {
+				return fail()
+			}
+			n = v
+		}
+		i++
+		switch strings.TrimSuffix(peek(), "s") {
+		case "min", "minute":
+			at = at.Add(time.Duration(n) * time.Minute)
+		case "hour":
+			at = at.Add(time.Duration(n) * time.Hour)
+		case "day":
+			at = at.AddDate(0, 0, n)
+		case "week":
+			at = at.AddDate(0, 0, 7*n)
+		case "month":
+			at = at.AddDate(0, n, 0)
+		case "year":
+			at = at.AddDate(n, 0, 0)
+		default:
+			return fail()
+		}
+		i++
+	}
+	if i < len(toks) {
+		return fail()
+	}
+	return at, "", true
+}
+
+func (t *Terminal) cmdAt(args []string, in io.Reader, out io.Writer) {
+	queue := byte('a')
+	file := ""
+	var spec []string
+	for i := 1; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-l":
+			t.cmdAtq(args, out)
+			return
+		case a == "-r" || a == "-d":
+			t.cmdAtrm(append([]string{"atrm"}, args[i+1:]...))
+			return
+		case a == "-c":
+			for _, id := range args[i+1:] {
+				j, ok := t.findAtJob(id)
+				if !ok {
+					fmt.Fprintf(t.Stderr, "Cannot find jobid %s\n", id)
+					t.lastExitCode = 1
+					continue
+				}
+				if e, ok := t.FS.GetEntry(j.Path); ok {
+					out.Write(e.Content)
+				}
+			}
+			return
+		case a == "-V":
+			fmt.Fprintln(t.Stderr, "at version 3.2.5")
+			return
+		case a == "-f" && i+1 < len(args):
+			i++
+			file = args[i]
+		case a == "-q" && i+1 < len(args):
+			i++
+			queue = args[i][0]
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+		default:
+			spec = append(spec, a)
+		}
+	}
+
+	when, last, ok := parseAtTime(spec, time.Now())
+	if !ok {
+		if last != "" {
+			fmt.Fprintf(t.Stderr, "syntax error. Last token seen: %s\n", last)
+		}
+		fmt.Fprintln(t.Stderr, "Garbled time")
+		t.lastExitCode = 1
+		return
+	}
+
+	var script string
+	switch {
+	case file != "":
+		e, ok := t.FS.GetEntry(t.FS.Abs(file))
+		if !ok || e.IsDir {
+			fmt.Fprintf(t.Stderr, "at: cannot open input file %s: No such file or directory\n", file)
+			t.lastExitCode = 1
+			return
+		}
+		script = string(e.Content)
+	case t.interactive() && isTTY(out):
+		var b strings.Builder
+		for {
+			io.WriteString(out, "at> ")
+			line, ok := t.readReply(in, out, true)
+			if !ok {
+				break
+			}
+			b.WriteString(line + "\n")
+		}
+		io.WriteString(out, "<EOT>\n")
+		script = b.String()
+	case in != nil:
+		data, _ := io.ReadAll(in)
+		script = string(data)
+	}
+
+	// .SEQ 记录上一个作业号 (十六进制)
+	seq := 0
+	if e, ok := t.FS.GetEntry(atjobsDir + "/.SEQ"); ok {
+		n, _ := strconv.ParseInt(strings.TrimSpace(string(e.Content)), 16, 32)
+		seq = int(n)
+	}
+	seq++
+	t.FS.Write(atjobsDir+"/.SEQ", []byte(fmt.Sprintf("%5x\n", seq)), 0600)
+
+	user := t.userName()
+	uid, gid := t.FS.owner(user)
+	t.mu.Lock()
+	env := make([]string, 0, len(t.Env))
+	for k, v := range t.Env {
+		switch k {
+		case "TERM", "DISPLAY", "_", "SHELLOPTS", "BASH_VERSINFO", "EUID", "GROUPS", "PPID", "UID":
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s; export %s\n", k, shellQuote(v), k))
+	}
+	t.mu.Unlock()
+	sort.Strings(env)
+	delim := fmt.Sprintf("marcinDELIMITER%08x", rand.Uint32())
+	job := fmt.Sprintf("#!/bin/sh\n# atrun uid=%d gid=%d\n# mail %8s 0\numask 22\n%scd %s || {\n\t echo 'Execution directory inaccessible' >&2\n\t exit 1\n}\n${SHELL:-/bin/sh} << '%s'\n%s\n%s\n",
+		uid, gid, user, strings.Join(env, ""), t.FS.Abs("."), delim, strings.TrimSuffix(script, "\n"), delim)
+	p := fmt.Sprintf("%s/%c%05x%08x", atjobsDir, queue, seq, when.Unix()/60)
+	t.FS.Write(p, []byte(job), 0700)
+	t.FS.Chown(p, uid, t.FS.gid("daemon"))
+
+	fmt.Fprintln(t.Stderr, "warning: commands will be executed using /bin/sh")
+	fmt.Fprintf(t.Stderr, "job %d at %s\n", seq, when.Format(atTimeFormat))
+}
+
+// shellQuote 按 at 写作业文件的方式转义变量值
+func shellQuote(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/_.-:,@%+=", r)) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (t *Terminal) cmdAtq(args []string, out io.Writer) {
+	for _, j := range t.atJobs() {
+		if j.Owner == t.userName() || t.userName() == "root" {
+			fmt.Fprintf(out, "%d\t%s %c %s\n", j.ID, j.When.Format(atTimeFormat), j.Queue, j.Owner)
+		}
+	}
+}
+
+func (t *Terminal) cmdAtrm(args []string) {
+	for _, id := range args[1:] {
+		j, ok := t.findAtJob(id)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "Cannot find jobid %s\n", id)
+			t.lastExitCode = 1
+			continue
+		}
+		t.FS.Remove(j.Path)
+	}
+}
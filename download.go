@@ -0,0 +1,731 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 下载子系统 (wget / curl)
+// ==========================================
+
+// FetchResponse 一次请求的结果，重定向不在此处跟随
+type FetchResponse struct {
+	StatusCode  int
+	Status      string // 例如 "200 OK"
+	ContentType string
+	Location    string
+	Body        []byte
+	Synthetic   bool // 内容为伪造，不写入隔离区
+}
+
+// FetchError 网络层失败，Op 为 "resolve"、"connect" 或 "timeout"
+type FetchError struct {
+	Op   string
+	Host string
+}
+
+func (e *FetchError) Error() string { return e.Op + " " + e.Host }
+
+// Fetcher 下载后端，部署时可替换为沙箱下载器
+type Fetcher interface {
+	Fetch(u *url.URL) (*FetchResponse, error)
+}
+
+// DownloadFetcher 当前使用的下载后端
+var DownloadFetcher Fetcher = &ArtifactFetcher{Dir: "artifacts", Fallback: SyntheticFetcher{}}
+
+// ArtifactFetcher 从本地目录提供样本 (Dir/<host>/<path> 或 Dir/<文件名>)，找不到时交给 Fallback
+type ArtifactFetcher struct {
+	Dir      string
+	Fallback Fetcher
+}
+
+func (f *ArtifactFetcher) Fetch(u *url.URL) (*FetchResponse, error) {
+	clean := path.Clean("/" + u.Path)
+	var rels []string
+	// 主机名可以通过会话自己的 /etc/hosts 映射成任意字符串，不能让它跳出 Dir
+	if host := u.Hostname(); host != "" && host != "." && host != ".." && !strings.ContainsAny(host, `/\`) {
+		rels = append(rels, filepath.Join(host, filepath.FromSlash(clean)))
+	}
+	rels = append(rels, path.Base(clean))
+	for _, rel := range rels {
+		if !filepath.IsLocal(rel) {
+			continue
+		}
+		p := filepath.Join(f.Dir, rel)
+		st, err := os.Stat(p)
+		if err != nil || !st.Mode().IsRegular() || st.Size() > MaxFileSize {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		return &FetchResponse{StatusCode: 200, Status: "200 OK", ContentType: guessContentType(clean), Body: data}, nil
+	}
+	if f.Fallback == nil {
+		return &FetchResponse{StatusCode: 404, Status: "404 Not Found", ContentType: "text/html", Body: notFoundPage(), Synthetic: true}, nil
+	}
+	return f.Fallback.Fetch(u)
+}
+
+// SyntheticFetcher 不联网，按 URL 确定性地伪造内容
+type SyntheticFetcher struct{}
+
+func (SyntheticFetcher) Fetch(u *url.URL) (*FetchResponse, error) {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		body := fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head><title>%s</title></head>\n<body>\n<h1>It works!</h1>\n</body>\n</html>\n", u.Hostname())
+		return &FetchResponse{StatusCode: 200, Status: "200 OK", ContentType: "text/html", Body: []byte(body), Synthetic: true}, nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(u.String()))
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+	ctype := guessContentType(p)
+
+	var body []byte
+	switch {
+	case strings.HasPrefix(ctype, "text/x-sh"):
+		body = []byte("#!/bin/sh\n")
+	case strings.HasPrefix(ctype, "text/"):
+		body = []byte("\n")
+	default:
+		// 以 ELF 头开头的随机数据，大小在 16K 到 1.5M 之间
+		body = make([]byte, 16<<10+rng.Intn(1500<<10))
+		rng.Read(body)
+		copy(body, "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00")
+	}
+	return &FetchResponse{StatusCode: 200, Status: "200 OK", ContentType: ctype, Body: body, Synthetic: true}, nil
+}
+
+func guessContentType(p string) string {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".sh", ".bash":
+		return "text/x-sh"
+	case ".py":
+		return "text/x-python"
+	case ".pl":
+		return "text/x-perl"
+	case ".txt", ".conf":
+		return "text/plain"
+	case ".html", ".htm", ".php":
+		return "text/html"
+	case ".gz", ".tgz":
+		return "application/x-gzip"
+	case ".tar":
+		return "application/x-tar"
+	case ".zip":
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+func notFoundPage() []byte {
+	return []byte("<!DOCTYPE HTML PUBLIC \"-//IETF//DTD HTML 2.0//EN\">\n<html><head>\n<title>404 Not Found</title>\n</head><body>\n<h1>Not Found</h1>\n<p>The requested URL was not found on this server.</p>\n</body></html>\n")
+}
+
+// parseDownloadURL 补全缺省的 scheme 并校验主机名
+func parseDownloadURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Hostname() == "" {
+		return nil, errors.New("no host")
+	}
+	return u, nil
+}
+
+func defaultPort(u *url.URL) int {
+	if p, err := strconv.Atoi(u.Port()); err == nil {
+		return p
+	}
+	switch u.Scheme {
+	case "https":
+		return 443
+	case "ftp":
+		return 21
+	}
+	return 80
+}
+
+// resolveHost 模拟 DNS：IP 字面量和 /etc/hosts 优先，其余带点的域名得到确定的公网地址
+func (t *Terminal) resolveHost(host string) (string, bool) {
+	if ip := net.ParseIP(host); ip != nil {
+		return host, true
+	}
+	if e, ok := t.FS.GetEntry("/etc/hosts"); ok {
+		e.mu.RLock()
+		content := string(e.Content)
+		e.mu.RUnlock()
+		for _, line := range strings.Split(content, "\n") {
+			f := strings.Fields(line)
+			for _, name := range f[min(1, len(f)):] {
+				if strings.EqualFold(name, host) && !strings.HasPrefix(f[0], "#") {
+					return f[0], true
+				}
+			}
+		}
+	}
+	if !strings.Contains(strings.Trim(host, "."), ".") {
+		return "", false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(host)))
+	s := h.Sum32()
+	first := []int{23, 45, 64, 91, 103, 104, 139, 141, 146, 157, 162, 185, 193, 198, 203, 209}[s&15]
+	return fmt.Sprintf("%d.%d.%d.%d", first, s>>8&0xff, s>>16&0xff, 1+int(s>>24)%254), true
+}
+
+// download 解析主机并取回 URL (含重定向)，每一跳都回调 hop 以便输出记录
+func (t *Terminal) download(u *url.URL, follow bool, maxRedirects int, hop func(u *url.URL, ip string, resp *FetchResponse, err error)) (*url.URL, *FetchResponse, error) {
+	for i := 0; ; i++ {
+		ip, ok := t.resolveHost(u.Hostname())
+		if !ok {
+			err := &FetchError{Op: "resolve", Host: u.Hostname()}
+			hop(u, "", nil, err)
+			return u, nil, err
+		}
+		resp, err := DownloadFetcher.Fetch(u)
+		hop(u, ip, resp, err)
+		if err != nil {
+			return u, nil, err
+		}
+		log.Printf("[Download] %s: %s -> %s (%d bytes)", t.Remote, u, resp.Status, len(resp.Body))
+		if !follow || resp.StatusCode < 300 || resp.StatusCode >= 400 || resp.Location == "" {
+			return u, resp, nil
+		}
+		if i >= maxRedirects {
+			return u, resp, errors.New("too many redirects")
+		}
+		next, err := u.Parse(resp.Location)
+		if err != nil {
+			return u, resp, nil
+		}
+		u = next
+	}
+}
+
+// saveDownload 写入会话文件系统，真实内容同时写入隔离区
+func (t *Terminal) saveDownload(tool string, u *url.URL, resp *FetchResponse, dst string) error {
+	if parent, ok := t.FS.GetEntry(path.Dir(dst)); !ok || !parent.IsDir {
+		return os.ErrNotExist
+	}
+	if err := t.FS.Write(dst, resp.Body, 0644); err != nil {
+		return err
+	}
+	log.Printf("[Download] %s: %s saved %s as %s", t.Remote, tool, u, dst)
+	if !resp.Synthetic {
+		CapturePayload(tool, t.Remote, u.String(), resp.Body)
+	}
+	return nil
+}
+
+// transferPace 伪造传输速度 (字节/秒) 和耗时，耗时上限 2 秒
+func transferPace(size int) (float64, time.Duration) {
+	speed := float64(2<<20 + rand.Intn(20<<20))
+	d := time.Duration(float64(size) / speed * float64(time.Second))
+	if d > 2*time.Second {
+		d = 2 * time.Second
+		speed = float64(size) / d.Seconds()
+	}
+	return speed, d
+}
+
+// humanSize 与 wget 一致的 1.2K / 3.4M 风格
+func humanSize(n float64) string {
+	units := []string{"", "K", "M", "G"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return strconv.Itoa(int(n))
+	}
+	if n < 10 {
+		return fmt.Sprintf("%.2f%s", n, units[i])
+	}
+	if n < 100 {
+		return fmt.Sprintf("%.1f%s", n, units[i])
+	}
+	return fmt.Sprintf("%.0f%s", n, units[i])
+}
+
+// wgetRate 下载结束时的平均速率，如 "10.8 MB"
+func wgetRate(speed float64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	i := 0
+	for speed >= 1024 && i < len(units)-1 {
+		speed /= 1024
+		i++
+	}
+	if speed < 10 {
+		return fmt.Sprintf("%.2f %s", speed, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", speed, units[i])
+}
+
+// --- wget ---
+
+// shortOpts 解析合并的短选项 (如 -qO- 或 -fsSLo x)，withArg 中的选项带参数
+func shortOpts(args []string, i int, withArg string, fn func(opt byte, val string)) int {
+	a := args[i]
+	for j := 1; j < len(a); j++ {
+		if strings.IndexByte(withArg, a[j]) >= 0 {
+			val := a[j+1:]
+			if val == "" && i+1 < len(args) {
+				i++
+				val = args[i]
+			}
+			fn(a[j], val)
+			return i
+		}
+		fn(a[j], "")
+	}
+	return i
+}
+
+func (t *Terminal) cmdWget(args []string, out io.Writer) {
+	stderr := t.Stderr
+	var urls []string
+	quiet, verbose := false, true
+	output, logFile, prefix := "", "", ""
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--quiet":
+			quiet = true
+		case a == "--no-verbose":
+			verbose = false
+		case strings.HasPrefix(a, "--output-document="):
+			output = strings.TrimPrefix(a, "--output-document=")
+		case strings.HasPrefix(a, "--output-file="):
+			logFile = strings.TrimPrefix(a, "--output-file=")
+		case strings.HasPrefix(a, "--directory-prefix="):
+			prefix = strings.TrimPrefix(a, "--directory-prefix=")
+		case strings.HasPrefix(a, "--"):
+			// --no-check-certificate、--user-agent=... 等对模拟无影响
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			i = shortOpts(args, i, "OoPtTUeaw", func(opt byte, val string) {
+				switch opt {
+				case 'q':
+					quiet = true
+				case 'O':
+					output = val
+				case 'o':
+					logFile = val
+				case 'P':
+					prefix = val
+				}
+			})
+			if a == "-nv" {
+				verbose, quiet = false, false
+			}
+		default:
+			urls = append(urls, a)
+		}
+	}
+
+	if len(urls) == 0 {
+		fmt.Fprintln(stderr, "wget: 未指定 URL")
+		fmt.Fprintln(stderr, "用法： wget [选项]... [URL]...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "请尝试使用“wget --help”查看更多的选项。")
+		t.lastExitCode = 1
+		return
+	}
+
+	var logBuf *bytes.Buffer
+	if logFile != "" {
+		logBuf = &bytes.Buffer{}
+		stderr = logBuf
+		defer func() { t.FS.Write(t.FS.Abs(logFile), logBuf.Bytes(), 0644) }()
+	}
+	var logw io.Writer = stderr
+	if quiet {
+		logw = io.Discard
+	}
+	tty := isTTY(logw)
+
+	for _, raw := range urls {
+		u, err := parseDownloadURL(raw)
+		if err != nil {
+			fmt.Fprintf(logw, "%s: 无效的 URL %s: 主机名无效\n", raw, raw)
+			t.lastExitCode = 1
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp" {
+			fmt.Fprintf(logw, "%s: 不支持的协议类型 “%s”。\n", raw, u.Scheme)
+			t.lastExitCode = 1
+			continue
+		}
+
+		final, resp, err := t.download(u, true, 20, func(u *url.URL, ip string, resp *FetchResponse, err error) {
+			if !verbose {
+				return
+			}
+			fmt.Fprintf(logw, "--%s--  %s\n", time.Now().Format("2006-01-02 15:04:05"), u)
+			host := u.Hostname()
+			port := defaultPort(u)
+			if ip == "" {
+				fmt.Fprintf(logw, "正在解析主机 %s (%s)... 失败：未知的名称或服务。\n", host, host)
+				fmt.Fprintf(logw, "wget: 无法解析主机地址 “%s”\n", host)
+				return
+			}
+			if net.ParseIP(host) != nil {
+				fmt.Fprintf(logw, "正在连接 %s:%d... ", host, port)
+			} else {
+				fmt.Fprintf(logw, "正在解析主机 %s (%s)... %s\n", host, host, ip)
+				fmt.Fprintf(logw, "正在连接 %s (%s)|%s|:%d... ", host, host, ip, port)
+			}
+			if err != nil {
+				fmt.Fprintln(logw, "失败：拒绝连接。")
+				return
+			}
+			fmt.Fprintln(logw, "已连接。")
+			fmt.Fprintf(logw, "已发出 HTTP 请求，正在等待回应... %s\n", resp.Status)
+			if resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Location != "" {
+				fmt.Fprintf(logw, "位置：%s [跟随至新的 URL]\n", resp.Location)
+			}
+		})
+		if err != nil {
+			var fe *FetchError
+			if errors.As(err, &fe) {
+				t.lastExitCode = 4
+			} else {
+				fmt.Fprintln(logw, "已超过 20 次重定向。")
+				t.lastExitCode = 8
+			}
+			continue
+		}
+		if resp.StatusCode >= 400 {
+			if verbose {
+				fmt.Fprintf(logw, "%s 错误 %s。\n\n", time.Now().Format("2006-01-02 15:04:05"), strings.Replace(resp.Status, " ", "：", 1))
+			} else {
+				fmt.Fprintf(logw, "%s URL:%s 错误 %s。\n", time.Now().Format("2006-01-02 15:04:05"), final, strings.Replace(resp.Status, " ", "：", 1))
+			}
+			t.lastExitCode = 8
+			continue
+		}
+
+		// 确定保存位置
+		name := output
+		toStdout := output == "-"
+		if name == "" {
+			name = path.Base(final.Path)
+			if name == "/" || name == "." || name == "" {
+				name = "index.html"
+			}
+			if prefix != "" {
+				name = path.Join(prefix, name)
+			}
+			// 不覆盖已有文件：依次尝试 .1 .2 ...
+			if _, exists := t.FS.GetEntry(t.FS.Abs(name)); exists {
+				for n := 1; ; n++ {
+					cand := fmt.Sprintf("%s.%d", name, n)
+					if _, exists := t.FS.GetEntry(t.FS.Abs(cand)); !exists {
+						name = cand
+						break
+					}
+				}
+			}
+		}
+
+		size := len(resp.Body)
+		if verbose {
+			if size >= 1024 {
+				fmt.Fprintf(logw, "长度： %d (%s) [%s]\n", size, humanSize(float64(size)), resp.ContentType)
+			} else {
+				fmt.Fprintf(logw, "长度： %d [%s]\n", size, resp.ContentType)
+			}
+			if toStdout {
+				fmt.Fprintln(logw, "正在保存至: “STDOUT”")
+			} else {
+				fmt.Fprintf(logw, "正在保存至: “%s”\n", name)
+			}
+			fmt.Fprintln(logw)
+		}
+
+		if !toStdout {
+			if err := t.saveDownload("wget", final, resp, t.FS.Abs(name)); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					fmt.Fprintf(logw, "%s: 没有那个文件或目录\n", name)
+				} else {
+					fmt.Fprintf(logw, "wget: 无法写入 “%s” (%v)。\n", name, err)
+				}
+				t.lastExitCode = 3
+				continue
+			}
+		} else if !resp.Synthetic {
+			CapturePayload("wget", t.Remote, final.String(), resp.Body)
+		}
+
+		speed, elapsed := transferPace(size)
+		if verbose {
+			label := name
+			if toStdout {
+				label = "-"
+			}
+			t.wgetProgress(logw, label, size, speed, elapsed, tty)
+			fmt.Fprintf(logw, "%s (%s/s) - 已保存 “%s” [%d/%d])\n\n", time.Now().Format("2006-01-02 15:04:05"), wgetRate(speed), label, size, size)
+		} else if !quiet {
+			fmt.Fprintf(logw, "%s URL:%s [%d/%d] -> \"%s\" [1]\n", time.Now().Format("2006-01-02 15:04:05"), final, size, size, name)
+		}
+		if toStdout {
+			out.Write(resp.Body)
+		}
+	}
+}
+
+// wgetProgress 终端上绘制进度条，非终端时使用点状进度 (与 wget 行为一致)
+func (t *Terminal) wgetProgress(w io.Writer, name string, size int, speed float64, elapsed time.Duration, tty bool) {
+	if !tty {
+		const perLine = 50 << 10
+		for off := 0; off < size || off == 0; off += perLine {
+			n := min(perLine, size-off)
+			var dots strings.Builder
+			for k := 0; k < (n+1023)>>10; k++ {
+				if k > 0 && k%10 == 0 {
+					dots.WriteByte(' ')
+				}
+				dots.WriteByte('.')
+			}
+			pct := 100
+			if size > 0 {
+				pct = (off + n) * 100 / size
+			}
+			fmt.Fprintf(w, "%6dK %-54s %3d%% %s\n", off>>10, dots.String(), pct, humanSize(speed))
+			if size == 0 {
+				break
+			}
+		}
+		fmt.Fprintln(w)
+		return
+	}
+
+	if len([]rune(name)) > 19 {
+		name = string([]rune(name)[:19])
+	}
+	barWidth := min(max(t.Width-60, 10), 60)
+	frames := 8
+	for f := 1; f <= frames; f++ {
+		done := size * f / frames
+		filled := barWidth * f / frames
+		bar := strings.Repeat("=", max(filled-1, 0)) + ">" + strings.Repeat(" ", barWidth-filled)
+		tail := fmt.Sprintf("%5sB/s    ", humanSize(speed))
+		if f == frames {
+			tail = fmt.Sprintf("%5sB/s    用时 %.1fs", humanSize(speed), elapsed.Seconds())
+		}
+		fmt.Fprintf(w, "\r%-20s%3d%%[%s] %7s  %s", name, f*100/frames, bar, humanSize(float64(done)), tail)
+		if f < frames {
+			time.Sleep(elapsed / time.Duration(frames))
+		}
+	}
+	fmt.Fprint(w, "\n\n")
+}
+
+// --- curl ---
+
+func (t *Terminal) cmdCurl(args []string, out io.Writer) {
+	stderr := t.Stderr
+	var urls []string
+	silent, showErr, follow, fail, head, remoteName := false, false, false, false, false, false
+	output := ""
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--silent":
+			silent = true
+		case a == "--show-error":
+			showErr = true
+		case a == "--location":
+			follow = true
+		case a == "--fail":
+			fail = true
+		case a == "--head":
+			head = true
+		case a == "--remote-name":
+			remoteName = true
+		case a == "--output" && i+1 < len(args):
+			i++
+			output = args[i]
+		case a == "--user-agent", a == "--header", a == "--request", a == "--data", a == "--user",
+			a == "--connect-timeout", a == "--max-time", a == "--proxy", a == "--retry":
+			i++
+		case strings.HasPrefix(a, "--"):
+			// --insecure、--compressed 等对模拟无影响
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			i = shortOpts(args, i, "oAHXduxmeEwTrbcz", func(opt byte, val string) {
+				switch opt {
+				case 's':
+					silent = true
+				case 'S':
+					showErr = true
+				case 'L':
+					follow = true
+				case 'f':
+					fail = true
+				case 'I':
+					head = true
+				case 'O':
+					remoteName = true
+				case 'o':
+					output = val
+				}
+			})
+		default:
+			urls = append(urls, a)
+		}
+	}
+
+	errf := func(code int, format string, a ...interface{}) {
+		if !silent || showErr {
+			fmt.Fprintf(stderr, "curl: (%d) "+format+"\n", append([]interface{}{code}, a...)...)
+		}
+		t.lastExitCode = code
+	}
+
+	if len(urls) == 0 {
+		fmt.Fprintln(stderr, "curl: try 'curl --help' or 'curl --manual' for more information")
+		t.lastExitCode = 2
+		return
+	}
+
+	for _, raw := range urls {
+		u, err := parseDownloadURL(raw)
+		if err != nil {
+			errf(3, "URL using bad/illegal format or missing URL")
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp" {
+			errf(1, "Protocol \"%s\" not supported or disabled in libcurl", u.Scheme)
+			continue
+		}
+
+		final, resp, err := t.download(u, follow, 50, func(*url.URL, string, *FetchResponse, error) {})
+		if err != nil {
+			var fe *FetchError
+			switch {
+			case errors.As(err, &fe) && fe.Op == "resolve":
+				errf(6, "Could not resolve host: %s", fe.Host)
+			case errors.As(err, &fe) && fe.Op == "timeout":
+				errf(28, "Failed to connect to %s port %d after 130000 ms: Connection timed out", fe.Host, defaultPort(u))
+			case errors.As(err, &fe):
+				errf(7, "Failed to connect to %s port %d after 0 ms: Connection refused", fe.Host, defaultPort(u))
+			default:
+				errf(47, "Maximum (50) redirects followed")
+			}
+			continue
+		}
+
+		if head {
+			fmt.Fprintf(out, "HTTP/1.1 %s\r\nDate: %s\r\nServer: Apache\r\nContent-Length: %d\r\nContent-Type: %s\r\n",
+				resp.Status, time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"), len(resp.Body), resp.ContentType)
+			if resp.Location != "" {
+				fmt.Fprintf(out, "Location: %s\r\n", resp.Location)
+			}
+			fmt.Fprint(out, "\r\n")
+			continue
+		}
+		if fail && resp.StatusCode >= 400 {
+			errf(22, "The requested URL returned error: %d", resp.StatusCode)
+			continue
+		}
+
+		dst := output
+		if remoteName {
+			dst = path.Base(final.Path)
+			if dst == "/" || dst == "." || dst == "" {
+				errf(23, "Failed writing received data to disk/application")
+				continue
+			}
+		}
+		if dst == "" || dst == "-" {
+			// 与 curl 7.81 一样拒绝把二进制输出到终端
+			if isTTY(out) && bytes.IndexByte(resp.Body, 0) >= 0 {
+				fmt.Fprintln(stderr, "Warning: Binary output can mess up your terminal. Use \"--output -\" to tell ")
+				fmt.Fprintln(stderr, "Warning: curl to output it to your terminal anyway, or consider \"--output ")
+				fmt.Fprintln(stderr, "Warning: <FILE>\" to save to a file.")
+				t.lastExitCode = 23
+				continue
+			}
+			if !silent && !isTTY(out) {
+				t.curlProgress(stderr, len(resp.Body))
+			}
+			if !resp.Synthetic {
+				CapturePayload("curl", t.Remote, final.String(), resp.Body)
+			}
+			out.Write(resp.Body)
+			continue
+		}
+
+		if !silent {
+			t.curlProgress(stderr, len(resp.Body))
+		}
+		if err := t.saveDownload("curl", final, resp, t.FS.Abs(dst)); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(stderr, "Warning: Failed to open the file %s: No such file or directory\n", dst)
+			}
+			errf(23, "Failure writing output to destination")
+		}
+	}
+}
+
+// curlProgress 输出 curl 的传输统计表
+func (t *Terminal) curlProgress(w io.Writer, size int) {
+	speed, elapsed := transferPace(size)
+	fmt.Fprintln(w, "  % Total    % Received % Xferd  Average Speed   Time    Time     Time  Current")
+	fmt.Fprintln(w, "                                 Dload  Upload   Total   Spent    Left  Speed")
+	line := func(done int, spent time.Duration) string {
+		pct := 100
+		if size > 0 {
+			pct = done * 100 / size
+		}
+		return fmt.Sprintf("%3d %5s %3d %5s    0     0  %5s      0 %s %s --:--:-- %5s",
+			pct, curlSize(size), pct, curlSize(done), curlSize(int(speed)), curlTime(elapsed), curlTime(spent), curlSize(int(speed)))
+	}
+	if isTTY(w) {
+		for f := 1; f < 4; f++ {
+			fmt.Fprint(w, "\r"+line(size*f/4, elapsed*time.Duration(f)/4))
+			time.Sleep(elapsed / 4)
+		}
+	}
+	fmt.Fprint(w, "\r"+line(size, elapsed)+"\n")
+}
+
+func curlSize(n int) string {
+	switch {
+	case n < 100000:
+		return strconv.Itoa(n)
+	case n < 10000<<10:
+		return strconv.Itoa(n>>10) + "k"
+	case n < 100<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	}
+	return strconv.Itoa(n>>20) + "M"
+}
+
+func curlTime(d time.Duration) string {
+	s := int(d.Seconds() + 0.999)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ==========================================
+// 行编辑器 (emacs 模式)
+// 按键解码 (多字节转义序列、Alt 组合键、括号粘贴)、kill ring、
+// Ctrl-R 反向搜索，以及按显示宽度计算的多行重绘
+// ==========================================
+
+type editKey int
+
+const (
+	keyRune editKey = iota
+	keyAlt          // ESC 前缀 + 字符 (Alt-x)
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft  // Ctrl/Alt + ←
+	keyWordRight // Ctrl/Alt + →
+	keyPasteStart
+	keyPasteEnd
+	keyIgnore // 不支持的序列 (Insert、PgUp 等)
+)
+
+type keyEvent struct {
+	code editKey
+	r    rune
+}
+
+// keyDecoder 把逐个到达的 rune 组装成按键
+type keyDecoder struct {
+	state  int // 0: 普通, 1: ESC, 2: CSI (ESC [), 3: SS3 (ESC O)
+	params []rune
+}
+
+func (d *keyDecoder) feed(r rune) (keyEvent, bool) {
+	switch d.state {
+	case 1:
+		switch r {
+		case '[':
+			d.state, d.params = 2, d.params[:0]
+			return keyEvent{}, false
+		case 'O':
+			d.state = 3
+			return keyEvent{}, false
+		case 27:
+			return keyEvent{}, false
+		}
+		d.state = 0
+		return keyEvent{keyAlt, r}, true
+	case 2:
+		// 参数字节 0x30-0x3F，结束字节 0x40-0x7E
+		if r >= 0x30 && r <= 0x3F {
+			d.params = append(d.params, r)
+			return keyEvent{}, false
+		}
+		d.state = 0
+		return d.csi(string(d.params), r), true
+	case 3:
+		d.state = 0
+		return d.csi("", r), true
+	}
+	if r == 27 {
+		d.state = 1
+		return keyEvent{}, false
+	}
+	return keyEvent{keyRune, r}, true
+}
+
+func (d *keyDecoder) csi(params string, final rune) keyEvent {
+	// 带修饰键的方向键：1;5C (Ctrl)、1;3C (Alt)
+	mod := ""
+	if i := strings.IndexByte(params, ';'); i >= 0 {
+		params, mod = params[:i], params[i+1:]
+	}
+	word := mod == "3" || mod == "5"
+	switch final {
+	case 'A':
+		return keyEvent{code: keyUp}
+	case 'B':
+		return keyEvent{code: keyDown}
+	case 'C':
+		if word {
+			return keyEvent{code: keyWordRight}
+		}
+		return keyEvent{code: keyRight}
+	case 'D':
+		if word {
+			return keyEvent{code: keyWordLeft}
+		}
+		return keyEvent{code: keyLeft}
+	case 'H':
+		return keyEvent{code: keyHome}
+	case 'F':
+		return keyEvent{code: keyEnd}
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyEvent{code: keyHome}
+		case "4", "8":
+			return keyEvent{code: keyEnd}
+		case "3":
+			return keyEvent{code: keyDelete}
+		case "200":
+			return keyEvent{code: keyPasteStart}
+		case "201":
+			return keyEvent{code: keyPasteEnd}
+		}
+	}
+	return keyEvent{code: keyIgnore}
+}
+
+// ==========================================
+// 显示宽度
+// ==========================================
+
+// runeWidth 返回字符在终端中占用的列数：CJK 和全角字符为 2，组合字符为 0
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || r < 32 || r == 0x7f:
+		return 0
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == 0x200B || r == 0x200D:
+		return 0
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F,
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+// visibleWidth 返回去掉 ANSI 转义序列后的显示宽度
+func visibleWidth(s string) int {
+	w := 0
+	inEsc := false
+	for _, r := range s {
+		switch {
+		case inEsc:
+			if r >= 0x40 && r <= 0x7E && r != '[' {
+				inEsc = false
+			}
+		case r == 27:
+			inEsc = true
+		default:
+			w += runeWidth(r)
+		}
+	}
+	return w
+}
+
+// ==========================================
+// 重绘
+// ==========================================
+
+// advance 计算在 (row, col) 输出 r 之后的光标位置；col == width 表示处于待换行状态
+func advance(row, col int, r rune, width int) (int, int) {
+	if r == '\n' {
+		return row + 1, 0
+	}
+	w := runeWidth(r)
+	if col+w > width {
+		row, col = row+1, 0
+	}
+	return row, col + w
+}
+
+func (t *Terminal) editWidth() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Width <= 0 {
+		return 80
+	}
+	return t.Width
+}
+
+// promptText 返回当前应显示的提示符 (反向搜索时为搜索提示)
+func (t *Terminal) promptText() string {
+	if t.search != nil {
+		failed := ""
+		if t.search.failed {
+			failed = "failed "
+		}
+		return fmt.Sprintf("(%sreverse-i-search)`%s': ", failed, string(t.search.query))
+	}
+	return t.promptString()
+}
+
+// promptLastLine 返回提示符的最后一行，编辑区从这一行开始
+func (t *Terminal) promptLastLine() string {
+	p := t.promptText()
+	if i := strings.LastIndex(p, "\n"); i >= 0 {
+		p = p[i+1:]
+	}
+	return p
+}
+
+// refreshLine 重绘提示符和整个编辑行，正确处理折行、换行符和宽字符
+func (t *Terminal) refreshLine() {
+	width := t.editWidth()
+	prompt := t.promptLastLine()
+
+	var b strings.Builder
+	b.WriteString("\r")
+	if t.editRow > 0 {
+		fmt.Fprintf(&b, "\033[%dA", t.editRow)
+	}
+	b.WriteString("\033[J")
+	b.WriteString(prompt)
+
+	row, col := 0, visibleWidth(prompt)
+	for col > width {
+		row, col = row+1, col-width
+	}
+	curRow, curCol := row, col
+	for i, r := range t.buffer {
+		if i == t.cursor {
+			curRow, curCol = row, col
+		}
+		row, col = advance(row, col, r, width)
+		if r == '\n' {
+			b.WriteString("\r\n")
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	if t.cursor >= len(t.buffer) {
+		curRow, curCol = row, col
+	}
+	// 恰好写满一行时终端停在行尾，主动换行让光标位置确定
+	if col >= width {
+		b.WriteString("\r\n")
+		row, col = row+1, 0
+	}
+	if curCol >= width {
+		curRow, curCol = curRow+1, 0
+	}
+
+	if row > curRow {
+		fmt.Fprintf(&b, "\033[%dA", row-curRow)
+	}
+	b.WriteString("\r")
+	if curCol > 0 {
+		fmt.Fprintf(&b, "\033[%dC", curCol)
+	}
+	t.editRow = curRow
+	t.RW.Write([]byte(b.String()))
+}
+
+// insert 在光标处插入文本；在行尾追加普通字符时只输出新字符
+func (t *Terminal) insert(rs []rune) {
+	atEnd := t.cursor == len(t.buffer) && t.search == nil
+	t.buffer = append(t.buffer[:t.cursor], append(append([]rune(nil), rs...), t.buffer[t.cursor:]...)...)
+	t.cursor += len(rs)
+	if !atEnd || strings.ContainsRune(string(rs), '\n') || strings.ContainsRune(string(t.buffer), '\n') {
+		t.refreshLine()
+		return
+	}
+
+	width := t.editWidth()
+	row, col := 0, visibleWidth(t.promptLastLine())
+	for col > width {
+		row, col = row+1, col-width
+	}
+	for _, r := range t.buffer {
+		row, col = advance(row, col, r, width)
+	}
+	out := string(rs)
+	if col >= width {
+		out += "\r\n"
+		row++
+	}
+	t.editRow = row
+	t.RW.Write([]byte(out))
+}
+
+// ==========================================
+// 编辑操作
+// ==========================================
+
+const killRingMax = 10
+
+// kill 删除 [from, to) 并放入 kill ring，连续的 kill 会合并
+func (t *Terminal) kill(from, to int, prepend bool) {
+	if from >= to {
+		return
+	}
+	text := string(t.buffer[from:to])
+	if t.lastAction == "kill" && len(t.killRing) > 0 {
+		top := len(t.killRing) - 1
+		if prepend {
+			t.killRing[top] = text + t.killRing[top]
+		} else {
+			t.killRing[top] += text
+		}
+	} else {
+		t.killRing = append(t.killRing, text)
+		if len(t.killRing) > killRingMax {
+			t.killRing = t.killRing[1:]
+		}
+	}
+	t.buffer = append(t.buffer[:from], t.buffer[to:]...)
+	t.cursor = from
+	t.action = "kill"
+	t.refreshLine()
+}
+
+func (t *Terminal) yank() {
+	if len(t.killRing) == 0 {
+		return
+	}
+	t.yankIdx = len(t.killRing) - 1
+	text := []rune(t.killRing[t.yankIdx])
+	t.yankLen = len(text)
+	t.insert(text)
+	t.action = "yank"
+}
+
+// yankPop 把刚粘贴的文本替换为 kill ring 中更早的一项 (Alt-y)
+func (t *Terminal) yankPop() {
+	if t.lastAction != "yank" || len(t.killRing) < 2 {
+		return
+	}
+	start := t.cursor - t.yankLen
+	t.buffer = append(t.buffer[:start], t.buffer[t.cursor:]...)
+	t.cursor = start
+	t.yankIdx = (t.yankIdx - 1 + len(t.killRing)) % len(t.killRing)
+	text := []rune(t.killRing[t.yankIdx])
+	t.yankLen = len(text)
+	t.buffer = append(t.buffer[:start], append(text, t.buffer[start:]...)...)
+	t.cursor = start + len(text)
+	t.action = "yank"
+	t.refreshLine()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordLeft 返回光标左边一个单词的起点 (字母数字构成单词)
+func (t *Terminal) wordLeft() int {
+	i := t.cursor
+	for i > 0 && !isWordRune(t.buffer[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(t.buffer[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordRight 返回光标右边一个单词的终点
+func (t *Terminal) wordRight() int {
+	i := t.cursor
+	for i < len(t.buffer) && !isWordRune(t.buffer[i]) {
+		i++
+	}
+	for i < len(t.buffer) && isWordRune(t.buffer[i]) {
+		i++
+	}
+	return i
+}
+
+// moveCursor 移动光标并重绘
+func (t *Terminal) moveCursor(pos int) {
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(t.buffer) {
+		pos = len(t.buffer)
+	}
+	if pos != t.cursor {
+		t.cursor = pos
+		t.refreshLine()
+	}
+}
+
+// historyMove 在历史记录中上下移动，dir 为 -1 (更早) 或 1 (更新)
+func (t *Terminal) historyMove(dir int) {
+	if t.histPos < 0 || t.histPos > len(t.History) {
+		t.histPos = len(t.History)
+	}
+	next := t.histPos + dir
+	if next < 0 || next > len(t.History) {
+		return
+	}
+	if t.histPos == len(t.History) {
+		t.histSaved = append([]rune(nil), t.buffer...)
+	}
+	t.histPos = next
+	if next == len(t.History) {
+		t.buffer = append([]rune(nil), t.histSaved...)
+	} else {
+		t.buffer = []rune(t.History[next])
+	}
+	t.cursor = len(t.buffer)
+	t.refreshLine()
+}
+
+// ==========================================
+// 反向搜索 (Ctrl-R)
+// ==========================================
+
+type historySearch struct {
+	query  []rune
+	pos    int    // 当前匹配的历史下标
+	saved  []rune // 进入搜索前的编辑行
+	failed bool
+}
+
+// searchFrom 从 start 往前搜索包含 query 的历史记录
+func (t *Terminal) searchFrom(start int) {
+	s := t.search
+	q := string(s.query)
+	for i := start; i >= 0; i-- {
+		if i < len(t.History) {
+			if idx := strings.Index(t.History[i], q); idx >= 0 {
+				s.pos, s.failed = i, false
+				t.buffer = []rune(t.History[i])
+				t.cursor = len([]rune(t.History[i][:idx]))
+				return
+			}
+		}
+	}
+	s.failed = true
+}
+
+// searchKey 处理反向搜索模式下的按键，返回 false 表示退出搜索后需要继续按普通按键处理
+func (t *Terminal) searchKey(ev keyEvent) bool {
+	s := t.search
+	if ev.code == keyRune {
+		switch ev.r {
+		case 18: // Ctrl-R：继续向前搜索
+			if len(s.query) == 0 && len(t.History) > 0 && s.pos == len(t.History) {
+				// 空查询时重复上一次的搜索词
+				s.query = []rune(t.lastSearch)
+			}
+			start := s.pos - 1
+			if s.pos >= len(t.History) {
+				start = len(t.History) - 1
+			}
+			if len(s.query) > 0 {
+				t.searchFrom(start)
+			}
+			t.refreshLine()
+			return true
+		case 7, 3: // Ctrl-G / Ctrl-C：放弃搜索，恢复原来的行
+			t.buffer = s.saved
+			t.cursor = len(t.buffer)
+			t.search = nil
+			t.refreshLine()
+			return true
+		case 127, 8:
+			if len(s.query) > 0 {
+				s.query = s.query[:len(s.query)-1]
+				t.searchFrom(len(t.History) - 1)
+			}
+			t.refreshLine()
+			return true
+		default:
+			if unicode.IsPrint(ev.r) {
+				s.query = append(s.query, ev.r)
+				start := s.pos
+				if start >= len(t.History) {
+					start = len(t.History) - 1
+				}
+				t.searchFrom(start)
+				t.refreshLine()
+				return true
+			}
+		}
+	}
+	// 其他按键：接受当前匹配，退出搜索，然后照常处理该按键
+	t.lastSearch = string(s.query)
+	t.search = nil
+	t.histPos = len(t.History)
+	t.refreshLine()
+	return false
+}
+
+// ==========================================
+// 按键分发
+// ==========================================
+
+type editResult int
+
+const (
+	editContinue editResult = iota
+	editSubmit
+	editEOF
+)
+
+// handleKey 处理一个按键，返回是否提交当前行或结束会话
+func (t *Terminal) handleKey(ev keyEvent) editResult {
+	// 括号粘贴：粘贴内容原样插入，不解释控制字符
+	if t.pasting {
+		switch ev.code {
+		case keyPasteEnd:
+			t.pasting = false
+			t.insert(t.pasteBuf)
+			t.pasteBuf = nil
+		case keyRune:
+			switch ev.r {
+			case '\r':
+				t.pasteBuf = append(t.pasteBuf, '\n')
+			case '\n':
+				if n := len(t.pasteBuf); n == 0 || t.pasteBuf[n-1] != '\n' {
+					t.pasteBuf = append(t.pasteBuf, '\n')
+				}
+			default:
+				if unicode.IsPrint(ev.r) || ev.r == '\t' {
+					t.pasteBuf = append(t.pasteBuf, ev.r)
+				}
+			}
+		}
+		return editContinue
+	}
+
+	if t.completeAsk != nil {
+		t.answerCompletionQuery(ev)
+		return editContinue
+	}
+	if t.search != nil && t.searchKey(ev) {
+		return editContinue
+	}
+
+	t.lastAction, t.action = t.action, ""
+	switch ev.code {
+	case keyUp:
+		t.historyMove(-1)
+	case keyDown:
+		t.historyMove(1)
+	case keyLeft:
+		t.moveCursor(t.cursor - 1)
+	case keyRight:
+		t.moveCursor(t.cursor + 1)
+	case keyHome:
+		t.moveCursor(0)
+	case keyEnd:
+		t.moveCursor(len(t.buffer))
+	case keyWordLeft:
+		t.moveCursor(t.wordLeft())
+	case keyWordRight:
+		t.moveCursor(t.wordRight())
+	case keyDelete:
+		if t.cursor < len(t.buffer) {
+			t.buffer = append(t.buffer[:t.cursor], t.buffer[t.cursor+1:]...)
+			t.refreshLine()
+		}
+	case keyPasteStart:
+		t.pasting = true
+	case keyAlt:
+		switch ev.r {
+		case 'b', 'B':
+			t.moveCursor(t.wordLeft())
+		case 'f', 'F':
+			t.moveCursor(t.wordRight())
+		case 'd', 'D':
+			t.kill(t.cursor, t.wordRight(), false)
+		case 127, 8:
+			t.kill(t.wordLeft(), t.cursor, true)
+		case 'y', 'Y':
+			t.yankPop()
+		case '.', '_':
+			// 插入上一条命令的最后一个参数
+			if n := len(t.History); n > 0 {
+				if f := parseArgs(t.History[n-1]); len(f) > 0 {
+					t.insert([]rune(f[len(f)-1]))
+				}
+			}
+		}
+	case keyRune:
+		return t.handleControl(ev.r)
+	}
+	return editContinue
+}
+
+func (t *Terminal) handleControl(r rune) editResult {
+	switch r {
+	case 1: // Ctrl-A
+		t.moveCursor(0)
+	case 2: // Ctrl-B
+		t.moveCursor(t.cursor - 1)
+	case 3: // Ctrl-C
+		t.moveCursor(len(t.buffer))
+		t.Print("^C\n")
+		t.buffer = t.buffer[:0]
+		t.cursor = 0
+		t.histPos = len(t.History)
+		t.Prompt()
+	case 4: // Ctrl-D
+		if len(t.buffer) == 0 {
+			t.Print("logout\n")
+			return editEOF
+		}
+		if t.cursor < len(t.buffer) {
+			t.buffer = append(t.buffer[:t.cursor], t.buffer[t.cursor+1:]...)
+			t.refreshLine()
+		}
+	case 5: // Ctrl-E
+		t.moveCursor(len(t.buffer))
+	case 6: // Ctrl-F
+		t.moveCursor(t.cursor + 1)
+	case 9: // TAB
+		t.handleAutoComplete()
+	case 11: // Ctrl-K
+		t.kill(t.cursor, len(t.buffer), false)
+	case 12: // Ctrl-L
+		t.RW.Write([]byte("\033[H\033[2J"))
+		t.Prompt()
+	case 13, 10: // Enter
+		return editSubmit
+	case 14: // Ctrl-N
+		t.historyMove(1)
+	case 16: // Ctrl-P
+		t.historyMove(-1)
+	case 18: // Ctrl-R
+		t.search = &historySearch{pos: len(t.History), saved: append([]rune(nil), t.buffer...)}
+		t.refreshLine()
+	case 20: // Ctrl-T：交换光标前的两个字符
+		if len(t.buffer) >= 2 && t.cursor > 0 {
+			i := t.cursor
+			if i == len(t.buffer) {
+				i--
+			}
+			t.buffer[i-1], t.buffer[i] = t.buffer[i], t.buffer[i-1]
+			t.cursor = i + 1
+			t.refreshLine()
+		}
+	case 21: // Ctrl-U
+		t.kill(0, t.cursor, true)
+	case 23: // Ctrl-W：按空白删除前一个词
+		i := t.cursor
+		for i > 0 && unicode.IsSpace(t.buffer[i-1]) {
+			i--
+		}
+		for i > 0 && !unicode.IsSpace(t.buffer[i-1]) {
+			i--
+		}
+		t.kill(i, t.cursor, true)
+	case 25: // Ctrl-Y
+		t.yank()
+	case 127, 8: // Backspace
+		if t.cursor > 0 {
+			t.buffer = append(t.buffer[:t.cursor-1], t.buffer[t.cursor:]...)
+			t.cursor--
+			t.refreshLine()
+		}
+	default:
+		if unicode.IsPrint(r) {
+			t.insert([]rune{r})
+		}
+	}
+	return editContinue
+}
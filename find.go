@@ -0,0 +1,787 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// find (GNU findutils 4.8)
+// ==========================================
+
+// findMaxVisits 单次 find 最多访问的条目数
+const findMaxVisits = 200000
+
+// findCtx 当前被测试的文件
+type findCtx struct {
+	path  string // 显示的路径
+	abs   string
+	start string // 起点 (用于 %P)
+	e     *FileEntry
+	depth int
+}
+
+type findPred func(c *findCtx) bool
+
+type finder struct {
+	t   *Terminal
+	out io.Writer
+
+	args []string
+	pos  int
+
+	maxDepth, minDepth int
+	depthFirst         bool
+	hasAction          bool
+	prune, quit        bool
+	failed             bool
+	visits             int
+	batches            []*findBatch
+	now                time.Time
+}
+
+// findBatch 收集 -exec ... {} + 的文件名，最后一起执行
+type findBatch struct {
+	argv  []string
+	files []string
+}
+
+type findError string
+
+func (f *finder) fail(format string, a ...any) {
+	panic(findError(fmt.Sprintf(format, a...)))
+}
+
+func (f *finder) peek() string {
+	if f.pos < len(f.args) {
+		return f.args[f.pos]
+	}
+	return ""
+}
+
+// arg 取出断言的参数
+func (f *finder) arg(name string) string {
+	if f.pos >= len(f.args) {
+		f.fail("缺少“%s”的参数", name)
+	}
+	f.pos++
+	return f.args[f.pos-1]
+}
+
+func (f *finder) parseOr() findPred {
+	left := f.parseAnd()
+	for f.peek() == "-o" || f.peek() == "-or" {
+		f.pos++
+		if f.pos >= len(f.args) || f.peek() == ")" {
+			f.fail("“-o”之后缺少表达式")
+		}
+		l, r := left, f.parseAnd()
+		left = func(c *findCtx) bool { return l(c) || r(c) }
+	}
+	return left
+}
+
+func (f *finder) parseAnd() findPred {
+	left := f.parseNot()
+	for f.pos < len(f.args) && f.peek() != "-o" && f.peek() != "-or" && f.peek() != ")" && f.peek() != "," {
+		if f.peek() == "-a" || f.peek() == "-and" {
+			f.pos++
+		}
+		l, r := left, f.parseNot()
+		left = func(c *findCtx) bool { return l(c) && r(c) }
+	}
+	return left
+}
+
+func (f *finder) parseNot() findPred {
+	switch f.peek() {
+	case "!", "-not":
+		f.pos++
+		if f.pos >= len(f.args) {
+			f.fail("“!”之后缺少表达式")
+		}
+		p := f.parseNot()
+		return func(c *findCtx) bool { return !p(c) }
+	case "(":
+		f.pos++
+		if f.peek() == ")" {
+			f.fail("在“(”和“)”之间没有表达式")
+		}
+		p := f.parseOr()
+		if f.peek() != ")" {
+			f.fail("表达式中不匹配的“(”")
+		}
+		f.pos++
+		return p
+	case ")":
+		f.fail("表达式中不匹配的“)”")
+	}
+	return f.primary()
+}
+
+// findGlob 把 shell 通配符转换为正则 (find 的 * 也匹配以 . 开头的名字)
+func findGlob(pat string, icase bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if icase {
+		b.WriteString("(?i)")
+	}
+	b.WriteByte('^')
+	for i := 0; i < len(pat); i++ {
+		switch c := pat[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteByte('.')
+		case '\\':
+			if i+1 < len(pat) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+			}
+		case '[':
+			j := i + 1
+			if j < len(pat) && (pat[j] == '!' || pat[j] == '^') {
+				j++
+			}
+			if j < len(pat) && pat[j] == ']' {
+				j++
+			}
+			for j < len(pat) && pat[j] != ']' {
+				j++
+			}
+			if j >= len(pat) {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pat[i+1 : j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteByte('$')
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(pat) + `$`)
+	}
+	return re
+}
+
+// findNumber 解析 +N / -N / N，返回比较函数
+func (f *finder) findNumber(name, v string) func(n float64) bool {
+	s := strings.TrimLeft(v, "+-")
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || s == "" {
+		f.fail("%s 的参数 “%s” 无效", name, v)
+	}
+	switch {
+	case strings.HasPrefix(v, "+"):
+		return func(x float64) bool { return x > n }
+	case strings.HasPrefix(v, "-"):
+		return func(x float64) bool { return x < n }
+	}
+	return func(x float64) bool { return x == n }
+}
+
+// findPerm 解析 -perm 的八进制或符号模式
+func (f *finder) findPerm(v string) (uint32, byte) {
+	kind := byte('=')
+	if v != "" && (v[0] == '-' || v[0] == '/' || v[0] == '+') {
+		kind = v[0]
+		v = v[1:]
+	}
+	if kind == '+' {
+		kind = '/'
+	}
+	if n, err := strconv.ParseUint(v, 8, 32); err == nil {
+		return uint32(n), kind
+	}
+	var mode uint32
+	for _, clause := range strings.Split(v, ",") {
+		i := 0
+		who := uint32(0)
+		for i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0 {
+			switch clause[i] {
+			case 'u':
+				who |= 04700
+			case 'g':
+				who |= 02070
+			case 'o':
+				who |= 01007
+			case 'a':
+				who |= 07777
+			}
+			i++
+		}
+		if who == 0 {
+			who = 07777
+		}
+		if i >= len(clause) || strings.IndexByte("=+", clause[i]) < 0 {
+			f.fail("无效的模式“%s”", v)
+		}
+		var bits uint32
+		for _, c := range clause[i+1:] {
+			switch c {
+			case 'r':
+				bits |= 0444
+			case 'w':
+				bits |= 0222
+			case 'x':
+				bits |= 0111
+			case 's':
+				bits |= 06000
+			case 't':
+				bits |= 01000
+			default:
+				f.fail("无效的模式“%s”", v)
+			}
+		}
+		mode |= bits & who
+	}
+	return mode, kind
+}
+
+// readable/writable/executable 按当前用户和权限位判断
+func (f *finder) access(e *FileEntry, bit uint32) bool {
+	uid, gid := f.t.FS.owner(f.t.userName())
+	mode := unixMode(e.Mode)
+	if uid == 0 {
+		return bit != 1 || e.IsDir || mode&0111 != 0
+	}
+	switch {
+	case e.UID == uid:
+		return mode&(bit<<6) != 0
+	case e.GID == gid:
+		return mode&(bit<<3) != 0
+	}
+	return mode&bit != 0
+}
+
+func (f *finder) primary() findPred {
+	name := f.arg("")
+	switch name {
+	case "-name", "-iname":
+		re := findGlob(f.arg(name), name == "-iname")
+		return func(c *findCtx) bool {
+			base := path.Base(c.path)
+			if c.path == "/" {
+				base = "/"
+			}
+			return re.MatchString(base)
+		}
+	case "-path", "-ipath", "-wholename", "-iwholename":
+		re := findGlob(f.arg(name), strings.HasPrefix(name, "-i"))
+		return func(c *findCtx) bool { return re.MatchString(c.path) }
+	case "-regex", "-iregex":
+		// 默认的 emacs 正则语法与 BRE 接近 (\| 和 \( 是元字符)，必须匹配整个路径
+		re, err := sedRegexp(f.arg(name), false, name == "-iregex")
+		if err != nil {
+			f.fail("%s", err)
+		}
+		re.Longest()
+		return func(c *findCtx) bool {
+			loc := re.FindStringIndex(c.path)
+			return loc != nil && loc[0] == 0 && loc[1] == len(c.path)
+		}
+	case "-type", "-xtype":
+		v := f.arg(name)
+		types := map[byte]bool{}
+		for _, t := range strings.Split(v, ",") {
+			if len(t) != 1 || strings.IndexByte("bcdpflsD", t[0]) < 0 {
+				f.fail("-type 的参数未知：%s", t)
+			}
+			types[t[0]] = true
+		}
+		return func(c *findCtx) bool {
+			m := c.e.Mode
+			switch {
+			case c.e.IsDir:
+				return types['d']
+			case m&os.ModeCharDevice != 0:
+				return types['c']
+			case m&os.ModeDevice != 0:
+				return types['b']
+			case m&os.ModeNamedPipe != 0:
+				return types['p']
+			case m&os.ModeSocket != 0:
+				return types['s']
+			case m&os.ModeSymlink != 0:
+				return types['l']
+			}
+			return types['f']
+		}
+	case "-perm":
+		mode, kind := f.findPerm(f.arg(name))
+		return func(c *findCtx) bool {
+			m := unixMode(c.e.Mode)
+			switch kind {
+			case '-':
+				return m&mode == mode
+			case '/':
+				return mode == 0 || m&mode != 0
+			}
+			return m == mode
+		}
+	case "-mtime", "-atime", "-ctime":
+		cmp := f.findNumber(name, f.arg(name))
+		return func(c *findCtx) bool {
+			return cmp(math.Floor(f.now.Sub(c.e.ModTime).Hours() / 24))
+		}
+	case "-mmin", "-amin", "-cmin":
+		cmp := f.findNumber(name, f.arg(name))
+		return func(c *findCtx) bool {
+			return cmp(math.Ceil(f.now.Sub(c.e.ModTime).Minutes()))
+		}
+	case "-newer", "-anewer", "-cnewer":
+		ref := f.arg(name)
+		re, ok := f.t.FS.GetEntry(f.t.FS.Abs(f.t.expandTilde(ref)))
+		if !ok {
+			f.fail("‘%s’: 没有那个文件或目录", ref)
+		}
+		return func(c *findCtx) bool { return c.e.ModTime.After(re.ModTime) }
+	case "-size":
+		v := f.arg(name)
+		unit := int64(512)
+		if v != "" {
+			switch v[len(v)-1] {
+			case 'c':
+				unit = 1
+			case 'w':
+				unit = 2
+			case 'b':
+				unit = 512
+			case 'k':
+				unit = 1024
+			case 'M':
+				unit = 1 << 20
+			case 'G':
+				unit = 1 << 30
+			}
+			if v[len(v)-1] < '0' || v[len(v)-1] > '9' {
+				v = v[:len(v)-1]
+			}
+		}
+		cmp := f.findNumber(name, v)
+		return func(c *findCtx) bool {
+			return cmp(float64((fileSize(c.e) + unit - 1) / unit))
+		}
+	case "-empty":
+		return func(c *findCtx) bool {
+			if c.e.IsDir {
+				children, _ := f.t.FS.ListDir(c.abs)
+				return len(children) == 0
+			}
+			return len(c.e.Content) == 0
+		}
+	case "-user", "-group":
+		v := f.arg(name)
+		ids := f.t.FS.users()
+		if name == "-group" {
+			ids = f.t.FS.groups()
+		}
+		id, ok := ids[v]
+		if !ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				if name == "-user" {
+					f.fail("‘%s’ 是未知的用户", v)
+				}
+				f.fail("‘%s’ 是未知的组", v)
+			}
+			id = n
+		}
+		if name == "-user" {
+			return func(c *findCtx) bool { return c.e.UID == id }
+		}
+		return func(c *findCtx) bool { return c.e.GID == id }
+	case "-uid", "-gid":
+		cmp := f.findNumber(name, f.arg(name))
+		if name == "-uid" {
+			return func(c *findCtx) bool { return cmp(float64(c.e.UID)) }
+		}
+		return func(c *findCtx) bool { return cmp(float64(c.e.GID)) }
+	case "-nouser":
+		users := f.t.FS.users()
+		return func(c *findCtx) bool { _, ok := getNameByID(users, c.e.UID); return !ok }
+	case "-nogroup":
+		groups := f.t.FS.groups()
+		return func(c *findCtx) bool { _, ok := getNameByID(groups, c.e.GID); return !ok }
+	case "-readable":
+		return func(c *findCtx) bool { return f.access(c.e, 4) }
+	case "-writable":
+		return func(c *findCtx) bool { return f.access(c.e, 2) }
+	case "-executable":
+		return func(c *findCtx) bool { return f.access(c.e, 1) }
+	case "-links":
+		cmp := f.findNumber(name, f.arg(name))
+		return func(c *findCtx) bool { return cmp(float64(max(c.e.Nlink, 1))) }
+	case "-inum":
+		cmp := f.findNumber(name, f.arg(name))
+		return func(c *findCtx) bool { return cmp(float64(fileInode(c.abs))) }
+	case "-true":
+		return func(*findCtx) bool { return true }
+	case "-false":
+		return func(*findCtx) bool { return false }
+
+	// 全局选项
+	case "-maxdepth", "-mindepth":
+		v := f.arg(name)
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			f.fail("%s 的参数 “%s” 无效", name, v)
+		}
+		if name == "-maxdepth" {
+			f.maxDepth = n
+		} else {
+			f.minDepth = n
+		}
+		return func(*findCtx) bool { return true }
+	case "-depth", "-d":
+		f.depthFirst = true
+		return func(*findCtx) bool { return true }
+	case "-xdev", "-mount", "-follow", "-noleaf", "-ignore_readdir_race", "-nowarn", "-warn":
+		return func(*findCtx) bool { return true }
+	case "-regextype":
+		f.arg(name)
+		return func(*findCtx) bool { return true }
+
+	// 动作
+	case "-print":
+		f.hasAction = true
+		return func(c *findCtx) bool { fmt.Fprintln(f.out, c.path); return true }
+	case "-print0":
+		f.hasAction = true
+		return func(c *findCtx) bool { fmt.Fprint(f.out, c.path+"\x00"); return true }
+	case "-printf":
+		format := f.arg(name)
+		f.hasAction = true
+		return func(c *findCtx) bool { fmt.Fprint(f.out, f.printf(format, c)); return true }
+	case "-ls":
+		f.hasAction = true
+		return func(c *findCtx) bool {
+			fmt.Fprintf(f.out, "%9d %6d %s %3d %-8s %-8s %12d %s %s\n", fileInode(c.abs), fileBlocks(c.e)/2,
+				modeString(c.e.Mode), max(c.e.Nlink, 1), f.t.FS.uidName(c.e.UID), f.t.FS.gidName(c.e.GID), fileSize(c.e),
+				lsTime(c.e.ModTime), c.path)
+			return true
+		}
+	case "-prune":
+		return func(c *findCtx) bool {
+			if !f.depthFirst {
+				f.prune = true
+			}
+			return true
+		}
+	case "-quit":
+		return func(*findCtx) bool { f.quit = true; return true }
+	case "-delete":
+		f.hasAction, f.depthFirst = true, true
+		return func(c *findCtx) bool {
+			if c.e.IsDir {
+				if children, _ := f.t.FS.ListDir(c.abs); len(children) > 0 {
+					fmt.Fprintf(f.t.Stderr, "find: 无法删除 ‘%s’: 目录非空\n", c.path)
+					f.failed = true
+					return false
+				}
+			}
+			if c.depth == 0 && (c.path == "." || c.path == "..") {
+				return true
+			}
+			f.t.FS.Remove(c.abs)
+			return true
+		}
+	case "-exec", "-execdir", "-ok", "-okdir":
+		f.hasAction = true
+		var argv []string
+		for {
+			if f.pos >= len(f.args) {
+				f.fail("缺少“%s”的参数", name)
+			}
+			a := f.args[f.pos]
+			f.pos++
+			if a == ";" {
+				break
+			}
+			if a == "+" && len(argv) > 0 && argv[len(argv)-1] == "{}" {
+				b := &findBatch{argv: argv[:len(argv)-1]}
+				f.batches = append(f.batches, b)
+				return func(c *findCtx) bool {
+					b.files = append(b.files, c.path)
+					return true
+				}
+			}
+			argv = append(argv, a)
+		}
+		if len(argv) == 0 {
+			f.fail("缺少“%s”的参数", name)
+		}
+		dir := strings.HasSuffix(name, "dir")
+		return func(c *findCtx) bool {
+			name := c.path
+			if dir {
+				name = "./" + path.Base(c.path)
+			}
+			cmd := make([]string, len(argv))
+			for i, a := range argv {
+				cmd[i] = strings.ReplaceAll(a, "{}", name)
+			}
+			return f.exec(cmd)
+		}
+	}
+	if strings.HasPrefix(name, "-") {
+		f.fail("未知的断言“%s”", name)
+	}
+	f.fail("路径必须在表达式之前: “%s”", name)
+	return nil
+}
+
+// exec 运行 -exec 的命令，命令退出码为 0 时为真
+func (f *finder) exec(argv []string) bool {
+	saved := f.t.lastExitCode
+	f.t.lastExitCode = 0
+	f.t.runCommand(argv, &bytes.Buffer{}, f.out)
+	ok := f.t.lastExitCode == 0
+	f.t.lastExitCode = saved
+	return ok
+}
+
+// printf 展开 -printf 的格式
+func (f *finder) printf(format string, c *findCtx) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		switch {
+		case ch == '\\' && i+1 < len(format):
+			i++
+			switch format[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			case '\\':
+				b.WriteByte('\\')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(format[i])
+			}
+		case ch == '%' && i+1 < len(format):
+			j := i + 1
+			for j < len(format) && strings.IndexByte("-+ #0123456789.", format[j]) >= 0 {
+				j++
+			}
+			if j >= len(format) {
+				b.WriteString(format[i:])
+				return b.String()
+			}
+			flags := format[i+1 : j]
+			i = j
+			str := func(s string) { fmt.Fprintf(&b, "%"+flags+"s", s) }
+			num := func(n int64) { fmt.Fprintf(&b, "%"+flags+"d", n) }
+			switch format[j] {
+			case '%':
+				b.WriteByte('%')
+			case 'p':
+				str(c.path)
+			case 'P':
+				str(strings.TrimPrefix(strings.TrimPrefix(c.path, c.start), "/"))
+			case 'f':
+				str(path.Base(c.path))
+			case 'h':
+				d := path.Dir(c.path)
+				if !strings.Contains(c.path, "/") {
+					d = "."
+				}
+				str(d)
+			case 's':
+				num(fileSize(c.e))
+			case 'k':
+				num(fileBlocks(c.e) / 2)
+			case 'b':
+				num(fileBlocks(c.e))
+			case 'm':
+				fmt.Fprintf(&b, "%"+flags+"o", unixMode(c.e.Mode))
+			case 'M':
+				str(modeString(c.e.Mode))
+			case 'u':
+				str(f.t.FS.uidName(c.e.UID))
+			case 'g':
+				str(f.t.FS.gidName(c.e.GID))
+			case 'U':
+				num(int64(c.e.UID))
+			case 'G':
+				num(int64(c.e.GID))
+			case 'i':
+				num(int64(fileInode(c.abs)))
+			case 'n':
+				num(int64(max(c.e.Nlink, 1)))
+			case 'd':
+				num(int64(c.depth))
+			case 'y':
+				if c.e.IsDir {
+					str("d")
+				} else {
+					str("f")
+				}
+			case 't', 'a', 'c':
+				str(c.e.ModTime.Format("Mon Jan _2 15:04:05.0000000000 2006"))
+			case 'T', 'A', 'C':
+				if i+1 < len(format) {
+					i++
+					str(findTimeField(format[i], c.e.ModTime))
+				}
+			default:
+				b.WriteString(format[j-len(flags)-1 : j+1])
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// findTimeField 实现 %Tk 中常用的 k
+func findTimeField(k byte, tm time.Time) string {
+	switch k {
+	case '@':
+		return fmt.Sprintf("%d.%010d", tm.Unix(), tm.Nanosecond()*10)
+	case 'Y':
+		return tm.Format("2006")
+	case 'm':
+		return tm.Format("01")
+	case 'd':
+		return tm.Format("02")
+	case 'H':
+		return tm.Format("15")
+	case 'M':
+		return tm.Format("04")
+	case 'S':
+		return tm.Format("05")
+	case 'F':
+		return tm.Format("2006-01-02")
+	case 'T':
+		return tm.Format("15:04:05")
+	case '+':
+		return tm.Format("2006-01-02+15:04:05")
+	case 'D':
+		return tm.Format("01/02/06")
+	}
+	return ""
+}
+
+// walk 先序 (或 -depth 时后序) 遍历目录树
+func (f *finder) walk(expr findPred, start, display, abs string, depth int) {
+	if f.quit {
+		return
+	}
+	if f.visits++; f.visits > findMaxVisits {
+		f.quit = true
+		return
+	}
+	e, ok := f.t.FS.GetEntry(abs)
+	if !ok {
+		return
+	}
+	c := &findCtx{path: display, abs: abs, start: start, e: e, depth: depth}
+	visit := func() {
+		if depth >= f.minDepth && expr(c) && !f.hasAction {
+			fmt.Fprintln(f.out, display)
+		}
+	}
+	f.prune = false
+	if !f.depthFirst {
+		visit()
+	}
+	if e.IsDir && !f.prune && (f.maxDepth < 0 || depth < f.maxDepth) {
+		children, _ := f.t.FS.ListDir(abs)
+		for _, child := range children {
+			if f.quit {
+				return
+			}
+			name := strings.TrimSuffix(display, "/") + "/" + child.Name
+			if display == "/" {
+				name = "/" + child.Name
+			}
+			f.walk(expr, start, name, path.Join(abs, child.Name), depth+1)
+		}
+	}
+	if f.depthFirst && !f.quit {
+		visit()
+	}
+}
+
+func (t *Terminal) cmdFind(args []string, out io.Writer) {
+	f := &finder{t: t, out: out, maxDepth: -1, now: time.Now()}
+	i := 1
+	// 开头的 -H -L -P 选项
+	for i < len(args) && (args[i] == "-H" || args[i] == "-L" || args[i] == "-P") {
+		i++
+	}
+	var starts []string
+	for i < len(args) && !(strings.HasPrefix(args[i], "-") && len(args[i]) > 1) && args[i] != "(" && args[i] != "!" {
+		starts = append(starts, args[i])
+		i++
+	}
+	if len(starts) == 0 {
+		starts = []string{"."}
+	}
+	f.args = args[i:]
+
+	var expr findPred
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				msg, ok := r.(findError)
+				if !ok {
+					panic(r)
+				}
+				fmt.Fprintf(t.Stderr, "find: %s\n", msg)
+				t.lastExitCode = 1
+				expr = nil
+			}
+		}()
+		expr = func(*findCtx) bool { return true }
+		if len(f.args) > 0 {
+			expr = f.parseOr()
+			if f.pos < len(f.args) {
+				f.fail("表达式中不匹配的“)”")
+			}
+		}
+	}()
+	if expr == nil {
+		return
+	}
+
+	for _, s := range starts {
+		p := t.FS.Abs(t.expandTilde(s))
+		if _, ok := t.FS.GetEntry(p); !ok {
+			fmt.Fprintf(t.Stderr, "find: ‘%s’: 没有那个文件或目录\n", s)
+			f.failed = true
+			continue
+		}
+		f.walk(expr, s, s, p, 0)
+	}
+	for _, b := range f.batches {
+		for len(b.files) > 0 {
+			n := min(len(b.files), 1000)
+			f.exec(append(append([]string{}, b.argv...), b.files[:n]...))
+			b.files = b.files[n:]
+		}
+	}
+	t.lastExitCode = 0
+	if f.failed {
+		t.lastExitCode = 1
+	}
+}
@@ -32,20 +32,77 @@ type FileEntry struct {
 	mu sync.RWMutex
 }
 
+// clone 复制元数据（不复制锁状态，复制 sync.Mutex 是不安全的）
+func (e *FileEntry) clone() *FileEntry {
+	return &FileEntry{
+		Name:    e.Name,
+		IsDir:   e.IsDir,
+		Content: e.Content,
+		Mode:    e.Mode,
+		ModTime: e.ModTime,
+		UID:     e.UID,
+		GID:     e.GID,
+		Nlink:   e.Nlink,
+	}
+}
+
 // SessionFS 会话文件系统，基于 COW 技术
 type SessionFS struct {
 	overlay map[string]*FileEntry // 会话层修改，nil 表示已删除
-	mu      sync.RWMutex
+	mu      *sync.RWMutex         // 与 View 共享，保护 overlay
 	cwd     string
+	self    func() int    // 当前会话 shell 的 PID，用于 /proc/self
+	origin  func() string // 写入方的对端地址，持久化检测事件据此归属
 }
 
 func NewSessionFS() *SessionFS {
 	return &SessionFS{
 		overlay: make(map[string]*FileEntry),
+		mu:      &sync.RWMutex{},
 		cwd:     "/root",
 	}
 }
 
+// View 返回共享同一 overlay、但拥有独立工作目录的视图
+// 用于多个会话同时操作同一文件系统而互不影响 cwd
+func (fs *SessionFS) View(cwd string) *SessionFS {
+	return &SessionFS{
+		overlay: fs.overlay,
+		mu:      fs.mu,
+		cwd:     cwd,
+		self:    fs.self,
+		origin:  fs.origin,
+	}
+}
+
+// From 返回以 remote 名义写入的视图，供不经过终端的服务 (FTP、SFTP、Redis 等) 使用
+func (fs *SessionFS) From(remote string) *SessionFS {
+	v := fs.View(fs.cwd)
+	v.origin = func() string { return remote }
+	return v
+}
+
+// remote 返回写入方的对端地址
+func (fs *SessionFS) remote() string {
+	if fs.origin == nil {
+		return "-"
+	}
+	return fs.origin()
+}
+
+// watched 返回持久化位置在修改前的内容
+func (fs *SessionFS) watched(p string) (old []byte, ok bool) {
+	if persistKind(p) == "" {
+		return nil, false
+	}
+	if e, exists := fs.GetEntry(p); exists && !e.IsDir {
+		e.mu.RLock()
+		old = e.Content
+		e.mu.RUnlock()
+	}
+	return old, true
+}
+
 // Abs 将相对路径转换为绝对路径，并处理 . 和 ..
 func (fs *SessionFS) Abs(p string) string {
 	if p == "" {
@@ -67,6 +124,13 @@ func (fs *SessionFS) Abs(p string) string {
 func (fs *SessionFS) GetEntry(p string) (*FileEntry, bool) {
 	p = path.Clean(p)
 
+	// /dev、/proc、/sys 下的虚拟文件在读取时生成
+	if v, ok := virtualMount(p); ok {
+		if e, ok := v.Lookup(fs, p); ok {
+			return e, true
+		}
+	}
+
 	// 1. 检查会话层 (加读锁)
 	fs.mu.RLock()
 	e, ok := fs.overlay[p]
@@ -104,6 +168,12 @@ func (fs *SessionFS) ListDir(dirPath string) ([]*FileEntry, error) {
 		}
 	}
 
+	if v, ok := virtualMount(dirPath); ok {
+		for _, e := range v.List(fs, dirPath) {
+			items[e.Name] = e
+		}
+	}
+
 	// 2. 加载 Overlay 中的子项，合并/应用变更
 	fs.mu.RLock()
 	for p, e := range fs.overlay {
@@ -131,13 +201,28 @@ func (fs *SessionFS) ListDir(dirPath string) ([]*FileEntry, error) {
 }
 
 func (fs *SessionFS) Write(p string, data []byte, mode os.FileMode) error {
+	p = path.Clean(p)
+	old, watched := fs.watched(p)
+	if err := fs.write(p, data, mode); err != nil {
+		return err
+	}
+	if watched {
+		persistEvent(fs.remote(), "write", p, old, data)
+	}
+	return nil
+}
+
+func (fs *SessionFS) write(p string, data []byte, mode os.FileMode) error {
+	if handled, err := fs.writeVirtual(p, data); handled {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	if len(data) > MaxFileSize {
 		return errors.New("超出磁盘限额")
 	}
-	p = path.Clean(p)
 
 	if existing, ok := fs.overlay[p]; ok && existing != nil {
 		existing.mu.Lock()
@@ -190,10 +275,20 @@ func (fs *SessionFS) Mkdir(p string) error {
 }
 
 func (fs *SessionFS) Remove(p string) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
 	p = path.Clean(p)
+	// 虚拟文件由提供者生成，无法删除
+	if v, ok := virtualMount(p); ok {
+		if _, ok := v.Lookup(fs, p); ok {
+			return errNotPermitted
+		}
+	}
+	old, watched := fs.watched(p)
+	fs.mu.Lock()
 	fs.overlay[p] = nil
+	fs.mu.Unlock()
+	if watched {
+		persistEvent(fs.remote(), "remove", p, old, nil)
+	}
 	return nil
 }
 
@@ -206,19 +301,28 @@ func (fs *SessionFS) Rename(oldP, newP string) error {
 	// 注意：这里我们做了一个浅拷贝，这在重命名时通常是可以的，
 	// 但如果之后修改 newEntry.Content，因为是切片引用，可能会影响旧的（如果旧的还存在）。
 	// 在本系统中，旧的被标记为 nil (删除)，所以没问题。
-	newEntry := *e
+	newEntry := e.clone()
 	newEntry.Name = path.Base(newP)
 	newEntry.ModTime = time.Now()
-	// 重置锁状态 (复制 sync.Mutex 是不安全的，必须重置)
-	newEntry.mu = sync.RWMutex{}
 
+	oldContent, oldWatched := fs.watched(oldP)
+	prev, newWatched := fs.watched(newP)
 	fs.mu.Lock()
-	fs.overlay[newP] = &newEntry
+	fs.overlay[newP] = newEntry
 	fs.overlay[oldP] = nil
 	fs.mu.Unlock()
+	if oldWatched {
+		persistEvent(fs.remote(), "remove", oldP, oldContent, nil)
+	}
+	if newWatched {
+		persistEvent(fs.remote(), "write", newP, prev, newEntry.Content)
+	}
 	return nil
 }
 
+// chmodBits Chmod 可以修改的位：权限位和 setuid/setgid/sticky
+const chmodBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
 func (fs *SessionFS) Chmod(p string, mode os.FileMode) error {
 	e, ok := fs.GetEntry(p)
 	if !ok {
@@ -232,17 +336,15 @@ func (fs *SessionFS) Chmod(p string, mode os.FileMode) error {
 	// 再次检查 Overlay，防止并发竞态
 	if existing, ok := fs.overlay[p]; ok && existing != nil {
 		existing.mu.Lock()
-		existing.Mode = (existing.Mode &^ 0777) | (mode & 0777)
+		existing.Mode = (existing.Mode &^ chmodBits) | (mode & chmodBits)
 		existing.ModTime = time.Now()
 		existing.mu.Unlock()
 	} else {
 		// 从 BaseFS 复制
-		newEntry := *e
-		newEntry.Mode = (newEntry.Mode &^ 0777) | (mode & 0777)
+		newEntry := e.clone()
+		newEntry.Mode = (newEntry.Mode &^ chmodBits) | (mode & chmodBits)
 		newEntry.ModTime = time.Now()
-		// 重置锁
-		newEntry.mu = sync.RWMutex{}
-		fs.overlay[p] = &newEntry
+		fs.overlay[p] = newEntry
 	}
 	return nil
 }
@@ -267,7 +369,7 @@ func (fs *SessionFS) Chown(p string, uid, gid int) error {
 		existing.ModTime = time.Now()
 		existing.mu.Unlock()
 	} else {
-		newEntry := *e
+		newEntry := e.clone()
 		if uid != -1 {
 			newEntry.UID = uid
 		}
@@ -275,8 +377,7 @@ func (fs *SessionFS) Chown(p string, uid, gid int) error {
 			newEntry.GID = gid
 		}
 		newEntry.ModTime = time.Now()
-		newEntry.mu = sync.RWMutex{}
-		fs.overlay[p] = &newEntry
+		fs.overlay[p] = newEntry
 	}
 	return nil
 }
@@ -3,7 +3,6 @@ package main
 import (
 	"os"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -16,8 +15,6 @@ var (
 	startTime      = time.Now()
 	BaseFS         map[string]*FileEntry
 	BaseFSDirCache map[string][]*FileEntry // 性能优化：预先索引的目录内容
-	Users          map[string]int          // 用户名 -> UID
-	Groups         map[string]int          // 组名 -> GID
 	// 新增：全局共享的会话文件系统，确保数据在不同连接间持久化
 	GlobalSessionFS *SessionFS
 )
@@ -25,8 +22,6 @@ var (
 func initFS() {
 	BaseFS = make(map[string]*FileEntry)
 	BaseFSDirCache = make(map[string][]*FileEntry) // 初始化缓存
-	Users = make(map[string]int)
-	Groups = make(map[string]int)
 	t := time.Now()
 
 	// 辅助函数：添加文件
@@ -47,10 +42,17 @@ func initFS() {
 		"/media", "/mnt", "/opt", "/proc", "/root", "/run", "/sbin",
 		"/srv", "/sys", "/tmp", "/usr", "/var", "/usr/bin", "/usr/sbin",
 		"/usr/local", "/usr/local/bin", "/var/log", "/home/user",
-		"/etc/ssh", "/etc/systemd", "/etc/network",
-		"/proc/sys", "/proc/sys/kernel", "/proc/net",
-		"/sys/class", "/sys/class/net", "/sys/class/net/eth0",
-		"/var/www", "/var/www/html",
+		"/etc/ssh", "/etc/systemd", "/etc/network", "/etc/skel",
+		"/var/www", "/var/www/html", "/usr/lib", "/usr/lib/cgi-bin",
+		"/root/.ssh", "/var/lib", "/var/lib/redis", "/var/lib/mysql", "/etc/redis", "/etc/mysql",
+		"/var/lib/mysql-files", "/usr/lib/mysql", "/usr/lib/mysql/plugin", "/var/mail",
+		"/usr/share", "/usr/share/man", "/usr/share/man/man1", "/usr/share/man/man5", "/usr/share/man/man8",
+		"/usr/share/doc", "/var/lib/dpkg", "/var/lib/dpkg/info", "/usr/lib/python3", "/usr/lib/python3/dist-packages",
+		"/usr/local/lib", "/usr/local/lib/python3.10", "/usr/local/lib/python3.10/dist-packages",
+		"/lib/systemd", "/lib/systemd/system", "/etc/systemd/system",
+		"/etc/systemd/system/multi-user.target.wants", "/etc/systemd/system/sysinit.target.wants",
+		"/etc/sudoers.d", "/etc/cron.d", "/etc/cron.hourly", "/etc/cron.daily", "/etc/cron.weekly", "/etc/cron.monthly",
+		"/var/spool", "/var/spool/cron", "/var/spool/cron/crontabs", "/var/spool/cron/atjobs", "/var/spool/cron/atspool",
 	}
 	for _, d := range dirs {
 		BaseFS[d] = &FileEntry{
@@ -62,7 +64,7 @@ func initFS() {
 		}
 	}
 
-	// 2. 初始化用户和组
+	// 2. 初始化用户和组 (用户数据库从这些文件读取，见 users.go)
 	passwdContent := "root:x:0:0:root:/root:/bin/bash\n" +
 		"daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n" +
 		"bin:x:2:2:bin:/bin:/usr/sbin/nologin\n" +
@@ -74,81 +76,143 @@ func initFS() {
 		"mail:x:8:8:mail:/var/mail:/usr/sbin/nologin\n" +
 		"news:x:9:9:news:/var/spool/news:/usr/sbin/nologin\n" +
 		"www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin\n" +
+		"nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n" +
+		"systemd-network:x:100:102:systemd Network Management,,,:/run/systemd:/usr/sbin/nologin\n" +
+		"systemd-resolve:x:101:103:systemd Resolver,,,:/run/systemd:/usr/sbin/nologin\n" +
+		"messagebus:x:102:105::/nonexistent:/usr/sbin/nologin\n" +
+		"systemd-timesync:x:103:106:systemd Time Synchronization,,,:/run/systemd:/usr/sbin/nologin\n" +
+		"syslog:x:104:111::/home/syslog:/usr/sbin/nologin\n" +
 		"sshd:x:108:65534::/run/sshd:/usr/sbin/nologin\n" +
+		"mysql:x:113:118:MySQL Server,,,:/nonexistent:/bin/false\n" +
+		"redis:x:114:119::/var/lib/redis:/usr/sbin/nologin\n" +
+		"postfix:x:115:120::/var/spool/postfix:/usr/sbin/nologin\n" +
+		"ftp:x:116:122:ftp daemon,,,:/srv/ftp:/usr/sbin/nologin\n" +
 		"user:x:1000:1000:user:/home/user:/bin/bash\n"
 	groupContent := "root:x:0:\n" +
 		"daemon:x:1:\n" +
 		"bin:x:2:\n" +
 		"sys:x:3:\n" +
-		"adm:x:4:syslog\n" +
+		"adm:x:4:syslog,user\n" +
 		"tty:x:5:\n" +
 		"disk:x:6:\n" +
 		"lp:x:7:\n" +
 		"mail:x:8:\n" +
 		"news:x:9:\n" +
+		"sudo:x:27:user\n" +
 		"www-data:x:33:\n" +
+		"shadow:x:42:\n" +
+		"users:x:100:\n" +
+		"systemd-network:x:102:\n" +
+		"systemd-resolve:x:103:\n" +
+		"messagebus:x:105:\n" +
+		"systemd-timesync:x:106:\n" +
+		"crontab:x:107:\n" +
+		"syslog:x:111:\n" +
 		"sshd:x:108:\n" +
-		"user:x:1000:\n"
-
-	parseUsersGroups := func(passwd, group string) {
-		for _, line := range strings.Split(passwd, "\n") {
-			parts := strings.Split(line, ":")
-			if len(parts) > 3 {
-				name := parts[0]
-				uid, _ := strconv.Atoi(parts[2])
-				Users[name] = uid
-			}
-		}
-		for _, line := range strings.Split(group, "\n") {
-			parts := strings.Split(line, ":")
-			if len(parts) > 2 {
-				name := parts[0]
-				gid, _ := strconv.Atoi(parts[2])
-				Groups[name] = gid
-			}
-		}
-	}
-	parseUsersGroups(passwdContent, groupContent)
+		"mysql:x:118:\n" +
+		"redis:x:119:\n" +
+		"postfix:x:120:\n" +
+		"postdrop:x:121:\n" +
+		"ftp:x:122:\n" +
+		"user:x:1000:\n" +
+		"nogroup:x:65534:\n"
 
 	add("/etc/passwd", passwdContent, 0644, 0, 0)
 	add("/etc/group", groupContent, 0644, 0, 0)
-	add("/etc/hostname", "ubuntu-server", 0644, 0, 0)
+	add("/etc/hostname", HostPersona.Hostname+"\n", 0644, 0, 0)
 	add("/etc/os-release", "PRETTY_NAME=\"Ubuntu 22.04.1 LTS\"\nNAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nVERSION=\"22.04.1 LTS (Jammy Jellyfish)\"\nID=ubuntu\n", 0644, 0, 0)
 	add("/etc/issue", "Ubuntu 22.04.1 LTS \\n \\l\n", 0644, 0, 0)
-	add("/etc/shadow", "root:*:18890:0:99999:7:::\nuser:$6$...:18890:0:99999:7:::\n", 0640, 0, 42)
-	add("/root/.bashrc", "export PS1='\\[\\033[01;32m\\]\\u@\\h\\[\\033[00m\\]:\\[\\033[01;34m\\]\\w\\[\\033[00m\\]\\$ '\nalias ll='ls -alF'\n", 0644, 0, 0)
-	add("/etc/hosts", "127.0.0.1 localhost\n127.0.1.1 ubuntu-server\n", 0644, 0, 0)
+	add("/etc/shadow", shadowFor(passwdContent, "18890"), 0640, 0, 42)
+	add("/etc/gshadow", gshadowFor(groupContent), 0640, 0, 42)
+	add("/etc/sudoers", etcSudoers, 0440, 0, 0)
+	add("/etc/sudoers.d/README", sudoersReadme, 0440, 0, 0)
+	add("/etc/profile", etcProfile, 0644, 0, 0)
+	add("/etc/bash.bashrc", etcBashrc, 0644, 0, 0)
+	add("/root/.profile", rootProfile, 0644, 0, 0)
+	add("/root/.bashrc", userBashrc, 0644, 0, 0)
+	for _, home := range []string{"/etc/skel", "/home/user"} {
+		uid := 0
+		if home == "/home/user" {
+			uid = 1000
+		}
+		add(home+"/.profile", skelProfile, 0644, uid, uid)
+		add(home+"/.bashrc", userBashrc, 0644, uid, uid)
+		add(home+"/.bash_logout", skelBashLogout, 0644, uid, uid)
+	}
+	add("/root/.bash_history", strings.Join(HostPersona.RootHistory, "\n")+"\n", 0600, 0, 0)
+	add("/home/user/.bash_history", strings.Join(HostPersona.UserHistory, "\n")+"\n", 0600, 1000, 1000)
+	add("/etc/hosts", etcHosts(), 0644, 0, 0)
 	add("/etc/resolv.conf", "nameserver 1.1.1.1\nnameserver 8.8.8.8\n", 0644, 0, 0)
 	add("/etc/fstab", "/dev/sda2 / ext4 defaults 0 0\n", 0644, 0, 0)
+	add("/var/www/html/index.html", "<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Transitional//EN\" \"http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd\">\n<html xmlns=\"http://www.w3.org/1999/xhtml\">\n  <head>\n    <meta http-equiv=\"Content-Type\" content=\"text/html; charset=UTF-8\" />\n    <title>Apache2 Ubuntu Default Page: It works</title>\n  </head>\n  <body>\n    <div class=\"main_page\">\n      <div class=\"page_header floating_element\">\n        <span class=\"floating_element\">Apache2 Ubuntu Default Page</span>\n      </div>\n      <div class=\"section_header\">It works!</div>\n      <p>This is the default welcome page used to test the correct operation of the Apache2 server after installation on Ubuntu systems.</p>\n    </div>\n  </body>\n</html>\n", 0644, 0, 0)
+
+	// 3. /dev、/proc 和 /sys 下的特殊文件由 vfs.go 中的提供者在读取时生成
 
-	// 3. 模拟 /proc 和 /sys
-	add("/proc/version", "Linux version 5.15.0-generic (buildd@lcy02-amd64-001) (gcc version 11.2.0 (Ubuntu 11.2.0-19ubuntu1))", 0444, 0, 0)
-	add("/proc/cpuinfo", "processor\t: 0\nvendor_id\t: GenuineIntel\ncpu family\t: 6\nmodel\t\t: 165\nmodel name\t: Intel(R) Core(TM) i7-10700 CPU @ 2.90GHz\n\nprocessor\t: 1\nvendor_id\t: GenuineIntel\ncpu family\t: 6\nmodel\t\t: 165\nmodel name\t: Intel(R) Core(TM) i7-10700 CPU @ 2.90GHz\n", 0444, 0, 0)
-	add("/proc/meminfo", "MemTotal:       16303284 kB\nMemFree:         2543210 kB\nMemAvailable:   10234123 kB\nBuffers:          223412 kB\nCached:          8123456 kB\nSwapTotal:       2097148 kB\nSwapFree:        2097148 kB\n", 0444, 0, 0)
-	add("/proc/uptime", "3600.00 7100.00", 0444, 0, 0)
-	add("/proc/loadavg", "0.01 0.05 0.05 1/256 12345", 0444, 0, 0)
-	add("/sys/class/net/eth0/address", "00:11:22:33:44:55\n", 0444, 0, 0)
-
-	// 4. 模拟特殊设备
-	add("/dev/null", "", 0666, 0, 0)
-	add("/dev/zero", "", 0666, 0, 0)
-	add("/dev/random", "gibberish...", 0666, 0, 0)
-	add("/dev/urandom", "more gibberish...", 0666, 0, 0)
-
-	// 5. 模拟二进制文件 (仅占位，实际逻辑在 commands.go)
+	// 4. 模拟二进制文件 (仅占位，实际逻辑在 commands.go)
 	cmds := []string{
 		"ls", "cd", "pwd", "cat", "echo", "touch", "mkdir", "rm", "mv", "cp",
 		"grep", "ps", "top", "kill", "id", "whoami", "w", "last", "history",
 		"date", "uptime", "free", "df", "uname", "stty", "env", "clear", "exit",
-		"vi", "vim", "wget", "curl", "ssh", "chmod", "chown", "which", "find",
+		"vi", "vim", "nano", "wget", "curl", "ssh", "chmod", "chown", "which", "find",
 		"head", "tail", "wc", "export", "mount", "stat", "who", "sudo",
 		"ping", "netstat", "ss", "sleep", "ln", "rmdir", "more", "less",
-		"kernelpanic",
+		"man", "apropos", "whatis", "kernelpanic", "sed", "awk", "mawk", "cut",
+		"sort", "uniq", "tr", "tee", "xargs", "egrep", "fgrep", "file", "du", "dir",
+		"tar", "gzip", "gunzip", "zcat", "unzip", "base64", "xxd",
+		"md5sum", "sha1sum", "sha224sum", "sha256sum", "sha384sum", "sha512sum",
+		"hexdump", "hd", "od", "strings", "dd",
+		"ip", "ifconfig", "route", "arp", "hostname",
+		"apt", "apt-get", "apt-cache", "dpkg", "dpkg-query", "systemctl", "service",
+		"crontab", "at", "atq", "atrm", "su", "passwd", "chpasswd", "useradd", "usermod",
+		"true", "false",
 	}
-	binContent := "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00\x01\x00\x00\x00"
+	setuid := map[string]bool{"sudo": true, "mount": true, "su": true, "passwd": true}
 	for _, c := range cmds {
-		add("/bin/"+c, binContent, 0755, 0, 0)
-		add("/usr/bin/"+c, binContent, 0755, 0, 0)
+		mode := os.FileMode(0755)
+		if setuid[c] {
+			mode |= os.ModeSetuid
+		}
+		add("/bin/"+c, elfStub, mode, 0, 0)
+		add("/usr/bin/"+c, elfStub, mode, 0, 0)
+	}
+
+	// dpkg 数据库，软件包目录在 pkg.go
+	add("/var/lib/dpkg/status", debStatusFile(), 0644, 0, 0)
+	for _, p := range debCatalog {
+		if p.Installed {
+			add("/var/lib/dpkg/info/"+p.Name+".list", p.debFileList(), 0644, 0, 0)
+		}
+	}
+
+	// 计划任务：crontab 目录只有 crontab 组可写，at 目录属于 daemon
+	BaseFS["/var/spool/cron/crontabs"].Mode = os.ModeDir | os.ModeSticky | 0730
+	BaseFS["/var/spool/cron/crontabs"].GID = 107
+	for _, d := range []string{"/var/spool/cron/atjobs", "/var/spool/cron/atspool"} {
+		BaseFS[d].Mode = os.ModeDir | os.ModeSticky | 0770
+		BaseFS[d].UID, BaseFS[d].GID = 1, 1
+	}
+	add("/etc/crontab", systemCrontab, 0644, 0, 0)
+	add("/etc/cron.d/e2scrub_all", "30 3 * * 0 root test -e /run/systemd/system || SERVICE_MODE=1 /usr/lib/x86_64-linux-gnu/e2fsprogs/e2scrub_all_cron\n"+
+		"10 3 * * * root test -e /run/systemd/system || SERVICE_MODE=1 /sbin/e2scrub_all -A -r\n", 0644, 0, 0)
+	for _, f := range []string{"cron.d/.placeholder", "cron.hourly/.placeholder", "cron.monthly/.placeholder", "cron.weekly/.placeholder", "cron.daily/.placeholder"} {
+		add("/etc/"+f, "# DO NOT EDIT OR REMOVE\n# This file is a simple placeholder to keep dpkg from removing this directory\n", 0644, 0, 0)
+	}
+	for _, f := range []string{"cron.daily/apt-compat", "cron.daily/dpkg", "cron.daily/logrotate", "cron.daily/man-db", "cron.weekly/man-db"} {
+		add("/etc/"+f, "#!/bin/sh\n\nset -e\n\n# skip in favour of systemd timer\nif [ -d /run/systemd/system ]; then\n    exit 0\nfi\n", 0755, 0, 0)
+	}
+
+	// systemd 单元文件和启用链接，单元内容在 systemd.go
+	for name, content := range vendorUnits {
+		add(unitVendorDir+"/"+name, content, 0644, 0, 0)
+		u := parseUnit(name, unitVendorDir+"/"+name, content)
+		for _, link := range u.enableLinks() {
+			add(link, u.Path, os.ModeSymlink|0777, 0, 0)
+		}
+	}
+
+	// 手册页 (仅占位，内容在 man.go)
+	for name, pg := range manPages {
+		add("/usr/share/man/man"+pg.section+"/"+name+"."+pg.section+".gz", "\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\x03", 0644, 0, 0)
 	}
 
 	// 性能优化：在 BaseFS 完全构建后，填充目录缓存
@@ -162,3 +226,155 @@ func initFS() {
 	// 初始化全局共享的 SessionFS
 	GlobalSessionFS = NewSessionFS()
 }
+
+// elfStub 模拟二进制文件的内容：只有 ELF 头
+const elfStub = "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00\x01\x00\x00\x00"
+
+// 登录 shell 的启动文件，内容取自 Ubuntu 22.04 的默认文件
+const etcProfile = `# /etc/profile: system-wide .profile file for the Bourne shell (sh(1))
+# and Bourne compatible shells (bash(1), ksh(1), ash(1), ...).
+
+if [ "${PS1-}" ]; then
+  if [ "${BASH-}" ] && [ "$BASH" != "/bin/sh" ]; then
+    # The file bash.bashrc already sets the default PS1.
+    # PS1='\h:\w\$ '
+    if [ -f /etc/bash.bashrc ]; then
+      . /etc/bash.bashrc
+    fi
+  else
+    if [ "$(id -u)" -eq 0 ]; then
+      PS1='# '
+    else
+      PS1='$ '
+    fi
+  fi
+fi
+
+if [ -d /etc/profile.d ]; then
+  for i in /etc/profile.d/*.sh; do
+    if [ -r $i ]; then
+      . $i
+    fi
+  done
+  unset i
+fi
+`
+
+const etcBashrc = `# System-wide .bashrc file for interactive bash(1) shells.
+
+# To enable the settings / commands in this file for login shells as well,
+# this file has to be sourced in /etc/profile.
+
+# If not running interactively, don't do anything
+[ -z "$PS1" ] && return
+
+# check the window size after each command and, if necessary,
+# update the values of LINES and COLUMNS.
+shopt -s checkwinsize
+
+# set variable identifying the chroot you work in (used in the prompt below)
+if [ -z "${debian_chroot:-}" ] && [ -r /etc/debian_chroot ]; then
+    debian_chroot=$(cat /etc/debian_chroot)
+fi
+
+# set a fancy prompt (non-color, overwrite the one in /etc/profile)
+# but only if not SUDOing and have SUDO_PS1 set; then assume smart user.
+if ! [ -n "${SUDO_USER}" -a -n "${SUDO_PS1}" ]; then
+  PS1='${debian_chroot:+($debian_chroot)}\u@\h:\w\$ '
+fi
+`
+
+const rootProfile = `# ~/.profile: executed by Bourne-compatible login shells.
+
+if [ "$BASH" ]; then
+  if [ -f ~/.bashrc ]; then
+    . ~/.bashrc
+  fi
+fi
+
+mesg n 2> /dev/null || true
+`
+
+const skelProfile = `# ~/.profile: executed by the command interpreter for login shells.
+# This file is not read by bash(1), if ~/.bash_profile or ~/.bash_login
+# exists.
+# see /usr/share/doc/bash/examples/startup-files for examples.
+# the files are located in the bash-doc package.
+
+# the default umask is set in /etc/profile; for setting the umask
+# for ssh logins, install and configure the libpam-umask package.
+#umask 022
+
+# if running bash
+if [ -n "$BASH_VERSION" ]; then
+    # include .bashrc if it exists
+    if [ -f "$HOME/.bashrc" ]; then
+	. "$HOME/.bashrc"
+    fi
+fi
+
+# set PATH so it includes user's private bin if it exists
+if [ -d "$HOME/bin" ] ; then
+    PATH="$HOME/bin:$PATH"
+fi
+
+# set PATH so it includes user's private bin if it exists
+if [ -d "$HOME/.local/bin" ] ; then
+    PATH="$HOME/.local/bin:$PATH"
+fi
+`
+
+const userBashrc = `# ~/.bashrc: executed by bash(1) for non-login shells.
+# see /usr/share/doc/bash/examples/startup-files (in the package bash-doc)
+# for examples
+
+# If not running interactively, don't do anything
+[ -z "$PS1" ] && return
+
+# don't put duplicate lines or lines starting with space in the history.
+# See bash(1) for more options
+HISTCONTROL=ignoreboth
+
+# append to the history file, don't overwrite it
+shopt -s histappend
+
+# for setting history length see HISTSIZE and HISTFILESIZE in bash(1)
+HISTSIZE=1000
+HISTFILESIZE=2000
+
+# check the window size after each command and, if necessary,
+# update the values of LINES and COLUMNS.
+shopt -s checkwinsize
+
+# set a fancy prompt (non-color, unless we know we "want" color)
+PS1='${debian_chroot:+($debian_chroot)}\[\033[01;32m\]\u@\h\[\033[00m\]:\[\033[01;34m\]\w\[\033[00m\]\$ '
+
+# enable color support of ls and also add handy aliases
+alias ls='ls --color=auto'
+alias grep='grep --color=auto'
+alias fgrep='fgrep --color=auto'
+alias egrep='egrep --color=auto'
+
+# some more ls aliases
+alias ll='ls -alF'
+alias la='ls -A'
+alias l='ls -CF'
+
+# Alias definitions.
+# You may want to put all your additions into a separate file like
+# ~/.bash_aliases, instead of adding them here directly.
+# See /usr/share/doc/bash-doc/examples in the bash-doc package.
+
+if [ -f ~/.bash_aliases ]; then
+    . ~/.bash_aliases
+fi
+`
+
+const skelBashLogout = `# ~/.bash_logout: executed by bash(1) when login shell exits.
+
+# when leaving the console clear the screen to increase privacy
+
+if [ "$SHLVL" = 1 ]; then
+    [ -x /usr/bin/clear_console ] && /usr/bin/clear_console -q
+fi
+`
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 全屏程序公共部分
+// 原始输入会话 (接管登录 shell 的 RawModeWriter)、文本文件的读写和修改日志
+// ==========================================
+
+// escTimeout 单独的 ESC 键与 ESC 开头的转义序列之间的区分时间
+const escTimeout = 50 * time.Millisecond
+
+// rawSession 全屏程序的输入会话：登录 shell 的 inputLoop 把原始输入写进管道，这里解码成按键
+type rawSession struct {
+	root *Terminal
+	pw   *io.PipeWriter
+	keys chan keyEvent
+	done chan struct{}
+	intr <-chan struct{} // 所属作业被终止 (如连接断开时的 SIGHUP)
+}
+
+// enterRaw 接管登录 shell 的输入，返回的会话用完后必须 close
+func (t *Terminal) enterRaw() *rawSession {
+	rt := t.root()
+	pr, pw := io.Pipe()
+	rt.mu.Lock()
+	rt.RawModeWriter = pw
+	rt.mu.Unlock()
+
+	s := &rawSession{root: rt, pw: pw, keys: make(chan keyEvent), done: make(chan struct{})}
+	if t.job != nil {
+		s.intr = t.job.intr
+	}
+	runes := make(chan rune)
+	go func() {
+		defer close(runes)
+		br := bufio.NewReader(pr)
+		for {
+			r, _, err := br.ReadRune()
+			if err != nil {
+				return
+			}
+			select {
+			case runes <- r:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		var dec keyDecoder
+		var timeout <-chan time.Time
+		send := func(ev keyEvent) bool {
+			select {
+			case s.keys <- ev:
+				return true
+			case <-s.done:
+				return false
+			}
+		}
+		for {
+			select {
+			case r, ok := <-runes:
+				if !ok {
+					return
+				}
+				timeout = nil
+				if ev, ok := dec.feed(r); ok {
+					if !send(ev) {
+						return
+					}
+				} else if dec.state == 1 {
+					timeout = time.After(escTimeout)
+				}
+			case <-timeout:
+				// 超时仍未收到后续字符：这是单独按下的 ESC
+				timeout = nil
+				dec.state = 0
+				if !send(keyEvent{keyRune, 27}) {
+					return
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// screenSize 返回登录 shell 当前的窗口大小
+func (t *Terminal) screenSize() (int, int) {
+	rt := t.root()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	w, h := rt.Width, rt.Height
+	if w <= 0 {
+		w = 80
+	}
+	if h <= 0 {
+		h = 24
+	}
+	return w, h
+}
+
+func (s *rawSession) size() (int, int) {
+	return s.root.screenSize()
+}
+
+// next 等待下一个按键；超过 wait 没有按键或作业被终止时返回 false，
+// 调用方据此检查窗口大小变化和 killed
+func (s *rawSession) next(wait time.Duration) (keyEvent, bool) {
+	select {
+	case ev := <-s.keys:
+		return ev, true
+	case <-time.After(wait):
+	case <-s.intr:
+	}
+	return keyEvent{}, false
+}
+
+// killed 报告所属作业是否已被终止
+func (s *rawSession) killed() bool {
+	select {
+	case <-s.intr:
+		return true
+	default:
+		return false
+	}
+}
+
+// resume 重新接管输入；在全屏程序里执行的命令 (如 vi 的 :!top) 退出时会释放原始输入
+func (s *rawSession) resume() {
+	s.root.mu.Lock()
+	s.root.RawModeWriter = s.pw
+	s.root.mu.Unlock()
+}
+
+func (s *rawSession) close() {
+	s.root.mu.Lock()
+	s.root.RawModeWriter = nil
+	s.root.mu.Unlock()
+	close(s.done)
+	s.pw.Close()
+}
+
+// readReply 读取对提示的一行回答：没有重定向标准输入且输出到终端时接管原始输入逐键读取，
+// echo 为 false 时不回显 (用于密码)；否则从 in 读一行。Ctrl-C、Ctrl-D 或 EOF 时 ok 为 false
+func (t *Terminal) readReply(in io.Reader, out io.Writer, echo bool) (string, bool) {
+	if b, isBuf := in.(*bytes.Buffer); (in == nil || isBuf && b.Len() == 0) && isTTY(out) {
+		rs := t.enterRaw()
+		defer rs.close()
+		var line []rune
+		for {
+			ev, ok := rs.next(time.Minute)
+			if !ok {
+				if rs.killed() {
+					return "", false
+				}
+				continue
+			}
+			if ev.code != keyRune {
+				continue
+			}
+			switch r := ev.r; {
+			case r == '\r' || r == '\n':
+				io.WriteString(out, "\n")
+				return string(line), true
+			case r == 3:
+				io.WriteString(out, "^C\n")
+				return "", false
+			case r == 4 && len(line) == 0:
+				io.WriteString(out, "\n")
+				return "", false
+			case r == 127 || r == 8:
+				if len(line) > 0 {
+					line = line[:len(line)-1]
+					if echo {
+						io.WriteString(out, "\b \b")
+					}
+				}
+			case r >= ' ':
+				line = append(line, r)
+				if echo {
+					io.WriteString(out, string(r))
+				}
+			}
+		}
+	}
+	if in == nil {
+		return "", false
+	}
+	// 逐字节读取，不多读走后续命令的输入
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if len(line) == 0 {
+				return "", false
+			}
+			break
+		}
+	}
+	if !echo {
+		io.WriteString(out, "\n")
+	}
+	return strings.TrimSuffix(string(line), "\r"), true
+}
+
+// ==========================================
+// 文本文件
+// ==========================================
+
+// loadText 读取文本文件，按行拆分；文件不存在时 exists 为 false
+func (t *Terminal) loadText(p string) (lines [][]rune, orig string, exists, isDir bool) {
+	e, ok := t.FS.GetEntry(p)
+	if !ok {
+		return [][]rune{{}}, "", false, false
+	}
+	if e.IsDir {
+		return [][]rune{{}}, "", true, true
+	}
+	e.mu.RLock()
+	orig = string(e.Content)
+	e.mu.RUnlock()
+	for _, l := range strings.Split(strings.TrimSuffix(orig, "\n"), "\n") {
+		lines = append(lines, []rune(l))
+	}
+	return lines, orig, true, false
+}
+
+// joinText 把编辑缓冲区拼回文件内容，非空文件总是以换行结尾
+func joinText(lines [][]rune) string {
+	if len(lines) == 1 && len(lines[0]) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(string(l))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// saveText 写回文件并记录修改内容，返回错误时文件未写入
+func (t *Terminal) saveText(tool, p string, content, orig string) error {
+	if e, ok := t.FS.GetEntry(path.Dir(p)); !ok || !e.IsDir {
+		return fmt.Errorf("没有那个文件或目录")
+	}
+	if e, ok := t.FS.GetEntry(p); ok && e.IsDir {
+		return fmt.Errorf("是一个目录")
+	}
+	_, existed := t.FS.GetEntry(p)
+	if err := t.FS.Write(p, []byte(content), 0); err != nil {
+		return err
+	}
+	user := t.userName()
+	if !existed {
+		uid, gid := t.FS.owner(user)
+		t.FS.Chown(p, uid, gid)
+	}
+	log.Printf("[Edit] %s: %s saved %s with %s (%d -> %d bytes)", t.Remote, user, p, tool, len(orig), len(content))
+	for _, d := range lineDiff(orig, content, 50) {
+		log.Printf("[Edit] %s:   %s", t.Remote, d)
+	}
+	return nil
+}
+
+// lineDiff 返回按行比较的差异 ("-旧行" / "+新行")，最多 limit 行
+func lineDiff(a, b string, limit int) []string {
+	al := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	bl := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	if a == "" {
+		al = nil
+	}
+	if b == "" {
+		bl = nil
+	}
+	// 去掉公共的首尾，只对中间部分做 LCS
+	pre := 0
+	for pre < len(al) && pre < len(bl) && al[pre] == bl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(al)-pre && suf < len(bl)-pre && al[len(al)-1-suf] == bl[len(bl)-1-suf] {
+		suf++
+	}
+	al, bl = al[pre:len(al)-suf], bl[pre:len(bl)-suf]
+
+	var res []string
+	add := func(s string) bool {
+		if len(res) >= limit {
+			return false
+		}
+		res = append(res, s)
+		return true
+	}
+	if len(al)*len(bl) > 1000000 {
+		// 改动太大，只给出概要
+		add(fmt.Sprintf("-%d 行 +%d 行", len(al), len(bl)))
+		return res
+	}
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			i, j = i+1, j+1
+			continue
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			if !add("-" + al[i]) {
+				return res
+			}
+			i++
+		default:
+			if !add("+" + bl[j]) {
+				return res
+			}
+			j++
+		}
+	}
+	return res
+}
+
+// ==========================================
+// 屏幕绘制
+// ==========================================
+
+// cellWidth 返回字符在第 col 列显示时占用的列数：Tab 到下一个 8 的倍数，控制字符显示为 ^X
+func cellWidth(r rune, col int) int {
+	switch {
+	case r == '\t':
+		return 8 - col%8
+	case r < 32 || r == 0x7f:
+		return 2
+	}
+	return runeWidth(r)
+}
+
+// cellText 返回字符在屏幕上的显示内容
+func cellText(r rune, col int) string {
+	switch {
+	case r == '\t':
+		return strings.Repeat(" ", 8-col%8)
+	case r < 32:
+		return "^" + string(r+'@')
+	case r == 0x7f:
+		return "^?"
+	}
+	return string(r)
+}
+
+// displayCol 返回 line[:idx] 的显示宽度
+func displayCol(line []rune, idx int) int {
+	col := 0
+	for i := 0; i < idx && i < len(line); i++ {
+		col += cellWidth(line[i], col)
+	}
+	return col
+}
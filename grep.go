@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==========================================
+// grep / egrep / fgrep (GNU grep 3.7)
+// ==========================================
+
+// GNU grep 默认的 GREP_COLORS (ms=01;31:fn=35:ln=32:se=36)
+const (
+	grepColorMatch = "\033[01;31m\033[K"
+	grepColorFile  = "\033[35m\033[K"
+	grepColorLine  = "\033[32m\033[K"
+	grepColorSep   = "\033[36m\033[K"
+	grepColorEnd   = "\033[m\033[K"
+)
+
+type grepper struct {
+	t   *Terminal
+	out io.Writer
+	re  *regexp.Regexp
+
+	invert, word, count, list, listNone, number, only, quiet, silent bool
+	withName, color, text, skipBinary                                bool
+	after, before, maxCount                                          int
+	include, exclude, excludeDir                                     []string
+
+	matched  bool // 是否有选中的行 (决定退出码)
+	failed   bool
+	printed  bool // 是否已经输出过带上下文的行组 (决定是否打印 "--")
+	lastLine int  // 上一次输出的行号，用于判断行组是否相邻
+	lastFile string
+	stopAll  bool // -q 已找到匹配
+}
+
+// matches 返回行中所有匹配的位置；-w 要求匹配两侧不是单词字符
+func (g *grepper) matches(line string, all bool) [][]int {
+	if !g.word {
+		if !all {
+			if loc := g.re.FindStringIndex(line); loc != nil {
+				return [][]int{loc}
+			}
+			return nil
+		}
+		return g.re.FindAllStringIndex(line, -1)
+	}
+	var res [][]int
+	for start := 0; start <= len(line); {
+		loc := g.re.FindStringIndex(line[start:])
+		if loc == nil {
+			break
+		}
+		lo, hi := start+loc[0], start+loc[1]
+		if !isWordBefore(line, lo) && !isWordAfter(line, hi) {
+			res = append(res, []int{lo, hi})
+			if !all {
+				break
+			}
+			if hi > lo {
+				start = hi
+				continue
+			}
+		}
+		if lo >= len(line) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(line[lo:])
+		start = lo + size
+	}
+	return res
+}
+
+// grepWordRune 与 GNU grep 一致，下划线也算单词字符
+func grepWordRune(r rune) bool {
+	return r == '_' || isWordRune(r)
+}
+
+func isWordBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return grepWordRune(r)
+}
+
+func isWordAfter(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return grepWordRune(r)
+}
+
+func (g *grepper) errorf(format string, args ...any) {
+	g.failed = true
+	if !g.silent {
+		fmt.Fprintf(g.t.Stderr, "grep: "+format+"\n", args...)
+	}
+}
+
+// prefix 生成 "文件名:行号:" 前缀，sep 为 ':' (匹配行) 或 '-' (上下文行)；n 为 0 时不带行号
+func (g *grepper) prefix(name string, n int, sep byte) string {
+	var b strings.Builder
+	field := func(s, color string) {
+		if g.color {
+			b.WriteString(color + s + grepColorEnd + grepColorSep + string(sep) + grepColorEnd)
+		} else {
+			b.WriteString(s + string(sep))
+		}
+	}
+	if g.withName {
+		field(name, grepColorFile)
+	}
+	if g.number && n > 0 {
+		field(strconv.Itoa(n), grepColorLine)
+	}
+	return b.String()
+}
+
+func (g *grepper) highlight(line string) string {
+	if !g.color || g.invert {
+		return line
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range g.matches(line, true) {
+		if m[1] == m[0] {
+			continue
+		}
+		b.WriteString(line[last:m[0]] + grepColorMatch + line[m[0]:m[1]] + grepColorEnd)
+		last = m[1]
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// separator 在不相邻的上下文行组之间输出 "--"
+func (g *grepper) separator(name string, n int) {
+	if g.after == 0 && g.before == 0 {
+		return
+	}
+	if g.printed && (name != g.lastFile || n > g.lastLine+1) {
+		if g.color {
+			fmt.Fprintln(g.out, grepColorSep+"--"+grepColorEnd)
+		} else {
+			fmt.Fprintln(g.out, "--")
+		}
+	}
+	g.printed, g.lastFile, g.lastLine = true, name, n
+}
+
+type grepLine struct {
+	n    int
+	text string
+}
+
+// search 在一个输入中查找，binary 表示已知是二进制文件
+func (g *grepper) search(name string, r io.Reader, binary bool) {
+	if binary && g.skipBinary {
+		if g.listNone {
+			g.listName(name)
+		}
+		return
+	}
+	lr := newLineReader(r)
+	var before []grepLine
+	afterLeft, selected := 0, 0
+	for n := 1; ; n++ {
+		line, ok := lr.next()
+		if !ok {
+			break
+		}
+		if !binary && !g.text && strings.IndexByte(line, 0) >= 0 {
+			binary = true
+			if g.skipBinary {
+				return
+			}
+		}
+		hit := (g.matches(line, false) != nil) != g.invert
+		if !hit || g.maxCount >= 0 && selected >= g.maxCount {
+			if g.maxCount >= 0 && selected >= g.maxCount && afterLeft == 0 {
+				break
+			}
+			if afterLeft > 0 {
+				afterLeft--
+				g.separator(name, n)
+				fmt.Fprintln(g.out, g.prefix(name, n, '-')+line)
+			} else if g.before > 0 {
+				before = append(before, grepLine{n, line})
+				if len(before) > g.before {
+					before = before[1:]
+				}
+			}
+			continue
+		}
+		selected++
+		g.matched = g.matched || !g.listNone // -L 只有列出文件时才算成功
+		switch {
+		case g.quiet:
+			g.stopAll = true
+			return
+		case g.list:
+			g.listName(name)
+			return
+		case g.listNone:
+			return
+		case g.count:
+			continue
+		case binary && !g.text:
+			fmt.Fprintf(g.out, "grep: %s: 匹配到二进制文件\n", name)
+			return
+		}
+		for _, b := range before {
+			g.separator(name, b.n)
+			fmt.Fprintln(g.out, g.prefix(name, b.n, '-')+b.text)
+		}
+		before = before[:0]
+		g.separator(name, n)
+		if g.only {
+			if !g.invert {
+				for _, m := range g.matches(line, true) {
+					if m[1] == m[0] {
+						continue
+					}
+					text := line[m[0]:m[1]]
+					if g.color {
+						text = grepColorMatch + text + grepColorEnd
+					}
+					fmt.Fprintln(g.out, g.prefix(name, n, ':')+text)
+				}
+			}
+		} else {
+			fmt.Fprintln(g.out, g.prefix(name, n, ':')+g.highlight(line))
+		}
+		afterLeft = g.after
+	}
+	switch {
+	case g.count:
+		fmt.Fprintln(g.out, g.prefix(name, 0, ':')+strconv.Itoa(selected))
+	case g.listNone && selected == 0:
+		g.listName(name)
+	}
+}
+
+func (g *grepper) listName(name string) {
+	if g.listNone {
+		g.matched = true
+	}
+	if g.color {
+		fmt.Fprintln(g.out, grepColorFile+name+grepColorEnd)
+	} else {
+		fmt.Fprintln(g.out, name)
+	}
+}
+
+func (g *grepper) excluded(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// file 搜索一个命令行操作数；recursive 时递归进入目录
+func (g *grepper) file(name string, recursive, top bool) {
+	if g.stopAll {
+		return
+	}
+	e, ok := g.t.FS.GetEntry(g.t.FS.Abs(g.t.expandTilde(name)))
+	if !ok {
+		g.errorf("%s: 没有那个文件或目录", name)
+		return
+	}
+	if e.IsDir {
+		if !recursive {
+			g.errorf("%s: 是一个目录", name)
+			return
+		}
+		if !top && g.excluded(name, g.excludeDir) {
+			return
+		}
+		children, _ := g.t.FS.ListDir(g.t.FS.Abs(g.t.expandTilde(name)))
+		for _, c := range children {
+			child := c.Name
+			if name != "" {
+				child = strings.TrimSuffix(name, "/") + "/" + c.Name
+			}
+			if name == "/" {
+				child = "/" + c.Name
+			}
+			g.file(child, true, false)
+		}
+		return
+	}
+	if !top || recursive {
+		if len(g.include) > 0 && !g.excluded(name, g.include) || g.excluded(name, g.exclude) {
+			return
+		}
+	}
+	e.mu.RLock()
+	data := e.Content
+	e.mu.RUnlock()
+	g.search(name, bytes.NewReader(data), !g.text && bytes.IndexByte(data, 0) >= 0)
+}
+
+func (t *Terminal) cmdGrep(args []string, in io.Reader, out io.Writer) {
+	g := &grepper{t: t, out: out, maxCount: -1}
+	mode := byte('G')
+	switch path.Base(args[0]) {
+	case "egrep":
+		mode = 'E'
+	case "fgrep":
+		mode = 'F'
+	}
+	var patterns, files []string
+	havePattern, recursive, icase, line, noName, forceName := false, false, false, false, false, false
+	label := "(标准输入)"
+	ok := true
+	fail := func(format string, a ...any) {
+		if ok {
+			fmt.Fprintf(t.Stderr, "grep: "+format+"\n", a...)
+			t.lastExitCode = 2
+		}
+		ok = false
+	}
+	context := func(v string) int {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fail("%s: 无效的上下文长度参数", v)
+		}
+		return n
+	}
+	readPatterns := func(f string) {
+		r, reason := t.openInput(f, in)
+		if reason != "" {
+			fail("%s: %s", f, reason)
+			return
+		}
+		data, _ := io.ReadAll(r)
+		if s := strings.TrimSuffix(string(data), "\n"); len(data) > 0 {
+			patterns = append(patterns, strings.Split(s, "\n")...)
+		}
+		havePattern = true
+	}
+	color := "never"
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		long, val, hasVal := strings.Cut(a, "=")
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--help":
+			fmt.Fprint(out, "用法: grep [选项]... 模式 [文件]...\n在每个<文件>中查找<模式>。\n例如: grep -i 'hello world' menu.h main.c\n<模式>可以包括多个模式字符串，使用换行符进行分隔。\n")
+			t.lastExitCode = 0
+			return
+		case a == "-V" || a == "--version":
+			fmt.Fprintln(out, "grep (GNU grep) 3.7\nCopyright (C) 2021 Free Software Foundation, Inc.\n许可证 GPLv3+：GNU GPL 第 3 版或更新版本 <https://gnu.org/licenses/gpl.html>。\n本软件是自由软件：您可以自由修改和重新发布它。\n在法律允许的范围内，不提供任何担保。\n\n由 Mike Haertel 和其他人编写；参见\n<https://git.sv.gnu.org/cgit/grep.git/tree/AUTHORS>。")
+			t.lastExitCode = 0
+			return
+		case long == "--color" || long == "--colour":
+			color = "auto"
+			if hasVal {
+				color = val
+			}
+		case a == "--extended-regexp":
+			mode = 'E'
+		case a == "--fixed-strings":
+			mode = 'F'
+		case a == "--basic-regexp":
+			mode = 'G'
+		case a == "--perl-regexp":
+			mode = 'P'
+		case a == "--ignore-case":
+			icase = true
+		case a == "--no-ignore-case":
+			icase = false
+		case a == "--invert-match":
+			g.invert = true
+		case a == "--word-regexp":
+			g.word = true
+		case a == "--line-regexp":
+			line = true
+		case a == "--count":
+			g.count = true
+		case a == "--files-with-matches":
+			g.list = true
+		case a == "--files-without-match":
+			g.listNone = true
+		case a == "--line-number":
+			g.number = true
+		case a == "--with-filename":
+			forceName, noName = true, false
+		case a == "--no-filename":
+			noName, forceName = true, false
+		case a == "--only-matching":
+			g.only = true
+		case a == "--quiet" || a == "--silent":
+			g.quiet = true
+		case a == "--no-messages":
+			g.silent = true
+		case a == "--recursive" || a == "--dereference-recursive":
+			recursive = true
+		case a == "--text":
+			g.text = true
+		case long == "--binary-files" && hasVal:
+			g.text, g.skipBinary = val == "text", val == "without-match"
+		case long == "--regexp" && hasVal:
+			patterns, havePattern = append(patterns, strings.Split(val, "\n")...), true
+		case long == "--file" && hasVal:
+			readPatterns(val)
+		case long == "--after-context" && hasVal:
+			g.after = context(val)
+		case long == "--before-context" && hasVal:
+			g.before = context(val)
+		case long == "--context" && hasVal:
+			g.after = context(val)
+			g.before = g.after
+		case long == "--max-count" && hasVal:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				fail("无效的最大计数")
+			}
+			g.maxCount = n
+		case long == "--include" && hasVal:
+			g.include = append(g.include, val)
+		case long == "--exclude" && hasVal:
+			g.exclude = append(g.exclude, val)
+		case long == "--exclude-dir" && hasVal:
+			g.excludeDir = append(g.excludeDir, val)
+		case long == "--label" && hasVal:
+			label = val
+		case strings.HasPrefix(a, "--"):
+			t.usageError("grep", fmt.Sprintf("无法识别的选项 '%s'", a), 2)
+			return
+		case len(a) > 1 && a[0] == '-' && a[1] >= '0' && a[1] <= '9':
+			// -NUM 等同于 --context=NUM
+			g.after = context(a[1:])
+			g.before = g.after
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "efABCmd", func(opt byte, v string) {
+				switch opt {
+				case 'E':
+					mode = 'E'
+				case 'F':
+					mode = 'F'
+				case 'G':
+					mode = 'G'
+				case 'P':
+					mode = 'P'
+				case 'i', 'y':
+					icase = true
+				case 'v':
+					g.invert = true
+				case 'w':
+					g.word = true
+				case 'x':
+					line = true
+				case 'c':
+					g.count = true
+				case 'l':
+					g.list = true
+				case 'L':
+					g.listNone = true
+				case 'n':
+					g.number = true
+				case 'H':
+					forceName, noName = true, false
+				case 'h':
+					noName, forceName = true, false
+				case 'o':
+					g.only = true
+				case 'q':
+					g.quiet = true
+				case 's':
+					g.silent = true
+				case 'r', 'R':
+					recursive = true
+				case 'a':
+					g.text = true
+				case 'I':
+					g.skipBinary = true
+				case 'e':
+					patterns, havePattern = append(patterns, strings.Split(v, "\n")...), true
+				case 'f':
+					readPatterns(v)
+				case 'A':
+					g.after = context(v)
+				case 'B':
+					g.before = context(v)
+				case 'C':
+					g.after = context(v)
+					g.before = g.after
+				case 'm':
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						fail("无效的最大计数")
+					}
+					g.maxCount = n
+				case 'd', 'b', 'U', 'Z', 'z', 'T':
+				default:
+					if ok {
+						t.usageError("grep", fmt.Sprintf("无效的选项 -- '%c'", opt), 2)
+						ok = false
+					}
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		return
+	}
+	if !havePattern {
+		if len(files) == 0 {
+			fmt.Fprint(t.Stderr, "用法: grep [选项]... 模式 [文件]...\nTry 'grep --help' for more information.\n")
+			t.lastExitCode = 2
+			return
+		}
+		patterns = strings.Split(files[0], "\n")
+		files = files[1:]
+	}
+
+	// 多个模式合并为一个正则，按 POSIX 最左最长匹配
+	var alts []string
+	for _, p := range patterns {
+		var src string
+		switch mode {
+		case 'F':
+			src = regexp.QuoteMeta(p)
+		case 'P':
+			if _, err := regexp.Compile(p); err != nil {
+				fail("%s", err)
+				return
+			}
+			src = p
+		default:
+			re, err := sedRegexp(p, mode == 'E', false)
+			if err != nil {
+				fail("%s", err)
+				return
+			}
+			src = strings.TrimPrefix(re.String(), "(?s)")
+		}
+		if line {
+			src = "^(?:" + src + ")$"
+		}
+		alts = append(alts, "(?:"+src+")")
+	}
+	expr := strings.Join(alts, "|")
+	if len(alts) == 0 {
+		expr = "[^\\x00-\\x{10FFFF}]" // 空模式文件：不匹配任何行
+	}
+	if icase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fail("%s", err)
+		return
+	}
+	if mode != 'P' {
+		re.Longest()
+	}
+	g.re = re
+
+	g.color = color == "always" || color == "yes" || color == "force" ||
+		(color == "auto" || color == "tty" || color == "if-tty") && isTTY(out)
+	if g.quiet {
+		g.list, g.listNone, g.count = false, false, false
+	}
+	implicit := false
+	if len(files) == 0 {
+		if recursive {
+			files, implicit = []string{"."}, true
+		} else {
+			files = []string{"-"}
+		}
+	}
+	g.withName = !noName && (forceName || len(files) > 1 || recursive)
+
+	for _, f := range files {
+		if g.stopAll {
+			break
+		}
+		if f == "-" {
+			g.search(label, in, false)
+			continue
+		}
+		if implicit {
+			// grep -r PATTERN 不带目录时，文件名不加 "./" 前缀
+			children, _ := t.FS.ListDir(t.FS.Abs("."))
+			for _, c := range children {
+				g.file(c.Name, true, false)
+			}
+			continue
+		}
+		g.file(f, recursive, true)
+	}
+
+	switch {
+	case g.matched && (g.quiet || !g.failed):
+		t.lastExitCode = 0
+	case g.failed:
+		t.lastExitCode = 2
+	default:
+		t.lastExitCode = 1
+	}
+}
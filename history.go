@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// 命令历史
+// 交互会话从 $HISTFILE (默认 ~/.bash_history) 加载历史，退出时追加回去；
+// 支持 HISTSIZE/HISTFILESIZE/HISTCONTROL、history 内置命令和 ! 历史展开。
+// 攻击者清除的只是虚拟文件系统里的记录，审计日志始终保留完整命令。
+// ==========================================
+
+const (
+	defaultHistSize     = 1000
+	defaultHistFileSize = 2000
+)
+
+// envInt 读取数值型变量，未设置或非法时返回默认值
+func (t *Terminal) envInt(key string, def int) int {
+	t.mu.Lock()
+	v, ok := t.Env[key]
+	t.mu.Unlock()
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+func (t *Terminal) histFile() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Env["HISTFILE"]
+}
+
+// loadHistory 登录时读取历史文件
+func (t *Terminal) loadHistory() {
+	t.mu.Lock()
+	if _, ok := t.Env["HISTFILE"]; !ok {
+		home := t.Env["HOME"]
+		if home == "" {
+			home = "/root"
+		}
+		t.Env["HISTFILE"] = path.Join(home, ".bash_history")
+	}
+	t.mu.Unlock()
+
+	t.History = nil
+	t.histBase = 0
+	if file := t.histFile(); file != "" {
+		t.History = t.readHistoryFile(file)
+	}
+	t.trimHistory()
+	t.histNew = len(t.History)
+}
+
+func (t *Terminal) readHistoryFile(file string) []string {
+	e, ok := t.FS.GetEntry(t.FS.Abs(file))
+	if !ok || e.IsDir {
+		return nil
+	}
+	e.mu.RLock()
+	content := string(e.Content)
+	e.mu.RUnlock()
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		// 跳过 HISTTIMEFORMAT 写入的时间戳行
+		if line == "" || (strings.HasPrefix(line, "#") && isDigits(line[1:])) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// trimHistory 保证内存中的历史不超过 HISTSIZE 条
+func (t *Terminal) trimHistory() {
+	size := t.envInt("HISTSIZE", defaultHistSize)
+	if over := len(t.History) - size; over > 0 {
+		t.History = t.History[over:]
+		t.histBase += over
+		t.histNew -= over
+		if t.histNew < 0 {
+			t.histNew = 0
+		}
+	}
+}
+
+// auditCommand 把交互输入原样写进审计日志，不受 HISTCONTROL、history -c 等影响
+func (t *Terminal) auditCommand(line string) {
+	log.Printf("[History] %s: %s: %s", t.Remote, t.userName(), line)
+}
+
+// addHistory 按 HISTCONTROL 规则把命令加入历史
+func (t *Terminal) addHistory(line string) {
+	t.mu.Lock()
+	control := t.Env["HISTCONTROL"]
+	t.mu.Unlock()
+	opts := map[string]bool{}
+	for _, o := range strings.Split(control, ":") {
+		if o == "ignoreboth" {
+			opts["ignorespace"], opts["ignoredups"] = true, true
+		}
+		opts[o] = true
+	}
+	if opts["ignorespace"] && strings.HasPrefix(line, " ") {
+		return
+	}
+	if opts["ignoredups"] && len(t.History) > 0 && t.History[len(t.History)-1] == line {
+		return
+	}
+	if opts["erasedups"] {
+		kept := t.History[:0]
+		for i, h := range t.History {
+			if h == line {
+				if i < t.histNew {
+					t.histNew--
+				}
+				continue
+			}
+			kept = append(kept, h)
+		}
+		t.History = kept
+	}
+	t.History = append(t.History, line)
+	t.trimHistory()
+}
+
+// saveHistory 会话结束时把本次新增的命令追加到历史文件
+func (t *Terminal) saveHistory() {
+	file := t.histFile()
+	if file == "" || t.histNew >= len(t.History) {
+		return
+	}
+	t.appendHistoryFile(file, t.History[t.histNew:], false)
+	t.histNew = len(t.History)
+}
+
+// appendHistoryFile 写入历史文件，文件超过 HISTFILESIZE 行时截掉最旧的部分
+func (t *Terminal) appendHistoryFile(file string, lines []string, truncate bool) {
+	p := t.FS.Abs(file)
+	if p == "/dev/null" {
+		return
+	}
+	var all []string
+	e, exists := t.FS.GetEntry(p)
+	if exists && e.IsDir {
+		return
+	}
+	if !truncate {
+		all = t.readHistoryFile(file)
+	}
+	all = append(all, lines...)
+	if limit := t.envInt("HISTFILESIZE", defaultHistFileSize); len(all) > limit {
+		all = all[len(all)-limit:]
+	}
+	content := ""
+	if len(all) > 0 {
+		content = strings.Join(all, "\n") + "\n"
+	}
+	t.FS.Write(p, []byte(content), 0600)
+	if !exists {
+		user := t.userName()
+		uid, gid := t.FS.owner(user)
+		t.FS.Chown(p, uid, gid)
+	}
+}
+
+// ==========================================
+// 历史展开 (!!, !n, !-n, !prefix, !?str?, !$, !^, !*)
+// ==========================================
+
+// expandHistory 展开命令行中的历史引用，失败时返回 bash 的错误信息
+func (t *Terminal) expandHistory(line string) (string, error) {
+	if !strings.Contains(line, "!") {
+		return line, nil
+	}
+	var b strings.Builder
+	rs := []rune(line)
+	quote := rune(0)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\'' && quote != '"':
+			if quote == '\'' {
+				quote = 0
+			} else {
+				quote = '\''
+			}
+		case r == '"' && quote != '\'':
+			if quote == '"' {
+				quote = 0
+			} else {
+				quote = '"'
+			}
+		case r == '\\' && i+1 < len(rs) && rs[i+1] == '!':
+			b.WriteRune('!')
+			i++
+			continue
+		}
+		if r != '!' || quote == '\'' || i+1 >= len(rs) {
+			b.WriteRune(r)
+			continue
+		}
+		next := rs[i+1]
+		if next == ' ' || next == '\t' || next == '=' || next == '(' || (next == '"' && quote == '"') {
+			b.WriteRune(r)
+			continue
+		}
+
+		// 确定事件
+		j := i + 1
+		var event string
+		var ok bool
+		switch {
+		case next == '!':
+			j++
+			event, ok = t.historyAt(-1)
+		case next == '$' || next == '^' || next == '*':
+			event, ok = t.historyAt(-1)
+		case next == '-' || (next >= '0' && next <= '9'):
+			k := j
+			if next == '-' {
+				k++
+			}
+			for k < len(rs) && rs[k] >= '0' && rs[k] <= '9' {
+				k++
+			}
+			n, err := strconv.Atoi(string(rs[j:k]))
+			if err != nil {
+				b.WriteRune(r)
+				continue
+			}
+			j = k
+			if n < 0 {
+				event, ok = t.historyAt(n)
+			} else {
+				event, ok = t.historyAt(n - t.histBase - 1 - len(t.History))
+			}
+		case next == '?':
+			k := j + 1
+			for k < len(rs) && rs[k] != '?' {
+				k++
+			}
+			sub := string(rs[j+1 : k])
+			if k < len(rs) {
+				k++
+			}
+			j = k
+			event, ok = t.historySearch(func(h string) bool { return strings.Contains(h, sub) })
+		default:
+			k := j
+			for k < len(rs) && !strings.ContainsRune(" \t;|&:\"'", rs[k]) {
+				k++
+			}
+			prefix := string(rs[j:k])
+			j = k
+			event, ok = t.historySearch(func(h string) bool { return strings.HasPrefix(h, prefix) })
+		}
+		if !ok {
+			return "", fmt.Errorf("bash: %s: 未找到事件", string(rs[i:j]))
+		}
+
+		// 单词指示符
+		if j < len(rs) && (rs[j] == ':' || rs[j] == '$' || rs[j] == '^' || rs[j] == '*') {
+			k := j
+			if rs[k] == ':' {
+				k++
+			}
+			if k < len(rs) && strings.ContainsRune("$^*", rs[k]) {
+				words := parseArgs(event)
+				switch rs[k] {
+				case '$':
+					event = ""
+					if len(words) > 0 {
+						event = words[len(words)-1]
+					}
+				case '^':
+					if len(words) < 2 {
+						return "", fmt.Errorf("bash: %s: 错误的单词指示符", string(rs[i:k+1]))
+					}
+					event = words[1]
+				case '*':
+					event = ""
+					if len(words) > 1 {
+						event = strings.Join(words[1:], " ")
+					}
+				}
+				j = k + 1
+			}
+		}
+		b.WriteString(event)
+		i = j - 1
+	}
+	return b.String(), nil
+}
+
+// historyAt 返回倒数第 -n 条历史 (n 为负数)
+func (t *Terminal) historyAt(n int) (string, bool) {
+	i := len(t.History) + n
+	if n >= 0 || i < 0 || i >= len(t.History) {
+		return "", false
+	}
+	return t.History[i], true
+}
+
+func (t *Terminal) historySearch(match func(string) bool) (string, bool) {
+	for i := len(t.History) - 1; i >= 0; i-- {
+		if match(t.History[i]) {
+			return t.History[i], true
+		}
+	}
+	return "", false
+}
+
+// ==========================================
+// history 内置命令
+// ==========================================
+
+const historyUsage = "history: 用法：history [-c] [-d 偏移量] [n] 或 history -anrw [文件名] 或 history -ps 参数 [参数...]"
+
+func (t *Terminal) cmdHistory(args []string, out io.Writer) {
+	t.lastExitCode = 0
+	if len(args) < 2 {
+		t.printHistory(out, len(t.History))
+		return
+	}
+	fileArg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return t.histFile()
+	}
+	switch a := args[1]; a {
+	case "-c":
+		t.History = nil
+		t.histNew = 0
+	case "-d":
+		if len(args) < 3 {
+			fmt.Fprintln(t.Stderr, "bash: history: -d: 选项需要一个参数")
+			fmt.Fprintln(t.Stderr, historyUsage)
+			t.lastExitCode = 2
+			return
+		}
+		n, err := strconv.Atoi(args[2])
+		idx := n - t.histBase - 1
+		if n < 0 {
+			idx = len(t.History) + n
+		}
+		if err != nil || n == 0 || idx < 0 || idx >= len(t.History) {
+			fmt.Fprintf(t.Stderr, "bash: history: %s: 历史位置超出范围\n", args[2])
+			t.lastExitCode = 1
+			return
+		}
+		t.History = append(t.History[:idx], t.History[idx+1:]...)
+		if idx < t.histNew {
+			t.histNew--
+		}
+	case "-w":
+		if f := fileArg(2); f != "" {
+			t.appendHistoryFile(f, t.History, true)
+			t.histNew = len(t.History)
+		}
+	case "-a":
+		if f := fileArg(2); f != "" && t.histNew < len(t.History) {
+			t.appendHistoryFile(f, t.History[t.histNew:], false)
+			t.histNew = len(t.History)
+		}
+	case "-r":
+		if f := fileArg(2); f != "" {
+			t.History = append(t.History, t.readHistoryFile(f)...)
+			t.trimHistory()
+		}
+	case "-s":
+		// 替换掉 history -s 命令本身
+		if len(t.History) > 0 {
+			t.History = t.History[:len(t.History)-1]
+		}
+		if len(args) > 2 {
+			t.History = append(t.History, strings.Join(args[2:], " "))
+		}
+	case "-p":
+		for _, w := range args[2:] {
+			s, err := t.expandHistory(w)
+			if err != nil {
+				fmt.Fprintln(t.Stderr, err)
+				t.lastExitCode = 1
+				return
+			}
+			fmt.Fprintln(out, s)
+		}
+	default:
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 {
+			if strings.HasPrefix(a, "-") {
+				fmt.Fprintf(t.Stderr, "bash: history: %s: 无效选项\n", a)
+			} else {
+				fmt.Fprintf(t.Stderr, "bash: history: %s: 需要数字参数\n", a)
+			}
+			fmt.Fprintln(t.Stderr, historyUsage)
+			t.lastExitCode = 2
+			return
+		}
+		t.printHistory(out, n)
+	}
+}
+
+func (t *Terminal) printHistory(out io.Writer, n int) {
+	start := len(t.History) - n
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(t.History); i++ {
+		fmt.Fprintf(out, "%5d  %s\n", t.histBase+i+1, t.History[i])
+	}
+}
@@ -0,0 +1,777 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// 作业控制
+// 后台作业、Ctrl-Z、jobs/fg/bg/wait/disown 和 nohup
+// 每个作业在子 shell (fork 出的 Terminal) 中运行，作业中的每条命令都登记在进程表里
+// ==========================================
+
+const (
+	jobRunning = iota
+	jobStopped
+	jobDone
+)
+
+// Job 一条在作业控制下运行的命令行
+type Job struct {
+	ID      int // 作业号，前台作业被挂起或放入后台时才分配
+	Cmdline string
+	procs   []*Process
+
+	mu       sync.Mutex
+	state    int
+	bg       bool
+	exit     int
+	signal   int           // 导致作业结束的信号
+	changed  chan struct{} // 状态变化时关闭并重建
+	done     chan struct{}
+	intr     chan struct{} // 收到终止信号时关闭，阻塞中的命令立即返回
+	nohup    bool          // nohup 启动，会话结束时不受 SIGHUP 影响
+	disowned bool
+}
+
+func newJob(cmdline string, bg bool) *Job {
+	return &Job{
+		Cmdline: cmdline,
+		bg:      bg,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+		intr:    make(chan struct{}),
+	}
+}
+
+// setState 修改作业状态并同步进程表中的 STAT
+func (j *Job) setState(state int) {
+	j.mu.Lock()
+	if j.state == jobDone || j.state == state {
+		j.mu.Unlock()
+		return
+	}
+	j.state = state
+	close(j.changed)
+	j.changed = make(chan struct{})
+	fg := !j.bg
+	j.mu.Unlock()
+
+	Procs.mu.Lock()
+	for _, p := range j.procs {
+		switch {
+		case state == jobStopped:
+			p.Stat = "T"
+		case fg && p.TTY != "?":
+			p.Stat = "S+"
+		default:
+			p.Stat = "S"
+		}
+	}
+	Procs.mu.Unlock()
+}
+
+// kill 以信号 sig 结束作业
+func (j *Job) kill(sig int) {
+	j.mu.Lock()
+	if j.state != jobDone && j.signal == 0 {
+		j.signal = sig
+		close(j.intr)
+	}
+	j.mu.Unlock()
+	j.finish(128 + sig)
+}
+
+// finish 标记作业结束并注销其进程
+func (j *Job) finish(code int) {
+	j.mu.Lock()
+	if j.state == jobDone {
+		j.mu.Unlock()
+		return
+	}
+	j.state = jobDone
+	if j.signal != 0 {
+		code = 128 + j.signal
+	}
+	j.exit = code
+	close(j.changed)
+	j.changed = make(chan struct{})
+	close(j.done)
+	j.mu.Unlock()
+
+	for _, p := range j.procs {
+		Procs.Remove(p.PID)
+	}
+}
+
+// snapshot 返回当前状态和状态变化通知
+func (j *Job) snapshot() (int, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state, j.changed
+}
+
+// statusText 返回 jobs 命令显示的状态
+func (j *Job) statusText() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case j.state == jobRunning:
+		return "运行中"
+	case j.state == jobStopped:
+		return "已停止"
+	case j.signal == sigKILL:
+		return "已杀死"
+	case j.signal == sigHUP:
+		return "挂断"
+	case j.signal == sigINT:
+		return "中断"
+	case j.signal != 0:
+		return "已终止"
+	case j.exit != 0:
+		return "退出 " + strconv.Itoa(j.exit)
+	}
+	return "已完成"
+}
+
+// lastPID 返回作业中最后一个命令的 PID ($! 和 [1] 12345 提示)
+func (j *Job) lastPID() int {
+	if len(j.procs) == 0 {
+		return 0
+	}
+	return j.procs[len(j.procs)-1].PID
+}
+
+// ==========================================
+// Terminal 上的作业管理
+// ==========================================
+
+// root 返回登录 shell 对应的 Terminal (子 shell 沿 parent 向上查找)
+func (t *Terminal) root() *Terminal {
+	for t.parent != nil {
+		t = t.parent
+	}
+	return t
+}
+
+// interactive 当前是否为交互式登录 shell (而不是脚本、子 shell 或 Web Shell)
+func (t *Terminal) interactive() bool {
+	return t.parent == nil && t.tty != "?" && t.scriptDepth == 0
+}
+
+// fork 创建运行作业的子 shell：环境变量和工作目录是副本，I/O 与父 shell 共享
+func (t *Terminal) fork(job *Job) *Terminal {
+	t.mu.Lock()
+	env := make(map[string]string, len(t.Env))
+	for k, v := range t.Env {
+		env[k] = v
+	}
+	c := &Terminal{
+		RW:           t.RW,
+		Stderr:       t.Stderr,
+		FS:           t.FS.View(t.FS.cwd),
+		Env:          env,
+		History:      append([]string(nil), t.History...),
+		Width:        t.Width,
+		Height:       t.Height,
+		Running:      true,
+		pid:          t.pid,
+		tty:          t.tty,
+		lastExitCode: t.lastExitCode,
+		lastBgPID:    t.lastBgPID,
+		Remote:       t.Remote,
+		scriptDepth:  t.scriptDepth,
+		aliases:      copyMap(t.aliases),
+		funcs:        copyMap(t.funcs),
+		positional:   t.positional,
+		parent:       t,
+		job:          job,
+	}
+	t.mu.Unlock()
+	return c
+}
+
+// subshell 在子 shell 中执行命令行并返回退出码，当前 shell 的 $? 和其他状态不受影响
+func (t *Terminal) subshell(cmdline string, in io.Reader, out io.Writer) int {
+	sub := t.fork(t.job)
+	sub.execPipelineIn(cmdline, in, out)
+	return sub.lastExitCode
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// splitBackground 去掉命令行末尾的 &，返回是否需要后台执行
+func splitBackground(cmdline string) (string, bool) {
+	s := strings.TrimSpace(cmdline)
+	if !strings.HasSuffix(s, "&") || strings.HasSuffix(s, "&&") || strings.HasSuffix(s, ">&") {
+		return cmdline, false
+	}
+	return strings.TrimSpace(s[:len(s)-1]), true
+}
+
+// startJob 在子 shell 中异步执行命令行
+func (t *Terminal) startJob(cmdline string, bg bool, out io.Writer) *Job {
+	job := newJob(cmdline, bg)
+	t.mu.Lock()
+	tty := t.tty
+	t.mu.Unlock()
+	stat := "S+"
+	if bg || tty == "?" {
+		stat = "S"
+	}
+	user := t.userName()
+	for _, seg := range strings.Split(cmdline, "|") {
+		if args := parseArgs(seg); len(args) > 0 {
+			job.procs = append(job.procs, Procs.Add(&Process{
+				PPID: t.pid, User: user, TTY: tty, Stat: stat,
+				VSZ: 7000 + rand.Intn(4000), RSS: 900 + rand.Intn(1200),
+				Args: args, job: job,
+			}))
+		}
+	}
+
+	sub := t.fork(job)
+	go func() {
+		defer func() { job.finish(sub.lastExitCode) }()
+		defer sub.recoverCommand(cmdline)
+		sub.execPipelineTo(cmdline, out)
+	}()
+	return job
+}
+
+// startBackground 处理以 & 结尾的命令行
+func (t *Terminal) startBackground(cmdline string, out io.Writer) {
+	// 非交互执行 (Web Shell 等) 的输出缓冲区在命令返回后即被读取，后台作业的输出直接丢弃
+	if _, ok := out.(*bytes.Buffer); ok {
+		out = io.Discard
+	}
+	job := t.startJob(cmdline, true, out)
+	t.lastBgPID = job.lastPID()
+	log.Printf("[Job] %s: background %q (pid %d)", t.Remote, cmdline, job.lastPID())
+	if t.interactive() {
+		t.addJob(job)
+		fmt.Fprintf(out, "[%d] %d\n", job.ID, job.lastPID())
+	}
+}
+
+// addJob 把作业放入作业表并分配作业号
+func (t *Terminal) addJob(job *Job) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	t.removeJobLocked(job)
+	if job.ID == 0 {
+		job.ID = 1
+		for _, j := range t.jobs {
+			if j.ID >= job.ID {
+				job.ID = j.ID + 1
+			}
+		}
+	}
+	// 最近放入的作业是当前作业 (+)
+	t.jobs = append(t.jobs, job)
+}
+
+func (t *Terminal) removeJobLocked(job *Job) {
+	for i, j := range t.jobs {
+		if j == job {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// jobMarkLocked 返回作业在 jobs 中的 +/- 标记
+func (t *Terminal) jobMarkLocked(job *Job) byte {
+	n := len(t.jobs)
+	switch {
+	case n > 0 && t.jobs[n-1] == job:
+		return '+'
+	case n > 1 && t.jobs[n-2] == job:
+		return '-'
+	}
+	return ' '
+}
+
+// formatJobLocked 按 bash 的格式输出一行作业状态
+func (t *Terminal) formatJobLocked(job *Job, long bool) string {
+	status := job.statusText()
+	cmd := job.Cmdline
+	if state, _ := job.snapshot(); state == jobRunning {
+		cmd += " &"
+	}
+	// bash 按字节宽度对齐状态列
+	pad := 24 - len(status)
+	if pad < 1 {
+		pad = 1
+	}
+	if long {
+		return fmt.Sprintf("[%d]%c %d %s%s%s", job.ID, t.jobMarkLocked(job), job.lastPID(), status, strings.Repeat(" ", pad), cmd)
+	}
+	return fmt.Sprintf("[%d]%c  %s%s%s", job.ID, t.jobMarkLocked(job), status, strings.Repeat(" ", pad), cmd)
+}
+
+// reportJobs 在显示提示符前报告已结束的后台作业
+func (t *Terminal) reportJobs(out io.Writer) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	var keep []*Job
+	for _, j := range t.jobs {
+		if state, _ := j.snapshot(); state == jobDone {
+			fmt.Fprintln(out, t.formatJobLocked(j, false))
+			continue
+		}
+		keep = append(keep, j)
+	}
+	t.jobs = keep
+}
+
+// hasStoppedJobs 是否有被挂起的作业 (此时 exit 需要确认)
+func (t *Terminal) hasStoppedJobs() bool {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	for _, j := range t.jobs {
+		if state, _ := j.snapshot(); state == jobStopped {
+			return true
+		}
+	}
+	return false
+}
+
+// findJob 解析作业声明：%1、%%、%+、%-、%name、%?str，空串表示当前作业
+func (t *Terminal) findJob(spec string) (*Job, bool) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	n := len(t.jobs)
+	spec = strings.TrimPrefix(spec, "%")
+	switch spec {
+	case "", "%", "+":
+		if n > 0 {
+			return t.jobs[n-1], true
+		}
+		return nil, false
+	case "-":
+		if n > 1 {
+			return t.jobs[n-2], true
+		}
+		return nil, false
+	}
+	if id, err := strconv.Atoi(spec); err == nil {
+		for _, j := range t.jobs {
+			if j.ID == id {
+				return j, true
+			}
+		}
+		return nil, false
+	}
+	for i := n - 1; i >= 0; i-- {
+		j := t.jobs[i]
+		if strings.HasPrefix(spec, "?") && strings.Contains(j.Cmdline, spec[1:]) || strings.HasPrefix(j.Cmdline, spec) {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+// waitForeground 等待前台作业结束或被 Ctrl-Z 挂起
+func (t *Terminal) waitForeground(job *Job, out io.Writer) {
+	job.mu.Lock()
+	job.bg = false
+	job.mu.Unlock()
+	job.setState(jobRunning)
+
+	t.mu.Lock()
+	t.fg = job
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.fg = nil
+		t.mu.Unlock()
+	}()
+
+	for {
+		state, changed := job.snapshot()
+		switch state {
+		case jobDone:
+			job.mu.Lock()
+			sig, code := job.signal, job.exit
+			job.mu.Unlock()
+			t.jobsMu.Lock()
+			t.removeJobLocked(job)
+			t.jobsMu.Unlock()
+			switch sig {
+			case 0, sigINT:
+			case sigKILL:
+				fmt.Fprintln(out, "已杀死")
+			default:
+				fmt.Fprintln(out, "已终止")
+			}
+			t.lastExitCode = code
+			return
+		case jobStopped:
+			t.addJob(job)
+			t.jobsMu.Lock()
+			fmt.Fprintf(out, "\n%s\n", t.formatJobLocked(job, false))
+			t.jobsMu.Unlock()
+			t.lastExitCode = 128 + sigTSTP
+			return
+		}
+		<-changed
+	}
+}
+
+// runForeground 交互式执行一行命令：按语句解释，每条简单命令交给 runSimple
+func (t *Terminal) runForeground(cmdline string) {
+	t.runLines(cmdline, &CRLFWriter{w: t.RW})
+}
+
+// runSimple 执行一条简单命令：交互式登录 shell 中内置命令和函数直接执行，其余命令作为前台作业
+func (t *Terminal) runSimple(cmdline string, out io.Writer) {
+	if !t.interactive() {
+		t.execPipelineTo(cmdline, out)
+		return
+	}
+	_, bg := splitBackground(cmdline)
+	args := parseArgs(t.expandAliases(cmdline))
+	if bg || len(args) == 0 || shellBuiltins[args[0]] || isAssignment(args[0]) || t.funcs[args[0]] != "" {
+		t.execPipelineTo(cmdline, out)
+		return
+	}
+	t.waitForeground(t.startJob(cmdline, false, out), out)
+}
+
+// shellBuiltins 需要修改登录 shell 自身状态、不能放进子 shell 执行的命令
+var shellBuiltins = map[string]bool{
+	"cd": true, "export": true, "exit": true, "logout": true, "history": true,
+	"fg": true, "bg": true, "jobs": true, "disown": true, "kill": true,
+	"unset": true, "alias": true, "unalias": true, "source": true, ".": true,
+}
+
+// interruptForeground 处理前台作业运行期间的 Ctrl-C / Ctrl-Z，返回是否已处理
+func (t *Terminal) interruptForeground(r rune) bool {
+	t.mu.Lock()
+	job := t.fg
+	t.mu.Unlock()
+	if job == nil {
+		return false
+	}
+	switch r {
+	case 3:
+		t.Print("^C\n")
+		job.kill(sigINT)
+	case 26:
+		t.Print("^Z")
+		job.setState(jobStopped)
+	default:
+		return false
+	}
+	return true
+}
+
+// hangup 会话结束时向未 nohup/disown 的作业发送 SIGHUP，其余作业过继给 init
+func (t *Terminal) hangup() {
+	for t.leaveShell() {
+	}
+	t.jobsMu.Lock()
+	jobs := append([]*Job(nil), t.jobs...)
+	t.jobs = nil
+	t.jobsMu.Unlock()
+	t.mu.Lock()
+	if t.fg != nil {
+		jobs = append(jobs, t.fg)
+	}
+	t.mu.Unlock()
+	for _, j := range jobs {
+		j.mu.Lock()
+		survive := j.nohup || j.disowned
+		j.mu.Unlock()
+		if !survive {
+			j.kill(sigHUP)
+		}
+	}
+}
+
+// pause 等待 d (d <= 0 表示一直等待)，响应所属作业的挂起/继续/终止；被终止时返回 false
+func (t *Terminal) pause(d time.Duration) bool {
+	job := t.job
+	if job == nil {
+		time.Sleep(d)
+		return true
+	}
+	remaining := d
+	for {
+		state, changed := job.snapshot()
+		switch state {
+		case jobDone:
+			return false
+		case jobStopped:
+			select {
+			case <-changed:
+			case <-job.intr:
+				return false
+			}
+			continue
+		}
+		var timeout <-chan time.Time
+		start := time.Now()
+		var timer *time.Timer
+		if d > 0 {
+			timer = time.NewTimer(remaining)
+			timeout = timer.C
+		}
+		select {
+		case <-timeout:
+			return true
+		case <-job.intr:
+			if timer != nil {
+				timer.Stop()
+			}
+			return false
+		case <-changed:
+			if timer != nil {
+				timer.Stop()
+				remaining -= time.Since(start)
+			}
+		}
+	}
+}
+
+// ==========================================
+// jobs / fg / bg / wait / disown / nohup
+// ==========================================
+
+func (t *Terminal) cmdJobs(args []string, out io.Writer) {
+	long, pidOnly := false, false
+	var specs []string
+	for _, a := range args[1:] {
+		switch {
+		case a == "-l":
+			long = true
+		case a == "-p":
+			pidOnly = true
+		case strings.HasPrefix(a, "-"):
+		default:
+			specs = append(specs, a)
+		}
+	}
+	var list []*Job
+	if len(specs) == 0 {
+		t.jobsMu.Lock()
+		list = append(list, t.jobs...)
+		t.jobsMu.Unlock()
+	}
+	for _, s := range specs {
+		j, ok := t.findJob(s)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "bash: jobs: %s: 无此任务\n", s)
+			t.lastExitCode = 1
+			continue
+		}
+		list = append(list, j)
+	}
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	var keep []*Job
+	for _, j := range t.jobs {
+		if state, _ := j.snapshot(); state != jobDone {
+			keep = append(keep, j)
+		}
+	}
+	for _, j := range list {
+		if pidOnly {
+			fmt.Fprintln(out, j.lastPID())
+		} else {
+			fmt.Fprintln(out, t.formatJobLocked(j, long))
+		}
+	}
+	// jobs 会顺带报告并清理已结束的作业
+	t.jobs = keep
+}
+
+// cmdFgBg 实现 fg 和 bg
+func (t *Terminal) cmdFgBg(args []string, out io.Writer) {
+	name := args[0]
+	spec := ""
+	if len(args) > 1 {
+		spec = args[1]
+	}
+	job, ok := t.findJob(spec)
+	if !ok {
+		if spec == "" {
+			spec = "当前"
+		}
+		fmt.Fprintf(t.Stderr, "bash: %s: %s: 无此任务\n", name, spec)
+		t.lastExitCode = 1
+		return
+	}
+	if !t.interactive() {
+		fmt.Fprintf(t.Stderr, "bash: %s: 无任务控制\n", name)
+		t.lastExitCode = 1
+		return
+	}
+	state, _ := job.snapshot()
+	if name == "fg" {
+		fmt.Fprintln(out, job.Cmdline)
+		t.jobsMu.Lock()
+		t.removeJobLocked(job)
+		t.jobsMu.Unlock()
+		t.waitForeground(job, out)
+		return
+	}
+	if state == jobRunning {
+		fmt.Fprintf(t.Stderr, "bash: bg: 任务 %d 已在后台运行\n", job.ID)
+		return
+	}
+	job.mu.Lock()
+	job.bg = true
+	job.mu.Unlock()
+	job.setState(jobRunning)
+	t.jobsMu.Lock()
+	fmt.Fprintf(out, "[%d]%c %s &\n", job.ID, t.jobMarkLocked(job), job.Cmdline)
+	t.jobsMu.Unlock()
+}
+
+// cmdWait 等待后台作业结束，可被 Ctrl-C 打断
+func (t *Terminal) cmdWait(args []string) {
+	rt := t.root()
+	var targets []*Job
+	if len(args) == 1 {
+		rt.jobsMu.Lock()
+		targets = append(targets, rt.jobs...)
+		rt.jobsMu.Unlock()
+	}
+	for _, a := range args[1:] {
+		if strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "%") {
+			continue
+		}
+		var job *Job
+		if strings.HasPrefix(a, "%") {
+			job, _ = rt.findJob(a)
+		} else if pid, err := strconv.Atoi(a); err == nil {
+			if p, ok := Procs.Get(pid); ok {
+				job = p.job
+			}
+		}
+		if job == nil {
+			fmt.Fprintf(t.Stderr, "bash: wait: %s: 不是本 shell 的子进程\n", a)
+			t.lastExitCode = 127
+			continue
+		}
+		targets = append(targets, job)
+	}
+	var intr <-chan struct{}
+	if t.job != nil {
+		intr = t.job.intr
+	}
+	for _, j := range targets {
+		if state, _ := j.snapshot(); state == jobStopped {
+			continue
+		}
+		select {
+		case <-j.done:
+			j.mu.Lock()
+			t.lastExitCode = j.exit
+			j.mu.Unlock()
+		case <-intr:
+			return
+		}
+	}
+}
+
+func (t *Terminal) cmdDisown(args []string) {
+	keepTable := false
+	all := false
+	var specs []string
+	for _, a := range args[1:] {
+		switch a {
+		case "-h":
+			keepTable = true
+		case "-a", "-r":
+			all = true
+		default:
+			specs = append(specs, a)
+		}
+	}
+	var targets []*Job
+	if all {
+		t.jobsMu.Lock()
+		targets = append(targets, t.jobs...)
+		t.jobsMu.Unlock()
+	} else if len(specs) == 0 {
+		specs = []string{""}
+	}
+	for _, s := range specs {
+		j, ok := t.findJob(s)
+		if !ok {
+			if s == "" {
+				s = "当前"
+			}
+			fmt.Fprintf(t.Stderr, "bash: disown: %s: 无此任务\n", s)
+			t.lastExitCode = 1
+			continue
+		}
+		targets = append(targets, j)
+	}
+	for _, j := range targets {
+		j.mu.Lock()
+		j.disowned = true
+		j.mu.Unlock()
+		if !keepTable {
+			t.jobsMu.Lock()
+			t.removeJobLocked(j)
+			t.jobsMu.Unlock()
+		}
+	}
+}
+
+// cmdNohup 忽略 SIGHUP 运行命令，输出是终端时追加到 nohup.out
+func (t *Terminal) cmdNohup(args []string, in io.Reader, out io.Writer) {
+	if len(args) < 2 {
+		fmt.Fprintln(t.Stderr, "nohup: 缺少操作数\nTry 'nohup --help' for more information.")
+		t.lastExitCode = 125
+		return
+	}
+	if t.job != nil {
+		t.job.mu.Lock()
+		t.job.nohup = true
+		t.job.mu.Unlock()
+	}
+	if !isTTY(out) {
+		if t.tty != "?" {
+			fmt.Fprintln(t.Stderr, "nohup: 忽略输入")
+		}
+		t.runCommand(args[1:], in, out)
+		return
+	}
+
+	target := t.FS.Abs("nohup.out")
+	if dir, ok := t.FS.GetEntry(t.FS.cwd); !ok || !dir.IsDir {
+		target = t.FS.Abs("~/nohup.out")
+	}
+	fmt.Fprintln(t.Stderr, "nohup: 忽略输入并把输出追加到 'nohup.out'")
+	var buf bytes.Buffer
+	t.runCommand(args[1:], in, &buf)
+	var old []byte
+	if e, ok := t.FS.GetEntry(target); ok && !e.IsDir {
+		e.mu.RLock()
+		old = e.Content
+		e.mu.RUnlock()
+	}
+	t.FS.Write(target, append(append([]byte(nil), old...), buf.Bytes()...), 0600)
+}
@@ -2,6 +2,8 @@
 
 package main
 
+import "syscall"
+
 func optimizeLimits() {
 	var rLim syscall.Rlimit
 	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLim); err == nil {
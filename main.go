@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -8,20 +9,36 @@ import (
 )
 
 func main() {
+	// 0. Parse Flags
+	honourAccounts := flag.Bool("honour-accounts", false,
+		"SSH/Telnet logins honour passwords set with passwd, chpasswd or useradd in the shared filesystem")
+	flag.Parse()
+
 	// 1. Optimize Syscall limits (from limit_linux.go or limit_other.go)
 	optimizeLimits()
 
 	// 2. Initialize Base Filesystem
 	initFS()
+	// 可选：SSH/Telnet 登录遵循会话中修改过的账户密码 (见 AuthPolicy.Accounts)
+	if *honourAccounts {
+		Auth.Accounts = GlobalSessionFS
+	}
 
 	// 3. Start Services
 	go runSSHServer()
 	go runTelnetServer()
 	go runRLoginServer()
+	go runRShServer()
+	go runRExecServer()
+	go runFTPServer()
+	go runHTTPServer()
+	go runRedisServer()
+	go runMySQLServer()
+	go runSMTPServer()
 
 	// 4. Wait for interrupt
 	log.Println("Fake Server Suite Running...")
-	log.Println("SSH: 2200, Telnet: 2300, RLogin: 5130")
+	log.Println("SSH: 2200, Telnet: 2300, RLogin: 5130, RSh: 5140, RExec: 5120, FTP: 2100, HTTP: 8080, Redis: 6379, MySQL: 3306, SMTP: 2500")
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
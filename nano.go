@@ -0,0 +1,703 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// nano
+// 无模式编辑器：标题栏、编辑区、状态栏和两行快捷键提示
+// ==========================================
+
+const nanoVersion = "6.2"
+
+// nanoShortcuts 底部两行快捷键提示
+var nanoShortcuts = [2][6][2]string{
+	{{"^G", "帮助"}, {"^O", "写入"}, {"^W", "搜索"}, {"^K", "剪切"}, {"^T", "执行命令"}, {"^C", "位置"}},
+	{{"^X", "离开"}, {"^R", "读档"}, {"^\\", "替换"}, {"^U", "粘贴"}, {"^J", "对齐"}, {"^_", "跳行"}},
+}
+
+// nanoYesNo 是/否提示时的快捷键
+var nanoYesNo = [2][6][2]string{
+	{{" Y", "是"}},
+	{{" N", "否"}, {"^C", "取消"}},
+}
+
+type nanoEditor struct {
+	t   *Terminal
+	rs  *rawSession
+	out io.Writer
+
+	name     string
+	path     string
+	lines    [][]rune
+	orig     string
+	modified bool
+
+	row, col int
+	top      int
+	width    int
+	height   int
+
+	cut     []string // 剪切缓冲区
+	cutting bool     // 上一个按键也是 ^K，连续剪切的行会累积起来
+
+	msg     string
+	prompt  string // 非空时状态栏显示输入提示
+	input   []rune
+	onInput func(string)
+	onYesNo func(bool) // 非空时等待回答是/否
+
+	lastSearch string
+	quit       bool
+}
+
+func (t *Terminal) cmdNano(args []string, out io.Writer) {
+	if !isTTY(out) {
+		fmt.Fprintln(out, "Too many errors from stdin")
+		t.lastExitCode = 1
+		return
+	}
+	n := &nanoEditor{t: t, out: out, lines: [][]rune{{}}}
+	gotoLine := 0
+	file := ""
+	for _, a := range args[1:] {
+		switch {
+		case strings.HasPrefix(a, "+"):
+			gotoLine, _ = strconv.Atoi(strings.SplitN(a[1:], ",", 2)[0])
+		case strings.HasPrefix(a, "-"):
+		case file == "":
+			file = a
+		}
+	}
+	if file != "" {
+		n.open(file)
+	}
+	if gotoLine > 0 {
+		n.row = min(gotoLine, len(n.lines)) - 1
+	}
+
+	self := t.spawn(args)
+	defer Procs.Remove(self.PID)
+
+	n.rs = t.enterRaw()
+	defer n.rs.close()
+	fmt.Fprint(out, "\033[?1049h\033[H\033[2J")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+
+	for !n.quit {
+		if err := n.render(); err != nil {
+			return
+		}
+		for {
+			ev, ok := n.rs.next(500 * time.Millisecond)
+			if ok {
+				n.handle(ev)
+				break
+			}
+			if n.rs.killed() {
+				return
+			}
+			if w, h := n.rs.size(); w != n.width || h != n.height {
+				break
+			}
+		}
+	}
+}
+
+func (n *nanoEditor) open(name string) {
+	p := n.t.FS.Abs(n.t.expandTilde(name))
+	lines, orig, exists, isDir := n.t.loadText(p)
+	switch {
+	case isDir:
+		n.msg = fmt.Sprintf("\"%s\" 是一个目录", name)
+		return
+	case !exists:
+		n.msg = "新文件"
+	default:
+		n.msg = fmt.Sprintf("读取了 %d 行", strings.Count(orig, "\n"))
+	}
+	n.name, n.path = name, p
+	n.lines, n.orig = lines, orig
+	log.Printf("[Edit] %s: %s opened %s with nano", n.t.Remote, n.t.userName(), p)
+}
+
+// ==========================================
+// 绘制
+// ==========================================
+
+// lineCells 把一行展开为屏幕格子，宽字符的第二格为空串
+func lineCells(line []rune) []string {
+	var cells []string
+	col := 0
+	for _, r := range line {
+		w := cellWidth(r, col)
+		switch {
+		case r == '\t':
+			for i := 0; i < w; i++ {
+				cells = append(cells, " ")
+			}
+		case w == 2 && runeWidth(r) != 2:
+			// 控制字符 ^X
+			txt := cellText(r, col)
+			cells = append(cells, txt[:1], txt[1:])
+		case w == 2:
+			cells = append(cells, string(r), "")
+		case w == 0 && len(cells) > 0:
+			cells[len(cells)-1] += string(r)
+		default:
+			cells = append(cells, string(r))
+		}
+		col += w
+	}
+	return cells
+}
+
+// viewLine 返回从第 off 列开始、宽 width 的显示内容，两端被截断时显示 < 和 >
+func viewLine(line []rune, off, width int) string {
+	cells := lineCells(line)
+	if off > len(cells) {
+		off = len(cells)
+	}
+	view := append([]string{}, cells[off:min(off+width, len(cells))]...)
+	if len(view) > 0 && view[0] == "" {
+		view[0] = " "
+	}
+	if off+width < len(cells) {
+		view[width-1] = ">"
+		if width >= 2 && view[width-2] != "" && runeWidth([]rune(view[width-2])[0]) == 2 {
+			view[width-2] = " "
+		}
+	} else if n := len(view); n > 0 && off+n < len(cells) && cells[off+n] == "" {
+		view[n-1] = " "
+	}
+	if off > 0 && len(view) > 0 {
+		view[0] = "<"
+		if len(view) > 1 && view[1] == "" {
+			view[1] = " "
+		}
+	}
+	return strings.Join(view, "")
+}
+
+// scrollOffset 返回光标所在行的水平偏移
+func scrollOffset(cx, width int) int {
+	if cx < width-1 {
+		return 0
+	}
+	page := max(width-8, 1)
+	return ((cx-(width-1))/page + 1) * page
+}
+
+func (n *nanoEditor) render() error {
+	n.width, n.height = n.rs.size()
+	w, h := n.width, n.height
+	textRows := max(h-4, 1)
+	n.clampCursor()
+	if n.row < n.top {
+		n.top = n.row
+	}
+	if n.row >= n.top+textRows {
+		n.top = n.row - textRows + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\033[?25l\033[H")
+
+	// 标题栏
+	left := "  GNU nano " + nanoVersion
+	title := n.name
+	if title == "" {
+		title = "新缓冲区"
+	}
+	right := ""
+	if n.modified {
+		right = "已修改  "
+	}
+	lw, tw, rw := visibleWidth(left), visibleWidth(title), visibleWidth(right)
+	pad1 := max((w-tw)/2-lw, 1)
+	pad2 := max(w-lw-pad1-tw-rw, 1)
+	bar := left + strings.Repeat(" ", pad1) + title + strings.Repeat(" ", pad2) + right
+	b.WriteString("\033[7m" + bar + "\033[0m")
+
+	curY, curX := 1, 0
+	for y := 0; y < textRows; y++ {
+		fmt.Fprintf(&b, "\033[%d;1H", y+2)
+		i := n.top + y
+		if i < len(n.lines) {
+			off := 0
+			if i == n.row {
+				cx := displayCol(n.lines[i], n.col)
+				off = scrollOffset(cx, w)
+				curY, curX = y+1, cx-off
+			}
+			b.WriteString(viewLine(n.lines[i], off, w))
+		}
+		b.WriteString("\033[K")
+	}
+
+	// 状态栏：输入提示或消息
+	fmt.Fprintf(&b, "\033[%d;1H\033[K", h-2)
+	shortcuts := nanoShortcuts
+	switch {
+	case n.onYesNo != nil:
+		text := n.prompt
+		b.WriteString("\033[7m" + text + strings.Repeat(" ", max(w-visibleWidth(text), 0)) + "\033[0m")
+		curY, curX = h-3, visibleWidth(text)
+		shortcuts = nanoYesNo
+	case n.prompt != "":
+		text := n.prompt + string(n.input)
+		b.WriteString("\033[7m" + text + strings.Repeat(" ", max(w-visibleWidth(text), 0)) + "\033[0m")
+		curY, curX = h-3, visibleWidth(text)
+	case n.msg != "":
+		text := "[ " + n.msg + " ]"
+		fmt.Fprintf(&b, "\033[%d;%dH\033[7m%s\033[0m", h-2, max((w-visibleWidth(text))/2, 0)+1, text)
+	}
+
+	// 快捷键
+	slot := max(w/6, 1)
+	for row := 0; row < 2; row++ {
+		fmt.Fprintf(&b, "\033[%d;1H\033[K", h-1+row)
+		for i, sc := range shortcuts[row] {
+			if sc[0] == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "\033[%d;%dH\033[7m%s\033[0m %s", h-1+row, i*slot+1, sc[0], sc[1])
+		}
+	}
+	fmt.Fprintf(&b, "\033[%d;%dH\033[?25h", curY+1, curX+1)
+	_, err := io.WriteString(n.out, b.String())
+	return err
+}
+
+// ==========================================
+// 编辑
+// ==========================================
+
+func (n *nanoEditor) clampCursor() {
+	if len(n.lines) == 0 {
+		n.lines = [][]rune{{}}
+	}
+	n.row = max(0, min(n.row, len(n.lines)-1))
+	n.col = max(0, min(n.col, len(n.lines[n.row])))
+}
+
+func (n *nanoEditor) insert(s string) {
+	for _, r := range s {
+		line := n.lines[n.row]
+		if r == '\n' {
+			tail := append([]rune{}, line[n.col:]...)
+			n.lines[n.row] = line[:n.col]
+			n.lines = append(n.lines[:n.row+1], append([][]rune{tail}, n.lines[n.row+1:]...)...)
+			n.row, n.col = n.row+1, 0
+			continue
+		}
+		nl := make([]rune, 0, len(line)+1)
+		n.lines[n.row] = append(append(append(nl, line[:n.col]...), r), line[n.col:]...)
+		n.col++
+	}
+	n.modified = true
+}
+
+// deleteChar 删除光标处的字符，在行尾时合并下一行
+func (n *nanoEditor) deleteChar() {
+	line := n.lines[n.row]
+	switch {
+	case n.col < len(line):
+		n.lines[n.row] = append(append([]rune{}, line[:n.col]...), line[n.col+1:]...)
+	case n.row < len(n.lines)-1:
+		n.lines[n.row] = append(append([]rune{}, line...), n.lines[n.row+1]...)
+		n.lines = append(n.lines[:n.row+1], n.lines[n.row+2:]...)
+	default:
+		return
+	}
+	n.modified = true
+}
+
+// cutLine ^K：剪切当前行，连续剪切时追加到剪切缓冲区
+func (n *nanoEditor) cutLine() {
+	if !n.cutting {
+		n.cut = nil
+	}
+	if n.row == len(n.lines)-1 && len(n.lines[n.row]) == 0 {
+		return
+	}
+	n.cut = append(n.cut, string(n.lines[n.row]))
+	n.lines = append(n.lines[:n.row], n.lines[n.row+1:]...)
+	n.col = 0
+	n.modified = true
+}
+
+// paste ^U：在当前行之前插入剪切缓冲区
+func (n *nanoEditor) paste() {
+	if len(n.cut) == 0 {
+		return
+	}
+	var add [][]rune
+	for _, l := range n.cut {
+		add = append(add, []rune(l))
+	}
+	n.lines = append(n.lines[:n.row], append(add, n.lines[n.row:]...)...)
+	n.row += len(add)
+	n.col = 0
+	n.modified = true
+}
+
+// ==========================================
+// 输入提示
+// ==========================================
+
+func (n *nanoEditor) ask(prompt, initial string, done func(string)) {
+	n.prompt, n.input, n.onInput, n.onYesNo = prompt, []rune(initial), done, nil
+}
+
+func (n *nanoEditor) askYesNo(prompt string, done func(bool)) {
+	n.prompt, n.input, n.onInput, n.onYesNo = prompt, nil, nil, done
+}
+
+func (n *nanoEditor) endPrompt() {
+	n.prompt, n.input, n.onInput, n.onYesNo = "", nil, nil, nil
+}
+
+func (n *nanoEditor) promptKey(ev keyEvent) {
+	if ev.code != keyRune {
+		return
+	}
+	r := ev.r
+	if r == 3 {
+		n.endPrompt()
+		n.msg = "已取消"
+		return
+	}
+	if yn := n.onYesNo; yn != nil {
+		switch r {
+		case 'y', 'Y':
+			n.endPrompt()
+			yn(true)
+		case 'n', 'N':
+			n.endPrompt()
+			yn(false)
+		}
+		return
+	}
+	switch r {
+	case '\r', '\n':
+		done, s := n.onInput, string(n.input)
+		n.endPrompt()
+		done(s)
+	case 127, 8:
+		if len(n.input) > 0 {
+			n.input = n.input[:len(n.input)-1]
+		}
+	case 21:
+		n.input = n.input[:0]
+	default:
+		if r >= 32 {
+			n.input = append(n.input, r)
+		}
+	}
+}
+
+// ==========================================
+// 按键处理
+// ==========================================
+
+func (n *nanoEditor) handle(ev keyEvent) {
+	if n.prompt != "" {
+		n.promptKey(ev)
+		return
+	}
+	n.msg = ""
+	cutting := false
+	defer func() {
+		n.cutting = cutting
+		n.clampCursor()
+	}()
+
+	page := max(n.height-5, 1)
+	switch ev.code {
+	case keyUp:
+		n.row--
+	case keyDown:
+		n.row++
+	case keyLeft:
+		n.left()
+	case keyRight:
+		n.right()
+	case keyHome:
+		n.col = 0
+	case keyEnd:
+		n.col = len(n.lines[n.row])
+	case keyDelete:
+		n.deleteChar()
+	case keyAlt:
+		switch ev.r {
+		case 'w', 'W':
+			n.search(n.lastSearch)
+		case 'g', 'G':
+			n.askGoto()
+		case '\\':
+			n.row, n.col = 0, 0
+		case '/':
+			n.row = len(n.lines) - 1
+			n.col = len(n.lines[n.row])
+		}
+	case keyRune:
+		switch r := ev.r; r {
+		case '\r', '\n':
+			n.insert("\n")
+		case 127, 8:
+			if n.col > 0 || n.row > 0 {
+				n.left()
+				n.deleteChar()
+			}
+		case 4:
+			n.deleteChar()
+		case 16:
+			n.row--
+		case 14:
+			n.row++
+		case 2:
+			n.left()
+		case 6:
+			n.right()
+		case 1:
+			n.col = 0
+		case 5:
+			n.col = len(n.lines[n.row])
+		case 25:
+			n.row -= page
+			n.top = max(n.top-page, 0)
+		case 22:
+			n.row += page
+			n.top += page
+		case 11:
+			n.cutLine()
+			cutting = true
+		case 21:
+			n.paste()
+		case 15:
+			n.ask("要写入的文件名: ", n.name, func(s string) { n.write(s) })
+		case 24:
+			n.exit()
+		case 23:
+			n.ask(n.searchPrompt("搜索"), "", n.search)
+		case 28:
+			n.askReplace()
+		case 18:
+			n.ask("要读取的文件: ", "", n.readFile)
+		case 20:
+			n.ask("要执行的命令: ", "", n.execute)
+		case 31:
+			n.askGoto()
+		case 3:
+			n.showPosition()
+		case 7:
+			n.msg = "未安装帮助文档"
+		case 12, 26, 27:
+		default:
+			if r == '\t' || r >= 32 {
+				n.insert(string(r))
+			}
+		}
+	}
+}
+
+func (n *nanoEditor) left() {
+	if n.col > 0 {
+		n.col--
+	} else if n.row > 0 {
+		n.row--
+		n.col = len(n.lines[n.row])
+	}
+}
+
+func (n *nanoEditor) right() {
+	if n.col < len(n.lines[n.row]) {
+		n.col++
+	} else if n.row < len(n.lines)-1 {
+		n.row, n.col = n.row+1, 0
+	}
+}
+
+// exit ^X：有修改时先询问是否保存
+func (n *nanoEditor) exit() {
+	if !n.modified {
+		n.quit = true
+		return
+	}
+	n.askYesNo("保存修改后的缓冲区吗？ ", func(yes bool) {
+		if !yes {
+			n.quit = true
+			return
+		}
+		n.ask("要写入的文件名: ", n.name, func(s string) {
+			if n.write(s) {
+				n.quit = true
+			}
+		})
+	})
+}
+
+func (n *nanoEditor) write(name string) bool {
+	if name == "" {
+		n.msg = "已取消"
+		return false
+	}
+	p := n.t.FS.Abs(n.t.expandTilde(name))
+	_, orig, _, _ := n.t.loadText(p)
+	content := joinText(n.lines)
+	if err := n.t.saveText("nano", p, content, orig); err != nil {
+		n.msg = fmt.Sprintf("写入 %s 时出错：%v", name, err)
+		return false
+	}
+	n.name, n.path = name, p
+	n.orig, n.modified = content, false
+	n.msg = fmt.Sprintf("已写入 %d 行", strings.Count(content, "\n"))
+	return true
+}
+
+func (n *nanoEditor) searchPrompt(label string) string {
+	if n.lastSearch != "" {
+		return fmt.Sprintf("%s [%s]: ", label, n.lastSearch)
+	}
+	return label + ": "
+}
+
+// search 从光标之后查找 (不区分大小写)，到文件尾后回绕
+func (n *nanoEditor) search(pat string) {
+	if pat == "" {
+		pat = n.lastSearch
+	}
+	if pat == "" {
+		n.msg = "已取消"
+		return
+	}
+	n.lastSearch = pat
+	needle := []rune(strings.ToLower(pat))
+	total := len(n.lines)
+	for i := 0; i <= total; i++ {
+		row := (n.row + i) % total
+		line := []rune(strings.ToLower(string(n.lines[row])))
+		start := 0
+		if i == 0 {
+			start = n.col + 1
+		}
+		for c := start; c+len(needle) <= len(line); c++ {
+			if i == total && c >= n.col {
+				break
+			}
+			if string(line[c:c+len(needle)]) == string(needle) {
+				if row < n.row || row == n.row && c <= n.col {
+					n.msg = "搜索已回绕"
+				}
+				n.row, n.col = row, c
+				return
+			}
+		}
+	}
+	n.msg = fmt.Sprintf("\"%s\" 未找到", pat)
+}
+
+// askReplace ^\：全部替换
+func (n *nanoEditor) askReplace() {
+	n.ask(n.searchPrompt("搜索(替换)"), "", func(pat string) {
+		if pat == "" {
+			pat = n.lastSearch
+		}
+		if pat == "" {
+			n.msg = "已取消"
+			return
+		}
+		n.lastSearch = pat
+		n.ask("替换为: ", "", func(rep string) {
+			count := 0
+			for i, l := range n.lines {
+				s := string(l)
+				if c := strings.Count(s, pat); c > 0 {
+					count += c
+					n.lines[i] = []rune(strings.ReplaceAll(s, pat, rep))
+				}
+			}
+			if count == 0 {
+				n.msg = fmt.Sprintf("\"%s\" 未找到", pat)
+				return
+			}
+			n.modified = true
+			n.msg = fmt.Sprintf("替换了 %d 处", count)
+		})
+	})
+}
+
+func (n *nanoEditor) askGoto() {
+	n.ask("输入行号，列号: ", "", func(s string) {
+		parts := strings.SplitN(s, ",", 2)
+		line, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			n.msg = "行号或列号无效"
+			return
+		}
+		n.row = line - 1
+		n.col = 0
+		if len(parts) == 2 {
+			if c, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				n.clampCursor()
+				n.col = c - 1
+			}
+		}
+	})
+}
+
+// readFile ^R：把文件内容插入到光标处
+func (n *nanoEditor) readFile(name string) {
+	if name == "" {
+		n.msg = "已取消"
+		return
+	}
+	p := n.t.FS.Abs(n.t.expandTilde(name))
+	_, content, exists, isDir := n.t.loadText(p)
+	switch {
+	case !exists:
+		n.msg = fmt.Sprintf("\"%s\" 未找到", name)
+	case isDir:
+		n.msg = fmt.Sprintf("\"%s\" 是一个目录", name)
+	default:
+		n.insert(content)
+		n.msg = fmt.Sprintf("读取了 %d 行", strings.Count(content, "\n"))
+	}
+}
+
+// execute ^T：执行命令并把输出插入到光标处
+func (n *nanoEditor) execute(cmd string) {
+	if strings.TrimSpace(cmd) == "" {
+		n.msg = "已取消"
+		return
+	}
+	log.Printf("[Edit] %s: nano shell escape: %q", n.t.Remote, cmd)
+	var buf bytes.Buffer
+	n.t.execPipelineTo(cmd, &buf)
+	n.rs.resume()
+	n.insert(buf.String())
+}
+
+func (n *nanoEditor) showPosition() {
+	totalChars, before := 0, 0
+	for i, l := range n.lines {
+		if i == n.row {
+			before = totalChars + n.col
+		}
+		totalChars += len(l) + 1
+	}
+	lineLen := len(n.lines[n.row]) + 1
+	pct := func(a, b int) int { return a * 100 / max(b, 1) }
+	n.msg = fmt.Sprintf("第 %d/%d 行 (%d%%)，第 %d/%d 列 (%d%%)，第 %d/%d 个字符 (%d%%)",
+		n.row+1, len(n.lines), pct(n.row+1, len(n.lines)),
+		n.col+1, lineLen, pct(n.col+1, lineLen),
+		before+1, totalChars, pct(before+1, totalChars))
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 网络查看工具: ip, ifconfig, route, arp, netstat, ss, hostname
+// 数据都来自 network.go 中的虚拟网络；修改网络配置的操作对 root 静默成功但不生效
+// ==========================================
+
+// ipFlags 返回 ip 命令显示的网卡标志、队列规则和状态
+func ipFlags(i netIface) (flags, qdisc, state string) {
+	if i.Loopback {
+		return "<LOOPBACK,UP,LOWER_UP>", "noqueue", "UNKNOWN"
+	}
+	return "<BROADCAST,MULTICAST,UP,LOWER_UP>", "fq_codel", "UP"
+}
+
+// ipLinkLine 返回 link/ether 或 link/loopback 一行
+func ipLinkLine(i netIface) string {
+	if i.Loopback {
+		return "    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00\n"
+	}
+	return "    link/ether " + i.MAC + " brd ff:ff:ff:ff:ff:ff\n"
+}
+
+// dhcpLeft 返回 DHCP 租约剩余秒数，租期一天，过半时续租
+func dhcpLeft() int {
+	return 86400 - int(time.Since(startTime).Seconds())%43200
+}
+
+const ipUsage = `Usage: ip [ OPTIONS ] OBJECT { COMMAND | help }
+       ip [ -force ] -batch filename
+where  OBJECT := { address | addrlabel | amt | fou | help | ila | ioam | l2tp |
+                   link | macsec | maddress | monitor | mptcp | mroute | mrule |
+                   neighbor | neighbour | netconf | netns | nexthop | ntable |
+                   ntbl | route | rule | sr | tap | tcpmetrics |
+                   token | tunnel | tuntap | vrf | xfrm }
+       OPTIONS := { -V[ersion] | -s[tatistics] | -d[etails] | -r[esolve] |
+                    -h[uman-readable] | -iec | -j[son] | -p[retty] |
+                    -f[amily] { inet | inet6 | mpls | bridge | link } |
+                    -4 | -6 | -M | -B | -0 |
+                    -l[oops] { maximum-addr-flush-attempts } | -br[ief] |
+                    -o[neline] | -t[imestamp] | -ts[hort] | -b[atch] [filename] |
+                    -rc[vbuf] [size] | -n[etns] name | -N[umeric] | -a[ll] |
+                    -c[olor]}
+`
+
+func (t *Terminal) cmdIP(args []string, out io.Writer) {
+	v4, v6, brief := true, true, false
+	i := 1
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		switch a := args[i]; {
+		case a == "-4":
+			v6 = false
+		case a == "-6":
+			v4 = false
+		case a == "-br" || a == "-brief":
+			brief = true
+		case a == "-s" || a == "-stats" || a == "-statistics" || a == "-d" || a == "-details" ||
+			strings.HasPrefix(a, "-c") || strings.HasPrefix(a, "--c"):
+		default:
+			fmt.Fprintf(t.Stderr, "Option \"%s\" is unknown, try \"ip -help\".\n", a)
+			t.lastExitCode = 255
+			return
+		}
+	}
+	if i >= len(args) {
+		io.WriteString(t.Stderr, ipUsage)
+		t.lastExitCode = 255
+		return
+	}
+
+	obj, rest := args[i], args[i+1:]
+	is := func(full string) bool { return strings.HasPrefix(full, obj) }
+	var kind string
+	switch {
+	case is("address"):
+		kind = "address"
+	case is("link"):
+		kind = "link"
+	case is("route"):
+		kind = "route"
+	case is("neighbour"), is("neighbor"):
+		kind = "neighbour"
+	case obj == "help":
+		io.WriteString(t.Stderr, ipUsage)
+		t.lastExitCode = 255
+		return
+	default:
+		fmt.Fprintf(t.Stderr, "Object \"%s\" is unknown, try \"ip help\".\n", obj)
+		t.lastExitCode = 255
+		return
+	}
+
+	verb := "show"
+	if len(rest) > 0 {
+		verb, rest = rest[0], rest[1:]
+	}
+	switch verb {
+	case "show", "list", "lst", "sh", "ls", "s", "l":
+	case "add", "del", "delete", "change", "replace", "set", "flush", "append", "a", "d":
+		if t.userName() != "root" {
+			fmt.Fprintln(t.Stderr, "RTNETLINK answers: Operation not permitted")
+			t.lastExitCode = 2
+		}
+		return
+	case "get":
+		if kind == "route" {
+			t.ipRouteGet(rest, out)
+			return
+		}
+		fallthrough
+	default:
+		fmt.Fprintf(t.Stderr, "Command \"%s\" is unknown, try \"ip %s help\".\n", verb, kind)
+		t.lastExitCode = 255
+		return
+	}
+
+	// show 的参数：[dev] NAME，其余过滤条件忽略
+	var dev string
+	for j := 0; j < len(rest); j++ {
+		switch rest[j] {
+		case "dev":
+			if j+1 < len(rest) {
+				dev = rest[j+1]
+				j++
+			}
+		case "up":
+		case "scope", "to", "table", "proto":
+			j++
+		default:
+			dev = rest[j]
+		}
+	}
+	ifaces := netIfaces()
+	if dev != "" && kind != "route" {
+		i, ok := findIface(dev)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "Device \"%s\" does not exist.\n", dev)
+			t.lastExitCode = 1
+			return
+		}
+		ifaces = []netIface{i}
+	}
+
+	switch kind {
+	case "address":
+		for _, i := range ifaces {
+			flags, qdisc, state := ipFlags(i)
+			if brief {
+				addrs := ""
+				if v4 {
+					addrs += i.Addr.String() + " "
+				}
+				if v6 {
+					addrs += i.inet6().String() + " "
+				}
+				fmt.Fprintf(out, "%-16s %-14s %s\n", i.Name, state, addrs)
+				continue
+			}
+			fmt.Fprintf(out, "%d: %s: %s mtu %d qdisc %s state %s group default qlen 1000\n", i.Index, i.Name, flags, i.MTU, qdisc, state)
+			if v4 && v6 {
+				io.WriteString(out, ipLinkLine(i))
+			}
+			if v4 {
+				if i.Loopback {
+					fmt.Fprintf(out, "    inet %s scope host lo\n       valid_lft forever preferred_lft forever\n", i.Addr)
+				} else {
+					left := dhcpLeft()
+					fmt.Fprintf(out, "    inet %s metric 100 brd %s scope global dynamic %s\n       valid_lft %dsec preferred_lft %dsec\n",
+						i.Addr, i.broadcast(), i.Name, left, left)
+				}
+			}
+			if v6 {
+				scope := "link"
+				if i.Loopback {
+					scope = "host"
+				}
+				fmt.Fprintf(out, "    inet6 %s scope %s \n       valid_lft forever preferred_lft forever\n", i.inet6(), scope)
+			}
+		}
+
+	case "link":
+		for _, i := range ifaces {
+			flags, qdisc, state := ipFlags(i)
+			if brief {
+				fmt.Fprintf(out, "%-16s %-14s %s %s \n", i.Name, state, i.MAC, flags)
+				continue
+			}
+			fmt.Fprintf(out, "%d: %s: %s mtu %d qdisc %s state %s mode DEFAULT group default qlen 1000\n", i.Index, i.Name, flags, i.MTU, qdisc, state)
+			io.WriteString(out, ipLinkLine(i))
+		}
+
+	case "route":
+		if !v4 {
+			eth, _ := findIface("eth0")
+			fmt.Fprintf(out, "::1 dev lo proto kernel metric 256 pref medium\n%s dev eth0 proto kernel metric 256 pref medium\n", eth.inet6().Masked())
+			return
+		}
+		for _, r := range netRoutes() {
+			dst := r.Dst.String()
+			if r.Dst.Bits() == 0 {
+				dst = "default"
+			} else if r.Dst.IsSingleIP() {
+				dst = r.Dst.Addr().String()
+			}
+			var b strings.Builder
+			b.WriteString(dst)
+			if r.Gateway.IsValid() {
+				b.WriteString(" via " + r.Gateway.String())
+			}
+			b.WriteString(" dev " + r.Dev + " proto " + r.Proto)
+			if r.Scope != "" {
+				b.WriteString(" scope " + r.Scope)
+			}
+			fmt.Fprintf(out, "%s src %s metric %d \n", b.String(), r.Src, r.Metric)
+		}
+
+	case "neighbour":
+		if !v4 {
+			return
+		}
+		for _, n := range netNeighbors() {
+			fmt.Fprintf(out, "%s dev %s lladdr %s %s\n", n.IP, n.Dev, n.MAC, n.State)
+		}
+	}
+}
+
+// ipRouteGet 实现 ip route get ADDRESS
+func (t *Terminal) ipRouteGet(args []string, out io.Writer) {
+	if len(args) == 0 {
+		fmt.Fprintln(t.Stderr, "need at least a destination address")
+		t.lastExitCode = 1
+		return
+	}
+	dst, err := netip.ParseAddr(args[len(args)-1])
+	if err != nil || !dst.Is4() {
+		fmt.Fprintf(t.Stderr, "Error: inet prefix is expected rather than \"%s\".\n", args[len(args)-1])
+		t.lastExitCode = 1
+		return
+	}
+	uid := max(t.FS.uid(t.userName()), 0)
+	if dst.IsLoopback() || dst == hostAddr() {
+		fmt.Fprintf(out, "local %s dev lo src %s uid %d \n    cache <local> \n", dst, dst, uid)
+		return
+	}
+	r, ok := routeTo(dst)
+	if !ok {
+		fmt.Fprintln(t.Stderr, "RTNETLINK answers: Network is unreachable")
+		t.lastExitCode = 2
+		return
+	}
+	via := ""
+	if r.Gateway.IsValid() {
+		via = " via " + r.Gateway.String()
+	}
+	fmt.Fprintf(out, "%s%s dev %s src %s uid %d \n    cache \n", dst, via, r.Dev, r.Src, uid)
+}
+
+// netToolsSize 按 net-tools 的格式 (十进制单位) 显示字节数
+func netToolsSize(n int64) string {
+	v := float64(n)
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	u := 0
+	for v >= 1000 && u < len(units)-1 {
+		v /= 1000
+		u++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[u])
+}
+
+func (t *Terminal) cmdIfconfig(args []string, out io.Writer) {
+	ifaces := netIfaces()
+	sort.Slice(ifaces, func(a, b int) bool { return ifaces[a].Name < ifaces[b].Name })
+	var names []string
+	for _, a := range args[1:] {
+		if a != "-a" && a != "-s" && a != "-v" {
+			names = append(names, a)
+		}
+	}
+	if len(names) > 0 {
+		i, ok := findIface(names[0])
+		if !ok {
+			fmt.Fprintf(t.Stderr, "%s: error fetching interface information: Device not found\n", names[0])
+			t.lastExitCode = 1
+			return
+		}
+		// ifconfig IFACE up/down/ADDR ... 修改配置
+		if len(names) > 1 {
+			if t.userName() != "root" {
+				op := "SIOCSIFADDR"
+				if names[1] == "up" || names[1] == "down" {
+					op = "SIOCSIFFLAGS"
+				}
+				fmt.Fprintf(t.Stderr, "%s: Operation not permitted\n", op)
+				t.lastExitCode = 1
+			}
+			return
+		}
+		ifaces = []netIface{i}
+	}
+
+	for _, i := range ifaces {
+		rxB, rxP, txB, txP := i.counters()
+		if i.Loopback {
+			fmt.Fprintf(out, "%s: flags=73<UP,LOOPBACK,RUNNING>  mtu %d\n", i.Name, i.MTU)
+			fmt.Fprintf(out, "        inet %s  netmask %s\n", i.Addr.Addr(), ipv4Mask(i.Addr.Bits()))
+			fmt.Fprintf(out, "        inet6 %s  prefixlen %d  scopeid 0x10<host>\n", i.inet6().Addr(), i.inet6().Bits())
+			io.WriteString(out, "        loop  txqueuelen 1000  (Local Loopback)\n")
+		} else {
+			fmt.Fprintf(out, "%s: flags=4163<UP,BROADCAST,RUNNING,MULTICAST>  mtu %d\n", i.Name, i.MTU)
+			fmt.Fprintf(out, "        inet %s  netmask %s  broadcast %s\n", i.Addr.Addr(), ipv4Mask(i.Addr.Bits()), i.broadcast())
+			fmt.Fprintf(out, "        inet6 %s  prefixlen %d  scopeid 0x20<link>\n", i.inet6().Addr(), i.inet6().Bits())
+			fmt.Fprintf(out, "        ether %s  txqueuelen 1000  (Ethernet)\n", i.MAC)
+		}
+		fmt.Fprintf(out, "        RX packets %d  bytes %d (%s)\n", rxP, rxB, netToolsSize(rxB))
+		io.WriteString(out, "        RX errors 0  dropped 0  overruns 0  frame 0\n")
+		fmt.Fprintf(out, "        TX packets %d  bytes %d (%s)\n", txP, txB, netToolsSize(txB))
+		io.WriteString(out, "        TX errors 0  dropped 0 overruns 0  carrier 0  collisions 0\n\n")
+	}
+}
+
+// hostName 按 net-tools 不加 -n 时的习惯把地址解析为主机名
+func hostName(a netip.Addr) string {
+	switch {
+	case a == netip.MustParseAddr("127.0.0.53"):
+		return "_localdnsstub"
+	case a.IsLoopback():
+		return "localhost"
+	case a == hostAddr():
+		return HostPersona.Hostname
+	case a.String() == HostPersona.Gateway:
+		return "_gateway"
+	}
+	return a.String()
+}
+
+func servName(port uint16, numeric bool) string {
+	if name, ok := portNames[port]; ok && !numeric {
+		return name
+	}
+	return strconv.Itoa(int(port))
+}
+
+// cmdRoute 输出 route 或 netstat -r 的路由表
+func (t *Terminal) cmdRoute(args []string, out io.Writer) {
+	numeric, netstat := false, args[0] == "netstat"
+	for _, a := range args[1:] {
+		switch {
+		case a == "add" || a == "del":
+			if t.userName() != "root" {
+				op := map[string]string{"add": "SIOCADDRT", "del": "SIOCDELRT"}[a]
+				fmt.Fprintf(t.Stderr, "%s: Operation not permitted\n", op)
+				t.lastExitCode = 7
+			}
+			return
+		case strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--"):
+			numeric = numeric || strings.Contains(a, "n")
+		}
+	}
+	io.WriteString(out, "Kernel IP routing table\n")
+	if netstat {
+		io.WriteString(out, "Destination     Gateway         Genmask         Flags   MSS Window  irtt Iface\n")
+	} else {
+		io.WriteString(out, "Destination     Gateway         Genmask         Flags Metric Ref    Use Iface\n")
+	}
+	for _, r := range netRoutes() {
+		dst, gw := r.Dst.Addr().String(), "0.0.0.0"
+		if r.Gateway.IsValid() {
+			gw = r.Gateway.String()
+		}
+		flags := "U"
+		if r.Gateway.IsValid() {
+			flags += "G"
+		}
+		if r.Dst.IsSingleIP() {
+			flags += "H"
+		}
+		if !numeric {
+			if r.Dst.Bits() == 0 {
+				dst = "default"
+			} else {
+				dst = hostName(r.Dst.Addr())
+			}
+			if r.Gateway.IsValid() {
+				gw = hostName(r.Gateway)
+			}
+		}
+		if netstat {
+			fmt.Fprintf(out, "%-15s %-15s %-15s %-5s %5d %-6d %4d %s\n", dst, gw, ipv4Mask(r.Dst.Bits()), flags, 0, 0, 0, r.Dev)
+		} else {
+			fmt.Fprintf(out, "%-15s %-15s %-15s %-5s %-6d %-2d %7d %s\n", dst, gw, ipv4Mask(r.Dst.Bits()), flags, r.Metric, 0, 0, r.Dev)
+		}
+	}
+}
+
+func (t *Terminal) cmdArp(args []string, out io.Writer) {
+	numeric, bsd := false, false
+	for _, a := range args[1:] {
+		switch {
+		case a == "-s" || a == "-d" || a == "--set" || a == "--delete":
+			if t.userName() != "root" {
+				if a == "-s" || a == "--set" {
+					fmt.Fprintln(t.Stderr, "SIOCSARP: Operation not permitted")
+				} else {
+					fmt.Fprintln(t.Stderr, "SIOCDARP(dontpub): Operation not permitted")
+				}
+				t.lastExitCode = 1
+			}
+			return
+		case strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--"):
+			numeric = numeric || strings.Contains(a, "n")
+			bsd = bsd || strings.Contains(a, "a")
+		}
+	}
+	if !bsd {
+		io.WriteString(out, "Address                  HWtype  HWaddress           Flags Mask            Iface\n")
+	}
+	for _, n := range netNeighbors() {
+		name := n.IP.String()
+		if !numeric {
+			name = hostName(n.IP)
+		}
+		if bsd {
+			if name == n.IP.String() && !numeric {
+				name = "?"
+			}
+			fmt.Fprintf(out, "%s (%s) at %s [ether] on %s\n", name, n.IP, n.MAC, n.Dev)
+			continue
+		}
+		fmt.Fprintf(out, "%-24s %-7s %-19s %-5s %-15s %s\n", name, "ether", n.MAC, "C", "", n.Dev)
+	}
+}
+
+// netOpts 是 netstat/ss 共用的选项
+type netOpts struct {
+	tcp, udp, listen, all, numeric, procs bool
+	v4, v6                                bool
+}
+
+// parseNetOpts 解析 -tulanp4 等短选项和对应的长选项，返回无法识别的选项
+func parseNetOpts(args []string, extra func(c byte) bool) (netOpts, string) {
+	o := netOpts{v4: true, v6: true}
+	long := map[string]byte{
+		"--tcp": 't', "--udp": 'u', "--listening": 'l', "--all": 'a', "--numeric": 'n',
+		"--programs": 'p', "--processes": 'p', "--ipv4": '4', "--ipv6": '6',
+	}
+	set := func(c byte) bool {
+		switch c {
+		case 't':
+			o.tcp = true
+		case 'u':
+			o.udp = true
+		case 'l':
+			o.listen = true
+		case 'a':
+			o.all = true
+		case 'n':
+			o.numeric = true
+		case 'p':
+			o.procs = true
+		case '4':
+			o.v6 = false
+		case '6':
+			o.v4 = false
+		default:
+			return extra(c)
+		}
+		return true
+	}
+	for _, a := range args[1:] {
+		if c, ok := long[a]; ok {
+			set(c)
+			continue
+		}
+		if !strings.HasPrefix(a, "-") || strings.HasPrefix(a, "--") {
+			return o, a
+		}
+		for k := 1; k < len(a); k++ {
+			if !set(a[k]) {
+				return o, string(a[k])
+			}
+		}
+	}
+	if !o.tcp && !o.udp {
+		o.tcp, o.udp = true, true
+	}
+	return o, ""
+}
+
+// selectSockets 按选项筛选套接字：默认只显示已建立的连接，-l 只显示监听端口，-a 显示全部
+func (o netOpts) selectSockets() []netSocket {
+	var res []netSocket
+	for _, s := range netSockets() {
+		tcp := s.Proto != "udp"
+		listening := s.State != "ESTABLISHED"
+		v6 := s.Proto == "tcp6"
+		switch {
+		case tcp && !o.tcp, !tcp && !o.udp:
+		case v6 && !o.v6, !v6 && !o.v4:
+		case !o.all && o.listen != listening:
+		default:
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// sockProcess 返回套接字所属的进程，非 root 用户看不到别人的进程
+func (t *Terminal) sockProcess(s netSocket) (Process, bool) {
+	p, ok := Procs.Get(s.PID)
+	if !ok {
+		return p, false
+	}
+	if u := t.userName(); u != "root" && u != p.User {
+		return p, false
+	}
+	return p, true
+}
+
+func (t *Terminal) cmdNetstat(args []string, out io.Writer) {
+	var route, ifaces bool
+	o, bad := parseNetOpts(args, func(c byte) bool {
+		switch c {
+		case 'r':
+			route = true
+		case 'i':
+			ifaces = true
+		case 'e', 'v', 'W', 'w', 'x':
+		default:
+			return false
+		}
+		return true
+	})
+	if bad != "" {
+		fmt.Fprintf(t.Stderr, "netstat: invalid option -- '%s'\nusage: netstat [-vWeenNcCF] [<Af>] -r         netstat {-V|--version|-h|--help}\n", strings.TrimLeft(bad, "-"))
+		t.lastExitCode = 1
+		return
+	}
+	if route {
+		t.cmdRoute([]string{"netstat", map[bool]string{true: "-n"}[o.numeric]}, out)
+		return
+	}
+	if ifaces {
+		io.WriteString(out, "Kernel Interface table\n")
+		fmt.Fprintf(out, "%-15s%6s %8s %6s %6s %-6s %8s %6s %6s %6s %s\n", "Iface", "MTU", "RX-OK", "RX-ERR", "RX-DRP", "RX-OVR", "TX-OK", "TX-ERR", "TX-DRP", "TX-OVR", "Flg")
+		for _, i := range netIfaces()[1:] {
+			_, rxP, _, txP := i.counters()
+			fmt.Fprintf(out, "%-15s%6d %8d %6d %6d %-6d %8d %6d %6d %6d %s\n", i.Name, i.MTU, rxP, 0, 0, 0, txP, 0, 0, 0, "BMRU")
+		}
+		lo := netIfaces()[0]
+		_, rxP, _, txP := lo.counters()
+		fmt.Fprintf(out, "%-15s%6d %8d %6d %6d %-6d %8d %6d %6d %6d %s\n", lo.Name, lo.MTU, rxP, 0, 0, 0, txP, 0, 0, 0, "LRU")
+		return
+	}
+
+	if o.procs && t.userName() != "root" {
+		fmt.Fprintln(t.Stderr, "(Not all processes could be identified, non-owned process info\n will not be shown, you would have to be root to see it all.)")
+	}
+	switch {
+	case o.all:
+		io.WriteString(out, "Active Internet connections (servers and established)\n")
+	case o.listen:
+		io.WriteString(out, "Active Internet connections (only servers)\n")
+	default:
+		io.WriteString(out, "Active Internet connections (w/o servers)\n")
+	}
+	header := fmt.Sprintf("%-5s %6s %6s %-23s %-23s %-11s", "Proto", "Recv-Q", "Send-Q", "Local Address", "Foreign Address", "State")
+	if o.procs {
+		header += " PID/Program name    "
+	}
+	fmt.Fprintln(out, header)
+
+	addr := func(ap netip.AddrPort, wildcard bool) string {
+		a := ap.Addr()
+		host := a.String()
+		if !o.numeric && !a.IsUnspecified() {
+			host = hostName(a)
+		} else if !o.numeric && a.Is6() {
+			host = "[::]"
+		}
+		port := servName(ap.Port(), o.numeric)
+		if wildcard {
+			port = "*"
+		}
+		return host + ":" + port
+	}
+	for _, s := range o.selectSockets() {
+		line := fmt.Sprintf("%-5s %6d %6d %-23s %-23s %-11s", s.Proto, 0, 0,
+			addr(s.Local, false), addr(s.Remote, s.State != "ESTABLISHED"), s.State)
+		if o.procs {
+			prog := "-"
+			if p, ok := t.sockProcess(s); ok {
+				prog = fmt.Sprintf("%d/%s", p.PID, p.Comm())
+			}
+			line += " " + prog
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+func (t *Terminal) cmdSs(args []string, out io.Writer) {
+	noHeader := false
+	o, bad := parseNetOpts(args, func(c byte) bool {
+		switch c {
+		case 'H':
+			noHeader = true
+		case 'e', 'o', 'i', 'm', 'r':
+		default:
+			return false
+		}
+		return true
+	})
+	if bad != "" {
+		fmt.Fprintf(t.Stderr, "ss: invalid option -- '%s'\nUsage: ss [ OPTIONS ]\n       ss [ OPTIONS ] [ FILTER ]\n", strings.TrimLeft(bad, "-"))
+		t.lastExitCode = 255
+		return
+	}
+	// 同时显示 TCP 和 UDP 时多一列 Netid
+	netid := o.tcp && o.udp
+	format := "%-9s %-6s %-6s %20s:%-8s %20s:%-8s %s"
+	if netid {
+		format = "%-6s " + format
+	}
+	row := func(cols ...any) {
+		if !netid {
+			cols = cols[1:]
+		}
+		fmt.Fprintln(out, strings.TrimRight(fmt.Sprintf(format, cols...), " "))
+	}
+	if !noHeader {
+		row("Netid", "State", "Recv-Q", "Send-Q", "Local Address", "Port", "Peer Address", "Port", "Process")
+	}
+
+	host := func(s netSocket, ap netip.AddrPort) string {
+		a := ap.Addr()
+		h := a.String()
+		if a.Is6() {
+			h = "[" + h + "]"
+		}
+		// 绑定到具体网卡的套接字显示为 addr%dev
+		if ap == s.Local && (a == netip.MustParseAddr("127.0.0.53") || (s.Proto == "udp" && a == hostAddr())) {
+			if a.IsLoopback() {
+				h += "%lo"
+			} else {
+				h += "%eth0"
+			}
+		}
+		return h
+	}
+	for _, s := range o.selectSockets() {
+		state := map[string]string{"LISTEN": "LISTEN", "ESTABLISHED": "ESTAB", "": "UNCONN"}[s.State]
+		sendQ := 0
+		if s.State == "LISTEN" {
+			sendQ = s.Backlog
+		}
+		peerPort := "*"
+		if s.State == "ESTABLISHED" {
+			peerPort = servName(s.Remote.Port(), o.numeric)
+		}
+		proc := ""
+		if o.procs {
+			if p, ok := t.sockProcess(s); ok {
+				proc = fmt.Sprintf("users:((%q,pid=%d,fd=%d))", p.Comm(), p.PID, s.FD)
+			}
+		}
+		row(strings.TrimSuffix(s.Proto, "6"), state, strconv.Itoa(0), strconv.Itoa(sendQ),
+			host(s, s.Local), servName(s.Local.Port(), o.numeric), host(s, s.Remote), peerPort, proc)
+	}
+}
+
+func (t *Terminal) cmdHostname(args []string, out io.Writer) {
+	if len(args) < 2 {
+		fmt.Fprintln(out, HostPersona.Hostname)
+		return
+	}
+	switch args[1] {
+	case "-I", "--all-ip-addresses":
+		fmt.Fprintln(out, HostPersona.IPAddr+" ")
+	case "-i", "--ip-address":
+		fmt.Fprintln(out, "127.0.1.1")
+	case "-f", "--fqdn", "--long", "-A", "--all-fqdns", "-s", "--short":
+		fmt.Fprintln(out, HostPersona.Hostname)
+	case "-d", "--domain", "-y", "--yp", "--nis":
+		fmt.Fprintln(out)
+	default:
+		if strings.HasPrefix(args[1], "-") {
+			fmt.Fprintf(t.Stderr, "hostname: invalid option -- '%s'\nUsage: hostname [-b] {hostname|-F file}         set host name (from file)\n", strings.TrimLeft(args[1], "-"))
+			t.lastExitCode = 1
+			return
+		}
+		if t.userName() != "root" {
+			fmt.Fprintln(t.Stderr, "hostname: you must be root to change the host name")
+			t.lastExitCode = 1
+		}
+	}
+}
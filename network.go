@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// 虚拟网络
+// 网卡、路由、邻居和监听端口由 HostPersona 推导，已建立的连接来自各服务收到的真实连接，
+// ip/ifconfig/route/arp/netstat/ss、/proc/net、/sys/class/net 和 /etc/hosts 都从这里取数据
+// ==========================================
+
+// netIface 网卡
+type netIface struct {
+	Index    int
+	Name     string
+	MAC      string
+	Addr     netip.Prefix
+	MTU      int
+	Loopback bool
+}
+
+func netIfaces() []netIface {
+	return []netIface{
+		{Index: 1, Name: "lo", MAC: "00:00:00:00:00:00", Addr: netip.MustParsePrefix("127.0.0.1/8"), MTU: 65536, Loopback: true},
+		{Index: 2, Name: "eth0", MAC: HostPersona.MACAddr, Addr: netip.PrefixFrom(hostAddr(), 24), MTU: 1500},
+	}
+}
+
+func findIface(name string) (netIface, bool) {
+	for _, i := range netIfaces() {
+		if i.Name == name {
+			return i, true
+		}
+	}
+	return netIface{}, false
+}
+
+func hostAddr() netip.Addr { return netip.MustParseAddr(HostPersona.IPAddr) }
+
+// ipv4Mask 返回前缀长度对应的点分掩码
+func ipv4Mask(bits int) netip.Addr {
+	var a [4]byte
+	binary.BigEndian.PutUint32(a[:], ^uint32(0)<<(32-bits))
+	return netip.AddrFrom4(a)
+}
+
+// broadcast 返回网卡所在子网的广播地址
+func (i netIface) broadcast() netip.Addr {
+	a := i.Addr.Masked().Addr().As4()
+	binary.BigEndian.PutUint32(a[:], binary.BigEndian.Uint32(a[:])|^uint32(0)>>i.Addr.Bits())
+	return netip.AddrFrom4(a)
+}
+
+// inet6 返回网卡的 IPv6 地址：lo 为 ::1，其余为由 MAC 按 EUI-64 生成的链路本地地址
+func (i netIface) inet6() netip.Prefix {
+	if i.Loopback {
+		return netip.PrefixFrom(netip.IPv6Loopback(), 128)
+	}
+	hw, _ := net.ParseMAC(i.MAC)
+	a := [16]byte{0: 0xfe, 1: 0x80, 8: hw[0] ^ 2, 9: hw[1], 10: hw[2], 11: 0xff, 12: 0xfe, 13: hw[3], 14: hw[4], 15: hw[5]}
+	return netip.PrefixFrom(netip.AddrFrom16(a), 64)
+}
+
+// counters 返回收发的字节数和包数，随开机时长增长
+func (i netIface) counters() (rxBytes, rxPackets, txBytes, txPackets int64) {
+	up := int64(time.Since(startTime).Seconds())
+	if i.Loopback {
+		rxBytes = 1_284_530 + up*410
+		return rxBytes, rxBytes / 118, rxBytes, rxBytes / 118
+	}
+	rxBytes = 48_213_774 + up*3_170
+	txBytes = 9_870_412 + up*1_240
+	return rxBytes, rxBytes / 712, txBytes, txBytes / 341
+}
+
+// netRoute 路由表项，Gateway 无效表示直连
+type netRoute struct {
+	Dst     netip.Prefix
+	Gateway netip.Addr
+	Dev     string
+	Proto   string
+	Scope   string // 空表示 global
+	Src     netip.Addr
+	Metric  int
+}
+
+func netRoutes() []netRoute {
+	ip := hostAddr()
+	gw := netip.MustParseAddr(HostPersona.Gateway)
+	return []netRoute{
+		{Dst: netip.PrefixFrom(netip.IPv4Unspecified(), 0), Gateway: gw, Dev: "eth0", Proto: "dhcp", Src: ip, Metric: 100},
+		{Dst: netip.PrefixFrom(ip, 24).Masked(), Dev: "eth0", Proto: "kernel", Scope: "link", Src: ip, Metric: 100},
+		{Dst: netip.PrefixFrom(gw, 32), Dev: "eth0", Proto: "dhcp", Scope: "link", Src: ip, Metric: 100},
+	}
+}
+
+// routeTo 按最长前缀匹配选出到 dst 的路由
+func routeTo(dst netip.Addr) (netRoute, bool) {
+	if dst.IsLoopback() {
+		return netRoute{Dst: netip.PrefixFrom(dst, 32), Dev: "lo", Scope: "host", Src: dst}, true
+	}
+	var best netRoute
+	found := false
+	for _, r := range netRoutes() {
+		if r.Dst.Contains(dst) && (!found || r.Dst.Bits() > best.Dst.Bits()) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// netNeighbor ARP 表项
+type netNeighbor struct {
+	IP    netip.Addr
+	MAC   string
+	Dev   string
+	State string
+}
+
+// netNeighbors 返回网关、同网段的一台常驻主机，以及同网段内当前连入的对端
+func netNeighbors() []netNeighbor {
+	subnet := netip.PrefixFrom(hostAddr(), 24).Masked()
+	peer := subnet.Addr().As4()
+	peer[3] = 5
+	res := []netNeighbor{
+		{netip.MustParseAddr(HostPersona.Gateway), HostPersona.GatewayMAC, "eth0", "REACHABLE"},
+		{netip.AddrFrom4(peer), "00:0c:29:4f:8e:21", "eth0", "STALE"},
+	}
+	seen := map[netip.Addr]bool{res[0].IP: true, res[1].IP: true, hostAddr(): true}
+	for _, s := range netSockets() {
+		a := s.Remote.Addr()
+		if s.State != "ESTABLISHED" || !subnet.Contains(a) || seen[a] {
+			continue
+		}
+		seen[a] = true
+		b := a.As4()
+		res = append(res, netNeighbor{a, fmt.Sprintf("52:54:00:%02x:%02x:%02x", b[1], b[2], b[3]), "eth0", "REACHABLE"})
+	}
+	return res
+}
+
+// netSocket 套接字
+type netSocket struct {
+	Proto   string // tcp、tcp6、udp
+	Local   netip.AddrPort
+	Remote  netip.AddrPort
+	State   string // LISTEN、ESTABLISHED，UDP 为空
+	Backlog int    // 监听队列长度 (ss 的 Send-Q)
+	PID     int
+	FD      int
+	Inode   int
+}
+
+// netListeners 返回守护进程的监听端口。本程序实际提供的 ssh/telnet/ftp/http 等服务
+// 按 persona 中的标准端口显示，而不是进程真正监听的端口
+func netListeners() []netSocket {
+	any4, any6 := netip.IPv4Unspecified(), netip.IPv6Unspecified()
+	local := netip.MustParseAddr("127.0.0.1")
+	stub := netip.MustParseAddr("127.0.0.53")
+	res := []netSocket{
+		{Proto: "tcp", Local: netip.AddrPortFrom(stub, 53), Backlog: 4096, PID: 577, FD: 14},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 22), Backlog: 128, PID: 832, FD: 3},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 21), Backlog: 32, PID: 845, FD: 3},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 23), Backlog: 64, PID: 858, FD: 4},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 512), Backlog: 64, PID: 858, FD: 5},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 513), Backlog: 64, PID: 858, FD: 6},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 514), Backlog: 64, PID: 858, FD: 7},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 6379), Backlog: 511, PID: 861, FD: 6},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 3306), Backlog: 151, PID: 902, FD: 23},
+		{Proto: "tcp", Local: netip.AddrPortFrom(local, 33060), Backlog: 70, PID: 902, FD: 21},
+		{Proto: "tcp", Local: netip.AddrPortFrom(any4, 25), Backlog: 100, PID: 1102, FD: 13},
+		{Proto: "tcp6", Local: netip.AddrPortFrom(any6, 22), Backlog: 128, PID: 832, FD: 4},
+		{Proto: "tcp6", Local: netip.AddrPortFrom(any6, 80), Backlog: 511, PID: 950, FD: 4},
+		{Proto: "udp", Local: netip.AddrPortFrom(stub, 53), PID: 577, FD: 13},
+		{Proto: "udp", Local: netip.AddrPortFrom(hostAddr(), 68), PID: 575, FD: 19},
+	}
+	for i := range res {
+		if res[i].Proto != "udp" {
+			res[i].State = "LISTEN"
+		}
+		if res[i].Proto == "tcp6" {
+			res[i].Remote = netip.AddrPortFrom(any6, 0)
+		} else {
+			res[i].Remote = netip.AddrPortFrom(any4, 0)
+		}
+		res[i].Inode = 21840 + i*37
+	}
+	return res
+}
+
+// netConns 是各服务当前持有的真实连接
+var netConns = struct {
+	sync.Mutex
+	seq   int
+	conns map[int]netSocket // inode -> 连接
+}{seq: 40000, conns: make(map[int]netSocket)}
+
+// trackPeer 登记一条从 remote ("ip:port") 连到本机服务端口 port 的连接，返回注销函数
+func trackPeer(remote string, port int) func() {
+	rap, err := netip.ParseAddrPort(remote)
+	if err != nil {
+		return func() {}
+	}
+	rap = netip.AddrPortFrom(rap.Addr().Unmap(), rap.Port())
+	s := netSocket{Proto: "tcp", Remote: rap, State: "ESTABLISHED", PID: 1}
+	if rap.Addr().Is6() {
+		s.Proto = "tcp6"
+	}
+	switch {
+	case rap.Addr().IsLoopback():
+		s.Local = netip.AddrPortFrom(rap.Addr(), uint16(port))
+	case rap.Addr().Is6():
+		eth, _ := findIface("eth0")
+		s.Local = netip.AddrPortFrom(eth.inet6().Addr(), uint16(port))
+	default:
+		s.Local = netip.AddrPortFrom(hostAddr(), uint16(port))
+	}
+	// 连接的描述符排在监听进程已占用的描述符之后
+	fd := 3
+	for _, l := range netListeners() {
+		if int(l.Local.Port()) == port && l.Proto != "udp" {
+			s.PID = l.PID
+		}
+	}
+	for _, l := range netListeners() {
+		if l.PID == s.PID {
+			fd = max(fd, l.FD)
+		}
+	}
+
+	netConns.Lock()
+	netConns.seq++
+	s.Inode = netConns.seq
+	s.FD = fd + 1 + len(netConns.conns)
+	netConns.conns[s.Inode] = s
+	netConns.Unlock()
+	return func() {
+		netConns.Lock()
+		delete(netConns.conns, s.Inode)
+		netConns.Unlock()
+	}
+}
+
+// trackConn 登记服务收到的连接，port 为该服务在 persona 中的标准端口
+func trackConn(c net.Conn, port int) func() {
+	return trackPeer(c.RemoteAddr().String(), port)
+}
+
+// recoverConn 在连接处理函数中 defer 调用：畸形输入触发的 panic 只断开该连接，
+// 不会让整个蜜罐进程退出
+func recoverConn(c net.Conn) {
+	if r := recover(); r != nil {
+		log.Printf("[Panic] %s: %v\n%s", c.RemoteAddr(), r, debug.Stack())
+	}
+}
+
+var httpConns sync.Map // net.Conn -> 注销函数
+
+// trackHTTPConn 用作 http.Server 的 ConnState，随连接建立和关闭登记、注销
+func trackHTTPConn(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		httpConns.Store(c, trackConn(c, 80))
+	case http.StateClosed, http.StateHijacked:
+		if done, ok := httpConns.LoadAndDelete(c); ok {
+			done.(func())()
+		}
+	}
+}
+
+// netSockets 返回全部套接字：监听端口在前，已建立的连接按建立顺序在后
+func netSockets() []netSocket {
+	res := netListeners()
+	netConns.Lock()
+	conns := make([]netSocket, 0, len(netConns.conns))
+	for _, s := range netConns.conns {
+		conns = append(conns, s)
+	}
+	netConns.Unlock()
+	sort.Slice(conns, func(i, j int) bool { return conns[i].Inode < conns[j].Inode })
+	return append(res, conns...)
+}
+
+// portNames 摘自 /etc/services
+var portNames = map[uint16]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 68: "bootpc", 80: "http",
+	512: "exec", 513: "login", 514: "shell", 3306: "mysql", 6379: "redis",
+}
+
+// etcHosts 生成 /etc/hosts，主机名按 Debian 的习惯解析到 127.0.1.1
+func etcHosts() string {
+	return "127.0.0.1 localhost\n127.0.1.1 " + HostPersona.Hostname + "\n\n" +
+		"# The following lines are desirable for IPv6 capable hosts\n" +
+		"::1     ip6-localhost ip6-loopback\nfe00::0 ip6-localnet\nff00::0 ip6-mcastprefix\nff02::1 ip6-allnodes\nff02::2 ip6-allrouters\n"
+}
+
+// ---------- /proc/net 与 /sys/class/net ----------
+
+// procHexAddr 按 /proc/net/tcp 的格式编码地址：每个 32 位字按主机字节序 (小端) 输出
+func procHexAddr(ap netip.AddrPort, v6 bool) string {
+	var b strings.Builder
+	if v6 {
+		a := ap.Addr().As16()
+		for i := 0; i < 16; i += 4 {
+			fmt.Fprintf(&b, "%08X", binary.LittleEndian.Uint32(a[i:i+4]))
+		}
+	} else {
+		a := ap.Addr().Unmap().As4()
+		fmt.Fprintf(&b, "%08X", binary.LittleEndian.Uint32(a[:]))
+	}
+	fmt.Fprintf(&b, ":%04X", ap.Port())
+	return b.String()
+}
+
+// socketUID 返回套接字所属进程的 UID
+func socketUID(fs *SessionFS, s netSocket) int {
+	if p, ok := Procs.Get(s.PID); ok {
+		return max(fs.uid(p.User), 0)
+	}
+	return 0
+}
+
+func procNetSockets(fs *SessionFS, proto string) string {
+	v6 := proto == "tcp6"
+	var b strings.Builder
+	switch proto {
+	case "tcp":
+		b.WriteString("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode                                                     \n")
+	case "tcp6":
+		b.WriteString("  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n")
+	case "udp":
+		b.WriteString("   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops             \n")
+	}
+	sl := 0
+	for _, s := range netSockets() {
+		if s.Proto != proto {
+			continue
+		}
+		st := map[string]int{"ESTABLISHED": 0x01, "LISTEN": 0x0A, "": 0x07}[s.State]
+		local, remote := procHexAddr(s.Local, v6), procHexAddr(s.Remote, v6)
+		if proto == "udp" {
+			fmt.Fprintf(&b, "%5d: %s %s %02X 00000000:00000000 00:00000000 00000000 %5d        0 %d 2 0000000000000000 0        \n",
+				sl, local, remote, st, socketUID(fs, s), s.Inode)
+		} else {
+			fmt.Fprintf(&b, "%4d: %s %s %02X 00000000:00000000 00:00000000 00000000 %5d        0 %d 1 0000000000000000 100 0 0 10 0\n",
+				sl, local, remote, st, socketUID(fs, s), s.Inode)
+		}
+		sl++
+	}
+	return b.String()
+}
+
+func procNetTCP(fs *SessionFS) string  { return procNetSockets(fs, "tcp") }
+func procNetTCP6(fs *SessionFS) string { return procNetSockets(fs, "tcp6") }
+func procNetUDP(fs *SessionFS) string  { return procNetSockets(fs, "udp") }
+
+func procNetDev(*SessionFS) string {
+	var b strings.Builder
+	b.WriteString("Inter-|   Receive                                                |  Transmit\n")
+	b.WriteString(" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+	for _, i := range netIfaces() {
+		rxB, rxP, txB, txP := i.counters()
+		fmt.Fprintf(&b, "%6s: %7d %7d    0    0    0     0          0         0 %8d %7d    0    0    0     0       0          0\n",
+			i.Name, rxB, rxP, txB, txP)
+	}
+	return b.String()
+}
+
+func procNetRoute(*SessionFS) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-127s\n", "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT")
+	hex := func(a netip.Addr) uint32 {
+		if !a.IsValid() {
+			return 0
+		}
+		v := a.As4()
+		return binary.LittleEndian.Uint32(v[:])
+	}
+	for _, r := range netRoutes() {
+		flags := 0x0001 // RTF_UP
+		if r.Gateway.IsValid() {
+			flags |= 0x0002 // RTF_GATEWAY
+		}
+		if r.Dst.Bits() == 32 {
+			flags |= 0x0004 // RTF_HOST
+		}
+		fmt.Fprintf(&b, "%-127s\n", fmt.Sprintf("%s\t%08X\t%08X\t%04X\t0\t0\t%d\t%08X\t0\t0\t0",
+			r.Dev, hex(r.Dst.Addr()), hex(r.Gateway), flags, r.Metric, hex(ipv4Mask(r.Dst.Bits()))))
+	}
+	return b.String()
+}
+
+func procNetARP(*SessionFS) string {
+	var b strings.Builder
+	b.WriteString("IP address       HW type     Flags       HW address            Mask     Device\n")
+	for _, n := range netNeighbors() {
+		fmt.Fprintf(&b, "%-16s 0x1         0x2         %-21s *        %s\n", n.IP, n.MAC, n.Dev)
+	}
+	return b.String()
+}
+
+// sysNetFiles 为每块网卡在 /sys/class/net 下补充属性文件
+func sysNetFiles(vt virtualTree) virtualTree {
+	for _, i := range netIfaces() {
+		dir := "/sys/class/net/" + i.Name + "/"
+		state := "up"
+		if i.Loopback {
+			state = "unknown"
+		}
+		vt[dir+"address"] = ro(text(i.MAC + "\n"))
+		vt[dir+"operstate"] = ro(text(state + "\n"))
+		vt[dir+"mtu"] = virtualFile{0644, text(strconv.Itoa(i.MTU) + "\n")}
+		vt[dir+"ifindex"] = ro(text(strconv.Itoa(i.Index) + "\n"))
+		vt[dir+"statistics/rx_bytes"] = ro(func(*SessionFS) string {
+			rx, _, _, _ := i.counters()
+			return strconv.FormatInt(rx, 10) + "\n"
+		})
+		vt[dir+"statistics/tx_bytes"] = ro(func(*SessionFS) string {
+			_, _, tx, _ := i.counters()
+			return strconv.FormatInt(tx, 10) + "\n"
+		})
+	}
+	return vt
+}
@@ -0,0 +1,608 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// more / less
+// 输出不是终端时等同于 cat
+// ==========================================
+
+// lessHelp less 的 h 命令显示的帮助
+const lessHelp = `
+                   SUMMARY OF LESS COMMANDS
+
+      Commands marked with * may be preceded by a number, N.
+      Notes in parentheses indicate the behavior if N is given.
+
+  h  H                 Display this help.
+  q  :q  Q  :Q  ZZ     Exit.
+ ---------------------------------------------------------------------------
+
+                           MOVING
+
+  e  ^E  j  ^N  CR  *  Forward  one line   (or N lines).
+  y  ^Y  k  ^K  ^P  *  Backward one line   (or N lines).
+  f  ^F  ^V  SPACE  *  Forward  one window (or N lines).
+  b  ^B  ESC-v      *  Backward one window (or N lines).
+  d  ^D             *  Forward  one half-window (and set half-window to N).
+  u  ^U             *  Backward one half-window (and set half-window to N).
+  r  ^R  ^L            Repaint screen.
+ ---------------------------------------------------------------------------
+
+                          SEARCHING
+
+  /pattern          *  Search forward for (N-th) matching line.
+  ?pattern          *  Search backward for (N-th) matching line.
+  n                 *  Repeat previous search (for N-th occurrence).
+  N                 *  Repeat previous search in reverse direction.
+ ---------------------------------------------------------------------------
+
+                           JUMPING
+
+  g  <  ESC-<       *  Go to first line in file (or line N).
+  G  >  ESC->       *  Go to last line in file (or line N).
+  p  %              *  Go to beginning of file (or N percent into file).
+  =  ^G  :f            Print current file name.
+ ---------------------------------------------------------------------------
+`
+
+// moreHelp more 在按下未知按键时的提示
+const moreHelp = "[按空格键继续，“q”退出。]"
+
+// pagerInput 读取分页程序的输入：文件参数或标准输入；多个文件之间加上 more 风格的分隔
+func (t *Terminal) pagerInput(args []string, in io.Reader, out io.Writer) (name, text string, ok bool) {
+	cmd := args[0]
+	var files []string
+	for _, a := range args[1:] {
+		if !strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "+") {
+			files = append(files, a)
+		}
+	}
+	if len(files) == 0 {
+		if b, isBuf := in.(*bytes.Buffer); in == nil || isBuf && b.Len() == 0 {
+			if cmd == "less" {
+				fmt.Fprintln(out, "Missing filename (\"less --help\" for help)")
+			} else {
+				fmt.Fprintln(out, "用法：\n more [选项] <文件>...")
+			}
+			t.lastExitCode = 1
+			return "", "", false
+		}
+		data, _ := io.ReadAll(io.LimitReader(in, MaxFileSize))
+		return "", string(data), true
+	}
+
+	var b strings.Builder
+	for _, f := range files {
+		e, found := t.FS.GetEntry(t.FS.Abs(f))
+		switch {
+		case !found && cmd == "less":
+			fmt.Fprintf(out, "%s: 没有那个文件或目录\n", f)
+			t.lastExitCode = 1
+			continue
+		case !found:
+			fmt.Fprintf(out, "more: 无法打开 %s: 没有那个文件或目录\n", f)
+			t.lastExitCode = 1
+			continue
+		case e.IsDir && cmd == "less":
+			fmt.Fprintf(out, "%s 是一个目录\n", f)
+			t.lastExitCode = 1
+			continue
+		case e.IsDir:
+			fmt.Fprintf(out, "\n*** %s: 目录 ***\n\n", f)
+			continue
+		}
+		if len(files) > 1 {
+			fmt.Fprintf(&b, "::::::::::::::\n%s\n::::::::::::::\n", f)
+		}
+		e.mu.RLock()
+		b.Write(e.Content)
+		e.mu.RUnlock()
+		if name == "" {
+			name = f
+		}
+	}
+	if b.Len() == 0 {
+		return "", "", false
+	}
+	return name, b.String(), true
+}
+
+func (t *Terminal) cmdPager(args []string, in io.Reader, out io.Writer) {
+	name, text, ok := t.pagerInput(args, in, out)
+	if !ok {
+		return
+	}
+	if !isTTY(out) {
+		io.WriteString(out, text)
+		return
+	}
+	self := t.spawn(args)
+	defer Procs.Remove(self.PID)
+	if args[0] == "more" {
+		t.moreText(text, out)
+	} else {
+		t.lessText(name, "", text, out)
+	}
+}
+
+// pagerLines 把文本拆成行，去掉末尾的空行和 \r
+func pagerLines(text string) []string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// wrapANSI 按屏幕宽度折行，SGR 转义序列原样保留且不占宽度
+func wrapANSI(line string, width int) []string {
+	var rows []string
+	var b strings.Builder
+	x, col := 0, 0
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r == 27 && i+1 < len(rs) && rs[i+1] == '[' {
+			j := i + 2
+			for j < len(rs) && (rs[j] < 0x40 || rs[j] > 0x7e) {
+				j++
+			}
+			if j < len(rs) && rs[j] == 'm' {
+				b.WriteString(string(rs[i : j+1]))
+				i = j
+				continue
+			}
+		}
+		cw := cellWidth(r, col)
+		if x+cw > width && x > 0 {
+			rows = append(rows, b.String())
+			b.Reset()
+			x = 0
+		}
+		b.WriteString(cellText(r, col))
+		x += cw
+		col += cw
+	}
+	return append(rows, b.String())
+}
+
+// ==========================================
+// less
+// ==========================================
+
+type pager struct {
+	t   *Terminal
+	rs  *rawSession
+	out io.Writer
+
+	lines  []string
+	name   string // 状态行显示的文件名
+	prompt string // 自定义状态行 (man 使用)，%d 为首行行号
+
+	top    int
+	width  int
+	height int
+	count  int
+
+	inputKind rune // 正在输入的搜索：'/' 或 '?'
+	input     []rune
+	re        *regexp.Regexp
+	back      bool
+	match     int // 上一次匹配的行，n 从这里继续
+	msg       string
+	quit      bool
+}
+
+// lessText 全屏分页显示文本
+func (t *Terminal) lessText(name, prompt, text string, out io.Writer) {
+	rs := t.enterRaw()
+	defer rs.close()
+	fmt.Fprint(out, "\033[?1049h\033[H\033[2J")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+	p := &pager{t: t, rs: rs, out: out, lines: pagerLines(text), name: name, prompt: prompt, match: -1}
+	p.run()
+}
+
+func (p *pager) run() {
+	for !p.quit {
+		if err := p.render(); err != nil {
+			return
+		}
+		for {
+			ev, ok := p.rs.next(500 * time.Millisecond)
+			if ok {
+				p.handle(ev)
+				break
+			}
+			if p.rs.killed() {
+				return
+			}
+			if w, h := p.rs.size(); w != p.width || h != p.height {
+				break
+			}
+		}
+	}
+}
+
+// maxTop 返回最后一屏的首行：从文件尾往回数，能完整放进一屏的最靠前的行
+func (p *pager) maxTop() int {
+	rows := max(p.height-1, 1)
+	used := 0
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		used += len(wrapANSI(p.lines[i], p.width))
+		if used > rows {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (p *pager) highlight(line string) string {
+	if p.re == nil {
+		return line
+	}
+	return p.re.ReplaceAllStringFunc(line, func(m string) string {
+		return "\033[7m" + m + "\033[27m"
+	})
+}
+
+func (p *pager) render() error {
+	p.width, p.height = p.rs.size()
+	rows := max(p.height-1, 1)
+	maxTop := p.maxTop()
+	p.top = max(0, min(p.top, maxTop))
+
+	var b strings.Builder
+	b.WriteString("\033[?25l")
+	y, last := 0, p.top
+	for i := p.top; i < len(p.lines) && y < rows; i++ {
+		for _, r := range wrapANSI(p.highlight(p.lines[i]), p.width) {
+			if y >= rows {
+				break
+			}
+			fmt.Fprintf(&b, "\033[%d;1H%s\033[0m\033[K", y+1, r)
+			y++
+		}
+		last = i
+	}
+	for ; y < rows; y++ {
+		fmt.Fprintf(&b, "\033[%d;1H~\033[K", y+1)
+	}
+
+	fmt.Fprintf(&b, "\033[%d;1H\033[K", p.height)
+	total := len(p.lines)
+	switch {
+	case p.inputKind != 0:
+		b.WriteString(string(p.inputKind) + string(p.input))
+	case p.msg != "":
+		b.WriteString("\033[7m" + p.msg + "\033[0m")
+	case p.top >= maxTop:
+		b.WriteString("\033[7m(END)\033[0m")
+	case p.prompt != "":
+		b.WriteString("\033[7m" + strings.Replace(p.prompt, "%d", strconv.Itoa(p.top+1), 1) + "\033[0m")
+	default:
+		status := fmt.Sprintf("第 %d-%d/%d 行 %d%%", p.top+1, last+1, total, (last+1)*100/total)
+		if p.name != "" {
+			status = p.name + " " + status
+		}
+		b.WriteString("\033[7m" + status + "\033[0m")
+	}
+	b.WriteString("\033[?25h")
+	_, err := io.WriteString(p.out, b.String())
+	return err
+}
+
+func (p *pager) takeCount(def int) int {
+	n := p.count
+	p.count = 0
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+func (p *pager) handle(ev keyEvent) {
+	if p.inputKind != 0 {
+		p.inputKey(ev)
+		return
+	}
+	if p.msg != "" {
+		// "(按回车键)" 之类的消息吞掉一个按键
+		p.msg = ""
+		return
+	}
+	rows := max(p.height-1, 1)
+	r := ev.r
+	switch ev.code {
+	case keyUp:
+		r = 'k'
+	case keyDown:
+		r = 'j'
+	case keyHome:
+		r = 'g'
+	case keyEnd:
+		r = 'G'
+	case keyAlt:
+		switch ev.r {
+		case 'v':
+			r = 'b'
+		case '<':
+			r = 'g'
+		case '>':
+			r = 'G'
+		default:
+			return
+		}
+	case keyRune:
+	default:
+		return
+	}
+
+	if r >= '0' && r <= '9' {
+		p.count = p.count*10 + int(r-'0')
+		return
+	}
+	switch r {
+	case 'q', 'Q', 3:
+		p.quit = true
+	case ' ', 'f', 'z', 6, 22:
+		p.top += p.takeCount(rows)
+	case 'b', 'w', 2:
+		p.top -= p.takeCount(rows)
+	case 'j', 'e', '\r', '\n', 14, 5:
+		p.top += p.takeCount(1)
+	case 'k', 'y', 16, 25, 11:
+		p.top = max(p.top-p.takeCount(1), 0)
+	case 'd', 4:
+		p.top += p.takeCount(rows / 2)
+	case 'u', 21:
+		p.top = max(p.top-p.takeCount(rows/2), 0)
+	case 'g', '<':
+		p.top = p.takeCount(1) - 1
+	case 'G', '>':
+		if n := p.takeCount(0); n > 0 {
+			p.top = n - 1
+		} else {
+			p.top = p.maxTop()
+		}
+	case 'p', '%':
+		p.top = p.takeCount(0) * len(p.lines) / 100
+	case '/', '?':
+		p.count = 0
+		p.inputKind, p.input = r, nil
+	case 'n', 'N':
+		back := p.back
+		if r == 'N' {
+			back = !back
+		}
+		p.search(back, p.takeCount(1))
+	case 'h', 'H':
+		p.count = 0
+		sub := &pager{t: p.t, rs: p.rs, out: p.out, lines: pagerLines(lessHelp), prompt: "HELP -- Press RETURN for more, or q when done", match: -1}
+		sub.run()
+	case '=', 7:
+		p.count = 0
+		name := p.name
+		if name == "" {
+			name = "标准输入"
+		}
+		p.msg = fmt.Sprintf("%s 第 %d 行，共 %d 行", name, p.top+1, len(p.lines))
+	default:
+		p.count = 0
+	}
+	p.top = max(p.top, 0)
+}
+
+func (p *pager) inputKey(ev keyEvent) {
+	if ev.code != keyRune {
+		return
+	}
+	switch r := ev.r; r {
+	case 27, 3:
+		p.inputKind = 0
+	case '\r', '\n':
+		back := p.inputKind == '?'
+		p.inputKind = 0
+		if len(p.input) > 0 {
+			pat := string(p.input)
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				re = regexp.MustCompile(regexp.QuoteMeta(pat))
+			}
+			p.re, p.back, p.match = re, back, -1
+		}
+		if p.re == nil {
+			p.msg = "没有上一个正则表达式"
+			return
+		}
+		p.search(back, 1)
+	case 127, 8:
+		if len(p.input) == 0 {
+			p.inputKind = 0
+		} else {
+			p.input = p.input[:len(p.input)-1]
+		}
+	default:
+		if r >= 32 {
+			p.input = append(p.input, r)
+		}
+	}
+}
+
+// search 查找第 n 个匹配行，把它显示在屏幕首行
+func (p *pager) search(back bool, n int) {
+	if p.re == nil {
+		p.msg = "没有上一个正则表达式"
+		return
+	}
+	from := p.top
+	if p.match >= 0 {
+		from = p.match
+	}
+	found := -1
+	if back {
+		for i := from - 1; i >= 0 && n > 0; i-- {
+			if p.re.MatchString(p.lines[i]) {
+				found, n = i, n-1
+			}
+		}
+	} else {
+		for i := from + 1; i < len(p.lines) && n > 0; i++ {
+			if p.re.MatchString(p.lines[i]) {
+				found, n = i, n-1
+			}
+		}
+	}
+	if found < 0 || n > 0 {
+		p.msg = "找不到模式  (按回车键)"
+		return
+	}
+	p.match, p.top = found, found
+}
+
+// ==========================================
+// more
+// 不使用备用屏幕，逐屏向下输出
+// ==========================================
+
+// moreText 分页输出文本，内容一屏放得下时直接输出
+func (t *Terminal) moreText(text string, out io.Writer) {
+	lines := pagerLines(text)
+	w, h := t.screenSize()
+	rowsOf := func(l string) int { return len(wrapANSI(l, w)) }
+	total := 0
+	for _, l := range lines {
+		total += rowsOf(l)
+	}
+	if total < h {
+		io.WriteString(out, text)
+		return
+	}
+
+	rs := t.enterRaw()
+	defer rs.close()
+	pos := 0
+	show := func(n int) {
+		for ; n > 0 && pos < len(lines); pos++ {
+			fmt.Fprintf(out, "%s\033[0m\n", lines[pos])
+			n -= rowsOf(lines[pos])
+		}
+	}
+	wait := func() (keyEvent, bool) {
+		for {
+			if ev, ok := rs.next(time.Second); ok {
+				return ev, true
+			}
+			if rs.killed() {
+				return keyEvent{}, false
+			}
+		}
+	}
+	var re *regexp.Regexp
+	find := func() {
+		for i := pos; i < len(lines); i++ {
+			if re.MatchString(lines[i]) {
+				fmt.Fprint(out, "\n...跳过\n")
+				pos = max(i-2, 0)
+				show(h - 3)
+				return
+			}
+		}
+		fmt.Fprint(out, "\033[7m模式未找到\033[0m")
+		wait()
+		fmt.Fprint(out, "\r\033[K")
+	}
+
+	show(h - 1)
+	count := 0
+	for pos < len(lines) {
+		w, h = rs.size()
+		fmt.Fprintf(out, "\033[7m--更多--(%d%%)\033[0m", pos*100/len(lines))
+		ev, ok := wait()
+		fmt.Fprint(out, "\r\033[K")
+		if !ok {
+			return
+		}
+		r := ev.r
+		if ev.code == keyDown {
+			r = '\r'
+		} else if ev.code != keyRune {
+			continue
+		}
+		if r >= '0' && r <= '9' {
+			count = count*10 + int(r-'0')
+			continue
+		}
+		n := count
+		count = 0
+		switch r {
+		case 'q', 'Q', 3:
+			return
+		case ' ', 'z', 'f':
+			if n == 0 {
+				n = h - 1
+			}
+			show(n)
+		case '\r', '\n', 'j':
+			show(max(n, 1))
+		case 'd', 4:
+			show(max(n, 11))
+		case 'b', 2:
+			pos = max(pos-2*(h-1), 0)
+			fmt.Fprint(out, "\033[H\033[2J")
+			show(h - 1)
+		case '=':
+			fmt.Fprintf(out, "\033[7m%d\033[0m", pos)
+			wait()
+			fmt.Fprint(out, "\r\033[K")
+		case '/':
+			fmt.Fprint(out, "/")
+			var pat []rune
+			for {
+				ev, ok := wait()
+				if !ok {
+					return
+				}
+				if ev.code != keyRune {
+					continue
+				}
+				if ev.r == '\r' || ev.r == '\n' || ev.r == 27 || ev.r == 3 {
+					break
+				}
+				if (ev.r == 127 || ev.r == 8) && len(pat) > 0 {
+					pat = pat[:len(pat)-1]
+					fmt.Fprint(out, "\b \b")
+				} else if ev.r >= 32 {
+					pat = append(pat, ev.r)
+					fmt.Fprint(out, string(ev.r))
+				}
+			}
+			fmt.Fprint(out, "\r\033[K")
+			if len(pat) == 0 {
+				continue
+			}
+			var err error
+			if re, err = regexp.Compile(string(pat)); err != nil {
+				re = regexp.MustCompile(regexp.QuoteMeta(string(pat)))
+			}
+			find()
+		case 'n':
+			if re != nil {
+				find()
+			}
+		default:
+			fmt.Fprintf(out, "\033[7m%s\033[0m", moreHelp)
+			wait()
+			fmt.Fprint(out, "\r\033[K")
+		}
+	}
+}
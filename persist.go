@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"path"
+	"strings"
+)
+
+// ==========================================
+// 持久化检测
+// 计划任务 (cron/at)、SSH 公钥、新增账户和 sudoers 是最常见的持久化手段。无论经由哪种服务写入
+// (shell 重定向、crontab、at、SFTP、FTP、Redis SAVE、MySQL INTO OUTFILE)，
+// 这些位置一旦变化就记录事件：新增的行逐条作为 IOC 输出，文件内容交给载荷捕获
+// ==========================================
+
+// persistKind 返回路径所属的持久化位置，无关路径返回空串
+func persistKind(p string) string {
+	switch {
+	case p == "/etc/crontab", strings.HasPrefix(p, "/etc/cron.") && p != "/etc/cron.allow" && p != "/etc/cron.deny",
+		strings.HasPrefix(p, "/var/spool/cron/crontabs/"):
+		return "cron"
+	case strings.HasPrefix(p, "/var/spool/cron/atjobs/") && !strings.HasPrefix(path.Base(p), "."):
+		return "at"
+	case (path.Base(p) == "authorized_keys" || path.Base(p) == "authorized_keys2") && path.Base(path.Dir(p)) == ".ssh":
+		return "ssh"
+	case p == "/etc/passwd" || p == "/etc/shadow" || p == "/etc/group" || p == "/etc/gshadow":
+		return "account"
+	case p == "/etc/sudoers" || strings.HasPrefix(p, "/etc/sudoers.d/"):
+		return "sudoers"
+	}
+	return ""
+}
+
+// persistEvent 记录持久化位置的一次变化
+func persistEvent(remote, op, p string, old, data []byte) {
+	if op == "remove" && old == nil || op == "write" && bytes.Equal(old, data) {
+		return
+	}
+	kind, full := persistKind(p), data
+	log.Printf("[Persistence] %s: %s %s %s (%d bytes)", remote, kind, op, p, len(data))
+	if op != "write" {
+		return
+	}
+	// at 作业文件中只有 here-document 里的命令来自攻击者
+	if kind == "at" {
+		old, data = atCommands(old), atCommands(data)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(old), "\n") {
+		seen[strings.TrimSpace(line)] = true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seen[line] = true
+		log.Printf("[Persistence] %s: %s + %s", remote, p, line)
+	}
+	CapturePayload("persistence", remote, p, full)
+}
+
+// atCommands 取出 at 作业文件中 here-document 包含的命令
+func atCommands(job []byte) []byte {
+	_, rest, ok := bytes.Cut(job, []byte("${SHELL:-/bin/sh} << '"))
+	if !ok {
+		return job
+	}
+	delim, body, _ := bytes.Cut(rest, []byte("'\n"))
+	body, _, _ = bytes.Cut(body, []byte(string(delim)+"\n"))
+	return body
+}
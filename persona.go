@@ -0,0 +1,91 @@
+package main
+
+// ==========================================
+// 主机画像 (Host Persona)
+// 所有服务的版本横幅、主机名、内核信息都从这里取值，保证各协议看到的主机一致
+// ==========================================
+
+type Persona struct {
+	Hostname    string
+	OSName      string // 如 "Ubuntu 22.04.1 LTS"
+	Kernel      string // uname -r
+	KernelBuild string // uname -v
+	Arch        string
+	CPUModel    string // /proc/cpuinfo 的 model name
+	CPUs        int
+	IPAddr      string // eth0 的 IPv4 地址，子网为 /24
+	MACAddr     string
+	Gateway     string // 默认网关
+	GatewayMAC  string
+
+	SSHVersion   string // SSH 协议横幅
+	HTTPServer   string // HTTP Server 头
+	FTPBanner    string // FTP 220 欢迎语
+	SMTPBanner   string // SMTP 220 欢迎语 (主机名之后的部分)
+	RedisVersion string
+	MySQLVersion string
+	BashVersion  string // $BASH_VERSION
+
+	// 预置的 ~/.bash_history，让主机看起来有人在日常维护
+	RootHistory []string
+	UserHistory []string
+}
+
+// HostPersona 当前模拟的主机
+var HostPersona = &Persona{
+	Hostname:    "ubuntu-server",
+	OSName:      "Ubuntu 22.04.1 LTS",
+	Kernel:      "5.15.0-generic",
+	KernelBuild: "#1 SMP Fri Jan 1 00:00:00 UTC 2022",
+	Arch:        "x86_64",
+	CPUModel:    "Intel(R) Core(TM) i7-10700 CPU @ 2.90GHz",
+	CPUs:        2,
+	IPAddr:      "192.168.1.10",
+	MACAddr:     "00:11:22:33:44:55",
+	Gateway:     "192.168.1.1",
+	GatewayMAC:  "a0:36:9f:1a:2b:3c",
+
+	SSHVersion:   "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1",
+	HTTPServer:   "Apache/2.4.52 (Ubuntu)",
+	FTPBanner:    "(vsFTPd 3.0.5)",
+	SMTPBanner:   "ESMTP Postfix (Ubuntu)",
+	RedisVersion: "6.0.16",
+	MySQLVersion: "8.0.32-0ubuntu0.22.04.2",
+	BashVersion:  "5.1.16(1)-release",
+
+	RootHistory: []string{
+		"apt update",
+		"apt upgrade -y",
+		"apt install -y apache2 mysql-server redis-server",
+		"systemctl enable --now apache2",
+		"mysql_secure_installation",
+		"vim /etc/redis/redis.conf",
+		"systemctl restart redis-server",
+		"ufw status",
+		"df -h",
+		"free -m",
+		"tail -f /var/log/apache2/error.log",
+		"cd /var/www/html",
+		"ls -la",
+		"chown -R www-data:www-data /var/www/html",
+		"mysqldump -u root -p --all-databases > /root/backup.sql",
+		"crontab -e",
+		"systemctl status mysql",
+		"netstat -tlnp",
+		"journalctl -u ssh --since today",
+		"reboot",
+	},
+	UserHistory: []string{
+		"ls",
+		"sudo apt update",
+		"cd /var/www/html",
+		"git pull",
+		"sudo systemctl reload apache2",
+		"exit",
+	},
+}
+
+// Uname 返回 uname -a 的输出
+func (p *Persona) Uname() string {
+	return "Linux " + p.Hostname + " " + p.Kernel + " " + p.KernelBuild + " " + p.Arch + " " + p.Arch + " " + p.Arch + " GNU/Linux"
+}
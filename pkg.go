@@ -0,0 +1,1386 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 软件包管理: apt, apt-get, apt-cache, dpkg, pip
+// 安装状态保存在会话文件系统的 /var/lib/dpkg/status 中，新装软件提供的程序以占位文件出现在 /usr/bin；
+// 攻击者请求安装的软件包名作为情报记录到日志。
+// 只实现 Debian 系的 apt/dpkg 和 pip：主机人设是 Ubuntu，yum、dnf、rpm 与真实系统一样
+// 走 command-not-found (rpm、dnf 可从软件源安装，yum 只提示相似命令)
+// ==========================================
+
+// debPackage 软件源 (jammy) 中的一个软件包
+type debPackage struct {
+	Name, Version, Section, Desc string
+	Universe                     bool     // 来自 universe 组件，否则为 main
+	Depends                      []string // 不在目录中的依赖视为已满足
+	Bins                         []string // 提供的程序，不带目录的位于 /usr/bin
+	Installed                    bool     // 系统预装
+	Essential                    bool     // 不允许卸载
+}
+
+var debCatalog = []debPackage{
+	// 预装软件
+	{Name: "adduser", Version: "3.118ubuntu5", Section: "admin", Desc: "add and remove users and groups", Bins: []string{"/usr/sbin/adduser", "/usr/sbin/deluser"}, Installed: true},
+	{Name: "apache2", Version: "2.4.52-1ubuntu4.7", Section: "httpd", Desc: "Apache HTTP Server", Bins: []string{"/usr/sbin/apache2ctl", "/usr/sbin/a2enmod"}, Installed: true},
+	{Name: "apt", Version: "2.4.11", Section: "admin", Desc: "commandline package manager", Bins: []string{"apt", "apt-get", "apt-cache"}, Installed: true, Essential: true},
+	{Name: "at", Version: "3.2.5-1ubuntu1", Section: "admin", Desc: "Delayed job execution and batch processing", Bins: []string{"at", "atq", "atrm", "batch", "/usr/sbin/atd"}, Installed: true},
+	{Name: "bash", Version: "5.1-6ubuntu1", Section: "shells", Desc: "GNU Bourne Again SHell", Bins: []string{"/bin/bash"}, Installed: true, Essential: true},
+	{Name: "coreutils", Version: "8.32-4.1ubuntu1", Section: "utils", Desc: "GNU core utilities", Bins: []string{"/bin/ls", "/bin/cat", "/bin/cp", "/bin/mv", "/bin/rm"}, Installed: true, Essential: true},
+	{Name: "cron", Version: "3.0pl1-137ubuntu3", Section: "admin", Desc: "process scheduling daemon", Bins: []string{"crontab", "/usr/sbin/cron"}, Installed: true},
+	{Name: "curl", Version: "7.81.0-1ubuntu1.15", Section: "web", Desc: "command line tool for transferring data with URL syntax", Bins: []string{"curl"}, Installed: true},
+	{Name: "dpkg", Version: "1.21.1ubuntu2.2", Section: "admin", Desc: "Debian package management system", Bins: []string{"dpkg", "dpkg-query"}, Installed: true, Essential: true},
+	{Name: "git", Version: "1:2.34.1-1ubuntu1.10", Section: "vcs", Desc: "fast, scalable, distributed revision control system", Bins: []string{"git"}, Installed: true},
+	{Name: "grep", Version: "3.7-1build1", Section: "utils", Desc: "GNU grep, egrep and fgrep", Bins: []string{"/bin/grep", "/bin/egrep", "/bin/fgrep"}, Installed: true, Essential: true},
+	{Name: "gzip", Version: "1.10-4ubuntu4.1", Section: "utils", Desc: "GNU compression utilities", Bins: []string{"/bin/gzip", "/bin/gunzip", "/bin/zcat"}, Installed: true, Essential: true},
+	{Name: "htop", Version: "3.0.5-7build2", Section: "utils", Desc: "interactive processes viewer", Bins: []string{"htop"}, Installed: true},
+	{Name: "inetutils-inetd", Version: "2:2.2-2ubuntu0.1", Section: "net", Desc: "internet super server", Bins: []string{"/usr/sbin/inetd"}, Installed: true},
+	{Name: "libc6", Version: "2.35-0ubuntu3.5", Section: "libs", Desc: "GNU C Library: Shared libraries", Installed: true, Essential: true},
+	{Name: "login", Version: "1:4.8.1-2ubuntu2.1", Section: "admin", Desc: "system login tools", Bins: []string{"/bin/login", "/bin/su"}, Installed: true, Essential: true},
+	{Name: "mysql-server", Version: HostPersona.MySQLVersion, Section: "database", Desc: "MySQL database server (metapackage depending on the latest version)", Installed: true},
+	{Name: "nano", Version: "6.2-1", Section: "editors", Desc: "small, friendly text editor inspired by Pico", Bins: []string{"/bin/nano"}, Installed: true},
+	{Name: "net-tools", Version: "1.60+git20181103.0eebece-1ubuntu5", Section: "net", Desc: "NET-3 networking toolkit", Bins: []string{"/bin/netstat", "/sbin/ifconfig", "/sbin/route", "/usr/sbin/arp"}, Installed: true},
+	{Name: "netcat-openbsd", Version: "1.218-4ubuntu1", Section: "net", Desc: "TCP/IP swiss army knife", Bins: []string{"/bin/nc.openbsd"}, Installed: true},
+	{Name: "openssh-client", Version: "1:8.9p1-3ubuntu0.1", Section: "net", Desc: "secure shell (SSH) client, for secure access to remote machines", Bins: []string{"ssh", "scp", "ssh-keygen"}, Installed: true},
+	{Name: "openssh-server", Version: "1:8.9p1-3ubuntu0.1", Section: "net", Desc: "secure shell (SSH) server, for secure access from remote machines", Bins: []string{"/usr/sbin/sshd"}, Installed: true},
+	{Name: "passwd", Version: "1:4.8.1-2ubuntu2.1", Section: "admin", Desc: "change and administer password and group data", Bins: []string{"passwd", "chage", "/usr/sbin/useradd", "/usr/sbin/usermod"}, Installed: true},
+	{Name: "perl", Version: "5.34.0-3ubuntu1.3", Section: "perl", Desc: "Larry Wall's Practical Extraction and Report Language", Bins: []string{"perl"}, Installed: true},
+	{Name: "postfix", Version: "3.6.4-1ubuntu1.2", Section: "mail", Desc: "High-performance mail transport agent", Bins: []string{"/usr/sbin/postfix", "/usr/sbin/sendmail"}, Installed: true},
+	{Name: "python3", Version: "3.10.6-1~22.04", Section: "python", Desc: "interactive high-level object-oriented language (default python3 version)", Bins: []string{"python3"}, Installed: true},
+	{Name: "redis-server", Version: "5:" + HostPersona.RedisVersion + "-1ubuntu1", Section: "database", Desc: "Persistent key-value database with network interface", Bins: []string{"redis-server"}, Installed: true},
+	{Name: "screen", Version: "4.9.0-1", Section: "misc", Desc: "terminal multiplexer with VT100/ANSI terminal emulation", Bins: []string{"screen"}, Installed: true},
+	{Name: "sed", Version: "4.8-1ubuntu2", Section: "utils", Desc: "GNU stream editor for filtering/transforming text", Bins: []string{"/bin/sed"}, Installed: true, Essential: true},
+	{Name: "sudo", Version: "1.9.9-1ubuntu2.4", Section: "admin", Desc: "Provide limited super user privileges to specific users", Bins: []string{"sudo"}, Installed: true},
+	{Name: "tar", Version: "1.34+dfsg-1ubuntu0.1.22.04.1", Section: "utils", Desc: "GNU version of the tar archiving utility", Bins: []string{"/bin/tar"}, Installed: true, Essential: true},
+	{Name: "tcpdump", Version: "4.99.1-3ubuntu0.1", Section: "net", Desc: "command-line network traffic analyzer", Depends: []string{"libpcap0.8"}, Bins: []string{"tcpdump"}, Installed: true},
+	{Name: "tmux", Version: "3.2a-4ubuntu0.2", Section: "admin", Desc: "terminal multiplexer", Bins: []string{"tmux"}, Installed: true},
+	{Name: "unzip", Version: "6.0-26ubuntu3.1", Section: "utils", Desc: "De-archiver for .zip files", Bins: []string{"unzip"}, Installed: true},
+	{Name: "vim", Version: "2:8.2.3995-1ubuntu2.13", Section: "editors", Desc: "Vi IMproved - enhanced vi editor", Bins: []string{"vim.basic"}, Installed: true},
+	{Name: "vsftpd", Version: "3.0.5-0ubuntu1", Section: "net", Desc: "lightweight, efficient FTP server written for security", Bins: []string{"/usr/sbin/vsftpd"}, Installed: true},
+	{Name: "wget", Version: "1.21.2-2ubuntu1", Section: "web", Desc: "retrieves files from the web", Bins: []string{"wget"}, Installed: true},
+	{Name: "libpcap0.8", Version: "1.10.1-4build1", Section: "libs", Desc: "system interface for user-level packet capture", Installed: true},
+
+	// 编译工具链
+	{Name: "build-essential", Version: "12.9ubuntu3", Section: "devel", Desc: "Informational list of build-essential packages", Depends: []string{"gcc", "g++", "make", "dpkg-dev", "libc6-dev"}},
+	{Name: "gcc", Version: "4:11.2.0-1ubuntu1", Section: "devel", Desc: "GNU C compiler", Depends: []string{"gcc-11", "cpp", "binutils", "libc6-dev"}, Bins: []string{"gcc", "cc", "c99"}},
+	{Name: "gcc-11", Version: "11.4.0-1ubuntu1~22.04", Section: "devel", Desc: "GNU C compiler", Depends: []string{"cpp-11", "libgcc-11-dev"}, Bins: []string{"gcc-11"}},
+	{Name: "cpp", Version: "4:11.2.0-1ubuntu1", Section: "interpreters", Desc: "GNU C preprocessor (cpp)", Depends: []string{"cpp-11"}, Bins: []string{"cpp"}},
+	{Name: "cpp-11", Version: "11.4.0-1ubuntu1~22.04", Section: "interpreters", Desc: "GNU C preprocessor", Bins: []string{"cpp-11"}},
+	{Name: "libgcc-11-dev", Version: "11.4.0-1ubuntu1~22.04", Section: "libdevel", Desc: "GCC support library (development files)"},
+	{Name: "binutils", Version: "2.38-4ubuntu2.4", Section: "devel", Desc: "GNU assembler, linker and binary utilities", Depends: []string{"binutils-x86-64-linux-gnu"}, Bins: []string{"as", "ld", "nm", "objdump", "objcopy", "readelf", "strip"}},
+	{Name: "binutils-x86-64-linux-gnu", Version: "2.38-4ubuntu2.4", Section: "devel", Desc: "GNU binary utilities, for x86-64-linux-gnu target"},
+	{Name: "libc6-dev", Version: "2.35-0ubuntu3.5", Section: "libdevel", Desc: "GNU C Library: Development Libraries and Header Files", Depends: []string{"libc-dev-bin", "linux-libc-dev"}},
+	{Name: "libc-dev-bin", Version: "2.35-0ubuntu3.5", Section: "libdevel", Desc: "GNU C Library: Development binaries"},
+	{Name: "linux-libc-dev", Version: "5.15.0-91.101", Section: "devel", Desc: "Linux Kernel Headers for development"},
+	{Name: "g++", Version: "4:11.2.0-1ubuntu1", Section: "devel", Desc: "GNU C++ compiler", Depends: []string{"g++-11", "gcc"}, Bins: []string{"g++", "c++"}},
+	{Name: "g++-11", Version: "11.4.0-1ubuntu1~22.04", Section: "devel", Desc: "GNU C++ compiler", Depends: []string{"gcc-11", "libstdc++-11-dev"}, Bins: []string{"g++-11"}},
+	{Name: "libstdc++-11-dev", Version: "11.4.0-1ubuntu1~22.04", Section: "libdevel", Desc: "GNU Standard C++ Library v3 (development files)"},
+	{Name: "make", Version: "4.3-4.1build1", Section: "devel", Desc: "utility for directing compilation", Bins: []string{"make"}},
+	{Name: "dpkg-dev", Version: "1.21.1ubuntu2.2", Section: "utils", Desc: "Debian package development tools", Depends: []string{"patch"}, Bins: []string{"dpkg-buildpackage", "dpkg-source"}},
+	{Name: "patch", Version: "2.7.6-7build2", Section: "utils", Desc: "Apply a diff file to an original", Bins: []string{"patch"}},
+	{Name: "cmake", Version: "3.22.1-1ubuntu1.22.04.1", Section: "devel", Desc: "cross-platform, open-source make system", Depends: []string{"cmake-data"}, Bins: []string{"cmake", "ctest", "cpack"}},
+	{Name: "cmake-data", Version: "3.22.1-1ubuntu1.22.04.1", Section: "devel", Desc: "CMake data files (modules, templates and documentation)"},
+	{Name: "autoconf", Version: "2.71-2", Section: "devel", Desc: "automatic configure script builder", Bins: []string{"autoconf", "autoreconf"}},
+	{Name: "automake", Version: "1:1.16.5-1.3", Section: "devel", Desc: "Tool for generating GNU Standards-compliant Makefiles", Depends: []string{"autoconf"}, Bins: []string{"automake", "aclocal"}},
+	{Name: "libtool", Version: "2.4.6-15build2", Section: "devel", Desc: "Generic library support script", Bins: []string{"libtoolize"}},
+	{Name: "pkg-config", Version: "0.29.2-1ubuntu3", Section: "devel", Desc: "manage compile and link flags for libraries", Bins: []string{"pkg-config"}},
+	{Name: "libssl-dev", Version: "3.0.2-0ubuntu1.12", Section: "libdevel", Desc: "Secure Sockets Layer toolkit - development files"},
+	{Name: "libuv1", Version: "1.43.0-1", Section: "libs", Desc: "asynchronous event notification library - runtime library"},
+	{Name: "libuv1-dev", Version: "1.43.0-1", Section: "libdevel", Desc: "asynchronous event notification library - development files", Depends: []string{"libuv1"}},
+	{Name: "libhwloc15", Version: "2.7.0-2", Section: "libs", Desc: "Hierarchical view of the machine - shared libs"},
+	{Name: "libhwloc-dev", Version: "2.7.0-2", Section: "libdevel", Desc: "Hierarchical view of the machine - static libs and headers", Depends: []string{"libhwloc15"}},
+	{Name: "libpcap0.8-dev", Version: "1.10.1-4build1", Section: "libdevel", Desc: "development library for libpcap (transitional package)", Depends: []string{"libpcap0.8"}},
+	{Name: "libpcap-dev", Version: "1.10.1-4build1", Section: "libdevel", Desc: "development library for libpcap (transitional package)", Depends: []string{"libpcap0.8-dev"}},
+	{Name: "golang-go", Version: "2:1.18~0ubuntu2", Section: "devel", Desc: "Go programming language compiler, linker, compiled stdlib", Depends: []string{"golang-1.18-go"}, Bins: []string{"go", "gofmt"}},
+	{Name: "golang-1.18-go", Version: "1.18.1-1ubuntu1.2", Section: "devel", Desc: "Go programming language compiler, linker, compiled stdlib"},
+	{Name: "strace", Version: "5.16-0ubuntu3", Section: "utils", Desc: "System call tracer", Bins: []string{"strace"}},
+
+	// 网络与安全工具
+	{Name: "masscan", Version: "2:1.3.2+ds1-1", Section: "net", Desc: "TCP port scanner", Universe: true, Depends: []string{"libpcap0.8"}, Bins: []string{"masscan"}},
+	{Name: "nmap", Version: "7.91+dfsg1+really7.80+dfsg1-2ubuntu0.1", Section: "net", Desc: "The Network Mapper", Universe: true, Depends: []string{"nmap-common", "liblinear4", "liblua5.3-0"}, Bins: []string{"nmap"}},
+	{Name: "nmap-common", Version: "7.91+dfsg1+really7.80+dfsg1-2ubuntu0.1", Section: "net", Desc: "Architecture independent files for nmap", Universe: true},
+	{Name: "liblinear4", Version: "2.3.0+dfsg-5", Section: "libs", Desc: "Library for Large Linear Classification", Universe: true},
+	{Name: "liblua5.3-0", Version: "5.3.6-1build1", Section: "libs", Desc: "Shared library for the Lua interpreter version 5.3"},
+	{Name: "ncat", Version: "7.91+dfsg1+really7.80+dfsg1-2ubuntu0.1", Section: "net", Desc: "NMAP netcat reimplementation", Universe: true, Bins: []string{"ncat"}},
+	{Name: "zmap", Version: "2.1.1-2build1", Section: "net", Desc: "network scanner for researchers", Universe: true, Depends: []string{"libpcap0.8"}, Bins: []string{"zmap"}},
+	{Name: "hydra", Version: "9.2-1ubuntu1", Section: "net", Desc: "very fast network logon cracker", Universe: true, Bins: []string{"hydra", "pw-inspector"}},
+	{Name: "john", Version: "1.8.0-4", Section: "admin", Desc: "active password cracking tool", Universe: true, Bins: []string{"john"}},
+	{Name: "socat", Version: "1.7.4.1-3ubuntu4", Section: "net", Desc: "multipurpose relay for bidirectional data transfer", Bins: []string{"socat"}},
+	{Name: "sshpass", Version: "1.09-1", Section: "admin", Desc: "Non-interactive ssh password authentication", Universe: true, Bins: []string{"sshpass"}},
+	{Name: "tor", Version: "0.4.6.10-1", Section: "net", Desc: "anonymizing overlay network for TCP", Universe: true, Depends: []string{"tor-geoipdb", "torsocks"}, Bins: []string{"tor"}},
+	{Name: "tor-geoipdb", Version: "0.4.6.10-1", Section: "net", Desc: "GeoIP database for Tor", Universe: true},
+	{Name: "torsocks", Version: "2.3.0-3", Section: "net", Desc: "use SOCKS-friendly applications with Tor", Universe: true, Bins: []string{"torsocks"}},
+	{Name: "proxychains4", Version: "4.16-1", Section: "net", Desc: "redirect connections through socks/http proxies (proxychains-ng)", Universe: true, Bins: []string{"proxychains4"}},
+	{Name: "whois", Version: "5.5.13", Section: "net", Desc: "intelligent WHOIS client", Bins: []string{"whois"}},
+	{Name: "jq", Version: "1.6-2.1ubuntu3", Section: "utils", Desc: "lightweight and flexible command-line JSON processor", Depends: []string{"libjq1"}, Bins: []string{"jq"}},
+	{Name: "libjq1", Version: "1.6-2.1ubuntu3", Section: "libs", Desc: "lightweight and flexible command-line JSON processor - shared library"},
+	{Name: "zip", Version: "3.0-12build2", Section: "utils", Desc: "Archiver for .zip files", Bins: []string{"zip"}},
+
+	// 运行环境
+	{Name: "python3-pip", Version: "22.0.2+dfsg-1ubuntu0.4", Section: "python", Desc: "Python package installer", Universe: true, Depends: []string{"python3-setuptools", "python3-wheel"}, Bins: []string{"pip", "pip3"}},
+	{Name: "python3-setuptools", Version: "59.6.0-1.2ubuntu0.22.04.1", Section: "python", Desc: "Python3 Distutils Enhancements"},
+	{Name: "python3-wheel", Version: "0.37.1-2ubuntu0.22.04.1", Section: "python", Desc: "built-package format for Python", Universe: true},
+	{Name: "nodejs", Version: "12.22.9~dfsg-1ubuntu3.3", Section: "javascript", Desc: "evented I/O for V8 javascript - runtime executable", Universe: true, Depends: []string{"libnode72"}, Bins: []string{"node", "nodejs"}},
+	{Name: "libnode72", Version: "12.22.9~dfsg-1ubuntu3.3", Section: "libs", Desc: "evented I/O for V8 javascript - runtime library", Universe: true},
+	{Name: "php-cli", Version: "2:8.1+92ubuntu1", Section: "php", Desc: "command-line interpreter for the PHP scripting language (default)", Depends: []string{"php8.1-cli"}, Bins: []string{"php"}},
+	{Name: "php8.1-cli", Version: "8.1.2-1ubuntu2.14", Section: "php", Desc: "command-line interpreter for the PHP scripting language", Bins: []string{"php8.1"}},
+	{Name: "docker.io", Version: "20.10.25-0ubuntu1~22.04.2", Section: "admin", Desc: "Linux container runtime", Universe: true, Depends: []string{"containerd", "runc"}, Bins: []string{"docker", "dockerd"}},
+	{Name: "containerd", Version: "1.7.2-0ubuntu1~22.04.1", Section: "admin", Desc: "daemon to control runC", Bins: []string{"containerd", "ctr"}},
+	{Name: "runc", Version: "1.1.7-0ubuntu1~22.04.1", Section: "admin", Desc: "Open Container Project - runtime", Bins: []string{"runc"}},
+
+	// 其他发行版的包管理器，Ubuntu 上也能装到
+	{Name: "dnf", Version: "4.9.0-1", Section: "admin", Desc: "Dandified Yum package manager", Universe: true, Depends: []string{"python3-dnf"}, Bins: []string{"dnf"}},
+	{Name: "python3-dnf", Version: "4.9.0-1", Section: "python", Desc: "Python 3 interface to DNF", Universe: true},
+	{Name: "rpm", Version: "4.17.0+dfsg1-4build1", Section: "admin", Desc: "package manager for RPM", Universe: true, Bins: []string{"rpm", "rpm2cpio"}},
+}
+
+func findDeb(name string) (debPackage, bool) {
+	for _, p := range debCatalog {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return debPackage{}, false
+}
+
+// debSizes 返回确定的下载大小和安装后占用 (字节)
+func (p debPackage) debSizes() (download, installed int) {
+	h := fnv.New32a()
+	h.Write([]byte(p.Name))
+	s := int(h.Sum32())
+	download = (4+s%1800)*1000 + s%997
+	return download, download * (2 + s%3)
+}
+
+func (p debPackage) arch() string {
+	if p.Name == "adduser" || strings.HasPrefix(p.Name, "python3-") ||
+		strings.HasSuffix(p.Name, "-common") || strings.HasSuffix(p.Name, "-data") || strings.HasSuffix(p.Name, "-geoipdb") {
+		return "all"
+	}
+	return "amd64"
+}
+
+// pocket 带 ubuntu0 或 22.04 字样的版本来自 jammy-updates
+func (p debPackage) pocket() string {
+	if strings.Contains(p.Version, "ubuntu0.") || strings.Contains(p.Version, "22.04") {
+		return "jammy-updates"
+	}
+	return "jammy"
+}
+
+func (p debPackage) component() string {
+	if p.Universe {
+		return "universe"
+	}
+	return "main"
+}
+
+// binPaths 返回程序的绝对路径
+func (p debPackage) binPaths() []string {
+	var res []string
+	for _, b := range p.Bins {
+		if !strings.HasPrefix(b, "/") {
+			b = "/usr/bin/" + b
+		}
+		res = append(res, b)
+	}
+	return res
+}
+
+// debStanza 生成 /var/lib/dpkg/status 中的一段记录
+func (p debPackage) debStanza() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package: %s\nStatus: install ok installed\n", p.Name)
+	if p.Essential {
+		b.WriteString("Essential: yes\nPriority: required\n")
+	} else {
+		b.WriteString("Priority: optional\n")
+	}
+	_, inst := p.debSizes()
+	fmt.Fprintf(&b, "Section: %s\nInstalled-Size: %d\n", p.Section, inst/1024)
+	fmt.Fprintf(&b, "Maintainer: Ubuntu Developers <ubuntu-devel-discuss@lists.ubuntu.com>\nArchitecture: %s\nVersion: %s\n", p.arch(), p.Version)
+	if len(p.Depends) > 0 {
+		fmt.Fprintf(&b, "Depends: %s\n", strings.Join(p.Depends, ", "))
+	}
+	fmt.Fprintf(&b, "Description: %s\n", p.Desc)
+	return b.String()
+}
+
+// debFileList 生成 /var/lib/dpkg/info/<包名>.list，预装软件不带文档目录
+func (p debPackage) debFileList() string {
+	files := p.binPaths()
+	if !p.Installed {
+		files = append(files, "/usr/share/doc/"+p.Name+"/copyright")
+	}
+	seen := map[string]bool{"/.": true}
+	var lines []string
+	for _, f := range files {
+		var dirs []string
+		for d := path.Dir(f); d != "/"; d = path.Dir(d) {
+			dirs = append([]string{d}, dirs...)
+		}
+		for _, d := range append(dirs, f) {
+			if !seen[d] {
+				seen[d] = true
+				lines = append(lines, d)
+			}
+		}
+	}
+	return "/.\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// debStatusFile 生成预装软件的 dpkg 状态文件
+func debStatusFile() string {
+	var stanzas []string
+	for _, p := range debCatalog {
+		if p.Installed {
+			stanzas = append(stanzas, p.debStanza())
+		}
+	}
+	return strings.Join(stanzas, "\n")
+}
+
+const dpkgStatus = "/var/lib/dpkg/status"
+
+// debStatus 读取 dpkg 状态文件，返回包名到记录的映射
+func (t *Terminal) debStatus() map[string]string {
+	res := map[string]string{}
+	e, ok := t.FS.GetEntry(dpkgStatus)
+	if !ok {
+		return res
+	}
+	e.mu.RLock()
+	content := string(e.Content)
+	e.mu.RUnlock()
+	for _, st := range strings.Split(content, "\n\n") {
+		if name, ok := debField(st, "Package"); ok {
+			res[name] = strings.TrimRight(st, "\n") + "\n"
+		}
+	}
+	return res
+}
+
+// debField 取记录中的字段值
+func debField(stanza, field string) (string, bool) {
+	for _, line := range strings.Split(stanza, "\n") {
+		if v, ok := strings.CutPrefix(line, field+": "); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (t *Terminal) debInstalled(name string) (string, bool) {
+	st, ok := t.debStatus()[name]
+	if !ok {
+		return "", false
+	}
+	return debField(st, "Version")
+}
+
+// writeDebStatus 按包名排序写回状态文件
+func (t *Terminal) writeDebStatus(status map[string]string) {
+	names := make([]string, 0, len(status))
+	for n := range status {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var stanzas []string
+	for _, n := range names {
+		stanzas = append(stanzas, status[n])
+	}
+	t.FS.Write(dpkgStatus, []byte(strings.Join(stanzas, "\n")), 0644)
+}
+
+// mkdirAll 逐级创建软件包需要的目录
+func (t *Terminal) mkdirAll(p string) {
+	if _, ok := t.FS.GetEntry(p); ok || p == "/" {
+		return
+	}
+	t.mkdirAll(path.Dir(p))
+	t.FS.Mkdir(p)
+}
+
+// debUnpack 登记安装并放置程序占位文件，已存在的同名文件保持不变
+func (t *Terminal) debUnpack(p debPackage) {
+	status := t.debStatus()
+	status[p.Name] = p.debStanza()
+	t.writeDebStatus(status)
+	t.FS.Write("/var/lib/dpkg/info/"+p.Name+".list", []byte(p.debFileList()), 0644)
+	if p.Installed {
+		return
+	}
+	for _, b := range p.binPaths() {
+		if _, ok := t.FS.GetEntry(b); !ok {
+			t.mkdirAll(path.Dir(b))
+			t.FS.Write(b, []byte(elfStub), 0755)
+		}
+	}
+	t.mkdirAll("/usr/share/doc/" + p.Name)
+	t.FS.Write("/usr/share/doc/"+p.Name+"/copyright",
+		[]byte("Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/\nUpstream-Name: "+p.Name+"\n"), 0644)
+}
+
+// debRemove 注销安装并删除安装时放置的文件，预装软件的程序保留
+func (t *Terminal) debRemove(p debPackage) {
+	status := t.debStatus()
+	delete(status, p.Name)
+	t.writeDebStatus(status)
+	t.FS.Remove("/var/lib/dpkg/info/" + p.Name + ".list")
+	if p.Installed {
+		return
+	}
+	for _, b := range p.binPaths() {
+		if e, ok := t.FS.GetEntry(b); ok && string(e.Content) == elfStub {
+			t.FS.Remove(b)
+		}
+	}
+	t.FS.Remove("/usr/share/doc/" + p.Name + "/copyright")
+	t.FS.Remove("/usr/share/doc/" + p.Name)
+}
+
+// debOwner 返回提供该程序的已安装软件包
+func (t *Terminal) debOwner(bin string) (debPackage, bool) {
+	status := t.debStatus()
+	for _, p := range debCatalog {
+		if _, ok := status[p.Name]; !ok {
+			continue
+		}
+		for _, b := range p.binPaths() {
+			if b == bin {
+				return p, true
+			}
+		}
+	}
+	return debPackage{}, false
+}
+
+// aptSize 与 apt 的 SizeToStr 一致：1000 进制，四位数以内带千位分隔符
+func aptSize(n float64) string {
+	units := []string{"", "k", "M", "G", "T"}
+	for i := range units {
+		if n < 100 && i > 0 {
+			return fmt.Sprintf("%.1f %sB", n, units[i])
+		}
+		if n < 10000 {
+			s := strconv.Itoa(int(n + 0.5))
+			if len(s) == 4 {
+				s = s[:1] + "," + s[1:]
+			}
+			return s + " " + units[i] + "B"
+		}
+		n /= 1000
+	}
+	return fmt.Sprintf("%.0f PB", n)
+}
+
+// wrapList 按 apt 的格式缩进两格列出包名，超过终端宽度时换行
+func wrapList(w io.Writer, names []string) {
+	line := " "
+	for _, n := range names {
+		if len(line)+1+len(n) > 79 {
+			fmt.Fprintln(w, line)
+			line = " "
+		}
+		line += " " + n
+	}
+	fmt.Fprintln(w, line)
+}
+
+// pkgIntel 记录攻击者要求安装或删除的软件包
+func (t *Terminal) pkgIntel(tool, op string, names []string) {
+	log.Printf("[Package] %s: %s %s %s", t.Remote, tool, op, strings.Join(names, " "))
+}
+
+// pkgPause 在终端上模拟下载和解包耗时
+func pkgPause(out io.Writer, d time.Duration) {
+	if isTTY(out) {
+		time.Sleep(d)
+	}
+}
+
+const aptLockError = "E: Could not open lock file /var/lib/dpkg/lock-frontend - open (13: Permission denied)\n" +
+	"E: Unable to acquire the dpkg frontend lock (/var/lib/dpkg/lock-frontend), are you root?\n"
+
+const aptUsage = `apt 2.4.11 (amd64)
+Usage: apt [options] command
+
+apt is a commandline package manager and provides commands for
+searching and managing as well as querying information about packages.
+It provides the same functionality as the specialized APT tools,
+like apt-get and apt-cache, but enables options more suitable for
+interactive use by default.
+
+Most used commands:
+  list - list packages based on package names
+  search - search in package descriptions
+  show - show package details
+  install - install packages
+  reinstall - reinstall packages
+  remove - remove packages
+  autoremove - automatically remove all unused packages
+  update - update list of available packages
+  upgrade - upgrade the system by installing/upgrading packages
+  full-upgrade - upgrade the system by removing/installing/upgrading packages
+  edit-sources - edit the source information file
+  satisfy - satisfy dependency strings
+
+See apt(8) for more information about the available commands.
+Configuration options and syntax is detailed in apt.conf(5).
+Information about how to configure sources can be found in sources.list(5).
+Package and version choices can be expressed via apt_preferences(5).
+Security details are available in apt-secure(8).
+                                        This APT has Super Cow Powers.
+`
+
+// cmdApt 实现 apt、apt-get 和 apt-cache
+func (t *Terminal) cmdApt(args []string, in io.Reader, out io.Writer) {
+	tool := args[0]
+	yes, quiet := false, 0
+	var op string
+	var names []string
+	for _, a := range args[1:] {
+		switch {
+		case a == "-y" || a == "--yes" || a == "--assume-yes":
+			yes = true
+		case a == "-q" || a == "--quiet":
+			quiet = max(quiet, 1)
+		case a == "-qq" || a == "-q=2":
+			quiet = 2
+		case strings.HasPrefix(a, "-"):
+			// --no-install-recommends、-f、--fix-missing、--installed 等
+			if strings.Contains(a, "y") && !strings.HasPrefix(a, "--") {
+				yes = true
+			}
+			if a == "--installed" || a == "--upgradable" {
+				names = append(names, a)
+			}
+		case op == "":
+			op = a
+		default:
+			names = append(names, a)
+		}
+	}
+	if quiet == 2 {
+		out = io.Discard
+	}
+	if tool == "apt" && !isTTY(out) && op != "" {
+		fmt.Fprint(t.Stderr, "\nWARNING: apt does not have a stable CLI interface. Use with caution in scripts.\n\n")
+	}
+	root := t.userName() == "root"
+
+	switch op {
+	case "":
+		if tool == "apt" {
+			io.WriteString(out, aptUsage)
+		} else {
+			fmt.Fprintf(out, "apt 2.4.11 (amd64)\nUsage: %s [options] command\n", tool)
+		}
+		t.lastExitCode = 1
+
+	case "update":
+		if tool == "apt-cache" {
+			break
+		}
+		if !root {
+			fmt.Fprintln(out, "Reading package lists... Done")
+			fmt.Fprint(t.Stderr, "E: Could not open lock file /var/lib/apt/lists/lock - open (13: Permission denied)\nE: Unable to lock directory /var/lib/apt/lists/\n")
+			t.lastExitCode = 100
+			return
+		}
+		fmt.Fprintln(out, "Hit:1 http://archive.ubuntu.com/ubuntu jammy InRelease")
+		pkgPause(out, 300*time.Millisecond)
+		fmt.Fprintln(out, "Get:2 http://archive.ubuntu.com/ubuntu jammy-updates InRelease [119 kB]")
+		pkgPause(out, 200*time.Millisecond)
+		fmt.Fprintln(out, "Get:3 http://archive.ubuntu.com/ubuntu jammy-backports InRelease [109 kB]")
+		fmt.Fprintln(out, "Get:4 http://security.ubuntu.com/ubuntu jammy-security InRelease [110 kB]")
+		pkgPause(out, 300*time.Millisecond)
+		fmt.Fprintln(out, "Fetched 338 kB in 1s (452 kB/s)")
+		fmt.Fprintln(out, "Reading package lists... Done")
+		if tool == "apt" {
+			fmt.Fprint(out, "Building dependency tree... Done\nReading state information... Done\nAll packages are up to date.\n")
+		}
+
+	case "upgrade", "dist-upgrade", "full-upgrade", "autoremove", "autoclean", "clean":
+		if !root {
+			fmt.Fprint(t.Stderr, aptLockError)
+			t.lastExitCode = 100
+			return
+		}
+		if op == "clean" || op == "autoclean" {
+			break
+		}
+		fmt.Fprint(out, "Reading package lists... Done\nBuilding dependency tree... Done\nReading state information... Done\n")
+		if strings.HasSuffix(op, "upgrade") {
+			fmt.Fprintln(out, "Calculating upgrade... Done")
+		}
+		fmt.Fprintln(out, "0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.")
+
+	case "install", "reinstall":
+		t.pkgIntel(tool, op, names)
+		if !root {
+			fmt.Fprint(t.Stderr, aptLockError)
+			t.lastExitCode = 100
+			return
+		}
+		t.aptInstall(names, yes, in, out)
+
+	case "remove", "purge":
+		t.pkgIntel(tool, op, names)
+		if !root {
+			fmt.Fprint(t.Stderr, aptLockError)
+			t.lastExitCode = 100
+			return
+		}
+		t.aptRemove(names, op == "purge", yes, in, out)
+
+	case "list":
+		fmt.Fprintln(out, "Listing... Done")
+		status := t.debStatus()
+		for _, p := range debCatalog {
+			_, inst := status[p.Name]
+			if !t.aptMatch(p.Name, names, inst) {
+				continue
+			}
+			mark := ""
+			if inst {
+				mark = " [installed]"
+			}
+			fmt.Fprintf(out, "%s/%s,now %s %s%s\n", p.Name, p.pocket(), p.Version, p.arch(), mark)
+		}
+
+	case "search":
+		if len(names) == 0 {
+			fmt.Fprintln(t.Stderr, "E: You must give at least one search pattern")
+			t.lastExitCode = 100
+			return
+		}
+		if tool == "apt" {
+			fmt.Fprint(out, "Sorting... Done\nFull Text Search... Done\n")
+		}
+		status := t.debStatus()
+		for _, p := range debCatalog {
+			text := strings.ToLower(p.Name + " " + p.Desc)
+			match := true
+			for _, n := range names {
+				match = match && strings.Contains(text, strings.ToLower(n))
+			}
+			if !match {
+				continue
+			}
+			if tool == "apt-cache" {
+				fmt.Fprintf(out, "%s - %s\n", p.Name, p.Desc)
+				continue
+			}
+			mark := ""
+			if _, ok := status[p.Name]; ok {
+				mark = " [installed]"
+			}
+			fmt.Fprintf(out, "%s/%s %s %s%s\n  %s\n\n", p.Name, p.pocket(), p.Version, p.arch(), mark, p.Desc)
+		}
+
+	case "show", "policy":
+		for _, n := range names {
+			p, ok := findDeb(n)
+			if !ok {
+				fmt.Fprintf(t.Stderr, "E: No packages found\n")
+				t.lastExitCode = 100
+				continue
+			}
+			inst, _ := t.debInstalled(n)
+			if op == "policy" {
+				if inst == "" {
+					inst = "(none)"
+				}
+				fmt.Fprintf(out, "%s:\n  Installed: %s\n  Candidate: %s\n  Version table:\n     %s 500\n        500 http://archive.ubuntu.com/ubuntu %s/%s amd64 Packages\n",
+					n, inst, p.Version, p.Version, p.pocket(), p.component())
+				continue
+			}
+			dl, size := p.debSizes()
+			fmt.Fprintf(out, "Package: %s\nVersion: %s\nPriority: optional\nSection: %s\nOrigin: Ubuntu\n", p.Name, p.Version, p.Section)
+			fmt.Fprintf(out, "Maintainer: Ubuntu Developers <ubuntu-devel-discuss@lists.ubuntu.com>\nInstalled-Size: %s\n", aptSize(float64(size)))
+			if len(p.Depends) > 0 {
+				fmt.Fprintf(out, "Depends: %s\n", strings.Join(p.Depends, ", "))
+			}
+			fmt.Fprintf(out, "Download-Size: %s\nAPT-Sources: http://archive.ubuntu.com/ubuntu %s/%s amd64 Packages\nDescription: %s\n\n",
+				aptSize(float64(dl)), p.pocket(), p.component(), p.Desc)
+		}
+
+	default:
+		fmt.Fprintf(t.Stderr, "E: Invalid operation %s\n", op)
+		t.lastExitCode = 100
+	}
+}
+
+// aptMatch apt list 的筛选：--installed 只列已安装的，其余参数为 shell 通配符
+func (t *Terminal) aptMatch(name string, patterns []string, installed bool) bool {
+	any := false
+	for _, p := range patterns {
+		switch p {
+		case "--installed":
+			if !installed {
+				return false
+			}
+		case "--upgradable":
+			return false
+		default:
+			any = true
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+	return !any
+}
+
+// aptPrelude 输出读取软件包列表的三行
+func aptPrelude(out io.Writer) {
+	fmt.Fprint(out, "Reading package lists... Done\nBuilding dependency tree... Done\nReading state information... Done\n")
+}
+
+// aptConfirm 询问是否继续；回答不是 Y 时输出 Abort.
+func (t *Terminal) aptConfirm(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, "Do you want to continue? [Y/n] ")
+	reply, ok := t.readReply(in, out, true)
+	if ok && (reply == "" || strings.EqualFold(reply, "y") || strings.EqualFold(reply, "yes")) {
+		return true
+	}
+	fmt.Fprintln(out, "Abort.")
+	t.lastExitCode = 1
+	return false
+}
+
+func (t *Terminal) aptInstall(names []string, yes bool, in io.Reader, out io.Writer) {
+	aptPrelude(out)
+	status := t.debStatus()
+	var want []debPackage
+	for _, n := range names {
+		n, _, _ = strings.Cut(n, "=")
+		p, ok := findDeb(n)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "E: Unable to locate package %s\n", n)
+			t.lastExitCode = 100
+			return
+		}
+		want = append(want, p)
+	}
+
+	// 依赖先于被依赖者安装
+	var order []debPackage
+	seen := map[string]bool{}
+	requested := map[string]bool{}
+	var visit func(p debPackage)
+	visit = func(p debPackage) {
+		if seen[p.Name] {
+			return
+		}
+		seen[p.Name] = true
+		for _, d := range p.Depends {
+			if dp, ok := findDeb(d); ok {
+				if _, inst := status[d]; !inst {
+					visit(dp)
+				}
+			}
+		}
+		order = append(order, p)
+	}
+	for _, p := range want {
+		requested[p.Name] = true
+		if _, inst := status[p.Name]; inst {
+			fmt.Fprintf(out, "%s is already the newest version (%s).\n", p.Name, p.Version)
+			seen[p.Name] = true
+			continue
+		}
+		visit(p)
+	}
+	if len(order) == 0 {
+		fmt.Fprintln(out, "0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.")
+		return
+	}
+
+	var extra, all []string
+	download, installed := 0, 0
+	for _, p := range order {
+		all = append(all, p.Name)
+		if !requested[p.Name] {
+			extra = append(extra, p.Name)
+		}
+		dl, inst := p.debSizes()
+		download += dl
+		installed += inst
+	}
+	sort.Strings(extra)
+	sort.Strings(all)
+	if len(extra) > 0 {
+		fmt.Fprintln(out, "The following additional packages will be installed:")
+		wrapList(out, extra)
+	}
+	fmt.Fprintln(out, "The following NEW packages will be installed:")
+	wrapList(out, all)
+	fmt.Fprintf(out, "0 upgraded, %d newly installed, 0 to remove and 0 not upgraded.\n", len(order))
+	fmt.Fprintf(out, "Need to get %s of archives.\n", aptSize(float64(download)))
+	fmt.Fprintf(out, "After this operation, %s of additional disk space will be used.\n", aptSize(float64(installed)))
+	// 只有附带安装了其他软件包时才需要确认
+	if len(extra) > 0 && !yes && !t.aptConfirm(in, out) {
+		return
+	}
+
+	speed, elapsed := transferPace(download)
+	for i, p := range order {
+		dl, _ := p.debSizes()
+		fmt.Fprintf(out, "Get:%d http://archive.ubuntu.com/ubuntu %s/%s amd64 %s %s %s [%s]\n",
+			i+1, p.pocket(), p.component(), p.Name, p.arch(), p.Version, aptSize(float64(dl)))
+		pkgPause(out, elapsed/time.Duration(len(order)))
+	}
+	fmt.Fprintf(out, "Fetched %s in %ds (%s/s)\n", aptSize(float64(download)), max(1, int(elapsed.Seconds()+0.5)), aptSize(speed))
+
+	files := 70000 + 37*len(status)
+	for _, p := range order {
+		fmt.Fprintf(out, "Selecting previously unselected package %s.\n", p.Name)
+		fmt.Fprintf(out, "(Reading database ... %d files and directories currently installed.)\n", files)
+		fmt.Fprintf(out, "Preparing to unpack .../%s_%s_%s.deb ...\n", p.Name, strings.ReplaceAll(p.Version, ":", "%3a"), p.arch())
+		fmt.Fprintf(out, "Unpacking %s (%s) ...\n", p.Name, p.Version)
+		t.debUnpack(p)
+		files += 37
+		pkgPause(out, 50*time.Millisecond)
+	}
+	libs := false
+	for _, p := range order {
+		fmt.Fprintf(out, "Setting up %s (%s) ...\n", p.Name, p.Version)
+		libs = libs || strings.HasPrefix(p.Name, "lib")
+		pkgPause(out, 50*time.Millisecond)
+	}
+	fmt.Fprintln(out, "Processing triggers for man-db (2.10.2-1) ...")
+	if libs {
+		fmt.Fprintln(out, "Processing triggers for libc-bin (2.35-0ubuntu3.5) ...")
+	}
+}
+
+func (t *Terminal) aptRemove(names []string, purge, yes bool, in io.Reader, out io.Writer) {
+	aptPrelude(out)
+	status := t.debStatus()
+	var remove []debPackage
+	freed := 0
+	for _, n := range names {
+		p, ok := findDeb(n)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "E: Unable to locate package %s\n", n)
+			t.lastExitCode = 100
+			return
+		}
+		if _, inst := status[n]; !inst {
+			fmt.Fprintf(out, "Package '%s' is not installed, so not removed\n", n)
+			continue
+		}
+		if p.Essential {
+			fmt.Fprintln(t.Stderr, "E: Removing essential system-critical packages is not permitted. This might break the system.")
+			t.lastExitCode = 100
+			return
+		}
+		remove = append(remove, p)
+		_, inst := p.debSizes()
+		freed += inst
+	}
+	if len(remove) == 0 {
+		fmt.Fprintln(out, "0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.")
+		return
+	}
+	var list []string
+	for _, p := range remove {
+		if purge {
+			list = append(list, p.Name+"*")
+		} else {
+			list = append(list, p.Name)
+		}
+	}
+	fmt.Fprintln(out, "The following packages will be REMOVED:")
+	wrapList(out, list)
+	fmt.Fprintf(out, "0 upgraded, 0 newly installed, %d to remove and 0 not upgraded.\n", len(remove))
+	fmt.Fprintf(out, "After this operation, %s disk space will be freed.\n", aptSize(float64(freed)))
+	if !yes && !t.aptConfirm(in, out) {
+		return
+	}
+	fmt.Fprintf(out, "(Reading database ... %d files and directories currently installed.)\n", 70000+37*len(status))
+	for _, p := range remove {
+		fmt.Fprintf(out, "Removing %s (%s) ...\n", p.Name, p.Version)
+		t.debRemove(p)
+		pkgPause(out, 50*time.Millisecond)
+	}
+	fmt.Fprintln(out, "Processing triggers for man-db (2.10.2-1) ...")
+}
+
+// cmdDpkg 实现 dpkg 和 dpkg-query 的常用操作
+func (t *Terminal) cmdDpkg(args []string, out io.Writer) {
+	if len(args) < 2 {
+		fmt.Fprint(t.Stderr, "dpkg: error: need an action option\n\nType dpkg --help for help about installing and deinstalling packages [*];\n"+
+			"Use 'apt' or 'aptitude' for user-friendly package management;\n"+
+			"Type dpkg -Dhelp for a list of dpkg debug flag values;\nType dpkg --force-help for a list of forcing options;\n"+
+			"Type dpkg-deb --help for help about manipulating *.deb files;\n\nOptions marked [*] produce a lot of output - pipe it through 'less' or 'more' !\n")
+		t.lastExitCode = 2
+		return
+	}
+	action, rest := args[1], args[2:]
+	status := t.debStatus()
+	switch action {
+	case "-l", "--list":
+		t.dpkgList(status, rest, out)
+
+	case "-L", "--listfiles":
+		for _, n := range rest {
+			e, ok := t.FS.GetEntry("/var/lib/dpkg/info/" + n + ".list")
+			if _, inst := status[n]; !inst || !ok {
+				fmt.Fprintf(t.Stderr, "dpkg-query: package '%s' is not installed\nUse dpkg --contents (= dpkg-deb --contents) to list archive files contents.\n", n)
+				t.lastExitCode = 1
+				continue
+			}
+			e.mu.RLock()
+			out.Write(e.Content)
+			e.mu.RUnlock()
+		}
+
+	case "-s", "--status":
+		for i, n := range rest {
+			st, ok := status[n]
+			if !ok {
+				fmt.Fprintf(t.Stderr, "dpkg-query: package '%s' is not installed and no information is available\nUse dpkg --info (= dpkg-deb --info) to examine archive files.\n", n)
+				t.lastExitCode = 1
+				continue
+			}
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			io.WriteString(out, st)
+		}
+
+	case "-S", "--search":
+		for _, f := range rest {
+			found := false
+			for _, p := range debCatalog {
+				if _, inst := status[p.Name]; !inst {
+					continue
+				}
+				for _, b := range p.binPaths() {
+					if b == f || (!strings.HasPrefix(f, "/") && strings.Contains(b, f)) {
+						fmt.Fprintf(out, "%s: %s\n", p.Name, b)
+						found = true
+					}
+				}
+			}
+			if !found {
+				fmt.Fprintf(t.Stderr, "dpkg-query: no path found matching pattern %s\n", f)
+				t.lastExitCode = 1
+			}
+		}
+
+	case "--get-selections":
+		names := make([]string, 0, len(status))
+		for n := range status {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(out, "%s%sinstall\n", n, strings.Repeat("\t", max(1, 6-len(n)/8)))
+		}
+
+	case "-i", "--install", "-r", "--remove", "-P", "--purge", "--configure":
+		t.pkgIntel("dpkg", action, rest)
+		if t.userName() != "root" {
+			fmt.Fprintln(t.Stderr, "dpkg: error: requested operation requires superuser privilege")
+			t.lastExitCode = 2
+			return
+		}
+		switch action {
+		case "-i", "--install":
+			for _, f := range rest {
+				t.dpkgInstallFile(f, out)
+			}
+		case "-r", "--remove", "-P", "--purge":
+			fmt.Fprintf(out, "(Reading database ... %d files and directories currently installed.)\n", 70000+37*len(status))
+			for _, n := range rest {
+				p, ok := findDeb(n)
+				if _, inst := status[n]; !inst {
+					fmt.Fprintf(t.Stderr, "dpkg: warning: ignoring request to remove %s which isn't installed\n", n)
+					continue
+				}
+				if !ok {
+					p = debPackage{Name: n, Version: "", Installed: true}
+					p.Version, _ = debField(status[n], "Version")
+				}
+				if p.Essential {
+					fmt.Fprintf(t.Stderr, "dpkg: error processing package %s (--remove):\n this is an essential package; it should not be removed\n", n)
+					t.lastExitCode = 1
+					continue
+				}
+				fmt.Fprintf(out, "Removing %s (%s) ...\n", p.Name, p.Version)
+				t.debRemove(p)
+			}
+		}
+
+	default:
+		fmt.Fprintf(t.Stderr, "dpkg: error: unknown option %s\n\nType dpkg --help for help about installing and deinstalling packages [*];\n", action)
+		t.lastExitCode = 2
+	}
+}
+
+// dpkgList 按 dpkg -l 的表格输出，列宽随内容变化
+func (t *Terminal) dpkgList(status map[string]string, patterns []string, out io.Writer) {
+	var names []string
+	for n := range status {
+		match := len(patterns) == 0
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, n); ok {
+				match = true
+			}
+		}
+		if match {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(t.Stderr, "dpkg-query: no packages found matching %s\n", strings.Join(patterns, " "))
+		t.lastExitCode = 1
+		return
+	}
+	sort.Strings(names)
+	type row struct{ name, version, arch, desc string }
+	rows := make([]row, len(names))
+	nw, vw, aw, dw := len("Name"), len("Version"), len("Architecture"), len("Description")
+	for i, n := range names {
+		v, _ := debField(status[n], "Version")
+		a, _ := debField(status[n], "Architecture")
+		d, _ := debField(status[n], "Description")
+		rows[i] = row{n, v, a, d}
+		nw, vw, dw = max(nw, len(n)), max(vw, len(v)), max(dw, len(d))
+	}
+	fmt.Fprint(out, "Desired=Unknown/Install/Remove/Purge/Hold\n"+
+		"| Status=Not/Inst/Conf-files/Unpacked/halF-conf/Half-inst/trig-aWait/Trig-pend\n"+
+		"|/ Err?=(none)/Reinst-required (Status,Err: uppercase=bad)\n")
+	fmt.Fprintf(out, "||/ %-*s %-*s %-*s %s\n", nw, "Name", vw, "Version", aw, "Architecture", "Description")
+	fmt.Fprintf(out, "+++-%s-%s-%s-%s\n", strings.Repeat("=", nw), strings.Repeat("=", vw), strings.Repeat("=", aw), strings.Repeat("=", dw))
+	for _, r := range rows {
+		fmt.Fprintf(out, "ii  %-*s %-*s %-*s %s\n", nw, r.name, vw, r.version, aw, r.arch, r.desc)
+	}
+}
+
+// dpkgInstallFile 安装本地 .deb：内容送入隔离区，包名和版本取自文件名 (name_version_arch.deb)
+func (t *Terminal) dpkgInstallFile(f string, out io.Writer) {
+	p := t.FS.Abs(f)
+	e, ok := t.FS.GetEntry(p)
+	if !ok || e.IsDir {
+		fmt.Fprintf(t.Stderr, "dpkg: error: cannot access archive '%s': No such file or directory\n", f)
+		t.lastExitCode = 2
+		return
+	}
+	e.mu.RLock()
+	data := e.Content
+	e.mu.RUnlock()
+	CapturePayload("dpkg", t.Remote, p, data)
+	if !strings.HasPrefix(string(data), "!<arch>\n") {
+		fmt.Fprintf(t.Stderr, "dpkg-deb: error: '%s' is not a Debian format archive\n", f)
+		fmt.Fprintf(t.Stderr, "dpkg: error processing archive %s (--install):\n dpkg-deb --control subprocess returned error exit status 2\n", f)
+		fmt.Fprintf(t.Stderr, "Errors were encountered while processing:\n %s\n", f)
+		t.lastExitCode = 1
+		return
+	}
+	parts := strings.Split(strings.TrimSuffix(path.Base(p), ".deb"), "_")
+	pkg := debPackage{Name: parts[0], Version: "1.0", Section: "misc", Desc: "no description given"}
+	if len(parts) > 1 {
+		pkg.Version = parts[1]
+	}
+	pkg.Installed = true // 程序内容未知，不放置占位文件
+	fmt.Fprintf(out, "Selecting previously unselected package %s.\n", pkg.Name)
+	fmt.Fprintf(out, "(Reading database ... %d files and directories currently installed.)\n", 70000+37*len(t.debStatus()))
+	fmt.Fprintf(out, "Preparing to unpack %s ...\nUnpacking %s (%s) ...\nSetting up %s (%s) ...\n", f, pkg.Name, pkg.Version, pkg.Name, pkg.Version)
+	t.debUnpack(pkg)
+}
+
+// ---------- pip ----------
+
+// pipPackage PyPI 上的一个发行包，Native 为带扩展模块的平台 wheel
+type pipPackage struct {
+	Version string
+	Native  bool
+	Deps    []string
+}
+
+var pipCatalog = map[string]pipPackage{
+	"requests":           {"2.31.0", false, []string{"charset-normalizer", "idna", "urllib3", "certifi"}},
+	"charset-normalizer": {"3.3.2", true, nil},
+	"idna":               {"3.6", false, nil},
+	"urllib3":            {"2.1.0", false, nil},
+	"certifi":            {"2023.11.17", false, nil},
+	"paramiko":           {"3.4.0", false, []string{"bcrypt", "cryptography", "pynacl"}},
+	"bcrypt":             {"4.1.2", true, nil},
+	"cryptography":       {"41.0.7", true, []string{"cffi"}},
+	"cffi":               {"1.16.0", true, []string{"pycparser"}},
+	"pycparser":          {"2.21", false, nil},
+	"pynacl":             {"1.5.0", true, []string{"cffi"}},
+	"pycryptodome":       {"3.19.0", true, nil},
+	"pycryptodomex":      {"3.19.0", true, nil},
+	"scapy":              {"2.5.0", false, nil},
+	"impacket":           {"0.11.0", false, []string{"pyasn1", "pycryptodomex", "ldap3"}},
+	"pyasn1":             {"0.5.1", false, nil},
+	"ldap3":              {"2.9.1", false, []string{"pyasn1"}},
+	"psutil":             {"5.9.7", true, nil},
+	"colorama":           {"0.4.6", false, nil},
+	"beautifulsoup4":     {"4.12.2", false, []string{"soupsieve"}},
+	"soupsieve":          {"2.5", false, nil},
+	"pysocks":            {"1.7.1", false, nil},
+}
+
+// findPip 查找 PyPI 包；目录外的包按名字确定地生成版本号
+func findPip(name string) pipPackage {
+	if p, ok := pipCatalog[name]; ok {
+		return p
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	s := h.Sum32()
+	return pipPackage{Version: fmt.Sprintf("%d.%d.%d", s%4, s>>8%20, s>>16%10)}
+}
+
+// pipSize 与 pip 的 format_size 一致
+func pipSize(n float64) string {
+	switch {
+	case n > 1e6:
+		return fmt.Sprintf("%.1f MB", n/1e6)
+	case n > 10e3:
+		return fmt.Sprintf("%d kB", int(n/1e3))
+	case n > 1e3:
+		return fmt.Sprintf("%.1f kB", n/1e3)
+	}
+	return fmt.Sprintf("%d bytes", int(n))
+}
+
+// pipNormalize 按 PEP 503 规范化包名
+func pipNormalize(name string) string {
+	return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+}
+
+// pipSites 返回 root 和当前用户的安装目录
+func (t *Terminal) pipSites() []string {
+	return []string{"/usr/local/lib/python3.10/dist-packages", t.lookupVar("HOME") + "/.local/lib/python3.10/site-packages"}
+}
+
+// pipInstalled 扫描 *.dist-info 目录，返回规范化包名到 (显示名, 版本, 目录) 的映射
+func (t *Terminal) pipInstalled() map[string][3]string {
+	res := map[string][3]string{}
+	for _, site := range t.pipSites() {
+		files, _ := t.FS.ListDir(site)
+		for _, f := range files {
+			base, ok := strings.CutSuffix(f.Name, ".dist-info")
+			if !f.IsDir || !ok {
+				continue
+			}
+			if name, ver, ok := strings.Cut(base, "-"); ok {
+				res[pipNormalize(name)] = [3]string{strings.ReplaceAll(name, "_", "-"), ver, site}
+			}
+		}
+	}
+	return res
+}
+
+func (t *Terminal) cmdPip(args []string, in io.Reader, out io.Writer) {
+	if len(args) < 2 {
+		fmt.Fprintf(out, "\nUsage:   \n  %s <command> [options]\n\nCommands:\n  install                     Install packages.\n  download                    Download packages.\n  uninstall                   Uninstall packages.\n  freeze                      Output installed packages in requirements format.\n  list                        List installed packages.\n  show                        Show information about installed packages.\n", args[0])
+		return
+	}
+	switch args[1] {
+	case "-V", "--version":
+		fmt.Fprintln(out, "pip 22.0.2 from /usr/lib/python3/dist-packages/pip (python 3.10)")
+	case "install":
+		t.pipInstall(args, out)
+	case "uninstall":
+		t.pipUninstall(args, in, out)
+	case "list", "freeze":
+		installed := t.pipInstalled()
+		rows := [][2]string{{"pip", "22.0.2"}, {"setuptools", "59.6.0"}, {"wheel", "0.37.1"}}
+		for _, v := range installed {
+			rows = append(rows, [2]string{v[0], v[1]})
+		}
+		sort.Slice(rows, func(i, j int) bool { return strings.ToLower(rows[i][0]) < strings.ToLower(rows[j][0]) })
+		if args[1] == "freeze" {
+			for _, v := range rows {
+				if v[0] != "pip" && v[0] != "setuptools" && v[0] != "wheel" {
+					fmt.Fprintf(out, "%s==%s\n", v[0], v[1])
+				}
+			}
+			return
+		}
+		w := len("Package")
+		for _, r := range rows {
+			w = max(w, len(r[0]))
+		}
+		fmt.Fprintf(out, "%-*s Version\n%s -------\n", w, "Package", strings.Repeat("-", w))
+		for _, r := range rows {
+			fmt.Fprintf(out, "%-*s %s\n", w, r[0], r[1])
+		}
+	case "show":
+		installed := t.pipInstalled()
+		for _, n := range args[2:] {
+			v, ok := installed[pipNormalize(n)]
+			if !ok {
+				fmt.Fprintf(out, "WARNING: Package(s) not found: %s\n", n)
+				t.lastExitCode = 1
+				continue
+			}
+			fmt.Fprintf(out, "Name: %s\nVersion: %s\nSummary: \nHome-page: \nAuthor: \nAuthor-email: \nLicense: \nLocation: %s\nRequires: %s\nRequired-by: \n",
+				v[0], v[1], v[2], strings.Join(findPip(pipNormalize(n)).Deps, ", "))
+		}
+	default:
+		fmt.Fprintf(t.Stderr, "ERROR: unknown command \"%s\"\n", args[1])
+		t.lastExitCode = 1
+	}
+}
+
+func (t *Terminal) pipInstall(args []string, out io.Writer) {
+	user, quiet := false, false
+	var reqs []string
+	for i := 2; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--user":
+			user = true
+		case a == "-q" || a == "--quiet":
+			quiet = true
+		case a == "-r" || a == "--requirement":
+			if i+1 >= len(args) {
+				fmt.Fprintf(t.Stderr, "\nUsage:   \n  %s install [options] <requirement specifier> [package-index-options] ...\n\n-r option requires 1 argument\n", args[0])
+				t.lastExitCode = 2
+				return
+			}
+			i++
+			e, ok := t.FS.GetEntry(t.FS.Abs(args[i]))
+			if !ok || e.IsDir {
+				fmt.Fprintf(t.Stderr, "ERROR: Could not open requirements file: [Errno 2] No such file or directory: '%s'\n", args[i])
+				t.lastExitCode = 1
+				return
+			}
+			e.mu.RLock()
+			for _, line := range strings.Split(string(e.Content), "\n") {
+				if line, _, _ = strings.Cut(line, "#"); strings.TrimSpace(line) != "" {
+					reqs = append(reqs, strings.TrimSpace(line))
+				}
+			}
+			e.mu.RUnlock()
+		case strings.HasPrefix(a, "-"):
+			// -U、--upgrade、--no-cache-dir、--break-system-packages 等
+		default:
+			reqs = append(reqs, a)
+		}
+	}
+	if len(reqs) == 0 {
+		fmt.Fprintln(t.Stderr, "ERROR: You must give at least one requirement to install (see \"pip help install\")")
+		t.lastExitCode = 1
+		return
+	}
+	t.pkgIntel(args[0], "install", reqs)
+	if quiet {
+		out = io.Discard
+	}
+
+	root := t.userName() == "root"
+	site := t.pipSites()[0]
+	if user || !root {
+		if !user {
+			fmt.Fprintln(out, "Defaulting to user installation because normal site-packages is not writeable")
+		}
+		site = t.pipSites()[1]
+	}
+	installed := t.pipInstalled()
+	type dist struct {
+		name, version string
+		native        bool
+	}
+	var order []dist
+	seen := map[string]bool{}
+	var collect func(spec string, dep bool) bool
+	collect = func(spec string, dep bool) bool {
+		name := spec
+		pinned := ""
+		if i := strings.IndexAny(spec, "=<>!~;[ "); i >= 0 {
+			name = spec[:i]
+			if v, ok := strings.CutPrefix(spec[i:], "=="); ok {
+				pinned = strings.TrimSpace(v)
+			}
+		}
+		if name == "" || strings.ContainsAny(name, "/:@") {
+			fmt.Fprintf(t.Stderr, "ERROR: Invalid requirement: '%s'\n", spec)
+			t.lastExitCode = 1
+			return false
+		}
+		key := pipNormalize(name)
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+		if v, ok := installed[key]; ok {
+			fmt.Fprintf(out, "Requirement already satisfied: %s in %s (%s)\n", name, v[2], v[1])
+			return true
+		}
+		p := findPip(key)
+		if pinned != "" {
+			p.Version = pinned
+		}
+		fmt.Fprintf(out, "Collecting %s\n", spec)
+		tag := "py3-none-any"
+		if p.Native {
+			tag = "cp310-cp310-manylinux_2_17_x86_64.manylinux2014_x86_64"
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		size := 15e3 + float64(h.Sum32()%600e3)
+		if p.Native {
+			size += 1500e3
+		}
+		speed, elapsed := transferPace(int(size))
+		fmt.Fprintf(out, "  Downloading %s-%s-%s.whl (%s)\n", strings.ReplaceAll(name, "-", "_"), p.Version, tag, pipSize(size))
+		pkgPause(out, elapsed)
+		unit, div := "kB", 1e3
+		if size >= 1e6 {
+			unit, div = "MB", 1e6
+		}
+		fmt.Fprintf(out, "     %s %.1f/%.1f %s %s/s eta 0:00:00\n", strings.Repeat("━", 40), size/div, size/div, unit, pipSize(speed))
+		for _, d := range p.Deps {
+			if !collect(d, true) {
+				return false
+			}
+		}
+		order = append(order, dist{name, p.Version, p.Native})
+		return true
+	}
+	for _, r := range reqs {
+		if !collect(r, false) {
+			return
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+
+	var names, done []string
+	for _, d := range order {
+		names = append(names, d.name)
+		done = append(done, d.name+"-"+d.version)
+		mod := strings.ReplaceAll(pipNormalize(d.name), "-", "_")
+		info := site + "/" + mod + "-" + d.version + ".dist-info"
+		t.mkdirAll(info)
+		t.FS.Write(info+"/METADATA", []byte("Metadata-Version: 2.1\nName: "+d.name+"\nVersion: "+d.version+"\n"), 0644)
+		t.FS.Write(info+"/INSTALLER", []byte("pip\n"), 0644)
+		t.mkdirAll(site + "/" + mod)
+		t.FS.Write(site+"/"+mod+"/__init__.py", nil, 0644)
+	}
+	sort.Strings(done)
+	fmt.Fprintf(out, "Installing collected packages: %s\n", strings.Join(names, ", "))
+	fmt.Fprintf(out, "Successfully installed %s\n", strings.Join(done, " "))
+	if root && !user {
+		fmt.Fprintln(out, "WARNING: Running pip as the 'root' user can result in broken permissions and conflicting behaviour with the system package manager. It is recommended to use a virtual environment instead: https://pip.pypa.io/warnings/venv")
+	}
+}
+
+func (t *Terminal) pipUninstall(args []string, in io.Reader, out io.Writer) {
+	yes := false
+	var names []string
+	for _, a := range args[2:] {
+		if a == "-y" || a == "--yes" {
+			yes = true
+		} else if !strings.HasPrefix(a, "-") {
+			names = append(names, a)
+		}
+	}
+	t.pkgIntel(args[0], "uninstall", names)
+	installed := t.pipInstalled()
+	for _, n := range names {
+		v, ok := installed[pipNormalize(n)]
+		if !ok {
+			fmt.Fprintf(out, "WARNING: Skipping %s as it is not installed.\n", n)
+			continue
+		}
+		mod := strings.ReplaceAll(pipNormalize(n), "-", "_")
+		info := v[2] + "/" + mod + "-" + v[1] + ".dist-info"
+		if !strings.HasPrefix(v[2], t.lookupVar("HOME")) && t.userName() != "root" {
+			fmt.Fprintf(t.Stderr, "ERROR: Exception:\nPermissionError: [Errno 13] Permission denied: '%s'\n", info)
+			t.lastExitCode = 2
+			return
+		}
+		fmt.Fprintf(out, "Found existing installation: %s %s\nUninstalling %s-%s:\n", v[0], v[1], v[0], v[1])
+		if !yes {
+			fmt.Fprintf(out, "  Would remove:\n    %s/*\n    %s/%s/*\nProceed (Y/n)? ", info, v[2], mod)
+			reply, ok := t.readReply(in, out, true)
+			if !ok || (reply != "" && !strings.EqualFold(reply, "y")) {
+				continue
+			}
+		}
+		for _, d := range []string{info, v[2] + "/" + mod} {
+			files, _ := t.FS.ListDir(d)
+			for _, f := range files {
+				t.FS.Remove(d + "/" + f.Name)
+			}
+			t.FS.Remove(d)
+		}
+		fmt.Fprintf(out, "  Successfully uninstalled %s-%s\n", v[0], v[1])
+	}
+}
+
+// ---------- 找不到命令 ----------
+
+// cnfSimilar Ubuntu 上没有、command-not-found 只能给出相似命令的常见外来命令
+var cnfSimilar = map[string]string{
+	"yum":    "  “gum” 命令来自 snap gum (0.13.0)\n  “sum” 命令来自 deb coreutils (8.32-4.1ubuntu1)\n  “num” 命令来自 deb quickcal (2.4-1)\n",
+	"apk":    "  “ark” 命令来自 deb ark (4:21.12.3-0ubuntu1)\n  “apt” 命令来自 deb apt (2.4.11)\n",
+	"zypper": "  “zipper” 命令来自 deb zipper.app (1.5-3)\n",
+}
+
+// commandNotFound 处理 PATH 中没有内置实现的命令：已安装软件包的程序因动态库版本不符无法运行，
+// 软件源中有的程序按 command-not-found 的格式提示安装方法。返回 false 时由调用方输出“未找到命令”
+func (t *Terminal) commandNotFound(args []string, out io.Writer) bool {
+	cmd := args[0]
+	if p, ok := t.lookPath(cmd); ok {
+		if e, _ := t.FS.GetEntry(p); string(e.Content) == elfStub {
+			if _, ok := t.debOwner(p); ok {
+				fmt.Fprintf(t.Stderr, "%s: /lib/x86_64-linux-gnu/libc.so.6: version `GLIBC_2.38' not found (required by %s)\n", cmd, cmd)
+				t.lastExitCode = 1
+				return true
+			}
+		}
+	}
+	if strings.Contains(cmd, "/") {
+		return false
+	}
+	if len(args) > 2 && args[1] == "install" {
+		t.pkgIntel(cmd, "install", args[2:])
+	}
+	sudo := "sudo "
+	if t.userName() == "root" {
+		sudo = ""
+	}
+	if similar, ok := cnfSimilar[cmd]; ok {
+		fmt.Fprintf(t.Stderr, "找不到命令 “%s”，您的意思是：\n%s尝试 %sapt install <deb name>\n", cmd, similar, sudo)
+		t.lastExitCode = 127
+		return true
+	}
+	status := t.debStatus()
+	for _, p := range debCatalog {
+		if _, inst := status[p.Name]; inst {
+			continue
+		}
+		for _, b := range p.binPaths() {
+			if path.Base(b) == cmd {
+				fmt.Fprintf(t.Stderr, "找不到命令 “%s”，但可以通过以下软件包安装它：\n\n%sapt install %s\n\n", cmd, sudo, p.Name)
+				t.lastExitCode = 127
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,1621 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// 模拟进程表
+// 预置与 HostPersona 一致的守护进程，会话的登录 shell 和前台命令在运行时登记，
+// ps/top/kill/pgrep 以及 /proc/<pid> 都从这里取数据，保证各处看到的进程一致
+// ==========================================
+
+const (
+	pidMax      = 4194304  // Ubuntu 22.04 的 kernel.pid_max
+	memTotalKiB = 16303284 // 与 /proc/meminfo 保持一致
+)
+
+// Process 模拟进程
+type Process struct {
+	PID    int
+	PPID   int
+	User   string
+	TTY    string // "?" 表示没有控制终端
+	Stat   string // ps 的 STAT 列，如 Ss、R+、I<
+	Start  time.Time
+	Load   float64 // 平均 CPU 占用比例，累计 CPU 时间随存活时间增长
+	VSZ    int     // KiB
+	RSS    int     // KiB
+	Name   string  // comm，为空时取自 Args[0]
+	Args   []string
+	Env    map[string]string
+	Kernel bool   // 内核线程，ps 中显示为 [name]
+	Unit   string // 所属 systemd 单元
+
+	term  *Terminal // 所属会话，登录 shell 被杀死时结束会话
+	shell bool      // 交互式 shell 忽略 SIGTERM/SIGINT/SIGQUIT
+	job   *Job      // 所属作业，信号转交作业控制处理
+}
+
+// Comm 返回进程名 (/proc/<pid>/comm)，与内核一样截断为 15 个字符
+func (p *Process) Comm() string {
+	name := p.Name
+	if name == "" && len(p.Args) > 0 {
+		name = p.Args[0]
+		// setproctitle 风格的标题，如 "sshd: root@pts/0"
+		if i := strings.Index(name, ": "); i > 0 {
+			name = name[:i]
+		}
+		name = path.Base(strings.TrimPrefix(name, "-"))
+	}
+	if !p.Kernel && len(name) > 15 {
+		name = name[:15]
+	}
+	return name
+}
+
+// Cmdline 返回完整命令行，内核线程没有命令行，显示为 [name]
+func (p *Process) Cmdline() string {
+	if p.Kernel || len(p.Args) == 0 {
+		return "[" + p.Comm() + "]"
+	}
+	return strings.Join(p.Args, " ")
+}
+
+// CPUTime 返回累计 CPU 时间
+func (p *Process) CPUTime() time.Duration {
+	return time.Duration(p.Load * float64(time.Since(p.Start)))
+}
+
+// PCPU 返回 ps 口径的 %CPU：累计 CPU 时间 / 存活时间
+func (p *Process) PCPU() float64 {
+	return p.Load * 100
+}
+
+// PMem 返回 %MEM
+func (p *Process) PMem() float64 {
+	return float64(p.RSS) / memTotalKiB * 100
+}
+
+// ProcessTable 进程表
+type ProcessTable struct {
+	mu     sync.Mutex
+	procs  map[int]*Process
+	next   int
+	ttys   map[int]bool // 已分配的 pts 编号
+	ttyMin int          // 最小的可能空闲 pts 编号
+}
+
+// Procs 全局进程表，所有会话共享
+var Procs = newProcessTable()
+
+var (
+	errNoProcess    = errors.New("没有那个进程")
+	errNotPermitted = errors.New("不允许的操作")
+)
+
+func newProcessTable() *ProcessTable {
+	pt := &ProcessTable{
+		procs: make(map[int]*Process),
+		next:  1200 + rand.Intn(800),
+		ttys:  make(map[int]bool),
+	}
+	pt.seed()
+	return pt
+}
+
+// seed 预置开机后常驻的内核线程和守护进程
+func (pt *ProcessTable) seed() {
+	// cpu 为每小时消耗的 CPU 时间
+	add := func(pid, ppid int, user, stat string, after time.Duration, cpu time.Duration, vsz, rss int, cmd string) *Process {
+		var args []string
+		if strings.Contains(cmd, ": ") {
+			args = []string{cmd}
+		} else {
+			args = strings.Fields(cmd)
+		}
+		p := &Process{
+			PID: pid, PPID: ppid, User: user, TTY: "?", Stat: stat,
+			Start: startTime.Add(after), Load: float64(cpu) / float64(time.Hour), VSZ: vsz, RSS: rss, Args: args,
+			Env: map[string]string{
+				"LANG":          "C.UTF-8",
+				"PATH":          "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+				"INVOCATION_ID": fmt.Sprintf("%032x", rand.Uint64()),
+			},
+		}
+		pt.procs[pid] = p
+		return p
+	}
+
+	add(1, 0, "root", "Ss", 0, 2300*time.Millisecond, 168532, 12856, "/sbin/init").Name = "systemd"
+
+	kthreads := []struct {
+		pid  int
+		stat string
+		name string
+	}{
+		{2, "S", "kthreadd"}, {3, "I<", "rcu_gp"}, {4, "I<", "rcu_par_gp"}, {5, "I<", "slub_flushwq"},
+		{6, "I<", "netns"}, {8, "I<", "kworker/0:0H-events_highpri"}, {10, "I<", "mm_percpu_wq"},
+		{11, "S", "rcu_tasks_rude_"}, {12, "S", "rcu_tasks_trace"}, {13, "S", "ksoftirqd/0"},
+		{14, "I", "rcu_sched"}, {15, "S", "migration/0"}, {16, "S", "idle_inject/0"}, {18, "S", "cpuhp/0"},
+		{19, "S", "cpuhp/1"}, {20, "S", "idle_inject/1"}, {21, "S", "migration/1"}, {22, "S", "ksoftirqd/1"},
+		{25, "S", "kdevtmpfs"}, {26, "I<", "inet_frag_wq"}, {27, "S", "kauditd"}, {28, "S", "khungtaskd"},
+		{29, "S", "oom_reaper"}, {30, "I<", "writeback"}, {31, "S", "kcompactd0"}, {32, "SN", "ksmd"},
+		{33, "SN", "khugepaged"}, {80, "I<", "kintegrityd"}, {81, "I<", "kblockd"}, {93, "S", "kswapd0"},
+		{143, "I", "kworker/1:2-events"}, {187, "I<", "kworker/u5:0"}, {231, "S", "jbd2/sda2-8"},
+	}
+	for _, k := range kthreads {
+		ppid := 2
+		if k.pid == 2 {
+			ppid = 0
+		}
+		p := add(k.pid, ppid, "root", k.stat, 0, 0, 0, 0, "")
+		p.Name, p.Kernel, p.Env = k.name, true, nil
+	}
+
+	add(289, 1, "root", "S<s", 2*time.Second, 310*time.Millisecond, 47948, 15724, "/lib/systemd/systemd-journald")
+	add(331, 1, "root", "Ss", 2*time.Second, 540*time.Millisecond, 25312, 6248, "/lib/systemd/systemd-udevd")
+	add(575, 1, "systemd-network", "Ss", 4*time.Second, 40*time.Millisecond, 16124, 8196, "/lib/systemd/systemd-networkd")
+	add(577, 1, "systemd-resolve", "Ss", 4*time.Second, 60*time.Millisecond, 25532, 12800, "/lib/systemd/systemd-resolved")
+	add(579, 1, "systemd-timesync", "Ssl", 4*time.Second, 20*time.Millisecond, 89356, 6544, "/lib/systemd/systemd-timesyncd")
+	add(640, 1, "root", "Ss", 5*time.Second, 10*time.Millisecond, 6896, 2956, "/usr/sbin/cron -f -P")
+	add(641, 1, "messagebus", "Ss", 5*time.Second, 90*time.Millisecond, 8628, 4860, "@dbus-daemon --system --address=systemd: --nofork --nopidfile --systemd-activation --syslog-only")
+	add(648, 1, "root", "Ssl", 5*time.Second, 120*time.Millisecond, 82768, 3864, "/usr/sbin/irqbalance --foreground")
+	add(652, 1, "syslog", "Ssl", 5*time.Second, 70*time.Millisecond, 222400, 6016, "/usr/sbin/rsyslogd -n -iNONE")
+	add(655, 1, "root", "Ss", 5*time.Second, 50*time.Millisecond, 15072, 7380, "/lib/systemd/systemd-logind")
+	add(700, 1, "root", "Ss+", 6*time.Second, 0, 6172, 1088, "/sbin/agetty -o -p -- \\u --noclear tty1 linux").TTY = "tty1"
+	add(832, 1, "root", "Ss", 6*time.Second, 20*time.Millisecond, 15432, 9084, "sshd: /usr/sbin/sshd -D [listener] 0 of 10-100 startups")
+	add(845, 1, "root", "Ss", 6*time.Second, 0, 6832, 2720, "/usr/sbin/vsftpd /etc/vsftpd.conf")
+	add(858, 1, "root", "Ss", 6*time.Second, 0, 4852, 1904, "/usr/sbin/inetd")
+	add(861, 1, "redis", "Ssl", 6*time.Second, 900*time.Millisecond, 65148, 6740, "/usr/bin/redis-server 0.0.0.0:6379")
+	add(902, 1, "mysql", "Ssl", 7*time.Second, 4100*time.Millisecond, 1810652, 392424, "/usr/sbin/mysqld")
+	add(950, 1, "root", "Ss", 7*time.Second, 150*time.Millisecond, 6868, 4696, "/usr/sbin/apache2 -k start")
+	add(951, 950, "www-data", "S", 7*time.Second, 0, 1211404, 4412, "/usr/sbin/apache2 -k start")
+	add(952, 950, "www-data", "S", 7*time.Second, 0, 1211404, 4412, "/usr/sbin/apache2 -k start")
+	add(1102, 1, "root", "Ss", 8*time.Second, 30*time.Millisecond, 40104, 4612, "/usr/lib/postfix/sbin/master -w")
+	add(1103, 1102, "postfix", "S", 8*time.Second, 0, 40472, 6404, "pickup -l -t unix -u -c")
+	add(1104, 1102, "postfix", "S", 8*time.Second, 0, 40520, 6492, "qmgr -l -t unix -u")
+	// dbus-daemon 的 argv[0] 带 @ 前缀，comm 仍为 dbus-daemon
+	pt.procs[641].Name = "dbus-daemon"
+	for pid, unit := range unitPIDs {
+		pt.procs[pid].Unit = unit
+	}
+	for _, pid := range sortedPIDs(unitPIDs) {
+		p := pt.procs[pid]
+		unitSeeds[p.Unit] = append(unitSeeds[p.Unit], *p)
+	}
+}
+
+// AllocPID 分配下一个空闲 PID
+func (pt *ProcessTable) AllocPID() int {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.allocLocked()
+}
+
+func (pt *ProcessTable) allocLocked() int {
+	for {
+		pt.next++
+		if pt.next >= pidMax {
+			pt.next = 300
+		}
+		if _, used := pt.procs[pt.next]; !used {
+			return pt.next
+		}
+	}
+}
+
+// LastPID 返回最近分配的 PID (/proc/loadavg 的最后一列)
+func (pt *ProcessTable) LastPID() int {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.next
+}
+
+// Add 登记进程，PID 为 0 时自动分配
+func (pt *ProcessTable) Add(p *Process) *Process {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if p.PID == 0 {
+		p.PID = pt.allocLocked()
+	}
+	if p.Start.IsZero() {
+		p.Start = time.Now()
+	}
+	if p.TTY == "" {
+		p.TTY = "?"
+	}
+	pt.procs[p.PID] = p
+	return p
+}
+
+// Remove 注销进程
+func (pt *ProcessTable) Remove(pid int) {
+	pt.mu.Lock()
+	delete(pt.procs, pid)
+	pt.mu.Unlock()
+}
+
+// Get 返回进程的快照
+func (pt *ProcessTable) Get(pid int) (Process, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	p, ok := pt.procs[pid]
+	if !ok {
+		return Process{}, false
+	}
+	return *p, true
+}
+
+// Snapshot 返回按 PID 排序的进程快照
+func (pt *ProcessTable) Snapshot() []Process {
+	pt.mu.Lock()
+	res := make([]Process, 0, len(pt.procs))
+	for _, p := range pt.procs {
+		res = append(res, *p)
+	}
+	pt.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].PID < res[j].PID })
+	return res
+}
+
+// Login 为交互式会话分配 pts 并登记 sshd 会话进程和登录 shell，返回注销函数
+func (pt *ProcessTable) Login(t *Terminal, user string, env map[string]string) func() {
+	pt.mu.Lock()
+	n := pt.ttyMin
+	for pt.ttys[n] {
+		n++
+	}
+	pt.ttys[n] = true
+	pt.ttyMin = n + 1
+	tty := fmt.Sprintf("pts/%d", n)
+
+	now := time.Now()
+	leader := &Process{
+		PID: pt.allocLocked(), PPID: 832, User: user, TTY: "?", Stat: "Ss", Start: now,
+		VSZ: 17184, RSS: 10944, Args: []string{fmt.Sprintf("sshd: %s@%s", user, tty)}, term: t,
+	}
+	pt.procs[leader.PID] = leader
+	if _, used := pt.procs[t.pid]; used {
+		t.pid = pt.allocLocked()
+	}
+	shell := &Process{
+		PID: t.pid, PPID: leader.PID, User: user, TTY: tty, Stat: "Ss", Start: now,
+		VSZ: 8608 + rand.Intn(200), RSS: 5200 + rand.Intn(300), Args: []string{"-bash"},
+		Env: env, term: t, shell: true,
+	}
+	pt.procs[shell.PID] = shell
+	pt.mu.Unlock()
+
+	t.mu.Lock()
+	t.tty = tty
+	t.mu.Unlock()
+
+	return func() {
+		pt.mu.Lock()
+		defer pt.mu.Unlock()
+		delete(pt.procs, leader.PID)
+		delete(pt.procs, shell.PID)
+		delete(pt.ttys, n)
+		if n < pt.ttyMin {
+			pt.ttyMin = n
+		}
+	}
+}
+
+// Signal 以 user 身份向 pid 发送信号，返回信号送达前的进程快照
+func (pt *ProcessTable) Signal(pid, sig int, user string) (Process, error) {
+	pt.mu.Lock()
+	p, ok := pt.procs[pid]
+	if !ok {
+		pt.mu.Unlock()
+		return Process{}, errNoProcess
+	}
+	snap := *p
+	if user != "root" && p.User != user {
+		pt.mu.Unlock()
+		return snap, errNotPermitted
+	}
+	// 内核线程和 init 不接收未处理的信号
+	if sig == 0 || p.Kernel || pid == 1 {
+		pt.mu.Unlock()
+		return snap, nil
+	}
+
+	job := p.job
+	switch sig {
+	case sigSTOP, sigTSTP, sigTTIN, sigTTOU:
+		if !strings.HasPrefix(p.Stat, "T") {
+			p.Stat = "T" + p.Stat[1:]
+		}
+		pt.mu.Unlock()
+		if job != nil {
+			job.setState(jobStopped)
+		}
+		return snap, nil
+	case sigCONT:
+		if strings.HasPrefix(p.Stat, "T") {
+			p.Stat = "S" + p.Stat[1:]
+		}
+		pt.mu.Unlock()
+		if job != nil {
+			job.setState(jobRunning)
+		}
+		return snap, nil
+	case sigCHLD, sigWINCH, sigURG:
+		pt.mu.Unlock()
+		return snap, nil
+	case sigHUP, sigUSR1, sigUSR2:
+		// 守护进程把这些信号当作重新加载配置
+		if p.term == nil && p.TTY == "?" && job == nil {
+			pt.mu.Unlock()
+			return snap, nil
+		}
+	case sigTERM, sigINT, sigQUIT:
+		if p.shell {
+			pt.mu.Unlock()
+			return snap, nil
+		}
+	}
+
+	delete(pt.procs, pid)
+	term := p.term
+	pt.mu.Unlock()
+
+	// 作业中的进程被杀死，结束整个作业
+	if job != nil {
+		job.kill(sig)
+	}
+	// 会话进程被杀死，结束对应的会话
+	if term != nil {
+		term.Running = false
+	}
+	return snap, nil
+}
+
+// ==========================================
+// 信号
+// ==========================================
+
+const (
+	sigHUP   = 1
+	sigINT   = 2
+	sigQUIT  = 3
+	sigKILL  = 9
+	sigUSR1  = 10
+	sigSEGV  = 11
+	sigUSR2  = 12
+	sigTERM  = 15
+	sigCHLD  = 17
+	sigCONT  = 18
+	sigSTOP  = 19
+	sigTSTP  = 20
+	sigTTIN  = 21
+	sigTTOU  = 22
+	sigURG   = 23
+	sigWINCH = 28
+)
+
+var signalNames = []string{"", "HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE", "KILL", "USR1",
+	"SEGV", "USR2", "PIPE", "ALRM", "TERM", "STKFLT", "CHLD", "CONT", "STOP", "TSTP", "TTIN", "TTOU",
+	"URG", "XCPU", "XFSZ", "VTALRM", "PROF", "WINCH", "POLL", "PWR", "SYS"}
+
+// signalName 返回不带 SIG 前缀的信号名
+func signalName(sig int) string {
+	switch {
+	case sig > 0 && sig < len(signalNames):
+		return signalNames[sig]
+	case sig == 34:
+		return "RTMIN"
+	case sig > 34 && sig < 50:
+		return fmt.Sprintf("RTMIN+%d", sig-34)
+	case sig >= 50 && sig < 64:
+		return fmt.Sprintf("RTMAX-%d", 64-sig)
+	case sig == 64:
+		return "RTMAX"
+	}
+	return strconv.Itoa(sig)
+}
+
+// parseSignal 解析信号编号或名称 (KILL、SIGKILL、kill 均可)
+func parseSignal(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 0 && n <= 64
+	}
+	s = strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	switch s {
+	case "IOT":
+		return 6, true
+	case "CLD":
+		return sigCHLD, true
+	case "IO":
+		return 29, true
+	}
+	for i := 1; i <= 64; i++ {
+		if signalName(i) == s {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// ==========================================
+// /proc/<pid>
+// ==========================================
+
+// procEntry 为 /proc/<pid> 及 cmdline、comm、environ、status 合成文件
+func procEntry(fs *SessionFS, p string) (*FileEntry, bool) {
+	rest, ok := strings.CutPrefix(p, "/proc/")
+	if !ok {
+		return nil, false
+	}
+	pidStr, file, _ := strings.Cut(rest, "/")
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return nil, false
+	}
+	proc, ok := Procs.Get(pid)
+	if !ok {
+		return nil, false
+	}
+	uid, gid := fs.owner(proc.User)
+	uid, gid = max(uid, 0), max(gid, 0)
+	if file == "" {
+		return &FileEntry{Name: pidStr, IsDir: true, Mode: 0555 | os.ModeDir, ModTime: proc.Start, UID: uid, GID: gid, Nlink: 9}, true
+	}
+
+	var content string
+	mode := os.FileMode(0444)
+	switch file {
+	case "cmdline":
+		if !proc.Kernel {
+			content = strings.Join(proc.Args, "\x00") + "\x00"
+		}
+	case "comm":
+		content = proc.Comm() + "\n"
+		mode = 0644
+	case "environ":
+		keys := make([]string, 0, len(proc.Env))
+		for k := range proc.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			content += k + "=" + proc.Env[k] + "\x00"
+		}
+		mode = 0400
+	case "status":
+		content = proc.status(uid, gid)
+	default:
+		return nil, false
+	}
+	return &FileEntry{Name: file, Content: []byte(content), Mode: mode, ModTime: proc.Start, UID: uid, GID: gid, Nlink: 1}, true
+}
+
+// procDirEntries 返回 /proc 下的 pid 目录或 /proc/<pid> 下的文件
+func procDirEntries(fs *SessionFS, dir string) []*FileEntry {
+	var res []*FileEntry
+	if dir == "/proc" {
+		for _, p := range Procs.Snapshot() {
+			if e, ok := procEntry(fs, "/proc/"+strconv.Itoa(p.PID)); ok {
+				res = append(res, e)
+			}
+		}
+		return res
+	}
+	for _, f := range []string{"cmdline", "comm", "environ", "status"} {
+		if e, ok := procEntry(fs, dir+"/"+f); ok {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (p *Process) status(uid, gid int) string {
+	states := map[byte]string{'R': "R (running)", 'S': "S (sleeping)", 'D': "D (disk sleep)",
+		'T': "T (stopped)", 'Z': "Z (zombie)", 'I': "I (idle)"}
+	state := "S (sleeping)"
+	if len(p.Stat) > 0 {
+		if s, ok := states[p.Stat[0]]; ok {
+			state = s
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Name:\t%s\nUmask:\t0022\nState:\t%s\nTgid:\t%d\nNgid:\t0\nPid:\t%d\nPPid:\t%d\nTracerPid:\t0\n",
+		p.Comm(), state, p.PID, p.PID, p.PPID)
+	fmt.Fprintf(&b, "Uid:\t%d\t%d\t%d\t%d\nGid:\t%d\t%d\t%d\t%d\nFDSize:\t64\nGroups:\t%d \n", uid, uid, uid, uid, gid, gid, gid, gid, gid)
+	fmt.Fprintf(&b, "NStgid:\t%d\nNSpid:\t%d\nNSpgid:\t%d\nNSsid:\t%d\n", p.PID, p.PID, p.PID, p.PID)
+	if !p.Kernel {
+		fmt.Fprintf(&b, "VmPeak:\t%8d kB\nVmSize:\t%8d kB\nVmLck:\t       0 kB\nVmHWM:\t%8d kB\nVmRSS:\t%8d kB\n", p.VSZ, p.VSZ, p.RSS, p.RSS)
+	}
+	b.WriteString("Threads:\t1\nSigQ:\t0/63439\nSigPnd:\t0000000000000000\nShdPnd:\t0000000000000000\n")
+	b.WriteString("Cpus_allowed:\t3\nCpus_allowed_list:\t0-1\nvoluntary_ctxt_switches:\t" + strconv.Itoa(100+p.PID%900) + "\nnonvoluntary_ctxt_switches:\t" + strconv.Itoa(p.PID%37) + "\n")
+	return b.String()
+}
+
+// ==========================================
+// 会话辅助
+// ==========================================
+
+// userName 返回会话的当前用户
+func (t *Terminal) userName() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u := t.Env["USER"]; u != "" {
+		return u
+	}
+	return "root"
+}
+
+// spawn 在当前会话下登记一个前台子进程，调用方负责 Procs.Remove
+// 在作业中运行时直接使用作业已登记的进程
+func (t *Terminal) spawn(args []string) *Process {
+	if t.job != nil {
+		for _, p := range t.job.procs {
+			if p.Args[0] == args[0] {
+				return p
+			}
+		}
+	}
+	t.mu.Lock()
+	tty := t.tty
+	t.mu.Unlock()
+	stat := "R+"
+	if tty == "?" {
+		stat = "R"
+	}
+	return Procs.Add(&Process{
+		PPID: t.pid, User: t.userName(), TTY: tty, Stat: stat,
+		VSZ: 9000 + rand.Intn(4000), RSS: 3000 + rand.Intn(1500),
+		Args: append([]string(nil), args...),
+	})
+}
+
+// execFile 执行会话文件系统中的文件：脚本交给 sh，ELF 程序模拟为脱离终端的守护进程
+func (t *Terminal) execFile(args []string, in io.Reader, out io.Writer) bool {
+	name := args[0]
+	if !strings.Contains(name, "/") {
+		return false
+	}
+	p := t.FS.Abs(name)
+	e, ok := t.FS.GetEntry(p)
+	switch {
+	case !ok:
+		fmt.Fprintf(t.Stderr, "bash: %s: 没有那个文件或目录\n", name)
+		t.lastExitCode = 127
+		return true
+	case e.IsDir:
+		fmt.Fprintf(t.Stderr, "bash: %s: 是一个目录\n", name)
+		t.lastExitCode = 126
+		return true
+	case e.Mode&0111 == 0:
+		fmt.Fprintf(t.Stderr, "bash: %s: 权限不够\n", name)
+		t.lastExitCode = 126
+		return true
+	}
+	e.mu.RLock()
+	content := e.Content
+	e.mu.RUnlock()
+
+	if !bytes.HasPrefix(content, []byte("\x7fELF")) {
+		t.runScript("sh", append([]string{"sh", p}, args[1:]...), in, out)
+		return true
+	}
+
+	// 恶意程序通常会 fork 到后台：登记为脱离终端的进程，立即返回
+	user := t.userName()
+	CapturePayload("exec", t.Remote, p, content)
+	log.Printf("[Exec] %s: %s executed %s", t.Remote, user, strings.Join(args, " "))
+	t.mu.Lock()
+	env := make(map[string]string, len(t.Env))
+	for k, v := range t.Env {
+		env[k] = v
+	}
+	t.mu.Unlock()
+	Procs.Add(&Process{
+		PPID: 1, User: user, TTY: "?", Stat: "Ssl", Load: execLoad(args, content),
+		VSZ: 200000 + rand.Intn(2000000), RSS: 4000 + rand.Intn(60000),
+		Args: append([]string(nil), args...), Env: env,
+	})
+	return true
+}
+
+// execLoad 估计后台程序的 CPU 占用：挖矿程序会占满 CPU
+func execLoad(args []string, content []byte) float64 {
+	cmdline := strings.ToLower(strings.Join(args, " "))
+	if strings.Contains(cmdline, "stratum") || strings.Contains(cmdline, "xmr") || strings.Contains(cmdline, "pool") ||
+		bytes.Contains(content, []byte("stratum+tcp")) {
+		return 0.97
+	}
+	return 0
+}
+
+// ==========================================
+// ps
+// ==========================================
+
+type psColumn struct {
+	header string
+	width  int
+	left   bool
+	value  func(p *Process) string
+}
+
+func psUser(name string) string {
+	if len(name) > 8 {
+		return name[:7] + "+"
+	}
+	return name
+}
+
+func psStart(t time.Time) string {
+	if time.Since(t) < 24*time.Hour && t.Day() == time.Now().Day() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan02")
+}
+
+func psElapsed(d time.Duration) string {
+	s := int(d.Seconds())
+	days, h, m, sec := s/86400, s/3600%24, s/60%60, s%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, h, m, sec)
+	case h > 0:
+		return fmt.Sprintf("%02d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%02d:%02d", m, sec)
+}
+
+func psCPUTime(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+var psColumns = map[string]psColumn{
+	"pid":  {"PID", 7, false, func(p *Process) string { return strconv.Itoa(p.PID) }},
+	"ppid": {"PPID", 7, false, func(p *Process) string { return strconv.Itoa(p.PPID) }},
+	"user": {"USER", 8, true, func(p *Process) string { return psUser(p.User) }},
+	"uid":  {"UID", 5, false, func(p *Process) string { return strconv.Itoa(max(GlobalSessionFS.uid(p.User), 0)) }}, // 进程表是全局的，属主按共享文件系统解析
+	"comm": {"COMMAND", 15, true, func(p *Process) string { return p.Comm() }},
+	"args": {"COMMAND", 0, true, func(p *Process) string { return p.Cmdline() }},
+	"%cpu": {"%CPU", 4, false, func(p *Process) string { return fmt.Sprintf("%.1f", p.PCPU()) }},
+	"%mem": {"%MEM", 4, false, func(p *Process) string { return fmt.Sprintf("%.1f", p.PMem()) }},
+	"vsz":  {"VSZ", 6, false, func(p *Process) string { return strconv.Itoa(p.VSZ) }},
+	"rss":  {"RSS", 5, false, func(p *Process) string { return strconv.Itoa(p.RSS) }},
+	"tty":  {"TT", 8, true, func(p *Process) string { return p.TTY }},
+	"stat": {"STAT", 4, true, func(p *Process) string { return p.Stat }},
+	"s":    {"S", 1, true, func(p *Process) string { return p.Stat[:1] }},
+	"start": {"STARTED", 8, false, func(p *Process) string {
+		if time.Since(p.Start) < 24*time.Hour {
+			return p.Start.Format("15:04:05")
+		}
+		return p.Start.Format("Jan 02")
+	}},
+	"bsdstart": {"START", 5, true, func(p *Process) string { return psStart(p.Start) }},
+	"stime":    {"STIME", 5, true, func(p *Process) string { return psStart(p.Start) }},
+	"lstart":   {"STARTED", 24, true, func(p *Process) string { return p.Start.Format("Mon Jan _2 15:04:05 2006") }},
+	"etime":    {"ELAPSED", 11, false, func(p *Process) string { return psElapsed(time.Since(p.Start)) }},
+	"time":     {"TIME", 8, false, func(p *Process) string { return psCPUTime(p.CPUTime()) }},
+	"bsdtime": {"TIME", 6, false, func(p *Process) string {
+		s := int(p.CPUTime().Seconds())
+		return fmt.Sprintf("%d:%02d", s/60, s%60)
+	}},
+	"ni": {"NI", 3, false, func(p *Process) string {
+		if strings.Contains(p.Stat, "<") {
+			return "-20"
+		}
+		if strings.Contains(p.Stat, "N") {
+			return "5"
+		}
+		return "0"
+	}},
+	"c": {"C", 2, false, func(p *Process) string { return strconv.Itoa(int(p.PCPU())) }},
+}
+
+// psAliases 是 ps -o 接受的别名及其默认表头
+var psAliases = map[string][2]string{
+	"pcpu": {"%cpu", ""}, "pmem": {"%mem", ""}, "cmd": {"args", "CMD"}, "command": {"args", ""},
+	"ucomm": {"comm", ""}, "euser": {"user", "EUSER"}, "uname": {"user", "USER"}, "ruser": {"user", "RUSER"},
+	"tname": {"tty", "TTY"}, "tt": {"tty", ""}, "vsize": {"vsz", ""}, "rssize": {"rss", ""}, "rsz": {"rss", "RSZ"},
+	"nice": {"ni", ""}, "cputime": {"time", ""}, "start_time": {"stime", "START"}, "state": {"s", ""},
+	"sid": {"pid", "SID"}, "pgid": {"pid", "PGID"}, "tid": {"pid", "TID"}, "lwp": {"pid", "LWP"},
+}
+
+type psField struct {
+	key    string
+	header string
+}
+
+// parsePsFormat 解析 -o 格式，如 "pid,user,args" 或 "pid=,comm=NAME"
+func parsePsFormat(spec string) ([]psField, error) {
+	var fields []psField
+	for len(spec) > 0 {
+		var item string
+		if i := strings.IndexAny(spec, ", "); i >= 0 && !strings.Contains(spec[:i], "=") {
+			item, spec = spec[:i], spec[i+1:]
+		} else {
+			// 带 = 的表头一直延续到结尾
+			item, spec = spec, ""
+		}
+		if item == "" {
+			continue
+		}
+		key, header, custom := strings.Cut(item, "=")
+		key = strings.ToLower(key)
+		if a, ok := psAliases[key]; ok {
+			key = a[0]
+			if !custom && a[1] != "" {
+				header, custom = a[1], true
+			}
+		}
+		col, ok := psColumns[key]
+		if !ok {
+			return nil, fmt.Errorf("error: unknown user-defined format specifier \"%s\"", item)
+		}
+		if !custom {
+			header = col.header
+		}
+		fields = append(fields, psField{key, header})
+	}
+	return fields, nil
+}
+
+// psFields 由多个单独的格式项组成标准格式 (带 = 的表头会吞掉后面的逗号，需逐项解析)
+func psFields(specs ...string) []psField {
+	var fields []psField
+	for _, s := range specs {
+		f, _ := parsePsFormat(s)
+		fields = append(fields, f...)
+	}
+	return fields
+}
+
+// psSorter 解析 --sort 键，如 -%cpu、+pid
+func psSorter(spec string) func(a, b *Process) bool {
+	var less []func(a, b *Process) int
+	for _, k := range strings.Split(spec, ",") {
+		desc := strings.HasPrefix(k, "-")
+		k = strings.TrimLeft(k, "+-")
+		if a, ok := psAliases[k]; ok {
+			k = a[0]
+		}
+		var cmp func(a, b *Process) int
+		switch k {
+		case "pid":
+			cmp = func(a, b *Process) int { return a.PID - b.PID }
+		case "ppid":
+			cmp = func(a, b *Process) int { return a.PPID - b.PPID }
+		case "%cpu", "c", "time":
+			cmp = func(a, b *Process) int { return int(a.CPUTime() - b.CPUTime()) }
+		case "%mem", "rss":
+			cmp = func(a, b *Process) int { return a.RSS - b.RSS }
+		case "vsz":
+			cmp = func(a, b *Process) int { return a.VSZ - b.VSZ }
+		case "start", "stime", "etime", "lstart":
+			cmp = func(a, b *Process) int { return int(a.Start.Sub(b.Start)) }
+		case "user":
+			cmp = func(a, b *Process) int { return strings.Compare(a.User, b.User) }
+		case "comm", "args":
+			cmp = func(a, b *Process) int { return strings.Compare(a.Comm(), b.Comm()) }
+		default:
+			continue
+		}
+		if desc {
+			c := cmp
+			cmp = func(a, b *Process) int { return -c(a, b) }
+		}
+		less = append(less, cmp)
+	}
+	return func(a, b *Process) bool {
+		for _, c := range less {
+			if r := c(a, b); r != 0 {
+				return r < 0
+			}
+		}
+		return a.PID < b.PID
+	}
+}
+
+func psUsage(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%s\n\nUsage:\n ps [options]\n\n Try 'ps --help <simple|list|output|threads|misc|all>'\n  or 'ps --help <s|l|o|t|m|a>'\n for additional help text.\n\nFor more details see ps(1).\n", msg)
+}
+
+func (t *Terminal) cmdPs(args []string, out io.Writer) {
+	var (
+		all, full, wide, noHeader bool
+		bsdA, bsdX, bsdU, bsd     bool
+		format                    []psField
+		sortSpec                  string
+		pids                      = map[int]bool{}
+		users                     = map[string]bool{}
+		names                     = map[string]bool{}
+	)
+	addList := func(dst func(string), list string) {
+		for _, s := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+			dst(s)
+		}
+	}
+	addPid := func(s string) {
+		if n, err := strconv.Atoi(s); err == nil {
+			pids[n] = true
+		}
+	}
+	addUser := func(s string) {
+		if uid, err := strconv.Atoi(s); err == nil {
+			if name, ok := getNameByID(t.FS.users(), uid); ok {
+				s = name
+			}
+		}
+		users[s] = true
+	}
+
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		// 需要参数的选项：值可以紧跟在选项后，也可以是下一个参数
+		takeValue := func(rest string) string {
+			if rest != "" {
+				return rest
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch {
+		case a == "":
+			// 空参数没有意义，跳过
+		case a == "--no-headers" || a == "--no-heading":
+			noHeader = true
+		case a == "--forest" || a == "-H":
+		case strings.HasPrefix(a, "--sort"):
+			sortSpec = takeValue(strings.TrimPrefix(strings.TrimPrefix(a, "--sort"), "="))
+		case a == "--pid" || a == "--user" || a == "--format":
+			v := takeValue("")
+			switch a {
+			case "--pid":
+				addList(addPid, v)
+			case "--user":
+				addList(addUser, v)
+			default:
+				f, err := parsePsFormat(v)
+				if err != nil {
+					psUsage(out, err.Error())
+					t.lastExitCode = 1
+					return
+				}
+				format = append(format, f...)
+			}
+		case strings.HasPrefix(a, "--"):
+			psUsage(out, "error: unknown gnu long option")
+			t.lastExitCode = 1
+			return
+		case strings.HasPrefix(a, "-") && len(a) > 1 && (a[1] < '0' || a[1] > '9'):
+			opts := a[1:]
+			// 兼容 ps -aux 这种写法，按 BSD 语法处理
+			if strings.ContainsRune(opts, 'u') && strings.ContainsRune(opts, 'x') {
+				bsdA, bsdX, bsdU, bsd = true, true, true, true
+				continue
+			}
+			for j := 0; j < len(opts); j++ {
+				switch opts[j] {
+				case 'e', 'A':
+					all = true
+				case 'f', 'F':
+					full = true
+				case 'a', 'd':
+					bsdA = true
+				case 'w':
+					wide = true
+				case 'l', 'y', 'j', 'H', 'M', 'Z', 'L', 'T', 'N':
+				case 'o', 'p', 'u', 'U', 'C', 'q':
+					v := takeValue(opts[j+1:])
+					switch opts[j] {
+					case 'o':
+						f, err := parsePsFormat(v)
+						if err != nil {
+							psUsage(out, err.Error())
+							t.lastExitCode = 1
+							return
+						}
+						format = append(format, f...)
+					case 'p', 'q':
+						addList(addPid, v)
+					case 'u', 'U':
+						addList(addUser, v)
+					case 'C':
+						addList(func(s string) { names[s] = true }, v)
+					}
+					j = len(opts)
+				default:
+					psUsage(out, "error: unsupported SysV option")
+					t.lastExitCode = 1
+					return
+				}
+			}
+		case a[0] >= '0' && a[0] <= '9' || a[0] == '-':
+			addList(addPid, strings.TrimPrefix(a, "-"))
+		default:
+			bsd = true
+			for j := 0; j < len(a); j++ {
+				switch a[j] {
+				case 'a':
+					bsdA = true
+				case 'x':
+					bsdX = true
+				case 'u':
+					bsdU = true
+				case 'w':
+					wide = true
+				case 'f', 'e', 'r', 'c', 'h', 'S', 'j', 'l', 'v', 'm':
+				case 'o', 'O', 'p', 'U', 't':
+					v := takeValue(a[j+1:])
+					switch a[j] {
+					case 'o', 'O':
+						f, err := parsePsFormat(v)
+						if err != nil {
+							psUsage(out, err.Error())
+							t.lastExitCode = 1
+							return
+						}
+						format = append(format, f...)
+					case 'p':
+						addList(addPid, v)
+					case 'U':
+						addList(addUser, v)
+					}
+					j = len(a)
+				default:
+					psUsage(out, "error: unsupported option (BSD syntax)")
+					t.lastExitCode = 1
+					return
+				}
+			}
+		}
+	}
+
+	switch {
+	case len(format) > 0:
+	case full:
+		format = psFields("user=UID", "pid", "ppid", "c", "stime", "tname", "time", "cmd")
+	case bsdU:
+		format = psFields("user", "pid", "%cpu", "%mem", "vsz", "rss", "tname", "stat", "bsdstart", "bsdtime", "command")
+	case bsd:
+		format = psFields("pid", "tname", "stat", "bsdtime", "command")
+	default:
+		format = psFields("pid", "tname", "time", "comm=CMD")
+	}
+
+	self := t.spawn(args)
+	defer Procs.Remove(self.PID)
+	me := t.userName()
+
+	var rows []*Process
+	for _, p := range Procs.Snapshot() {
+		p := p
+		var match bool
+		switch {
+		case len(pids) > 0 || len(users) > 0 || len(names) > 0:
+			match = pids[p.PID] || users[p.User] || names[p.Comm()]
+		case all || (bsdA && bsdX):
+			match = true
+		case bsdA:
+			match = p.TTY != "?"
+		case bsdX:
+			match = p.User == me
+		default:
+			match = p.TTY == self.TTY && p.TTY != "?" && p.User == me
+		}
+		if match {
+			rows = append(rows, &p)
+		}
+	}
+	if sortSpec != "" {
+		less := psSorter(sortSpec)
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	}
+
+	limit := 0
+	if isTTY(out) && !wide {
+		t.mu.Lock()
+		limit = t.Width
+		t.mu.Unlock()
+	}
+	line := func(cells []string) {
+		var b strings.Builder
+		for i, c := range cells {
+			col := psColumns[format[i].key]
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			switch {
+			case i == len(cells)-1 && col.left:
+				b.WriteString(c)
+			case col.left:
+				fmt.Fprintf(&b, "%-*s", col.width, c)
+			default:
+				fmt.Fprintf(&b, "%*s", col.width, c)
+			}
+		}
+		s := b.String()
+		if limit > 0 && len(s) > limit {
+			s = s[:limit]
+		}
+		fmt.Fprintln(out, s)
+	}
+
+	header := make([]string, len(format))
+	hasHeader := false
+	for i, f := range format {
+		header[i] = f.header
+		hasHeader = hasHeader || f.header != ""
+	}
+	if hasHeader && !noHeader {
+		line(header)
+	}
+	for _, p := range rows {
+		cells := make([]string, len(format))
+		for i, f := range format {
+			cells[i] = psColumns[f.key].value(p)
+		}
+		line(cells)
+	}
+	if len(rows) == 0 {
+		t.lastExitCode = 1
+	}
+}
+
+// ==========================================
+// kill / pgrep / pkill / killall
+// ==========================================
+
+func (t *Terminal) cmdKill(args []string, out io.Writer) {
+	const usage = "kill: 用法：kill [-s 信号声明 | -n 信号编号 | -信号声明] 进程号 | 任务声明 ... 或 kill -l [信号声明]\n"
+	sig := sigTERM
+	i := 1
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(a, "-") || len(a) == 1 {
+			break
+		}
+		switch a {
+		case "-l", "-L", "--list":
+			t.killList(args[i+1:], out)
+			return
+		case "-s", "-n":
+			if i+1 >= len(args) {
+				fmt.Fprintf(t.Stderr, "bash: kill: %s: 选项需要一个参数\n", a)
+				fmt.Fprint(t.Stderr, usage)
+				t.lastExitCode = 2
+				return
+			}
+			i++
+			a = "-" + args[i]
+		}
+		n, ok := parseSignal(a[1:])
+		if !ok {
+			fmt.Fprintf(t.Stderr, "bash: kill: %s: 无效的信号声明\n", a[1:])
+			t.lastExitCode = 1
+			return
+		}
+		sig = n
+	}
+	if i >= len(args) {
+		fmt.Fprint(t.Stderr, usage)
+		t.lastExitCode = 2
+		return
+	}
+
+	user := t.userName()
+	for _, a := range args[i:] {
+		if strings.HasPrefix(a, "%") {
+			job, ok := t.root().findJob(a)
+			if !ok {
+				fmt.Fprintf(t.Stderr, "bash: kill: %s: 无此任务\n", a)
+				t.lastExitCode = 1
+				continue
+			}
+			for _, p := range job.procs {
+				if _, alive := Procs.Get(p.PID); alive {
+					t.sendSignal(p.PID, sig, user, "kill")
+				}
+			}
+			continue
+		}
+		pid, err := strconv.Atoi(a)
+		if err != nil {
+			fmt.Fprintf(t.Stderr, "bash: kill: %s: 参数必须是进程或任务 ID\n", a)
+			t.lastExitCode = 1
+			continue
+		}
+		if pid < 0 {
+			pid = -pid // 进程组按组长处理
+		}
+		if !t.sendSignal(pid, sig, user, "kill") {
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// sendSignal 投递信号并记录日志，失败时按 bash 的格式报错
+func (t *Terminal) sendSignal(pid, sig int, user, tool string) bool {
+	p, err := Procs.Signal(pid, sig, user)
+	if err != nil {
+		if tool == "kill" {
+			fmt.Fprintf(t.Stderr, "bash: kill: (%d) - %s\n", pid, err)
+		} else {
+			fmt.Fprintf(t.Stderr, "%s: 杀死进程 %d 失败：%s\n", tool, pid, err)
+		}
+		return false
+	}
+	log.Printf("[Process] %s: %s sent SIG%s to %d (%s)", t.Remote, user, signalName(sig), pid, p.Cmdline())
+	return true
+}
+
+func (t *Terminal) killList(args []string, out io.Writer) {
+	if len(args) == 0 {
+		var b strings.Builder
+		col := 0
+		for i := 1; i <= 64; i++ {
+			if i == 32 || i == 33 {
+				continue
+			}
+			fmt.Fprintf(&b, "%2d) SIG%s", i, signalName(i))
+			col++
+			if col%5 == 0 {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\t")
+			}
+		}
+		fmt.Fprintln(out, strings.TrimRight(b.String(), "\t\n"))
+		return
+	}
+	for _, a := range args {
+		if n, err := strconv.Atoi(a); err == nil {
+			// kill -l 137 这种退出码形式
+			if n > 128 {
+				n -= 128
+			}
+			fmt.Fprintln(out, signalName(n))
+		} else if n, ok := parseSignal(a); ok {
+			fmt.Fprintln(out, n)
+		} else {
+			fmt.Fprintf(t.Stderr, "bash: kill: %s: 无效的信号声明\n", a)
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// cmdPgrep 实现 pgrep、pkill 和 killall
+func (t *Terminal) cmdPgrep(args []string, out io.Writer) {
+	tool := args[0]
+	var (
+		full, exact, listName, listFull, count, newest, oldest bool
+		delim                                                  = "\n"
+		sig                                                    = sigTERM
+		users                                                  = map[string]bool{}
+		patterns                                               []string
+	)
+	if tool == "killall" {
+		exact = true
+	}
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") || len(a) == 1 {
+			patterns = append(patterns, a)
+			continue
+		}
+		if tool != "pgrep" {
+			if n, ok := parseSignal(a[1:]); ok {
+				sig = n
+				continue
+			}
+		}
+		switch a {
+		case "--signal", "-s":
+			if i+1 < len(args) {
+				i++
+				if n, ok := parseSignal(args[i]); ok {
+					sig = n
+				}
+			}
+			continue
+		case "--full":
+			full = true
+			continue
+		case "--exact":
+			exact = true
+			continue
+		}
+		for j := 1; j < len(a); j++ {
+			switch a[j] {
+			case 'f':
+				full = true
+			case 'x', 'e':
+				exact = true
+			case 'l':
+				listName = true
+			case 'a':
+				listFull = true
+			case 'c':
+				count = true
+			case 'n':
+				newest = true
+			case 'o':
+				oldest = true
+			case 'i', 'q', 'v', 'w', 'I':
+			case 'u', 'U', 'd':
+				v := a[j+1:]
+				if v == "" && i+1 < len(args) {
+					i++
+					v = args[i]
+				}
+				if a[j] == 'd' {
+					delim = v
+				} else {
+					for _, u := range strings.Split(v, ",") {
+						users[u] = true
+					}
+				}
+				j = len(a)
+			default:
+				fmt.Fprintf(out, "%s: invalid option -- '%c'\n", tool, a[j])
+				t.lastExitCode = 2
+				return
+			}
+		}
+	}
+	if len(patterns) == 0 && len(users) == 0 {
+		if tool == "killall" {
+			fmt.Fprintln(out, "用法：killall [ -Z CONTEXT ] [ -u USER ] [ -y TIME ] [ -o TIME ] [ -eIgiqrvw ]\n               [ -s SIGNAL | -SIGNAL ] NAME...")
+		} else {
+			fmt.Fprintf(out, "%s: no matching criteria specified\nTry `%s --help' for more information.\n", tool, tool)
+		}
+		t.lastExitCode = 2
+		return
+	}
+
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		if tool == "killall" {
+			p = regexp.QuoteMeta(p)
+		}
+		if exact {
+			p = "^(?:" + p + ")$"
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			fmt.Fprintf(out, "%s: 无法编译正则表达式\n", tool)
+			t.lastExitCode = 2
+			return
+		}
+		res = append(res, re)
+	}
+
+	var matched []Process
+	for _, p := range Procs.Snapshot() {
+		if p.PID == t.pid && tool != "pgrep" {
+			continue // 不会杀死调用者自己的 shell
+		}
+		if len(users) > 0 && !users[p.User] {
+			continue
+		}
+		target := p.Comm()
+		if full {
+			target = p.Cmdline()
+		}
+		ok := len(res) == 0
+		for _, re := range res {
+			if re.MatchString(target) {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, p)
+		}
+	}
+	if (newest || oldest) && len(matched) > 1 {
+		pick := matched[0]
+		for _, p := range matched[1:] {
+			if newest && !p.Start.Before(pick.Start) || oldest && p.Start.Before(pick.Start) {
+				pick = p
+			}
+		}
+		matched = []Process{pick}
+	}
+
+	if len(matched) == 0 {
+		if tool == "killall" {
+			for _, p := range patterns {
+				fmt.Fprintf(t.Stderr, "%s: 未找到进程\n", p)
+			}
+		}
+		t.lastExitCode = 1
+		return
+	}
+
+	if tool == "pgrep" {
+		if count {
+			fmt.Fprintln(out, len(matched))
+			return
+		}
+		items := make([]string, len(matched))
+		for i, p := range matched {
+			switch {
+			case listFull:
+				items[i] = fmt.Sprintf("%d %s", p.PID, p.Cmdline())
+			case listName:
+				items[i] = fmt.Sprintf("%d %s", p.PID, p.Comm())
+			default:
+				items[i] = strconv.Itoa(p.PID)
+			}
+		}
+		fmt.Fprintln(out, strings.Join(items, delim))
+		return
+	}
+
+	user := t.userName()
+	killed := 0
+	for _, p := range matched {
+		if t.sendSignal(p.PID, sig, user, tool) {
+			killed++
+		}
+	}
+	if count {
+		fmt.Fprintln(out, killed)
+	}
+	if killed == 0 {
+		t.lastExitCode = 1
+	}
+}
+
+// ==========================================
+// top
+// ==========================================
+
+// upString 按 top/uptime 的格式输出运行时长
+func upString(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	h, m := int(d.Hours())%24, int(d.Minutes())%60
+	var s string
+	if days > 0 {
+		s = fmt.Sprintf("%d day", days)
+		if days > 1 {
+			s += "s"
+		}
+		s += ", "
+	}
+	if h > 0 {
+		return s + fmt.Sprintf("%2d:%02d", h, m)
+	}
+	return s + fmt.Sprintf("%d min", m)
+}
+
+// prettyUp 按 uptime -p 的格式输出运行时长
+func prettyUp(d time.Duration) string {
+	var parts []string
+	for _, u := range []struct {
+		n    int
+		name string
+	}{
+		{int(d.Hours()) / 24 / 7, "week"}, {int(d.Hours()) / 24 % 7, "day"},
+		{int(d.Hours()) % 24, "hour"}, {int(d.Minutes()) % 60, "minute"},
+	} {
+		if u.n == 0 {
+			continue
+		}
+		s := fmt.Sprintf("%d %s", u.n, u.name)
+		if u.n > 1 {
+			s += "s"
+		}
+		parts = append(parts, s)
+	}
+	if len(parts) == 0 {
+		return "up 0 minutes"
+	}
+	return "up " + strings.Join(parts, ", ")
+}
+
+// uptimeSummary 返回 uptime 与 top 首行共用的部分：当前时间、运行时长、会话数和平均负载
+func uptimeSummary() string {
+	sessions := 0
+	for _, p := range Procs.Snapshot() {
+		if p.shell {
+			sessions++
+		}
+	}
+	users := "users"
+	if sessions == 1 {
+		users = "user"
+	}
+	l1, l5, l15 := loadAverage()
+	return fmt.Sprintf("%s up %s,  %d %s,  load average: %.2f, %.2f, %.2f",
+		time.Now().Format("15:04:05"), upString(time.Since(startTime)), sessions, users, l1, l5, l15)
+}
+
+func (t *Terminal) cmdTop(args []string, out io.Writer) {
+	batch := !isTTY(out)
+	iterations := 0
+	delay := 3 * time.Second
+	var user string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		for j := 1; j < len(a); j++ {
+			switch a[j] {
+			case 'b':
+				batch = true
+			case 'c', 'i', 'H', 'S':
+			case 'n', 'd', 'u', 'U', 'p', 'o', 'w':
+				v := a[j+1:]
+				if v == "" && i+1 < len(args) {
+					i++
+					v = args[i]
+				}
+				switch a[j] {
+				case 'n':
+					iterations, _ = strconv.Atoi(v)
+				case 'd':
+					if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+						delay = time.Duration(f * float64(time.Second))
+					}
+				case 'u', 'U':
+					user = v
+				}
+				j = len(a)
+			default:
+				fmt.Fprintf(out, "top: 无效选项 '%c'\n用法：\n  top -hv | -bcEeHiOSs1 -d secs -n max -u|U user -p pid(s) -o field -w [cols]\n", a[j])
+				t.lastExitCode = 1
+				return
+			}
+		}
+	}
+	// 限制刷新频率，防止被用来刷流量
+	if delay < time.Second {
+		delay = time.Second
+	}
+
+	self := t.spawn(args)
+	defer Procs.Remove(self.PID)
+
+	if batch {
+		if iterations <= 0 {
+			iterations = 1
+		}
+		if iterations > 5 {
+			iterations = 5
+		}
+		for n := 0; n < iterations; n++ {
+			if n > 0 {
+				time.Sleep(time.Second)
+				fmt.Fprintln(out)
+			}
+			t.renderTop(out, self.PID, user, 0, false)
+		}
+		return
+	}
+
+	// 原始输入由登录 shell 的 inputLoop 转发
+	rs := t.enterRaw()
+	defer rs.close()
+
+	fmt.Fprint(out, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+
+	tick := time.NewTicker(delay)
+	defer tick.Stop()
+	for n := 0; iterations <= 0 || n < iterations; n++ {
+		_, h := rs.size()
+		if err := t.renderTop(out, self.PID, user, h, true); err != nil {
+			return // 连接已断开
+		}
+		select {
+		case k := <-rs.keys:
+			if k.code == keyRune && (k.r == 'q' || k.r == 3) {
+				return
+			}
+		case <-tick.C:
+		}
+	}
+}
+
+// renderTop 输出一帧 top 画面，rows 大于 0 时只显示能放进屏幕的进程
+func (t *Terminal) renderTop(out io.Writer, self int, user string, rows int, clear bool) error {
+	procs := Procs.Snapshot()
+	var running, sleeping, stopped, zombie int
+	for _, p := range procs {
+		switch p.Stat[0] {
+		case 'R':
+			running++
+		case 'T':
+			stopped++
+		case 'Z':
+			zombie++
+		default:
+			sleeping++
+		}
+	}
+
+	// top 按本次刷新的 CPU 占用排序，给 top 自己和少量进程一点抖动
+	load := make(map[int]float64, len(procs))
+	for _, p := range procs {
+		switch {
+		case p.PID == self:
+			load[p.PID] = 0.3 + rand.Float64()*0.4
+		case !p.Kernel && p.Load > 0 && rand.Intn(12) == 0:
+			load[p.PID] = 0.3 + rand.Float64()
+		}
+	}
+	sort.SliceStable(procs, func(i, j int) bool {
+		if load[procs[i].PID] != load[procs[j].PID] {
+			return load[procs[i].PID] > load[procs[j].PID]
+		}
+		return procs[i].PID < procs[j].PID
+	})
+	var us float64
+	for _, l := range load {
+		us += l / 2
+	}
+
+	var b strings.Builder
+	if clear {
+		b.WriteString("\033[H\033[2J")
+	}
+	b.WriteString("top - " + uptimeSummary() + "\n")
+	fmt.Fprintf(&b, "Tasks: %3d total, %3d running, %3d sleeping, %3d stopped, %3d zombie\n",
+		len(procs), running, sleeping, stopped, zombie)
+	fmt.Fprintf(&b, "%%Cpu(s): %4.1f us, %4.1f sy,  0.0 ni, %4.1f id,  0.0 wa,  0.0 hi,  0.0 si,  0.0 st\n", us, us/3, 100-us-us/3)
+	m := memStats(t.FS)
+	mib := func(kib int) float64 { return float64(kib) / 1024 }
+	fmt.Fprintf(&b, "MiB Mem :%9.1f total,%9.1f free,%9.1f used,%9.1f buff/cache\n", mib(m.Total), mib(m.Free), mib(m.Used()), mib(m.BuffCache()))
+	fmt.Fprintf(&b, "MiB Swap:%9.1f total,%9.1f free,%9.1f used.%9.1f avail Mem \n\n", mib(m.SwapTotal), mib(m.SwapFree), mib(m.SwapTotal-m.SwapFree), mib(m.Available))
+	b.WriteString("\033[7m    PID USER      PR  NI    VIRT    RES    SHR S  %CPU  %MEM     TIME+ COMMAND          \033[0m\n")
+
+	shown := 0
+	for _, p := range procs {
+		if user != "" && p.User != user {
+			continue
+		}
+		if rows > 0 && shown >= rows-8 {
+			break
+		}
+		pr, ni := "20", 0
+		switch {
+		case strings.Contains(p.Stat, "<"):
+			pr, ni = "0", -20
+		case strings.Contains(p.Stat, "N"):
+			pr, ni = "25", 5
+		case strings.HasPrefix(p.Comm(), "migration/"):
+			pr = "rt"
+		}
+		cs := p.CPUTime().Milliseconds() / 10
+		fmt.Fprintf(&b, "%7d %-8s  %2s %3d %7d %6d %6d %c %5.1f %5.1f %9s %s\n",
+			p.PID, psUser(p.User), pr, ni, p.VSZ, p.RSS, p.RSS*2/3, p.Stat[0],
+			load[p.PID], p.PMem(), fmt.Sprintf("%d:%02d.%02d", cs/6000, cs/100%60, cs%100), p.Comm())
+		shown++
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
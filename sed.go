@@ -0,0 +1,1169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ==========================================
+// sed 流编辑器 (GNU sed 常用子集)
+// ==========================================
+
+// 单次执行的资源上限，防止 :a;ba、:a;s/x/xx/;ta 之类的脚本长时间占满 CPU 和内存
+const (
+	sedMaxSteps = 5000000         // 最多运行的命令数
+	sedMaxTime  = 5 * time.Second // 最长运行时间
+	sedMaxSpace = MaxFileSize     // 模式空间和保持空间的最大长度
+)
+
+// sedAddr 行地址：行号、first~step、$ 或 /正则/
+type sedAddr struct {
+	line, step int
+	last       bool
+	isRe       bool
+	re         *regexp.Regexp // isRe 且为 nil 时复用上一个正则
+}
+
+type sedCmd struct {
+	a1, a2  *sedAddr
+	neg     bool
+	name    byte
+	inRange bool
+
+	re     *regexp.Regexp // s 命令的正则，nil 表示复用上一个
+	rep    string
+	global bool
+	nth    int
+	print  bool
+	text   string // a/i/c 的文本、b/t/T/: 的标签、r/w 以及 s///w 的文件名
+	target int    // b/t/T 的跳转位置，'{' 对应的 '}' 的位置
+	ymap   map[rune]rune
+	code   int
+}
+
+// sedRegexp 把 POSIX 基本/扩展正则转换为 Go 正则；. 可以匹配模式空间里的换行
+func sedRegexp(pat string, ere, icase bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if icase {
+		b.WriteString("(?i)")
+	}
+	start := true // 是否处于可以出现字面 * 的位置
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		atStart := start
+		start = false
+		switch {
+		case c == '[':
+			// 方括号表达式原样复制，其中的反斜杠是普通字符
+			j := i + 1
+			if j < len(pat) && pat[j] == '^' {
+				j++
+			}
+			if j < len(pat) && pat[j] == ']' {
+				j++
+			}
+			for j < len(pat) && pat[j] != ']' {
+				if pat[j] == '[' && j+1 < len(pat) && (pat[j+1] == ':' || pat[j+1] == '.' || pat[j+1] == '=') {
+					if k := strings.Index(pat[j+2:], string(pat[j+1])+"]"); k >= 0 {
+						j += k + 4
+						continue
+					}
+				}
+				j++
+			}
+			if j >= len(pat) {
+				return nil, fmt.Errorf("未匹配的 [、[^、[:、[. 或 [=")
+			}
+			b.WriteString(strings.ReplaceAll(pat[i:j+1], `\`, `\\`))
+			i = j
+		case c == '\\' && i+1 < len(pat):
+			i++
+			n := pat[i]
+			switch {
+			case !ere && strings.IndexByte("(){}+?|", n) >= 0:
+				b.WriteByte(n)
+				start = n == '(' || n == '|'
+			case n >= '1' && n <= '9':
+				return nil, fmt.Errorf("无效的向后引用")
+			case n == 'n':
+				b.WriteString(`\n`)
+			case n == 't':
+				b.WriteString(`\t`)
+			case n == '<' || n == '>':
+				b.WriteString(`\b`)
+			case n == '`':
+				b.WriteString(`\A`)
+			case n == '\'':
+				b.WriteString(`\z`)
+			case strings.IndexByte("wWsSbB", n) >= 0:
+				b.WriteByte('\\')
+				b.WriteByte(n)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(n)))
+			}
+		case !ere && strings.IndexByte("(){}+?|", c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '*' && atStart:
+			b.WriteString(`\*`)
+		case c == '^':
+			b.WriteByte(c)
+			start = true
+		case ere && (c == '(' || c == '|'):
+			b.WriteByte(c)
+			start = true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		var se *syntax.Error
+		msg := "无效的正则表达式"
+		if errors.As(err, &se) {
+			switch se.Code {
+			case syntax.ErrMissingParen, syntax.ErrUnexpectedParen:
+				msg = "未匹配的 ( 或 \\("
+			case syntax.ErrMissingRepeatArgument, syntax.ErrInvalidRepeatOp:
+				msg = "无效的前置正则表达式"
+			case syntax.ErrInvalidRepeatSize:
+				msg = "无效的 \\{\\} 内容"
+			case syntax.ErrMissingBracket:
+				msg = "未匹配的 [、[^、[:、[. 或 [="
+			}
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+	return re, nil
+}
+
+// sedParser 把脚本解析为命令序列
+type sedParser struct {
+	s    string
+	pos  int
+	ere  bool
+	cmds []*sedCmd
+}
+
+func (p *sedParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("-e 表达式 #1, 字符 %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *sedParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *sedParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sedParser) number() int {
+	n := 0
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		n = n*10 + int(p.s[p.pos]-'0')
+		p.pos++
+	}
+	return n
+}
+
+// delimited 读取到未转义的 delim 为止；\delim 变成 delim，其余转义保留给后续处理
+func (p *sedParser) delimited(delim byte) (string, bool) {
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == delim:
+			return b.String(), true
+		case c == '\\' && p.pos < len(p.s):
+			n := p.s[p.pos]
+			p.pos++
+			switch {
+			case n == delim:
+				b.WriteByte(n)
+			case n == '\n':
+				b.WriteString(`\n`)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(n)
+			}
+		case c == '\n' && delim != '\n':
+			return "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+// regex 解析 /re/ 后面的 I、M 标志并编译；空正则表示复用上一个
+func (p *sedParser) regex(pat string, icase bool) (*regexp.Regexp, error) {
+	if pat == "" {
+		return nil, nil
+	}
+	re, err := sedRegexp(pat, p.ere, icase)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return re, nil
+}
+
+func (p *sedParser) addr() (*sedAddr, error) {
+	c := p.peek()
+	switch {
+	case c >= '0' && c <= '9':
+		a := &sedAddr{line: p.number()}
+		if p.peek() == '~' {
+			p.pos++
+			a.step = p.number()
+		}
+		return a, nil
+	case c == '$':
+		p.pos++
+		return &sedAddr{last: true}, nil
+	case c == '/' || c == '\\':
+		p.pos++
+		delim := byte('/')
+		if c == '\\' {
+			delim = p.peek()
+			p.pos++
+		}
+		pat, ok := p.delimited(delim)
+		if !ok {
+			return nil, p.errorf("未终止的地址正则表达式")
+		}
+		icase := false
+		for p.peek() == 'I' || p.peek() == 'M' {
+			icase = icase || p.peek() == 'I'
+			p.pos++
+		}
+		re, err := p.regex(pat, icase)
+		if err != nil {
+			return nil, err
+		}
+		return &sedAddr{isRe: true, re: re}, nil
+	}
+	return nil, nil
+}
+
+// label 读取标签名，到 ; 或换行为止
+func (p *sedParser) label() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ';' && p.s[p.pos] != '\n' {
+		p.pos++
+	}
+	return strings.TrimRight(p.s[start:p.pos], " \t")
+}
+
+// restOfLine 读取到行尾 (r、w 的文件名)
+func (p *sedParser) restOfLine() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// text 读取 a/i/c 的文本：支持 "a\<换行>文本" 和 GNU 的单行 "a 文本"
+func (p *sedParser) text() (string, error) {
+	p.skipSpace()
+	if p.peek() == '\\' {
+		p.pos++
+		p.skipSpace()
+		if p.peek() == '\n' {
+			p.pos++
+		}
+	}
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		if c == '\n' {
+			break
+		}
+		if c == '\\' && p.pos < len(p.s) {
+			c = p.s[p.pos]
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+	if b.Len() == 0 {
+		return "", p.errorf("期望在 a, c, i 之后出现 \\")
+	}
+	return b.String(), nil
+}
+
+func parseSed(script string, ere bool) ([]*sedCmd, error) {
+	p := &sedParser{s: script, ere: ere}
+	var blocks []int
+	for {
+		for p.pos < len(p.s) && strings.IndexByte(" \t\n;", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			break
+		}
+		if p.peek() == '#' {
+			p.restOfLine()
+			continue
+		}
+		c := &sedCmd{nth: 1}
+		var err error
+		if c.a1, err = p.addr(); err != nil {
+			return nil, err
+		}
+		if c.a1 != nil && p.peek() == ',' {
+			p.pos++
+			p.skipSpace()
+			if c.a2, err = p.addr(); err != nil {
+				return nil, err
+			}
+			if c.a2 == nil {
+				return nil, p.errorf("意外的“,”")
+			}
+		}
+		if c.a1 != nil && c.a1.line == 0 && c.a1.step == 0 && !c.a1.last && !c.a1.isRe &&
+			(c.a2 == nil || !c.a2.isRe) {
+			return nil, p.errorf("无效的行地址 0")
+		}
+		p.skipSpace()
+		if p.peek() == '!' {
+			c.neg = true
+			p.pos++
+			p.skipSpace()
+		}
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("缺少命令")
+		}
+		c.name = p.s[p.pos]
+		p.pos++
+		switch c.name {
+		case '{':
+			blocks = append(blocks, len(p.cmds))
+		case '}':
+			if len(blocks) == 0 {
+				return nil, p.errorf("意外的“}”")
+			}
+			if c.a1 != nil {
+				return nil, p.errorf("} 不接受任何地址")
+			}
+			p.cmds[blocks[len(blocks)-1]].target = len(p.cmds)
+			blocks = blocks[:len(blocks)-1]
+		case '=', 'd', 'D', 'g', 'G', 'h', 'H', 'l', 'n', 'N', 'p', 'P', 'x', 'z', 'F':
+		case 'q', 'Q':
+			if c.a2 != nil {
+				return nil, p.errorf("命令只接受一个地址")
+			}
+			p.skipSpace()
+			c.code = p.number()
+		case 'a', 'i', 'c':
+			if c.text, err = p.text(); err != nil {
+				return nil, err
+			}
+		case ':':
+			if c.a1 != nil {
+				return nil, p.errorf(": 不接受任何地址")
+			}
+			if c.text = p.label(); c.text == "" {
+				return nil, p.errorf("\":\" 缺少标签")
+			}
+		case 'b', 't', 'T':
+			c.text = p.label()
+		case 'r', 'R', 'w', 'W':
+			if c.text = p.restOfLine(); c.text == "" {
+				return nil, p.errorf("缺少文件名")
+			}
+		case 's':
+			if err := p.subst(c); err != nil {
+				return nil, err
+			}
+		case 'y':
+			if err := p.translit(c); err != nil {
+				return nil, err
+			}
+		default:
+			p.pos--
+			return nil, p.errorf("未知的命令：“%c”", c.name)
+		}
+		p.cmds = append(p.cmds, c)
+		// 命令之后只允许空白、分号、换行、} 或注释
+		p.skipSpace()
+		if p.pos < len(p.s) && strings.IndexByte(";\n}#", p.s[p.pos]) < 0 {
+			if c.name == 's' {
+				return nil, p.errorf("未知的“s”选项")
+			}
+			return nil, p.errorf("命令后含有多余的字符")
+		}
+	}
+	if len(blocks) > 0 {
+		p.pos = 0
+		return nil, p.errorf("未匹配的“{”")
+	}
+	// 解析跳转目标
+	for _, c := range p.cmds {
+		if c.name != 'b' && c.name != 't' && c.name != 'T' {
+			continue
+		}
+		c.target = len(p.cmds)
+		if c.text == "" {
+			continue
+		}
+		found := false
+		for j, d := range p.cmds {
+			if d.name == ':' && d.text == c.text {
+				c.target, found = j, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("无法找到标签用于跳转至“%s”", c.text)
+		}
+	}
+	return p.cmds, nil
+}
+
+func (p *sedParser) subst(c *sedCmd) error {
+	delim := p.peek()
+	if delim == 0 || delim == '\n' || delim == '\\' {
+		return p.errorf("未终止的“s”命令")
+	}
+	p.pos++
+	pat, ok := p.delimited(delim)
+	if !ok {
+		return p.errorf("未终止的“s”命令")
+	}
+	if c.rep, ok = p.delimited(delim); !ok {
+		return p.errorf("未终止的“s”命令")
+	}
+	icase := false
+flags:
+	for p.pos < len(p.s) {
+		switch f := p.s[p.pos]; {
+		case f == 'g':
+			c.global = true
+		case f == 'p':
+			c.print = true
+		case f == 'i' || f == 'I':
+			icase = true
+		case f == 'm' || f == 'M' || f == 'e':
+		case f >= '0' && f <= '9':
+			if c.nth = p.number(); c.nth == 0 {
+				return p.errorf("“s”命令的数字选项不能为零")
+			}
+			continue
+		case f == 'w':
+			p.pos++
+			if c.text = p.restOfLine(); c.text == "" {
+				return p.errorf("缺少文件名")
+			}
+			break flags
+		default:
+			break flags
+		}
+		p.pos++
+	}
+	var err error
+	if c.re, err = p.regex(pat, icase); err != nil {
+		return err
+	}
+	// 检查替换部分引用的分组是否存在
+	if c.re != nil {
+		for i := 0; i+1 < len(c.rep); i++ {
+			if c.rep[i] == '\\' {
+				if n := c.rep[i+1]; n >= '1' && n <= '9' && int(n-'0') > c.re.NumSubexp() {
+					return p.errorf("“s”命令的 RHS 中有无效引用 \\%c", n)
+				}
+				i++
+			}
+		}
+	}
+	return nil
+}
+
+func (p *sedParser) translit(c *sedCmd) error {
+	delim := p.peek()
+	if delim == 0 || delim == '\n' || delim == '\\' {
+		return p.errorf("未终止的“y”命令")
+	}
+	p.pos++
+	src, ok1 := p.delimited(delim)
+	dst, ok2 := p.delimited(delim)
+	if !ok1 || !ok2 {
+		return p.errorf("未终止的“y”命令")
+	}
+	unescape := func(s string) []rune {
+		s = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s)
+		return []rune(s)
+	}
+	a, b := unescape(src), unescape(dst)
+	if len(a) != len(b) {
+		return p.errorf("“y”命令的字符串长度不同")
+	}
+	c.ymap = map[rune]rune{}
+	for i, r := range a {
+		c.ymap[r] = b[i]
+	}
+	return nil
+}
+
+// sedExpand 展开替换文本中的 &、\1..\9、\n 以及 GNU 的 \U \L \u \l \E
+func sedExpand(rep, src string, m []int) string {
+	var b strings.Builder
+	caseMode, oneShot := byte(0), byte(0)
+	write := func(s string) {
+		for _, r := range s {
+			switch {
+			case oneShot == 'u':
+				r = unicode.ToUpper(r)
+			case oneShot == 'l':
+				r = unicode.ToLower(r)
+			case caseMode == 'U':
+				r = unicode.ToUpper(r)
+			case caseMode == 'L':
+				r = unicode.ToLower(r)
+			}
+			oneShot = 0
+			b.WriteRune(r)
+		}
+	}
+	group := func(n int) string {
+		if 2*n+1 < len(m) && m[2*n] >= 0 {
+			return src[m[2*n]:m[2*n+1]]
+		}
+		return ""
+	}
+	for i := 0; i < len(rep); i++ {
+		c := rep[i]
+		switch {
+		case c == '&':
+			write(group(0))
+		case c == '\\' && i+1 < len(rep):
+			i++
+			switch n := rep[i]; {
+			case n >= '0' && n <= '9':
+				write(group(int(n - '0')))
+			case n == 'n':
+				write("\n")
+			case n == 't':
+				write("\t")
+			case n == 'U' || n == 'L':
+				caseMode = n
+			case n == 'E':
+				caseMode = 0
+			case n == 'u' || n == 'l':
+				oneShot = n
+			default:
+				write(string(n))
+			}
+		default:
+			j := i + 1
+			for j < len(rep) && rep[j] != '&' && rep[j] != '\\' {
+				j++
+			}
+			write(rep[i:j])
+			i = j - 1
+		}
+	}
+	return b.String()
+}
+
+// sedInput 把多个输入文件连成一个行流，并预读一行以判断 $
+type sedInput struct {
+	t     *Terminal
+	names []string
+	in    io.Reader
+	cur   *lineReader
+	name  string
+
+	peeked     bool
+	pline      string
+	pnl, pok   bool
+	failed     bool
+	lastNL     bool // 最近读出的一行是否以换行结尾
+	fileOfPeek string
+}
+
+func (s *sedInput) read() (string, bool) {
+	if s.peeked {
+		s.peeked = false
+		s.lastNL, s.name = s.pnl, s.fileOfPeek
+		return s.pline, s.pok
+	}
+	for {
+		if s.cur == nil {
+			if len(s.names) == 0 {
+				return "", false
+			}
+			name := s.names[0]
+			s.names = s.names[1:]
+			r, reason := s.t.openInput(name, s.in)
+			if reason == "是一个目录" {
+				fmt.Fprintf(s.t.Stderr, "sed: 读取 %s 时出错：%s\n", name, reason)
+				s.failed = true
+				continue
+			} else if reason != "" {
+				fmt.Fprintf(s.t.Stderr, "sed: 无法读取 %s：%s\n", name, reason)
+				s.failed = true
+				continue
+			}
+			s.cur, s.name = newLineReader(r), name
+		}
+		if line, ok := s.cur.next(); ok {
+			s.lastNL = s.cur.nl
+			return line, true
+		}
+		s.cur = nil
+	}
+}
+
+func (s *sedInput) isLast() bool {
+	if !s.peeked {
+		name, nl := s.name, s.lastNL
+		s.pline, s.pok = s.read()
+		s.pnl, s.fileOfPeek = s.lastNL, s.name
+		s.name, s.lastNL = name, nl
+		s.peeked = true
+	}
+	return !s.pok
+}
+
+// sedRun 一次 sed 执行的状态
+type sedRun struct {
+	t       *Terminal
+	cmds    []*sedCmd
+	quiet   bool
+	out     io.Writer
+	input   *sedInput
+	lineNo  int
+	ps, hs  string
+	appendQ []string
+	tflag   bool
+	lastRe  *regexp.Regexp
+	wfiles  map[string]*bytes.Buffer
+	worder  []string
+	quit    bool
+	code    int
+	steps   int
+	limit   time.Time // 运行截止时间
+	errMsg  string
+}
+
+func (r *sedRun) regex(re *regexp.Regexp) *regexp.Regexp {
+	if re != nil {
+		r.lastRe = re
+		return re
+	}
+	if r.lastRe == nil {
+		r.fail("没有上一个正则表达式")
+	}
+	return r.lastRe
+}
+
+func (r *sedRun) fail(msg string) {
+	if r.errMsg == "" {
+		r.errMsg = msg
+	}
+	r.quit = true
+}
+
+func (r *sedRun) match1(a *sedAddr) bool {
+	switch {
+	case a.last:
+		return r.input.isLast()
+	case a.isRe:
+		re := r.regex(a.re)
+		return re != nil && re.MatchString(r.ps)
+	case a.step > 0:
+		return r.lineNo >= a.line && (r.lineNo-a.line)%a.step == 0
+	}
+	return r.lineNo == a.line
+}
+
+func (r *sedRun) matches(c *sedCmd) bool {
+	if c.a1 == nil {
+		return true
+	}
+	var m bool
+	switch {
+	case c.a2 == nil:
+		m = r.match1(c.a1)
+	case c.inRange:
+		m = true
+		if c.a2.isRe || c.a2.last {
+			c.inRange = !r.match1(c.a2)
+		} else if r.lineNo >= c.a2.line {
+			c.inRange = false
+		}
+	case c.a1.line == 0 && c.a1.step == 0 && !c.a1.last && !c.a1.isRe && r.lineNo == 1:
+		// 0,/re/：正则可以在第一行就结束范围
+		m = true
+		c.inRange = !r.match1(c.a2)
+	case r.match1(c.a1):
+		m = true
+		switch {
+		case c.a2.isRe:
+			c.inRange = true
+		case c.a2.last:
+			c.inRange = !r.input.isLast()
+		default:
+			c.inRange = c.a2.line > r.lineNo
+		}
+	}
+	return m != c.neg
+}
+
+func (r *sedRun) write(s string) {
+	io.WriteString(r.out, s)
+}
+
+func (r *sedRun) flushAppend() {
+	for _, s := range r.appendQ {
+		r.write(s)
+	}
+	r.appendQ = r.appendQ[:0]
+}
+
+func (r *sedRun) writeFile(name, s string) {
+	if name == "/dev/stdout" {
+		r.write(s)
+		return
+	}
+	b, ok := r.wfiles[name]
+	if !ok {
+		b = &bytes.Buffer{}
+		r.wfiles[name] = b
+		r.worder = append(r.worder, name)
+	}
+	b.WriteString(s)
+}
+
+// escapeSedL 按 l 命令的格式显示不可打印字符
+func escapeSedL(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\a':
+			b.WriteString(`\a`)
+		case c == '\b':
+			b.WriteString(`\b`)
+		case c == '\f':
+			b.WriteString(`\f`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\v':
+			b.WriteString(`\v`)
+		case c < 32 || c >= 127:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String() + "$\n"
+}
+
+func (r *sedRun) subst(c *sedCmd) {
+	re := r.regex(c.re)
+	if re == nil {
+		return
+	}
+	var b strings.Builder
+	last, n, done := 0, 0, false
+	for _, m := range re.FindAllStringSubmatchIndex(r.ps, -1) {
+		n++
+		if n < c.nth || !c.global && n > c.nth {
+			continue
+		}
+		b.WriteString(r.ps[last:m[0]])
+		b.WriteString(sedExpand(c.rep, r.ps, m))
+		last, done = m[1], true
+	}
+	if !done {
+		return
+	}
+	b.WriteString(r.ps[last:])
+	r.ps, r.tflag = b.String(), true
+	if c.print {
+		r.write(r.ps + "\n")
+	}
+	if c.text != "" {
+		r.writeFile(c.text, r.ps+"\n")
+	}
+}
+
+// cycle 对模式空间执行一遍脚本；restart 为真表示 D 命令要求不读入新行重新开始
+func (r *sedRun) cycle() (restart bool) {
+	deleted := false
+	pc := 0
+loop:
+	for pc < len(r.cmds) && !r.quit {
+		c := r.cmds[pc]
+		pc++
+		if r.steps++; r.steps > sedMaxSteps || r.steps%1024 == 0 && time.Now().After(r.limit) {
+			r.fail("超出执行限制")
+			return false
+		}
+		if !r.matches(c) {
+			if c.name == '{' {
+				pc = c.target + 1
+			}
+			continue
+		}
+		switch c.name {
+		case '=':
+			r.write(strconv.Itoa(r.lineNo) + "\n")
+		case 'a':
+			r.appendQ = append(r.appendQ, c.text+"\n")
+		case 'i':
+			r.write(c.text + "\n")
+		case 'c':
+			if c.a2 == nil || !c.inRange || c.neg {
+				r.write(c.text + "\n")
+			}
+			deleted = true
+			break loop
+		case 'd':
+			deleted = true
+			break loop
+		case 'D':
+			i := strings.IndexByte(r.ps, '\n')
+			if i < 0 {
+				deleted = true
+				break loop
+			}
+			r.ps = r.ps[i+1:]
+			r.flushAppend()
+			return true
+		case 'g':
+			r.ps = r.hs
+		case 'G':
+			r.ps += "\n" + r.hs
+		case 'h':
+			r.hs = r.ps
+		case 'H':
+			r.hs += "\n" + r.ps
+		case 'x':
+			r.ps, r.hs = r.hs, r.ps
+		case 'l':
+			r.write(escapeSedL(r.ps))
+		case 'n', 'N':
+			if r.input.isLast() {
+				r.quit = true
+				break loop
+			}
+			if c.name == 'n' && !r.quiet {
+				r.write(r.ps + "\n")
+			}
+			r.flushAppend()
+			line, _ := r.input.read()
+			r.lineNo++
+			if c.name == 'n' {
+				r.ps = line
+			} else {
+				r.ps += "\n" + line
+			}
+		case 'p':
+			r.write(r.ps + "\n")
+		case 'P':
+			if i := strings.IndexByte(r.ps, '\n'); i >= 0 {
+				r.write(r.ps[:i+1])
+			} else {
+				r.write(r.ps + "\n")
+			}
+		case 'q':
+			r.quit, r.code = true, c.code
+			break loop
+		case 'Q':
+			r.quit, r.code = true, c.code
+			deleted = true
+			break loop
+		case 'r', 'R':
+			if e, ok := r.t.FS.GetEntry(r.t.FS.Abs(c.text)); ok && !e.IsDir {
+				r.appendQ = append(r.appendQ, string(e.Content))
+			}
+		case 'w', 'W':
+			r.writeFile(c.text, r.ps+"\n")
+		case 's':
+			r.subst(c)
+		case 't':
+			if r.tflag {
+				r.tflag = false
+				pc = c.target
+			}
+		case 'T':
+			if !r.tflag {
+				pc = c.target
+			} else {
+				r.tflag = false
+			}
+		case 'b':
+			pc = c.target
+		case 'y':
+			r.ps = strings.Map(func(x rune) rune {
+				if y, ok := c.ymap[x]; ok {
+					return y
+				}
+				return x
+			}, r.ps)
+		case 'z':
+			r.ps = ""
+		case 'F':
+			name := r.input.name
+			if name == "" {
+				name = "-"
+			}
+			r.write(name + "\n")
+		}
+		if len(r.ps) > sedMaxSpace || len(r.hs) > sedMaxSpace {
+			r.fail("无法重新分配内存")
+			return false
+		}
+	}
+	if !deleted && !r.quiet {
+		// 最后一行没有换行时，输出也不补换行
+		if !r.input.lastNL && r.input.isLast() {
+			r.write(r.ps)
+		} else {
+			r.write(r.ps + "\n")
+		}
+	}
+	r.flushAppend()
+	return false
+}
+
+// run 处理一个输入流直到结束或 q 命令
+func (r *sedRun) run(input *sedInput) {
+	r.input, r.lineNo = input, 0
+	for _, c := range r.cmds {
+		c.inRange = false
+	}
+	for !r.quit {
+		line, ok := input.read()
+		if !ok {
+			break
+		}
+		r.lineNo++
+		r.ps, r.tflag = line, false
+		for r.cycle() && !r.quit {
+		}
+	}
+}
+
+const sedUsage = `用法：sed [选项]... {脚本(如果没有其他脚本)} [输入文件]...
+
+  -n, --quiet, --silent
+                 取消自动打印模式空间
+  -e 脚本, --expression=脚本
+                 添加“脚本”到程序的运行列表
+  -f 脚本文件, --file=脚本文件
+                 添加“脚本文件”到程序的运行列表
+  -i[后缀], --in-place[=后缀]
+                 直接修改文件 (如果指定后缀则进行备份)
+  -E, -r, --regexp-extended
+                 在脚本中使用扩展正则表达式
+  -s, --separate
+                 将输入文件视为各自独立的文件而不是一个长的连续输入流
+`
+
+func (t *Terminal) cmdSed(args []string, in io.Reader, out io.Writer) {
+	var scripts, files []string
+	quiet, ere, separate, inPlace := false, false, false, false
+	suffix := ""
+	ok := true
+	addFile := func(name string) {
+		if name == "-" {
+			scripts = append(scripts, "")
+			return
+		}
+		e, found := t.FS.GetEntry(t.FS.Abs(name))
+		if !found || e.IsDir {
+			fmt.Fprintf(t.Stderr, "sed: 无法读取 %s：没有那个文件或目录\n", name)
+			t.lastExitCode = 1
+			ok = false
+			return
+		}
+		scripts = append(scripts, strings.TrimSuffix(string(e.Content), "\n"))
+	}
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--quiet" || a == "--silent":
+			quiet = true
+		case a == "--regexp-extended":
+			ere = true
+		case a == "--separate":
+			separate = true
+		case a == "--posix" || a == "--debug" || a == "--sandbox" || a == "--unbuffered" || a == "--follow-symlinks":
+		case strings.HasPrefix(a, "--expression="):
+			scripts = append(scripts, strings.TrimPrefix(a, "--expression="))
+		case strings.HasPrefix(a, "--file="):
+			addFile(strings.TrimPrefix(a, "--file="))
+		case strings.HasPrefix(a, "--in-place"):
+			inPlace, suffix = true, strings.TrimPrefix(strings.TrimPrefix(a, "--in-place"), "=")
+		case strings.HasPrefix(a, "--line-length="):
+		case strings.HasPrefix(a, "--"):
+			t.usageError("sed", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case strings.HasPrefix(a, "-i"):
+			// -i 的后缀必须紧跟在选项后面
+			inPlace, suffix = true, a[2:]
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "eflsi", func(opt byte, val string) {
+				switch opt {
+				case 'n':
+					quiet = true
+				case 'E', 'r':
+					ere = true
+				case 's':
+					separate = true
+				case 'e':
+					scripts = append(scripts, val)
+				case 'f':
+					addFile(val)
+				case 'l', 'u', 'z':
+				default:
+					if ok {
+						t.usageError("sed", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+						ok = false
+					}
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		return
+	}
+	if scripts == nil {
+		if len(files) == 0 {
+			fmt.Fprint(t.Stderr, sedUsage)
+			t.lastExitCode = 1
+			return
+		}
+		scripts, files = files[:1], files[1:]
+	}
+	script := strings.Join(scripts, "\n")
+	if strings.HasPrefix(script, "#n\n") || script == "#n" {
+		quiet = true
+	}
+	cmds, err := parseSed(script, ere)
+	if err != nil {
+		fmt.Fprintf(t.Stderr, "sed: %v\n", err)
+		t.lastExitCode = 1
+		return
+	}
+	r := &sedRun{t: t, cmds: cmds, quiet: quiet, out: out, wfiles: map[string]*bytes.Buffer{}, limit: time.Now().Add(sedMaxTime)}
+	finish := func() {
+		for _, name := range r.worder {
+			t.writeTextFile("sed", name, r.wfiles[name].Bytes(), false)
+		}
+		if r.errMsg != "" {
+			fmt.Fprintf(t.Stderr, "sed: %s\n", r.errMsg)
+			t.lastExitCode = 4
+		}
+	}
+
+	if inPlace {
+		if len(files) == 0 {
+			fmt.Fprintln(t.Stderr, "sed: 没有输入文件")
+			t.lastExitCode = 1
+			return
+		}
+		for _, f := range files {
+			p := t.FS.Abs(f)
+			e, found := t.FS.GetEntry(p)
+			if !found {
+				fmt.Fprintf(t.Stderr, "sed: 无法读取 %s：没有那个文件或目录\n", f)
+				t.lastExitCode = 2
+				continue
+			}
+			if e.IsDir {
+				fmt.Fprintf(t.Stderr, "sed: 无法编辑 %s：不是一个普通文件\n", f)
+				t.lastExitCode = 4
+				continue
+			}
+			e.mu.RLock()
+			orig := string(e.Content)
+			e.mu.RUnlock()
+			var buf bytes.Buffer
+			r.out = &buf
+			r.run(&sedInput{t: t, names: []string{f}, in: in})
+			if suffix != "" {
+				backup := suffix
+				if strings.Contains(suffix, "*") {
+					backup = strings.ReplaceAll(suffix, "*", path.Base(p))
+					if !strings.Contains(backup, "/") {
+						backup = path.Join(path.Dir(p), backup)
+					}
+				} else {
+					backup = p + suffix
+				}
+				t.FS.Write(t.FS.Abs(backup), []byte(orig), e.Mode)
+			}
+			if err := t.saveText("sed -i", p, buf.String(), orig); err != nil {
+				fmt.Fprintf(t.Stderr, "sed: 无法重命名 %s：%v\n", f, err)
+				t.lastExitCode = 4
+			}
+			if r.quit {
+				break
+			}
+		}
+		finish()
+		if r.code != 0 {
+			t.lastExitCode = r.code
+		}
+		return
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var failed bool
+	if separate {
+		for _, f := range files {
+			input := &sedInput{t: t, names: []string{f}, in: in}
+			r.run(input)
+			failed = failed || input.failed
+			if r.quit {
+				break
+			}
+		}
+	} else {
+		input := &sedInput{t: t, names: files, in: in}
+		r.run(input)
+		failed = input.failed
+	}
+	if failed {
+		t.lastExitCode = 2
+	}
+	finish()
+	if r.code != 0 {
+		t.lastExitCode = r.code
+	}
+}
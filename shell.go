@@ -0,0 +1,912 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// Shell 语言层
+// 变量展开、别名、函数、source 以及启动文件 (/etc/profile、~/.profile、~/.bashrc)。
+// 脚本按语句解释执行，支持 ; && || 以及 if/for 和函数定义，足够跑通常见的启动文件和投放脚本。
+// ==========================================
+
+// expandVars 展开命令行中的 $NAME、${NAME}、${NAME:-默认值}、${NAME:+替换值} 和特殊变量；
+// 单引号内的内容保持原样
+func (t *Terminal) expandVars(line string) string {
+	if !strings.Contains(line, "$") {
+		return line
+	}
+	var b strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(line):
+			b.WriteByte(c)
+			b.WriteByte(line[i+1])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		}
+		if c != '$' || inSingle || i+1 >= len(line) {
+			b.WriteByte(c)
+			continue
+		}
+		next := line[i+1]
+		switch {
+		case next == '{':
+			end := strings.IndexByte(line[i:], '}')
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteString(t.expandBraced(line[i+2 : i+end]))
+			i += end
+		case strings.IndexByte("?$!#@*0123456789", next) >= 0:
+			b.WriteString(t.lookupVar(string(next)))
+			i++
+		case next == '_' || isAlpha(next):
+			j := i + 1
+			for j < len(line) && (line[j] == '_' || isAlpha(line[j]) || (line[j] >= '0' && line[j] <= '9')) {
+				j++
+			}
+			b.WriteString(t.lookupVar(line[i+1 : j]))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// expandBraced 处理 ${...} 内部的表达式
+func (t *Terminal) expandBraced(expr string) string {
+	if strings.HasPrefix(expr, "#") && len(expr) > 1 {
+		return strconv.Itoa(len(t.lookupVar(expr[1:])))
+	}
+	for _, op := range []string{":-", ":+", ":=", "-", "+"} {
+		if i := strings.Index(expr, op); i > 0 && isIdentifier(expr[:i]) {
+			name, word := expr[:i], expr[i+len(op):]
+			val, set := t.varValue(name)
+			empty := !set || (strings.HasPrefix(op, ":") && val == "")
+			switch op {
+			case ":-", "-":
+				if empty {
+					return t.expandVars(word)
+				}
+				return val
+			case ":=":
+				if empty {
+					val = t.expandVars(word)
+					t.Env[name] = val
+				}
+				return val
+			default:
+				if empty {
+					return ""
+				}
+				return t.expandVars(word)
+			}
+		}
+	}
+	return t.lookupVar(expr)
+}
+
+// varValue 返回变量值及其是否已设置
+func (t *Terminal) varValue(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(t.lastExitCode), true
+	case "$":
+		return strconv.Itoa(t.pid), true
+	case "!":
+		if t.lastBgPID == 0 {
+			return "", false
+		}
+		return strconv.Itoa(t.lastBgPID), true
+	case "#":
+		return strconv.Itoa(len(t.positional)), true
+	case "@", "*":
+		return strings.Join(t.positional, " "), true
+	case "0":
+		return "-bash", true
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n > 0 && n <= len(t.positional) {
+			return t.positional[n-1], true
+		}
+		return "", false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, ok := t.Env[name]
+	return v, ok
+}
+
+func (t *Terminal) lookupVar(name string) string {
+	v, _ := t.varValue(name)
+	return v
+}
+
+// ==========================================
+// 别名
+// ==========================================
+
+// expandAliases 展开每个管道段的第一个单词，同一别名不重复展开
+func (t *Terminal) expandAliases(cmdline string) string {
+	if len(t.aliases) == 0 {
+		return cmdline
+	}
+	segs := splitPipeline(cmdline)
+	for i, seg := range segs {
+		seen := map[string]bool{}
+		for {
+			trimmed := strings.TrimLeft(seg, " \t")
+			lead := seg[:len(seg)-len(trimmed)]
+			word := trimmed
+			if j := strings.IndexAny(trimmed, " \t"); j >= 0 {
+				word = trimmed[:j]
+			}
+			val, ok := t.aliases[word]
+			if !ok || seen[word] {
+				break
+			}
+			seen[word] = true
+			seg = lead + val + trimmed[len(word):]
+		}
+		segs[i] = seg
+	}
+	return strings.Join(segs, "|")
+}
+
+func quoteAlias(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+func (t *Terminal) cmdAlias(args []string, out io.Writer) {
+	t.lastExitCode = 0
+	if len(args) == 1 || (len(args) == 2 && args[1] == "-p") {
+		names := make([]string, 0, len(t.aliases))
+		for k := range t.aliases {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Fprintf(out, "alias %s=%s\n", k, quoteAlias(t.aliases[k]))
+		}
+		return
+	}
+	for _, a := range args[1:] {
+		if a == "-p" {
+			continue
+		}
+		if name, val, ok := strings.Cut(a, "="); ok {
+			if name == "" || strings.ContainsAny(name, "/$`=\"' \t") {
+				fmt.Fprintf(t.Stderr, "bash: alias: `%s': 无效的别名名称\n", name)
+				t.lastExitCode = 1
+				continue
+			}
+			if t.aliases == nil {
+				t.aliases = map[string]string{}
+			}
+			t.aliases[name] = val
+			continue
+		}
+		if val, ok := t.aliases[a]; ok {
+			fmt.Fprintf(out, "alias %s=%s\n", a, quoteAlias(val))
+		} else {
+			fmt.Fprintf(t.Stderr, "bash: alias: %s: 未找到\n", a)
+			t.lastExitCode = 1
+		}
+	}
+}
+
+func (t *Terminal) cmdUnalias(args []string) {
+	t.lastExitCode = 0
+	if len(args) < 2 {
+		fmt.Fprintln(t.Stderr, "unalias: 用法：unalias [-a] 名称 [名称 ...]")
+		t.lastExitCode = 2
+		return
+	}
+	for _, a := range args[1:] {
+		if a == "-a" {
+			t.aliases = nil
+			continue
+		}
+		if _, ok := t.aliases[a]; !ok {
+			fmt.Fprintf(t.Stderr, "bash: unalias: %s: 未找到\n", a)
+			t.lastExitCode = 1
+			continue
+		}
+		delete(t.aliases, a)
+	}
+}
+
+// cmdType 说明名称会被如何解释
+func (t *Terminal) cmdType(args []string, out io.Writer) {
+	t.lastExitCode = 0
+	for _, name := range args[1:] {
+		if strings.HasPrefix(name, "-") {
+			continue
+		}
+		switch {
+		case t.aliases[name] != "":
+			fmt.Fprintf(out, "%s 是“%s”的别名\n", name, t.aliases[name])
+		case t.funcs[name] != "":
+			fmt.Fprintf(out, "%s 是函数\n%s () \n{ \n", name, name)
+			for _, line := range strings.Split(t.funcs[name], "\n") {
+				fmt.Fprintf(out, "    %s\n", line)
+			}
+			fmt.Fprintln(out, "}")
+		case shellBuiltins[name] || name == "echo" || name == "pwd" || name == "type" || name == "test" || name == "[":
+			fmt.Fprintf(out, "%s 是 shell 内建\n", name)
+		default:
+			if p, ok := t.lookPath(name); ok {
+				fmt.Fprintf(out, "%s 是 %s\n", name, p)
+			} else {
+				fmt.Fprintf(t.Stderr, "bash: type: %s: 未找到\n", name)
+				t.lastExitCode = 1
+			}
+		}
+	}
+}
+
+// lookPath 在 $PATH 中查找可执行文件
+func (t *Terminal) lookPath(name string) (string, bool) {
+	for _, dir := range strings.Split(t.lookupVar("PATH"), ":") {
+		if dir == "" {
+			continue
+		}
+		p := path.Join(dir, name)
+		if e, ok := t.FS.GetEntry(p); ok && !e.IsDir && e.Mode&0111 != 0 {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// ==========================================
+// test / [
+// ==========================================
+
+func (t *Terminal) cmdTest(args []string) {
+	if args[0] == "[" {
+		if args[len(args)-1] != "]" {
+			fmt.Fprintln(t.Stderr, "bash: [: 缺少 `]'")
+			t.lastExitCode = 2
+			return
+		}
+		args = args[:len(args)-1]
+	}
+	ok, err := t.evalTest(args[1:])
+	if err != nil {
+		fmt.Fprintf(t.Stderr, "bash: %s: %v\n", args[0], err)
+		t.lastExitCode = 2
+		return
+	}
+	t.lastExitCode = 1
+	if ok {
+		t.lastExitCode = 0
+	}
+}
+
+func (t *Terminal) evalTest(a []string) (bool, error) {
+	if len(a) > 0 && a[0] == "!" {
+		ok, err := t.evalTest(a[1:])
+		return !ok, err
+	}
+	for i, w := range a {
+		if w == "-o" || w == "-a" {
+			l, err := t.evalTest(a[:i])
+			if err != nil {
+				return false, err
+			}
+			r, err := t.evalTest(a[i+1:])
+			if w == "-o" {
+				return l || r, err
+			}
+			return l && r, err
+		}
+	}
+	switch len(a) {
+	case 0:
+		return false, nil
+	case 1:
+		return a[0] != "", nil
+	case 2:
+		if a[0] == "-n" {
+			return a[1] != "", nil
+		}
+		if a[0] == "-z" {
+			return a[1] == "", nil
+		}
+		e, ok := t.FS.GetEntry(t.FS.Abs(t.expandTilde(a[1])))
+		switch a[0] {
+		case "-e", "-a":
+			return ok, nil
+		case "-f":
+			return ok && !e.IsDir, nil
+		case "-d":
+			return ok && e.IsDir, nil
+		case "-s":
+			return ok && len(e.Content) > 0, nil
+		case "-r", "-w":
+			return ok, nil
+		case "-x":
+			return ok && e.Mode&0111 != 0, nil
+		case "-L", "-h":
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: 需要一元表达式", a[0])
+	case 3:
+		switch a[1] {
+		case "=", "==":
+			return a[0] == a[2], nil
+		case "!=":
+			return a[0] != a[2], nil
+		case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+			x, err1 := strconv.Atoi(a[0])
+			y, err2 := strconv.Atoi(a[2])
+			if err1 != nil {
+				return false, fmt.Errorf("%s: 需要整数表达式", a[0])
+			}
+			if err2 != nil {
+				return false, fmt.Errorf("%s: 需要整数表达式", a[2])
+			}
+			switch a[1] {
+			case "-eq":
+				return x == y, nil
+			case "-ne":
+				return x != y, nil
+			case "-lt":
+				return x < y, nil
+			case "-le":
+				return x <= y, nil
+			case "-gt":
+				return x > y, nil
+			}
+			return x >= y, nil
+		}
+		return false, fmt.Errorf("%s: 需要二元表达式", a[1])
+	}
+	return false, fmt.Errorf("参数太多")
+}
+
+// ==========================================
+// 脚本解释器
+// ==========================================
+
+// shellStmt 脚本中的一条语句，op 为它与前一条语句之间的连接符 (";"、"&&"、"||")
+type shellStmt struct {
+	text string
+	op   string
+}
+
+// splitStatements 按换行、; && || 切分脚本，并把 then/else/do 等关键字拆成单独的语句
+func splitStatements(script string) []shellStmt {
+	var res []shellStmt
+	var buf strings.Builder
+	var quote byte
+	op := ";"
+	emit := func(next string) {
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		for text != "" {
+			word, rest, _ := strings.Cut(text, " ")
+			switch word {
+			case "then", "else", "do", "{":
+				res = append(res, shellStmt{word, op})
+				op = ";"
+				text = strings.TrimSpace(rest)
+				continue
+			}
+			res = append(res, shellStmt{text, op})
+			break
+		}
+		op = next
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(script) {
+				buf.WriteByte(c)
+				i++
+				c = script[i]
+			}
+		case c == '\\' && i+1 < len(script):
+			if script[i+1] == '\n' {
+				i++
+				continue // 续行
+			}
+			buf.WriteByte(c)
+			i++
+			c = script[i]
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (buf.Len() == 0 || strings.HasSuffix(buf.String(), " ")):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			emit(";")
+			continue
+		case c == '\n' || c == ';':
+			emit(";")
+			continue
+		case (c == '&' || c == '|') && i+1 < len(script) && script[i+1] == c:
+			emit(string([]byte{c, c}))
+			i++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	emit(";")
+	return res
+}
+
+// 控制流
+const (
+	flowNormal = iota
+	flowExit   // exit
+	flowReturn // 函数中的 return
+)
+
+var funcDefRe = regexp.MustCompile(`^(?:function\s+([A-Za-z_][\w.-]*)\s*(?:\(\s*\))?|([A-Za-z_][\w.-]*)\s*\(\s*\))\s*(\{\s*(.*))?$`)
+
+// runLines 解释执行一段脚本
+func (t *Terminal) runLines(script string, out io.Writer) int {
+	return t.runStmts(splitStatements(script), out)
+}
+
+func firstWord(s string) string {
+	w, _, _ := strings.Cut(s, " ")
+	return w
+}
+
+// blockEnd 从 start 开始查找与 open 配对的结束关键字，返回其下标
+func blockEnd(stmts []shellStmt, start int, open, close string) int {
+	depth := 0
+	for i := start; i < len(stmts); i++ {
+		switch w := firstWord(stmts[i].text); {
+		case w == open:
+			depth++
+		case w == close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (t *Terminal) runStmts(stmts []shellStmt, out io.Writer) int {
+	for i := 0; i < len(stmts) && t.Running; i++ {
+		s := stmts[i]
+		skip := (s.op == "&&" && t.lastExitCode != 0) || (s.op == "||" && t.lastExitCode == 0)
+		word := firstWord(s.text)
+
+		// 函数定义：name() { ... } 或 function name { ... }
+		if m := funcDefRe.FindStringSubmatch(s.text); m != nil {
+			name := m[1] + m[2]
+			j := i + 1
+			var body []string
+			if m[3] == "" {
+				// 左花括号在下一条语句
+				if j < len(stmts) && stmts[j].text == "{" {
+					j++
+				}
+			} else if strings.TrimSpace(m[4]) != "" {
+				body = append(body, strings.TrimSpace(m[4]))
+			}
+			depth := 1
+			for ; j < len(stmts); j++ {
+				st := stmts[j]
+				if st.text == "}" {
+					depth--
+					if depth == 0 {
+						break
+					}
+				} else if st.text == "{" || funcDefRe.MatchString(st.text) && strings.Contains(st.text, "{") {
+					depth++
+				}
+				switch {
+				case len(body) == 0:
+					body = append(body, st.text)
+				case st.op == "&&" || st.op == "||":
+					body[len(body)-1] += " " + st.op + " " + st.text
+				default:
+					body = append(body, st.text)
+				}
+			}
+			if !skip {
+				if t.funcs == nil {
+					t.funcs = map[string]string{}
+				}
+				t.funcs[name] = strings.Join(body, "\n")
+				t.lastExitCode = 0
+			}
+			i = j
+			continue
+		}
+
+		switch word {
+		case "if":
+			end := blockEnd(stmts, i, "if", "fi")
+			if end < 0 {
+				fmt.Fprintln(t.Stderr, "bash: 未预期的文件结尾")
+				t.lastExitCode = 2
+				return flowNormal
+			}
+			if !skip {
+				if f := t.runIf(stmts[i:end+1], out); f != flowNormal {
+					return f
+				}
+			}
+			i = end
+			continue
+		case "for":
+			end := blockEnd(stmts, i, "for", "done")
+			if end < 0 || i+1 >= end || stmts[i+1].text != "do" {
+				fmt.Fprintln(t.Stderr, "bash: 未预期的文件结尾")
+				t.lastExitCode = 2
+				return flowNormal
+			}
+			if !skip {
+				if f := t.runFor(s.text, stmts[i+2:end], out); f != flowNormal {
+					return f
+				}
+			}
+			i = end
+			continue
+		}
+		if skip {
+			continue
+		}
+		switch word {
+		case "exit":
+			if t.interactive() {
+				// 登录 shell 的 exit 由内置命令处理 (有停止的任务时会先提示)
+				t.runSimple(s.text, out)
+				if !t.Running {
+					return flowExit
+				}
+				continue
+			}
+			if f := parseArgs(t.expandVars(s.text)); len(f) > 1 {
+				t.lastExitCode, _ = strconv.Atoi(f[1])
+			}
+			return flowExit
+		case "return":
+			if t.interactive() {
+				fmt.Fprintln(t.Stderr, "bash: return: 只能从函数或者源脚本中‘返回’")
+				t.lastExitCode = 1
+				continue
+			}
+			if f := parseArgs(t.expandVars(s.text)); len(f) > 1 {
+				t.lastExitCode, _ = strconv.Atoi(f[1])
+			}
+			return flowReturn
+		case "then", "else", "elif", "fi", "do", "done", "{", "}":
+			fmt.Fprintf(t.Stderr, "bash: 未预期的符号 `%s' 附近有语法错误\n", word)
+			t.lastExitCode = 2
+			continue
+		}
+		if rest, ok := strings.CutPrefix(s.text, "! "); ok {
+			t.runSimple(rest, out)
+			if t.lastExitCode == 0 {
+				t.lastExitCode = 1
+			} else {
+				t.lastExitCode = 0
+			}
+			continue
+		}
+		t.runSimple(s.text, out)
+		// 交互执行时 Ctrl-C 中断整行
+		if t.lastExitCode == 128+sigINT && t.interactive() {
+			return flowNormal
+		}
+	}
+	return flowNormal
+}
+
+// runIf 执行 if ... fi 块
+func (t *Terminal) runIf(block []shellStmt, out io.Writer) int {
+	cond := []shellStmt{{strings.TrimSpace(strings.TrimPrefix(block[0].text, "if")), ";"}}
+	i := 1
+	for i < len(block) {
+		// 条件部分一直到 then
+		for i < len(block) && block[i].text != "then" {
+			cond = append(cond, block[i])
+			i++
+		}
+		if f := t.runStmts(cond, out); f != flowNormal {
+			return f
+		}
+		matched := t.lastExitCode == 0
+		i++
+		// 分支体一直到同层的 elif/else/fi
+		start, depth := i, 0
+		for ; i < len(block); i++ {
+			w := firstWord(block[i].text)
+			if w == "if" {
+				depth++
+			} else if w == "fi" && depth > 0 {
+				depth--
+			} else if depth == 0 && (w == "elif" || w == "else" || w == "fi") {
+				break
+			}
+		}
+		if matched {
+			return t.runStmts(block[start:i], out)
+		}
+		if i >= len(block) {
+			break
+		}
+		switch firstWord(block[i].text) {
+		case "elif":
+			cond = []shellStmt{{strings.TrimSpace(strings.TrimPrefix(block[i].text, "elif")), ";"}}
+			i++
+			continue
+		case "else":
+			t.lastExitCode = 0
+			return t.runStmts(block[i+1:len(block)-1], out)
+		}
+		break
+	}
+	t.lastExitCode = 0
+	return flowNormal
+}
+
+// maxLoopIterations 防止 for 循环展开出过多的命令
+const maxLoopIterations = 1000
+
+// runFor 执行 for NAME in WORDS; do ...; done
+func (t *Terminal) runFor(head string, body []shellStmt, out io.Writer) int {
+	f := parseArgs(t.expandVars(head))
+	if len(f) < 2 || !isIdentifier(f[1]) {
+		fmt.Fprintf(t.Stderr, "bash: `%s': 不是有效的标识符\n", strings.Join(f[1:], " "))
+		t.lastExitCode = 1
+		return flowNormal
+	}
+	words := t.positional
+	if len(f) > 2 && f[2] == "in" {
+		words = nil
+		for _, w := range f[3:] {
+			words = append(words, t.expandBraces(w)...)
+		}
+	}
+	for n, w := range words {
+		if n >= maxLoopIterations || !t.Running {
+			break
+		}
+		t.Env[f[1]] = w
+		if fl := t.runStmts(body, out); fl != flowNormal {
+			return fl
+		}
+	}
+	return flowNormal
+}
+
+var seqBraceRe = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}$`)
+
+// expandBraces 展开 {1..10} 形式的序列
+func (t *Terminal) expandBraces(w string) []string {
+	m := seqBraceRe.FindStringSubmatch(w)
+	if m == nil {
+		return []string{w}
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+	step := 1
+	if b < a {
+		step = -1
+	}
+	var res []string
+	for i := a; len(res) < maxLoopIterations; i += step {
+		res = append(res, strconv.Itoa(i))
+		if i == b {
+			break
+		}
+	}
+	return res
+}
+
+// callFunction 在当前 shell 中执行函数，参数作为位置参数
+func (t *Terminal) callFunction(name string, args []string, out io.Writer) {
+	if t.scriptDepth >= maxScriptDepth {
+		fmt.Fprintf(t.Stderr, "bash: %s: 超出最大函数嵌套层数\n", name)
+		t.lastExitCode = 1
+		return
+	}
+	saved := t.positional
+	t.positional = args
+	t.scriptDepth++
+	defer func() {
+		t.positional = saved
+		t.scriptDepth--
+	}()
+	t.lastExitCode = 0
+	t.runLines(t.funcs[name], out)
+}
+
+// ==========================================
+// source 与启动文件
+// ==========================================
+
+// sourceFile 在当前 shell 中执行文件，文件不存在时返回 false
+func (t *Terminal) sourceFile(name string, args []string, out io.Writer) bool {
+	p := t.FS.Abs(t.expandTilde(name))
+	e, ok := t.FS.GetEntry(p)
+	if !ok || e.IsDir {
+		return false
+	}
+	e.mu.RLock()
+	script := string(e.Content)
+	e.mu.RUnlock()
+	if t.scriptDepth >= maxScriptDepth {
+		fmt.Fprintf(t.Stderr, "bash: %s: 超出最大嵌套层数\n", name)
+		t.lastExitCode = 1
+		return true
+	}
+	saved := t.positional
+	if len(args) > 0 {
+		t.positional = args
+	}
+	t.scriptDepth++
+	defer func() {
+		t.positional = saved
+		t.scriptDepth--
+	}()
+	t.lastExitCode = 0
+	t.runLines(script, out)
+	return true
+}
+
+func (t *Terminal) cmdSource(args []string, out io.Writer) {
+	if len(args) < 2 {
+		fmt.Fprintf(t.Stderr, "bash: %s: 需要文件名参数\n%s: 用法：%s 文件名 [参数]\n", args[0], args[0], args[0])
+		t.lastExitCode = 2
+		return
+	}
+	if t.scriptDepth == 0 {
+		log.Printf("[Shell] %s: %s sourcing %s", t.Remote, t.userName(), t.FS.Abs(t.expandTilde(args[1])))
+	}
+	if !t.sourceFile(args[1], args[2:], out) {
+		fmt.Fprintf(t.Stderr, "bash: %s: 没有那个文件或目录\n", args[1])
+		t.lastExitCode = 1
+	}
+}
+
+// loadStartupFiles 按登录 shell 的顺序读取 /etc/profile 和 ~/.bash_profile、~/.bash_login、~/.profile 中的第一个
+func (t *Terminal) loadStartupFiles() {
+	out := &CRLFWriter{w: t.RW}
+	t.sourceFile("/etc/profile", nil, out)
+	for _, f := range []string{"~/.bash_profile", "~/.bash_login", "~/.profile"} {
+		if t.sourceFile(f, nil, out) {
+			break
+		}
+	}
+	t.lastExitCode = 0
+}
+
+// ==========================================
+// PS1
+// ==========================================
+
+// renderPS1 按 bash 的规则解释提示符中的反斜杠转义，然后做变量展开
+func (t *Terminal) renderPS1(ps1 string) string {
+	user := t.userName()
+	home := t.lookupVar("HOME")
+	cwd := t.FS.cwd
+	now := time.Now()
+
+	tilde := func(dir string) string {
+		if home != "" && home != "/" && (dir == home || strings.HasPrefix(dir, home+"/")) {
+			return "~" + dir[len(home):]
+		}
+		return dir
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(ps1); i++ {
+		c := ps1[i]
+		if c != '\\' || i+1 >= len(ps1) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := ps1[i]; e {
+		case 'u':
+			b.WriteString(user)
+		case 'h':
+			h, _, _ := strings.Cut(HostPersona.Hostname, ".")
+			b.WriteString(h)
+		case 'H':
+			b.WriteString(HostPersona.Hostname)
+		case 'w':
+			b.WriteString(tilde(cwd))
+		case 'W':
+			if w := tilde(cwd); w == "~" || w == "/" {
+				b.WriteString(w)
+			} else {
+				b.WriteString(path.Base(cwd))
+			}
+		case '$':
+			if t.FS.uid(user) == 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('$')
+			}
+		case 't':
+			b.WriteString(now.Format("15:04:05"))
+		case 'T':
+			b.WriteString(now.Format("03:04:05"))
+		case '@':
+			b.WriteString(now.Format("03:04 PM"))
+		case 'A':
+			b.WriteString(now.Format("15:04"))
+		case 'd':
+			b.WriteString(now.Format("Mon Jan 02"))
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 's':
+			b.WriteString("bash")
+		case 'v':
+			v := strings.SplitN(HostPersona.BashVersion, ".", 3)
+			b.WriteString(strings.Join(v[:min(2, len(v))], "."))
+		case 'V':
+			v, _, _ := strings.Cut(HostPersona.BashVersion, "(")
+			b.WriteString(v)
+		case 'j':
+			t.root().jobsMu.Lock()
+			b.WriteString(strconv.Itoa(len(t.root().jobs)))
+			t.root().jobsMu.Unlock()
+		case 'l':
+			b.WriteString(path.Base(t.tty))
+		case '!':
+			b.WriteString(strconv.Itoa(t.histBase + len(t.History) + 1))
+		case '#':
+			b.WriteString(strconv.Itoa(t.cmdCount + 1))
+		case 'e':
+			b.WriteByte(27)
+		case 'a':
+			b.WriteByte(7)
+		case '[', ']':
+			// 非打印字符的边界标记，显示宽度由 visibleWidth 按 ANSI 序列计算
+		case '\\':
+			b.WriteByte('\\')
+		case '0', '1', '2', '3':
+			j := i
+			for j < len(ps1) && j < i+3 && ps1[j] >= '0' && ps1[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(ps1[i:j], 8, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(e)
+		}
+	}
+	return t.expandVars(b.String())
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FTPBindAddr = "0.0.0.0:2100"
+
+	ftpIdleTimeout = 5 * time.Minute
+	ftpDataTimeout = 30 * time.Second
+	maxFTPLineLen  = 4096
+)
+
+func runFTPServer() {
+	ln, err := net.Listen("tcp", FTPBindAddr)
+	if err != nil {
+		log.Printf("[FTP] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[FTP] Server listening on %s", FTPBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleFTPConn(c)
+	}
+}
+
+// ftpSession 单个 FTP 控制连接的状态
+// 文件操作直接作用于共享的 SessionFS，与 SSH/SFTP 看到的内容一致
+type ftpSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	fs     *SessionFS
+	remote string
+
+	user       string
+	loggedIn   bool
+	cwd        string
+	binary     bool
+	renameFrom string
+
+	// 数据连接：被动模式监听器或主动模式目标地址，二者互斥
+	pasvLn     net.Listener
+	activeAddr string
+}
+
+func handleFTPConn(c net.Conn) {
+	defer recoverConn(c)
+	defer c.Close()
+	defer trackConn(c, 21)()
+
+	s := &ftpSession{
+		conn:   c,
+		reader: bufio.NewReader(c),
+		fs:     GlobalSessionFS.From(remoteIP(c.RemoteAddr())),
+		remote: remoteIP(c.RemoteAddr()),
+		cwd:    "/",
+	}
+	defer s.closeData()
+
+	log.Printf("[FTP] %s: connected", s.remote)
+	s.reply(220, HostPersona.FTPBanner)
+
+	for {
+		c.SetReadDeadline(time.Now().Add(ftpIdleTimeout))
+		line, err := s.readLine()
+		if err != nil {
+			if err != io.EOF {
+				s.reply(421, "Timeout.")
+			}
+			return
+		}
+		if line == "" {
+			continue
+		}
+		log.Printf("[FTP] %s: %s", s.remote, line)
+
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		if !s.handle(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+func (s *ftpSession) readLine() (string, error) {
+	var buf []byte
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			break
+		}
+		if len(buf) < maxFTPLineLen {
+			buf = append(buf, b)
+		}
+	}
+	return strings.TrimRight(string(buf), "\r"), nil
+}
+
+func (s *ftpSession) reply(code int, msg string) {
+	fmt.Fprintf(s.conn, "%d %s\r\n", code, msg)
+}
+
+// abs 根据 FTP 会话自身的 cwd 解析路径 (不能使用共享 SessionFS 的 cwd)
+func (s *ftpSession) abs(p string) string {
+	if p == "" {
+		return s.cwd
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(s.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+// handle 处理一条命令，返回 false 表示关闭连接
+func (s *ftpSession) handle(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		s.user = arg
+		s.loggedIn = false
+		s.reply(331, "Please specify the password.")
+		return true
+	case "PASS":
+		if s.user == "" {
+			s.reply(503, "Login with USER first.")
+			return true
+		}
+		if !Auth.Check("ftp", s.remote, s.user, arg) {
+			time.Sleep(time.Second)
+			s.reply(530, "Login incorrect.")
+			return true
+		}
+		s.loggedIn = true
+		if home, ok := lookupHome(s.fs, s.user); ok {
+			if e, ok := s.fs.GetEntry(home); ok && e.IsDir {
+				s.cwd = home
+			}
+		}
+		s.reply(230, "Login successful.")
+		return true
+	case "QUIT":
+		s.reply(221, "Goodbye.")
+		return false
+	case "FEAT":
+		fmt.Fprint(s.conn, "211-Features:\r\n EPRT\r\n EPSV\r\n MDTM\r\n MLST type*;size*;modify*;UNIX.mode*;\r\n PASV\r\n REST STREAM\r\n SIZE\r\n TVFS\r\n UTF8\r\n211 End\r\n")
+		return true
+	case "SYST":
+		s.reply(215, "UNIX Type: L8")
+		return true
+	case "NOOP":
+		s.reply(200, "NOOP ok.")
+		return true
+	case "OPTS":
+		if strings.HasPrefix(strings.ToUpper(arg), "UTF8") {
+			s.reply(200, "Always in UTF8 mode.")
+		} else {
+			s.reply(501, "Option not understood.")
+		}
+		return true
+	case "AUTH":
+		s.reply(530, "Please login with USER and PASS.")
+		return true
+	}
+
+	if !s.loggedIn {
+		s.reply(530, "Please login with USER and PASS.")
+		return true
+	}
+
+	switch cmd {
+	case "PWD", "XPWD":
+		s.reply(257, fmt.Sprintf("%q is the current directory", s.cwd))
+	case "CWD", "XCWD":
+		p := s.abs(arg)
+		if e, ok := s.fs.GetEntry(p); ok && e.IsDir {
+			s.cwd = p
+			s.reply(250, "Directory successfully changed.")
+		} else {
+			s.reply(550, "Failed to change directory.")
+		}
+	case "CDUP", "XCUP":
+		s.cwd = path.Dir(s.cwd)
+		s.reply(250, "Directory successfully changed.")
+	case "TYPE":
+		switch strings.ToUpper(arg) {
+		case "I", "L 8":
+			s.binary = true
+			s.reply(200, "Switching to Binary mode.")
+		case "A", "A N":
+			s.binary = false
+			s.reply(200, "Switching to ASCII mode.")
+		default:
+			s.reply(500, "Unrecognised TYPE command.")
+		}
+	case "MODE":
+		if strings.ToUpper(arg) == "S" {
+			s.reply(200, "Mode set to S.")
+		} else {
+			s.reply(504, "Bad MODE command.")
+		}
+	case "STRU":
+		if strings.ToUpper(arg) == "F" {
+			s.reply(200, "Structure set to F.")
+		} else {
+			s.reply(504, "Bad STRU command.")
+		}
+	case "PASV":
+		s.handlePasv(false)
+	case "EPSV":
+		s.handlePasv(true)
+	case "PORT":
+		s.handlePort(arg)
+	case "EPRT":
+		s.handleEprt(arg)
+	case "LIST", "NLST", "MLSD":
+		s.handleList(cmd, arg)
+	case "MLST":
+		p := s.abs(arg)
+		e, ok := s.fs.GetEntry(p)
+		if !ok {
+			s.reply(550, "Could not get file status.")
+			break
+		}
+		fmt.Fprintf(s.conn, "250-Listing %s\r\n %s %s\r\n250 End\r\n", p, mlsFacts(e), p)
+	case "RETR":
+		s.handleRetr(arg)
+	case "STOR", "APPE":
+		s.handleStor(arg, cmd == "APPE")
+	case "SIZE":
+		e, ok := s.fs.GetEntry(s.abs(arg))
+		if !ok || e.IsDir {
+			s.reply(550, "Could not get file size.")
+		} else {
+			s.reply(213, strconv.Itoa(len(e.Content)))
+		}
+	case "MDTM":
+		e, ok := s.fs.GetEntry(s.abs(arg))
+		if !ok || e.IsDir {
+			s.reply(550, "Could not get file modification time.")
+		} else {
+			s.reply(213, e.ModTime.UTC().Format("20060102150405"))
+		}
+	case "MKD", "XMKD":
+		p := s.abs(arg)
+		parent, ok := s.fs.GetEntry(path.Dir(p))
+		if _, exists := s.fs.GetEntry(p); exists || !ok || !parent.IsDir {
+			s.reply(550, "Create directory operation failed.")
+		} else {
+			s.fs.Mkdir(p)
+			s.reply(257, fmt.Sprintf("%q created", p))
+		}
+	case "RMD", "XRMD":
+		p := s.abs(arg)
+		e, ok := s.fs.GetEntry(p)
+		children, _ := s.fs.ListDir(p)
+		if !ok || !e.IsDir || len(children) > 0 || p == "/" {
+			s.reply(550, "Remove directory operation failed.")
+		} else {
+			s.fs.Remove(p)
+			s.reply(250, "Remove directory operation successful.")
+		}
+	case "DELE":
+		p := s.abs(arg)
+		if e, ok := s.fs.GetEntry(p); !ok || e.IsDir {
+			s.reply(550, "Delete operation failed.")
+		} else {
+			s.fs.Remove(p)
+			s.reply(250, "Delete operation successful.")
+		}
+	case "RNFR":
+		p := s.abs(arg)
+		if _, ok := s.fs.GetEntry(p); !ok {
+			s.reply(550, "RNFR command failed.")
+		} else {
+			s.renameFrom = p
+			s.reply(350, "Ready for RNTO.")
+		}
+	case "RNTO":
+		if s.renameFrom == "" {
+			s.reply(503, "RNFR required first.")
+			break
+		}
+		if err := s.fs.Rename(s.renameFrom, s.abs(arg)); err != nil {
+			s.reply(550, "Rename failed.")
+		} else {
+			s.reply(250, "Rename successful.")
+		}
+		s.renameFrom = ""
+	case "SITE":
+		s.handleSite(arg)
+	case "REST":
+		s.reply(350, "Restart position accepted ("+arg+").")
+	case "ABOR":
+		s.closeData()
+		s.reply(225, "No transfer to ABOR.")
+	case "STAT":
+		fmt.Fprintf(s.conn, "211-FTP server status:\r\n     Connected to %s\r\n     Logged in as %s\r\n     TYPE: %s\r\n     No session bandwidth limit\r\n     Session timeout in seconds is 300\r\n     Control connection is plain text\r\n     Data connections will be plain text\r\n     vsFTPd 3.0.5 - secure, fast, stable\r\n211 End of status\r\n",
+			s.remote, s.user, map[bool]string{true: "BINARY", false: "ASCII"}[s.binary])
+	case "HELP":
+		fmt.Fprint(s.conn, "214-The following commands are recognized.\r\n ABOR ACCT ALLO APPE CDUP CWD  DELE EPRT EPSV FEAT HELP LIST MDTM MKD\r\n MODE NLST NOOP OPTS PASS PASV PORT PWD  QUIT REIN REST RETR RMD  RNFR\r\n RNTO SITE SIZE SMNT STAT STOR STOU STRU SYST TYPE USER XCUP XCWD XMKD\r\n XPWD XRMD\r\n214 Help OK.\r\n")
+	default:
+		s.reply(500, "Unknown command.")
+	}
+	return true
+}
+
+func (s *ftpSession) handleSite(arg string) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		s.reply(500, "SITE command not understood.")
+		return
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "CHMOD":
+		if len(fields) < 3 {
+			s.reply(500, "SITE CHMOD needs 2 arguments.")
+			return
+		}
+		m, err := strconv.ParseUint(fields[1], 8, 32)
+		if err != nil {
+			s.reply(550, "SITE CHMOD command failed.")
+			return
+		}
+		if err := s.fs.Chmod(s.abs(strings.Join(fields[2:], " ")), os.FileMode(m)); err != nil {
+			s.reply(550, "SITE CHMOD command failed.")
+			return
+		}
+		s.reply(200, "SITE CHMOD command ok.")
+	case "UMASK":
+		s.reply(200, "UMASK set to 0022 (was 0022)")
+	case "HELP":
+		s.reply(214, "CHMOD UMASK HELP")
+	default:
+		s.reply(500, "Unknown SITE command.")
+	}
+}
+
+// --- 数据连接 ---
+
+func (s *ftpSession) closeData() {
+	if s.pasvLn != nil {
+		s.pasvLn.Close()
+		s.pasvLn = nil
+	}
+	s.activeAddr = ""
+}
+
+func (s *ftpSession) handlePasv(extended bool) {
+	s.closeData()
+	localIP := remoteIP(s.conn.LocalAddr())
+	ln, err := net.Listen("tcp", net.JoinHostPort(localIP, "0"))
+	if err != nil {
+		s.reply(425, "Failed to enter passive mode.")
+		return
+	}
+	s.pasvLn = ln
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if extended {
+		s.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+		return
+	}
+	ip := net.ParseIP(localIP).To4()
+	if ip == nil {
+		// IPv6 控制连接无法使用 PASV
+		s.closeData()
+		s.reply(425, "Passive mode not supported on IPv6; use EPSV.")
+		return
+	}
+	s.reply(227, fmt.Sprintf("Entering Passive Mode (%d,%d,%d,%d,%d,%d).", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xFF))
+}
+
+func (s *ftpSession) handlePort(arg string) {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 6 {
+		s.reply(500, "Illegal PORT command.")
+		return
+	}
+	nums := make([]int, 6)
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 || n > 255 {
+			s.reply(500, "Illegal PORT command.")
+			return
+		}
+		nums[i] = n
+	}
+	ip := fmt.Sprintf("%d.%d.%d.%d", nums[0], nums[1], nums[2], nums[3])
+	s.setActive(ip, nums[4]<<8|nums[5])
+}
+
+func (s *ftpSession) handleEprt(arg string) {
+	// 格式：|协议|地址|端口|
+	if len(arg) < 2 {
+		s.reply(500, "Bad EPRT command.")
+		return
+	}
+	parts := strings.Split(arg[1:], arg[:1])
+	if len(parts) < 3 {
+		s.reply(500, "Bad EPRT command.")
+		return
+	}
+	port, err := strconv.Atoi(parts[2])
+	if err != nil || port <= 0 || port > 65535 {
+		s.reply(500, "Bad EPRT command.")
+		return
+	}
+	s.setActive(parts[1], port)
+}
+
+// setActive 与 vsftpd 一样拒绝指向第三方主机的 PORT (防止 FTP bounce)
+func (s *ftpSession) setActive(ip string, port int) {
+	s.closeData()
+	if ip != s.remote || port < 1024 {
+		log.Printf("[FTP] %s: rejected bounce PORT to %s:%d", s.remote, ip, port)
+		s.reply(500, "Illegal PORT command.")
+		return
+	}
+	s.activeAddr = net.JoinHostPort(ip, strconv.Itoa(port))
+	s.reply(200, "PORT command successful. Consider using PASV.")
+}
+
+// openData 建立数据连接，调用方负责关闭
+func (s *ftpSession) openData() (net.Conn, error) {
+	defer s.closeData()
+	if s.pasvLn != nil {
+		if tl, ok := s.pasvLn.(*net.TCPListener); ok {
+			tl.SetDeadline(time.Now().Add(ftpDataTimeout))
+		}
+		return s.pasvLn.Accept()
+	}
+	if s.activeAddr != "" {
+		return net.DialTimeout("tcp", s.activeAddr, ftpDataTimeout)
+	}
+	return nil, os.ErrInvalid
+}
+
+func (s *ftpSession) hasData() bool {
+	return s.pasvLn != nil || s.activeAddr != ""
+}
+
+// --- 传输命令 ---
+
+func (s *ftpSession) handleList(cmd, arg string) {
+	if !s.hasData() {
+		s.reply(425, "Use PORT or PASV first.")
+		return
+	}
+	// 忽略 "LIST -la" 之类的参数
+	target := ""
+	for _, f := range strings.Fields(arg) {
+		if !strings.HasPrefix(f, "-") {
+			target = f
+		}
+	}
+	p := s.abs(target)
+	e, ok := s.fs.GetEntry(p)
+	if !ok && cmd == "MLSD" {
+		s.closeData()
+		s.reply(550, "Could not list directory.")
+		return
+	}
+
+	var entries []*FileEntry
+	if ok && e.IsDir {
+		entries, _ = s.fs.ListDir(p)
+	} else if ok {
+		entries = []*FileEntry{e}
+	}
+
+	var buf bytes.Buffer
+	for _, f := range entries {
+		switch cmd {
+		case "LIST":
+			buf.WriteString(ftpListLine(f))
+		case "NLST":
+			if target != "" && ok && e.IsDir {
+				buf.WriteString(path.Join(target, f.Name))
+			} else {
+				buf.WriteString(f.Name)
+			}
+		case "MLSD":
+			buf.WriteString(mlsFacts(f) + " " + f.Name)
+		}
+		buf.WriteString("\r\n")
+	}
+
+	s.reply(150, "Here comes the directory listing.")
+	dc, err := s.openData()
+	if err != nil {
+		s.reply(425, "Failed to establish connection.")
+		return
+	}
+	dc.Write(buf.Bytes())
+	dc.Close()
+	s.reply(226, "Directory send OK.")
+}
+
+func (s *ftpSession) handleRetr(arg string) {
+	p := s.abs(arg)
+	e, ok := s.fs.GetEntry(p)
+	if !ok || e.IsDir {
+		s.closeData()
+		s.reply(550, "Failed to open file.")
+		return
+	}
+	if !s.hasData() {
+		s.reply(425, "Use PORT or PASV first.")
+		return
+	}
+	e.mu.RLock()
+	content := e.Content
+	e.mu.RUnlock()
+
+	mode := "ASCII"
+	if s.binary {
+		mode = "BINARY"
+	}
+	s.reply(150, fmt.Sprintf("Opening %s mode data connection for %s (%d bytes).", mode, arg, len(content)))
+	dc, err := s.openData()
+	if err != nil {
+		s.reply(425, "Failed to establish connection.")
+		return
+	}
+	dc.Write(content)
+	dc.Close()
+	s.reply(226, "Transfer complete.")
+}
+
+func (s *ftpSession) handleStor(arg string, appendMode bool) {
+	p := s.abs(arg)
+	if parent, ok := s.fs.GetEntry(path.Dir(p)); !ok || !parent.IsDir {
+		s.closeData()
+		s.reply(553, "Could not create file.")
+		return
+	}
+	if e, ok := s.fs.GetEntry(p); ok && e.IsDir {
+		s.closeData()
+		s.reply(553, "Could not create file.")
+		return
+	}
+	if !s.hasData() {
+		s.reply(425, "Use PORT or PASV first.")
+		return
+	}
+
+	s.reply(150, "Ok to send data.")
+	dc, err := s.openData()
+	if err != nil {
+		s.reply(425, "Failed to establish connection.")
+		return
+	}
+	dc.SetReadDeadline(time.Now().Add(ftpIdleTimeout))
+	// 多读 1 字节用于判断是否超出限额
+	data, err := io.ReadAll(io.LimitReader(dc, MaxFileSize+1))
+	dc.Close()
+	if err != nil {
+		s.reply(426, "Failure reading network stream.")
+		return
+	}
+
+	if appendMode {
+		if e, ok := s.fs.GetEntry(p); ok {
+			e.mu.RLock()
+			data = append(append([]byte{}, e.Content...), data...)
+			e.mu.RUnlock()
+		}
+	}
+	CapturePayload("ftp", s.remote, p, data)
+	if err := s.fs.Write(p, data, 0); err != nil {
+		s.reply(552, "Exceeded storage allocation.")
+		return
+	}
+	s.reply(226, "Transfer complete.")
+}
+
+// ftpListLine 生成 vsftpd 风格的 LIST 行 (属主显示为数字 ID)
+func ftpListLine(e *FileEntry) string {
+	ts := e.ModTime.Format("Jan 02 15:04")
+	if time.Since(e.ModTime) > 180*24*time.Hour {
+		ts = e.ModTime.Format("Jan 02  2006")
+	}
+	nlink := e.Nlink
+	if nlink == 0 {
+		nlink = 1
+	}
+	return fmt.Sprintf("%s %4d %-8d %-8d %12d %s %s", e.Mode.String(), nlink, e.UID, e.GID, len(e.Content), ts, e.Name)
+}
+
+func mlsFacts(e *FileEntry) string {
+	typ := "file"
+	if e.IsDir {
+		typ = "dir"
+	}
+	return fmt.Sprintf("type=%s;size=%d;modify=%s;UNIX.mode=%04o;", typ, len(e.Content), e.ModTime.UTC().Format("20060102150405"), e.Mode.Perm())
+}
@@ -0,0 +1,963 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MySQLBindAddr = "0.0.0.0:3306"
+
+	mysqlMaxPacket = MaxFileSize + 1024
+
+	comQuit      = 0x01
+	comInitDB    = 0x02
+	comQuery     = 0x03
+	comFieldList = 0x04
+	comPing      = 0x0e
+
+	clientConnectWithDB   = 0x00000008
+	clientProtocol41      = 0x00000200
+	clientSecureConn      = 0x00008000
+	clientPluginAuth      = 0x00080000
+	clientPluginAuthLenEn = 0x00200000
+)
+
+func runMySQLServer() {
+	ln, err := net.Listen("tcp", MySQLBindAddr)
+	if err != nil {
+		log.Printf("[MySQL] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[MySQL] Server listening on %s", MySQLBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleMySQLConn(c)
+	}
+}
+
+// mysqlState 所有连接共享的服务器状态
+type mysqlState struct {
+	mu        sync.Mutex
+	databases map[string]bool
+	udfs      map[string]string // 函数名 -> soname
+	nextID    uint32
+}
+
+var mysqlDB = &mysqlState{
+	databases: map[string]bool{
+		"information_schema": true, "mysql": true,
+		"performance_schema": true, "sys": true,
+	},
+	udfs:   make(map[string]string),
+	nextID: 8,
+}
+
+// mysqlErr 对应 ERR_Packet
+type mysqlErr struct {
+	code  uint16
+	state string
+	msg   string
+}
+
+func (e *mysqlErr) Error() string { return e.msg }
+
+func errSyntax(near string) *mysqlErr {
+	return &mysqlErr{1064, "42000", fmt.Sprintf("You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '%s' at line 1", near)}
+}
+
+type sqlVal struct {
+	s    string
+	null bool
+}
+
+type mysqlConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	seq    byte
+	remote string
+	user   string
+	db     string
+	fs     *SessionFS
+	state  *mysqlState
+}
+
+func handleMySQLConn(c net.Conn) {
+	defer recoverConn(c)
+	defer c.Close()
+	defer trackConn(c, 3306)()
+	mc := &mysqlConn{
+		conn:   c,
+		r:      bufio.NewReader(c),
+		remote: remoteIP(c.RemoteAddr()),
+		fs:     GlobalSessionFS.From(remoteIP(c.RemoteAddr())),
+		state:  mysqlDB,
+	}
+	log.Printf("[MySQL] %s: connected", mc.remote)
+	if !mc.handshake() {
+		return
+	}
+	mc.serve()
+}
+
+func (mc *mysqlConn) readPacket() ([]byte, error) {
+	mc.conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+	var hdr [4]byte
+	if _, err := io.ReadFull(mc.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	if n > mysqlMaxPacket {
+		return nil, errors.New("packet too large")
+	}
+	mc.seq = hdr[3] + 1
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(mc.r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (mc *mysqlConn) writePacket(payload []byte) error {
+	hdr := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), mc.seq}
+	mc.seq++
+	_, err := mc.conn.Write(append(hdr, payload...))
+	return err
+}
+
+func (mc *mysqlConn) writeOK(affected int) error {
+	p := []byte{0x00}
+	p = appendLenEnc(p, uint64(affected))
+	p = appendLenEnc(p, 0)
+	p = append(p, 0x02, 0x00, 0x00, 0x00) // SERVER_STATUS_AUTOCOMMIT, 0 warnings
+	return mc.writePacket(p)
+}
+
+func (mc *mysqlConn) writeErr(e *mysqlErr) error {
+	p := []byte{0xff, byte(e.code), byte(e.code >> 8), '#'}
+	p = append(p, e.state...)
+	p = append(p, e.msg...)
+	return mc.writePacket(p)
+}
+
+func (mc *mysqlConn) writeEOF() error {
+	return mc.writePacket([]byte{0xfe, 0x00, 0x00, 0x02, 0x00})
+}
+
+// writeResultSet 以文本协议返回结果集
+func (mc *mysqlConn) writeResultSet(cols []string, rows [][]sqlVal) error {
+	mc.writePacket(appendLenEnc(nil, uint64(len(cols))))
+	for _, c := range cols {
+		var p []byte
+		p = appendLenEncStr(p, "def")
+		p = appendLenEncStr(p, "")
+		p = appendLenEncStr(p, "")
+		p = appendLenEncStr(p, "")
+		p = appendLenEncStr(p, c)
+		p = appendLenEncStr(p, "")
+		p = append(p, 0x0c, 0xff, 0x00) // 字符集 utf8mb4_0900_ai_ci
+		p = binary.LittleEndian.AppendUint32(p, 1024)
+		p = append(p, 0xfd, 0x00, 0x00, 0x1f, 0x00, 0x00) // VAR_STRING
+		mc.writePacket(p)
+	}
+	mc.writeEOF()
+	for _, row := range rows {
+		var p []byte
+		for _, v := range row {
+			if v.null {
+				p = append(p, 0xfb)
+			} else {
+				p = appendLenEncStr(p, v.s)
+			}
+		}
+		mc.writePacket(p)
+	}
+	return mc.writeEOF()
+}
+
+func appendLenEnc(p []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(p, byte(n))
+	case n < 1<<16:
+		return append(p, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(p, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		p = append(p, 0xfe)
+		return binary.LittleEndian.AppendUint64(p, n)
+	}
+}
+
+func appendLenEncStr(p []byte, s string) []byte {
+	return append(appendLenEnc(p, uint64(len(s))), s...)
+}
+
+func readLenEnc(p []byte) (uint64, int) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	switch p[0] {
+	case 0xfc:
+		if len(p) >= 3 {
+			return uint64(binary.LittleEndian.Uint16(p[1:])), 3
+		}
+	case 0xfd:
+		if len(p) >= 4 {
+			return uint64(p[1]) | uint64(p[2])<<8 | uint64(p[3])<<16, 4
+		}
+	case 0xfe:
+		if len(p) >= 9 {
+			return binary.LittleEndian.Uint64(p[1:]), 9
+		}
+	default:
+		return uint64(p[0]), 1
+	}
+	return 0, 0
+}
+
+// handshake 发送 HandshakeV10 并校验 HandshakeResponse41
+func (mc *mysqlConn) handshake() bool {
+	mc.state.mu.Lock()
+	mc.state.nextID++
+	connID := mc.state.nextID
+	mc.state.mu.Unlock()
+
+	salt := make([]byte, 20)
+	rand.Read(salt)
+	for i := range salt {
+		salt[i] = salt[i]%94 + 33 // 与 mysqld 一样只使用可打印字符
+	}
+
+	p := []byte{0x0a}
+	p = append(p, HostPersona.MySQLVersion...)
+	p = append(p, 0)
+	p = binary.LittleEndian.AppendUint32(p, connID)
+	p = append(p, salt[:8]...)
+	p = append(p, 0)
+	p = append(p, 0x0f, 0xa2) // 能力标志低 16 位
+	p = append(p, 0xff)       // utf8mb4_0900_ai_ci
+	p = append(p, 0x02, 0x00) // SERVER_STATUS_AUTOCOMMIT
+	p = append(p, 0x2a, 0x00) // 能力标志高 16 位
+	p = append(p, 21)
+	p = append(p, make([]byte, 10)...)
+	p = append(p, salt[8:]...)
+	p = append(p, 0)
+	p = append(p, "mysql_native_password"...)
+	p = append(p, 0)
+	mc.seq = 0
+	if mc.writePacket(p) != nil {
+		return false
+	}
+
+	resp, err := mc.readPacket()
+	if err != nil {
+		return false
+	}
+	if len(resp) < 32 || binary.LittleEndian.Uint32(resp)&clientProtocol41 == 0 {
+		log.Printf("[MySQL] %s: unsupported handshake response (%d bytes)", mc.remote, len(resp))
+		mc.writeErr(&mysqlErr{1043, "08S01", "Bad handshake"})
+		return false
+	}
+	caps := binary.LittleEndian.Uint32(resp)
+	rest := resp[32:]
+	user, rest := cutNul(rest)
+
+	var scramble []byte
+	switch {
+	case caps&clientPluginAuthLenEn != 0:
+		n, k := readLenEnc(rest)
+		if k == 0 || n > uint64(len(rest)-k) {
+			mc.writeErr(&mysqlErr{1043, "08S01", "Bad handshake"})
+			return false
+		}
+		scramble, rest = rest[k:k+int(n)], rest[k+int(n):]
+	case caps&clientSecureConn != 0:
+		if len(rest) == 0 || int(rest[0]) > len(rest)-1 {
+			mc.writeErr(&mysqlErr{1043, "08S01", "Bad handshake"})
+			return false
+		}
+		scramble, rest = rest[1:1+int(rest[0])], rest[1+int(rest[0]):]
+	default:
+		var s string
+		s, rest = cutNul(rest)
+		scramble = []byte(s)
+	}
+	db := ""
+	if caps&clientConnectWithDB != 0 {
+		db, rest = cutNul(rest)
+	}
+	plugin := ""
+	if caps&clientPluginAuth != 0 {
+		plugin, _ = cutNul(rest)
+	}
+	log.Printf("[MySQL] %s: login user=%q db=%q plugin=%q scramble=%s salt=%s", mc.remote, user, db, plugin, hex.EncodeToString(scramble), hex.EncodeToString(salt))
+
+	pass := ""
+	if len(scramble) > 0 {
+		pass = "scramble:" + hex.EncodeToString(scramble)
+	}
+	if !Auth.Check("mysql", mc.remote, user, pass) {
+		using := "NO"
+		if len(scramble) > 0 {
+			using = "YES"
+		}
+		mc.writeErr(&mysqlErr{1045, "28000", fmt.Sprintf("Access denied for user '%s'@'%s' (using password: %s)", user, mc.remote, using)})
+		return false
+	}
+	mc.user = user
+	if db != "" {
+		if e := mc.useDB(db); e != nil {
+			mc.writeErr(e)
+			return false
+		}
+	}
+	mc.writeOK(0)
+	return true
+}
+
+func cutNul(p []byte) (string, []byte) {
+	for i, b := range p {
+		if b == 0 {
+			return string(p[:i]), p[i+1:]
+		}
+	}
+	return string(p), nil
+}
+
+func (mc *mysqlConn) serve() {
+	for {
+		pkt, err := mc.readPacket()
+		if err != nil || len(pkt) == 0 {
+			return
+		}
+		switch pkt[0] {
+		case comQuit:
+			log.Printf("[MySQL] %s: quit", mc.remote)
+			return
+		case comPing:
+			mc.writeOK(0)
+		case comInitDB:
+			log.Printf("[MySQL] %s: init db %q", mc.remote, pkt[1:])
+			if e := mc.useDB(string(pkt[1:])); e != nil {
+				mc.writeErr(e)
+			} else {
+				mc.writeOK(0)
+			}
+		case comFieldList:
+			mc.writeEOF()
+		case comQuery:
+			q := string(pkt[1:])
+			log.Printf("[MySQL] %s: query %q", mc.remote, q)
+			mc.query(q)
+		default:
+			mc.writeErr(&mysqlErr{1047, "08S01", "Unknown command"})
+		}
+	}
+}
+
+func (mc *mysqlConn) useDB(name string) *mysqlErr {
+	name = strings.Trim(strings.TrimSpace(name), "`")
+	mc.state.mu.Lock()
+	ok := mc.state.databases[strings.ToLower(name)]
+	mc.state.mu.Unlock()
+	if !ok {
+		return &mysqlErr{1049, "42000", fmt.Sprintf("Unknown database '%s'", name)}
+	}
+	mc.db = name
+	return nil
+}
+
+var (
+	reSQLInto     = regexp.MustCompile(`(?is)\s+into\s+(outfile|dumpfile)\s+'((?:[^'\\]|\\.)*)'`)
+	reSQLCreateFn = regexp.MustCompile("(?is)^create\\s+(?:aggregate\\s+)?function\\s+(?:if\\s+not\\s+exists\\s+)?`?(\\w+)`?\\s+returns\\s+\\w+\\s+soname\\s+['\"]([^'\"]+)['\"]")
+	reSQLDropFn   = regexp.MustCompile("(?is)^drop\\s+function\\s+(if\\s+exists\\s+)?`?(\\w+)`?")
+	reSQLDB       = regexp.MustCompile("(?is)^(create|drop)\\s+(?:database|schema)\\s+(if\\s+(?:not\\s+)?exists\\s+)?`?(\\w+)`?")
+	reSQLLike     = regexp.MustCompile(`(?is)\slike\s+'([^']*)'`)
+	reSQLAlias    = regexp.MustCompile("(?is)^(.+?)\\s+as\\s+[`'\"]?(\\w+)[`'\"]?$")
+	reSQLFunc     = regexp.MustCompile(`(?is)^(\w+)\s*\((.*)\)$`)
+	reSQLLimit    = regexp.MustCompile(`(?is)\s+limit\s+\d+(\s*,\s*\d+)?$`)
+)
+
+// query 处理一条 COM_QUERY
+func (mc *mysqlConn) query(q string) {
+	q = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(q), ";"))
+	lower := strings.ToLower(q)
+	first := strings.Fields(lower + " ")
+	if len(first) == 0 {
+		mc.writeErr(&mysqlErr{1065, "42000", "Query was empty"})
+		return
+	}
+
+	switch {
+	case first[0] == "select":
+		mc.selectQuery(q)
+	case first[0] == "use":
+		if e := mc.useDB(strings.TrimSpace(q[3:])); e != nil {
+			mc.writeErr(e)
+		} else {
+			mc.writeOK(0)
+		}
+	case first[0] == "show":
+		mc.showQuery(q, lower)
+	case reSQLCreateFn.MatchString(q):
+		m := reSQLCreateFn.FindStringSubmatch(q)
+		mc.createFunction(strings.ToLower(m[1]), m[2])
+	case reSQLDropFn.MatchString(q):
+		m := reSQLDropFn.FindStringSubmatch(q)
+		name := strings.ToLower(m[2])
+		mc.state.mu.Lock()
+		_, ok := mc.state.udfs[name]
+		delete(mc.state.udfs, name)
+		mc.state.mu.Unlock()
+		if !ok && m[1] == "" {
+			mc.writeErr(&mysqlErr{1305, "42000", fmt.Sprintf("FUNCTION %s does not exist", m[2])})
+		} else {
+			mc.writeOK(0)
+		}
+	case reSQLDB.MatchString(q):
+		m := reSQLDB.FindStringSubmatch(q)
+		name := strings.ToLower(m[3])
+		mc.state.mu.Lock()
+		exists := mc.state.databases[name]
+		create := strings.EqualFold(m[1], "create")
+		if create && !exists {
+			mc.state.databases[name] = true
+		} else if !create && exists {
+			delete(mc.state.databases, name)
+		}
+		mc.state.mu.Unlock()
+		switch {
+		case create && exists && m[2] == "":
+			mc.writeErr(&mysqlErr{1007, "HY000", fmt.Sprintf("Can't create database '%s'; database exists", m[3])})
+		case !create && !exists && m[2] == "":
+			mc.writeErr(&mysqlErr{1008, "HY000", fmt.Sprintf("Can't drop database '%s'; database doesn't exist", m[3])})
+		default:
+			mc.writeOK(1)
+		}
+	case first[0] == "set", first[0] == "begin", first[0] == "commit", first[0] == "rollback",
+		first[0] == "flush", first[0] == "grant", first[0] == "revoke", first[0] == "kill":
+		mc.writeOK(0)
+	case first[0] == "create", first[0] == "insert", first[0] == "update", first[0] == "delete",
+		first[0] == "drop", first[0] == "alter", first[0] == "replace":
+		if mc.db == "" {
+			mc.writeErr(&mysqlErr{1046, "3D000", "No database selected"})
+		} else {
+			mc.writeOK(1)
+		}
+	default:
+		mc.writeErr(errSyntax(truncateSQL(q)))
+	}
+}
+
+func truncateSQL(s string) string {
+	if len(s) > 80 {
+		return s[:80]
+	}
+	return s
+}
+
+func (mc *mysqlConn) showQuery(q, lower string) {
+	pattern := ""
+	if m := reSQLLike.FindStringSubmatch(q); m != nil {
+		pattern = m[1]
+	}
+	switch {
+	case strings.HasPrefix(lower, "show databases"), strings.HasPrefix(lower, "show schemas"):
+		mc.state.mu.Lock()
+		var names []string
+		for n := range mc.state.databases {
+			if pattern == "" || sqlLike(pattern, n) {
+				names = append(names, n)
+			}
+		}
+		mc.state.mu.Unlock()
+		sort.Strings(names)
+		rows := make([][]sqlVal, len(names))
+		for i, n := range names {
+			rows[i] = []sqlVal{{s: n}}
+		}
+		mc.writeResultSet([]string{"Database"}, rows)
+	case strings.HasPrefix(lower, "show tables"):
+		if mc.db == "" {
+			mc.writeErr(&mysqlErr{1046, "3D000", "No database selected"})
+			return
+		}
+		var rows [][]sqlVal
+		if strings.EqualFold(mc.db, "mysql") {
+			for _, t := range []string{"columns_priv", "db", "func", "plugin", "proxies_priv", "tables_priv", "user"} {
+				rows = append(rows, []sqlVal{{s: t}})
+			}
+		}
+		mc.writeResultSet([]string{"Tables_in_" + mc.db}, rows)
+	case strings.Contains(lower, "variables"):
+		var names []string
+		for n := range mysqlVariables() {
+			if pattern == "" || sqlLike(pattern, n) {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		vars := mysqlVariables()
+		var rows [][]sqlVal
+		for _, n := range names {
+			rows = append(rows, []sqlVal{{s: n}, {s: vars[n]}})
+		}
+		mc.writeResultSet([]string{"Variable_name", "Value"}, rows)
+	case strings.HasPrefix(lower, "show warnings"), strings.HasPrefix(lower, "show errors"):
+		mc.writeResultSet([]string{"Level", "Code", "Message"}, nil)
+	case strings.HasPrefix(lower, "show grants"):
+		mc.writeResultSet([]string{"Grants for " + mc.user + "@%"},
+			[][]sqlVal{{{s: fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO `%s`@`%%` WITH GRANT OPTION", mc.user)}}})
+	case strings.HasPrefix(lower, "show processlist"), strings.HasPrefix(lower, "show full processlist"):
+		mc.writeResultSet([]string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"},
+			[][]sqlVal{{{s: "5"}, {s: "event_scheduler"}, {s: "localhost"}, {null: true}, {s: "Daemon"}, {s: "3600"}, {s: "Waiting on empty queue"}, {null: true}}})
+	default:
+		mc.writeErr(errSyntax(truncateSQL(q)))
+	}
+}
+
+// sqlLike 实现 LIKE 的 % 和 _ 通配
+func sqlLike(pattern, s string) bool {
+	var re strings.Builder
+	re.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			re.WriteString(".*")
+		case '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	ok, _ := regexp.MatchString(re.String(), s)
+	return ok
+}
+
+func mysqlVariables() map[string]string {
+	return map[string]string{
+		"version":                       HostPersona.MySQLVersion,
+		"version_comment":               "(Ubuntu)",
+		"version_compile_os":            "Linux",
+		"version_compile_machine":       HostPersona.Arch,
+		"hostname":                      HostPersona.Hostname,
+		"port":                          "3306",
+		"datadir":                       "/var/lib/mysql/",
+		"basedir":                       "/usr/",
+		"plugin_dir":                    "/usr/lib/mysql/plugin/",
+		"secure_file_priv":              "",
+		"tmpdir":                        "/tmp",
+		"socket":                        "/var/run/mysqld/mysqld.sock",
+		"max_allowed_packet":            "67108864",
+		"character_set_client":          "utf8mb4",
+		"character_set_connection":      "utf8mb4",
+		"character_set_results":         "utf8mb4",
+		"character_set_server":          "utf8mb4",
+		"collation_server":              "utf8mb4_0900_ai_ci",
+		"collation_connection":          "utf8mb4_0900_ai_ci",
+		"auto_increment_increment":      "1",
+		"autocommit":                    "1",
+		"transaction_isolation":         "REPEATABLE-READ",
+		"lower_case_table_names":        "0",
+		"sql_mode":                      "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION",
+		"system_time_zone":              "UTC",
+		"time_zone":                     "SYSTEM",
+		"license":                       "GPL",
+		"wait_timeout":                  "28800",
+		"interactive_timeout":           "28800",
+		"net_write_timeout":             "60",
+		"have_ssl":                      "DISABLED",
+		"have_symlink":                  "DISABLED",
+		"general_log":                   "OFF",
+		"general_log_file":              "/var/lib/mysql/" + HostPersona.Hostname + ".log",
+		"log_error":                     "/var/log/mysql/error.log",
+		"init_connect":                  "",
+		"performance_schema":            "ON",
+		"default_authentication_plugin": "caching_sha2_password",
+	}
+}
+
+func (mc *mysqlConn) selectQuery(q string) {
+	// SELECT ... INTO OUTFILE/DUMPFILE: 把结果写入文件系统
+	into, target := "", ""
+	if loc := reSQLInto.FindStringSubmatchIndex(q); loc != nil {
+		into = strings.ToLower(q[loc[2]:loc[3]])
+		target = unescapeSQL(q[loc[4]:loc[5]])
+		tail := q[loc[1]:]
+		q = q[:loc[0]]
+		if i := topLevelKeyword(tail, "from"); i >= 0 {
+			q += " " + tail[i:]
+		}
+	}
+
+	cols, rows, e := mc.evalSelect(strings.TrimSpace(q[len("select"):]))
+	if e != nil {
+		mc.writeErr(e)
+		return
+	}
+	if into == "" {
+		mc.writeResultSet(cols, rows)
+		return
+	}
+
+	var data strings.Builder
+	for _, row := range rows {
+		for i, v := range row {
+			if into == "outfile" && i > 0 {
+				data.WriteByte('\t')
+			}
+			if v.null && into == "outfile" {
+				data.WriteString(`\N`)
+			} else {
+				data.WriteString(v.s)
+			}
+		}
+		if into == "outfile" {
+			data.WriteByte('\n')
+		}
+	}
+	if into == "dumpfile" && len(rows) > 1 {
+		mc.writeErr(&mysqlErr{1172, "42000", "Result consisted of more than one row"})
+		return
+	}
+	if _, ok := mc.fs.GetEntry(target); ok {
+		mc.writeErr(&mysqlErr{1086, "HY000", fmt.Sprintf("File '%s' already exists", target)})
+		return
+	}
+	if e, ok := mc.fs.GetEntry(path.Dir(path.Clean(target))); !ok || !e.IsDir || !strings.HasPrefix(target, "/") {
+		mc.writeErr(&mysqlErr{1, "HY000", fmt.Sprintf("Can't create/write to file '%s' (OS errno 2 - No such file or directory)", target)})
+		return
+	}
+	if err := mc.fs.Write(target, []byte(data.String()), 0640); err != nil {
+		mc.writeErr(&mysqlErr{1, "HY000", fmt.Sprintf("Can't create/write to file '%s' (OS errno 122 - Disk quota exceeded)", target)})
+		return
+	}
+	log.Printf("[MySQL] %s: IOC select into %s %s", mc.remote, into, target)
+	CapturePayload("mysql-"+into, mc.remote, target, []byte(data.String()))
+	mc.writeOK(len(rows))
+}
+
+// topLevelKeyword 查找不在引号和括号内的关键字位置
+func topLevelKeyword(s, kw string) int {
+	depth := 0
+	var quote byte
+	lower := strings.ToLower(s)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], kw) &&
+			(i == 0 || s[i-1] == ' ' || s[i-1] == '\t' || s[i-1] == '\n') &&
+			(i+len(kw) == len(s) || s[i+len(kw)] == ' ' || s[i+len(kw)] == '\t' || s[i+len(kw)] == '\n'):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitTopLevel 按不在引号和括号内的逗号分割
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" || len(parts) > 0 {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+func (mc *mysqlConn) evalSelect(body string) ([]string, [][]sqlVal, *mysqlErr) {
+	body = reSQLLimit.ReplaceAllString(body, "")
+	from := ""
+	if i := topLevelKeyword(body, "from"); i >= 0 {
+		from = strings.TrimSpace(body[i+4:])
+		body = strings.TrimSpace(body[:i])
+	}
+	exprs := splitTopLevel(body)
+	if len(exprs) == 0 {
+		return nil, nil, errSyntax("")
+	}
+
+	if from != "" {
+		table := strings.ToLower(strings.Trim(strings.Fields(from)[0], "`"))
+		if table != "dual" {
+			return mc.selectTable(table, exprs)
+		}
+	}
+
+	var cols []string
+	var row []sqlVal
+	for _, e := range exprs {
+		name := e
+		if m := reSQLAlias.FindStringSubmatch(e); m != nil {
+			e, name = m[1], m[2]
+		}
+		v, err := mc.evalExpr(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		cols = append(cols, name)
+		row = append(row, v)
+	}
+	return cols, [][]sqlVal{row}, nil
+}
+
+// selectTable 仅模拟攻击者常查的 mysql.user
+func (mc *mysqlConn) selectTable(table string, exprs []string) ([]string, [][]sqlVal, *mysqlErr) {
+	if !strings.Contains(table, ".") {
+		if mc.db == "" {
+			return nil, nil, &mysqlErr{1046, "3D000", "No database selected"}
+		}
+		table = strings.ToLower(mc.db) + "." + table
+	}
+	if table != "mysql.user" {
+		return nil, nil, &mysqlErr{1146, "42S02", fmt.Sprintf("Table '%s' doesn't exist", table)}
+	}
+	users := []map[string]string{
+		{"user": "debian-sys-maint", "host": "localhost", "plugin": "caching_sha2_password", "authentication_string": "$A$005$Xk7mQ2pLr9vT4wYz1nB8c/Jh5dF3sG6aE0uI9oP2qR7tK4lM8nV1xC5bZ3"},
+		{"user": "mysql.infoschema", "host": "localhost", "plugin": "caching_sha2_password", "authentication_string": "$A$005$THISISACOMBINATIONOFINVALIDSALTANDPASSWORDTHATMUSTNEVERBRBEUSED"},
+		{"user": "mysql.session", "host": "localhost", "plugin": "caching_sha2_password", "authentication_string": "$A$005$THISISACOMBINATIONOFINVALIDSALTANDPASSWORDTHATMUSTNEVERBRBEUSED"},
+		{"user": "mysql.sys", "host": "localhost", "plugin": "caching_sha2_password", "authentication_string": "$A$005$THISISACOMBINATIONOFINVALIDSALTANDPASSWORDTHATMUSTNEVERBRBEUSED"},
+		{"user": "root", "host": "localhost", "plugin": "auth_socket", "authentication_string": ""},
+	}
+	var cols []string
+	for _, e := range exprs {
+		if e == "*" {
+			cols = append(cols, "host", "user", "plugin", "authentication_string")
+			continue
+		}
+		c := strings.ToLower(strings.Trim(e, "`"))
+		if _, ok := users[0][c]; !ok {
+			return nil, nil, &mysqlErr{1054, "42S22", fmt.Sprintf("Unknown column '%s' in 'field list'", e)}
+		}
+		cols = append(cols, c)
+	}
+	var rows [][]sqlVal
+	for _, u := range users {
+		var row []sqlVal
+		for _, c := range cols {
+			row = append(row, sqlVal{s: u[c]})
+		}
+		rows = append(rows, row)
+	}
+	return cols, rows, nil
+}
+
+func unescapeSQL(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`, `\n`, "\n", `\r`, "\r", `\t`, "\t", `\0`, "\x00", `''`, `'`)
+	return r.Replace(s)
+}
+
+func (mc *mysqlConn) evalExpr(e string) (sqlVal, *mysqlErr) {
+	e = strings.TrimSpace(e)
+	lower := strings.ToLower(e)
+	switch {
+	case e == "":
+		return sqlVal{}, errSyntax("")
+	case lower == "null":
+		return sqlVal{null: true}, nil
+	case len(e) >= 2 && (e[0] == '\'' || e[0] == '"') && e[len(e)-1] == e[0]:
+		return sqlVal{s: unescapeSQL(e[1 : len(e)-1])}, nil
+	case strings.HasPrefix(lower, "0x"):
+		b, err := hex.DecodeString(e[2:])
+		if err != nil {
+			return sqlVal{}, &mysqlErr{1054, "42S22", fmt.Sprintf("Unknown column '%s' in 'field list'", e)}
+		}
+		return sqlVal{s: string(b)}, nil
+	case strings.HasPrefix(lower, "x'") && strings.HasSuffix(e, "'"):
+		b, err := hex.DecodeString(e[2 : len(e)-1])
+		if err != nil {
+			return sqlVal{}, errSyntax(e)
+		}
+		return sqlVal{s: string(b)}, nil
+	case strings.HasPrefix(e, "@@"):
+		name := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(lower[2:], "session."), "global."), "local.")
+		if name == "tx_isolation" {
+			name = "transaction_isolation"
+		}
+		v, ok := mysqlVariables()[name]
+		if !ok {
+			return sqlVal{}, &mysqlErr{1193, "HY000", fmt.Sprintf("Unknown system variable '%s'", name)}
+		}
+		return sqlVal{s: v}, nil
+	case lower == "current_user", lower == "current_timestamp":
+		return mc.evalExpr(e + "()")
+	}
+	if _, err := strconv.ParseFloat(e, 64); err == nil {
+		return sqlVal{s: e}, nil
+	}
+
+	m := reSQLFunc.FindStringSubmatch(e)
+	if m == nil {
+		return sqlVal{}, &mysqlErr{1054, "42S22", fmt.Sprintf("Unknown column '%s' in 'field list'", e)}
+	}
+	name := strings.ToLower(m[1])
+	var args []sqlVal
+	for _, a := range splitTopLevel(m[2]) {
+		v, err := mc.evalExpr(a)
+		if err != nil {
+			return sqlVal{}, err
+		}
+		args = append(args, v)
+	}
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i].s
+		}
+		return ""
+	}
+
+	switch name {
+	case "version":
+		return sqlVal{s: HostPersona.MySQLVersion}, nil
+	case "user", "current_user", "session_user", "system_user":
+		host := mc.remote
+		if name == "current_user" {
+			host = "%"
+		}
+		return sqlVal{s: mc.user + "@" + host}, nil
+	case "database", "schema":
+		if mc.db == "" {
+			return sqlVal{null: true}, nil
+		}
+		return sqlVal{s: mc.db}, nil
+	case "now", "sysdate", "current_timestamp":
+		return sqlVal{s: time.Now().Format("2006-01-02 15:04:05")}, nil
+	case "connection_id":
+		return sqlVal{s: "9"}, nil
+	case "sleep":
+		// 基于时间的盲注探测：睡眠但设置上限
+		if n, err := strconv.ParseFloat(arg(0), 64); err == nil && n > 0 {
+			if n > 10 {
+				n = 10
+			}
+			time.Sleep(time.Duration(n * float64(time.Second)))
+		}
+		return sqlVal{s: "0"}, nil
+	case "concat":
+		var b strings.Builder
+		for _, a := range args {
+			if a.null {
+				return sqlVal{null: true}, nil
+			}
+			b.WriteString(a.s)
+		}
+		return sqlVal{s: b.String()}, nil
+	case "hex":
+		return sqlVal{s: strings.ToUpper(hex.EncodeToString([]byte(arg(0))))}, nil
+	case "unhex":
+		b, err := hex.DecodeString(arg(0))
+		if err != nil {
+			return sqlVal{null: true}, nil
+		}
+		return sqlVal{s: string(b)}, nil
+	case "to_base64":
+		return sqlVal{s: base64.StdEncoding.EncodeToString([]byte(arg(0)))}, nil
+	case "from_base64":
+		b, err := base64.StdEncoding.DecodeString(arg(0))
+		if err != nil {
+			return sqlVal{null: true}, nil
+		}
+		return sqlVal{s: string(b)}, nil
+	case "load_file":
+		log.Printf("[MySQL] %s: load_file %q", mc.remote, arg(0))
+		ent, ok := mc.fs.GetEntry(arg(0))
+		if !ok || ent.IsDir {
+			return sqlVal{null: true}, nil
+		}
+		ent.mu.RLock()
+		defer ent.mu.RUnlock()
+		return sqlVal{s: string(ent.Content)}, nil
+	}
+
+	mc.state.mu.Lock()
+	_, isUDF := mc.state.udfs[name]
+	mc.state.mu.Unlock()
+	if isUDF {
+		log.Printf("[MySQL] %s: IOC udf %s(%q)", mc.remote, name, arg(0))
+		out := RunShellCommand(mc.fs, mc.remote, "mysql", "/var/lib/mysql", arg(0))
+		if name == "sys_exec" {
+			return sqlVal{s: "0"}, nil
+		}
+		return sqlVal{s: string(out)}, nil
+	}
+	if mc.db == "" {
+		return sqlVal{}, &mysqlErr{1046, "3D000", "No database selected"}
+	}
+	return sqlVal{}, &mysqlErr{1305, "42000", fmt.Sprintf("FUNCTION %s.%s does not exist", mc.db, m[1])}
+}
+
+// createFunction 处理 UDF 提权：库文件必须已被写入 plugin_dir
+func (mc *mysqlConn) createFunction(name, soname string) {
+	mc.state.mu.Lock()
+	defer mc.state.mu.Unlock()
+	if _, ok := mc.state.udfs[name]; ok {
+		mc.writeErr(&mysqlErr{1125, "HY000", fmt.Sprintf("Function '%s' already exists", name)})
+		return
+	}
+	p := path.Join(mysqlVariables()["plugin_dir"], path.Base(soname))
+	ent, ok := mc.fs.GetEntry(p)
+	if !ok || ent.IsDir {
+		mc.writeErr(&mysqlErr{1126, "HY000", fmt.Sprintf("Can't open shared library '%s' (errno: 2 cannot open shared object file: No such file or directory)", path.Base(soname))})
+		return
+	}
+	ent.mu.RLock()
+	CapturePayload("mysql-udf", mc.remote, p, ent.Content)
+	ent.mu.RUnlock()
+	mc.state.udfs[name] = soname
+	log.Printf("[MySQL] %s: IOC udf %s created from %s", mc.remote, name, p)
+	mc.writeOK(0)
+}
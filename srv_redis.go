@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RedisBindAddr = "0.0.0.0:6379"
+
+	maxRedisArgs = 1024
+)
+
+func runRedisServer() {
+	ln, err := net.Listen("tcp", RedisBindAddr)
+	if err != nil {
+		log.Printf("[Redis] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[Redis] Server listening on %s", RedisBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleRedisConn(c)
+	}
+}
+
+// redisStore 所有连接共享的 Redis 状态 (与真实实例一样是全局的)
+type redisStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	config   map[string]string
+	modules  map[string]string // 模块名 -> .so 路径
+	master   string
+	lastSave time.Time
+	fs       *SessionFS
+}
+
+var redisDB = newRedisStore()
+
+func newRedisStore() *redisStore {
+	return &redisStore{
+		data: make(map[string]string),
+		config: map[string]string{
+			"dir":                         "/var/lib/redis",
+			"dbfilename":                  "dump.rdb",
+			"requirepass":                 "",
+			"masterauth":                  "",
+			"bind":                        "0.0.0.0",
+			"protected-mode":              "no",
+			"port":                        "6379",
+			"databases":                   "16",
+			"maxmemory":                   "0",
+			"maxmemory-policy":            "noeviction",
+			"appendonly":                  "no",
+			"appendfilename":              "appendonly.aof",
+			"save":                        "900 1 300 10 60 10000",
+			"replica-read-only":           "yes",
+			"slave-read-only":             "yes",
+			"timeout":                     "0",
+			"tcp-keepalive":               "300",
+			"loglevel":                    "notice",
+			"logfile":                     "/var/log/redis/redis-server.log",
+			"rdbcompression":              "yes",
+			"rdbchecksum":                 "yes",
+			"stop-writes-on-bgsave-error": "yes",
+		},
+		modules:  make(map[string]string),
+		lastSave: startTime,
+	}
+}
+
+func (s *redisStore) sessionFS() *SessionFS {
+	if s.fs != nil {
+		return s.fs
+	}
+	return GlobalSessionFS
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	w      *bufio.Writer
+	remote string
+	db     *redisStore
+}
+
+func handleRedisConn(c net.Conn) {
+	defer recoverConn(c)
+	defer c.Close()
+	defer trackConn(c, 6379)()
+	rc := &redisConn{
+		conn:   c,
+		reader: bufio.NewReader(c),
+		w:      bufio.NewWriter(c),
+		remote: remoteIP(c.RemoteAddr()),
+		db:     redisDB,
+	}
+	log.Printf("[Redis] %s: connected", rc.remote)
+	rc.serve()
+}
+
+func (rc *redisConn) serve() {
+	for {
+		rc.conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+		args, err := readRESP(rc.reader)
+		if err != nil {
+			if err != io.EOF {
+				rc.writeError("ERR Protocol error: " + err.Error())
+				rc.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		log.Printf("[Redis] %s: %q", rc.remote, args)
+
+		// 与 Redis 3.2.7+ 一样，拒绝跨协议 (HTTP) 攻击
+		if cmd := strings.ToLower(args[0]); cmd == "post" || cmd == "host:" {
+			log.Printf("[Redis] %s: Possible SECURITY ATTACK detected, closing", rc.remote)
+			return
+		}
+		if !rc.dispatch(args) {
+			rc.w.Flush()
+			return
+		}
+		rc.w.Flush()
+	}
+}
+
+// readRESP 读取一条命令：RESP 数组或内联命令
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return parseArgs(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxRedisArgs {
+		return nil, errors.New("invalid multibulk length")
+	}
+	// 与 Redis 一样，*0 和 *-1 (空数组) 当作空命令忽略
+	if n <= 0 {
+		return nil, nil
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		hdr, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(hdr, "$") {
+			return nil, fmt.Errorf("expected '$', got '%.1s'", hdr)
+		}
+		l, err := strconv.Atoi(hdr[1:])
+		if err != nil || l < 0 || l > MaxFileSize {
+			return nil, errors.New("invalid bulk length")
+		}
+		buf := make([]byte, l+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:l]))
+	}
+	return args, nil
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			return strings.TrimRight(string(buf), "\r"), nil
+		}
+		if len(buf) >= 64<<10 {
+			return "", errors.New("too big inline request")
+		}
+		buf = append(buf, b)
+	}
+}
+
+// --- 回复编码 ---
+
+func (rc *redisConn) writeSimple(s string) { fmt.Fprintf(rc.w, "+%s\r\n", s) }
+func (rc *redisConn) writeError(s string)  { fmt.Fprintf(rc.w, "-%s\r\n", s) }
+func (rc *redisConn) writeInt(n int)       { fmt.Fprintf(rc.w, ":%d\r\n", n) }
+func (rc *redisConn) writeNil()            { rc.w.WriteString("$-1\r\n") }
+func (rc *redisConn) writeBulk(s string)   { fmt.Fprintf(rc.w, "$%d\r\n%s\r\n", len(s), s) }
+
+func (rc *redisConn) writeArray(items []string) {
+	fmt.Fprintf(rc.w, "*%d\r\n", len(items))
+	for _, it := range items {
+		rc.writeBulk(it)
+	}
+}
+
+func (rc *redisConn) wrongArgs(cmd string) {
+	rc.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd))
+}
+
+// dispatch 执行一条命令，返回 false 表示关闭连接
+func (rc *redisConn) dispatch(args []string) bool {
+	cmd := strings.ToLower(args[0])
+	// rogue 模块的命令可能长时间运行 (如 sleep)，不能在整个执行期间持有全局锁
+	if cmd == "system.exec" || cmd == "system.rev" {
+		rc.handleSystemModule(cmd, args)
+		return true
+	}
+	db := rc.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch cmd {
+	case "ping":
+		if len(args) > 1 {
+			rc.writeBulk(args[1])
+		} else {
+			rc.writeSimple("PONG")
+		}
+	case "echo":
+		if len(args) != 2 {
+			rc.wrongArgs(cmd)
+			break
+		}
+		rc.writeBulk(args[1])
+	case "quit":
+		rc.writeSimple("OK")
+		return false
+	case "auth":
+		if db.config["requirepass"] == "" {
+			rc.writeError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		} else if args[len(args)-1] == db.config["requirepass"] {
+			rc.writeSimple("OK")
+		} else {
+			rc.writeError("WRONGPASS invalid username-password pair")
+		}
+	case "select":
+		if len(args) != 2 {
+			rc.wrongArgs(cmd)
+		} else if n, err := strconv.Atoi(args[1]); err != nil {
+			rc.writeError("ERR value is not an integer or out of range")
+		} else if n < 0 || n > 15 {
+			rc.writeError("ERR DB index is out of range")
+		} else {
+			rc.writeSimple("OK")
+		}
+	case "info":
+		section := ""
+		if len(args) > 1 {
+			section = strings.ToLower(args[1])
+		}
+		rc.writeBulk(db.info(section))
+	case "config":
+		rc.handleConfig(args)
+	case "set":
+		if len(args) < 3 {
+			rc.wrongArgs(cmd)
+			break
+		}
+		db.data[args[1]] = args[2]
+		rc.writeSimple("OK")
+	case "setnx":
+		if len(args) != 3 {
+			rc.wrongArgs(cmd)
+			break
+		}
+		if _, ok := db.data[args[1]]; ok {
+			rc.writeInt(0)
+		} else {
+			db.data[args[1]] = args[2]
+			rc.writeInt(1)
+		}
+	case "get":
+		if len(args) != 2 {
+			rc.wrongArgs(cmd)
+		} else if v, ok := db.data[args[1]]; ok {
+			rc.writeBulk(v)
+		} else {
+			rc.writeNil()
+		}
+	case "del", "unlink":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := db.data[k]; ok {
+				delete(db.data, k)
+				n++
+			}
+		}
+		rc.writeInt(n)
+	case "exists":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := db.data[k]; ok {
+				n++
+			}
+		}
+		rc.writeInt(n)
+	case "type":
+		if _, ok := db.data[args[len(args)-1]]; ok && len(args) == 2 {
+			rc.writeSimple("string")
+		} else {
+			rc.writeSimple("none")
+		}
+	case "ttl", "pttl":
+		if _, ok := db.data[args[len(args)-1]]; ok && len(args) == 2 {
+			rc.writeInt(-1)
+		} else {
+			rc.writeInt(-2)
+		}
+	case "expire", "pexpire":
+		if len(args) != 3 {
+			rc.wrongArgs(cmd)
+		} else if _, ok := db.data[args[1]]; ok {
+			rc.writeInt(1)
+		} else {
+			rc.writeInt(0)
+		}
+	case "keys":
+		if len(args) != 2 {
+			rc.wrongArgs(cmd)
+			break
+		}
+		var keys []string
+		for k := range db.data {
+			if ok, _ := path.Match(args[1], k); ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		rc.writeArray(keys)
+	case "dbsize":
+		rc.writeInt(len(db.data))
+	case "flushall", "flushdb":
+		db.data = make(map[string]string)
+		rc.writeSimple("OK")
+	case "save":
+		if err := db.save(rc.remote); err != nil {
+			rc.writeError("ERR")
+		} else {
+			rc.writeSimple("OK")
+		}
+	case "bgsave":
+		db.save(rc.remote)
+		rc.writeSimple("Background saving started")
+	case "lastsave":
+		rc.writeInt(int(db.lastSave.Unix()))
+	case "slaveof", "replicaof":
+		if len(args) != 3 {
+			rc.wrongArgs(cmd)
+			break
+		}
+		if strings.EqualFold(args[1], "no") && strings.EqualFold(args[2], "one") {
+			db.master = ""
+			rc.writeSimple("OK")
+			break
+		}
+		if _, err := strconv.Atoi(args[2]); err != nil {
+			rc.writeError("ERR Invalid master port")
+			break
+		}
+		db.master = net.JoinHostPort(args[1], args[2])
+		log.Printf("[Redis] %s: IOC replication master set to %s", rc.remote, db.master)
+		rc.writeSimple("OK")
+	case "module":
+		rc.handleModule(args)
+	case "client":
+		if len(args) > 1 && strings.EqualFold(args[1], "list") {
+			rc.writeBulk(fmt.Sprintf("id=3 addr=%s fd=8 name= age=0 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=26 qbuf-free=32742 obl=0 oll=0 omem=0 events=r cmd=client user=default\n", rc.conn.RemoteAddr()))
+		} else if len(args) > 1 && strings.EqualFold(args[1], "getname") {
+			rc.writeNil()
+		} else {
+			rc.writeSimple("OK")
+		}
+	case "command":
+		rc.w.WriteString("*0\r\n")
+	case "time":
+		now := time.Now()
+		rc.writeArray([]string{strconv.FormatInt(now.Unix(), 10), strconv.Itoa(now.Nanosecond() / 1000)})
+	case "shutdown":
+		// 假装关闭，断开连接即可
+		return false
+	default:
+		rc.unknownCommand(args)
+	}
+	return true
+}
+
+func (rc *redisConn) unknownCommand(args []string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ERR unknown command `%s`, with args beginning with: ", args[0])
+	for _, a := range args[1:] {
+		fmt.Fprintf(&b, "`%s`, ", a)
+	}
+	rc.writeError(b.String())
+}
+
+func (rc *redisConn) handleConfig(args []string) {
+	db := rc.db
+	if len(args) < 2 {
+		rc.wrongArgs("config")
+		return
+	}
+	switch strings.ToLower(args[1]) {
+	case "get":
+		if len(args) != 3 {
+			rc.wrongArgs("config|get")
+			return
+		}
+		var keys []string
+		for k := range db.config {
+			if ok, _ := path.Match(strings.ToLower(args[2]), k); ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var res []string
+		for _, k := range keys {
+			res = append(res, k, db.config[k])
+		}
+		rc.writeArray(res)
+	case "set":
+		if len(args) != 4 {
+			rc.wrongArgs("config|set")
+			return
+		}
+		key := strings.ToLower(args[2])
+		if _, ok := db.config[key]; !ok {
+			rc.writeError(fmt.Sprintf("ERR Unsupported CONFIG parameter: %s", args[2]))
+			return
+		}
+		if key == "dir" {
+			fs := db.sessionFS()
+			dir := args[3]
+			if !strings.HasPrefix(dir, "/") {
+				dir = path.Join(db.config["dir"], dir)
+			}
+			dir = path.Clean(dir)
+			if e, ok := fs.GetEntry(dir); !ok {
+				rc.writeError("ERR Changing directory: No such file or directory")
+				return
+			} else if !e.IsDir {
+				rc.writeError("ERR Changing directory: Not a directory")
+				return
+			}
+			args[3] = dir
+		}
+		if key == "dbfilename" && strings.Contains(args[3], "/") {
+			rc.writeError("ERR dbfilename can't be a path, just a filename")
+			return
+		}
+		db.config[key] = args[3]
+		if key == "dir" || key == "dbfilename" {
+			log.Printf("[Redis] %s: IOC config %s = %q", rc.remote, key, args[3])
+		}
+		rc.writeSimple("OK")
+	case "resetstat", "rewrite":
+		rc.writeSimple("OK")
+	default:
+		rc.writeError("ERR Unknown subcommand or wrong number of arguments for '" + args[1] + "'. Try CONFIG HELP.")
+	}
+}
+
+func (rc *redisConn) handleModule(args []string) {
+	db := rc.db
+	if len(args) < 2 {
+		rc.wrongArgs("module")
+		return
+	}
+	switch strings.ToLower(args[1]) {
+	case "load":
+		if len(args) < 3 {
+			rc.wrongArgs("module|load")
+			return
+		}
+		fs := db.sessionFS()
+		p := args[2]
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join(db.config["dir"], p)
+		}
+		e, ok := fs.GetEntry(p)
+		if !ok || e.IsDir {
+			rc.writeError("ERR Error loading the extension. Please check the server logs.")
+			return
+		}
+		e.mu.RLock()
+		CapturePayload("redis-module", rc.remote, p, e.Content)
+		e.mu.RUnlock()
+		// 常见的 rogue-server 模块都注册为 "system"
+		db.modules["system"] = p
+		log.Printf("[Redis] %s: IOC module loaded from %s", rc.remote, p)
+		rc.writeSimple("OK")
+	case "list":
+		fmt.Fprintf(rc.w, "*%d\r\n", len(db.modules))
+		for name := range db.modules {
+			rc.w.WriteString("*4\r\n")
+			rc.writeBulk("name")
+			rc.writeBulk(name)
+			rc.writeBulk("ver")
+			rc.writeInt(1)
+		}
+	case "unload":
+		if len(args) < 3 {
+			rc.wrongArgs("module|unload")
+			return
+		}
+		if _, ok := db.modules[args[2]]; !ok {
+			rc.writeError("ERR Error unloading module: no such module with that name")
+			return
+		}
+		delete(db.modules, args[2])
+		rc.writeSimple("OK")
+	default:
+		rc.writeError("ERR MODULE subcommand must be one of LOAD, UNLOAD or LIST")
+	}
+}
+
+// handleSystemModule 模拟 rogue 模块提供的命令执行，只在读取状态时持有 db.mu
+func (rc *redisConn) handleSystemModule(cmd string, args []string) {
+	db := rc.db
+	db.mu.Lock()
+	_, loaded := db.modules["system"]
+	fs, dir := db.sessionFS(), db.config["dir"]
+	db.mu.Unlock()
+	if !loaded {
+		rc.unknownCommand(args)
+		return
+	}
+	if cmd == "system.rev" {
+		log.Printf("[Redis] %s: IOC reverse shell requested to %q", rc.remote, args[1:])
+		rc.writeSimple("OK")
+		return
+	}
+	if len(args) < 2 {
+		rc.wrongArgs(cmd)
+		return
+	}
+	log.Printf("[Redis] %s: system.exec %q", rc.remote, args[1])
+	out := RunShellCommand(fs, rc.remote, "root", dir, args[1])
+	rc.writeBulk(string(out))
+}
+
+// save 将数据以 RDB 格式写入 dir/dbfilename (对攻击者可见)，remote 为发起保存的客户端
+// 调用方需持有 s.mu
+func (s *redisStore) save(remote string) error {
+	p := path.Join(s.config["dir"], s.config["dbfilename"])
+	if err := s.sessionFS().From(remote).Write(p, encodeRDB(s.data), 0644); err != nil {
+		return err
+	}
+	s.lastSave = time.Now()
+	log.Printf("[Redis] SAVE wrote %s (%d keys)", p, len(s.data))
+	return nil
+}
+
+func encodeRDB(data map[string]string) []byte {
+	var b bytes.Buffer
+	b.WriteString("REDIS0009")
+	aux := func(k, v string) {
+		b.WriteByte(0xFA)
+		rdbString(&b, k)
+		rdbString(&b, v)
+	}
+	aux("redis-ver", HostPersona.RedisVersion)
+	aux("redis-bits", "64")
+	aux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	aux("used-mem", "866176")
+	aux("aof-preamble", "0")
+
+	b.WriteByte(0xFE) // SELECTDB
+	b.WriteByte(0)
+	b.WriteByte(0xFB) // RESIZEDB
+	rdbLen(&b, len(data))
+	rdbLen(&b, 0)
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(0) // 字符串类型
+		rdbString(&b, k)
+		rdbString(&b, data[k])
+	}
+	b.WriteByte(0xFF)
+	b.Write(make([]byte, 8)) // 校验和 (0 表示未校验)
+	return b.Bytes()
+}
+
+func rdbLen(b *bytes.Buffer, n int) {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(byte(0x40 | n>>8))
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(0x80)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
+
+func rdbString(b *bytes.Buffer, s string) {
+	rdbLen(b, len(s))
+	b.WriteString(s)
+}
+
+// info 生成 INFO 输出，调用方需持有 s.mu
+func (s *redisStore) info(section string) string {
+	uptime := int(time.Since(startTime).Seconds())
+	role := "role:master\r\nconnected_slaves:0\r\n"
+	if s.master != "" {
+		host, port, _ := net.SplitHostPort(s.master)
+		role = fmt.Sprintf("role:slave\r\nmaster_host:%s\r\nmaster_port:%s\r\nmaster_link_status:down\r\nmaster_last_io_seconds_ago:-1\r\nmaster_sync_in_progress:0\r\nslave_repl_offset:1\r\nslave_priority:100\r\nslave_read_only:1\r\nconnected_slaves:0\r\n", host, port)
+	}
+	keyspace := ""
+	if len(s.data) > 0 {
+		keyspace = fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", len(s.data))
+	}
+	sections := []struct{ name, body string }{
+		{"server", fmt.Sprintf("redis_version:%s\r\nredis_git_sha1:00000000\r\nredis_git_dirty:0\r\nredis_build_id:a3fdef44459b3ad6\r\nredis_mode:standalone\r\nos:Linux %s %s\r\narch_bits:64\r\nmultiplexing_api:epoll\r\natomicvar_api:atomic-builtin\r\ngcc_version:11.2.0\r\nprocess_id:%d\r\nrun_id:1d8a0e3b4c5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b\r\ntcp_port:6379\r\nuptime_in_seconds:%d\r\nuptime_in_days:%d\r\nhz:10\r\nconfigured_hz:10\r\nlru_clock:%d\r\nexecutable:/usr/bin/redis-server\r\nconfig_file:/etc/redis/redis.conf\r\nio_threads_active:0\r\n",
+			HostPersona.RedisVersion, HostPersona.Kernel, HostPersona.Arch, 917, uptime, uptime/86400, time.Now().Unix()&0xFFFFFF)},
+		{"clients", "connected_clients:1\r\nclient_recent_max_input_buffer:8\r\nclient_recent_max_output_buffer:0\r\nblocked_clients:0\r\ntracking_clients:0\r\nclients_in_timeout_table:0\r\n"},
+		{"memory", "used_memory:866176\r\nused_memory_human:845.88K\r\nused_memory_rss:5976064\r\nused_memory_rss_human:5.70M\r\nused_memory_peak:866176\r\nused_memory_peak_human:845.88K\r\ntotal_system_memory:16694562816\r\ntotal_system_memory_human:15.55G\r\nmaxmemory:0\r\nmaxmemory_human:0B\r\nmaxmemory_policy:noeviction\r\nmem_fragmentation_ratio:6.90\r\nmem_allocator:jemalloc-5.2.1\r\n"},
+		{"persistence", fmt.Sprintf("loading:0\r\nrdb_changes_since_last_save:0\r\nrdb_bgsave_in_progress:0\r\nrdb_last_save_time:%d\r\nrdb_last_bgsave_status:ok\r\naof_enabled:0\r\naof_rewrite_in_progress:0\r\n", s.lastSave.Unix())},
+		{"stats", "total_connections_received:12\r\ntotal_commands_processed:37\r\ninstantaneous_ops_per_sec:0\r\nrejected_connections:0\r\nexpired_keys:0\r\nevicted_keys:0\r\nkeyspace_hits:3\r\nkeyspace_misses:1\r\n"},
+		{"replication", role + "master_replid:8f1e4c2b7a9d3e5f6a1b2c3d4e5f6a7b8c9d0e1f\r\nmaster_repl_offset:0\r\n"},
+		{"cpu", "used_cpu_sys:1.218640\r\nused_cpu_user:0.913980\r\nused_cpu_sys_children:0.000000\r\nused_cpu_user_children:0.000000\r\n"},
+		{"modules", ""},
+		{"keyspace", keyspace},
+	}
+	var b strings.Builder
+	for _, sec := range sections {
+		if section != "" && section != "all" && section != "default" && section != "everything" && section != sec.name {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(sec.name[:1]) + sec.name[1:] + "\r\n" + sec.body)
+	}
+	return b.String()
+}
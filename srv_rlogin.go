@@ -2,16 +2,27 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
+	"errors"
+	"io"
 	"log"
 	"net"
+	"path"
 	"strings"
 	"time"
 )
 
 const (
 	RLoginBindAddr = "0.0.0.0:5130"
+
+	// TIOCPKT 控制字节 (通过 TCP 紧急数据发送给客户端)
+	tiocpktFlushWrite = 0x02 // 丢弃客户端尚未输出的数据
+	tiocpktNoStop     = 0x10 // 关闭本地 ^S/^Q 流控 (raw 模式)
+	tiocpktDoStop     = 0x20 // 开启本地 ^S/^Q 流控 (cooked 模式)
+	tiocpktWindow     = 0x80 // 请求客户端开始报告窗口大小
+
+	// 握手字段的最大长度，防止恶意客户端发送超长字段耗尽内存
+	maxRFieldLen = 1024
 )
 
 func runRLoginServer() {
@@ -40,23 +51,79 @@ type RLoginStream struct {
 	winSizeBuf    []byte
 	initialWidth  int // 修复：用于缓存在 Terminal 创建前的窗口宽度
 	initialHeight int // 修复：用于缓存在 Terminal 创建前的窗口高度
+
+	// 客户端转义 (~.) 处理
+	atLineStart bool
+	sawTilde    bool
+	pending     []byte
+	rawMode     bool
+}
+
+// syncFlowControl 终端进入/退出全屏应用时通知客户端切换本地流控
+func (rs *RLoginStream) syncFlowControl() {
+	if rs.term == nil {
+		return
+	}
+	rs.term.mu.Lock()
+	raw := rs.term.RawModeWriter != nil
+	rs.term.mu.Unlock()
+	if raw == rs.rawMode {
+		return
+	}
+	rs.rawMode = raw
+	if raw {
+		sendUrgent(rs.conn, tiocpktNoStop)
+	} else {
+		sendUrgent(rs.conn, tiocpktDoStop)
+	}
 }
 
 func (rs *RLoginStream) Read(p []byte) (n int, err error) {
+	if len(rs.pending) > 0 {
+		p[0] = rs.pending[0]
+		rs.pending = rs.pending[1:]
+		return 1, nil
+	}
 	for {
 		b, err := rs.reader.ReadByte()
 		if err != nil {
 			return 0, err
 		}
+		rs.syncFlowControl()
 
 		switch rs.state {
 		case 0:
 			if b == 0xFF {
 				rs.state = 1
-			} else {
-				p[0] = b
+				continue
+			}
+			// 行首的 "~." 表示客户端要求断开连接
+			if rs.sawTilde {
+				rs.sawTilde = false
+				if b == '.' {
+					return 0, io.EOF
+				}
+				rs.pending = append(rs.pending, b)
+				rs.atLineStart = b == '\r' || b == '\n'
+				p[0] = '~'
 				return 1, nil
 			}
+			if rs.atLineStart && b == '~' {
+				rs.sawTilde = true
+				continue
+			}
+			switch b {
+			case '\r', '\n', 3, 21: // 回车、Ctrl+C、Ctrl+U 之后视为新行
+				rs.atLineStart = true
+			default:
+				rs.atLineStart = false
+			}
+			if b == 3 {
+				// 中断时和真实 rlogind 一样通知客户端丢弃待输出数据
+				sendUrgent(rs.conn, tiocpktFlushWrite)
+			}
+			p[0] = b
+			return 1, nil
 		case 1:
 			if b == 0xFF {
 				rs.state = 2
@@ -101,33 +168,33 @@ func (rs *RLoginStream) Write(p []byte) (n int, err error) {
 }
 
 func handleRLoginConn(c net.Conn) {
+	defer recoverConn(c)
 	defer c.Close()
+	defer trackConn(c, 513)()
 	reader := bufio.NewReader(c)
 
-	var clientUser, serverUser, termInfo []byte
-	var err error
-	_, err = reader.ReadBytes(0)
-	if err != nil {
+	if _, err := readRField(reader); err != nil {
 		return
 	}
-	clientUser, err = reader.ReadBytes(0)
+	clientUser, err := readRField(reader)
 	if err != nil {
 		return
 	}
-	serverUser, err = reader.ReadBytes(0)
+	serverUser, err := readRField(reader)
 	if err != nil {
 		return
 	}
-	termInfo, err = reader.ReadBytes(0)
+	termInfo, err := readRField(reader)
 	if err != nil {
 		return
 	}
 
 	c.Write([]byte{0})
+	// 与 rlogind 一致：接受连接后立即请求窗口大小报告
+	sendUrgent(c, tiocpktWindow)
 
 	termType := "vt100"
-	termStr := string(bytes.TrimRight(termInfo, "\x00"))
-	if parts := strings.Split(termStr, "/"); len(parts) > 0 {
+	if parts := strings.Split(termInfo, "/"); len(parts) > 0 && parts[0] != "" {
 		termType = parts[0]
 	}
 
@@ -136,25 +203,178 @@ func handleRLoginConn(c net.Conn) {
 		reader:        reader,
 		initialWidth:  80, // 设置默认值
 		initialHeight: 24, // 设置默认值
+		atLineStart:   true,
 	}
 
-	c.Write([]byte("Password: "))
-	readLine(rs)
-	c.Write([]byte("\r\n"))
-
 	fs := GlobalSessionFS
+	remoteHost := remoteIP(c.RemoteAddr())
+	log.Printf("[RLogin] %s: %s -> %s (%s)", remoteHost, clientUser, serverUser, termInfo)
 
-	env := map[string]string{
-		"TERM":  termType,
-		"USER":  string(bytes.TrimRight(clientUser, "\x00")),
-		"SHELL": "/bin/bash",
-	}
-	if string(bytes.TrimRight(serverUser, "\x00")) == "root" {
-		env["USER"] = "root"
+	if rhostsTrusted(fs, serverUser, clientUser, remoteHost) {
+		log.Printf("[RLogin] %s: trusted via rhosts, skipping password", remoteHost)
+	} else {
+		c.Write([]byte("Password: "))
+		pass := readLine(rs)
+		c.Write([]byte("\r\n"))
+		if !Auth.Check("rlogin", remoteHost, serverUser, pass) {
+			c.Write([]byte("Login incorrect\r\n"))
+			return
+		}
 	}
 
+	env := rEnv(fs, serverUser)
+	env["TERM"] = termType
+
 	// 修复：使用协商后缓存的尺寸创建 Terminal
 	term := NewTerminal(rs, fs, env, rs.initialWidth, rs.initialHeight)
+	term.Remote = remoteIP(c.RemoteAddr())
 	rs.term = term
 	term.Run()
 }
+
+// readRField 读取 r* 协议中以 NUL 结尾的字段
+func readRField(r *bufio.Reader) (string, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == 0 {
+			return string(buf), nil
+		}
+		if len(buf) >= maxRFieldLen {
+			return "", errors.New("field too long")
+		}
+		buf = append(buf, b)
+	}
+}
+
+// remoteIP 返回连接对端的 IP (不含端口)
+func remoteIP(addr net.Addr) string {
+	s := addr.String()
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return s
+}
+
+// rEnv 为 r* 服务构造登录环境，HOME 取自会话中的 /etc/passwd
+func rEnv(fs *SessionFS, user string) map[string]string {
+	if user == "" {
+		user = "root"
+	}
+	home, ok := lookupHome(fs, user)
+	if !ok {
+		home = "/home/" + user
+	}
+	return map[string]string{
+		"TERM":  "vt100",
+		"PATH":  "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"USER":  user,
+		"HOME":  home,
+		"SHELL": "/bin/bash",
+	}
+}
+
+// lookupHome 在会话的 /etc/passwd 中查找用户主目录
+func lookupHome(fs *SessionFS, user string) (string, bool) {
+	e, ok := fs.GetEntry("/etc/passwd")
+	if !ok {
+		return "", false
+	}
+	for _, line := range strings.Split(string(e.Content), "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) > 5 && parts[0] == user {
+			return parts[5], true
+		}
+	}
+	return "", false
+}
+
+// rhostsTrusted 按 ruserok(3) 的语义检查 /etc/hosts.equiv 与 ~/.rhosts
+// root 不参考 hosts.equiv；权限过宽或属主不对的 .rhosts 会被忽略
+func rhostsTrusted(fs *SessionFS, localUser, remoteUser, remoteHost string) bool {
+	names := hostNames(fs, remoteHost)
+
+	if localUser != "root" {
+		if e, ok := fs.GetEntry("/etc/hosts.equiv"); ok && !e.IsDir {
+			if trusted, _ := matchRhosts(string(e.Content), localUser, remoteUser, names); trusted {
+				return true
+			}
+		}
+	}
+
+	home, ok := lookupHome(fs, localUser)
+	if !ok {
+		return false
+	}
+	e, ok := fs.GetEntry(path.Join(home, ".rhosts"))
+	if !ok || e.IsDir || e.Mode&0022 != 0 {
+		return false
+	}
+	if uid := fs.uid(localUser); uid >= 0 && e.UID != uid && e.UID != 0 {
+		return false
+	}
+	trusted, _ := matchRhosts(string(e.Content), localUser, remoteUser, names)
+	return trusted
+}
+
+// matchRhosts 逐行匹配 "host [user]"，支持 "+" 通配与 "-" 否定
+// 返回值 matched 表示是否有条目命中 (包括否定条目)
+func matchRhosts(content, localUser, remoteUser string, names []string) (trusted, matched bool) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		host, hostNeg := fields[0], false
+		if strings.HasPrefix(host, "-") {
+			host, hostNeg = host[1:], true
+		}
+		hostOK := host == "+"
+		for _, n := range names {
+			if strings.EqualFold(host, n) {
+				hostOK = true
+			}
+		}
+		if !hostOK {
+			continue
+		}
+		if hostNeg {
+			return false, true
+		}
+
+		if len(fields) < 2 {
+			if remoteUser == localUser {
+				return true, true
+			}
+			continue
+		}
+		user, userNeg := fields[1], false
+		if strings.HasPrefix(user, "-") {
+			user, userNeg = user[1:], true
+		}
+		if user == "+" || user == remoteUser {
+			return !userNeg, true
+		}
+	}
+	return false, false
+}
+
+// hostNames 返回远端 IP 本身以及 /etc/hosts 中映射到该 IP 的主机名
+func hostNames(fs *SessionFS, ip string) []string {
+	names := []string{ip}
+	e, ok := fs.GetEntry("/etc/hosts")
+	if !ok {
+		return names
+	}
+	for _, line := range strings.Split(string(e.Content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == ip {
+			names = append(names, fields[1:]...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	RShBindAddr   = "0.0.0.0:5140" // rsh (shell, 514)
+	RExecBindAddr = "0.0.0.0:5120" // rexec (exec, 512)
+)
+
+func runRShServer() {
+	ln, err := net.Listen("tcp", RShBindAddr)
+	if err != nil {
+		log.Printf("[RSh] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[RSh] Server listening on %s", RShBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleRShConn(c)
+	}
+}
+
+func runRExecServer() {
+	ln, err := net.Listen("tcp", RExecBindAddr)
+	if err != nil {
+		log.Printf("[RExec] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[RExec] Server listening on %s", RExecBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleRExecConn(c)
+	}
+}
+
+// handleRShConn 处理 rsh: stderr端口\0 客户端用户\0 服务端用户\0 命令\0
+// rsh 没有密码，只能依赖 .rhosts / hosts.equiv 信任关系
+func handleRShConn(c net.Conn) {
+	defer recoverConn(c)
+	defer c.Close()
+	defer trackConn(c, 514)()
+	reader := bufio.NewReader(c)
+
+	stderrPort, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	clientUser, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	serverUser, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	cmd, err := readRField(reader)
+	if err != nil {
+		return
+	}
+
+	fs := GlobalSessionFS
+	remoteHost := remoteIP(c.RemoteAddr())
+	// 不回连 stderr 端口，错误输出与标准输出合并
+	log.Printf("[RSh] %s: %s -> %s (stderr port %q): %q", remoteHost, clientUser, serverUser, stderrPort, cmd)
+
+	if !rhostsTrusted(fs, serverUser, clientUser, remoteHost) {
+		c.Write([]byte("\x01Permission denied.\n"))
+		return
+	}
+	c.Write([]byte{0})
+	runRemoteCommand(c, fs, serverUser, cmd)
+}
+
+// handleRExecConn 处理 rexec: stderr端口\0 用户\0 密码\0 命令\0
+func handleRExecConn(c net.Conn) {
+	defer recoverConn(c)
+	defer c.Close()
+	defer trackConn(c, 512)()
+	reader := bufio.NewReader(c)
+
+	stderrPort, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	user, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	pass, err := readRField(reader)
+	if err != nil {
+		return
+	}
+	cmd, err := readRField(reader)
+	if err != nil {
+		return
+	}
+
+	remoteHost := remoteIP(c.RemoteAddr())
+	log.Printf("[RExec] %s: %s (stderr port %q): %q", remoteHost, user, stderrPort, cmd)
+
+	if !Auth.Check("rexec", remoteHost, user, pass) {
+		c.Write([]byte("\x01Login incorrect.\n"))
+		return
+	}
+	c.Write([]byte{0})
+	runRemoteCommand(c, GlobalSessionFS, user, cmd)
+}
+
+// runRemoteCommand 复用 Shell 管道执行单条命令 (与 SSH exec 相同的会话流程)
+func runRemoteCommand(c net.Conn, fs *SessionFS, user, cmd string) {
+	term := NewTerminal(c, fs, rEnv(fs, user), 80, 24)
+	term.Remote = remoteIP(c.RemoteAddr())
+	term.Exec(cmd)
+}
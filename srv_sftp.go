@@ -35,8 +35,9 @@ func (h *SFTPHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
 
 // Filewrite implements sftp.FileWriter
 func (h *SFTPHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
-	// 创建写入器
-	return &SFTPWriter{fs: h.fs, path: r.Filepath}, nil
+	// 创建写入器，持久化位置在关闭时检测变化
+	old, watched := h.fs.watched(r.Filepath)
+	return &SFTPWriter{fs: h.fs, path: r.Filepath, old: old, watched: watched}, nil
 }
 
 // Filecmd implements sftp.FileCmder (Mkdir, Rmdir, Rename, Chmod, etc.)
@@ -86,8 +87,10 @@ func (h *SFTPHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
 
 // SFTPWriter 优化版：避免持有全局锁，解决大文件卡顿和Panic问题
 type SFTPWriter struct {
-	fs   *SessionFS
-	path string
+	fs      *SessionFS
+	path    string
+	old     []byte // 持久化位置写入前的内容
+	watched bool
 	// 不再在 Writer 内部维护 buf，直接操作 FileEntry
 }
 
@@ -170,6 +173,20 @@ func (w *SFTPWriter) WriteAt(p []byte, off int64) (int, error) {
 	return len(p), nil
 }
 
+// Close 上传结束后检测持久化位置的变化
+func (w *SFTPWriter) Close() error {
+	if !w.watched {
+		return nil
+	}
+	if e, ok := w.fs.GetEntry(w.path); ok {
+		e.mu.RLock()
+		data := append([]byte(nil), e.Content...)
+		e.mu.RUnlock()
+		persistEvent(w.fs.remote(), "write", w.path, w.old, data)
+	}
+	return nil
+}
+
 // Adapter for os.FileInfo to satisfy sftp.ListerAt
 type fileInfo struct{ e *FileEntry }
 
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SMTPBindAddr = "0.0.0.0:2500"
+
+	smtpIdleTimeout = 5 * time.Minute
+	maxSMTPLineLen  = 2048
+	maxSMTPRcpts    = 100
+	maxMIMEDepth    = 10
+)
+
+// SMTPOfferTLS 为 false 时只宣告 STARTTLS，实际握手请求会被拒绝
+var SMTPOfferTLS = true
+
+func runSMTPServer() {
+	ln, err := net.Listen("tcp", SMTPBindAddr)
+	if err != nil {
+		log.Printf("[SMTP] Failed to listen: %v", err)
+		return
+	}
+	log.Printf("[SMTP] Server listening on %s", SMTPBindAddr)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		go handleSMTPConn(c)
+	}
+}
+
+var (
+	smtpTLSOnce   sync.Once
+	smtpTLSConfig *tls.Config
+)
+
+// smtpTLS 首次使用时生成以主机名为 CN 的自签名证书
+func smtpTLS() *tls.Config {
+	smtpTLSOnce.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			log.Printf("[SMTP] Failed to generate TLS key: %v", err)
+			return
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: HostPersona.Hostname},
+			DNSNames:     []string{HostPersona.Hostname},
+			NotBefore:    startTime.AddDate(-1, 0, 0),
+			NotAfter:     startTime.AddDate(9, 0, 0),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			log.Printf("[SMTP] Failed to create certificate: %v", err)
+			return
+		}
+		smtpTLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		}
+	})
+	return smtpTLSConfig
+}
+
+type smtpSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	fs     *SessionFS
+	remote string
+
+	helo     string
+	esmtp    bool
+	tls      bool
+	authUser string
+	from     string
+	rcpts    []string
+	hasFrom  bool
+}
+
+func handleSMTPConn(c net.Conn) {
+	defer recoverConn(c)
+	defer trackConn(c, 25)()
+	s := &smtpSession{
+		conn:   c,
+		reader: bufio.NewReader(c),
+		fs:     GlobalSessionFS.From(remoteIP(c.RemoteAddr())),
+		remote: remoteIP(c.RemoteAddr()),
+	}
+	// STARTTLS 之后 s.conn 会被替换为 TLS 连接
+	defer func() { s.conn.Close() }()
+	log.Printf("[SMTP] %s: connected", s.remote)
+	s.serve()
+}
+
+func (s *smtpSession) serve() {
+	s.reply(220, HostPersona.Hostname+" "+HostPersona.SMTPBanner)
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(smtpIdleTimeout))
+		line, err := s.readLine()
+		if err != nil {
+			if err != io.EOF {
+				s.reply(421, "4.4.2 "+HostPersona.Hostname+" Error: timeout exceeded")
+			}
+			return
+		}
+		if line == "" {
+			s.reply(500, "5.5.2 Error: bad syntax")
+			continue
+		}
+		log.Printf("[SMTP] %s: %s", s.remote, line)
+
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if !s.handle(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+func (s *smtpSession) readLine() (string, error) {
+	var buf []byte
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			break
+		}
+		if len(buf) < maxSMTPLineLen {
+			buf = append(buf, b)
+		}
+	}
+	return strings.TrimRight(string(buf), "\r"), nil
+}
+
+func (s *smtpSession) reply(code int, msg string) {
+	fmt.Fprintf(s.conn, "%d %s\r\n", code, msg)
+}
+
+func (s *smtpSession) reset() {
+	s.from, s.rcpts, s.hasFrom = "", nil, false
+}
+
+// handle 处理一条命令，返回 false 表示关闭连接
+func (s *smtpSession) handle(cmd, arg string) bool {
+	switch cmd {
+	case "HELO", "EHLO":
+		if arg == "" {
+			s.reply(501, "Syntax: "+cmd+" hostname")
+			return true
+		}
+		s.helo, s.esmtp = arg, cmd == "EHLO"
+		s.reset()
+		if !s.esmtp {
+			s.reply(250, HostPersona.Hostname)
+			return true
+		}
+		exts := []string{HostPersona.Hostname, "PIPELINING", fmt.Sprintf("SIZE %d", MaxFileSize), "VRFY", "ETRN"}
+		if !s.tls {
+			exts = append(exts, "STARTTLS")
+		}
+		exts = append(exts, "AUTH PLAIN LOGIN", "AUTH=PLAIN LOGIN", "ENHANCEDSTATUSCODES", "8BITMIME", "DSN", "SMTPUTF8")
+		for i, e := range exts {
+			sep := "-"
+			if i == len(exts)-1 {
+				sep = " "
+			}
+			fmt.Fprintf(s.conn, "250%s%s\r\n", sep, e)
+		}
+	case "STARTTLS":
+		if s.tls {
+			s.reply(554, "5.5.1 Error: TLS already active")
+			return true
+		}
+		cfg := smtpTLS()
+		if !SMTPOfferTLS || cfg == nil {
+			s.reply(454, "4.7.0 TLS not available due to local problem")
+			return true
+		}
+		s.reply(220, "2.0.0 Ready to start TLS")
+		tc := tls.Server(s.conn, cfg)
+		tc.SetDeadline(time.Now().Add(30 * time.Second))
+		if err := tc.Handshake(); err != nil {
+			log.Printf("[SMTP] %s: TLS handshake failed: %v", s.remote, err)
+			return false
+		}
+		tc.SetDeadline(time.Time{})
+		log.Printf("[SMTP] %s: TLS established (%s)", s.remote, tls.CipherSuiteName(tc.ConnectionState().CipherSuite))
+		// RFC 3207: 握手后必须丢弃之前的会话状态
+		s.conn, s.reader, s.tls = tc, bufio.NewReader(tc), true
+		s.helo, s.esmtp, s.authUser = "", false, ""
+		s.reset()
+	case "AUTH":
+		s.handleAuth(arg)
+	case "MAIL":
+		if s.helo == "" {
+			s.reply(503, "5.5.1 Error: send HELO/EHLO first")
+			return true
+		}
+		if s.hasFrom {
+			s.reply(503, "5.5.1 Error: nested MAIL command")
+			return true
+		}
+		addr, ok := smtpPath(arg, "FROM:")
+		if !ok {
+			s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+			return true
+		}
+		s.from, s.hasFrom = addr, true
+		s.reply(250, "2.1.0 Ok")
+	case "RCPT":
+		if !s.hasFrom {
+			s.reply(503, "5.5.1 Error: need MAIL command")
+			return true
+		}
+		addr, ok := smtpPath(arg, "TO:")
+		if !ok || addr == "" {
+			s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+			return true
+		}
+		if len(s.rcpts) >= maxSMTPRcpts {
+			s.reply(452, "4.5.3 Error: too many recipients")
+			return true
+		}
+		s.rcpts = append(s.rcpts, addr)
+		// 假装是开放中继：任何域都接受
+		s.reply(250, "2.1.5 Ok")
+	case "DATA":
+		if !s.hasFrom {
+			s.reply(503, "5.5.1 Error: need MAIL command")
+			return true
+		}
+		if len(s.rcpts) == 0 {
+			s.reply(554, "5.5.1 Error: no valid recipients")
+			return true
+		}
+		s.reply(354, "End data with <CR><LF>.<CR><LF>")
+		body, tooBig, err := s.readData()
+		if err != nil {
+			return false
+		}
+		if tooBig {
+			s.reply(552, "5.3.4 Error: message file too big")
+		} else {
+			s.reply(250, "2.0.0 Ok: queued as "+s.deliver(body))
+		}
+		s.reset()
+	case "RSET":
+		s.reset()
+		s.reply(250, "2.0.0 Ok")
+	case "NOOP":
+		s.reply(250, "2.0.0 Ok")
+	case "VRFY":
+		if arg == "" {
+			s.reply(501, "5.5.4 Syntax: VRFY address")
+			return true
+		}
+		s.reply(252, "2.0.0 "+arg)
+	case "ETRN":
+		s.reply(250, "Queuing started")
+	case "QUIT":
+		s.reply(221, "2.0.0 Bye")
+		return false
+	default:
+		s.reply(502, "5.5.2 Error: command not recognized")
+	}
+	return true
+}
+
+// smtpPath 解析 "FROM:<addr> SIZE=..." 形式的参数
+func smtpPath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if strings.HasPrefix(arg, "<") {
+		end := strings.IndexByte(arg, '>')
+		if end < 0 {
+			return "", false
+		}
+		return arg[1:end], true
+	}
+	// 兼容不带尖括号的写法
+	if f := strings.Fields(arg); len(f) > 0 {
+		return f[0], true
+	}
+	return "", false
+}
+
+func (s *smtpSession) handleAuth(arg string) {
+	if s.authUser != "" {
+		s.reply(503, "5.5.1 Error: already authenticated")
+		return
+	}
+	mech, initial, _ := strings.Cut(arg, " ")
+	var user, pass string
+	switch strings.ToUpper(mech) {
+	case "PLAIN":
+		if initial == "" {
+			s.reply(334, "")
+			line, err := s.readLine()
+			if err != nil {
+				return
+			}
+			initial = line
+		}
+		if initial == "*" {
+			s.reply(501, "5.7.0 Authentication aborted")
+			return
+		}
+		raw, err := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(raw), "\x00")
+		if err != nil || len(parts) != 3 {
+			s.reply(535, "5.7.8 Error: authentication failed: Invalid base64 data in initial response")
+			return
+		}
+		user, pass = parts[1], parts[2]
+	case "LOGIN":
+		var fields [2]string
+		prompts := []string{"VXNlcm5hbWU6", "UGFzc3dvcmQ6"} // "Username:" / "Password:"
+		for i := range fields {
+			line := initial
+			if i > 0 || line == "" {
+				s.reply(334, prompts[i])
+				var err error
+				if line, err = s.readLine(); err != nil {
+					return
+				}
+			}
+			if line == "*" {
+				s.reply(501, "5.7.0 Authentication aborted")
+				return
+			}
+			raw, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				s.reply(535, "5.7.8 Error: authentication failed: Invalid base64 data in continued response")
+				return
+			}
+			fields[i] = string(raw)
+		}
+		user, pass = fields[0], fields[1]
+	default:
+		s.reply(535, "5.7.8 Error: authentication failed: Invalid authentication mechanism")
+		return
+	}
+
+	if !Auth.Check("smtp", s.remote, user, pass) {
+		s.reply(535, "5.7.8 Error: authentication failed: authentication failure")
+		return
+	}
+	s.authUser = user
+	s.reply(235, "2.7.0 Authentication successful")
+}
+
+// readData 读取 DATA 内容直到单独的 "."，并去掉透明点 (RFC 5321 4.5.2)
+func (s *smtpSession) readData() ([]byte, bool, error) {
+	var msg bytes.Buffer
+	tooBig := false
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(smtpIdleTimeout))
+		line, err := s.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			if !tooBig {
+				msg.Write(line)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if len(trimmed) == 1 && trimmed[0] == '.' {
+			break
+		}
+		if len(trimmed) > 0 && trimmed[0] == '.' {
+			line = line[1:]
+		}
+		if msg.Len()+len(line) > MaxFileSize {
+			tooBig = true
+		}
+		if !tooBig {
+			msg.Write(line)
+		}
+	}
+	return msg.Bytes(), tooBig, nil
+}
+
+// deliver 保存邮件样本、提取 IOC 并投递到本地邮箱，返回队列 ID
+func (s *smtpSession) deliver(body []byte) string {
+	idBytes := make([]byte, 5)
+	rand.Read(idBytes)
+	qid := strings.ToUpper(hex.EncodeToString(idBytes))
+
+	now := time.Now()
+	with := "ESMTP"
+	if s.tls {
+		with = "ESMTPS"
+	}
+	if s.authUser != "" {
+		with += "A"
+	}
+	received := fmt.Sprintf("Received: from %s (unknown [%s])\r\n\tby %s (Postfix) with %s id %s\r\n\tfor <%s>; %s\r\n",
+		s.helo, s.remote, HostPersona.Hostname, with, qid, s.rcpts[0], now.Format(time.RFC1123Z))
+	msg := append([]byte("Return-Path: <"+s.from+">\r\n"+received), body...)
+
+	log.Printf("[SMTP] %s: message %s from=<%s> rcpts=%q auth=%q size=%d", s.remote, qid, s.from, s.rcpts, s.authUser, len(body))
+	CapturePayload("smtp", s.remote, qid+".eml", msg)
+	s.extractIOCs(qid, body)
+
+	for _, rcpt := range s.rcpts {
+		if user, ok := s.localUser(rcpt); ok {
+			s.appendMbox(user, msg, now)
+		}
+	}
+	return qid
+}
+
+// localUser 判断收件人是否为本机用户
+func (s *smtpSession) localUser(rcpt string) (string, bool) {
+	local, domain, found := strings.Cut(rcpt, "@")
+	if found {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if d != "localhost" && d != strings.ToLower(HostPersona.Hostname) {
+			return "", false
+		}
+	}
+	local = strings.ToLower(local)
+	if local == "postmaster" || local == "abuse" {
+		local = "root"
+	}
+	if _, ok := lookupHome(s.fs, local); !ok {
+		return "", false
+	}
+	return local, true
+}
+
+// appendMbox 以 mbox 格式追加到 /var/mail/<user>
+func (s *smtpSession) appendMbox(user string, msg []byte, now time.Time) {
+	p := path.Join("/var/mail", user)
+	var existing []byte
+	if e, ok := s.fs.GetEntry(p); ok && !e.IsDir {
+		e.mu.RLock()
+		existing = append(existing, e.Content...)
+		e.mu.RUnlock()
+	}
+
+	var b bytes.Buffer
+	b.Write(existing)
+	from := s.from
+	if from == "" {
+		from = "MAILER-DAEMON"
+	}
+	fmt.Fprintf(&b, "From %s  %s\n", from, now.Format("Mon Jan _2 15:04:05 2006"))
+	for _, line := range strings.Split(strings.ReplaceAll(string(msg), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			b.WriteByte('>')
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	if err := s.fs.Write(p, b.Bytes(), 0660); err != nil {
+		log.Printf("[SMTP] %s: mailbox %s: %v", s.remote, p, err)
+		return
+	}
+	s.fs.Chown(p, s.fs.uid(user), s.fs.gid("mail"))
+}
+
+var reMailURL = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'()\[\]{}]+`)
+
+// extractIOCs 从邮件中提取 URL 和附件放入隔离区
+func (s *smtpSession) extractIOCs(qid string, body []byte) {
+	m, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[SMTP] %s: %s: unparsable message: %v", s.remote, qid, err)
+		return
+	}
+	log.Printf("[SMTP] %s: %s subject=%q from=%q", s.remote, qid, m.Header.Get("Subject"), m.Header.Get("From"))
+
+	var urls []string
+	seen := make(map[string]bool)
+	var walk func(h mail.Header, r io.Reader, depth int)
+	walk = func(h mail.Header, r io.Reader, depth int) {
+		ctype, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+		if err != nil {
+			ctype = "text/plain"
+		}
+		if strings.HasPrefix(ctype, "multipart/") && depth < maxMIMEDepth {
+			mr := multipart.NewReader(r, params["boundary"])
+			for {
+				part, err := mr.NextRawPart()
+				if err != nil {
+					return
+				}
+				walk(mail.Header(part.Header), part, depth+1)
+			}
+		}
+
+		data, _ := io.ReadAll(io.LimitReader(decodeTransfer(h.Get("Content-Transfer-Encoding"), r), MaxFileSize))
+		disp, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+		name := dparams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		if disp == "attachment" || name != "" {
+			if name == "" {
+				name = "attachment"
+			}
+			CapturePayload("smtp-attachment", s.remote, qid+"/"+name, data)
+			return
+		}
+		if strings.HasPrefix(ctype, "text/") {
+			for _, u := range reMailURL.FindAllString(string(data), -1) {
+				u = strings.TrimRight(u, ".,;:!?")
+				if !seen[u] {
+					seen[u] = true
+					urls = append(urls, u)
+				}
+			}
+		}
+	}
+	walk(m.Header, m.Body, 0)
+
+	if len(urls) > 0 {
+		for _, u := range urls {
+			log.Printf("[SMTP] %s: %s IOC url %s", s.remote, qid, u)
+		}
+		CapturePayload("smtp-urls", s.remote, qid+".urls", []byte(strings.Join(urls, "\n")+"\n"))
+	}
+}
+
+func decodeTransfer(enc string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(enc)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner 去掉 base64 正文中的换行和空白
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		j := 0
+		for _, b := range p[:n] {
+			if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/binary"
 	"encoding/pem"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -24,11 +25,14 @@ const (
 func runSSHServer() {
 	// SSH Config
 	config := &ssh.ServerConfig{
-		ServerVersion: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1",
+		ServerVersion: HostPersona.SSHVersion,
 		NoClientAuth:  false,
 		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
-			// 蜜罐模式：接受所有密码
-			return nil, nil
+			// 蜜罐模式：由统一认证策略决定 (默认接受所有密码)
+			if Auth.Check("ssh", remoteIP(c.RemoteAddr()), c.User(), string(pass)) {
+				return nil, nil
+			}
+			return nil, errors.New("permission denied")
 		},
 	}
 	config.AddHostKey(loadHostKey())
@@ -67,6 +71,8 @@ func loadHostKey() ssh.Signer {
 }
 
 func handleSSHConn(c net.Conn, cfg *ssh.ServerConfig) {
+	defer recoverConn(c)
+	defer trackConn(c, 22)()
 	_, chans, reqs, err := ssh.NewServerConn(c, cfg)
 	if err != nil {
 		return
@@ -98,6 +104,7 @@ func handleSSHConn(c net.Conn, cfg *ssh.ServerConfig) {
 		}
 
 		go func(in <-chan *ssh.Request, channel ssh.Channel) {
+			defer recoverConn(c)
 			cols, rows := 80, 24
 			var activeTerm *Terminal
 
@@ -145,8 +152,10 @@ func handleSSHConn(c net.Conn, cfg *ssh.ServerConfig) {
 					r.Reply(true, nil)
 					// 启动交互式 Shell
 					term := NewTerminal(channel, fs, env, cols, rows)
+					term.Remote = remoteIP(c.RemoteAddr())
 					activeTerm = term
 					go func() {
+						defer recoverConn(c)
 						term.Run()
 						channel.Close()
 					}()
@@ -154,11 +163,16 @@ func handleSSHConn(c net.Conn, cfg *ssh.ServerConfig) {
 				case "exec":
 					if len(r.Payload) > 4 {
 						l := binary.BigEndian.Uint32(r.Payload[0:4])
+						if uint64(len(r.Payload)) < 4+uint64(l) {
+							r.Reply(false, nil)
+							continue
+						}
 						cmd := string(r.Payload[4 : 4+l])
 						r.Reply(true, nil)
 
 						// 执行单次命令
 						term := NewTerminal(channel, fs, env, cols, rows)
+						term.Remote = remoteIP(c.RemoteAddr())
 						// exec 不需要 Run() 的循环，直接 Exec
 						term.Exec(cmd)
 						// 发送退出状态
@@ -171,7 +185,7 @@ func handleSSHConn(c net.Conn, cfg *ssh.ServerConfig) {
 				case "subsystem":
 					if string(r.Payload[4:]) == "sftp" {
 						r.Reply(true, nil)
-						h := &SFTPHandler{fs: fs}
+						h := &SFTPHandler{fs: fs.From(remoteIP(c.RemoteAddr()))}
 						srv := sftp.NewRequestServer(channel, sftp.Handlers{
 							FileGet: h, FilePut: h, FileCmd: h, FileList: h,
 						})
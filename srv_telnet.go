@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 	"net"
-	"strings"
 	"time"
 )
 
@@ -278,7 +277,9 @@ func (ts *TelnetStream) Write(p []byte) (n int, err error) {
 }
 
 func handleTelnetConn(c net.Conn) {
+	defer recoverConn(c)
 	defer c.Close()
+	defer trackConn(c, 23)()
 
 	env := map[string]string{
 		"TERM":  "vt100",
@@ -294,27 +295,42 @@ func handleTelnetConn(c net.Conn) {
 		initialHeight:     24, // 设置默认值
 	}
 
-	ts.Write([]byte("\r\nUbuntu 22.04 LTS\r\nubuntu-server login: "))
+	ts.Write([]byte("\r\n" + HostPersona.OSName + "\r\n"))
 
-	user := readLine(ts)
-	if user == "" {
+	// 与 login(1) 一样最多允许 3 次尝试
+	loggedIn := false
+	for i := 0; i < 3 && !loggedIn; i++ {
+		ts.Write([]byte(HostPersona.Hostname + " login: "))
+		user := readLine(ts)
+		if user == "" {
+			return
+		}
+		ts.Write([]byte("Password: "))
+		pass := readLine(ts)
+		ts.Write([]byte("\r\n"))
+
+		if Auth.Check("telnet", remoteIP(c.RemoteAddr()), user, pass) {
+			env["USER"] = user
+			loggedIn = true
+		} else {
+			time.Sleep(time.Second)
+			ts.Write([]byte("Login incorrect\r\n"))
+		}
+	}
+	if !loggedIn {
 		return
 	}
-	env["USER"] = strings.TrimSpace(user)
 	if env["USER"] == "root" {
 		env["HOME"] = "/root"
 	} else {
 		env["HOME"] = "/home/" + env["USER"]
 	}
 
-	ts.Write([]byte("Password: "))
-	readLine(ts)
-	ts.Write([]byte("\r\n"))
-
 	fs := GlobalSessionFS
 
 	// 使用协商后缓存的尺寸创建 Terminal
 	term := NewTerminal(ts, fs, env, ts.initialWidth, ts.initialHeight)
+	term.Remote = remoteIP(c.RemoteAddr())
 	ts.term = term
 	term.Run()
 }
@@ -0,0 +1,832 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// su / sudo
+// 切换身份只是替换会话的环境变量 (USER、HOME 等)，命令实现据此判断当前用户；
+// 交互式的 su、sudo -i 压入一层嵌套 shell，exit 时恢复之前的身份
+// ==========================================
+
+const etcSudoers = `#
+# This file MUST be edited with the 'visudo' command as root.
+#
+# Please consider adding local content in /etc/sudoers.d/ instead of
+# directly modifying this file.
+#
+# See the man page for details on how to write a sudoers file.
+#
+Defaults	env_reset
+Defaults	mail_badpass
+Defaults	secure_path="/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/snap/bin"
+Defaults	use_pty
+
+# This preserves proxy settings from user environments of root
+# equivalent users (group sudo)
+#Defaults:%sudo env_keep += "http_proxy https_proxy ftp_proxy all_proxy no_proxy"
+
+# This allows running arbitrary commands, but so does ALL, and it means
+# different sudoers have their choice of editor respected.
+#Defaults:%sudo env_keep += "EDITOR"
+
+# Completely harmless preservation of a user preference.
+#Defaults:%sudo env_keep += "GREP_COLOR"
+
+# While you shouldn't normally run git as root, you need to with etckeeper
+#Defaults:%sudo env_keep += "GIT_AUTHOR_* GIT_COMMITTER_*"
+
+# Per-user preferences; root won't have sensible values for them.
+#Defaults:%sudo env_keep += "EMAIL DEBEMAIL DEBFULLNAME"
+
+# "sudo scp" or "sudo rsync" should be able to use your SSH agent.
+#Defaults:%sudo env_keep += "SSH_AGENT_PID SSH_AUTH_SOCK"
+
+# Ditto for GPG agent
+#Defaults:%sudo env_keep += "GPG_AGENT_INFO"
+
+# Host alias specification
+
+# User alias specification
+
+# Cmnd alias specification
+
+# User privilege specification
+root	ALL=(ALL:ALL) ALL
+
+# Members of the admin group may gain root privileges
+%admin ALL=(ALL) ALL
+
+# Allow members of group sudo to execute any command
+%sudo	ALL=(ALL:ALL) ALL
+
+# See sudoers(5) for more information on "@include" directives:
+
+@includedir /etc/sudoers.d
+`
+
+const sudoersReadme = `#
+# The default /etc/sudoers file created on installation of the
+# sudo  package now includes the directive:
+#
+# 	@includedir /etc/sudoers.d
+#
+# This will cause sudo to read and parse any files in the /etc/sudoers.d
+# directory that do not end in '~' or contain a '.' character.
+#
+# Note that there must be at least one file in the sudoers.d directory (this
+# one will do).
+#
+# Note also, that because sudoers contents can vary widely, no attempt is
+# made to add this directive to existing sudoers files on upgrade.  Feel free
+# to add the above directive to the end of your /etc/sudoers file to enable
+# this functionality for existing installations if you wish! Sudo
+# versions older than the one in Debian 1.7.2p1-1 do not support this.
+#
+# Finally, please note that using the visudo command is the recommended way
+# to update sudoers content, since it protects against many failure modes.
+# See the man page for visudo and sudoers for more information.
+#
+`
+
+const sudoUsage = `usage: sudo -h | -K | -k | -V
+usage: sudo -v [-ABknS] [-g group] [-h host] [-p prompt] [-u user]
+usage: sudo -l [-ABknS] [-g group] [-h host] [-p prompt] [-U user] [-u user]
+            [command]
+usage: sudo [-ABbEHknPS] [-r role] [-t type] [-C num] [-D directory] [-g
+            group] [-h host] [-p prompt] [-R directory] [-T timeout] [-u
+            user] [VAR=value] [-i|-s] [<command>]
+usage: sudo -e [-ABknS] [-r role] [-t type] [-C num] [-D directory] [-g
+            group] [-h host] [-p prompt] [-R directory] [-T timeout] [-u
+            user] file ...
+`
+
+// sudoTimeout sudo 验证密码后免密的时长 (timestamp_timeout)
+const sudoTimeout = 15 * time.Minute
+
+// sudoRule sudoers 中的一条用户规则，如 "%sudo ALL=(ALL:ALL) NOPASSWD: ALL"
+type sudoRule struct {
+	who   []string
+	runas string // 括号中的目标用户，空表示只能以 root 执行
+	tags  []string
+	cmds  string
+}
+
+// sudoersLines 读取 sudoers 及其 @includedir、@include 引入的文件，
+// 返回合并续行、去掉注释后的行
+func (fs *SessionFS) sudoersLines(p string, depth int) []string {
+	e, ok := fs.GetEntry(p)
+	if !ok || e.IsDir || depth > 8 {
+		return nil
+	}
+	e.mu.RLock()
+	content := string(e.Content)
+	e.mu.RUnlock()
+	var res []string
+	cont := ""
+	for _, line := range strings.Split(content, "\n") {
+		line, cont = cont+line, ""
+		if strings.HasSuffix(line, "\\") {
+			cont = strings.TrimSuffix(line, "\\")
+			continue
+		}
+		line = strings.TrimSpace(line)
+		word, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		if arg != "" && !path.IsAbs(arg) {
+			arg = path.Join(path.Dir(p), arg)
+		}
+		switch word {
+		case "@includedir", "#includedir":
+			// 与 sudo 一样跳过以 '~' 结尾或含 '.' 的文件
+			items, _ := fs.ListDir(arg)
+			for _, it := range items {
+				if !it.IsDir && !strings.Contains(it.Name, ".") && !strings.HasSuffix(it.Name, "~") {
+					res = append(res, fs.sudoersLines(path.Join(arg, it.Name), depth+1)...)
+				}
+			}
+			continue
+		case "@include", "#include":
+			res = append(res, fs.sudoersLines(arg, depth+1)...)
+			continue
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// parseSudoRule 解析用户规则行，Defaults 和别名定义返回 false
+func parseSudoRule(line string) (sudoRule, bool) {
+	eq := strings.IndexByte(line, '=')
+	if eq < 0 || strings.HasPrefix(line, "Defaults") || strings.Contains(strings.Fields(line)[0], "_Alias") {
+		return sudoRule{}, false
+	}
+	head := strings.Fields(line[:eq])
+	if len(head) < 2 {
+		return sudoRule{}, false
+	}
+	r := sudoRule{who: strings.Split(strings.Join(head[:len(head)-1], ""), ",")}
+	rest := strings.TrimSpace(line[eq+1:])
+	if strings.HasPrefix(rest, "(") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return sudoRule{}, false
+		}
+		r.runas, rest = strings.TrimSpace(rest[1:end]), strings.TrimSpace(rest[end+1:])
+	}
+	for {
+		tag, after, ok := strings.Cut(rest, ":")
+		tag = strings.TrimSpace(tag)
+		if !ok || tag == "" || strings.ToUpper(tag) != tag || strings.ContainsAny(tag, " /") {
+			break
+		}
+		r.tags, rest = append(r.tags, tag), strings.TrimSpace(after)
+	}
+	r.cmds = rest
+	return r, true
+}
+
+// sudoers 返回 sudoers 中的全局 Defaults 设置和适用于用户 u 的规则
+func (fs *SessionFS) sudoers(u passwdEntry) (defaults []string, rules []sudoRule) {
+	groups := fs.memberOf(u)
+	matches := func(who string) bool {
+		switch {
+		case who == "ALL" || who == u.Name || who == "#"+strconv.Itoa(u.UID):
+			return true
+		case strings.HasPrefix(who, "%"):
+			for _, g := range groups {
+				if who == "%"+g.Name || who == "%#"+strconv.Itoa(g.GID) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, line := range fs.sudoersLines("/etc/sudoers", 0) {
+		if rest, ok := strings.CutPrefix(line, "Defaults"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			for _, d := range strings.Split(rest, ",") {
+				if d = strings.TrimSpace(d); d != "" {
+					defaults = append(defaults, d)
+				}
+			}
+			continue
+		}
+		r, ok := parseSudoRule(line)
+		if !ok {
+			continue
+		}
+		for _, who := range r.who {
+			if matches(strings.TrimSpace(who)) {
+				rules = append(rules, r)
+				break
+			}
+		}
+	}
+	return defaults, rules
+}
+
+// nopasswd 规则是否带 NOPASSWD 标签
+func (r sudoRule) nopasswd() bool {
+	return containsString(r.tags, "NOPASSWD")
+}
+
+// allows 判断规则是否允许以 target 身份执行 cmdline (首个词为命令的绝对路径)
+func (r sudoRule) allows(target, cmdline string) bool {
+	switch runas, _, _ := strings.Cut(r.runas, ":"); {
+	case r.runas == "" && target != "root":
+		return false
+	case r.runas != "" && !containsString(strings.Split(strings.ReplaceAll(runas, " ", ""), ","), "ALL") &&
+		!containsString(strings.Split(strings.ReplaceAll(runas, " ", ""), ","), target):
+		return false
+	}
+	for _, c := range strings.Split(r.cmds, ",") {
+		f := strings.Fields(c)
+		switch {
+		case len(f) == 0:
+		case f[0] == "ALL":
+			return true
+		case len(f) == 1 && f[0] == strings.Fields(cmdline)[0]:
+			return true
+		case strings.Join(f, " ") == cmdline:
+			return true
+		}
+	}
+	return false
+}
+
+// String 按 sudo -l 的格式输出规则
+func (r sudoRule) String() string {
+	runas := "root"
+	if r.runas != "" {
+		parts := strings.Split(r.runas, ":")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		runas = strings.Join(parts, " : ")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "(%s) ", runas)
+	for _, tag := range r.tags {
+		b.WriteString(tag + ": ")
+	}
+	b.WriteString(r.cmds)
+	return b.String()
+}
+
+// shortHostname sudo 消息中使用的主机名
+func shortHostname() string {
+	h, _, _ := strings.Cut(HostPersona.Hostname, ".")
+	return h
+}
+
+// suFrame 切换身份前的会话状态，离开嵌套 shell 时据此恢复
+type suFrame struct {
+	env   map[string]string
+	cwd   string
+	pid   int
+	procs []int // su、sudo 为嵌套 shell 登记的进程
+}
+
+// loginPath 登录环境的 PATH (login.defs 的 ENV_SUPATH、ENV_PATH)
+func loginPath(uid int) string {
+	if uid == 0 {
+		return "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+	return "/usr/local/bin:/usr/bin:/bin:/usr/local/games:/usr/games"
+}
+
+// userEnv 以 src 为基础生成 u 的环境变量。
+// login 时像登录 shell 一样只保留 TERM 并重置 PATH
+func userEnv(src map[string]string, u passwdEntry, login bool, extra map[string]string) map[string]string {
+	env := make(map[string]string, len(src))
+	for k, v := range src {
+		if !login || k == "TERM" {
+			env[k] = v
+		}
+	}
+	env["USER"], env["LOGNAME"], env["HOME"], env["SHELL"] = u.Name, u.Name, u.Home, u.Shell
+	if login {
+		env["PATH"] = loginPath(u.UID)
+		env["PS1"], env["BASH"], env["BASH_VERSION"] = defaultPS1, "/bin/bash", HostPersona.BashVersion
+	}
+	for k, v := range extra {
+		env[k] = v
+	}
+	return env
+}
+
+// homeDir 登录时进入的目录，主目录不存在时为 /
+func (fs *SessionFS) homeDir(u passwdEntry) string {
+	if e, ok := fs.GetEntry(u.Home); ok && e.IsDir {
+		return u.Home
+	}
+	return "/"
+}
+
+// switchUser 把会话切换为 u 的身份，返回切换前的状态
+func (t *Terminal) switchUser(u passwdEntry, login bool, extra map[string]string) *suFrame {
+	t.mu.Lock()
+	f := &suFrame{env: t.Env, cwd: t.FS.cwd, pid: t.pid}
+	t.Env = userEnv(t.Env, u, login, extra)
+	t.mu.Unlock()
+	if login {
+		t.FS.cwd = t.FS.homeDir(u)
+	}
+	return f
+}
+
+// restoreUser 恢复 switchUser 之前的身份
+func (t *Terminal) restoreUser(f *suFrame) {
+	t.mu.Lock()
+	t.Env, t.pid = f.env, f.pid
+	t.mu.Unlock()
+	t.FS.cwd = f.cwd
+	for _, pid := range f.procs {
+		Procs.Remove(pid)
+	}
+}
+
+// shellHost 返回承接嵌套 shell 的交互式登录 shell。命令在前台作业的子 shell 中执行，
+// 只有单独作为前台作业的 su、sudo 才能占用终端，管道、脚本和后台中的返回 nil
+func (t *Terminal) shellHost() *Terminal {
+	if t.interactive() {
+		return t
+	}
+	rt := t.root()
+	if t.job == nil || len(t.job.procs) != 1 || t.scriptDepth != 0 || !rt.interactive() {
+		return nil
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.fg != t.job {
+		return nil
+	}
+	return rt
+}
+
+// openShell 在 host 上以 u 的身份打开嵌套 shell，并登记 su/sudo 及 shell 进程。
+// 新环境以 t 的环境为基础，sudo su 因此能带上 SUDO_USER 等变量
+func (t *Terminal) openShell(host *Terminal, u passwdEntry, login bool, extra map[string]string, cmdline []string) {
+	t.mu.Lock()
+	env := userEnv(t.Env, u, login, extra)
+	t.mu.Unlock()
+	cwd := t.FS.cwd
+	if login {
+		cwd = t.FS.homeDir(u)
+	}
+
+	host.mu.Lock()
+	f := &suFrame{env: host.Env, cwd: host.FS.cwd, pid: host.pid}
+	tty := host.tty
+	host.mu.Unlock()
+	parent := Procs.Add(&Process{
+		PPID: f.pid, User: "root", TTY: tty, Stat: "S", VSZ: 10000 + rand.Intn(2000), RSS: 4000 + rand.Intn(800),
+		Args: append([]string(nil), cmdline...),
+	})
+	name := path.Base(u.Shell)
+	if login {
+		name = "-" + name
+	}
+	shell := Procs.Add(&Process{
+		PPID: parent.PID, User: u.Name, TTY: tty, Stat: "S", VSZ: 8608 + rand.Intn(200), RSS: 5200 + rand.Intn(300),
+		Args: []string{name}, Env: env, term: host, shell: true,
+	})
+	f.procs = []int{parent.PID, shell.PID}
+
+	host.mu.Lock()
+	host.Env, host.pid = env, shell.PID
+	host.su = append(host.su, f)
+	host.mu.Unlock()
+	host.FS.cwd = cwd
+	log.Printf("[Shell] %s: %s opened a shell as %s", t.Remote, strings.Join(cmdline, " "), u.Name)
+	if login {
+		host.loadStartupFiles()
+	}
+}
+
+// leaveShell 退出最内层的嵌套 shell；不在嵌套 shell 中时返回 false
+func (t *Terminal) leaveShell() bool {
+	t.mu.Lock()
+	n := len(t.su)
+	if n == 0 {
+		t.mu.Unlock()
+		return false
+	}
+	f := t.su[n-1]
+	t.su = t.su[:n-1]
+	t.mu.Unlock()
+	t.restoreUser(f)
+	return true
+}
+
+// runAs 以 u 的身份执行：没有命令时在交互式会话中打开嵌套 shell，否则执行标准输入中的脚本
+func (t *Terminal) runAs(u passwdEntry, login bool, extra map[string]string, cmdline []string, command []string, in io.Reader, out io.Writer) {
+	if len(command) == 0 {
+		if host := t.shellHost(); host != nil {
+			t.openShell(host, u, login, extra, cmdline)
+			return
+		}
+	}
+	f := t.switchUser(u, login, extra)
+	if len(command) > 0 {
+		t.runCommand(command, in, out)
+	} else if in != nil {
+		data, _ := io.ReadAll(io.LimitReader(in, MaxFileSize))
+		t.runLines(string(data), out)
+	}
+	t.restoreUser(f)
+}
+
+// ==========================================
+// su
+// ==========================================
+
+func (t *Terminal) cmdSu(args []string, in io.Reader, out io.Writer) {
+	login := false
+	var command, shell string
+	var rest []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		next := func() (string, bool) {
+			if i+1 >= len(args) {
+				fmt.Fprintf(t.Stderr, "su: option requires an argument -- '%s'\nTry 'su --help' for more information.\n", strings.TrimLeft(a, "-"))
+				t.lastExitCode = 1
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+		var ok bool
+		switch {
+		case len(rest) > 0:
+			rest = append(rest, a)
+		case a == "-" || a == "-l" || a == "--login":
+			login = true
+		case a == "-c" || a == "--command":
+			if command, ok = next(); !ok {
+				return
+			}
+		case strings.HasPrefix(a, "--command="):
+			command = strings.TrimPrefix(a, "--command=")
+		case a == "-s" || a == "--shell":
+			if shell, ok = next(); !ok {
+				return
+			}
+		case strings.HasPrefix(a, "--shell="):
+			shell = strings.TrimPrefix(a, "--shell=")
+		case a == "-m" || a == "-p" || a == "--preserve-environment" || a == "-P" || a == "--pty":
+		case a == "-h" || a == "--help":
+			fmt.Fprint(out, "\nUsage:\n su [options] [-] [<user> [<argument>...]]\n\nChange the effective user ID and group ID to that of <user>.\nA mere - implies -l.  If <user> is not given, root is assumed.\n")
+			return
+		case strings.HasPrefix(a, "-"):
+			fmt.Fprintf(t.Stderr, "su: invalid option -- '%s'\nTry 'su --help' for more information.\n", strings.TrimLeft(a, "-"))
+			t.lastExitCode = 1
+			return
+		default:
+			rest = append(rest, a)
+		}
+	}
+	name := "root"
+	if len(rest) > 0 {
+		name = rest[0]
+	}
+	u, ok := t.FS.lookupUser(name)
+	if !ok {
+		fmt.Fprintf(t.Stderr, "su: user %s does not exist or the user entry does not contain all the required fields\n", name)
+		t.lastExitCode = 1
+		return
+	}
+	self := t.userName()
+	if self != "root" {
+		// su 只从终端读取密码
+		if !isTTY(t.Stderr) {
+			fmt.Fprintln(t.Stderr, "su: must be run from a terminal")
+			t.lastExitCode = 1
+			return
+		}
+		fmt.Fprint(t.Stderr, "Password: ")
+		pass, ok := t.readReply(nil, t.Stderr, false)
+		if !ok {
+			t.lastExitCode = 1
+			return
+		}
+		valid := t.FS.checkPassword(name, pass)
+		log.Printf("[Account] %s: su %s by %s with password %q valid=%v", t.Remote, name, self, pass, valid)
+		if !valid {
+			fmt.Fprintln(t.Stderr, "su: Authentication failure")
+			t.lastExitCode = 1
+			return
+		}
+	}
+	if shell != "" && self == "root" {
+		u.Shell = shell
+	}
+	switch path.Base(u.Shell) {
+	case "nologin":
+		fmt.Fprintln(out, "This account is currently not available.")
+		t.lastExitCode = 1
+		return
+	case "false":
+		t.lastExitCode = 1
+		return
+	}
+	var command2 []string
+	if command != "" {
+		command2 = []string{"sh", "-c", command}
+	}
+	t.runAs(u, login, nil, args, command2, in, out)
+}
+
+// ==========================================
+// sudo
+// ==========================================
+
+// getSudoTime 返回 sudo 上次验证密码的时间。与 sudo 按终端记录时间戳一样，
+// 记在登录 shell 上，管道和子 shell 中的 sudo 共用
+func (t *Terminal) getSudoTime() time.Time {
+	rt := t.root()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.sudoTime
+}
+
+func (t *Terminal) setSudoTime(v time.Time) {
+	rt := t.root()
+	rt.mu.Lock()
+	rt.sudoTime = v
+	rt.mu.Unlock()
+}
+
+// sudoAuthenticate 提示输入当前用户的密码，最多三次。没有 -S 时 sudo 只从终端读取
+func (t *Terminal) sudoAuthenticate(self string, in io.Reader, stdin bool) bool {
+	if !stdin {
+		in = nil
+		if !isTTY(t.Stderr) {
+			fmt.Fprintln(t.Stderr, "sudo: a terminal is required to read the password; either use the -S option to read from standard input or configure an askpass helper")
+			fmt.Fprintln(t.Stderr, "sudo: a password is required")
+			return false
+		}
+	}
+	for try := 1; try <= 3; try++ {
+		fmt.Fprintf(t.Stderr, "[sudo] password for %s: ", self)
+		pass, ok := t.readReply(in, t.Stderr, false)
+		if !ok {
+			switch {
+			case try > 1:
+				fmt.Fprintf(t.Stderr, "sudo: %d incorrect password attempt%s\n", try-1, map[bool]string{true: "", false: "s"}[try == 2])
+			case stdin:
+				fmt.Fprintln(t.Stderr, "sudo: no password was provided\nsudo: a password is required")
+			}
+			return false
+		}
+		valid := t.FS.checkPassword(self, pass)
+		log.Printf("[Account] %s: sudo password for %s: %q valid=%v", t.Remote, self, pass, valid)
+		if valid {
+			t.setSudoTime(time.Now())
+			return true
+		}
+		if try < 3 {
+			fmt.Fprintln(t.Stderr, "Sorry, try again.")
+		}
+	}
+	fmt.Fprintln(t.Stderr, "sudo: 3 incorrect password attempts")
+	return false
+}
+
+func (t *Terminal) cmdSudo(args []string, in io.Reader, out io.Writer) {
+	target := "root"
+	var login, shell, list, nonInteractive, stdin, validate, reset, version, help bool
+	i := 1
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			break
+		}
+		if strings.HasPrefix(a, "--") {
+			name, val, hasVal := strings.Cut(a, "=")
+			switch name {
+			case "--user", "--group", "--prompt", "--host", "--close-from", "--chdir", "--role", "--type", "--command-timeout", "--other-user":
+				if !hasVal && i+1 < len(args) {
+					i++
+					val = args[i]
+				}
+				if name == "--user" {
+					target = val
+				}
+			case "--login":
+				login = true
+			case "--shell":
+				shell = true
+			case "--list":
+				list = true
+			case "--non-interactive":
+				nonInteractive = true
+			case "--stdin":
+				stdin = true
+			case "--validate":
+				validate = true
+			case "--reset-timestamp", "--remove-timestamp":
+				reset = true
+			case "--version":
+				version = true
+			case "--help":
+				help = true
+			case "--preserve-env", "--set-home", "--background", "--askpass", "--bell", "--preserve-groups":
+			default:
+				fmt.Fprintf(t.Stderr, "sudo: unrecognized option '%s'\n%s", a, sudoUsage)
+				t.lastExitCode = 1
+				return
+			}
+			continue
+		}
+		bad := byte(0)
+		i = shortOpts(args, i, "ugpCDRrtTUh", func(opt byte, val string) {
+			switch opt {
+			case 'u':
+				target = val
+			case 'i':
+				login = true
+			case 's':
+				shell = true
+			case 'l':
+				list = true
+			case 'n':
+				nonInteractive = true
+			case 'S':
+				stdin = true
+			case 'v':
+				validate = true
+			case 'k', 'K':
+				reset = true
+			case 'V':
+				version = true
+			case 'h':
+				// -h 单独使用时是帮助，带参数时是主机名
+				help = help || val == ""
+			case 'g', 'p', 'C', 'D', 'R', 'r', 't', 'T', 'U', 'E', 'H', 'b', 'A', 'B', 'P':
+			default:
+				bad = opt
+			}
+		})
+		if bad != 0 {
+			fmt.Fprintf(t.Stderr, "sudo: invalid option -- '%c'\n%s", bad, sudoUsage)
+			t.lastExitCode = 1
+			return
+		}
+	}
+	command := args[i:]
+
+	switch {
+	case version:
+		fmt.Fprintln(out, "Sudo version 1.9.9")
+		if t.userName() == "root" {
+			fmt.Fprintln(out, "Sudoers policy plugin version 1.9.9\nSudoers file grammar version 48\nSudoers I/O plugin version 1.9.9\nSudoers audit plugin version 1.9.9")
+		}
+		return
+	case help:
+		fmt.Fprintf(out, "sudo - execute a command as another user\n\n%s", sudoUsage)
+		return
+	}
+	if reset {
+		t.setSudoTime(time.Time{})
+		if len(command) == 0 && !login && !shell && !list && !validate {
+			return
+		}
+	}
+	if len(command) == 0 && !login && !shell && !list && !validate {
+		fmt.Fprint(t.Stderr, sudoUsage)
+		t.lastExitCode = 1
+		return
+	}
+
+	u, ok := t.FS.lookupUser(target)
+	if n, err := strconv.Atoi(strings.TrimPrefix(target, "#")); !ok && err == nil && strings.HasPrefix(target, "#") {
+		for _, e := range t.FS.passwdEntries() {
+			if e.UID == n {
+				u, ok = e, true
+				break
+			}
+		}
+	}
+	if !ok {
+		fmt.Fprintf(t.Stderr, "sudo: unknown user %s\nsudo: error initializing audit plugin sudoers_audit\n", target)
+		t.lastExitCode = 1
+		return
+	}
+	self := t.userName()
+	me, _ := t.FS.lookupUser(self)
+	defaults, rules := t.FS.sudoers(me)
+	host := shortHostname()
+
+	// VAR=value 形式的参数设置目标命令的环境变量
+	extra := map[string]string{}
+	for len(command) > 0 && isAssignment(command[0]) {
+		k, v, _ := strings.Cut(command[0], "=")
+		extra[k] = v
+		command = command[1:]
+	}
+
+	// 找出允许执行该命令的规则
+	var cmdline string
+	found := true
+	if len(command) > 0 {
+		p := command[0]
+		if strings.Contains(p, "/") {
+			p = t.FS.Abs(p)
+		} else {
+			p, found = t.lookPath(p)
+		}
+		cmdline = strings.Join(append([]string{p}, command[1:]...), " ")
+	} else if login || shell {
+		cmdline = u.Shell
+	}
+	// 与 sudoers 一样以最后一条匹配的规则为准；sudo -l、-v 只要有一条 NOPASSWD 规则就不需要密码
+	var rule *sudoRule
+	for i := range rules {
+		switch {
+		case cmdline != "" && rules[i].allows(u.Name, cmdline),
+			cmdline == "" && (rule == nil || !rule.nopasswd()):
+			rule = &rules[i]
+		}
+	}
+
+	if self != "root" && time.Since(t.getSudoTime()) > sudoTimeout && (rule == nil || !rule.nopasswd()) {
+		if nonInteractive {
+			fmt.Fprintln(t.Stderr, "sudo: a password is required")
+			t.lastExitCode = 1
+			return
+		}
+		if !t.sudoAuthenticate(self, in, stdin) {
+			t.lastExitCode = 1
+			return
+		}
+	}
+
+	if list {
+		if len(rules) == 0 {
+			fmt.Fprintf(out, "Sorry, user %s may not run sudo on %s.\n", self, host)
+			t.lastExitCode = 1
+			return
+		}
+		fmt.Fprintf(out, "Matching Defaults entries for %s on %s:\n", self, host)
+		for j, d := range defaults {
+			if k, v, ok := strings.Cut(d, "="); ok {
+				defaults[j] = k + "=" + strings.ReplaceAll(strings.Trim(v, `"`), ":", `\:`)
+			}
+		}
+		fmt.Fprintf(out, "    %s\n\nUser %s may run the following commands on %s:\n", strings.Join(defaults, ", "), self, host)
+		for _, r := range rules {
+			fmt.Fprintf(out, "    %s\n", r)
+		}
+		return
+	}
+	if validate && len(command) == 0 && !login && !shell {
+		return
+	}
+	if rule == nil && self != "root" {
+		if len(rules) == 0 {
+			fmt.Fprintf(t.Stderr, "%s is not in the sudoers file.  This incident will be reported.\n", self)
+		} else {
+			fmt.Fprintf(t.Stderr, "Sorry, user %s is not allowed to execute '%s' as %s on %s.\n", self, cmdline, u.Name, host)
+		}
+		log.Printf("[Shell] %s: sudo denied for %s: %s", t.Remote, self, cmdline)
+		t.lastExitCode = 1
+		return
+	}
+	if !found {
+		fmt.Fprintf(t.Stderr, "sudo: %s: command not found\n", command[0])
+		t.lastExitCode = 1
+		return
+	}
+
+	extra["SUDO_USER"], extra["SUDO_UID"], extra["SUDO_GID"] = self, strconv.Itoa(me.UID), strconv.Itoa(me.GID)
+	extra["SUDO_COMMAND"] = cmdline
+	for _, d := range defaults {
+		if v, ok := strings.CutPrefix(d, "secure_path="); ok {
+			extra["PATH"] = strings.Trim(v, `"`)
+		}
+	}
+	log.Printf("[Shell] %s: sudo %s -> %s: %s", t.Remote, self, u.Name, cmdline)
+	// sudo bash、sudo su 等不带参数的 shell 与 sudo -s 一样打开嵌套 shell
+	if len(command) == 1 {
+		switch path.Base(command[0]) {
+		case "bash", "sh", "dash":
+			command = nil
+		}
+	}
+	t.runAs(u, login, extra, args, command, in, out)
+}
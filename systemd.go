@@ -0,0 +1,1257 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// systemd: systemctl, service
+// 单元注册表每次从会话文件系统中的单元文件解析，/etc/systemd/system 覆盖 /lib/systemd/system；
+// 启用状态就是 .wants 目录中的链接，运行状态就是进程表中属于该单元的进程。
+// 非发行版单元被启用、启动或重新加载时，其 ExecStart 作为持久化 IOC 记录到日志
+// ==========================================
+
+const (
+	unitVendorDir = "/lib/systemd/system"
+	unitAdminDir  = "/etc/systemd/system"
+	unitTasksMax  = 19020 // TasksMax 默认值 (pid_max 与内存推算)
+)
+
+// vendorUnits 预置的发行版单元文件，与 process.go 中的守护进程对应
+var vendorUnits = map[string]string{
+	"ssh.service": `[Unit]
+Description=OpenBSD Secure Shell server
+Documentation=man:sshd(8) man:sshd_config(5)
+After=network.target auditd.service
+ConditionPathExists=!/etc/ssh/sshd_not_to_be_run
+
+[Service]
+EnvironmentFile=-/etc/default/ssh
+ExecStartPre=/usr/sbin/sshd -t
+ExecStart=/usr/sbin/sshd -D $SSHD_OPTS
+ExecReload=/usr/sbin/sshd -t
+ExecReload=/bin/kill -HUP $MAINPID
+KillMode=process
+Restart=on-failure
+RestartPreventExitStatus=255
+Type=notify
+RuntimeDirectory=sshd
+RuntimeDirectoryMode=0755
+
+[Install]
+WantedBy=multi-user.target
+Alias=sshd.service
+`,
+	"vsftpd.service": `[Unit]
+Description=vsftpd FTP server
+After=network.target
+
+[Service]
+Type=simple
+ExecStart=/usr/sbin/vsftpd /etc/vsftpd.conf
+ExecReload=/bin/kill -HUP $MAINPID
+ExecStartPre=-/bin/mkdir -p /var/run/vsftpd/empty
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"inetutils-inetd.service": `[Unit]
+Description=Internet superserver
+Documentation=man:inetutils-inetd(8)
+After=network.target
+
+[Service]
+Type=forking
+ExecStart=/usr/sbin/inetd
+ExecReload=/bin/kill -HUP $MAINPID
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"redis-server.service": `[Unit]
+Description=Advanced key-value store
+After=network.target
+Documentation=http://redis.io/documentation, man:redis-server(1)
+
+[Service]
+Type=notify
+ExecStart=/usr/bin/redis-server /etc/redis/redis.conf --supervised systemd --daemonize no
+PIDFile=/run/redis/redis-server.pid
+TimeoutStopSec=0
+Restart=always
+User=redis
+Group=redis
+RuntimeDirectory=redis
+RuntimeDirectoryMode=2755
+
+UMask=007
+PrivateTmp=yes
+LimitNOFILE=65535
+PrivateDevices=yes
+ProtectHome=yes
+ReadOnlyDirectories=/
+ReadWriteDirectories=-/var/lib/redis
+ReadWriteDirectories=-/var/log/redis
+ReadWriteDirectories=-/var/run/redis
+
+NoNewPrivileges=true
+CapabilityBoundingSet=CAP_SETGID CAP_SETUID CAP_SYS_RESOURCE
+
+[Install]
+WantedBy=multi-user.target
+Alias=redis.service
+`,
+	"mysql.service": `# MySQL systemd service file
+
+[Unit]
+Description=MySQL Community Server
+After=network.target
+
+[Install]
+WantedBy=multi-user.target
+
+[Service]
+Type=notify
+User=mysql
+PermissionsStartOnly=true
+ExecStartPre=/usr/share/mysql/mysql-systemd-start pre
+ExecStart=/usr/sbin/mysqld
+TimeoutSec=infinity
+Restart=on-failure
+RuntimeDirectory=mysqld
+RuntimeDirectoryMode=755
+LimitNOFILE=10000
+
+# Set enviroment variable MYSQLD_PARENT_PID. This is required for restart.
+Environment=MYSQLD_PARENT_PID=1
+`,
+	"apache2.service": `[Unit]
+Description=The Apache HTTP Server
+After=network.target remote-fs.target nss-lookup.target
+Documentation=https://httpd.apache.org/docs/2.4/
+
+[Service]
+Type=forking
+Environment=APACHE_STARTED_BY_SYSTEMD=true
+ExecStart=/usr/sbin/apachectl start
+ExecStop=/usr/sbin/apachectl graceful-stop
+ExecReload=/usr/sbin/apachectl graceful
+KillMode=mixed
+PrivateTmp=true
+Restart=on-abort
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"postfix.service": `[Unit]
+Description=Postfix Mail Transport Agent
+Documentation=man:postfix(1)
+Conflicts=sendmail.service exim4.service
+ConditionPathExists=/etc/postfix/main.cf
+
+[Service]
+Type=forking
+PIDFile=/var/spool/postfix/pid/master.pid
+ExecStart=/usr/sbin/postfix start
+ExecStop=/usr/sbin/postfix stop
+ExecReload=/usr/sbin/postfix reload
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"cron.service": `[Unit]
+Description=Regular background program processing daemon
+Documentation=man:cron(8)
+After=remote-fs.target nss-user-lookup.target
+
+[Service]
+EnvironmentFile=-/etc/default/cron
+ExecStart=/usr/sbin/cron -f -P $EXTRA_OPTS
+IgnoreSIGPIPE=false
+KillMode=process
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"rsyslog.service": `[Unit]
+Description=System Logging Service
+Requires=syslog.socket
+Documentation=man:rsyslogd(8)
+Documentation=man:rsyslog.conf(5)
+Documentation=https://www.rsyslog.com/doc/
+
+[Service]
+Type=notify
+ExecStart=/usr/sbin/rsyslogd -n -iNONE
+StandardOutput=null
+Restart=on-failure
+
+# Increase the default a bit in order to allow many simultaneous
+# files to be monitored, we might need a lot of fds.
+LimitNOFILE=16384
+
+[Install]
+WantedBy=multi-user.target
+Alias=syslog.service
+`,
+	"irqbalance.service": `[Unit]
+Description=irqbalance daemon
+Documentation=man:irqbalance(1)
+Documentation=https://github.com/Irqbalance/irqbalance
+ConditionVirtualization=!container
+
+[Service]
+EnvironmentFile=-/etc/default/irqbalance
+ExecStart=/usr/sbin/irqbalance --foreground $IRQBALANCE_ARGS
+CapabilityBoundingSet=
+NoNewPrivileges=yes
+ReadOnlyPaths=/
+ReadWritePaths=/proc/irq
+RestrictAddressFamilies=AF_UNIX
+RuntimeDirectory=irqbalance/
+
+[Install]
+WantedBy=multi-user.target
+`,
+	"dbus.service": `[Unit]
+Description=D-Bus System Message Bus
+Documentation=man:dbus-daemon(1)
+Requires=dbus.socket
+
+[Service]
+ExecStart=/usr/bin/dbus-daemon --system --address=systemd: --nofork --nopidfile --systemd-activation --syslog-only
+ExecReload=/usr/bin/dbus-send --print-reply --system --type=method_call --dest=org.freedesktop.DBus / org.freedesktop.DBus.ReloadConfig
+OOMScoreAdjust=-900
+`,
+	"systemd-journald.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=Journal Service
+Documentation=man:systemd-journald.service(8) man:journald.conf(5)
+DefaultDependencies=no
+Requires=systemd-journald.socket
+After=systemd-journald.socket systemd-journald-dev-log.socket systemd-journald-audit.socket syslog.socket
+Before=sysinit.target
+
+[Service]
+DeviceAllow=char-* rw
+ExecStart=/lib/systemd/systemd-journald
+FileDescriptorStoreMax=4224
+Restart=always
+RestartSec=0
+Sockets=systemd-journald.socket systemd-journald-dev-log.socket systemd-journald-audit.socket
+StandardOutput=null
+Type=notify
+WatchdogSec=3min
+`,
+	"systemd-udevd.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=Rule-based Manager for Device Events and Files
+Documentation=man:systemd-udevd.service(8) man:udev(7)
+DefaultDependencies=no
+After=systemd-sysusers.service systemd-hwdb-update.service
+Before=sysinit.target
+ConditionPathIsReadWrite=/sys
+
+[Service]
+DeviceAllow=block-* rwm
+DeviceAllow=char-* rwm
+Type=notify
+Delegate=pids
+OOMScoreAdjust=-1000
+Sockets=systemd-udevd-control.socket systemd-udevd-kernel.socket
+Restart=always
+RestartSec=0
+ExecStart=/lib/systemd/systemd-udevd
+ExecReload=udevadm control --reload --timeout 0
+KillMode=mixed
+TasksMax=infinity
+`,
+	"systemd-networkd.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=Network Configuration
+Documentation=man:systemd-networkd.service(8)
+ConditionCapability=CAP_NET_ADMIN
+DefaultDependencies=no
+After=systemd-networkd.socket systemd-udevd.service network-pre.target systemd-sysusers.service systemd-sysctl.service
+Before=network.target multi-user.target shutdown.target
+Conflicts=shutdown.target
+Wants=systemd-networkd.socket network.target
+
+[Service]
+AmbientCapabilities=CAP_NET_ADMIN CAP_NET_BIND_SERVICE CAP_NET_BROADCAST CAP_NET_RAW
+ExecStart=!!/lib/systemd/systemd-networkd
+Restart=on-failure
+RestartSec=0
+RuntimeDirectory=systemd/netif
+RuntimeDirectoryPreserve=yes
+Type=notify
+User=systemd-network
+
+[Install]
+WantedBy=multi-user.target
+Also=systemd-networkd.socket
+Alias=dbus-org.freedesktop.network1.service
+`,
+	"systemd-resolved.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=Network Name Resolution
+Documentation=man:systemd-resolved.service(8)
+Documentation=man:org.freedesktop.resolve1(5)
+DefaultDependencies=no
+After=systemd-sysusers.service
+Before=network.target nss-lookup.target shutdown.target
+Conflicts=shutdown.target
+Wants=nss-lookup.target
+
+[Service]
+AmbientCapabilities=CAP_SETPCAP CAP_NET_RAW CAP_NET_BIND_SERVICE
+ExecStart=!!/lib/systemd/systemd-resolved
+Restart=always
+RestartSec=0
+Type=notify
+User=systemd-resolve
+
+[Install]
+WantedBy=multi-user.target
+Alias=dbus-org.freedesktop.resolve1.service
+`,
+	"systemd-timesyncd.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=Network Time Synchronization
+Documentation=man:systemd-timesyncd.service(8)
+ConditionVirtualization=!container
+DefaultDependencies=no
+After=systemd-sysusers.service
+Before=time-set.target sysinit.target shutdown.target
+Conflicts=shutdown.target systemd-timesyncd.service
+Wants=time-set.target
+
+[Service]
+AmbientCapabilities=CAP_SYS_TIME
+ExecStart=!!/lib/systemd/systemd-timesyncd
+Restart=always
+RestartSec=0
+Type=notify
+User=systemd-timesync
+WatchdogSec=3min
+
+[Install]
+WantedBy=sysinit.target
+Alias=dbus-org.freedesktop.timesync1.service
+`,
+	"systemd-logind.service": `#  SPDX-License-Identifier: LGPL-2.1-or-later
+
+[Unit]
+Description=User Login Management
+Documentation=man:sd-login(3)
+Documentation=man:systemd-logind.service(8)
+Documentation=man:logind.conf(5)
+Documentation=man:org.freedesktop.login1(5)
+Wants=user.slice modprobe@drm.service
+After=nss-user-lookup.target user.slice modprobe@drm.service
+
+[Service]
+BusName=org.freedesktop.login1
+CapabilityBoundingSet=CAP_SYS_ADMIN CAP_MAC_ADMIN CAP_AUDIT_CONTROL CAP_CHOWN CAP_DAC_READ_SEARCH CAP_DAC_OVERRIDE CAP_FOWNER CAP_SYS_TTY_CONFIG CAP_LINUX_IMMUTABLE
+DeviceAllow=block-* r
+ExecStart=/lib/systemd/systemd-logind
+FileDescriptorStoreMax=512
+Restart=always
+RestartSec=0
+Type=notify
+WatchdogSec=3min
+`,
+	"ufw.service": `[Unit]
+Description=Uncomplicated firewall
+Documentation=man:ufw(8)
+DefaultDependencies=no
+Before=network-pre.target
+Wants=network-pre.target local-fs.target
+After=local-fs.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/lib/ufw/ufw-init start quiet
+ExecStop=/lib/ufw/ufw-init stop
+
+[Install]
+WantedBy=multi-user.target
+`,
+}
+
+// unitPIDs 预置守护进程所属的单元
+var unitPIDs = map[int]string{
+	289: "systemd-journald.service", 331: "systemd-udevd.service", 575: "systemd-networkd.service",
+	577: "systemd-resolved.service", 579: "systemd-timesyncd.service", 640: "cron.service", 641: "dbus.service",
+	648: "irqbalance.service", 652: "rsyslog.service", 655: "systemd-logind.service", 832: "ssh.service",
+	845: "vsftpd.service", 858: "inetutils-inetd.service", 861: "redis-server.service", 902: "mysql.service",
+	950: "apache2.service", 951: "apache2.service", 952: "apache2.service",
+	1102: "postfix.service", 1103: "postfix.service", 1104: "postfix.service",
+}
+
+// unitSeeds 预置守护进程的快照，发行版单元停止后再启动时按此重建进程
+var unitSeeds = map[string][]Process{}
+
+func sortedPIDs(m map[int]string) []int {
+	pids := make([]int, 0, len(m))
+	for pid := range m {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// systemdUnit 从单元文件解析出的单元
+type systemdUnit struct {
+	Name    string
+	Path    string
+	Content string
+	Enabled bool
+	Masked  bool
+	Aliases []string
+	keys    map[string][]string // "Section.Key" -> 值，空赋值清空之前的值
+}
+
+func parseUnit(name, p, content string) *systemdUnit {
+	u := &systemdUnit{Name: name, Path: p, Content: content, keys: make(map[string][]string)}
+	section := ""
+	lines := strings.Split(strings.ReplaceAll(content, "\\\n", " "), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			section = line[1 : len(line)-1]
+		default:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key := section + "." + strings.TrimSpace(k)
+			if v = strings.TrimSpace(v); v == "" {
+				delete(u.keys, key)
+			} else {
+				u.keys[key] = append(u.keys[key], v)
+			}
+		}
+	}
+	return u
+}
+
+// get 返回最后一次赋值
+func (u *systemdUnit) get(key string) string {
+	if v := u.keys[key]; len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return ""
+}
+
+// list 返回所有赋值中以空白分隔的各项
+func (u *systemdUnit) list(key string) []string {
+	var res []string
+	for _, v := range u.keys[key] {
+		res = append(res, strings.Fields(v)...)
+	}
+	return res
+}
+
+func (u *systemdUnit) description() string {
+	if d := u.get("Unit.Description"); d != "" {
+		return d
+	}
+	return u.Name
+}
+
+// vendor 单元文件与发行版预置内容一致
+func (u *systemdUnit) vendor() bool {
+	base, ok := BaseFS[u.Path]
+	return ok && string(base.Content) == u.Content
+}
+
+// installable 有 [Install] 段，否则为 static
+func (u *systemdUnit) installable() bool {
+	return len(u.keys["Install.WantedBy"])+len(u.keys["Install.RequiredBy"])+len(u.keys["Install.Alias"]) > 0
+}
+
+func (u *systemdUnit) fileState() string {
+	switch {
+	case u.Masked:
+		return "masked"
+	case !u.installable():
+		return "static"
+	case u.Enabled:
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// execArgs 把 ExecStart 转成进程参数：去掉 -@:+! 前缀和未定义的环境变量
+func (u *systemdUnit) execArgs() []string {
+	var args []string
+	for _, f := range strings.Fields(strings.TrimLeft(u.get("Service.ExecStart"), "-@:+!")) {
+		if !strings.HasPrefix(f, "$") {
+			args = append(args, f)
+		}
+	}
+	return args
+}
+
+// unitRegistry 单元名 (含别名) -> 单元
+type unitRegistry map[string]*systemdUnit
+
+// unitRegistry 从单元文件目录解析单元，目录中的符号链接是别名或屏蔽
+func (t *Terminal) unitRegistry() unitRegistry {
+	reg := make(unitRegistry)
+	links := make(map[string]string)
+	for _, dir := range []string{unitVendorDir, unitAdminDir} {
+		files, _ := t.FS.ListDir(dir)
+		for _, f := range files {
+			if f.IsDir || !strings.Contains(f.Name, ".") || strings.Contains(f.Name, "@.") {
+				continue
+			}
+			f.mu.RLock()
+			content := string(f.Content)
+			f.mu.RUnlock()
+			if f.Mode&os.ModeSymlink != 0 {
+				links[f.Name] = content
+				continue
+			}
+			reg[f.Name] = parseUnit(f.Name, path.Join(dir, f.Name), content)
+		}
+	}
+	for name, target := range links {
+		if target == "/dev/null" {
+			if u, ok := reg[name]; ok {
+				u.Masked = true
+			} else {
+				reg[name] = &systemdUnit{Name: name, Path: path.Join(unitAdminDir, name), Masked: true, keys: map[string][]string{}}
+			}
+		} else if u, ok := reg[path.Base(target)]; ok && reg[name] == nil {
+			u.Aliases = append(u.Aliases, name)
+			reg[name] = u
+		}
+	}
+
+	// 启用状态：*.wants / *.requires 目录中的链接
+	dirs, _ := t.FS.ListDir(unitAdminDir)
+	for _, d := range dirs {
+		if !d.IsDir || !(strings.HasSuffix(d.Name, ".wants") || strings.HasSuffix(d.Name, ".requires")) {
+			continue
+		}
+		files, _ := t.FS.ListDir(path.Join(unitAdminDir, d.Name))
+		for _, f := range files {
+			if u, ok := reg[f.Name]; ok {
+				u.Enabled = true
+			}
+		}
+	}
+	return reg
+}
+
+// lookup 按名称查找单元，没有后缀的名称视为 .service
+func (reg unitRegistry) lookup(name string) (string, *systemdUnit) {
+	if !strings.Contains(name, ".") {
+		name += ".service"
+	}
+	return name, reg[name]
+}
+
+// units 返回去掉别名后按名称排序的单元
+func (reg unitRegistry) units() []*systemdUnit {
+	var res []*systemdUnit
+	for name, u := range reg {
+		if name == u.Name {
+			res = append(res, u)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// unitProcs 返回属于单元的进程，主进程在前
+func unitProcs(name string) []Process {
+	var res []Process
+	for _, p := range Procs.Snapshot() {
+		if p.Unit == name {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// unitIntel 记录非发行版单元的持久化行为，并捕获单元文件和 ExecStart 指向的程序
+func (t *Terminal) unitIntel(op string, u *systemdUnit) {
+	if u.vendor() || u.Masked {
+		return
+	}
+	log.Printf("[Persistence] %s: %s systemctl %s %s (%s) ExecStart=%s", t.Remote, t.userName(), op, u.Name, u.Path, u.get("Service.ExecStart"))
+	CapturePayload("systemd", t.Remote, u.Path, []byte(u.Content))
+	if args := u.execArgs(); len(args) > 0 {
+		if e, ok := t.FS.GetEntry(args[0]); ok && !e.IsDir {
+			e.mu.RLock()
+			content := append([]byte(nil), e.Content...)
+			e.mu.RUnlock()
+			CapturePayload("systemd", t.Remote, args[0], content)
+		}
+	}
+}
+
+// startUnit 按 ExecStart 登记单元的主进程，程序不存在时单元启动失败
+func (t *Terminal) startUnit(u *systemdUnit) {
+	if len(unitProcs(u.Name)) > 0 || u.get("Service.Type") == "oneshot" {
+		return
+	}
+	if seeds := unitSeeds[u.Name]; len(seeds) > 0 && u.vendor() {
+		pids := map[int]int{1: 1}
+		for _, seed := range seeds {
+			p := seed
+			p.PID, p.PPID, p.Start = 0, pids[seed.PPID], time.Now()
+			p.Env = map[string]string{}
+			for k, v := range seed.Env {
+				p.Env[k] = v
+			}
+			p.Env["INVOCATION_ID"] = fmt.Sprintf("%032x", rand.Uint64())
+			pids[seed.PID] = Procs.Add(&p).PID
+		}
+		return
+	}
+	args := u.execArgs()
+	if len(args) == 0 {
+		return
+	}
+	var content []byte
+	if path.IsAbs(args[0]) {
+		e, ok := t.FS.GetEntry(args[0])
+		if !ok || e.IsDir || e.Mode&0111 == 0 {
+			return
+		}
+		e.mu.RLock()
+		content = e.Content
+		e.mu.RUnlock()
+	}
+	user := u.get("Service.User")
+	if _, ok := t.FS.lookupUser(user); !ok {
+		user = "root"
+	}
+	Procs.Add(&Process{
+		PPID: 1, User: user, TTY: "?", Stat: "Ss", Load: execLoad(args, content),
+		VSZ: 20000 + rand.Intn(200000), RSS: 3000 + rand.Intn(20000), Args: args, Unit: u.Name,
+		Env: map[string]string{
+			"LANG":          "C.UTF-8",
+			"PATH":          "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+			"INVOCATION_ID": fmt.Sprintf("%032x", rand.Uint64()),
+		},
+	})
+}
+
+func stopUnit(u *systemdUnit) {
+	for _, p := range unitProcs(u.Name) {
+		Procs.Remove(p.PID)
+	}
+}
+
+// unitLink 在 /etc/systemd/system 中创建指向 target 的链接
+func (t *Terminal) unitLink(link, target string) {
+	t.mkdirAll(path.Dir(link))
+	t.FS.Write(link, []byte(target), os.ModeSymlink|0777)
+	fmt.Fprintf(t.Stderr, "Created symlink %s → %s.\n", link, target)
+}
+
+// enableLinks 返回启用单元时创建的链接
+func (u *systemdUnit) enableLinks() []string {
+	var links []string
+	for _, w := range u.list("Install.WantedBy") {
+		links = append(links, path.Join(unitAdminDir, w+".wants", u.Name))
+	}
+	for _, r := range u.list("Install.RequiredBy") {
+		links = append(links, path.Join(unitAdminDir, r+".requires", u.Name))
+	}
+	for _, a := range u.list("Install.Alias") {
+		links = append(links, path.Join(unitAdminDir, a))
+	}
+	return links
+}
+
+const unitNoInstall = `The unit files have no installation config (WantedBy=, RequiredBy=, Also=,
+Alias= settings in the [Install] section, and DefaultInstance= for template
+units). This means they are not meant to be enabled using systemctl.
+
+Possible reasons for having this kind of units are:
+• A unit may be statically enabled by being symlinked from another unit's
+  .wants/ or .requires/ directory.
+• A unit's purpose may be to act as a helper for some other unit which has
+  a requirement dependency on it.
+• A unit may be started when needed via activation (socket, path, timer,
+  D-Bus, udev, scripted systemctl call, ...).
+• In case of template units, the unit is meant to be enabled with some
+  instance name specified.
+`
+
+// sdRelative 按 systemd 的 format_timestamp_relative 格式化时间差
+func sdRelative(d time.Duration) string {
+	const day, week, month = 24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour
+	switch {
+	case d >= month:
+		return fmt.Sprintf("%d months %d days ago", d/month, d%month/day)
+	case d >= week:
+		if d%week/day == 0 {
+			return fmt.Sprintf("%d weeks ago", d/week)
+		}
+		return fmt.Sprintf("%d weeks %d days ago", d/week, d%week/day)
+	case d >= 2*day:
+		return fmt.Sprintf("%d days ago", d/day)
+	case d >= 25*time.Hour:
+		return fmt.Sprintf("1 day %dh ago", (d-day)/time.Hour)
+	case d >= 6*time.Hour:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dmin ago", d/time.Hour, d%time.Hour/time.Minute)
+	case d >= 5*time.Minute:
+		return fmt.Sprintf("%dmin ago", d/time.Minute)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dmin %ds ago", d/time.Minute, d%time.Minute/time.Second)
+	case d >= time.Second:
+		return fmt.Sprintf("%ds ago", d/time.Second)
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%dms ago", d/time.Millisecond)
+	}
+	return "now"
+}
+
+// sdTimespan 按毫秒精度格式化时长，如 20ms、4.1s、1min 2.5s
+func sdTimespan(d time.Duration) string {
+	d = max(d.Truncate(time.Millisecond), 0)
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+	var b strings.Builder
+	if d >= time.Hour {
+		fmt.Fprintf(&b, "%dh ", d/time.Hour)
+		d %= time.Hour
+	}
+	if d >= time.Minute {
+		fmt.Fprintf(&b, "%dmin ", d/time.Minute)
+		d %= time.Minute
+	}
+	s := fmt.Sprintf("%d.%03d", d/time.Second, d%time.Second/time.Millisecond)
+	b.WriteString(strings.TrimSuffix(strings.TrimRight(s, "0"), ".") + "s")
+	return b.String()
+}
+
+// sdBytes 按 systemd 的 format_bytes 格式化内存用量 (KiB)
+func sdBytes(kib int) string {
+	switch {
+	case kib >= 1<<20:
+		return fmt.Sprintf("%.1fG", float64(kib)/(1<<20))
+	case kib >= 1<<10:
+		return fmt.Sprintf("%.1fM", float64(kib)/(1<<10))
+	}
+	return fmt.Sprintf("%.1fK", float64(kib))
+}
+
+// unitStatus 输出 systemctl status 的单元状态，返回单元是否在运行
+func (t *Terminal) unitStatus(out io.Writer, u *systemdUnit) bool {
+	procs := unitProcs(u.Name)
+	active := len(procs) > 0 || (u.get("Service.Type") == "oneshot" && u.get("Service.RemainAfterExit") == "yes" && !u.Masked)
+	bullet := "○"
+	if active {
+		bullet = "●"
+	}
+	if u.Masked {
+		fmt.Fprintf(out, "%s %s\n", bullet, u.Name)
+		fmt.Fprintf(out, "%11s: masked (Reason: Unit %s is masked.)\n", "Loaded", u.Name)
+		fmt.Fprintf(out, "%11s: inactive (dead)\n", "Active")
+		return false
+	}
+	fmt.Fprintf(out, "%s %s - %s\n", bullet, u.Name, u.description())
+	state := u.fileState()
+	if state != "static" {
+		state += "; vendor preset: enabled"
+	}
+	fmt.Fprintf(out, "%11s: loaded (%s; %s)\n", "Loaded", u.Path, state)
+
+	since := startTime.Add(10 * time.Second)
+	switch {
+	case len(procs) > 0:
+		since = procs[0].Start
+		fmt.Fprintf(out, "%11s: active (running) since %s; %s\n", "Active", since.Format("Mon 2006-01-02 15:04:05 MST"), sdRelative(time.Since(since)))
+	case active:
+		fmt.Fprintf(out, "%11s: active (exited) since %s; %s\n", "Active", since.Format("Mon 2006-01-02 15:04:05 MST"), sdRelative(time.Since(since)))
+	default:
+		fmt.Fprintf(out, "%11s: inactive (dead)\n", "Active")
+	}
+	for i, doc := range u.list("Unit.Documentation") {
+		if i == 0 {
+			fmt.Fprintf(out, "%11s: %s\n", "Docs", strings.TrimSuffix(doc, ","))
+		} else {
+			fmt.Fprintf(out, "%13s%s\n", "", strings.TrimSuffix(doc, ","))
+		}
+	}
+	if len(procs) == 0 {
+		return active
+	}
+
+	var rss int
+	var cpu time.Duration
+	for _, p := range procs {
+		rss += p.RSS
+		cpu += p.CPUTime()
+	}
+	fmt.Fprintf(out, "%11s: %d (%s)\n", "Main PID", procs[0].PID, procs[0].Comm())
+	fmt.Fprintf(out, "%11s: %d (limit: %d)\n", "Tasks", len(procs), unitTasksMax)
+	fmt.Fprintf(out, "%11s: %s\n", "Memory", sdBytes(rss))
+	fmt.Fprintf(out, "%11s: %s\n", "CPU", sdTimespan(cpu))
+	fmt.Fprintf(out, "%11s: /system.slice/%s\n", "CGroup", u.Name)
+	for i, p := range procs {
+		branch := "├─"
+		if i == len(procs)-1 {
+			branch = "└─"
+		}
+		args := make([]string, len(p.Args))
+		for j, a := range p.Args {
+			if strings.ContainsAny(a, " \t\"") {
+				a = `"` + strings.ReplaceAll(a, `"`, `\"`) + `"`
+			}
+			args[j] = a
+		}
+		fmt.Fprintf(out, "%13s%s%d %s\n", "", branch, p.PID, strings.Join(args, " "))
+	}
+
+	// journal 只对 root 和 adm 组可见
+	if t.userName() == "root" {
+		fmt.Fprintln(out)
+		stamp := func(ts time.Time) string { return ts.Format("Jan 02 15:04:05") + " " + HostPersona.Hostname }
+		fmt.Fprintf(out, "%s systemd[1]: Starting %s...\n", stamp(since.Add(-40*time.Millisecond)), u.description())
+		fmt.Fprintf(out, "%s systemd[1]: Started %s.\n", stamp(since), u.description())
+	}
+	return true
+}
+
+// systemctlDenied 普通用户没有 polkit 授权
+func (t *Terminal) systemctlDenied(what string) bool {
+	if t.userName() == "root" {
+		return false
+	}
+	fmt.Fprintf(t.Stderr, "Failed to %s: Interactive authentication required.\n", what)
+	t.lastExitCode = 1
+	return true
+}
+
+func (t *Terminal) cmdSystemctl(args []string, out io.Writer) {
+	var all, now, quiet bool
+	var verb string
+	var names []string
+	for _, a := range args[1:] {
+		switch {
+		case a == "-a" || a == "--all":
+			all = true
+		case a == "--now":
+			now = true
+		case a == "-q" || a == "--quiet":
+			quiet = true
+		case a == "--version":
+			fmt.Fprintln(out, "systemd 249 (249.11-0ubuntu3.12)")
+			fmt.Fprintln(out, "+PAM +AUDIT +SELINUX +APPARMOR +IMA +SMACK +SECCOMP +GCRYPT +GNUTLS +OPENSSL +ACL +BLKID +CURL +ELFUTILS +FIDO2 +IDN2 -IDN +IPTC +KMOD +LIBCRYPTSETUP +LIBFDISK +PCRE2 -PWQUALITY -P11KIT -QRENCODE +BZIP2 +LZ4 +XZ +ZLIB +ZSTD -XKBCOMMON +UTMP +SYSVINIT default-hierarchy=unified")
+			return
+		case strings.HasPrefix(a, "-"):
+		case verb == "":
+			verb = a
+		default:
+			names = append(names, a)
+		}
+	}
+	if verb == "" {
+		verb = "list-units"
+	}
+	reg := t.unitRegistry()
+
+	// need 查找参数中的单元，找不到时输出 notFound 并以 code 退出
+	need := func(notFound string, code int) []*systemdUnit {
+		if len(names) == 0 {
+			fmt.Fprintln(t.Stderr, "Too few arguments.")
+			t.lastExitCode = 1
+			return nil
+		}
+		var res []*systemdUnit
+		for _, n := range names {
+			name, u := reg.lookup(n)
+			if u == nil {
+				fmt.Fprintf(t.Stderr, notFound+"\n", name)
+				t.lastExitCode = code
+				return nil
+			}
+			res = append(res, u)
+		}
+		return res
+	}
+
+	switch verb {
+	case "list-units":
+		t.systemctlListUnits(out, reg, all)
+
+	case "list-unit-files":
+		units := reg.units()
+		w, sw := len("UNIT FILE"), len("STATE")
+		for _, u := range units {
+			w, sw = max(w, len(u.Name)), max(sw, len(u.fileState()))
+		}
+		fmt.Fprintf(out, "%-*s %-*s %s\n", w, "UNIT FILE", sw, "STATE", "VENDOR PRESET")
+		for _, u := range units {
+			preset := "enabled"
+			if !u.installable() || u.Masked {
+				preset = "-"
+			}
+			fmt.Fprintf(out, "%-*s %-*s %s\n", w, u.Name, sw, u.fileState(), preset)
+		}
+		fmt.Fprintf(out, "\n%d unit files listed.\n", len(units))
+
+	case "status":
+		if len(names) == 0 {
+			t.systemStatus(out, reg)
+			return
+		}
+		units := need("Unit %s could not be found.", 4)
+		if units == nil {
+			return
+		}
+		t.lastExitCode = 0
+		for i, u := range units {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			if !t.unitStatus(out, u) {
+				t.lastExitCode = 3
+			}
+		}
+
+	case "is-active", "is-failed":
+		t.lastExitCode = 3
+		if verb == "is-failed" {
+			t.lastExitCode = 1
+		}
+		for _, n := range names {
+			state := "inactive"
+			if name, u := reg.lookup(n); u != nil && len(unitProcs(name)) > 0 {
+				state = "active"
+				if verb == "is-active" {
+					t.lastExitCode = 0
+				}
+			}
+			if !quiet {
+				fmt.Fprintln(out, state)
+			}
+		}
+
+	case "is-enabled":
+		units := need("Failed to get unit file state for %s: No such file or directory", 1)
+		if units == nil {
+			return
+		}
+		t.lastExitCode = 1
+		for _, u := range units {
+			state := u.fileState()
+			if state == "enabled" || state == "static" {
+				t.lastExitCode = 0
+			}
+			if !quiet {
+				fmt.Fprintln(out, state)
+			}
+		}
+
+	case "cat":
+		units := need("No files found for %s.", 1)
+		for i, u := range units {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			if u.Masked {
+				fmt.Fprintf(t.Stderr, "Unit %s is masked.\n", u.Name)
+				t.lastExitCode = 1
+				continue
+			}
+			fmt.Fprintf(out, "# %s\n%s", u.Path, u.Content)
+		}
+
+	case "show":
+		for _, u := range need("Unit %s could not be found.", 4) {
+			procs := unitProcs(u.Name)
+			mainPID, active, sub := 0, "inactive", "dead"
+			if len(procs) > 0 {
+				mainPID, active, sub = procs[0].PID, "active", "running"
+			}
+			typ := u.get("Service.Type")
+			if typ == "" {
+				typ = "simple"
+			}
+			fmt.Fprintf(out, "Type=%s\n", typ)
+			fmt.Fprintf(out, "MainPID=%d\n", mainPID)
+			if exec := u.execArgs(); len(exec) > 0 {
+				fmt.Fprintf(out, "ExecStart={ path=%s ; argv[]=%s }\n", exec[0], strings.Join(exec, " "))
+			}
+			fmt.Fprintf(out, "User=%s\n", u.get("Service.User"))
+			fmt.Fprintf(out, "Id=%s\n", u.Name)
+			fmt.Fprintf(out, "Names=%s\n", strings.Join(append([]string{u.Name}, u.Aliases...), " "))
+			fmt.Fprintf(out, "Description=%s\n", u.description())
+			fmt.Fprintf(out, "LoadState=loaded\nActiveState=%s\nSubState=%s\n", active, sub)
+			fmt.Fprintf(out, "FragmentPath=%s\nUnitFileState=%s\nUnitFilePreset=enabled\n", u.Path, u.fileState())
+		}
+
+	case "start", "stop", "restart", "reload", "try-restart", "reload-or-restart":
+		var units []*systemdUnit
+		for _, n := range names {
+			name, u := reg.lookup(n)
+			if t.systemctlDenied(verb + " " + name) {
+				fmt.Fprintf(t.Stderr, "See system logs and 'systemctl status %s' for details.\n", name)
+				return
+			}
+			if u == nil {
+				fmt.Fprintf(t.Stderr, "Failed to %s %s: Unit %s not found.\n", verb, name, name)
+				t.lastExitCode = 5
+				return
+			}
+			if u.Masked {
+				fmt.Fprintf(t.Stderr, "Failed to %s %s: Unit %s is masked.\n", verb, name, name)
+				t.lastExitCode = 1
+				return
+			}
+			units = append(units, u)
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(t.Stderr, "Too few arguments.")
+			t.lastExitCode = 1
+			return
+		}
+		for _, u := range units {
+			running := len(unitProcs(u.Name)) > 0
+			switch verb {
+			case "reload":
+				if !running {
+					fmt.Fprintf(t.Stderr, "%s is not active, cannot reload.\n", u.Name)
+					t.lastExitCode = 1
+					return
+				}
+			case "stop":
+				stopUnit(u)
+			case "try-restart":
+				if running {
+					stopUnit(u)
+					t.startUnit(u)
+				}
+			case "restart", "reload-or-restart":
+				t.unitIntel(verb, u)
+				stopUnit(u)
+				t.startUnit(u)
+			case "start":
+				t.unitIntel(verb, u)
+				t.startUnit(u)
+			}
+		}
+
+	case "enable", "disable", "mask", "unmask":
+		units := need("Failed to "+verb+" unit: Unit file %s does not exist.", 1)
+		if units == nil || t.systemctlDenied(verb+" unit") {
+			return
+		}
+		for _, u := range units {
+			switch verb {
+			case "enable":
+				if u.Masked {
+					fmt.Fprintf(t.Stderr, "Failed to enable unit: Unit file %s is masked.\n", u.Path)
+					t.lastExitCode = 1
+					return
+				}
+				if !u.installable() {
+					fmt.Fprint(t.Stderr, unitNoInstall)
+					continue
+				}
+				t.unitIntel(verb, u)
+				for _, link := range u.enableLinks() {
+					if _, ok := t.FS.GetEntry(link); !ok {
+						t.unitLink(link, u.Path)
+					}
+				}
+				if now {
+					t.startUnit(u)
+				}
+			case "disable":
+				for _, link := range u.enableLinks() {
+					if e, ok := t.FS.GetEntry(link); ok && e.Mode&os.ModeSymlink != 0 {
+						t.FS.Remove(link)
+						fmt.Fprintf(t.Stderr, "Removed %s.\n", link)
+					}
+				}
+				if now {
+					stopUnit(u)
+				}
+			case "mask":
+				link := path.Join(unitAdminDir, u.Name)
+				if e, ok := t.FS.GetEntry(link); ok && e.Mode&os.ModeSymlink == 0 {
+					fmt.Fprintf(t.Stderr, "Failed to mask unit: File %s already exists.\n", link)
+					t.lastExitCode = 1
+					return
+				}
+				if !u.Masked {
+					t.unitLink(link, "/dev/null")
+				}
+			case "unmask":
+				if u.Masked {
+					link := path.Join(unitAdminDir, u.Name)
+					t.FS.Remove(link)
+					fmt.Fprintf(t.Stderr, "Removed %s.\n", link)
+				}
+			}
+		}
+
+	case "daemon-reload", "daemon-reexec":
+		if t.systemctlDenied("reload daemon") {
+			return
+		}
+		for _, u := range reg.units() {
+			t.unitIntel(verb, u)
+		}
+
+	case "list-timers":
+		fmt.Fprintln(out, "NEXT LEFT LAST PASSED UNIT ACTIVATES")
+		fmt.Fprintln(out, "\n0 timers listed.")
+		if !all {
+			fmt.Fprintln(out, "Pass --all to see loaded but inactive timers, too.")
+		}
+
+	default:
+		fmt.Fprintf(t.Stderr, "Unknown command verb %s.\n", verb)
+		t.lastExitCode = 1
+	}
+}
+
+// systemctlListUnits 只列出服务单元，默认只显示运行中的
+func (t *Terminal) systemctlListUnits(out io.Writer, reg unitRegistry, all bool) {
+	type row struct{ name, active, sub, desc string }
+	var rows []row
+	w := len("UNIT")
+	for _, u := range reg.units() {
+		active, sub := "inactive", "dead"
+		if len(unitProcs(u.Name)) > 0 {
+			active, sub = "active", "running"
+		} else if u.get("Service.Type") == "oneshot" && u.get("Service.RemainAfterExit") == "yes" && !u.Masked {
+			active, sub = "active", "exited"
+		} else if !all {
+			continue
+		}
+		rows = append(rows, row{u.Name, active, sub, u.description()})
+		w = max(w, len(u.Name))
+	}
+	fmt.Fprintf(out, "  %-*s LOAD   ACTIVE   SUB     DESCRIPTION\n", w, "UNIT")
+	for _, r := range rows {
+		load := "loaded"
+		if reg[r.name].Masked {
+			load = "masked"
+		}
+		fmt.Fprintf(out, "  %-*s %-6s %-8s %-7s %s\n", w, r.name, load, r.active, r.sub, r.desc)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "LOAD   = Reflects whether the unit definition was properly loaded.")
+	fmt.Fprintln(out, "ACTIVE = The high-level unit activation state, i.e. generalization of SUB.")
+	fmt.Fprintln(out, "SUB    = The low-level unit activation state, values depend on unit type.")
+	if all {
+		fmt.Fprintf(out, "%d loaded units listed.\n", len(rows))
+	} else {
+		fmt.Fprintf(out, "%d loaded units listed. Pass --all to see loaded but inactive units, too.\n", len(rows))
+	}
+	fmt.Fprintln(out, "To show all installed unit files use 'systemctl list-unit-files'.")
+}
+
+// systemStatus 不带单元的 systemctl status
+func (t *Terminal) systemStatus(out io.Writer, reg unitRegistry) {
+	fmt.Fprintf(out, "● %s\n", HostPersona.Hostname)
+	fmt.Fprintf(out, "%11s: running\n", "State")
+	fmt.Fprintf(out, "%11s: 0 queued\n", "Jobs")
+	fmt.Fprintf(out, "%11s: 0 units\n", "Failed")
+	fmt.Fprintf(out, "%11s: %s; %s\n", "Since", startTime.Format("Mon 2006-01-02 15:04:05 MST"), sdRelative(time.Since(startTime)))
+	fmt.Fprintf(out, "%11s: /\n", "CGroup")
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%13s├─init.scope\n%13s│ └─1 /sbin/init\n", "", "")
+	fmt.Fprintf(&b, "%13s└─system.slice\n", "")
+	units := reg.units()
+	var running []*systemdUnit
+	for _, u := range units {
+		if len(unitProcs(u.Name)) > 0 {
+			running = append(running, u)
+		}
+	}
+	for i, u := range running {
+		branch, pipe := "├─", "│ "
+		if i == len(running)-1 {
+			branch, pipe = "└─", "  "
+		}
+		fmt.Fprintf(&b, "%15s%s%s\n", "", branch, u.Name)
+		procs := unitProcs(u.Name)
+		for j, p := range procs {
+			leaf := "├─"
+			if j == len(procs)-1 {
+				leaf = "└─"
+			}
+			fmt.Fprintf(&b, "%15s%s%s%d %s\n", "", pipe, leaf, p.PID, p.Cmdline())
+		}
+	}
+	out.Write(b.Bytes())
+}
+
+// cmdService service NAME ACTION 转交给 systemctl
+func (t *Terminal) cmdService(args []string, out io.Writer) {
+	if len(args) > 1 && args[1] == "--status-all" {
+		for _, u := range t.unitRegistry().units() {
+			name := strings.TrimSuffix(u.Name, ".service")
+			if name == u.Name || !u.installable() {
+				continue
+			}
+			mark := "-"
+			if len(unitProcs(u.Name)) > 0 {
+				mark = "+"
+			}
+			fmt.Fprintf(out, " [ %s ]  %s\n", mark, name)
+		}
+		return
+	}
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(t.Stderr, "Usage: service < option > | --status-all | [ service_name [ command | --full-restart ] ]")
+		t.lastExitCode = 1
+		return
+	}
+	if _, u := t.unitRegistry().lookup(args[1]); u == nil {
+		fmt.Fprintf(t.Stderr, "%s: unrecognized service\n", args[1])
+		t.lastExitCode = 1
+		return
+	}
+	if len(args) < 3 {
+		fmt.Fprintf(t.Stderr, "Usage: /etc/init.d/%s {start|stop|reload|force-reload|restart|try-restart|status}\n", args[1])
+		t.lastExitCode = 1
+		return
+	}
+	action := args[2]
+	switch action {
+	case "force-reload", "--full-restart":
+		action = "restart"
+	case "start", "stop", "restart", "reload", "try-restart", "status":
+	default:
+		fmt.Fprintf(t.Stderr, "Usage: /etc/init.d/%s {start|stop|reload|force-reload|restart|try-restart|status}\n", args[1])
+		t.lastExitCode = 1
+		return
+	}
+	t.cmdSystemctl([]string{"systemctl", action, args[1]}, out)
+}
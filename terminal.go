@@ -5,12 +5,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"math/rand"
-	"path"
-	"sort"
+	"log"
+	"runtime/debug"
 	"strings"
 	"sync"
-	"unicode"
+	"time"
 )
 
 // CRLFWriter 包装 io.Writer，将所有 \n 转换为 \r\n，解决阶梯效应
@@ -40,21 +39,57 @@ type Terminal struct {
 	mu sync.Mutex
 
 	// I/O
-	RW io.ReadWriter // 原始读写接口
+	RW     io.ReadWriter // 原始读写接口
+	Stderr io.Writer     // 标准错误：默认直接写到终端，2>file、2>&1 时临时指向重定向目标
 
 	// State
-	FS           *SessionFS
-	Env          map[string]string
-	History      []string
+	FS       *SessionFS
+	Env      map[string]string
+	History  []string
+	histBase int // 已被 HISTSIZE 挤掉的历史条数，用于 history 编号
+	histNew  int // History 中尚未写入 HISTFILE 的起始下标
+	cmdCount int // 本会话执行过的命令数 (PS1 的 \#)
+
+	aliases      map[string]string // alias 定义
+	funcs        map[string]string // shell 函数名 -> 函数体
+	positional   []string          // 位置参数 $1...
 	Width        int
 	Height       int
 	Running      bool
 	pid          int
+	tty          string // 控制终端，如 pts/0；非交互执行时为 "?"
 	lastExitCode int
+	Remote       string     // 对端地址，用于日志和载荷捕获
+	scriptDepth  int        // sh 嵌套层数
+	lastBgPID    int        // $!
+	exitWarned   bool       // 已提示过 "有停止的任务"
+	su           []*suFrame // su、sudo -i 打开的嵌套 shell，exit 时逐层恢复身份
+	sudoTime     time.Time  // sudo 上次验证密码的时间
+
+	// 作业控制
+	parent *Terminal  // 子 shell 的父 shell
+	job    *Job       // 子 shell 所属的作业
+	fg     *Job       // 当前前台作业
+	jobs   []*Job     // 作业表 (后台和已挂起的作业)
+	jobsMu sync.Mutex // 保护 jobs
 
 	// Line Editing State
-	buffer []rune
-	cursor int // buffer 中的光标位置
+	buffer      []rune
+	cursor      int            // buffer 中的光标位置
+	editRow     int            // 光标相对提示符首行的行数，重绘时据此回到起点
+	keys        keyDecoder     // 按键解码状态
+	histPos     int            // 上下翻历史时的位置
+	histSaved   []rune         // 开始翻历史前正在编辑的行
+	killRing    []string       // Ctrl-K/U/W 删除的文本
+	yankIdx     int            // Alt-y 轮换到的 kill ring 位置
+	yankLen     int            // 上一次粘贴的长度
+	action      string         // 本次按键的操作类型 (kill/yank)，用于合并连续 kill
+	lastAction  string         // 上一次按键的操作类型
+	search      *historySearch // Ctrl-R 反向搜索状态
+	lastSearch  string         // 上一次搜索的关键字
+	pasting     bool           // 处于括号粘贴中
+	pasteBuf    []rune         // 括号粘贴收到的文本
+	completeAsk []string       // 等待回答 "Display all N possibilities?" 的候选
 
 	// RawModeWriter 用于支持交互式全屏应用
 	// 当不为 nil 时，所有的输入都会直接写入此 Writer，而不是进入行编辑器
@@ -66,17 +101,23 @@ type Terminal struct {
 }
 
 func NewTerminal(rw io.ReadWriter, fs *SessionFS, env map[string]string, w, h int) *Terminal {
-	return &Terminal{
+	t := &Terminal{
 		RW:      rw,
-		FS:      fs,
+		Stderr:  &CRLFWriter{w: rw},
+		FS:      fs.View(fs.cwd),
 		Env:     env,
 		Width:   w,
 		Height:  h,
 		Running: true,
 		buffer:  make([]rune, 0, 1024),
-		pid:     1000 + rand.Intn(20000),
+		pid:     Procs.AllocPID(),
+		tty:     "?",
 		keyChan: make(chan rune, 128), // 带缓冲的通道，防止按键丢失
 	}
+	// 每个会话有独立的工作目录和 /proc/self
+	t.FS.self = func() int { return t.pid }
+	t.FS.origin = func() string { return t.Remote }
+	return t
 }
 
 func (t *Terminal) Resize(w, h int) {
@@ -93,45 +134,28 @@ func (t *Terminal) Print(s string) {
 	cw.Write([]byte(s))
 }
 
-func (t *Terminal) Prompt() {
-	t.mu.Lock()
-	user := t.Env["USER"]
-	if user == "" {
-		user = "root"
+// promptString 返回按 PS1 渲染的提示符，未设置 PS1 时使用 bash 的默认值
+func (t *Terminal) promptString() string {
+	ps1, ok := t.varValue("PS1")
+	if !ok {
+		ps1 = defaultPS1
 	}
-	t.mu.Unlock()
-
-	hostname := "ubuntu-server"
-	dir := t.FS.cwd
-
-	// 处理路径缩写
-	if dir == "/root" {
-		dir = "~"
-	} else if strings.HasPrefix(dir, "/root/") {
-		dir = "~" + dir[5:]
-	} else if strings.HasPrefix(dir, "/home/"+user) {
-		dir = "~" + dir[len("/home/"+user):]
-	}
-
-	// 提示符颜色：绿用户@红主机:蓝目录
-	promptSign := "$"
-	if user == "root" {
-		promptSign = "#"
-	}
-
-	fmt.Fprintf(t.RW, "\r\033[1;32m%s@%s\033[0m:\033[1;34m%s\033[0m%s ", user, hostname, dir, promptSign)
+	return t.renderPS1(ps1)
+}
 
-	// 重绘当前 buffer
-	t.RW.Write([]byte(string(t.buffer)))
+// defaultPS1 bash 在交互模式下的内置默认提示符
+const defaultPS1 = `\s-\v\$ `
 
-	// 移动光标回正确位置
-	if len(t.buffer) > t.cursor {
-		fmt.Fprintf(t.RW, "\033[%dD", len(t.buffer)-t.cursor)
+// Prompt 在新的一行输出提示符并重绘当前 buffer
+func (t *Terminal) Prompt() {
+	// 开启括号粘贴模式，粘贴内容会被 ESC [200~ ... ESC [201~ 包围
+	t.RW.Write([]byte("\033[?2004h"))
+	// 多行提示符的前几行只输出一次，重绘时只处理最后一行
+	if p := t.promptText(); strings.Contains(p, "\n") {
+		t.Print(p[:strings.LastIndex(p, "\n")+1])
 	}
-}
-
-func (t *Terminal) ClearLine() {
-	t.RW.Write([]byte("\r\033[K"))
+	t.editRow = 0
+	t.refreshLine()
 }
 
 // inputLoop 独立运行的输入读取循环，防止 exec 阻塞导致无法读取输入
@@ -141,6 +165,13 @@ func (t *Terminal) inputLoop() {
 	for t.Running {
 		r, _, err := reader.ReadRune()
 		if err != nil {
+			// 连接断开：挂断前台作业，让主循环可以退出
+			t.mu.Lock()
+			fg := t.fg
+			t.mu.Unlock()
+			if fg != nil {
+				fg.kill(sigHUP)
+			}
 			close(t.keyChan)
 			return // 通常是 io.EOF
 		}
@@ -155,6 +186,8 @@ func (t *Terminal) inputLoop() {
 			if _, err := rawWriter.Write([]byte(string(r))); err != nil {
 				// 写入失败通常意味着管道关闭，游戏结束，忽略错误
 			}
+		} else if (r == 3 || r == 26) && t.interruptForeground(r) {
+			// 前台作业运行中的 Ctrl-C / Ctrl-Z
 		} else {
 			// 否则发送给 Shell 主循环进行行编辑处理
 			t.keyChan <- r
@@ -163,258 +196,320 @@ func (t *Terminal) inputLoop() {
 }
 
 func (t *Terminal) Run() {
-	t.Print("Welcome to Ubuntu 22.04 LTS (GNU/Linux 5.15.0-generic x86_64)\n")
+	t.Print("Welcome to " + HostPersona.OSName + " (GNU/Linux " + HostPersona.Kernel + " " + HostPersona.Arch + ")\n")
 	t.Print(" * Documentation:  https://help.ubuntu.com\n")
 	t.Print(" * Management:     https://landscape.canonical.com\n")
 	t.Print(" * Support:        https://ubuntu.com/advantage\n\n")
+
+	// 在进程表中登记会话 (sshd 会话进程 + 登录 shell)
+	t.mu.Lock()
+	env := make(map[string]string, len(t.Env))
+	for k, v := range t.Env {
+		env[k] = v
+	}
+	t.mu.Unlock()
+	user := env["USER"]
+	if user == "" {
+		user = "root"
+	}
+	defer Procs.Login(t, user, env)()
+	defer t.hangup()
+
+	// 交互式登录 shell：先给出默认提示符，再读取启动文件
+	t.mu.Lock()
+	if _, ok := t.Env["PS1"]; !ok {
+		t.Env["PS1"] = defaultPS1
+	}
+	t.Env["BASH"] = "/bin/bash"
+	t.Env["BASH_VERSION"] = HostPersona.BashVersion
+	t.mu.Unlock()
+	t.loadStartupFiles()
+	t.loadHistory()
+	defer t.saveHistory()
+
 	t.Prompt()
 
 	// 启动独立的输入读取协程
 	go t.inputLoop()
 
-	histIdx := -1
-
-	// 用于解析 ANSI 转义序列的简单状态机
-	// 0: None, 1: ESC, 2: [
-	escState := 0
+	t.histPos = len(t.History)
 
 	// 主循环现在从 channel 读取按键，而不是直接从 reader 读取
 	for r := range t.keyChan {
-		// --- ANSI 转义序列解析 (方向键) ---
-		if escState == 0 {
-			if r == 27 {
-				escState = 1
-				continue
-			}
-		} else if escState == 1 {
-			if r == '[' {
-				escState = 2
-				continue
-			}
-			escState = 0 // Reset if not [
-			// Fallthrough to handle as normal char (simplified)
-		} else if escState == 2 {
-			escState = 0
-			switch r {
-			case 'A': // Up
-				if len(t.History) > 0 {
-					if histIdx == -1 {
-						histIdx = len(t.History)
-					}
-					if histIdx > 0 {
-						histIdx--
-						t.buffer = []rune(t.History[histIdx])
-						t.cursor = len(t.buffer)
-						t.ClearLine()
-						t.Prompt()
-					}
-				}
-				continue
-			case 'B': // Down
-				if histIdx < len(t.History) {
-					histIdx++
-					if histIdx >= len(t.History) {
-						histIdx = len(t.History)
-						t.buffer = []rune{}
-					} else {
-						t.buffer = []rune(t.History[histIdx])
-					}
-					t.cursor = len(t.buffer)
-					t.ClearLine()
-					t.Prompt()
-				}
-				continue
-			case 'C': // Right
-				if t.cursor < len(t.buffer) {
-					t.cursor++
-					t.RW.Write([]byte("\033[C"))
-				}
-				continue
-			case 'D': // Left
-				if t.cursor > 0 {
-					t.cursor--
-					t.RW.Write([]byte("\033[D"))
-				}
-				continue
-			}
+		// 登录 shell 可能已被其他会话 kill
+		if !t.Running {
+			return
 		}
 
-		// --- 标准输入处理 ---
-		switch r {
-		case 3: // Ctrl+C
-			t.buffer = t.buffer[:0]
-			t.cursor = 0
-			t.Print("^C\n")
-			t.Prompt()
-
-		case 4: // Ctrl+D
-			if len(t.buffer) == 0 {
-				return
+		ev, ok := t.keys.feed(r)
+		if !ok {
+			continue
+		}
+		switch t.handleKey(ev) {
+		case editEOF:
+			if t.leaveShell() {
+				t.Prompt()
+				continue
 			}
-
-		case 13, 10: // Enter
+			return
+		case editSubmit:
+			t.moveCursor(len(t.buffer))
+			t.RW.Write([]byte("\033[?2004l"))
 			t.Print("\n")
 			cmd := string(t.buffer)
-			if len(strings.TrimSpace(cmd)) > 0 {
-				if len(t.History) == 0 || t.History[len(t.History)-1] != cmd {
-					t.History = append(t.History, cmd)
-					if len(t.History) > 100 {
-						t.History = t.History[1:]
-					}
+			t.buffer = t.buffer[:0]
+			t.cursor = 0
+			// 粘贴的多行文本逐行执行
+			for _, line := range strings.Split(cmd, "\n") {
+				if len(strings.TrimSpace(line)) == 0 {
+					continue
+				}
+				expanded, err := t.expandHistory(line)
+				if err != nil {
+					t.auditCommand(line)
+					t.Print(err.Error() + "\n")
+					t.lastExitCode = 1
+					continue
+				}
+				if expanded != line {
+					t.Print(expanded + "\n")
 				}
-				histIdx = len(t.History)
-				t.execPipeline(cmd) // 这里可能会阻塞，但 inputLoop 依然在工作
+				t.auditCommand(expanded)
+				t.addHistory(expanded)
+				t.cmdCount++
+				t.runForeground(expanded) // 这里可能会阻塞，但 inputLoop 依然在工作
 				if !t.Running {
 					return
 				}
 			}
-			t.buffer = t.buffer[:0]
-			t.cursor = 0
+			t.histPos = len(t.History)
+			t.reportJobs(&CRLFWriter{w: t.RW})
 			t.Prompt()
+		}
+	}
+}
 
-		case 127, 8: // Backspace
-			if t.cursor > 0 {
-				t.buffer = append(t.buffer[:t.cursor-1], t.buffer[t.cursor:]...)
-				t.cursor--
-				t.ClearLine()
-				t.Prompt()
-			}
+func (t *Terminal) execPipeline(cmdline string) {
+	t.execPipelineTo(cmdline, &CRLFWriter{w: t.RW}) // 默认输出到终端
+}
 
-		case 9: // TAB
-			t.handleAutoComplete()
+// execPipelineTo 执行命令行，并将 (未被重定向的) 输出写入 finalOut
+func (t *Terminal) execPipelineTo(cmdline string, finalOut io.Writer) {
+	t.execPipelineIn(cmdline, nil, finalOut)
+}
 
-		default:
-			if unicode.IsPrint(r) {
-				t.buffer = append(t.buffer[:t.cursor], append([]rune{r}, t.buffer[t.cursor:]...)...)
-				t.cursor++
-				if t.cursor == len(t.buffer) {
-					// 将 rune 作为 UTF-8 字符串写回终端
-					t.RW.Write([]byte(string(r)))
-				} else {
-					t.ClearLine()
-					t.Prompt()
-				}
-			}
-		}
+// execPipelineIn 与 execPipelineTo 相同，但管道的第一个命令从 stdin 读取 (nil 表示空输入)
+func (t *Terminal) execPipelineIn(cmdline string, stdin io.Reader, finalOut io.Writer) {
+	// 以 & 结尾的命令行作为后台作业执行，展开留给作业自己的子 shell
+	if line, bg := splitBackground(cmdline); bg {
+		t.lastExitCode = 0
+		t.startBackground(line, finalOut)
+		return
 	}
-}
+	// 先展开别名和变量 ($? 需要在重置退出码之前读取)
+	cmdline = t.expandVars(t.expandAliases(cmdline))
+	t.lastExitCode = 0
 
-func (t *Terminal) handleAutoComplete() {
-	line := string(t.buffer[:t.cursor])
-	parts := strings.Fields(line)
+	// 重定向在每个管道命令内部处理 (见 runRedirected)
+	t.runPipelineWithOutput(splitPipeline(cmdline), stdin, finalOut)
+}
 
-	var lastWord string
-	if len(parts) > 0 && !strings.HasSuffix(line, " ") {
-		lastWord = parts[len(parts)-1]
-	} else {
-		lastWord = ""
+// splitPipeline 按不在引号内的 | 切分管道 (|| 留给语句切分处理)
+func splitPipeline(cmdline string) []string {
+	var segs []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(cmdline); i++ {
+		c := cmdline[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(cmdline):
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '|':
+			segs = append(segs, cmdline[start:i])
+			start = i + 1
+		}
 	}
+	return append(segs, cmdline[start:])
+}
 
-	isCmd := (len(parts) == 0) || (len(parts) == 1 && !strings.HasSuffix(line, " "))
-	var candidates []string
+// redirect 一个重定向操作：把描述符 fd (0、1、2，'&' 表示 1 和 2) 指向文件 name，或复制描述符 dup (2>&1、>&2)
+type redirect struct {
+	fd         byte
+	name       string
+	appendMode bool
+	dup        byte // 0 表示不是复制
+}
 
-	if isCmd {
-		for _, binDir := range []string{"/bin", "/usr/bin"} {
-			files, _ := t.FS.ListDir(binDir)
-			for _, f := range files {
-				if strings.HasPrefix(f.Name, lastWord) {
-					candidates = append(candidates, f.Name)
-				}
+// splitRedirects 从命令行中去掉不在引号内的重定向，返回剩余命令行和按出现顺序排列的重定向
+func splitRedirects(cmdline string) (rest string, redirs []redirect) {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(cmdline); i++ {
+		c := cmdline[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
 			}
+			b.WriteByte(c)
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+		}
+		if c == '<' && i+1 < len(cmdline) && (cmdline[i+1] == '<' || cmdline[i+1] == '(') {
+			// here-document 和进程替换不是文件重定向，原样保留
+			b.WriteString(cmdline[i : i+2])
+			i++
+			continue
+		}
+		if c != '>' && c != '<' {
+			b.WriteByte(c)
+			continue
+		}
+		// 紧贴在 > 或 < 前面的文件描述符：1>、2>、&>、0<
+		r := redirect{fd: '1'}
+		fds := "12&"
+		if c == '<' {
+			r.fd, fds = '0', "0"
+		}
+		if s := b.String(); len(s) > 0 && strings.IndexByte(fds, s[len(s)-1]) >= 0 &&
+			(len(s) == 1 || s[len(s)-2] == ' ' || s[len(s)-2] == '\t') {
+			r.fd = s[len(s)-1]
+			b.Reset()
+			b.WriteString(s[:len(s)-1])
+		}
+		if c == '>' && i+1 < len(cmdline) && cmdline[i+1] == '>' {
+			r.appendMode = true
+			i++
 		}
-	} else {
-		dir, filePrefix := path.Split(lastWord)
-		absDir := t.FS.Abs(dir)
-		files, _ := t.FS.ListDir(absDir)
-		for _, f := range files {
-			if strings.HasPrefix(f.Name, filePrefix) {
-				name := f.Name
-				if f.IsDir {
-					name += "/"
+		if c == '>' && i+1 < len(cmdline) && cmdline[i+1] == '&' {
+			i++
+			if i+1 < len(cmdline) && cmdline[i+1] >= '0' && cmdline[i+1] <= '9' { // >&2、2>&1
+				for i++; i+1 < len(cmdline) && cmdline[i+1] >= '0' && cmdline[i+1] <= '9'; i++ {
 				}
-				candidates = append(candidates, path.Join(dir, name))
+				r.dup = cmdline[i]
+				redirs = append(redirs, r)
+				continue
 			}
+			r.fd = '&' // >&file 等同于 &>file
 		}
-	}
-
-	// 去重并排序
-	unique := make(map[string]bool)
-	var clean []string
-	for _, c := range candidates {
-		if !unique[c] {
-			unique[c] = true
-			clean = append(clean, c)
-		}
-	}
-	sort.Strings(clean)
-	candidates = clean
-
-	if len(candidates) == 1 {
-		completion := candidates[0][len(lastWord):]
-		t.buffer = append(t.buffer, []rune(completion)...)
-		t.cursor += len(completion)
-		if !strings.HasSuffix(candidates[0], "/") {
-			t.buffer = append(t.buffer, ' ')
-			t.cursor++
+		j := i + 1
+		for j < len(cmdline) && (cmdline[j] == ' ' || cmdline[j] == '\t') {
+			j++
 		}
-		t.ClearLine()
-		t.Prompt()
-	} else if len(candidates) > 1 {
-		t.Print("\n")
-		// 简单列出
-		for _, c := range candidates {
-			fmt.Fprintf(t.RW, "%s  ", c)
+		var word strings.Builder
+		var q byte
+		for ; j < len(cmdline); j++ {
+			d := cmdline[j]
+			if q == 0 && (d == ' ' || d == '\t' || d == '|' || d == '>' || d == '<') {
+				break
+			}
+			switch {
+			case q != 0 && d == q:
+				q = 0
+			case q == 0 && (d == '\'' || d == '"'):
+				q = d
+			default:
+				word.WriteByte(d)
+			}
 		}
-		t.Print("\n")
-		t.Prompt()
+		i = j - 1
+		r.name = word.String()
+		redirs = append(redirs, r)
 	}
+	return b.String(), redirs
 }
 
-func (t *Terminal) execPipeline(cmdline string) {
-	t.lastExitCode = 0
-
-	// 重构：更健壮的重定向和管道处理
-	// 1. 首先确定最终的输出目的地
-	finalOut := &CRLFWriter{w: t.RW} // 默认输出到终端
-	var fileWriteBuffer *bytes.Buffer
-	var fileWritePath string
-
-	parts := parseArgs(cmdline)
-	args := parts
-	for i := 0; i < len(parts); i++ {
-		if (parts[i] == ">" || parts[i] == ">>") && i+1 < len(parts) {
-			fileWritePath = t.FS.Abs(parts[i+1])
-			fileWriteBuffer = &bytes.Buffer{}
-			// 从传递给命令的参数中移除重定向标记
-			args = parts[:i]
-			cmdline = strings.Join(args, " ") // 重建不带重定向的命令行
-			break
+// runRedirected 按顺序应用重定向后执行一条命令：< 文件作为标准输入，标准输出和标准错误可以指向文件或互相复制
+func (t *Terminal) runRedirected(args []string, redirs []redirect, in io.Reader, out io.Writer) {
+	stderr := t.Stderr
+	fds := map[byte]io.Writer{'1': out, '2': stderr}
+	var flushes []func()
+	for _, r := range redirs {
+		if r.fd == '0' {
+			f, reason := t.openInput(t.FS.Abs(t.expandTilde(r.name)), in)
+			if f == nil {
+				// 与 bash 一样不执行命令，但在它之前的输出重定向已经创建了文件
+				fmt.Fprintf(stderr, "-bash: %s: %s\n", r.name, reason)
+				for _, flush := range flushes {
+					flush()
+				}
+				t.lastExitCode = 1
+				return
+			}
+			in = f
+			continue
+		}
+		if r.dup != 0 {
+			if w, ok := fds[r.dup]; ok && r.fd != '&' {
+				fds[r.fd] = w
+			}
+			continue
+		}
+		w, flush := t.redirectWriter(r.name, r.appendMode)
+		flushes = append(flushes, flush)
+		if r.fd == '&' {
+			fds['1'], fds['2'] = w, w
+		} else {
+			fds[r.fd] = w
 		}
 	}
 
-	// 2. 运行管道，将输出定向到正确的目标
-	var effectiveOut io.Writer = finalOut
-	if fileWriteBuffer != nil {
-		effectiveOut = fileWriteBuffer
+	t.Stderr = fds['2']
+	t.runCommand(args, in, fds['1'])
+	t.Stderr = stderr
+	for _, flush := range flushes {
+		flush()
 	}
+}
 
-	pipeline := strings.Split(cmdline, "|")
-	t.runPipelineWithOutput(pipeline, effectiveOut)
+// recoverCommand 捕获内置命令实现中的 panic：记录日志，像进程段错误一样报告并以 128+SIGSEGV 退出，
+// 而不是让 panic 结束整个服务器进程
+func (t *Terminal) recoverCommand(name string) {
+	if r := recover(); r != nil {
+		log.Printf("[Panic] %s: %s: %v\n%s", t.Remote, name, r, debug.Stack())
+		fmt.Fprintln(t.Stderr, "段错误 (核心已转储)")
+		t.lastExitCode = 128 + sigSEGV
+	}
+}
 
-	// 3. 如果输出被重定向，则将缓冲区内容写入文件
-	if fileWriteBuffer != nil {
-		// 注意：'>>' (追加) 在此被简化为覆盖，对于蜜罐来说是可接受的
-		t.FS.Write(fileWritePath, fileWriteBuffer.Bytes(), 0644)
+// redirectWriter 返回重定向到文件 name 的 Writer，命令结束后调用 flush 把内容写入文件
+func (t *Terminal) redirectWriter(name string, appendMode bool) (io.Writer, func()) {
+	p := t.FS.Abs(t.expandTilde(name))
+	if p == "/dev/null" {
+		return io.Discard, func() {}
+	}
+	buf := &bytes.Buffer{}
+	return buf, func() {
+		data := buf.Bytes()
+		if e, ok := t.FS.GetEntry(p); ok && appendMode && !e.IsDir {
+			data = append(append([]byte{}, e.Content...), data...)
+		}
+		if err := t.FS.Write(p, data, 0); err != nil {
+			fmt.Fprintf(t.Stderr, "-bash: %s: %v\n", name, err)
+			t.lastExitCode = 1
+		}
 	}
 }
 
-func (t *Terminal) runPipelineWithOutput(pipeline []string, finalOut io.Writer) {
-	var commands [][]string
+func (t *Terminal) runPipelineWithOutput(pipeline []string, stdin io.Reader, finalOut io.Writer) {
+	type command struct {
+		args   []string
+		redirs []redirect
+	}
+	var commands []command
 	for _, cmdStr := range pipeline {
-		args := parseArgs(cmdStr)
+		rest, redirs := splitRedirects(cmdStr)
+		args := parseArgs(rest)
 		// 预先过滤掉空命令
 		if len(args) > 0 {
-			commands = append(commands, args)
+			commands = append(commands, command{args, redirs})
 		}
 	}
 
@@ -423,16 +518,22 @@ func (t *Terminal) runPipelineWithOutput(pipeline []string, finalOut io.Writer)
 		return
 	}
 
+	if stdin == nil {
+		stdin = &bytes.Buffer{}
+	}
 	if len(commands) == 1 {
 		// 单个命令直接执行，不需要建立管道
-		t.runCommand(commands[0], &bytes.Buffer{}, finalOut)
+		t.runRedirected(commands[0].args, commands[0].redirs, stdin, finalOut)
 		return
 	}
 
+	// 与 bash 一样，管道中的每个命令在各自的子 shell 中并发执行，
+	// 标准错误和退出码互不干扰，整条管道的退出码取最后一个命令的
 	var wg sync.WaitGroup
-	var in io.Reader = &bytes.Buffer{}
+	in := stdin
+	subs := make([]*Terminal, len(commands))
 
-	for i, args := range commands {
+	for i, c := range commands {
 		wg.Add(1)
 
 		var out io.Writer
@@ -453,7 +554,8 @@ func (t *Terminal) runPipelineWithOutput(pipeline []string, finalOut io.Writer)
 			pipeCloser = pipeW
 		}
 
-		go func(args []string, stdin io.Reader, stdout io.Writer, outCloser io.Closer) {
+		subs[i] = t.fork(t.job)
+		go func(sub *Terminal, c command, stdin io.Reader, stdout io.Writer, outCloser io.Closer) {
 			defer wg.Done()
 
 			// 执行完后关闭输出端，通知下游 EOF
@@ -466,25 +568,43 @@ func (t *Terminal) runPipelineWithOutput(pipeline []string, finalOut io.Writer)
 				defer r.Close()
 			}
 
-			t.runCommand(args, stdin, stdout)
-		}(args, in, out, pipeCloser)
+			defer sub.recoverCommand(c.args[0])
+			sub.runRedirected(c.args, c.redirs, stdin, stdout)
+		}(subs[i], c, in, out, pipeCloser)
 
 		in = pipeR
 	}
 	wg.Wait()
+	t.lastExitCode = subs[len(subs)-1].lastExitCode
 }
 
 func parseArgs(cmdline string) []string {
 	var args []string
 	var buf strings.Builder
-	inQuote := false
+	inQuote, quoted := false, false // quoted: 当前参数含引号 ('' 也是一个参数)
 	var quoteChar rune
+	escaped := false
 
 	for _, r := range cmdline {
+		if escaped {
+			// 双引号内的反斜杠只转义 " \ $ `，其余原样保留
+			if inQuote && !strings.ContainsRune("\"\\$`", r) {
+				buf.WriteByte('\\')
+			}
+			buf.WriteRune(r)
+			escaped = false
+			continue
+		}
 		switch r {
+		case '\\':
+			if inQuote && quoteChar == '\'' {
+				buf.WriteRune(r)
+			} else {
+				escaped = true
+			}
 		case '"', '\'':
 			if !inQuote {
-				inQuote = true
+				inQuote, quoted = true, true
 				quoteChar = r
 			} else if r == quoteChar {
 				inQuote = false
@@ -493,9 +613,10 @@ func parseArgs(cmdline string) []string {
 			}
 		case ' ', '\t':
 			if !inQuote {
-				if buf.Len() > 0 {
+				if buf.Len() > 0 || quoted {
 					args = append(args, buf.String())
 					buf.Reset()
+					quoted = false
 				}
 			} else {
 				buf.WriteRune(r)
@@ -504,7 +625,10 @@ func parseArgs(cmdline string) []string {
 			buf.WriteRune(r)
 		}
 	}
-	if buf.Len() > 0 {
+	if escaped {
+		buf.WriteByte('\\')
+	}
+	if buf.Len() > 0 || quoted {
 		args = append(args, buf.String())
 	}
 	return args
@@ -514,3 +638,72 @@ func parseArgs(cmdline string) []string {
 func (t *Terminal) Exec(cmdline string) {
 	t.execPipeline(cmdline)
 }
+
+// RunShellCommand 以指定用户在 dir 下非交互地执行一条命令，返回原始输出 (无 TTY、无 CRLF 转换)
+// 供 Web Shell、CGI 等非终端入口复用同一套命令实现
+func RunShellCommand(fs *SessionFS, remote, user, dir, cmdline string) []byte {
+	var out bytes.Buffer
+	rw := &struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(nil), &out}
+	t := NewTerminal(rw, fs.View(dir), rEnv(fs, user), 80, 24)
+	t.Remote = remote
+	t.Stderr = &out
+	t.execPipelineTo(cmdline, &out)
+	return out.Bytes()
+}
+
+// maxScriptDepth 限制 sh 的嵌套层数 (例如脚本里再次 curl | sh)
+const maxScriptDepth = 8
+
+// runScript 模拟 sh/bash：支持 -c、脚本文件以及从标准输入读取 (curl ... | sh)
+func (t *Terminal) runScript(name string, args []string, in io.Reader, out io.Writer) {
+	var script string
+	src := "stdin"
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if a == "-c" {
+			if i+1 >= len(args) {
+				fmt.Fprintf(t.Stderr, "%s: -c: 选项需要一个参数\n", name)
+				t.lastExitCode = 2
+				return
+			}
+			script, src = args[i+1], "-c"
+			break
+		}
+		if strings.HasPrefix(a, "-") {
+			continue // -x、-e、-s 等
+		}
+		p := t.FS.Abs(a)
+		e, ok := t.FS.GetEntry(p)
+		if !ok || e.IsDir {
+			fmt.Fprintf(t.Stderr, "%s: %s: 没有那个文件或目录\n", name, a)
+			t.lastExitCode = 127
+			return
+		}
+		e.mu.RLock()
+		script = string(e.Content)
+		e.mu.RUnlock()
+		src = p
+		break
+	}
+	if src == "stdin" {
+		data, _ := io.ReadAll(io.LimitReader(in, MaxFileSize))
+		script = string(data)
+	}
+	if strings.TrimSpace(script) == "" {
+		return
+	}
+	if t.scriptDepth >= maxScriptDepth {
+		fmt.Fprintf(t.Stderr, "%s: 超出最大嵌套层数\n", name)
+		t.lastExitCode = 1
+		return
+	}
+	t.scriptDepth++
+	defer func() { t.scriptDepth-- }()
+	log.Printf("[Shell] %s: %s running script from %s (%d bytes)", t.Remote, name, src, len(script))
+
+	// 脚本中的 exit 只结束脚本，不结束会话
+	t.runLines(script, out)
+}
@@ -0,0 +1,1682 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==========================================
+// 文本处理工具 (cut / sort / uniq / tr / tee / xargs)
+// sed 和 awk 分别在 sed.go、awk.go 中实现
+// ==========================================
+
+// openInput 打开文本工具的一个输入文件，"-" 表示标准输入；失败时返回原因
+func (t *Terminal) openInput(name string, in io.Reader) (io.Reader, string) {
+	if name == "-" {
+		if in == nil {
+			return strings.NewReader(""), ""
+		}
+		return in, ""
+	}
+	if r, ok := t.FS.Stream(t.FS.Abs(name)); ok {
+		return r, ""
+	}
+	e, ok := t.FS.GetEntry(t.FS.Abs(name))
+	switch {
+	case !ok:
+		return nil, "没有那个文件或目录"
+	case e.IsDir:
+		return nil, "是一个目录"
+	}
+	e.mu.RLock()
+	data := e.Content
+	e.mu.RUnlock()
+	return bytes.NewReader(data), ""
+}
+
+// writeTextFile 把工具的输出写入会话文件系统，失败时按 "cmd: 文件: 原因" 报告
+func (t *Terminal) writeTextFile(cmd, name string, data []byte, appendMode bool) bool {
+	p := t.FS.Abs(name)
+	reason := ""
+	e, exists := t.FS.GetEntry(p)
+	if exists && e.IsDir {
+		reason = "是一个目录"
+	} else if d, ok := t.FS.GetEntry(pathDir(p)); !ok || !d.IsDir {
+		reason = "没有那个文件或目录"
+	} else {
+		if exists && appendMode {
+			e.mu.RLock()
+			data = append(append([]byte{}, e.Content...), data...)
+			e.mu.RUnlock()
+		}
+		if err := t.FS.Write(p, data, 0); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		fmt.Fprintf(t.Stderr, "%s: %s: %s\n", cmd, name, reason)
+		return false
+	}
+	return true
+}
+
+// pathDir 返回绝对路径的父目录
+func pathDir(p string) string {
+	if i := strings.LastIndexByte(p, '/'); i > 0 {
+		return p[:i]
+	}
+	return "/"
+}
+
+// usageError 输出 GNU 风格的参数错误及 --help 提示
+func (t *Terminal) usageError(cmd, msg string, code int) {
+	fmt.Fprintf(t.Stderr, "%s: %s\nTry '%s --help' for more information.\n", cmd, msg, cmd)
+	t.lastExitCode = code
+}
+
+// lineReader 逐行读取输入 (不受行长限制)，返回的行不含换行符
+type lineReader struct {
+	r  *bufio.Reader
+	nl bool // 最近读到的一行是否以换行结尾
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+func (l *lineReader) next() (string, bool) {
+	s, err := l.r.ReadString('\n')
+	if s == "" && err != nil {
+		return "", false
+	}
+	l.nl = strings.HasSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\n"), true
+}
+
+// ---------- cut ----------
+
+// cutRange 闭区间 [lo, hi]，hi 为 0 表示直到行尾
+type cutRange struct{ lo, hi int }
+
+type cutList []cutRange
+
+func (l cutList) has(n int) bool {
+	for _, r := range l {
+		if n >= r.lo && (r.hi == 0 || n <= r.hi) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCutList 解析 1,3-5,7- 形式的列表
+func parseCutList(s string) (cutList, string) {
+	var l cutList
+	for _, part := range strings.Split(s, ",") {
+		lo, hi := part, part
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+			if lo == "" && hi == "" {
+				return nil, "无效的范围，没有端点：'-'"
+			}
+		}
+		a, b := 1, 0
+		var err error
+		if lo != "" {
+			if a, err = strconv.Atoi(lo); err != nil || a < 0 {
+				return nil, fmt.Sprintf("无效的字段值 '%s'", part)
+			}
+		}
+		if hi != "" {
+			if b, err = strconv.Atoi(hi); err != nil || b < 0 {
+				return nil, fmt.Sprintf("无效的字段范围 '%s'", part)
+			}
+		}
+		if a == 0 || hi != "" && b == 0 {
+			return nil, "字段和位置从 1 开始编号"
+		}
+		if b != 0 && b < a {
+			return nil, "无效的递减范围"
+		}
+		l = append(l, cutRange{a, b})
+	}
+	return l, ""
+}
+
+func (t *Terminal) cmdCut(args []string, in io.Reader, out io.Writer) {
+	var list cutList
+	mode := byte(0) // 'b'、'c' 或 'f'
+	delim, outDelim := "\t", ""
+	hasDelim, onlyDelim, complement := false, false, false
+	var files []string
+	setList := func(m byte, v string) bool {
+		if mode != 0 {
+			t.usageError("cut", "只能指定一个列表类型", 1)
+			return false
+		}
+		l, msg := parseCutList(v)
+		if msg != "" {
+			t.usageError("cut", msg, 1)
+			return false
+		}
+		mode, list = m, l
+		return true
+	}
+	ok := true
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--complement":
+			complement = true
+		case a == "--only-delimited":
+			onlyDelim = true
+		case strings.HasPrefix(a, "--output-delimiter="):
+			outDelim = strings.TrimPrefix(a, "--output-delimiter=")
+		case strings.HasPrefix(a, "--delimiter="):
+			delim, hasDelim = strings.TrimPrefix(a, "--delimiter="), true
+		case strings.HasPrefix(a, "--fields="):
+			ok = setList('f', strings.TrimPrefix(a, "--fields="))
+		case strings.HasPrefix(a, "--characters="):
+			ok = setList('c', strings.TrimPrefix(a, "--characters="))
+		case strings.HasPrefix(a, "--bytes="):
+			ok = setList('b', strings.TrimPrefix(a, "--bytes="))
+		case strings.HasPrefix(a, "--"):
+			t.usageError("cut", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "bcfd", func(opt byte, val string) {
+				if !ok {
+					return
+				}
+				switch opt {
+				case 'b', 'c', 'f':
+					ok = setList(opt, val)
+				case 'd':
+					delim, hasDelim = val, true
+				case 's':
+					onlyDelim = true
+				case 'n':
+				default:
+					t.usageError("cut", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+					ok = false
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	switch {
+	case !ok:
+		return
+	case mode == 0:
+		t.usageError("cut", "您必须指定一组字节、字符或字段的列表", 1)
+		return
+	case hasDelim && mode != 'f':
+		t.usageError("cut", "仅当操作字段时才能指定分隔符", 1)
+		return
+	case onlyDelim && mode != 'f':
+		t.usageError("cut", "仅当操作字段时才能抑制无分隔符的行", 1)
+		return
+	case len([]rune(delim)) != 1:
+		t.usageError("cut", "分隔符必须是单个字符", 1)
+		return
+	}
+	if outDelim == "" && mode == 'f' {
+		outDelim = delim
+	}
+	pick := func(n int) bool { return list.has(n) != complement }
+
+	cutLine := func(line string) (string, bool) {
+		var b strings.Builder
+		switch mode {
+		case 'b':
+			for i := 0; i < len(line); i++ {
+				if pick(i + 1) {
+					b.WriteByte(line[i])
+				}
+			}
+		case 'c':
+			for i, r := range []rune(line) {
+				if pick(i + 1) {
+					b.WriteRune(r)
+				}
+			}
+		default:
+			if !strings.Contains(line, delim) {
+				return line, !onlyDelim
+			}
+			first := true
+			for i, f := range strings.Split(line, delim) {
+				if pick(i + 1) {
+					if !first {
+						b.WriteString(outDelim)
+					}
+					b.WriteString(f)
+					first = false
+				}
+			}
+		}
+		return b.String(), true
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, f := range files {
+		r, reason := t.openInput(f, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "cut: %s: %s\n", f, reason)
+			t.lastExitCode = 1
+			continue
+		}
+		lr := newLineReader(r)
+		for {
+			line, more := lr.next()
+			if !more {
+				break
+			}
+			if s, keep := cutLine(line); keep {
+				fmt.Fprintln(out, s)
+			}
+		}
+	}
+}
+
+// ---------- sort ----------
+
+// sortKey 一个 -k 排序键，字段和字符位置从 1 开始，endField 为 0 表示到行尾
+type sortKey struct {
+	startField, startChar int
+	endField, endChar     int
+	opts                  sortOpts
+}
+
+type sortOpts struct {
+	numeric, general, human, version, month, random bool
+	fold, blanks, reverse                           bool
+}
+
+func (o sortOpts) any() bool { return o != sortOpts{} }
+
+// set 设置一个排序选项字母，不认识的字母返回 false
+func (o *sortOpts) set(c byte) bool {
+	switch c {
+	case 'n':
+		o.numeric = true
+	case 'g':
+		o.general = true
+	case 'h':
+		o.human = true
+	case 'V':
+		o.version = true
+	case 'M':
+		o.month = true
+	case 'R':
+		o.random = true
+	case 'f':
+		o.fold = true
+	case 'b':
+		o.blanks = true
+	case 'r':
+		o.reverse = true
+	case 'd', 'i':
+	default:
+		return false
+	}
+	return true
+}
+
+// parseSortKey 解析 F[.C][OPTS][,F[.C][OPTS]]
+func parseSortKey(s string) (sortKey, bool) {
+	var k sortKey
+	pos := func(s string) (int, int, sortOpts, bool) {
+		var o sortOpts
+		j := len(s)
+		for j > 0 && !unicode.IsDigit(rune(s[j-1])) {
+			j--
+		}
+		for _, c := range []byte(s[j:]) {
+			if !o.set(c) {
+				return 0, 0, o, false
+			}
+		}
+		num := s[:j]
+		ch := 0
+		if i := strings.IndexByte(num, '.'); i >= 0 {
+			var err error
+			if ch, err = strconv.Atoi(num[i+1:]); err != nil {
+				return 0, 0, o, false
+			}
+			num = num[:i]
+		}
+		f, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, 0, o, false
+		}
+		return f, ch, o, true
+	}
+	start, end := s, ""
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		start, end = s[:i], s[i+1:]
+	}
+	var ok bool
+	var o2 sortOpts
+	if k.startField, k.startChar, k.opts, ok = pos(start); !ok || k.startField == 0 {
+		return k, false
+	}
+	if end != "" {
+		if k.endField, k.endChar, o2, ok = pos(end); !ok || k.endField == 0 {
+			return k, false
+		}
+		if o2.any() {
+			o2.reverse = o2.reverse || k.opts.reverse
+			k.opts = o2
+		}
+	}
+	return k, true
+}
+
+// fieldBounds 返回各字段在行内的起止位置：有 -t 时按分隔符切分，
+// 否则以空白到非空白的边界切分，每个字段带着前导空白
+func fieldBounds(line, sep string) (starts, ends []int) {
+	if sep != "" {
+		pos := 0
+		for _, f := range strings.Split(line, sep) {
+			starts, ends = append(starts, pos), append(ends, pos+len(f))
+			pos += len(f) + len(sep)
+		}
+		return
+	}
+	for i := 0; i < len(line); {
+		starts = append(starts, i)
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		ends = append(ends, i)
+	}
+	return
+}
+
+// keyText 取出排序键对应的文本
+func (k sortKey) keyText(line, sep string) string {
+	starts, ends := fieldBounds(line, sep)
+	if k.startField > len(starts) {
+		return ""
+	}
+	skip := func(i int) int {
+		for k.opts.blanks && i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		return i
+	}
+	from := skip(starts[k.startField-1])
+	if k.startChar > 0 {
+		from += k.startChar - 1
+	}
+	to := len(line)
+	if k.endField > 0 && k.endField <= len(starts) {
+		to = ends[k.endField-1]
+		if k.endChar > 0 {
+			to = skip(starts[k.endField-1]) + k.endChar
+		}
+	}
+	from, to = min(from, len(line)), min(to, len(line))
+	if to < from {
+		return ""
+	}
+	return line[from:to]
+}
+
+// leadingNumber 解析开头的数字 (允许前导空白、负号和小数点)，没有数字时为 0
+func leadingNumber(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	v, _ := strconv.ParseFloat(strings.TrimRight(s[:i], "."), 64)
+	return v
+}
+
+// humanNumber 解析 2K、1.5G 之类带单位后缀的大小
+func humanNumber(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	v := leadingNumber(s)
+	i := 0
+	for i < len(s) && (s[i] == '-' || s[i] == '.' || s[i] >= '0' && s[i] <= '9') {
+		i++
+	}
+	if i < len(s) {
+		if p := strings.IndexByte("KMGTPEZY", byte(unicode.ToUpper(rune(s[i])))); p >= 0 {
+			v *= math.Pow(1024, float64(p+1))
+		}
+	}
+	return v
+}
+
+var monthNames = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+
+func monthIndex(s string) int {
+	s = strings.ToUpper(strings.TrimLeft(s, " \t"))
+	for i, m := range monthNames {
+		if strings.HasPrefix(s, m) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// versionCompare 自然顺序比较，连续数字按数值大小比较
+func versionCompare(a, b string) int {
+	for a != "" && b != "" {
+		da, db := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+		if da && db {
+			i, j := 0, 0
+			for i < len(a) && unicode.IsDigit(rune(a[i])) {
+				i++
+			}
+			for j < len(b) && unicode.IsDigit(rune(b[j])) {
+				j++
+			}
+			na, nb := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(na) != len(nb) {
+				return cmp.Compare(len(na), len(nb))
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return cmp.Compare(int(a[0]), int(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// compareBy 按一组排序选项比较两个键
+func compareBy(a, b string, o sortOpts) int {
+	if o.blanks {
+		a, b = strings.TrimLeft(a, " \t"), strings.TrimLeft(b, " \t")
+	}
+	var c int
+	switch {
+	case o.numeric:
+		c = cmp.Compare(leadingNumber(a), leadingNumber(b))
+	case o.general:
+		fa, ea := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		fb, eb := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		switch {
+		case ea != nil && eb != nil:
+		case ea != nil:
+			c = -1
+		case eb != nil:
+			c = 1
+		default:
+			c = cmp.Compare(fa, fb)
+		}
+	case o.human:
+		c = cmp.Compare(humanNumber(a), humanNumber(b))
+	case o.month:
+		c = cmp.Compare(monthIndex(a), monthIndex(b))
+	case o.version:
+		c = versionCompare(a, b)
+	case o.random:
+		ha, hb := fnv.New64a(), fnv.New64a()
+		ha.Write([]byte(a))
+		hb.Write([]byte(b))
+		if c = cmp.Compare(ha.Sum64(), hb.Sum64()); c == 0 {
+			c = strings.Compare(a, b)
+		}
+	case o.fold:
+		c = strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+	default:
+		c = strings.Compare(a, b)
+	}
+	if o.reverse {
+		c = -c
+	}
+	return c
+}
+
+func (t *Terminal) cmdSort(args []string, in io.Reader, out io.Writer) {
+	var global sortOpts
+	var keys []sortKey
+	var files []string
+	sep, output := "", ""
+	unique, stable, check := false, false, false
+	ok := true
+	fail := func(msg string) {
+		if ok {
+			t.usageError("sort", msg, 2)
+			ok = false
+		}
+	}
+	addKey := func(v string) {
+		if k, good := parseSortKey(v); good {
+			keys = append(keys, k)
+		} else {
+			fail(fmt.Sprintf("无效的键位置 '%s'", v))
+		}
+	}
+	setSep := func(v string) {
+		switch {
+		case v == `\0`:
+			sep = "\x00"
+		case len([]rune(v)) != 1:
+			fail(fmt.Sprintf("多字符制表符 '%s'", v))
+		default:
+			sep = v
+		}
+	}
+	longOpts := map[string]byte{
+		"--numeric-sort": 'n', "--general-numeric-sort": 'g', "--human-numeric-sort": 'h',
+		"--version-sort": 'V', "--month-sort": 'M', "--random-sort": 'R', "--ignore-case": 'f',
+		"--ignore-leading-blanks": 'b', "--reverse": 'r', "--dictionary-order": 'd',
+	}
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case longOpts[a] != 0:
+			global.set(longOpts[a])
+		case a == "--unique":
+			unique = true
+		case a == "--stable":
+			stable = true
+		case a == "--check":
+			check = true
+		case strings.HasPrefix(a, "--key="):
+			addKey(strings.TrimPrefix(a, "--key="))
+		case strings.HasPrefix(a, "--field-separator="):
+			setSep(strings.TrimPrefix(a, "--field-separator="))
+		case strings.HasPrefix(a, "--output="):
+			output = strings.TrimPrefix(a, "--output=")
+		case strings.HasPrefix(a, "--parallel=") || strings.HasPrefix(a, "--buffer-size=") || strings.HasPrefix(a, "--temporary-directory="):
+		case strings.HasPrefix(a, "--"):
+			fail(fmt.Sprintf("无法识别的选项 '%s'", a))
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "ktoST", func(opt byte, val string) {
+				switch opt {
+				case 'k':
+					addKey(val)
+				case 't':
+					setSep(val)
+				case 'o':
+					output = val
+				case 'u':
+					unique = true
+				case 's':
+					stable = true
+				case 'c', 'C':
+					check = true
+				case 'S', 'T', 'z':
+				default:
+					if !global.set(opt) {
+						fail(fmt.Sprintf("无效的选项 -- '%c'", opt))
+					}
+				}
+			})
+		default:
+			files = append(files, a)
+		}
+	}
+	if !ok {
+		return
+	}
+	if len(keys) == 0 {
+		keys = []sortKey{{startField: 1}}
+	}
+	// 全局选项只作用于没有自带选项的键
+	for i := range keys {
+		if !keys[i].opts.any() {
+			keys[i].opts = global
+		}
+	}
+	wholeLine := len(keys) == 1 && keys[0].startField == 1 && keys[0].startChar == 0 && keys[0].endField == 0
+	compare := func(a, b string) int {
+		for _, k := range keys {
+			ka, kb := a, b
+			if !wholeLine {
+				ka, kb = k.keyText(a, sep), k.keyText(b, sep)
+			}
+			if c := compareBy(ka, kb, k.opts); c != 0 {
+				return c
+			}
+		}
+		if unique || stable {
+			return 0
+		}
+		// 所有键都相等时按整行字节序比较
+		c := strings.Compare(a, b)
+		if global.reverse {
+			c = -c
+		}
+		return c
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var lines []string
+	for _, f := range files {
+		r, reason := t.openInput(f, in)
+		if reason == "是一个目录" {
+			fmt.Fprintf(t.Stderr, "sort: 读取失败: %s: %s\n", f, reason)
+			t.lastExitCode = 2
+			return
+		} else if reason != "" {
+			fmt.Fprintf(t.Stderr, "sort: 无法读取: %s: %s\n", f, reason)
+			t.lastExitCode = 2
+			return
+		}
+		lr := newLineReader(r)
+		for {
+			line, more := lr.next()
+			if !more {
+				break
+			}
+			if check && len(lines) > 0 {
+				if c := compare(lines[len(lines)-1], line); c > 0 || c == 0 && unique {
+					fmt.Fprintf(t.Stderr, "sort: %s:%d: 无序: %s\n", f, len(lines)+1, line)
+					t.lastExitCode = 1
+					return
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	if check {
+		return
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return compare(lines[i], lines[j]) < 0 })
+	var b bytes.Buffer
+	for i, line := range lines {
+		if unique && i > 0 && compare(lines[i-1], line) == 0 {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if output != "" && output != "-" {
+		if !t.writeTextFile("sort", output, b.Bytes(), false) {
+			t.lastExitCode = 2
+		}
+		return
+	}
+	out.Write(b.Bytes())
+}
+
+// ---------- uniq ----------
+
+func (t *Terminal) cmdUniq(args []string, in io.Reader, out io.Writer) {
+	count, dupOnly, uniqOnly, allDups, fold := false, false, false, false, false
+	skipFields, skipChars, checkChars := 0, 0, -1
+	var names []string
+	ok := true
+	number := func(opt, v string) int {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			if ok {
+				fmt.Fprintf(t.Stderr, "uniq: %s: 无效的%s\n", v, opt)
+				t.lastExitCode = 1
+			}
+			ok = false
+		}
+		return n
+	}
+	for i := 1; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--count":
+			count = true
+		case a == "--repeated":
+			dupOnly = true
+		case a == "--unique":
+			uniqOnly = true
+		case a == "--ignore-case":
+			fold = true
+		case a == "--all-repeated":
+			allDups = true
+		case strings.HasPrefix(a, "--skip-fields="):
+			skipFields = number("要跳过的字段数", strings.TrimPrefix(a, "--skip-fields="))
+		case strings.HasPrefix(a, "--skip-chars="):
+			skipChars = number("要跳过的字节数", strings.TrimPrefix(a, "--skip-chars="))
+		case strings.HasPrefix(a, "--check-chars="):
+			checkChars = number("要比较的字节数", strings.TrimPrefix(a, "--check-chars="))
+		case strings.HasPrefix(a, "--"):
+			t.usageError("uniq", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "fsw", func(opt byte, val string) {
+				switch opt {
+				case 'c':
+					count = true
+				case 'd':
+					dupOnly = true
+				case 'u':
+					uniqOnly = true
+				case 'D':
+					allDups = true
+				case 'i':
+					fold = true
+				case 'f':
+					skipFields = number("要跳过的字段数", val)
+				case 's':
+					skipChars = number("要跳过的字节数", val)
+				case 'w':
+					checkChars = number("要比较的字节数", val)
+				case 'z':
+				default:
+					if ok {
+						t.usageError("uniq", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+						ok = false
+					}
+				}
+			})
+		default:
+			names = append(names, a)
+		}
+	}
+	if !ok {
+		return
+	}
+	if len(names) > 2 {
+		t.usageError("uniq", fmt.Sprintf("额外的操作数 '%s'", names[2]), 1)
+		return
+	}
+	input := "-"
+	if len(names) > 0 {
+		input = names[0]
+	}
+	r, reason := t.openInput(input, in)
+	if reason != "" {
+		fmt.Fprintf(t.Stderr, "uniq: %s: %s\n", input, reason)
+		t.lastExitCode = 1
+		return
+	}
+	dst := out
+	var buf bytes.Buffer
+	if len(names) == 2 && names[1] != "-" {
+		dst = &buf
+	}
+
+	key := func(line string) string {
+		for i := 0; i < skipFields; i++ {
+			line = strings.TrimLeft(line, " \t")
+			if j := strings.IndexAny(line, " \t"); j >= 0 {
+				line = line[j:]
+			} else {
+				line = ""
+			}
+		}
+		if skipChars > len(line) {
+			line = ""
+		} else {
+			line = line[skipChars:]
+		}
+		if checkChars >= 0 && checkChars < len(line) {
+			line = line[:checkChars]
+		}
+		if fold {
+			line = strings.ToLower(line)
+		}
+		return line
+	}
+	var group []string
+	flush := func() {
+		n := len(group)
+		if n == 0 || dupOnly && n < 2 || uniqOnly && n > 1 || allDups && n < 2 {
+			return
+		}
+		switch {
+		case allDups:
+			for _, l := range group {
+				fmt.Fprintln(dst, l)
+			}
+		case count:
+			fmt.Fprintf(dst, "%7d %s\n", n, group[0])
+		default:
+			fmt.Fprintln(dst, group[0])
+		}
+	}
+	lr := newLineReader(r)
+	for {
+		line, more := lr.next()
+		if !more {
+			break
+		}
+		if len(group) > 0 && key(group[0]) != key(line) {
+			flush()
+			group = group[:0]
+		}
+		group = append(group, line)
+	}
+	flush()
+	if dst == &buf {
+		if !t.writeTextFile("uniq", names[1], buf.Bytes(), false) {
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// ---------- tr ----------
+
+func runeIndex(rs []rune, r rune) int {
+	for i, c := range rs {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+var trClasses = map[string]func(rune) bool{
+	"alpha":  unicode.IsLetter,
+	"digit":  func(r rune) bool { return r >= '0' && r <= '9' },
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || r >= '0' && r <= '9' },
+	"upper":  unicode.IsUpper,
+	"lower":  unicode.IsLower,
+	"space":  unicode.IsSpace,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"punct":  func(r rune) bool { return r < 128 && (unicode.IsPunct(r) || unicode.IsSymbol(r)) },
+	"cntrl":  unicode.IsControl,
+	"print":  func(r rune) bool { return r >= 32 && r < 127 },
+	"graph":  func(r rune) bool { return r > 32 && r < 127 },
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// trUnescape 解析 tr 字符串中的反斜杠转义
+func trUnescape(s string) []rune {
+	var res []rune
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '\\' || i+1 == len(rs) {
+			res = append(res, rs[i])
+			continue
+		}
+		i++
+		switch c := rs[i]; c {
+		case 'n':
+			res = append(res, '\n')
+		case 't':
+			res = append(res, '\t')
+		case 'r':
+			res = append(res, '\r')
+		case 'a':
+			res = append(res, '\a')
+		case 'b':
+			res = append(res, '\b')
+		case 'f':
+			res = append(res, '\f')
+		case 'v':
+			res = append(res, '\v')
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				for j := 0; j < 3 && i < len(rs) && rs[i] >= '0' && rs[i] <= '7'; j++ {
+					n = n*8 + int(rs[i]-'0')
+					i++
+				}
+				i--
+				res = append(res, rune(n))
+			} else {
+				res = append(res, c)
+			}
+		}
+	}
+	return res
+}
+
+// expandTrSet 展开 a-z、[:alpha:]、[x*n] 等写法；fill 返回 [x*] 在集合中的位置 (-1 表示没有)
+func expandTrSet(s string) (set []rune, fill int, msg string) {
+	fill = -1
+	rs := trUnescape(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] == '[' && i+1 < len(rs) {
+			rest := string(rs[i+1:])
+			if strings.HasPrefix(rest, ":") {
+				if j := strings.Index(rest, ":]"); j > 0 {
+					name := rest[1:j]
+					fn := trClasses[name]
+					if fn == nil {
+						return nil, fill, fmt.Sprintf("无效的字符类 '%s'", name)
+					}
+					if name == "upper" || name == "lower" {
+						// 大小写类按字母顺序展开，转换时一一对应
+						base := 'A'
+						if name == "lower" {
+							base = 'a'
+						}
+						for c := base; c < base+26; c++ {
+							set = append(set, c)
+						}
+					} else {
+						for c := rune(0); c < 256; c++ {
+							if fn(c) {
+								set = append(set, c)
+							}
+						}
+					}
+					i += len([]rune(rest[:j+2]))
+					continue
+				}
+			}
+			if i+3 < len(rs) && rs[i+2] == '*' {
+				if j := runeIndex(rs[i+3:], ']'); j >= 0 {
+					c, num := rs[i+1], string(rs[i+3:i+3+j])
+					if num == "" {
+						fill = len(set)
+						set = append(set, c)
+					} else {
+						base := 10
+						if strings.HasPrefix(num, "0") {
+							base = 8
+						}
+						n, err := strconv.ParseInt(num, base, 32)
+						if err != nil {
+							return nil, fill, fmt.Sprintf("无效的重复计数 '%s'", num)
+						}
+						for k := 0; k < int(n); k++ {
+							set = append(set, c)
+						}
+					}
+					i += 3 + j
+					continue
+				}
+			}
+		}
+		if i+2 < len(rs) && rs[i+1] == '-' {
+			lo, hi := rs[i], rs[i+2]
+			if hi < lo {
+				return nil, fill, fmt.Sprintf("范围端点 '%c-%c' 的顺序颠倒", lo, hi)
+			}
+			for c := lo; c <= hi; c++ {
+				set = append(set, c)
+			}
+			i += 2
+			continue
+		}
+		set = append(set, rs[i])
+	}
+	return set, fill, ""
+}
+
+func (t *Terminal) cmdTr(args []string, in io.Reader, out io.Writer) {
+	complement, del, squeeze, truncate := false, false, false, false
+	var sets []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			sets = append(sets, args[i+1:]...)
+			i = len(args)
+		case a == "--complement":
+			complement = true
+		case a == "--delete":
+			del = true
+		case a == "--squeeze-repeats":
+			squeeze = true
+		case a == "--truncate-set1":
+			truncate = true
+		case strings.HasPrefix(a, "--"):
+			t.usageError("tr", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			bad := byte(0)
+			shortOpts(args, i, "", func(opt byte, _ string) {
+				switch opt {
+				case 'c', 'C':
+					complement = true
+				case 'd':
+					del = true
+				case 's':
+					squeeze = true
+				case 't':
+					truncate = true
+				default:
+					if bad == 0 {
+						bad = opt
+					}
+				}
+			})
+			if bad != 0 {
+				t.usageError("tr", fmt.Sprintf("无效的选项 -- '%c'", bad), 1)
+				return
+			}
+		default:
+			sets = append(sets, a)
+		}
+	}
+	// -d 只需要一组字符，-s 可以有一组或两组，-d -s 和替换需要两组
+	need, most := 2, 2
+	switch {
+	case del && !squeeze:
+		need, most = 1, 1
+	case squeeze && !del:
+		need = 1
+	}
+	switch {
+	case len(sets) == 0:
+		t.usageError("tr", "缺少操作数", 1)
+		return
+	case len(sets) < need && del:
+		t.usageError("tr", fmt.Sprintf("在 '%s' 后缺少操作数\n删除并压缩时必须给定两组字符串。", sets[0]), 1)
+		return
+	case len(sets) < need:
+		t.usageError("tr", fmt.Sprintf("在 '%s' 后缺少操作数\n进行替换时必须给定两组字符串。", sets[0]), 1)
+		return
+	case len(sets) > most:
+		msg := fmt.Sprintf("额外的操作数 '%s'", sets[most])
+		if del && most == 1 {
+			msg += "\n只有一组字符串可以在删除时给出。"
+		}
+		t.usageError("tr", msg, 1)
+		return
+	}
+	set1, _, msg := expandTrSet(sets[0])
+	var set2 []rune
+	fill := -1
+	if msg == "" && len(sets) > 1 {
+		set2, fill, msg = expandTrSet(sets[1])
+	}
+	if msg != "" {
+		fmt.Fprintf(t.Stderr, "tr: %s\n", msg)
+		t.lastExitCode = 1
+		return
+	}
+	in1 := map[rune]bool{}
+	for _, r := range set1 {
+		in1[r] = true
+	}
+	match1 := func(r rune) bool { return in1[r] != complement }
+
+	translate := !del && len(sets) == 2
+	mapping := map[rune]rune{}
+	if translate {
+		if len(set2) == 0 {
+			t.usageError("tr", "当不截断集合1时，集合2 不能为空", 1)
+			return
+		}
+		if truncate && len(set1) > len(set2) {
+			set1 = set1[:len(set2)]
+		}
+		// [x*] 填充到与集合1 等长
+		if fill >= 0 && len(set2) < len(set1) {
+			pad := make([]rune, len(set1)-len(set2))
+			for i := range pad {
+				pad[i] = set2[fill]
+			}
+			set2 = append(set2[:fill+1], append(pad, set2[fill+1:]...)...)
+		}
+		for i, r := range set1 {
+			j := i
+			if j >= len(set2) {
+				j = len(set2) - 1
+			}
+			mapping[r] = set2[j]
+		}
+	}
+	// 压缩作用于最后给出的那组字符
+	squeezeSet := in1
+	squeezeComp := complement
+	if len(sets) == 2 {
+		squeezeSet, squeezeComp = map[rune]bool{}, false
+		for _, r := range set2 {
+			squeezeSet[r] = true
+		}
+	}
+
+	last, haveLast := rune(0), false
+	br := bufio.NewReader(in)
+	var b strings.Builder
+	for {
+		chunk, err := br.ReadString('\n')
+		b.Reset()
+		for _, r := range chunk {
+			switch {
+			case del && match1(r):
+				continue
+			case translate && complement && !in1[r]:
+				r = set2[len(set2)-1]
+			case translate && !complement:
+				if m, ok := mapping[r]; ok {
+					r = m
+				}
+			}
+			if squeeze && haveLast && r == last && squeezeSet[r] != squeezeComp {
+				continue
+			}
+			last, haveLast = r, true
+			b.WriteRune(r)
+		}
+		io.WriteString(out, b.String())
+		if err != nil {
+			break
+		}
+	}
+}
+
+// ---------- wc ----------
+
+type wcCounts struct{ lines, words, chars, bytes, maxLine int }
+
+// count 统计一段输入；inWord 和 col 在分块之间保持状态
+func (c *wcCounts) count(data []byte, inWord *bool, col *int) {
+	c.bytes += len(data)
+	for _, r := range string(data) {
+		c.chars++
+		switch r {
+		case '\n':
+			c.lines++
+			*col = 0
+		case '\t':
+			*col += 8 - *col%8
+		default:
+			*col++
+		}
+		c.maxLine = max(c.maxLine, *col)
+		space := unicode.IsSpace(r)
+		if !space && !*inWord {
+			c.words++
+		}
+		*inWord = !space
+	}
+}
+
+func (t *Terminal) cmdWc(args []string, in io.Reader, out io.Writer) {
+	var lines, words, chars, bytesOpt, maxLine bool
+	var files []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--lines":
+			lines = true
+		case a == "--words":
+			words = true
+		case a == "--chars":
+			chars = true
+		case a == "--bytes":
+			bytesOpt = true
+		case a == "--max-line-length":
+			maxLine = true
+		case strings.HasPrefix(a, "--"):
+			t.usageError("wc", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			bad := byte(0)
+			shortOpts(args, i, "", func(opt byte, _ string) {
+				switch opt {
+				case 'l':
+					lines = true
+				case 'w':
+					words = true
+				case 'm':
+					chars = true
+				case 'c':
+					bytesOpt = true
+				case 'L':
+					maxLine = true
+				default:
+					if bad == 0 {
+						bad = opt
+					}
+				}
+			})
+			if bad != 0 {
+				t.usageError("wc", fmt.Sprintf("无效的选项 -- '%c'", bad), 1)
+				return
+			}
+		default:
+			files = append(files, a)
+		}
+	}
+	if !lines && !words && !chars && !bytesOpt && !maxLine {
+		lines, words, bytesOpt = true, true, true
+	}
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	// 宽度规则同 GNU wc：只输出一个数时不对齐，否则按文件总大小取宽度，读标准输入时至少 7
+	selected := 0
+	for _, b := range []bool{lines, words, chars, bytesOpt, maxLine} {
+		if b {
+			selected++
+		}
+	}
+	width, total := 1, 0
+	if selected > 1 || len(files) > 1 {
+		for _, f := range files {
+			if f == "" || f == "-" {
+				width = 7
+			} else if e, ok := t.FS.GetEntry(t.FS.Abs(f)); ok && !e.IsDir {
+				total += len(e.Content)
+			}
+		}
+		width = max(width, len(strconv.Itoa(total)))
+	}
+	print := func(c wcCounts, name string) {
+		var parts []string
+		for _, f := range []struct {
+			on bool
+			n  int
+		}{{lines, c.lines}, {words, c.words}, {chars, c.chars}, {bytesOpt, c.bytes}, {maxLine, c.maxLine}} {
+			if f.on {
+				parts = append(parts, fmt.Sprintf("%*d", width, f.n))
+			}
+		}
+		if name != "" {
+			parts = append(parts, name)
+		}
+		fmt.Fprintln(out, strings.Join(parts, " "))
+	}
+
+	var sum wcCounts
+	for _, f := range files {
+		name := f
+		if f == "" {
+			f = "-"
+		}
+		r, reason := t.openInput(f, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "wc: %s: %s\n", f, reason)
+			t.lastExitCode = 1
+			continue
+		}
+		var c wcCounts
+		inWord, col := false, 0
+		buf := make([]byte, 32*1024)
+		var pending []byte // 跨块的不完整 UTF-8 字符
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				data := append(pending, buf[:n]...)
+				cut := len(data)
+				for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+					if utf8.RuneStart(data[i]) {
+						if !utf8.FullRune(data[i:]) {
+							cut = i
+						}
+						break
+					}
+				}
+				c.count(data[:cut], &inWord, &col)
+				pending = append([]byte(nil), data[cut:]...)
+			}
+			if err != nil {
+				break
+			}
+		}
+		c.count(pending, &inWord, &col)
+		print(c, name)
+		sum.lines += c.lines
+		sum.words += c.words
+		sum.chars += c.chars
+		sum.bytes += c.bytes
+		sum.maxLine = max(sum.maxLine, c.maxLine)
+	}
+	if len(files) > 1 {
+		print(sum, "总计")
+	}
+}
+
+// ---------- tee ----------
+
+func (t *Terminal) cmdTee(args []string, in io.Reader, out io.Writer) {
+	appendMode := false
+	var files []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case a == "--append":
+			appendMode = true
+		case a == "--ignore-interrupts" || strings.HasPrefix(a, "--output-error"):
+		case strings.HasPrefix(a, "--"):
+			t.usageError("tee", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case len(a) > 1 && a[0] == '-':
+			bad := byte(0)
+			shortOpts(args, i, "", func(opt byte, _ string) {
+				switch opt {
+				case 'a':
+					appendMode = true
+				case 'i', 'p':
+				default:
+					if bad == 0 {
+						bad = opt
+					}
+				}
+			})
+			if bad != 0 {
+				t.usageError("tee", fmt.Sprintf("无效的选项 -- '%c'", bad), 1)
+				return
+			}
+		default:
+			files = append(files, a)
+		}
+	}
+	// 边读边输出，文件内容在输入结束时一次写入
+	var data bytes.Buffer
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			out.Write(buf[:n])
+			if data.Len() < MaxFileSize {
+				data.Write(buf[:n])
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	for _, f := range files {
+		if f == "-" {
+			out.Write(data.Bytes())
+			continue
+		}
+		if !t.writeTextFile("tee", f, data.Bytes(), appendMode) {
+			t.lastExitCode = 1
+		}
+	}
+}
+
+// ---------- xargs ----------
+
+// xargsSplit 按空白切分 xargs 的输入，支持引号和反斜杠转义
+func xargsSplit(s string) ([]string, string) {
+	var items []string
+	var b strings.Builder
+	var quote rune
+	inWord := false
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\n' {
+				kind := "双"
+				if quote == '\'' {
+					kind = "单"
+				}
+				return nil, fmt.Sprintf("未匹配的%s引号；默认情况下，引号对 xargs 有特殊含义，除非您使用 -0 选项", kind)
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\' && i+1 < len(rs):
+			i++
+			b.WriteRune(rs[i])
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				items = append(items, b.String())
+				b.Reset()
+				inWord = false
+			}
+		default:
+			b.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		kind := "双"
+		if quote == '\'' {
+			kind = "单"
+		}
+		return nil, fmt.Sprintf("未匹配的%s引号；默认情况下，引号对 xargs 有特殊含义，除非您使用 -0 选项", kind)
+	}
+	if inWord {
+		items = append(items, b.String())
+	}
+	return items, ""
+}
+
+// xargsMaxRuns 限制 xargs 启动命令的次数
+const xargsMaxRuns = 1000
+
+func (t *Terminal) cmdXargs(args []string, in io.Reader, out io.Writer) {
+	maxArgs, maxLines := 0, 0
+	replace, delim, argFile := "", "", ""
+	noRunEmpty, verbose, nul := false, false, false
+	i := 1
+	ok := true
+	number := func(opt byte, v string) int {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			if ok {
+				fmt.Fprintf(t.Stderr, "xargs: 无效的数字 \"%s\" 用于 -%c 选项\n", v, opt)
+				t.lastExitCode = 1
+			}
+			ok = false
+		}
+		return n
+	}
+options:
+	for ; i < len(args) && ok; i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			i++
+			break options
+		case a == "--null":
+			nul = true
+		case a == "--no-run-if-empty":
+			noRunEmpty = true
+		case a == "--verbose":
+			verbose = true
+		case strings.HasPrefix(a, "--max-args="):
+			maxArgs = number('n', strings.TrimPrefix(a, "--max-args="))
+		case strings.HasPrefix(a, "--max-lines="):
+			maxLines = number('L', strings.TrimPrefix(a, "--max-lines="))
+		case strings.HasPrefix(a, "--replace"):
+			replace = "{}"
+			if v := strings.TrimPrefix(a, "--replace"); strings.HasPrefix(v, "=") {
+				replace = v[1:]
+			}
+		case strings.HasPrefix(a, "--delimiter="):
+			delim = string(trUnescape(strings.TrimPrefix(a, "--delimiter=")))
+		case strings.HasPrefix(a, "--arg-file="):
+			argFile = strings.TrimPrefix(a, "--arg-file=")
+		case strings.HasPrefix(a, "--max-procs=") || strings.HasPrefix(a, "--max-chars=") || a == "--exit" || a == "--open-tty":
+		case strings.HasPrefix(a, "--"):
+			t.usageError("xargs", fmt.Sprintf("无法识别的选项 '%s'", a), 1)
+			return
+		case a == "-i" || strings.HasPrefix(a, "-i") && len(a) > 2:
+			replace = "{}"
+			if len(a) > 2 {
+				replace = a[2:]
+			}
+		case len(a) > 1 && a[0] == '-':
+			i = shortOpts(args, i, "nLIdaPsE", func(opt byte, val string) {
+				switch opt {
+				case 'n':
+					maxArgs = number(opt, val)
+				case 'L', 'l':
+					maxLines = 1
+					if opt == 'L' {
+						maxLines = number(opt, val)
+					}
+				case 'I':
+					replace = val
+				case 'd':
+					delim = string(trUnescape(val))
+				case 'a':
+					argFile = val
+				case '0':
+					nul = true
+				case 'r':
+					noRunEmpty = true
+				case 't':
+					verbose = true
+				case 'P', 's', 'E', 'x', 'p', 'o':
+				default:
+					if ok {
+						t.usageError("xargs", fmt.Sprintf("无效的选项 -- '%c'", opt), 1)
+						ok = false
+					}
+				}
+			})
+		default:
+			break options
+		}
+	}
+	if !ok {
+		return
+	}
+	command := append([]string{}, args[i:]...)
+	if len(command) == 0 {
+		command = []string{"echo"}
+	}
+
+	src := in
+	if argFile != "" {
+		r, reason := t.openInput(argFile, in)
+		if reason != "" {
+			fmt.Fprintf(t.Stderr, "xargs: %s: %s\n", argFile, reason)
+			t.lastExitCode = 1
+			return
+		}
+		src = r
+	}
+	data, _ := io.ReadAll(io.LimitReader(src, MaxFileSize))
+	text := string(data)
+
+	// 切分输入：每个元素是一行内的若干参数 (-L / -I 以行为单位)
+	var lines [][]string
+	switch {
+	case nul || delim != "":
+		sep := "\x00"
+		if delim != "" {
+			sep = delim
+		}
+		items := strings.Split(text, sep)
+		if n := len(items); items[n-1] == "" {
+			items = items[:n-1]
+		}
+		for _, it := range items {
+			lines = append(lines, []string{it})
+		}
+	case replace != "":
+		for _, l := range strings.Split(text, "\n") {
+			if l = strings.TrimLeft(l, " \t"); l != "" {
+				lines = append(lines, []string{l})
+			}
+		}
+	default:
+		for _, l := range strings.Split(text, "\n") {
+			items, msg := xargsSplit(l)
+			if msg != "" {
+				fmt.Fprintf(t.Stderr, "xargs: %s\n", msg)
+				t.lastExitCode = 1
+				return
+			}
+			if len(items) > 0 {
+				lines = append(lines, items)
+			}
+		}
+	}
+
+	// 组装每次调用的参数
+	var batches [][]string
+	switch {
+	case replace != "":
+		for _, l := range lines {
+			argv := make([]string, len(command))
+			for j, c := range command {
+				argv[j] = strings.ReplaceAll(c, replace, l[0])
+			}
+			batches = append(batches, argv)
+		}
+	case maxLines > 0:
+		for j := 0; j < len(lines); j += maxLines {
+			argv := append([]string{}, command...)
+			for _, l := range lines[j:min(j+maxLines, len(lines))] {
+				argv = append(argv, l...)
+			}
+			batches = append(batches, argv)
+		}
+	default:
+		var items []string
+		for _, l := range lines {
+			items = append(items, l...)
+		}
+		step := len(items)
+		if maxArgs > 0 {
+			step = maxArgs
+		}
+		for j := 0; j < len(items); j += step {
+			batches = append(batches, append(append([]string{}, command...), items[j:min(j+step, len(items))]...))
+		}
+	}
+	if len(batches) == 0 && !noRunEmpty && replace == "" {
+		batches = [][]string{command}
+	}
+
+	name := command[0]
+	if _, found := t.lookPath(name); !found && !strings.Contains(name, "/") && !shellBuiltins[name] && name != "echo" {
+		if len(batches) > 0 {
+			fmt.Fprintf(t.Stderr, "xargs: %s: 没有那个文件或目录\n", name)
+			t.lastExitCode = 127
+		}
+		return
+	}
+	status := 0
+	for n, argv := range batches {
+		if n >= xargsMaxRuns {
+			break
+		}
+		if verbose {
+			fmt.Fprintln(t.Stderr, strings.Join(argv, " "))
+		}
+		// 每条命令在自己的子 shell 中执行，退出码不经过 xargs 所在 shell 的 $?
+		sub := t.fork(t.job)
+		sub.runCommand(argv, &bytes.Buffer{}, out)
+		switch code := sub.lastExitCode; {
+		case code == 255:
+			fmt.Fprintf(t.Stderr, "xargs: %s: 以状态 255 退出；中止\n", name)
+			t.lastExitCode = 124
+			return
+		case code > 128 && code < 255:
+			fmt.Fprintf(t.Stderr, "xargs: %s: 被信号 %d 终止\n", name, code-128)
+			t.lastExitCode = 125
+			return
+		case code != 0:
+			status = 123
+		}
+	}
+	t.lastExitCode = status
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// sendUrgent 以 TCP 紧急数据 (MSG_OOB) 发送单个控制字节，rlogind 用它传递 TIOCPKT 标志
+func sendUrgent(c net.Conn, b byte) error {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return errors.New("urgent data not supported")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sendErr error
+	err = raw.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendto(int(fd), []byte{b}, syscall.MSG_OOB, nil)
+		// EAGAIN 时交给 runtime 等待可写后重试
+		return sendErr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func sendUrgent(c net.Conn, b byte) error {
+	// no-op
+	return errors.New("urgent data not supported")
+}
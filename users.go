@@ -0,0 +1,1299 @@
+package main
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 用户数据库
+// 用户和组直接取自会话文件系统中的 /etc/passwd、/etc/group 和 /etc/shadow，
+// passwd、useradd、usermod 等命令也只是改写这些文件，因此攻击者手工编辑的结果同样生效
+// ==========================================
+
+// passwdEntry /etc/passwd 的一行
+type passwdEntry struct {
+	Name, Passwd string
+	UID, GID     int
+	Gecos        string
+	Home, Shell  string
+}
+
+// groupEntry /etc/group 的一行
+type groupEntry struct {
+	Name, Passwd string
+	GID          int
+	Members      []string
+}
+
+// readDB 读取账户文件，返回按 ':' 拆分的非空、非注释行
+func (fs *SessionFS) readDB(p string) [][]string {
+	e, ok := fs.GetEntry(p)
+	if !ok || e.IsDir {
+		return nil
+	}
+	e.mu.RLock()
+	content := string(e.Content)
+	e.mu.RUnlock()
+	var res [][]string
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, strings.Split(line, ":"))
+	}
+	return res
+}
+
+func (fs *SessionFS) passwdEntries() []passwdEntry {
+	var res []passwdEntry
+	for _, f := range fs.readDB("/etc/passwd") {
+		if len(f) < 7 {
+			continue
+		}
+		uid, err1 := strconv.Atoi(f[2])
+		gid, err2 := strconv.Atoi(f[3])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		res = append(res, passwdEntry{Name: f[0], Passwd: f[1], UID: uid, GID: gid, Gecos: f[4], Home: f[5], Shell: f[6]})
+	}
+	return res
+}
+
+func (fs *SessionFS) groupEntries() []groupEntry {
+	var res []groupEntry
+	for _, f := range fs.readDB("/etc/group") {
+		if len(f) < 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(f[2])
+		if err != nil {
+			continue
+		}
+		g := groupEntry{Name: f[0], Passwd: f[1], GID: gid}
+		if f[3] != "" {
+			g.Members = strings.Split(f[3], ",")
+		}
+		res = append(res, g)
+	}
+	return res
+}
+
+// lookupUser 按用户名查找账户
+func (fs *SessionFS) lookupUser(name string) (passwdEntry, bool) {
+	for _, u := range fs.passwdEntries() {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return passwdEntry{}, false
+}
+
+// lookupGroup 按组名或数字 GID 查找组
+func (fs *SessionFS) lookupGroup(name string) (groupEntry, bool) {
+	gid, err := strconv.Atoi(name)
+	for _, g := range fs.groupEntries() {
+		if g.Name == name || err == nil && g.GID == gid {
+			return g, true
+		}
+	}
+	return groupEntry{}, false
+}
+
+// users 返回 用户名 -> UID
+func (fs *SessionFS) users() map[string]int {
+	m := make(map[string]int)
+	for _, u := range fs.passwdEntries() {
+		m[u.Name] = u.UID
+	}
+	return m
+}
+
+// groups 返回 组名 -> GID
+func (fs *SessionFS) groups() map[string]int {
+	m := make(map[string]int)
+	for _, g := range fs.groupEntries() {
+		m[g.Name] = g.GID
+	}
+	return m
+}
+
+// uid 返回用户的 UID，未知用户为 -1
+func (fs *SessionFS) uid(name string) int {
+	if u, ok := fs.lookupUser(name); ok {
+		return u.UID
+	}
+	return -1
+}
+
+// gid 返回组的 GID，未知组为 -1
+func (fs *SessionFS) gid(name string) int {
+	if g, ok := fs.lookupGroup(name); ok {
+		return g.GID
+	}
+	return -1
+}
+
+// owner 返回用户的 UID 和主组 GID，未知用户均为 -1 (Chown 时保持不变)
+func (fs *SessionFS) owner(name string) (uid, gid int) {
+	if u, ok := fs.lookupUser(name); ok {
+		return u.UID, u.GID
+	}
+	return -1, -1
+}
+
+// uidName 返回 UID 对应的用户名，没有对应账户时返回数字
+func (fs *SessionFS) uidName(uid int) string {
+	for _, u := range fs.passwdEntries() {
+		if u.UID == uid {
+			return u.Name
+		}
+	}
+	return strconv.Itoa(uid)
+}
+
+func (fs *SessionFS) gidName(gid int) string {
+	for _, g := range fs.groupEntries() {
+		if g.GID == gid {
+			return g.Name
+		}
+	}
+	return strconv.Itoa(gid)
+}
+
+// memberOf 返回用户所属的组：主组在前，其后是按 GID 排列的附加组
+func (fs *SessionFS) memberOf(u passwdEntry) []groupEntry {
+	var primary, res []groupEntry
+	for _, g := range fs.groupEntries() {
+		switch {
+		case g.GID == u.GID:
+			if len(primary) == 0 {
+				primary = append(primary, g)
+			}
+		case containsString(g.Members, u.Name):
+			res = append(res, g)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].GID < res[j].GID })
+	if len(primary) == 0 {
+		primary = []groupEntry{{Name: strconv.Itoa(u.GID), GID: u.GID}}
+	}
+	return append(primary, res...)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowHash 返回用户在 /etc/shadow 中的密码字段
+func (fs *SessionFS) shadowHash(name string) (string, bool) {
+	for _, f := range fs.readDB("/etc/shadow") {
+		if len(f) > 1 && f[0] == name {
+			return f[1], true
+		}
+	}
+	return "", false
+}
+
+// checkPassword 按 /etc/shadow 校验用户密码，锁定 ("!"、"*") 的账户总是失败
+func (fs *SessionFS) checkPassword(name, pass string) bool {
+	hash, ok := fs.shadowHash(name)
+	if !ok {
+		return false
+	}
+	if hash == "" {
+		return pass == ""
+	}
+	return cryptVerify(pass, hash)
+}
+
+// sessionPassword 判断 pass 是否为会话中为 name 设置的密码；
+// 账户的 shadow 记录与初始文件系统一致时 set 为 false，由调用方按原策略处理
+func (fs *SessionFS) sessionPassword(name, pass string) (valid, set bool) {
+	hash, ok := fs.shadowHash(name)
+	if !ok {
+		return false, false
+	}
+	if orig, ok := NewSessionFS().shadowHash(name); ok && orig == hash {
+		return false, false
+	}
+	return fs.checkPassword(name, pass), true
+}
+
+// updateDB 逐行改写账户文件：edit 返回 nil 时删除该行，add 中的行追加到末尾。
+// 与 shadow 工具一样先把原文件保存为 "<文件名>-"
+func (fs *SessionFS) updateDB(p string, edit func(f []string) []string, add ...string) {
+	e, ok := fs.GetEntry(p)
+	if !ok || e.IsDir {
+		return
+	}
+	e.mu.RLock()
+	old := string(e.Content)
+	mode, uid, gid := e.Mode, e.UID, e.GID
+	e.mu.RUnlock()
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(old, "\n"), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") && edit != nil {
+			f := edit(strings.Split(line, ":"))
+			if f == nil {
+				continue
+			}
+			line = strings.Join(f, ":")
+		}
+		if line != "" {
+			b.WriteString(line + "\n")
+		}
+	}
+	for _, line := range add {
+		b.WriteString(line + "\n")
+	}
+	fs.Write(p+"-", []byte(old), mode)
+	fs.Chown(p+"-", uid, gid)
+	fs.Write(p, []byte(b.String()), 0)
+}
+
+// setShadow 修改用户的 shadow 记录
+func (fs *SessionFS) setShadow(name string, fn func(f []string)) {
+	fs.updateDB("/etc/shadow", func(f []string) []string {
+		if f[0] == name && len(f) >= 9 {
+			fn(f)
+		}
+		return f
+	})
+}
+
+// shadowDays 返回 shadow 中使用的日期 (自 1970-01-01 起的天数)
+func shadowDays(t time.Time) string {
+	return strconv.FormatInt(t.Unix()/86400, 10)
+}
+
+// accountDenied 非 root 用户修改账户文件时 shadow 工具的报错
+func (t *Terminal) accountDenied(cmd string) bool {
+	if t.userName() == "root" {
+		return false
+	}
+	fmt.Fprintf(t.Stderr, "%s: Permission denied.\n%s: cannot lock /etc/passwd; try again later.\n", cmd, cmd)
+	t.lastExitCode = 1
+	return true
+}
+
+// accountOpts 按 getopt_long 的规则解析 shadow 工具的选项：long 把长选项映射为短选项，
+// withArg 中的短选项带参数。返回非选项参数，遇到无效选项时 bad 为其报错
+func accountOpts(args []string, known, withArg string, long map[string]byte, fn func(opt byte, val string)) (rest []string, bad string) {
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			return append(rest, args[i+1:]...), ""
+		case strings.HasPrefix(a, "--"):
+			name, val, hasVal := strings.Cut(a, "=")
+			opt, ok := long[name]
+			if !ok {
+				return nil, fmt.Sprintf("unrecognized option '%s'", a)
+			}
+			if strings.IndexByte(withArg, opt) >= 0 && !hasVal {
+				if i+1 >= len(args) {
+					return nil, fmt.Sprintf("option '%s' requires an argument", a)
+				}
+				i++
+				val = args[i]
+			}
+			fn(opt, val)
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			for j := 1; j < len(a); j++ {
+				if strings.IndexByte(known, a[j]) < 0 {
+					return nil, fmt.Sprintf("invalid option -- '%c'", a[j])
+				}
+				if strings.IndexByte(withArg, a[j]) >= 0 {
+					if j == len(a)-1 && i+1 >= len(args) {
+						return nil, fmt.Sprintf("option requires an argument -- '%c'", a[j])
+					}
+					break
+				}
+			}
+			i = shortOpts(args, i, withArg, fn)
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return rest, ""
+}
+
+// validAccountName 按 shadow 工具的规则检查用户名和组名
+func validAccountName(name string) bool {
+	if name == "" || len(name) > 32 || name[0] == '-' || name == "." || name == ".." {
+		return false
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return false
+	}
+	return !strings.ContainsAny(name, ":,/ \t\n")
+}
+
+// ==========================================
+// crypt(3) SHA-512 ($6$)
+// ==========================================
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// maxCryptRounds 拒绝轮数过大的哈希，防止攻击者写入 shadow 后借校验消耗 CPU
+const maxCryptRounds = 1000000
+
+// sha512CryptOrder 输出编码时 3 字节一组的取值顺序
+var sha512CryptOrder = [...][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+	{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+	{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+}
+
+// sha512Crypt 按 Ulrich Drepper 的 SHA-crypt 规范计算 $6$ 哈希；
+// setting 形如 "$6$salt" 或 "$6$rounds=N$salt"，可以带上已有哈希的其余部分
+func sha512Crypt(pass, setting string) (string, bool) {
+	rest, ok := strings.CutPrefix(setting, "$6$")
+	if !ok {
+		return "", false
+	}
+	rounds, custom := 5000, false
+	if r, ok := strings.CutPrefix(rest, "rounds="); ok {
+		n, after, found := strings.Cut(r, "$")
+		v, err := strconv.Atoi(n)
+		if !found || err != nil {
+			return "", false
+		}
+		rounds, custom, rest = min(max(v, 1000), 999999999), true, after
+	}
+	if rounds > maxCryptRounds {
+		return "", false
+	}
+	salt, _, _ := strings.Cut(rest, "$")
+	salt = salt[:min(len(salt), 16)]
+	p, s := []byte(pass), []byte(salt)
+
+	h := sha512.New()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	db := h.Sum(nil)
+
+	h.Reset()
+	h.Write(p)
+	h.Write(s)
+	for n := len(p); n > 0; n -= 64 {
+		h.Write(db[:min(n, 64)])
+	}
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(db)
+		} else {
+			h.Write(p)
+		}
+	}
+	da := h.Sum(nil)
+
+	h.Reset()
+	for range p {
+		h.Write(p)
+	}
+	dp := h.Sum(nil)
+	pseq := make([]byte, 0, len(p))
+	for len(pseq) < len(p) {
+		pseq = append(pseq, dp[:min(64, len(p)-len(pseq))]...)
+	}
+
+	h.Reset()
+	for i := 0; i < 16+int(da[0]); i++ {
+		h.Write(s)
+	}
+	sseq := h.Sum(nil)[:len(s)]
+
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(pseq)
+		} else {
+			h.Write(da)
+		}
+		if i%3 != 0 {
+			h.Write(sseq)
+		}
+		if i%7 != 0 {
+			h.Write(pseq)
+		}
+		if i&1 != 0 {
+			h.Write(da)
+		} else {
+			h.Write(pseq)
+		}
+		da = h.Sum(da[:0])
+	}
+
+	out := []byte("$6$")
+	if custom {
+		out = append(out, "rounds="+strconv.Itoa(rounds)+"$"...)
+	}
+	out = append(out, salt+"$"...)
+	enc := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for ; n > 0; n-- {
+			out = append(out, cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	for _, o := range sha512CryptOrder {
+		enc(da[o[0]], da[o[1]], da[o[2]], 4)
+	}
+	enc(0, 0, da[63], 2)
+	return string(out), true
+}
+
+// cryptHash 以随机盐计算密码的 $6$ 哈希
+func cryptHash(pass string) string {
+	salt := make([]byte, 16)
+	for i := range salt {
+		salt[i] = cryptAlphabet[rand.Intn(len(cryptAlphabet))]
+	}
+	h, _ := sha512Crypt(pass, "$6$"+string(salt))
+	return h
+}
+
+// cryptVerify 校验密码与 shadow 中的哈希是否匹配，只支持 $6$
+func cryptVerify(pass, hash string) bool {
+	h, ok := sha512Crypt(pass, hash)
+	return ok && h == hash
+}
+
+// ==========================================
+// id
+// ==========================================
+
+func (t *Terminal) cmdId(args []string, out io.Writer) {
+	var only byte
+	names := false
+	var target string
+	long := map[string]byte{"--user": 'u', "--group": 'g', "--groups": 'G', "--name": 'n', "--real": 'r'}
+	for _, a := range args[1:] {
+		var flags string
+		switch {
+		case long[a] != 0:
+			flags = string(long[a])
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			flags = a[1:]
+		default:
+			target = a
+		}
+		for i := 0; i < len(flags); i++ {
+			switch c := flags[i]; c {
+			case 'u', 'g', 'G':
+				only = c
+			case 'n':
+				names = true
+			case 'r':
+			default:
+				t.usageError("id", fmt.Sprintf("无效的选项 -- '%c'", c), 1)
+				return
+			}
+		}
+	}
+	if names && only == 0 {
+		fmt.Fprintln(t.Stderr, "id: 只能在使用 -u、-g 或 -G 时才能显示名称")
+		t.lastExitCode = 1
+		return
+	}
+	name := target
+	if name == "" {
+		name = t.userName()
+	}
+	u, ok := t.FS.lookupUser(name)
+	if !ok {
+		if n, err := strconv.Atoi(name); err == nil && target != "" {
+			for _, e := range t.FS.passwdEntries() {
+				if e.UID == n {
+					u, ok = e, true
+					break
+				}
+			}
+		}
+		if !ok {
+			fmt.Fprintf(t.Stderr, "id: “%s”：无此用户\n", name)
+			t.lastExitCode = 1
+			return
+		}
+	}
+	groups := t.FS.memberOf(u)
+	show := func(id int, name string) string {
+		if names {
+			return name
+		}
+		return strconv.Itoa(id)
+	}
+	switch only {
+	case 'u':
+		fmt.Fprintln(out, show(u.UID, u.Name))
+	case 'g':
+		fmt.Fprintln(out, show(groups[0].GID, groups[0].Name))
+	case 'G':
+		var list []string
+		for _, g := range groups {
+			list = append(list, show(g.GID, g.Name))
+		}
+		fmt.Fprintln(out, strings.Join(list, " "))
+	default:
+		var list []string
+		for _, g := range groups {
+			list = append(list, fmt.Sprintf("%d(%s)", g.GID, g.Name))
+		}
+		fmt.Fprintf(out, "uid=%d(%s) gid=%d(%s) groups=%s\n", u.UID, u.Name, groups[0].GID, groups[0].Name, strings.Join(list, ","))
+	}
+}
+
+// ==========================================
+// passwd / chpasswd
+// ==========================================
+
+const passwdUsage = `Usage: passwd [options] [LOGIN]
+
+Options:
+  -a, --all                     report password status on all accounts
+  -d, --delete                  delete the password for the named account
+  -e, --expire                  force expire the password for the named account
+  -h, --help                    display this help message and exit
+  -k, --keep-tokens             change password only if expired
+  -i, --inactive INACTIVE       set password inactive after expiration
+                                to INACTIVE
+  -l, --lock                    lock the password of the named account
+  -n, --mindays MIN_DAYS        set minimum number of days before password
+                                change to MIN_DAYS
+  -q, --quiet                   quiet mode
+  -r, --repository REPOSITORY   change password in REPOSITORY repository
+  -R, --root CHROOT_DIR         directory to chroot into
+  -S, --status                  report password status on the named account
+  -u, --unlock                  unlock the password of the named account
+  -w, --warndays WARN_DAYS      set expiration warning days to WARN_DAYS
+  -x, --maxdays MAX_DAYS        set maximum number of days before password
+                                change to MAX_DAYS
+
+`
+
+var passwdLong = map[string]byte{
+	"--all": 'a', "--delete": 'd', "--expire": 'e', "--help": 'h', "--keep-tokens": 'k', "--inactive": 'i',
+	"--lock": 'l', "--mindays": 'n', "--quiet": 'q', "--repository": 'r', "--root": 'R', "--status": 'S',
+	"--unlock": 'u', "--warndays": 'w', "--maxdays": 'x',
+}
+
+// setPassword 为用户设置新密码并记录明文
+func (t *Terminal) setPassword(cmd, name, pass string) {
+	hash := cryptHash(pass)
+	t.FS.setShadow(name, func(f []string) {
+		f[1], f[2] = hash, shadowDays(time.Now())
+	})
+	log.Printf("[Account] %s: %s set password for %s: %q", t.Remote, cmd, name, pass)
+}
+
+func (t *Terminal) cmdPasswd(args []string, in io.Reader, out io.Writer) {
+	self := t.userName()
+	var op byte
+	all := false
+	rest, bad := accountOpts(args, "adehkilnqrRSuwx", "inrRwx", passwdLong, func(opt byte, _ string) {
+		switch opt {
+		case 'a':
+			all = true
+		case 'd', 'e', 'l', 'u', 'S', 'h':
+			op = opt
+		}
+	})
+	if op == 'h' && bad == "" {
+		io.WriteString(out, passwdUsage)
+		return
+	}
+	if bad != "" || len(rest) > 1 || all && op != 'S' {
+		if bad != "" {
+			fmt.Fprintf(t.Stderr, "passwd: %s\n", bad)
+		}
+		io.WriteString(t.Stderr, passwdUsage)
+		t.lastExitCode = 2
+		return
+	}
+	name := self
+	if len(rest) == 1 {
+		name = rest[0]
+	}
+	if _, ok := t.FS.lookupUser(name); !ok {
+		fmt.Fprintf(t.Stderr, "passwd: user '%s' does not exist\n", name)
+		t.lastExitCode = 1
+		return
+	}
+	if self != "root" && (name != self || op != 0 && op != 'S' || all) {
+		fmt.Fprintf(t.Stderr, "passwd: You may not view or modify password information for %s.\n", name)
+		t.lastExitCode = 1
+		return
+	}
+
+	switch op {
+	case 'S':
+		for _, f := range t.FS.readDB("/etc/shadow") {
+			if len(f) < 9 || !all && f[0] != name {
+				continue
+			}
+			status := "P"
+			switch {
+			case f[1] == "":
+				status = "NP"
+			case strings.HasPrefix(f[1], "!") || strings.HasPrefix(f[1], "*"):
+				status = "L"
+			}
+			date := "01/01/1970"
+			if d, err := strconv.ParseInt(f[2], 10, 64); err == nil {
+				date = time.Unix(d*86400, 0).UTC().Format("01/02/2006")
+			}
+			field := func(s, def string) string {
+				if s == "" {
+					return def
+				}
+				return s
+			}
+			fmt.Fprintf(out, "%s %s %s %s %s %s %s\n", f[0], status, date, field(f[3], "0"), field(f[4], "99999"), field(f[5], "7"), field(f[6], "-1"))
+		}
+		return
+	case 'l', 'u', 'd', 'e':
+		hash, _ := t.FS.shadowHash(name)
+		if op == 'u' && strings.TrimPrefix(hash, "!") == "" {
+			fmt.Fprintln(t.Stderr, "passwd: unlocking the password would result in a passwordless account.\nYou should set a password with usermod -p to unlock the password of this account.")
+			t.lastExitCode = 3
+			return
+		}
+		t.FS.setShadow(name, func(f []string) {
+			switch op {
+			case 'l':
+				if !strings.HasPrefix(f[1], "!") {
+					f[1] = "!" + f[1]
+				}
+			case 'u':
+				f[1] = strings.TrimPrefix(f[1], "!")
+			case 'd':
+				f[1] = ""
+			case 'e':
+				f[2] = "0"
+			}
+		})
+		log.Printf("[Account] %s: passwd -%c %s", t.Remote, op, name)
+		fmt.Fprintln(out, "passwd: password expiry information changed.")
+		return
+	}
+
+	fail := func(msg string) {
+		if msg != "" {
+			fmt.Fprintln(t.Stderr, msg)
+		}
+		fmt.Fprintln(t.Stderr, "passwd: Authentication token manipulation error\npasswd: password unchanged")
+		t.lastExitCode = 10
+	}
+	if self != "root" {
+		fmt.Fprintf(out, "Changing password for %s.\n", name)
+		fmt.Fprint(t.Stderr, "Current password: ")
+		cur, ok := t.readReply(in, t.Stderr, false)
+		if !ok || !t.FS.checkPassword(name, cur) {
+			fail("")
+			return
+		}
+	}
+	fmt.Fprint(t.Stderr, "New password: ")
+	pass, ok := t.readReply(in, t.Stderr, false)
+	if !ok {
+		fail("")
+		return
+	}
+	fmt.Fprint(t.Stderr, "Retype new password: ")
+	again, ok := t.readReply(in, t.Stderr, false)
+	switch {
+	case !ok:
+		fail("")
+	case pass != again:
+		fail("Sorry, passwords do not match.")
+	case pass == "":
+		fail("No password has been supplied.")
+	case self != "root" && len(pass) < 6:
+		fail("You must choose a longer password.")
+	default:
+		t.setPassword("passwd", name, pass)
+		fmt.Fprintln(out, "passwd: password updated successfully")
+	}
+}
+
+// cmdChpasswd 从标准输入批量读取 "用户名:密码"
+func (t *Terminal) cmdChpasswd(args []string, in io.Reader) {
+	encrypted := false
+	_, bad := accountOpts(args, "echmRs", "cRs", map[string]byte{
+		"--encrypted": 'e', "--crypt-method": 'c', "--help": 'h', "--md5": 'm', "--root": 'R', "--sha-rounds": 's',
+	}, func(opt byte, _ string) {
+		if opt == 'e' {
+			encrypted = true
+		}
+	})
+	if bad != "" {
+		fmt.Fprintf(t.Stderr, "chpasswd: %s\nUsage: chpasswd [options]\n", bad)
+		t.lastExitCode = 2
+		return
+	}
+	if t.accountDenied("chpasswd") {
+		return
+	}
+	if in == nil {
+		return
+	}
+	data, _ := io.ReadAll(io.LimitReader(in, MaxFileSize))
+	for n, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		name, pass, ok := strings.Cut(line, ":")
+		if !ok {
+			fmt.Fprintf(t.Stderr, "chpasswd: line %d: missing new password\n", n+1)
+			t.lastExitCode = 1
+			continue
+		}
+		if _, exists := t.FS.lookupUser(name); !exists {
+			fmt.Fprintf(t.Stderr, "chpasswd: (user %s) pam_chauthtok() failed, error:\nAuthentication token manipulation error\n", name)
+			fmt.Fprintf(t.Stderr, "chpasswd: (line %d, user %s) password not changed\n", n+1, name)
+			t.lastExitCode = 1
+			continue
+		}
+		if encrypted {
+			t.FS.setShadow(name, func(f []string) {
+				f[1], f[2] = pass, shadowDays(time.Now())
+			})
+			log.Printf("[Account] %s: chpasswd set hash for %s: %q", t.Remote, name, pass)
+			continue
+		}
+		t.setPassword("chpasswd", name, pass)
+	}
+}
+
+// ==========================================
+// useradd / usermod
+// ==========================================
+
+const useraddUsage = `Usage: useradd [options] LOGIN
+       useradd -D
+       useradd -D [options]
+
+Options:
+      --badnames                do not check for bad names
+  -b, --base-dir BASE_DIR       base directory for the home directory of the
+                                new account
+  -c, --comment COMMENT         GECOS field of the new account
+  -d, --home-dir HOME_DIR       home directory of the new account
+  -D, --defaults                print or change default useradd configuration
+  -e, --expiredate EXPIRE_DATE  expiration date of the new account
+  -f, --inactive INACTIVE       password inactivity period of the new account
+  -g, --gid GROUP               name or ID of the primary group of the new
+                                account
+  -G, --groups GROUPS           list of supplementary groups of the new
+                                account
+  -h, --help                    display this help message and exit
+  -k, --skel SKEL_DIR           use this alternative skeleton directory
+  -K, --key KEY=VALUE           override /etc/login.defs defaults
+  -l, --no-log-init             do not add the user to the lastlog and
+                                faillog databases
+  -m, --create-home             create the user's home directory
+  -M, --no-create-home          do not create the user's home directory
+  -N, --no-user-group           do not create a group with the same name as
+                                the user
+  -o, --non-unique              allow to create users with duplicate
+                                (non-unique) UID
+  -p, --password PASSWORD       encrypted password of the new account
+  -r, --system                  create a system account
+  -R, --root CHROOT_DIR         directory to chroot into
+  -P, --prefix PREFIX_DIR       prefix directory where are located the /etc/* files
+  -s, --shell SHELL             login shell of the new account
+  -u, --uid UID                 user ID of the new account
+  -U, --user-group              create a user group with the same name as
+                                the user
+
+`
+
+const usermodUsage = `Usage: usermod [options] LOGIN
+
+Options:
+  -a, --append                  append the user to the supplemental GROUPS
+                                mentioned by the -G option without removing
+                                the user from other groups
+  -b, --badnames                allow bad names
+  -c, --comment COMMENT         new value of the GECOS field
+  -d, --home HOME_DIR           new home directory for the user account
+  -e, --expiredate EXPIRE_DATE  set account expiration date to EXPIRE_DATE
+  -f, --inactive INACTIVE       set password inactive after expiration
+                                to INACTIVE
+  -g, --gid GROUP               force use GROUP as new primary group
+  -G, --groups GROUPS           new list of supplementary GROUPS
+  -h, --help                    display this help message and exit
+  -l, --login NEW_LOGIN         new value of the login name
+  -L, --lock                    lock the user account
+  -m, --move-home               move contents of the home directory to the
+                                new location (use only with -d)
+  -o, --non-unique              allow using duplicate (non-unique) UID
+  -p, --password PASSWORD       use encrypted password for the new password
+  -P, --prefix PREFIX_DIR       prefix directory where are located the /etc/* files
+  -r, --remove                  remove the user from only the supplemental GROUPS
+                                mentioned by the -G option without removing
+                                the user from other groups
+  -R, --root CHROOT_DIR         directory to chroot into
+  -s, --shell SHELL             new login shell for the user account
+  -u, --uid UID                 new UID for the user account
+  -U, --unlock                  unlock the user account
+
+`
+
+// nextID 返回 [lo, hi] 内比已用 ID 都大的第一个 ID；系统账户 (down) 从 hi 向下分配
+func nextID(used map[int]bool, lo, hi int, down bool) int {
+	if down {
+		for id := hi; id >= lo; id-- {
+			if !used[id] {
+				return id
+			}
+		}
+		return hi
+	}
+	top := lo - 1
+	for id := range used {
+		if id >= lo && id <= hi && id > top {
+			top = id
+		}
+	}
+	return top + 1
+}
+
+// addToGroups 把用户加入 /etc/group 和 /etc/gshadow 中的组；replace 时同时从其他组移除
+func (fs *SessionFS) addToGroups(name string, groups []string, replace bool) {
+	for _, p := range []string{"/etc/group", "/etc/gshadow"} {
+		// group 和 gshadow 的成员列表都在第 4 列
+		fs.updateDB(p, func(f []string) []string {
+			if len(f) < 4 {
+				return f
+			}
+			var members []string
+			if f[3] != "" {
+				members = strings.Split(f[3], ",")
+			}
+			want := containsString(groups, f[0])
+			has := containsString(members, name)
+			switch {
+			case want && !has:
+				members = append(members, name)
+			case !want && has && replace:
+				kept := members[:0]
+				for _, m := range members {
+					if m != name {
+						kept = append(kept, m)
+					}
+				}
+				members = kept
+			}
+			f[3] = strings.Join(members, ",")
+			return f
+		})
+	}
+}
+
+// checkGroups 检查 -G 的组列表，返回规范化后的组名
+func (t *Terminal) checkGroups(cmd, list string) ([]string, bool) {
+	var names []string
+	for _, g := range strings.Split(list, ",") {
+		if g == "" {
+			continue
+		}
+		e, ok := t.FS.lookupGroup(g)
+		if !ok {
+			fmt.Fprintf(t.Stderr, "%s: group '%s' does not exist\n", cmd, g)
+			t.lastExitCode = 6
+			return nil, false
+		}
+		names = append(names, e.Name)
+	}
+	return names, true
+}
+
+func (t *Terminal) cmdUseradd(args []string, out io.Writer) {
+	opts := map[byte]string{}
+	rest, bad := accountOpts(args, "bcdDefgGhkKlmMNoprRPsuU", "bcdefgGkKpRPsu", map[string]byte{
+		"--base-dir": 'b', "--comment": 'c', "--home-dir": 'd', "--defaults": 'D', "--expiredate": 'e',
+		"--inactive": 'f', "--gid": 'g', "--groups": 'G', "--help": 'h', "--skel": 'k', "--key": 'K',
+		"--no-log-init": 'l', "--create-home": 'm', "--no-create-home": 'M', "--no-user-group": 'N',
+		"--non-unique": 'o', "--password": 'p', "--system": 'r', "--root": 'R', "--prefix": 'P',
+		"--shell": 's', "--uid": 'u', "--user-group": 'U', "--badnames": 'B',
+	}, func(opt byte, val string) { opts[opt] = val })
+	has := func(o byte) bool { _, ok := opts[o]; return ok }
+	if has('h') {
+		io.WriteString(out, useraddUsage)
+		return
+	}
+	if has('D') && len(rest) == 0 && bad == "" {
+		fmt.Fprintln(out, "GROUP=100\nHOME=/home\nINACTIVE=-1\nEXPIRE=\nSHELL=/bin/sh\nSKEL=/etc/skel\nCREATE_MAIL_SPOOL=no")
+		return
+	}
+	if bad != "" || len(rest) != 1 {
+		if bad != "" {
+			fmt.Fprintf(t.Stderr, "useradd: %s\n", bad)
+		}
+		io.WriteString(t.Stderr, useraddUsage)
+		t.lastExitCode = 2
+		return
+	}
+	if t.accountDenied("useradd") {
+		return
+	}
+	name := rest[0]
+	if !validAccountName(name) {
+		fmt.Fprintf(t.Stderr, "useradd: invalid user name '%s'\n", name)
+		t.lastExitCode = 3
+		return
+	}
+	if _, ok := t.FS.lookupUser(name); ok {
+		fmt.Fprintf(t.Stderr, "useradd: user '%s' already exists\n", name)
+		t.lastExitCode = 9
+		return
+	}
+	usedUID, usedGID := map[int]bool{}, map[int]bool{}
+	for _, u := range t.FS.passwdEntries() {
+		usedUID[u.UID] = true
+	}
+	for _, g := range t.FS.groupEntries() {
+		usedGID[g.GID] = true
+	}
+
+	lo, hi := 1000, 60000
+	if has('r') {
+		lo, hi = 100, 999
+	}
+	uid := nextID(usedUID, lo, hi, has('r'))
+	if v, ok := opts['u']; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fmt.Fprintf(t.Stderr, "useradd: invalid user ID '%s'\n", v)
+			t.lastExitCode = 3
+			return
+		}
+		if usedUID[n] && !has('o') {
+			fmt.Fprintf(t.Stderr, "useradd: UID %d is not unique\n", n)
+			t.lastExitCode = 4
+			return
+		}
+		uid = n
+	}
+
+	// 主组：-g 指定的组；否则创建同名组 (USERGROUPS_ENAB)，-N 时使用 users 组
+	gid, newGroup := 100, ""
+	switch v, ok := opts['g']; {
+	case ok:
+		g, exists := t.FS.lookupGroup(v)
+		if !exists {
+			fmt.Fprintf(t.Stderr, "useradd: group '%s' does not exist\n", v)
+			t.lastExitCode = 6
+			return
+		}
+		gid = g.GID
+	case !has('N'):
+		if _, exists := t.FS.lookupGroup(name); exists {
+			fmt.Fprintf(t.Stderr, "useradd: group %s exists - if you want to add this user to that group, use -g.\n", name)
+			t.lastExitCode = 9
+			return
+		}
+		gid, newGroup = uid, name
+		if usedGID[gid] {
+			gid = nextID(usedGID, lo, hi, has('r'))
+		}
+	}
+	var extra []string
+	if v, ok := opts['G']; ok {
+		if extra, ok = t.checkGroups("useradd", v); !ok {
+			return
+		}
+	}
+
+	home := path.Join("/home", name)
+	if v, ok := opts['b']; ok {
+		home = path.Join(v, name)
+	}
+	if v, ok := opts['d']; ok {
+		home = v
+	}
+	shell := "/bin/sh"
+	if v, ok := opts['s']; ok && v != "" {
+		shell = v
+	}
+	hash := "!"
+	if v, ok := opts['p']; ok {
+		hash = v
+	}
+
+	t.FS.updateDB("/etc/passwd", nil, fmt.Sprintf("%s:x:%d:%d:%s:%s:%s", name, uid, gid, opts['c'], home, shell))
+	t.FS.updateDB("/etc/shadow", nil, fmt.Sprintf("%s:%s:%s:0:99999:7:::", name, hash, shadowDays(time.Now())))
+	if newGroup != "" {
+		t.FS.updateDB("/etc/group", nil, fmt.Sprintf("%s:x:%d:", newGroup, gid))
+		t.FS.updateDB("/etc/gshadow", nil, newGroup+":!::")
+	}
+	if len(extra) > 0 {
+		t.FS.addToGroups(name, extra, false)
+	}
+	log.Printf("[Account] %s: useradd %s uid=%d gid=%d groups=%s home=%s shell=%s hash=%q",
+		t.Remote, name, uid, gid, strings.Join(extra, ","), home, shell, hash)
+
+	if has('m') && !has('M') {
+		if e, ok := t.FS.GetEntry(home); ok && e.IsDir {
+			fmt.Fprintf(t.Stderr, "useradd: warning: the home directory %s already exists.\nuseradd: Not copying any file from skel directory into it.\n", home)
+			return
+		}
+		t.FS.Mkdir(home)
+		t.FS.Chmod(home, 0750)
+		t.FS.Chown(home, uid, gid)
+		skel := "/etc/skel"
+		if v, ok := opts['k']; ok {
+			skel = v
+		}
+		if items, err := t.FS.ListDir(skel); err == nil {
+			for _, e := range items {
+				if e.IsDir {
+					continue
+				}
+				p := path.Join(home, e.Name)
+				e.mu.RLock()
+				data := append([]byte(nil), e.Content...)
+				e.mu.RUnlock()
+				t.FS.Write(p, data, e.Mode)
+				t.FS.Chown(p, uid, gid)
+			}
+		}
+	}
+}
+
+func (t *Terminal) cmdUsermod(args []string, out io.Writer) {
+	opts := map[byte]string{}
+	rest, bad := accountOpts(args, "abcdefgGhlLmopPrRsuU", "cdefgGlpPRsu", map[string]byte{
+		"--append": 'a', "--badnames": 'b', "--comment": 'c', "--home": 'd', "--expiredate": 'e',
+		"--inactive": 'f', "--gid": 'g', "--groups": 'G', "--help": 'h', "--login": 'l', "--lock": 'L',
+		"--move-home": 'm', "--non-unique": 'o', "--password": 'p', "--prefix": 'P', "--remove": 'r',
+		"--root": 'R', "--shell": 's', "--uid": 'u', "--unlock": 'U',
+	}, func(opt byte, val string) { opts[opt] = val })
+	has := func(o byte) bool { _, ok := opts[o]; return ok }
+	if has('h') {
+		io.WriteString(out, usermodUsage)
+		return
+	}
+	if bad != "" || len(rest) != 1 {
+		if bad != "" {
+			fmt.Fprintf(t.Stderr, "usermod: %s\n", bad)
+		}
+		io.WriteString(t.Stderr, usermodUsage)
+		t.lastExitCode = 2
+		return
+	}
+	if len(opts) == 0 {
+		fmt.Fprintln(t.Stderr, "usermod: no options")
+		io.WriteString(t.Stderr, usermodUsage)
+		t.lastExitCode = 2
+		return
+	}
+	if (has('a') || has('r')) && !has('G') {
+		fmt.Fprintf(t.Stderr, "usermod: %s flag is only allowed with the -G flag\n", map[bool]string{true: "-a", false: "-r"}[has('a')])
+		io.WriteString(t.Stderr, usermodUsage)
+		t.lastExitCode = 2
+		return
+	}
+	if t.accountDenied("usermod") {
+		return
+	}
+	name := rest[0]
+	u, ok := t.FS.lookupUser(name)
+	if !ok {
+		fmt.Fprintf(t.Stderr, "usermod: user '%s' does not exist\n", name)
+		t.lastExitCode = 6
+		return
+	}
+
+	uid, gid := u.UID, u.GID
+	if v, ok := opts['u']; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fmt.Fprintf(t.Stderr, "usermod: invalid user ID '%s'\n", v)
+			t.lastExitCode = 3
+			return
+		}
+		taken := false
+		for _, e := range t.FS.passwdEntries() {
+			taken = taken || e.UID == n && e.Name != name
+		}
+		if taken && !has('o') {
+			fmt.Fprintf(t.Stderr, "usermod: UID '%d' already exists\n", n)
+			t.lastExitCode = 4
+			return
+		}
+		uid = n
+	}
+	if v, ok := opts['g']; ok {
+		g, exists := t.FS.lookupGroup(v)
+		if !exists {
+			fmt.Fprintf(t.Stderr, "usermod: group '%s' does not exist\n", v)
+			t.lastExitCode = 6
+			return
+		}
+		gid = g.GID
+	}
+	var groups []string
+	if v, ok := opts['G']; ok {
+		if groups, ok = t.checkGroups("usermod", v); !ok {
+			return
+		}
+	}
+	newName := name
+	if v, ok := opts['l']; ok && v != name {
+		if !validAccountName(v) {
+			fmt.Fprintf(t.Stderr, "usermod: invalid user name '%s'\n", v)
+			t.lastExitCode = 3
+			return
+		}
+		if _, exists := t.FS.lookupUser(v); exists {
+			fmt.Fprintf(t.Stderr, "usermod: user '%s' already exists\n", v)
+			t.lastExitCode = 9
+			return
+		}
+		newName = v
+	}
+	if has('L') && has('U') {
+		fmt.Fprintln(t.Stderr, "usermod: the -L, -p, and -U flags are exclusive")
+		io.WriteString(t.Stderr, usermodUsage)
+		t.lastExitCode = 2
+		return
+	}
+	if hash, _ := t.FS.shadowHash(name); has('U') && strings.TrimPrefix(hash, "!") == "" {
+		fmt.Fprintln(t.Stderr, "usermod: unlocking the user's password would result in a passwordless account.\nYou should set a password with usermod -p to unlock this user's password.")
+		return
+	}
+
+	home, shell, gecos := u.Home, u.Shell, u.Gecos
+	if v, ok := opts['d']; ok {
+		home = v
+	}
+	if v, ok := opts['s']; ok {
+		shell = v
+	}
+	if v, ok := opts['c']; ok {
+		gecos = v
+	}
+	t.FS.updateDB("/etc/passwd", func(f []string) []string {
+		if f[0] == name && len(f) >= 7 {
+			f[0], f[2], f[3], f[4], f[5], f[6] = newName, strconv.Itoa(uid), strconv.Itoa(gid), gecos, home, shell
+		}
+		return f
+	})
+	t.FS.updateDB("/etc/shadow", func(f []string) []string {
+		if f[0] != name || len(f) < 9 {
+			return f
+		}
+		f[0] = newName
+		switch {
+		case has('p'):
+			f[1], f[2] = opts['p'], shadowDays(time.Now())
+		case has('L') && !strings.HasPrefix(f[1], "!"):
+			f[1] = "!" + f[1]
+		case has('U'):
+			f[1] = strings.TrimPrefix(f[1], "!")
+		}
+		return f
+	})
+	if newName != name {
+		// 组成员列表中的旧用户名一并改名
+		for _, p := range []string{"/etc/group", "/etc/gshadow"} {
+			t.FS.updateDB(p, func(f []string) []string {
+				if len(f) < 4 {
+					return f
+				}
+				members := strings.Split(f[3], ",")
+				for i, m := range members {
+					if m == name {
+						members[i] = newName
+					}
+				}
+				f[3] = strings.Join(members, ",")
+				return f
+			})
+		}
+	}
+	if has('G') {
+		if has('r') {
+			var keep []string
+			for _, g := range t.FS.memberOf(passwdEntry{Name: newName, GID: gid})[1:] {
+				if !containsString(groups, g.Name) {
+					keep = append(keep, g.Name)
+				}
+			}
+			t.FS.addToGroups(newName, keep, true)
+		} else {
+			t.FS.addToGroups(newName, groups, !has('a'))
+		}
+	}
+	if home != u.Home && has('m') {
+		if e, ok := t.FS.GetEntry(u.Home); ok && e.IsDir {
+			t.FS.Rename(u.Home, home)
+		}
+	}
+	if uid != u.UID || gid != u.GID {
+		if e, ok := t.FS.GetEntry(home); ok && e.IsDir && e.UID == u.UID {
+			t.FS.Chown(home, uid, gid)
+		}
+	}
+	var changes []string
+	for _, o := range []byte("acdgGlLmopsuU") {
+		if v, ok := opts[o]; ok {
+			changes = append(changes, strings.TrimSpace("-"+string(o)+" "+v))
+		}
+	}
+	log.Printf("[Account] %s: usermod %s %s", t.Remote, name, strings.Join(changes, " "))
+}
+
+// shadowFor 为 passwd 中的账户生成初始 /etc/shadow：root 和基础系统账户为 "*"，
+// 软件包创建的系统账户为 "!"，普通用户使用随机密码的哈希
+func shadowFor(passwd string, days string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(passwd), "\n") {
+		f := strings.Split(line, ":")
+		uid, _ := strconv.Atoi(f[2])
+		hash := "*"
+		switch {
+		case uid >= 1000 && uid < 65534:
+			hash = cryptHash(randomSuffix(16))
+		case uid >= 100 && uid < 65534:
+			hash = "!"
+		}
+		fmt.Fprintf(&b, "%s:%s:%s:0:99999:7:::\n", f[0], hash, days)
+	}
+	return b.String()
+}
+
+// gshadowFor 生成与 /etc/group 对应的 /etc/gshadow
+func gshadowFor(group string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(group), "\n") {
+		f := strings.Split(line, ":")
+		gid, _ := strconv.Atoi(f[2])
+		pass := "*"
+		if gid >= 100 && gid < 65534 {
+			pass = "!"
+		}
+		fmt.Fprintf(&b, "%s:%s::%s\n", f[0], pass, f[3])
+	}
+	return b.String()
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// 虚拟文件: /dev、/proc、/sys
+// 这些条目不保存在 BaseFS 或 overlay 中，而是在每次读取时由提供者生成，
+// 内容取自 HostPersona、进程表和会话状态，保证与 uptime/free/top/ps 等命令一致
+// ==========================================
+
+// VirtualProvider 为挂载点下的路径按需生成条目
+type VirtualProvider interface {
+	// Lookup 返回 p 对应的条目，文件内容在调用时生成
+	Lookup(fs *SessionFS, p string) (*FileEntry, bool)
+	// List 返回目录 dir 下由提供者生成的子项
+	List(fs *SessionFS, dir string) []*FileEntry
+}
+
+// virtualStreamer 由提供无限数据流的提供者实现 (如 /dev/zero)
+type virtualStreamer interface {
+	Open(p string) (io.Reader, bool)
+}
+
+// virtualSink 由接受写入的提供者实现，返回 false 表示 p 不可写
+type virtualSink interface {
+	WriteFile(p string, data []byte) bool
+}
+
+var virtualMounts = []struct {
+	prefix   string
+	provider VirtualProvider
+}{
+	{"/dev", devProvider{}},
+	{"/proc", procProvider{}},
+	{"/sys", sysProvider{}},
+}
+
+var errReadOnly = errors.New("权限不够")
+
+// virtualMount 返回负责 p 的提供者，挂载点目录本身也归提供者列举
+func virtualMount(p string) (VirtualProvider, bool) {
+	for _, m := range virtualMounts {
+		if p == m.prefix || strings.HasPrefix(p, m.prefix+"/") {
+			return m.provider, true
+		}
+	}
+	return nil, false
+}
+
+// Stream 返回字符设备的数据流，p 不是流式设备时返回 false
+func (fs *SessionFS) Stream(p string) (io.Reader, bool) {
+	p = path.Clean(p)
+	if v, ok := virtualMount(p); ok {
+		if s, ok := v.(virtualStreamer); ok {
+			return s.Open(p)
+		}
+	}
+	return nil, false
+}
+
+// writeVirtual 处理对虚拟文件的写入，handled 为 false 表示 p 不是虚拟文件
+func (fs *SessionFS) writeVirtual(p string, data []byte) (handled bool, err error) {
+	v, ok := virtualMount(p)
+	if !ok {
+		return false, nil
+	}
+	if _, ok := v.Lookup(fs, p); !ok {
+		return false, nil
+	}
+	if s, ok := v.(virtualSink); ok && s.WriteFile(p, data) {
+		return true, nil
+	}
+	return true, errReadOnly
+}
+
+// virtualFile 是 virtualTree 中的一个文件
+type virtualFile struct {
+	mode os.FileMode
+	gen  func(fs *SessionFS) string
+}
+
+// virtualTree 以完整路径描述一组生成文件，中间目录由路径推导
+type virtualTree map[string]virtualFile
+
+func (vt virtualTree) lookup(fs *SessionFS, p string) (*FileEntry, bool) {
+	if f, ok := vt[p]; ok {
+		return &FileEntry{Name: path.Base(p), Content: []byte(f.gen(fs)), Mode: f.mode, ModTime: time.Now(), Nlink: 1}, true
+	}
+	for name := range vt {
+		if strings.HasPrefix(name, p+"/") {
+			return &FileEntry{Name: path.Base(p), IsDir: true, Mode: 0555 | os.ModeDir, ModTime: startTime, Nlink: 2}, true
+		}
+	}
+	return nil, false
+}
+
+func (vt virtualTree) list(fs *SessionFS, dir string) []*FileEntry {
+	var res []*FileEntry
+	seen := make(map[string]bool)
+	for name := range vt {
+		rest, ok := strings.CutPrefix(name, dir+"/")
+		if !ok {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		if e, ok := vt.lookup(fs, dir+"/"+child); ok {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func ro(gen func(fs *SessionFS) string) virtualFile { return virtualFile{0444, gen} }
+
+func text(s string) func(*SessionFS) string {
+	return func(*SessionFS) string { return s }
+}
+
+// ---------- /dev ----------
+
+// devStreamLimit 限制单次从 /dev/zero、/dev/urandom 读取的总量，防止无休止的输出占满内存
+const devStreamLimit = 32 << 20
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// charDevices 模拟字符设备的主、次设备号，ls -l 在大小一栏显示它们
+var charDevices = map[string][2]int{
+	"/dev/null": {1, 3}, "/dev/zero": {1, 5}, "/dev/random": {1, 8}, "/dev/urandom": {1, 9},
+}
+
+type devProvider struct{}
+
+func (devProvider) Lookup(fs *SessionFS, p string) (*FileEntry, bool) {
+	if _, ok := charDevices[p]; !ok {
+		return nil, false
+	}
+	return &FileEntry{Name: path.Base(p), Mode: os.ModeDevice | os.ModeCharDevice | 0666, ModTime: startTime, Nlink: 1}, true
+}
+
+func (d devProvider) List(fs *SessionFS, dir string) []*FileEntry {
+	var res []*FileEntry
+	for p := range charDevices {
+		if path.Dir(p) == dir {
+			e, _ := d.Lookup(fs, p)
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (devProvider) Open(p string) (io.Reader, bool) {
+	switch p {
+	case "/dev/null":
+		return strings.NewReader(""), true
+	case "/dev/zero":
+		return io.LimitReader(zeroReader{}, devStreamLimit), true
+	case "/dev/random", "/dev/urandom":
+		return io.LimitReader(rand.Reader, devStreamLimit), true
+	}
+	return nil, false
+}
+
+// WriteFile 丢弃写入的数据，写 /dev/zero 和 /dev/urandom 在 Linux 上同样会成功
+func (devProvider) WriteFile(p string, data []byte) bool {
+	_, ok := charDevices[p]
+	return ok
+}
+
+// ---------- /proc ----------
+
+type procProvider struct{}
+
+// resolve 把 /proc/self 换成当前会话 shell 的 /proc/<pid>
+func (procProvider) resolve(fs *SessionFS, p string) string {
+	rest, ok := strings.CutPrefix(p, "/proc/self")
+	if ok && (rest == "" || rest[0] == '/') && fs.self != nil {
+		return "/proc/" + strconv.Itoa(fs.self()) + rest
+	}
+	return p
+}
+
+func (pp procProvider) Lookup(fs *SessionFS, p string) (*FileEntry, bool) {
+	if e, ok := procEntry(fs, pp.resolve(fs, p)); ok {
+		e.Name = path.Base(p)
+		return e, true
+	}
+	return procFiles.lookup(fs, p)
+}
+
+func (pp procProvider) List(fs *SessionFS, dir string) []*FileEntry {
+	res := procDirEntries(fs, pp.resolve(fs, dir))
+	if dir == "/proc" {
+		if e, ok := pp.Lookup(fs, "/proc/self"); ok {
+			res = append(res, e)
+		}
+	}
+	return append(res, procFiles.list(fs, dir)...)
+}
+
+// WriteFile 接受对 /proc/sys 可写参数的写入，但不改变生成的值
+func (procProvider) WriteFile(p string, data []byte) bool {
+	f, ok := procFiles[p]
+	return ok && f.mode&0200 != 0
+}
+
+var procFiles = virtualTree{
+	"/proc/version": ro(func(*SessionFS) string {
+		return fmt.Sprintf("Linux version %s (buildd@lcy02-amd64-001) (gcc (Ubuntu 11.3.0-1ubuntu1~22.04) 11.3.0, GNU ld (GNU Binutils for Ubuntu) 2.38) %s\n",
+			HostPersona.Kernel, HostPersona.KernelBuild)
+	}),
+	"/proc/cpuinfo": ro(procCPUInfo),
+	"/proc/meminfo": ro(func(fs *SessionFS) string { return memStats(fs).meminfo() }),
+	"/proc/uptime": ro(func(*SessionFS) string {
+		c := cpuStats()
+		return fmt.Sprintf("%.2f %.2f\n", time.Since(startTime).Seconds(), float64(c.idle)/100)
+	}),
+	"/proc/loadavg": ro(func(*SessionFS) string {
+		l1, l5, l15 := loadAverage()
+		running, total := 0, 0
+		for _, p := range Procs.Snapshot() {
+			if p.Stat[0] == 'R' {
+				running++
+			}
+			total++
+		}
+		return fmt.Sprintf("%.2f %.2f %.2f %d/%d %d\n", l1, l5, l15, max(running, 1), total, Procs.LastPID())
+	}),
+	"/proc/stat":      ro(procStat),
+	"/proc/mounts":    ro(text(procMounts)),
+	"/proc/net/tcp":   ro(procNetTCP),
+	"/proc/net/tcp6":  ro(procNetTCP6),
+	"/proc/net/udp":   ro(procNetUDP),
+	"/proc/net/dev":   ro(procNetDev),
+	"/proc/net/route": ro(procNetRoute),
+	"/proc/net/arp":   ro(procNetARP),
+	"/proc/cmdline": ro(func(*SessionFS) string {
+		return "BOOT_IMAGE=/boot/vmlinuz-" + HostPersona.Kernel + " root=/dev/sda2 ro quiet splash\n"
+	}),
+	"/proc/sys/kernel/hostname":           {0644, func(*SessionFS) string { return HostPersona.Hostname + "\n" }},
+	"/proc/sys/kernel/osrelease":          ro(func(*SessionFS) string { return HostPersona.Kernel + "\n" }),
+	"/proc/sys/kernel/ostype":             ro(text("Linux\n")),
+	"/proc/sys/kernel/version":            ro(func(*SessionFS) string { return HostPersona.KernelBuild + "\n" }),
+	"/proc/sys/kernel/pid_max":            {0644, text(strconv.Itoa(pidMax) + "\n")},
+	"/proc/sys/kernel/randomize_va_space": {0644, text("2\n")},
+	"/proc/sys/net/ipv4/ip_forward":       {0644, text("0\n")},
+}
+
+func procCPUInfo(*SessionFS) string {
+	var b strings.Builder
+	for i := 0; i < HostPersona.CPUs; i++ {
+		fmt.Fprintf(&b, "processor\t: %d\nvendor_id\t: GenuineIntel\ncpu family\t: 6\nmodel\t\t: 165\nmodel name\t: %s\n", i, HostPersona.CPUModel)
+		fmt.Fprintf(&b, "stepping\t: 5\ncpu MHz\t\t: 2904.004\ncache size\t: 16384 KB\nphysical id\t: 0\nsiblings\t: %d\ncore id\t\t: %d\ncpu cores\t: %d\n", HostPersona.CPUs, i, HostPersona.CPUs)
+		b.WriteString("flags\t\t: fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 clflush mmx fxsr sse sse2 ss ht syscall nx pdpe1gb rdtscp lm constant_tsc rep_good nopl xtopology cpuid pni pclmulqdq ssse3 fma cx16 pcid sse4_1 sse4_2 x2apic movbe popcnt aes xsave avx f16c rdrand lahf_lm abm 3dnowprefetch fsgsbase bmi1 avx2 smep bmi2 erms invpcid rdseed adx smap clflushopt xsaveopt xsavec xgetbv1 xsaves arat md_clear flush_l1d arch_capabilities\n")
+		b.WriteString("bogomips\t: 5808.00\nclflush size\t: 64\ncache_alignment\t: 64\naddress sizes\t: 39 bits physical, 48 bits virtual\npower management:\n\n")
+	}
+	return b.String()
+}
+
+// cpuTimes 是开机以来全部 CPU 累计的时钟滴答 (USER_HZ = 100)
+type cpuTimes struct {
+	user, system, idle, iowait int64
+}
+
+// cpuStats 由进程表的累计 CPU 时间推算 /proc/stat 与 /proc/uptime 的计数
+func cpuStats() cpuTimes {
+	var busy time.Duration
+	for _, p := range Procs.Snapshot() {
+		busy += max(p.CPUTime(), 0)
+	}
+	total := int64(time.Since(startTime).Seconds()*100) * int64(HostPersona.CPUs)
+	c := cpuTimes{user: int64(busy.Seconds() * 100)}
+	c.system = c.user/3 + total/400
+	c.iowait = total / 2000
+	c.idle = max(total-c.user-c.system-c.iowait, 0)
+	return c
+}
+
+func procStat(*SessionFS) string {
+	c := cpuStats()
+	n := int64(HostPersona.CPUs)
+	var b strings.Builder
+	fmt.Fprintf(&b, "cpu  %d 0 %d %d %d 0 %d 0 0 0\n", c.user, c.system, c.idle, c.iowait, c.system/20)
+	for i := int64(0); i < n; i++ {
+		fmt.Fprintf(&b, "cpu%d %d 0 %d %d %d 0 %d 0 0 0\n", i, c.user/n, c.system/n, c.idle/n, c.iowait/n, c.system/20/n)
+	}
+	up := int64(time.Since(startTime).Seconds())
+	running := 0
+	for _, p := range Procs.Snapshot() {
+		if p.Stat[0] == 'R' {
+			running++
+		}
+	}
+	fmt.Fprintf(&b, "intr %d\nctxt %d\nbtime %d\nprocesses %d\nprocs_running %d\nprocs_blocked 0\nsoftirq %d\n",
+		up*310+14022, up*590+31877, startTime.Unix(), Procs.LastPID(), max(running, 1), up*120+5123)
+	return b.String()
+}
+
+// procMounts 与 df 显示的文件系统保持一致
+var procMounts = fmt.Sprintf(`sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+udev /dev devtmpfs rw,nosuid,relatime,size=%dk,nr_inodes=2027282,mode=755,inode64 0 0
+devpts /dev/pts devpts rw,nosuid,noexec,relatime,gid=5,mode=620,ptmxmode=000 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,noexec,relatime,size=%[2]dk,mode=755,inode64 0 0
+/dev/sda2 / ext4 rw,relatime 0 0
+securityfs /sys/kernel/security securityfs rw,nosuid,nodev,noexec,relatime 0 0
+tmpfs /dev/shm tmpfs rw,nosuid,nodev,inode64 0 0
+tmpfs /run/lock tmpfs rw,nosuid,nodev,noexec,relatime,size=5120k,inode64 0 0
+cgroup2 /sys/fs/cgroup cgroup2 rw,nosuid,nodev,noexec,relatime,nsdelegate,memory_recursiveprot 0 0
+tmpfs /run/user/0 tmpfs rw,nosuid,nodev,relatime,size=%[2]dk,nr_inodes=407582,mode=700,inode64 0 0
+`, memTotalKiB/2, memTotalKiB/10)
+
+// memInfo 是 /proc/meminfo、free 和 top 共用的内存统计 (KiB)
+type memInfo struct {
+	Total, Free, Available, Buffers, Cached, Shmem, SReclaimable, SwapTotal, SwapFree int
+}
+
+func (m memInfo) BuffCache() int { return m.Buffers + m.Cached + m.SReclaimable }
+
+// Used 按 procps 的口径计算已用内存
+func (m memInfo) Used() int { return m.Total - m.Free - m.BuffCache() }
+
+// memStats 以进程常驻内存为已用，会话写入的文件计入页缓存
+func memStats(fs *SessionFS) memInfo {
+	used := 4_600_000
+	for _, p := range Procs.Snapshot() {
+		used += p.RSS
+	}
+	cached := 7_700_000
+	if fs != nil {
+		fs.mu.RLock()
+		for _, e := range fs.overlay {
+			if e != nil {
+				cached += len(e.Content) / 1024
+			}
+		}
+		fs.mu.RUnlock()
+	}
+	m := memInfo{
+		Total: memTotalKiB, Buffers: 223412, Cached: cached, Shmem: 2608, SReclaimable: 412788,
+		SwapTotal: 2097148, SwapFree: 2097148,
+	}
+	m.Free = max(m.Total-used-m.BuffCache(), 0)
+	m.Available = m.Free + m.BuffCache() - m.Shmem - m.SReclaimable/2
+	return m
+}
+
+func (m memInfo) meminfo() string {
+	var b strings.Builder
+	for _, f := range []struct {
+		name string
+		v    int
+	}{
+		{"MemTotal", m.Total}, {"MemFree", m.Free}, {"MemAvailable", m.Available},
+		{"Buffers", m.Buffers}, {"Cached", m.Cached}, {"SwapCached", 0},
+		{"Active", m.Used()/2 + m.Cached/3}, {"Inactive", m.Cached * 2 / 3},
+		{"SwapTotal", m.SwapTotal}, {"SwapFree", m.SwapFree},
+		{"Dirty", 148}, {"Writeback", 0}, {"AnonPages", m.Used() * 2 / 3},
+		{"Mapped", 287412}, {"Shmem", m.Shmem}, {"Slab", m.SReclaimable + 98312},
+		{"SReclaimable", m.SReclaimable}, {"SUnreclaim", 98312},
+		{"CommitLimit", m.Total/2 + m.SwapTotal}, {"Committed_AS", m.Used() + 1_300_000},
+		{"VmallocTotal", 34359738367},
+	} {
+		fmt.Fprintf(&b, "%-16s%8d kB\n", f.name+":", f.v)
+	}
+	// HugePages_* 是页数，没有单位
+	b.WriteString("HugePages_Total:       0\nHugePages_Free:        0\nHugePages_Rsvd:        0\nHugePages_Surp:        0\nHugepagesize:       2048 kB\n")
+	return b.String()
+}
+
+// cmdFree 以 /proc/meminfo 相同的数据输出 free，支持 -b/-k/-m/-g/-h 单位
+func (t *Terminal) cmdFree(args []string, out io.Writer) {
+	unit := 1 // 以 KiB 为单位的倍率，0 表示 -h
+	for _, a := range args[1:] {
+		switch a {
+		case "-b", "--bytes":
+			unit = -1024
+		case "-k", "--kibi":
+			unit = 1
+		case "-m", "--mebi":
+			unit = 1024
+		case "-g", "--gibi":
+			unit = 1024 * 1024
+		case "-h", "--human":
+			unit = 0
+		default:
+			t.usageError("free", fmt.Sprintf("无效的选项 -- '%s'", strings.TrimLeft(a, "-")), 1)
+			return
+		}
+	}
+	show := func(kib int) string {
+		switch {
+		case unit < 0:
+			return strconv.Itoa(kib * -unit)
+		case unit > 0:
+			return strconv.Itoa(kib / unit)
+		}
+		v := float64(kib) * 1024
+		for _, u := range []string{"B", "Ki", "Mi", "Gi", "Ti"} {
+			if v < 1024 || u == "Ti" {
+				if v < 10 && u != "B" {
+					return fmt.Sprintf("%.1f%s", v, u)
+				}
+				return fmt.Sprintf("%.0f%s", v, u)
+			}
+			v /= 1024
+		}
+		return ""
+	}
+	m := memStats(t.FS)
+	fmt.Fprintln(out, "              total        used        free      shared  buff/cache   available")
+	fmt.Fprintf(out, "%-7s%12s%12s%12s%12s%12s%12s\n", "Mem:", show(m.Total), show(m.Used()), show(m.Free), show(m.Shmem), show(m.BuffCache()), show(m.Available))
+	fmt.Fprintf(out, "%-7s%12s%12s%12s\n", "Swap:", show(m.SwapTotal), show(m.SwapTotal-m.SwapFree), show(m.SwapFree))
+}
+
+// loadAverage 随开机时长缓慢起伏，同一时刻 uptime、top 和 /proc/loadavg 读到相同的值
+func loadAverage() (l1, l5, l15 float64) {
+	// 内核每 5 秒更新一次负载
+	x := math.Floor(time.Since(startTime).Seconds()/5) * 5
+	l1 = 0.02 + 0.07*(1+math.Sin(x/97))
+	l5 = 0.03 + 0.04*(1+math.Sin(x/431))
+	l15 = 0.04 + 0.02*(1+math.Sin(x/1307))
+	return
+}
+
+// ---------- /sys ----------
+
+type sysProvider struct{}
+
+func (sysProvider) Lookup(fs *SessionFS, p string) (*FileEntry, bool) {
+	return sysFiles.lookup(fs, p)
+}
+
+func (sysProvider) List(fs *SessionFS, dir string) []*FileEntry {
+	return sysFiles.list(fs, dir)
+}
+
+var sysFiles = sysNetFiles(virtualTree{
+	"/sys/class/dmi/id/sys_vendor":     ro(text("Dell Inc.\n")),
+	"/sys/class/dmi/id/product_name":   ro(text("OptiPlex 7080\n")),
+	"/sys/class/dmi/id/bios_vendor":    ro(text("Dell Inc.\n")),
+	"/sys/devices/system/cpu/online":   ro(func(*SessionFS) string { return fmt.Sprintf("0-%d\n", HostPersona.CPUs-1) }),
+	"/sys/devices/system/cpu/possible": ro(func(*SessionFS) string { return fmt.Sprintf("0-%d\n", HostPersona.CPUs-1) }),
+})
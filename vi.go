@@ -0,0 +1,1363 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==========================================
+// vi / vim
+// 普通模式、插入模式和命令行模式，文件读写走 SessionFS
+// ==========================================
+
+const (
+	viNormal = iota
+	viInsert
+	viCmdline
+)
+
+// viUndoLimit 撤销历史的最大步数
+const viUndoLimit = 100
+
+type viSnapshot struct {
+	lines    []string
+	row, col int
+}
+
+type viEditor struct {
+	t    *Terminal
+	rs   *rawSession
+	out  io.Writer
+	tool string // vi / vim
+
+	name     string // 显示用的文件名
+	path     string // 绝对路径，未命名缓冲区为空
+	lines    [][]rune
+	orig     string // 打开或上次保存时的内容
+	modified bool
+
+	row, col int // 光标所在行和字符下标
+	top      int // 屏幕第一行显示的行号
+	width    int
+	height   int
+
+	mode    int
+	cmdKind rune   // ':' '/' '?'
+	cmdline []rune // 命令行模式下输入的内容
+	msg     string // 底部消息
+	msgErr  bool
+
+	count   int  // 数字前缀
+	pending rune // 等待后续按键的命令 (d y c g r Z)
+	reg     string
+	regLine bool // 寄存器内容是整行
+
+	undo []viSnapshot
+	redo []viSnapshot
+
+	lastSearch string
+	searchBack bool
+	number     bool
+	quit       bool
+}
+
+func (t *Terminal) cmdVi(args []string, out io.Writer) {
+	if !isTTY(out) {
+		fmt.Fprintln(out, "Vim: 警告: 输出不是到终端")
+		t.lastExitCode = 1
+		return
+	}
+	v := &viEditor{t: t, out: out, tool: args[0], lines: [][]rune{{}}}
+	gotoLine := 0
+	file := ""
+	for _, a := range args[1:] {
+		switch {
+		case a == "+":
+			gotoLine = -1
+		case strings.HasPrefix(a, "+"):
+			gotoLine, _ = strconv.Atoi(a[1:])
+		case strings.HasPrefix(a, "-"):
+			// 其他选项忽略
+		case file == "":
+			file = a
+		}
+	}
+	if file != "" {
+		v.open(file)
+	}
+	switch {
+	case gotoLine < 0:
+		v.row = len(v.lines) - 1
+	case gotoLine > 0:
+		v.row = min(gotoLine, len(v.lines)) - 1
+	}
+
+	self := t.spawn(args)
+	defer Procs.Remove(self.PID)
+
+	v.rs = t.enterRaw()
+	defer v.rs.close()
+	fmt.Fprint(out, "\033[?1049h\033[H\033[2J")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+
+	for !v.quit {
+		if err := v.render(); err != nil {
+			return // 连接已断开
+		}
+		for {
+			ev, ok := v.rs.next(500 * time.Millisecond)
+			if ok {
+				v.handle(ev)
+				break
+			}
+			if v.rs.killed() {
+				return
+			}
+			if w, h := v.rs.size(); w != v.width || h != v.height {
+				break
+			}
+		}
+	}
+}
+
+// open 读入文件，替换当前缓冲区
+func (v *viEditor) open(name string) {
+	p := v.t.FS.Abs(v.t.expandTilde(name))
+	lines, orig, exists, isDir := v.t.loadText(p)
+	v.name, v.path = name, p
+	v.lines, v.orig, v.modified = lines, orig, false
+	v.row, v.col, v.top = 0, 0, 0
+	v.undo, v.redo = nil, nil
+	switch {
+	case isDir:
+		v.setMsg(fmt.Sprintf("\"%s\" 是一个目录", name), false)
+	case !exists:
+		v.setMsg(fmt.Sprintf("\"%s\" [新]", name), false)
+	default:
+		n := len(lines)
+		if orig == "" {
+			n = 0
+		}
+		v.setMsg(fmt.Sprintf("\"%s\" %dL, %dB", name, n, len(orig)), false)
+	}
+	log.Printf("[Edit] %s: %s opened %s with %s", v.t.Remote, v.t.userName(), p, v.tool)
+}
+
+func (v *viEditor) setMsg(msg string, isErr bool) {
+	v.msg, v.msgErr = msg, isErr
+}
+
+// ==========================================
+// 绘制
+// ==========================================
+
+// wrapLine 按屏幕宽度折行，返回每个屏幕行的内容以及下标 cursor 所在的屏幕行和列
+func wrapLine(line []rune, width, cursor int) (rows []string, cy, cx int) {
+	var b strings.Builder
+	col, x := 0, 0
+	for i, r := range line {
+		cw := cellWidth(r, col)
+		if x+cw > width && x > 0 {
+			rows = append(rows, b.String())
+			b.Reset()
+			x = 0
+		}
+		if i == cursor {
+			cy, cx = len(rows), x
+		}
+		b.WriteString(cellText(r, col))
+		x += cw
+		col += cw
+	}
+	if cursor >= len(line) {
+		if x >= width {
+			rows = append(rows, b.String())
+			b.Reset()
+			x = 0
+		}
+		cy, cx = len(rows), x
+	}
+	rows = append(rows, b.String())
+	return rows, cy, cx
+}
+
+// gutter 返回行号栏的宽度
+func (v *viEditor) gutter() int {
+	if !v.number {
+		return 0
+	}
+	return max(3, len(strconv.Itoa(len(v.lines)))) + 1
+}
+
+// scroll 调整 top，保证光标所在行完整显示
+func (v *viEditor) scroll(textW, textRows int) {
+	if v.row < v.top {
+		v.top = v.row
+	}
+	for v.top < v.row {
+		used := 0
+		for i := v.top; i <= v.row; i++ {
+			rows, _, _ := wrapLine(v.lines[i], textW, -1)
+			used += len(rows)
+		}
+		if used <= textRows {
+			break
+		}
+		v.top++
+	}
+}
+
+func (v *viEditor) render() error {
+	v.width, v.height = v.rs.size()
+	w, textRows := v.width, v.height-1
+	gw := v.gutter()
+	textW := max(w-gw, 1)
+	v.clampCursor()
+	v.scroll(textW, textRows)
+
+	var b strings.Builder
+	b.WriteString("\033[?25l")
+	y, curY, curX := 0, 0, 0
+	last := v.top
+	for i := v.top; i < len(v.lines) && y < textRows; i++ {
+		cursor := -1
+		if i == v.row {
+			cursor = v.col
+		}
+		rows, cy, cx := wrapLine(v.lines[i], textW, cursor)
+		if i > v.top && y+len(rows) > textRows {
+			// 放不下的行用 @ 占位
+			for ; y < textRows; y++ {
+				fmt.Fprintf(&b, "\033[%d;1H\033[94m@\033[0m\033[K", y+1)
+			}
+			break
+		}
+		if i == v.row {
+			curY, curX = y+cy, gw+cx
+		}
+		for j, r := range rows {
+			if y >= textRows {
+				break
+			}
+			fmt.Fprintf(&b, "\033[%d;1H", y+1)
+			if gw > 0 {
+				if j == 0 {
+					fmt.Fprintf(&b, "\033[33m%*d \033[0m", gw-1, i+1)
+				} else {
+					b.WriteString(strings.Repeat(" ", gw))
+				}
+			}
+			b.WriteString(r)
+			b.WriteString("\033[K")
+			y++
+		}
+		last = i
+	}
+	for ; y < textRows; y++ {
+		fmt.Fprintf(&b, "\033[%d;1H\033[94m~\033[0m\033[K", y+1)
+	}
+
+	// 底部：命令行、消息或模式提示，右侧是标尺
+	fmt.Fprintf(&b, "\033[%d;1H\033[K", v.height)
+	if v.mode == viCmdline {
+		text := string(v.cmdKind) + string(v.cmdline)
+		b.WriteString(text)
+		curY, curX = v.height-1, visibleWidth(text)
+	} else {
+		switch {
+		case v.msg != "" && v.msgErr:
+			b.WriteString("\033[97;41m" + v.msg + "\033[0m")
+		case v.msg != "":
+			b.WriteString(v.msg)
+		case v.mode == viInsert:
+			b.WriteString("\033[1m-- 插入 --\033[0m")
+		}
+		if w >= 40 {
+			fmt.Fprintf(&b, "\033[%d;%dH%-14s%s", v.height, w-17, v.ruler(), v.position(last))
+		}
+	}
+	fmt.Fprintf(&b, "\033[%d;%dH\033[?25h", curY+1, curX+1)
+	_, err := io.WriteString(v.out, b.String())
+	return err
+}
+
+// ruler 返回光标位置 "行,列"，空行为 "0-1"
+func (v *viEditor) ruler() string {
+	line := v.lines[v.row]
+	if len(line) == 0 {
+		return fmt.Sprintf("%d,0-1", v.row+1)
+	}
+	byteCol := len(string(line[:v.col])) + 1
+	dispCol := displayCol(line, v.col) + 1
+	if byteCol != dispCol {
+		return fmt.Sprintf("%d,%d-%d", v.row+1, byteCol, dispCol)
+	}
+	return fmt.Sprintf("%d,%d", v.row+1, byteCol)
+}
+
+// position 返回屏幕在文件中的位置：全部、顶端、底端或百分比
+func (v *viEditor) position(last int) string {
+	below := len(v.lines) - 1 - last
+	switch {
+	case v.top == 0 && below == 0:
+		return "全部"
+	case v.top == 0:
+		return "顶端"
+	case below == 0:
+		return "底端"
+	}
+	return fmt.Sprintf("%d%%", v.top*100/(v.top+below))
+}
+
+// ==========================================
+// 光标和编辑辅助
+// ==========================================
+
+// clampCursor 把光标限制在有效范围内；普通模式下光标不能停在行尾之后
+func (v *viEditor) clampCursor() {
+	if len(v.lines) == 0 {
+		v.lines = [][]rune{{}}
+	}
+	v.row = max(0, min(v.row, len(v.lines)-1))
+	limit := len(v.lines[v.row])
+	if v.mode != viInsert && limit > 0 {
+		limit--
+	}
+	v.col = max(0, min(v.col, limit))
+}
+
+// firstNonBlank 返回行内第一个非空白字符的下标
+func firstNonBlank(line []rune) int {
+	for i, r := range line {
+		if r != ' ' && r != '\t' {
+			return i
+		}
+	}
+	return 0
+}
+
+// takeCount 返回数字前缀 (默认为 1) 并清零
+func (v *viEditor) takeCount() int {
+	n := max(v.count, 1)
+	v.count = 0
+	return n
+}
+
+func (v *viEditor) snapshot() viSnapshot {
+	s := viSnapshot{row: v.row, col: v.col}
+	for _, l := range v.lines {
+		s.lines = append(s.lines, string(l))
+	}
+	return s
+}
+
+func (v *viEditor) restore(s viSnapshot) {
+	v.lines = v.lines[:0]
+	for _, l := range s.lines {
+		v.lines = append(v.lines, []rune(l))
+	}
+	v.row, v.col = s.row, s.col
+	v.modified = joinText(v.lines) != v.orig
+}
+
+// change 在修改缓冲区之前调用，记录撤销点
+func (v *viEditor) change() {
+	v.undo = append(v.undo, v.snapshot())
+	if len(v.undo) > viUndoLimit {
+		v.undo = v.undo[1:]
+	}
+	v.redo = nil
+	v.modified = true
+}
+
+// runeClass 单词划分：0 空白，1 单词字符，2 标点
+func runeClass(r rune) int {
+	switch {
+	case r == ' ' || r == '\t':
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+// nextWord 返回下一个单词的开头 (w)
+func (v *viEditor) nextWord(row, col int) (int, int) {
+	line := v.lines[row]
+	if col < len(line) {
+		if c := runeClass(line[col]); c != 0 {
+			for col < len(line) && runeClass(line[col]) == c {
+				col++
+			}
+		}
+	}
+	for {
+		line = v.lines[row]
+		for col < len(line) && runeClass(line[col]) == 0 {
+			col++
+		}
+		if col < len(line) {
+			return row, col
+		}
+		if row == len(v.lines)-1 {
+			return row, max(len(line)-1, 0)
+		}
+		row, col = row+1, 0
+		if len(v.lines[row]) == 0 {
+			return row, 0
+		}
+	}
+}
+
+// prevWord 返回上一个单词的开头 (b)
+func (v *viEditor) prevWord(row, col int) (int, int) {
+	for {
+		col--
+		if col < 0 {
+			if row == 0 {
+				return 0, 0
+			}
+			row--
+			col = len(v.lines[row])
+			if col == 0 {
+				return row, 0
+			}
+			continue
+		}
+		if runeClass(v.lines[row][col]) != 0 {
+			break
+		}
+	}
+	line := v.lines[row]
+	c := runeClass(line[col])
+	for col > 0 && runeClass(line[col-1]) == c {
+		col--
+	}
+	return row, col
+}
+
+// wordEnd 返回当前或下一个单词的结尾 (e)
+func (v *viEditor) wordEnd(row, col int) (int, int) {
+	col++
+	for {
+		line := v.lines[row]
+		for col < len(line) && runeClass(line[col]) == 0 {
+			col++
+		}
+		if col < len(line) {
+			break
+		}
+		if row == len(v.lines)-1 {
+			return row, max(len(line)-1, 0)
+		}
+		row, col = row+1, 0
+	}
+	line := v.lines[row]
+	c := runeClass(line[col])
+	for col+1 < len(line) && runeClass(line[col+1]) == c {
+		col++
+	}
+	return row, col
+}
+
+// deleteLines 删除 [from, to] 行并放入寄存器
+func (v *viEditor) deleteLines(from, to int) {
+	v.yankLines(from, to)
+	v.lines = append(v.lines[:from], v.lines[to+1:]...)
+	if len(v.lines) == 0 {
+		v.lines = [][]rune{{}}
+	}
+	v.row = min(from, len(v.lines)-1)
+	v.col = firstNonBlank(v.lines[v.row])
+}
+
+func (v *viEditor) yankLines(from, to int) {
+	var parts []string
+	for i := from; i <= to; i++ {
+		parts = append(parts, string(v.lines[i]))
+	}
+	v.reg, v.regLine = strings.Join(parts, "\n"), true
+}
+
+// deleteRange 删除当前行 [from, to) 之间的字符并放入寄存器
+func (v *viEditor) deleteRange(from, to int) {
+	line := v.lines[v.row]
+	to = min(to, len(line))
+	if from >= to {
+		return
+	}
+	v.reg, v.regLine = string(line[from:to]), false
+	v.lines[v.row] = append(append([]rune{}, line[:from]...), line[to:]...)
+	v.col = from
+}
+
+// insertText 在光标处插入文本，可以包含换行
+func (v *viEditor) insertText(s string) {
+	for _, r := range s {
+		if r == '\n' {
+			v.newline()
+			continue
+		}
+		line := v.lines[v.row]
+		nl := make([]rune, 0, len(line)+1)
+		nl = append(append(append(nl, line[:v.col]...), r), line[v.col:]...)
+		v.lines[v.row] = nl
+		v.col++
+	}
+}
+
+// newline 在光标处断行
+func (v *viEditor) newline() {
+	line := v.lines[v.row]
+	head := append([]rune{}, line[:v.col]...)
+	tail := append([]rune{}, line[v.col:]...)
+	v.lines[v.row] = head
+	v.lines = append(v.lines[:v.row+1], append([][]rune{tail}, v.lines[v.row+1:]...)...)
+	v.row, v.col = v.row+1, 0
+}
+
+// ==========================================
+// 按键处理
+// ==========================================
+
+func (v *viEditor) handle(ev keyEvent) {
+	if v.mode != viCmdline {
+		v.msg = ""
+	}
+	if ev.code == keyAlt {
+		// ESC 之后紧跟的字符：先按 ESC 处理，再作为普通模式命令
+		v.handle(keyEvent{keyRune, 27})
+		v.handle(keyEvent{keyRune, ev.r})
+		return
+	}
+	switch v.mode {
+	case viInsert:
+		v.insertKey(ev)
+	case viCmdline:
+		v.cmdlineKey(ev)
+	default:
+		v.normalKey(ev)
+	}
+}
+
+func (v *viEditor) insertKey(ev keyEvent) {
+	switch ev.code {
+	case keyUp:
+		v.row--
+	case keyDown:
+		v.row++
+	case keyLeft:
+		v.col--
+	case keyRight:
+		v.col++
+	case keyHome:
+		v.col = 0
+	case keyEnd:
+		v.col = len(v.lines[v.row])
+	case keyDelete:
+		if v.col < len(v.lines[v.row]) {
+			v.deleteRange(v.col, v.col+1)
+		} else if v.row < len(v.lines)-1 {
+			v.lines[v.row] = append(v.lines[v.row], v.lines[v.row+1]...)
+			v.lines = append(v.lines[:v.row+1], v.lines[v.row+2:]...)
+		}
+	case keyRune:
+		switch r := ev.r; r {
+		case 27, 3: // ESC / Ctrl-C
+			v.mode = viNormal
+			v.col--
+		case '\r', '\n':
+			v.newline()
+		case 127, 8:
+			if v.col > 0 {
+				v.col--
+				line := v.lines[v.row]
+				v.lines[v.row] = append(append([]rune{}, line[:v.col]...), line[v.col+1:]...)
+			} else if v.row > 0 {
+				prev := v.lines[v.row-1]
+				v.col = len(prev)
+				v.lines[v.row-1] = append(append([]rune{}, prev...), v.lines[v.row]...)
+				v.lines = append(v.lines[:v.row], v.lines[v.row+1:]...)
+				v.row--
+			}
+		case 23: // Ctrl-W 删除前一个单词
+			from := v.col
+			line := v.lines[v.row]
+			for from > 0 && runeClass(line[from-1]) == 0 {
+				from--
+			}
+			if from > 0 {
+				c := runeClass(line[from-1])
+				for from > 0 && runeClass(line[from-1]) == c {
+					from--
+				}
+			}
+			v.lines[v.row] = append(append([]rune{}, line[:from]...), line[v.col:]...)
+			v.col = from
+		default:
+			if r == '\t' || r >= 32 {
+				v.insertText(string(r))
+			}
+		}
+	}
+	v.clampCursor()
+}
+
+func (v *viEditor) cmdlineKey(ev keyEvent) {
+	if ev.code != keyRune {
+		return
+	}
+	switch r := ev.r; r {
+	case 27, 3:
+		v.mode = viNormal
+	case '\r', '\n':
+		v.mode = viNormal
+		cmd := string(v.cmdline)
+		if v.cmdKind == ':' {
+			v.exCommand(cmd)
+		} else {
+			v.search(cmd, v.cmdKind == '?')
+		}
+	case 127, 8:
+		if len(v.cmdline) == 0 {
+			v.mode = viNormal
+		} else {
+			v.cmdline = v.cmdline[:len(v.cmdline)-1]
+		}
+	case 21: // Ctrl-U
+		v.cmdline = v.cmdline[:0]
+	default:
+		if r >= 32 || r == '\t' {
+			v.cmdline = append(v.cmdline, r)
+		}
+	}
+}
+
+// viKey 把方向键等转换为对应的普通模式命令
+func viKey(ev keyEvent) rune {
+	switch ev.code {
+	case keyRune:
+		return ev.r
+	case keyUp:
+		return 'k'
+	case keyDown:
+		return 'j'
+	case keyLeft:
+		return 'h'
+	case keyRight:
+		return 'l'
+	case keyHome:
+		return '0'
+	case keyEnd:
+		return '$'
+	case keyDelete:
+		return 'x'
+	case keyWordLeft:
+		return 'b'
+	case keyWordRight:
+		return 'w'
+	}
+	return 0
+}
+
+func (v *viEditor) normalKey(ev keyEvent) {
+	r := viKey(ev)
+	if r == 0 {
+		return
+	}
+	if v.pending != 0 {
+		op := v.pending
+		v.pending = 0
+		v.operator(op, r)
+		v.clampCursor()
+		return
+	}
+	if r >= '1' && r <= '9' || r == '0' && v.count > 0 {
+		if v.count < 100000 {
+			v.count = v.count*10 + int(r-'0')
+		}
+		return
+	}
+
+	line := v.lines[v.row]
+	switch r {
+	case 27:
+		if v.count == 0 {
+			io.WriteString(v.out, "\a")
+		}
+		v.count = 0
+	case 'h', 8, 127:
+		v.col -= v.takeCount()
+	case 'l', ' ':
+		v.col += v.takeCount()
+	case 'j', 14, '+', '\r':
+		v.row += v.takeCount()
+		if r == '+' || r == '\r' {
+			v.clampCursor()
+			v.col = firstNonBlank(v.lines[v.row])
+		}
+	case 'k', 16, '-':
+		v.row -= v.takeCount()
+		if r == '-' {
+			v.clampCursor()
+			v.col = firstNonBlank(v.lines[v.row])
+		}
+	case '0':
+		v.col = 0
+	case '^':
+		v.col = firstNonBlank(line)
+	case '$':
+		v.row += v.takeCount() - 1
+		v.clampCursor()
+		v.col = len(v.lines[v.row])
+	case 'w', 'b', 'e':
+		for n := v.takeCount(); n > 0; n-- {
+			switch r {
+			case 'w':
+				v.row, v.col = v.nextWord(v.row, v.col)
+			case 'b':
+				v.row, v.col = v.prevWord(v.row, v.col)
+			default:
+				v.row, v.col = v.wordEnd(v.row, v.col)
+			}
+		}
+	case 'G':
+		if v.count > 0 {
+			v.row = v.takeCount() - 1
+		} else {
+			v.row = len(v.lines) - 1
+		}
+		v.clampCursor()
+		v.col = firstNonBlank(v.lines[v.row])
+	case 6, 2, 4, 21: // Ctrl-F / Ctrl-B / Ctrl-D / Ctrl-U
+		page := max(v.height-3, 1)
+		if r == 4 || r == 21 {
+			page = max(v.height/2, 1)
+		}
+		if r == 2 || r == 21 {
+			page = -page
+		}
+		v.row += page
+		v.top = max(0, min(v.top+page, len(v.lines)-1))
+		v.count = 0
+	case 'g', 'd', 'y', 'c', 'r', 'Z':
+		v.pending = r
+	case 'x', 'X':
+		n := v.takeCount()
+		if len(line) == 0 {
+			break
+		}
+		v.change()
+		if r == 'x' {
+			v.deleteRange(v.col, v.col+n)
+		} else if v.col > 0 {
+			v.deleteRange(max(v.col-n, 0), v.col)
+		}
+	case 'D', 'C':
+		v.count = 0
+		v.change()
+		v.deleteRange(v.col, len(line))
+		if r == 'C' {
+			v.mode = viInsert
+		}
+	case 's':
+		n := v.takeCount()
+		v.change()
+		v.deleteRange(v.col, v.col+n)
+		v.mode = viInsert
+	case 'S':
+		v.operator('c', 'c')
+	case 'Y':
+		v.operator('y', 'y')
+	case 'p', 'P':
+		v.put(r == 'p', v.takeCount())
+	case 'i', 'a', 'I', 'A', 'o', 'O':
+		v.count = 0
+		v.change()
+		v.mode = viInsert
+		switch r {
+		case 'a':
+			if len(line) > 0 {
+				v.col++
+			}
+		case 'I':
+			v.col = firstNonBlank(line)
+		case 'A':
+			v.col = len(line)
+		case 'o':
+			v.lines = append(v.lines[:v.row+1], append([][]rune{{}}, v.lines[v.row+1:]...)...)
+			v.row, v.col = v.row+1, 0
+		case 'O':
+			v.lines = append(v.lines[:v.row], append([][]rune{{}}, v.lines[v.row:]...)...)
+			v.col = 0
+		}
+	case 'J':
+		n := max(v.takeCount(), 2) - 1
+		if v.row+1 >= len(v.lines) {
+			break
+		}
+		v.change()
+		for ; n > 0 && v.row+1 < len(v.lines); n-- {
+			cur := v.lines[v.row]
+			next := v.lines[v.row+1]
+			next = next[firstNonBlank(next):]
+			v.col = len(cur)
+			if len(cur) > 0 && len(next) > 0 {
+				cur = append(cur, ' ')
+			}
+			v.lines[v.row] = append(append([]rune{}, cur...), next...)
+			v.lines = append(v.lines[:v.row+1], v.lines[v.row+2:]...)
+		}
+	case '~':
+		n := v.takeCount()
+		if len(line) == 0 {
+			break
+		}
+		v.change()
+		for ; n > 0 && v.col < len(line); n-- {
+			c := line[v.col]
+			if unicode.IsUpper(c) {
+				line[v.col] = unicode.ToLower(c)
+			} else {
+				line[v.col] = unicode.ToUpper(c)
+			}
+			v.col++
+		}
+	case 'u':
+		v.count = 0
+		if len(v.undo) == 0 {
+			v.setMsg("已经位于最旧的改变", false)
+			break
+		}
+		v.redo = append(v.redo, v.snapshot())
+		v.restore(v.undo[len(v.undo)-1])
+		v.undo = v.undo[:len(v.undo)-1]
+	case 18: // Ctrl-R
+		v.count = 0
+		if len(v.redo) == 0 {
+			v.setMsg("已经位于最新的改变", false)
+			break
+		}
+		v.undo = append(v.undo, v.snapshot())
+		v.restore(v.redo[len(v.redo)-1])
+		v.redo = v.redo[:len(v.redo)-1]
+	case 'n', 'N':
+		v.count = 0
+		back := v.searchBack
+		if r == 'N' {
+			back = !back
+		}
+		v.find(v.lastSearch, back)
+	case ':', '/', '?':
+		v.count = 0
+		v.mode = viCmdline
+		v.cmdKind = r
+		v.cmdline = v.cmdline[:0]
+	case 7: // Ctrl-G
+		v.count = 0
+		name := v.name
+		if name == "" {
+			name = "[未命名]"
+		}
+		mod := ""
+		if v.modified {
+			mod = " [已修改]"
+		}
+		v.setMsg(fmt.Sprintf("\"%s\"%s %d 行 --%d%%--", name, mod, len(v.lines), (v.row+1)*100/len(v.lines)), false)
+	default:
+		v.count = 0
+	}
+	v.clampCursor()
+}
+
+// operator 处理两个按键组成的命令：dd、dw、yy、cw、gg、r<x>、ZZ 等
+func (v *viEditor) operator(op, r rune) {
+	n := v.takeCount()
+	switch op {
+	case 'g':
+		if r == 'g' {
+			v.row = n - 1
+			v.clampCursor()
+			v.col = firstNonBlank(v.lines[v.row])
+		}
+		return
+	case 'Z':
+		switch r {
+		case 'Z':
+			v.exCommand("x")
+		case 'Q':
+			v.exCommand("q!")
+		}
+		return
+	case 'r':
+		line := v.lines[v.row]
+		if r == 27 || v.col+n > len(line) {
+			return
+		}
+		v.change()
+		for i := 0; i < n; i++ {
+			line[v.col+i] = r
+		}
+		v.col += n - 1
+		return
+	}
+
+	// d / y / c 加移动
+	var from, to int
+	lineWise := false
+	switch r {
+	case op, 'j', 'k', 'G':
+		lineWise = true
+		from, to = v.row, v.row+n-1
+		switch r {
+		case 'j':
+			to = v.row + n
+		case 'k':
+			from, to = v.row-n, v.row
+		case 'G':
+			to = len(v.lines) - 1
+		}
+		from = max(from, 0)
+		to = min(to, len(v.lines)-1)
+	case 'w', 'e':
+		from, to = v.col, v.col
+		line := v.lines[v.row]
+		for i := 0; i < n && to < len(line); i++ {
+			if r == 'e' || op == 'c' && runeClass(line[to]) != 0 {
+				// cw 和 ce 一样不包含单词后的空白
+				_, end := v.wordEnd(v.row, to)
+				to = end + 1
+				continue
+			}
+			row, col := v.nextWord(v.row, to)
+			if row != v.row || col <= to {
+				col = len(line)
+			}
+			to = col
+		}
+	case '$':
+		from, to = v.col, len(v.lines[v.row])
+	case '0':
+		from, to = 0, v.col
+	case 'l', ' ':
+		from, to = v.col, v.col+n
+	case 'h':
+		from, to = max(v.col-n, 0), v.col
+	default:
+		return
+	}
+
+	if lineWise {
+		switch op {
+		case 'y':
+			v.yankLines(from, to)
+			if to > from {
+				v.setMsg(fmt.Sprintf("复制了 %d 行", to-from+1), false)
+			}
+		case 'd':
+			v.change()
+			v.deleteLines(from, to)
+			if to-from >= 2 {
+				v.setMsg(fmt.Sprintf("少了 %d 行", to-from+1), false)
+			}
+		case 'c':
+			v.change()
+			v.yankLines(from, to)
+			indent := v.lines[from][:firstNonBlank(v.lines[from])]
+			v.lines = append(v.lines[:from], append([][]rune{append([]rune{}, indent...)}, v.lines[to+1:]...)...)
+			v.row, v.col = from, len(indent)
+			v.mode = viInsert
+		}
+		return
+	}
+	line := v.lines[v.row]
+	to = min(to, len(line))
+	switch op {
+	case 'y':
+		if from < to {
+			v.reg, v.regLine = string(line[from:to]), false
+		}
+		v.col = from
+	case 'd', 'c':
+		v.change()
+		v.deleteRange(from, to)
+		if op == 'c' {
+			v.mode = viInsert
+		}
+	}
+}
+
+// put 粘贴寄存器内容：整行放在当前行之后 (p) 或之前 (P)，字符放在光标之后或之前
+func (v *viEditor) put(after bool, n int) {
+	if v.reg == "" && !v.regLine {
+		v.setMsg("E353: 寄存器 \" 里没有东西", true)
+		return
+	}
+	v.change()
+	if v.regLine {
+		var add [][]rune
+		for i := 0; i < n; i++ {
+			for _, l := range strings.Split(v.reg, "\n") {
+				add = append(add, []rune(l))
+			}
+		}
+		at := v.row
+		if after {
+			at++
+		}
+		v.lines = append(v.lines[:at], append(add, v.lines[at:]...)...)
+		v.row = at
+		v.col = firstNonBlank(v.lines[at])
+		if len(add) > 2 {
+			v.setMsg(fmt.Sprintf("多了 %d 行", len(add)), false)
+		}
+		return
+	}
+	if after && len(v.lines[v.row]) > 0 {
+		v.col++
+	}
+	v.insertText(strings.Repeat(v.reg, n))
+	v.col--
+}
+
+// ==========================================
+// 查找
+// ==========================================
+
+// viRegexp 把 vim 的模式转换为 Go 正则，无法编译时按字面量匹配
+func viRegexp(pat string) *regexp.Regexp {
+	p := strings.NewReplacer(`\<`, `\b`, `\>`, `\b`, `\(`, `(`, `\)`, `)`, `\|`, `|`, `\+`, `+`, `\?`, `?`).Replace(pat)
+	if re, err := regexp.Compile(p); err == nil {
+		return re
+	}
+	return regexp.MustCompile(regexp.QuoteMeta(pat))
+}
+
+func (v *viEditor) search(pat string, back bool) {
+	if pat == "" {
+		pat = v.lastSearch
+	}
+	v.lastSearch, v.searchBack = pat, back
+	v.find(pat, back)
+}
+
+// find 从光标处查找下一个 (back 为真时是上一个) 匹配，到达文件尾/首后回绕
+func (v *viEditor) find(pat string, back bool) {
+	if pat == "" {
+		v.setMsg("E35: 没有上一个查找模式", true)
+		return
+	}
+	re := viRegexp(pat)
+	matches := func(row int) []int {
+		s := string(v.lines[row])
+		var cols []int
+		for _, loc := range re.FindAllStringIndex(s, -1) {
+			cols = append(cols, utf8.RuneCountInString(s[:loc[0]]))
+		}
+		return cols
+	}
+	total := len(v.lines)
+	for i := 0; i <= total; i++ {
+		var row int
+		if back {
+			row = ((v.row-i)%total + total) % total
+		} else {
+			row = (v.row + i) % total
+		}
+		cols := matches(row)
+		if back {
+			for j := len(cols) - 1; j >= 0; j-- {
+				if i > 0 || cols[j] < v.col {
+					v.jump(row, cols[j], back)
+					return
+				}
+			}
+		} else {
+			for _, c := range cols {
+				if i > 0 || c > v.col {
+					v.jump(row, c, back)
+					return
+				}
+			}
+		}
+	}
+	v.setMsg("E486: 找不到模式: "+pat, true)
+}
+
+func (v *viEditor) jump(row, col int, back bool) {
+	switch {
+	case !back && (row < v.row || row == v.row && col <= v.col):
+		v.setMsg("已查找到文件结尾，再从开头继续查找", true)
+	case back && (row > v.row || row == v.row && col >= v.col):
+		v.setMsg("已查找到文件开头，再从结尾继续查找", true)
+	default:
+		prefix := "/"
+		if back {
+			prefix = "?"
+		}
+		v.setMsg(prefix+v.lastSearch, false)
+	}
+	v.row, v.col = row, col
+}
+
+// ==========================================
+// 命令行 (:)
+// ==========================================
+
+var subRe = regexp.MustCompile(`\\([0-9&])|&|\$`)
+
+func (v *viEditor) exCommand(cmd string) {
+	cmd = strings.TrimSpace(strings.TrimLeft(cmd, ": "))
+	if cmd == "" {
+		return
+	}
+	if n, err := strconv.Atoi(cmd); err == nil {
+		v.row = max(n, 1) - 1
+		v.clampCursor()
+		v.col = firstNonBlank(v.lines[v.row])
+		return
+	}
+	if cmd == "$" {
+		v.row = len(v.lines) - 1
+		return
+	}
+	if strings.HasPrefix(cmd, "!") {
+		v.shell(strings.TrimSpace(cmd[1:]))
+		return
+	}
+	if strings.HasPrefix(cmd, "%s") || strings.HasPrefix(cmd, "s") && len(cmd) > 1 && !unicode.IsLetter(rune(cmd[1])) {
+		v.substitute(cmd)
+		return
+	}
+
+	i := 0
+	for i < len(cmd) && cmd[i] >= 'a' && cmd[i] <= 'z' {
+		i++
+	}
+	name, rest := cmd[:i], cmd[i:]
+	bang := strings.HasPrefix(rest, "!")
+	if bang {
+		rest = rest[1:]
+	}
+	arg := strings.TrimSpace(rest)
+
+	switch name {
+	case "w", "write":
+		v.write(arg)
+	case "wq", "x", "xit", "exi", "exit", "wqa", "wqall", "xa", "xall":
+		if name[0] == 'x' || name[0] == 'e' {
+			if !v.modified && arg == "" {
+				v.quit = true
+				return
+			}
+		}
+		if v.write(arg) {
+			v.quit = true
+		}
+	case "q", "quit", "qa", "qall", "quita", "quitall":
+		if v.modified && !bang {
+			v.setMsg("E37: 已修改但尚未保存 (可用 ! 强制执行)", true)
+			return
+		}
+		v.quit = true
+	case "e", "edit":
+		switch {
+		case v.modified && !bang:
+			v.setMsg("E37: 已修改但尚未保存 (可用 ! 强制执行)", true)
+		case arg != "":
+			v.open(arg)
+		case v.path != "":
+			v.open(v.name)
+		}
+	case "r", "read":
+		p := v.t.FS.Abs(v.t.expandTilde(arg))
+		lines, _, exists, isDir := v.t.loadText(p)
+		if arg == "" || !exists || isDir {
+			v.setMsg("E484: 无法打开文件 "+arg, true)
+			return
+		}
+		v.change()
+		v.lines = append(v.lines[:v.row+1], append(lines, v.lines[v.row+1:]...)...)
+		v.row++
+	case "d", "de", "del", "delete":
+		v.operator('d', 'd')
+	case "y", "ya", "yank":
+		v.operator('y', 'y')
+	case "set", "se":
+		for _, opt := range strings.Fields(arg) {
+			switch opt {
+			case "nu", "number":
+				v.number = true
+			case "nonu", "nonumber":
+				v.number = false
+			case "nu!", "number!", "invnumber":
+				v.number = !v.number
+			}
+		}
+	case "sh", "shell":
+		v.shell("")
+	case "h", "help":
+		v.setMsg("E149: 抱歉，没有 help.txt 的说明", true)
+	case "noh", "nohlsearch", "sy", "syntax", "filetype", "colo", "colorscheme", "redr", "redraw":
+	default:
+		v.setMsg("E492: 不是编辑器的命令: "+cmd, true)
+	}
+}
+
+// write 保存缓冲区，name 为空时写回当前文件
+func (v *viEditor) write(name string) bool {
+	target := v.path
+	if name != "" {
+		target = v.t.FS.Abs(v.t.expandTilde(name))
+	}
+	if target == "" {
+		v.setMsg("E32: 没有文件名", true)
+		return false
+	}
+	if v.path == "" {
+		v.name, v.path = name, target
+	}
+	display := name
+	if display == "" {
+		display = v.name
+	}
+	_, orig, exists, _ := v.t.loadText(target)
+	content := joinText(v.lines)
+	if err := v.t.saveText(v.tool, target, content, orig); err != nil {
+		v.setMsg(fmt.Sprintf("\"%s\" E212: 无法打开并写入文件", display), true)
+		return false
+	}
+	n := len(v.lines)
+	if content == "" {
+		n = 0
+	}
+	tag := ""
+	if !exists {
+		tag = " [新]"
+	}
+	v.setMsg(fmt.Sprintf("\"%s\"%s %dL, %dB 已写入", display, tag, n, len(content)), false)
+	if target == v.path {
+		v.orig, v.modified = content, false
+	}
+	return true
+}
+
+// substitute 执行 :s/pat/rep/flags 或 :%s/pat/rep/flags
+func (v *viEditor) substitute(cmd string) {
+	from, to := v.row, v.row
+	if strings.HasPrefix(cmd, "%") {
+		from, to = 0, len(v.lines)-1
+		cmd = cmd[1:]
+	}
+	cmd = cmd[1:]
+	if cmd == "" {
+		return
+	}
+	delim := cmd[0]
+	var parts []string
+	var cur strings.Builder
+	for i := 1; i < len(cmd); i++ {
+		switch {
+		case cmd[i] == '\\' && i+1 < len(cmd) && cmd[i+1] == delim:
+			cur.WriteByte(delim)
+			i++
+		case cmd[i] == delim:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(cmd[i])
+		}
+	}
+	parts = append(parts, cur.String())
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	pat, rep, flags := parts[0], parts[1], parts[2]
+	if pat == "" {
+		pat = v.lastSearch
+	}
+	if pat == "" {
+		v.setMsg("E35: 没有上一个查找模式", true)
+		return
+	}
+	v.lastSearch = pat
+	re := viRegexp(pat)
+	// vim 的替换串：& 和 \0 是整个匹配，\1..\9 是分组，\& 是字面量
+	tmpl := subRe.ReplaceAllStringFunc(rep, func(m string) string {
+		switch {
+		case m == "$":
+			return "$$"
+		case m == "&" || m == `\0`:
+			return "${0}"
+		case m == `\&`:
+			return "&"
+		}
+		return "${" + m[1:] + "}"
+	})
+	global := strings.Contains(flags, "g")
+
+	subs, lines := 0, 0
+	changed := false
+	for row := from; row <= to; row++ {
+		s := string(v.lines[row])
+		locs := re.FindAllStringSubmatchIndex(s, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		if !global {
+			locs = locs[:1]
+		}
+		if !changed {
+			v.change()
+			changed = true
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			b.WriteString(s[last:loc[0]])
+			b.Write(re.ExpandString(nil, tmpl, s, loc))
+			last = loc[1]
+		}
+		b.WriteString(s[last:])
+		v.lines[row] = []rune(b.String())
+		subs += len(locs)
+		lines++
+		v.row = row
+	}
+	if subs == 0 {
+		v.setMsg("E486: 找不到模式: "+pat, true)
+		return
+	}
+	v.col = firstNonBlank(v.lines[v.row])
+	if subs > 1 || lines > 1 {
+		v.setMsg(fmt.Sprintf("%d 次替换，共 %d 行", subs, lines), false)
+	}
+}
+
+// shell 执行 :!cmd，暂时离开全屏，输出结束后等待按键
+func (v *viEditor) shell(cmd string) {
+	log.Printf("[Edit] %s: %s shell escape: %q", v.t.Remote, v.tool, cmd)
+	fmt.Fprint(v.out, "\033[?1049l\033[?25h")
+	if cmd != "" {
+		fmt.Fprintf(v.out, ":!%s\n", cmd)
+		v.t.execPipelineTo(cmd, v.out)
+		v.rs.resume()
+		if code := v.t.lastExitCode; code != 0 {
+			fmt.Fprintf(v.out, "\nshell 返回 %d\n", code)
+		}
+	}
+	fmt.Fprint(v.out, "\n请按 ENTER 或其它命令继续")
+	for {
+		if _, ok := v.rs.next(time.Second); ok || v.rs.killed() {
+			break
+		}
+	}
+	fmt.Fprint(v.out, "\033[?1049h\033[H\033[2J")
+}